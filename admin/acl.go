@@ -0,0 +1,299 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/pkg/errors"
+)
+
+const (
+	// max size of request body
+	maxRequestBodySize = 1024 * 1024
+)
+
+var (
+	errAllowListExists     = errors.New("allowlist already exists")
+	errRateRegistryMissing = errors.New("rate limit registry not served in process")
+)
+
+// allowListRequest request to create an allowlist.
+type allowListRequest struct {
+	Name  string          `json:"name"`
+	Rules json.RawMessage `json:"rules"`
+}
+
+func (s *Server) registerAclRoutes() {
+	s.handle(http.MethodGet, "/v1/{network}/acl/allowlists", s.listAllowLists)
+	s.handle(http.MethodPost, "/v1/{network}/acl/allowlists", s.createAllowList)
+	s.handle(http.MethodGet, "/v1/{network}/acl/allowlists/{name}", s.getAllowList)
+	s.handle(http.MethodPut, "/v1/{network}/acl/allowlists/{name}", s.updateAllowList)
+	s.handle(http.MethodDelete, "/v1/{network}/acl/allowlists/{name}", s.deleteAllowList)
+	s.handle(http.MethodPost, "/v1/{network}/acl/apply", s.applyAllowLists)
+}
+
+func (s *Server) listAllowLists(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	allowLists, _, err := space.Store.LoadAclAllowListConfigs()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]*acl.AllowList, 0, len(allowLists))
+	for _, al := range allowLists {
+		result = append(result, al)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getAllowList(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	al, err := space.Store.LoadAclAllowList(params["name"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// version for optimistic concurrency control of subsequent update
+	confName := mysql.AclAllowListConfKeyPrefix + al.Name
+	if cfgmap, err := space.Store.LoadConfig(confName); err == nil {
+		if confVal, ok := cfgmap[confName].(string); ok {
+			setConfigVersion(w, confVal)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, al)
+}
+
+func (s *Server) createAllowList(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, network, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req allowListRequest
+	if err := decodeRequestBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	al, err := acl.ParseAllowList(req.Name, string(req.Rules), network)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validateAllowListRouteGroup(space, al); err != nil {
+		writeRouteGroupError(w, err)
+		return
+	}
+
+	// create only if not existed, even if created concurrently by others
+	confName := mysql.AclAllowListConfKeyPrefix + al.Name
+	err = space.Store.StoreConfigIfMatch(operator(r), confName, string(req.Rules), "")
+
+	if errors.Is(err, mysql.ErrConfigConflict) {
+		writeError(w, http.StatusConflict, errAllowListExists)
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setConfigVersion(w, string(req.Rules))
+	writeJSON(w, http.StatusCreated, al)
+}
+
+func (s *Server) updateAllowList(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, network, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := readRequestBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	al, err := acl.ParseAllowList(params["name"], string(rules), network)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validateAllowListRouteGroup(space, al); err != nil {
+		writeRouteGroupError(w, err)
+		return
+	}
+
+	confName := mysql.AclAllowListConfKeyPrefix + al.Name
+
+	cfgmap, err := space.Store.LoadConfig(confName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(cfgmap) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if version, ok := ifMatchVersion(r); ok {
+		// reject if modified by others since read
+		err = space.Store.StoreConfigIfMatch(operator(r), confName, string(rules), version)
+	} else {
+		err = space.Store.StoreConfigBy(operator(r), confName, string(rules))
+	}
+
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	setConfigVersion(w, string(rules))
+	writeJSON(w, http.StatusOK, al)
+}
+
+func (s *Server) deleteAllowList(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	removed, err := space.Store.DeleteConfigBy(operator(r), mysql.AclAllowListConfKeyPrefix+params["name"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !removed {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// applyAllowLists reloads the persisted configurations to refresh in-memory ACL state at once.
+func (s *Server) applyAllowLists(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if space.RateRegistry == nil {
+		writeError(w, http.StatusServiceUnavailable, errRateRegistryMissing)
+		return
+	}
+
+	// configs might be changed out of process, eg., by command line tools
+	space.Store.InvalidateConfigCache()
+
+	if err := space.RateRegistry.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"applied": true})
+}
+
+// validateAllowListRouteGroup rejects allowlist pinned to unknown node route group, which is
+// neither builtin nor persisted in store.
+func validateAllowListRouteGroup(space *Space, al *acl.AllowList) error {
+	if len(al.RouteGroup) == 0 {
+		return nil
+	}
+
+	return mysql.ValidateNodeRouteGroup(space.Store, al.RouteGroup, space.BuiltinRouteGroups)
+}
+
+func writeRouteGroupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, mysql.ErrNodeRouteGroupNotFound) {
+		writeError(w, http.StatusBadRequest, err)
+	} else {
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// writeStoreError writes error response with status code according to the store error kind.
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, mysql.ErrConfigNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, mysql.ErrDecodeFailed):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, mysql.ErrConfigConflict):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// setConfigVersion sets the config version as `ETag` response header, which could be specified
+// by `If-Match` request header for the subsequent update.
+func setConfigVersion(w http.ResponseWriter, confVal string) {
+	w.Header().Set("ETag", strconv.Quote(mysql.ConfigVersion(confVal)))
+}
+
+// ifMatchVersion returns the expected config version from `If-Match` request header, or false
+// if not specified or matches any.
+func ifMatchVersion(r *http.Request) (string, bool) {
+	etag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if len(etag) == 0 || etag == "*" {
+		return "", false
+	}
+
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), true
+}
+
+func readRequestBody(r *http.Request) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read request body")
+	}
+
+	if len(data) > maxRequestBodySize {
+		return nil, errors.New("request body too large")
+	}
+
+	return data, nil
+}
+
+func decodeRequestBody(r *http.Request, v interface{}) error {
+	data, err := readRequestBody(r)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.WithMessage(err, "malformed request body")
+	}
+
+	return nil
+}
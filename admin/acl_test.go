@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) *Server {
+	config := &mysql.Config{
+		Enabled:     true,
+		Driver:      mysql.DriverSqlite,
+		SqlitePath:  filepath.Join(t.TempDir(), "admin.db"),
+		AutoMigrate: true,
+	}
+
+	db := config.MustOpenOrCreate(mysql.StoreOption{})
+	t.Cleanup(func() { db.Close() })
+
+	return NewServer(&Config{AuthToken: "secret"}, map[string]*Space{
+		"eth": {Store: db, Usages: db},
+	})
+}
+
+func serveTestRequest(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func TestAllowListAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	rules := `{"allowMethods": ["eth_call"]}`
+	createBody := `{"name": "fluent", "rules": ` + rules + `}`
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", createBody)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", createBody)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// malformed allowlists are rejected
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/acl/allowlists/fluent", `{"allowMethod": []}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/acl/allowlists/fluent", `{"contractAddresses": ["0x1"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/acl/allowlists/fluent", `{"disallowMethods": ["eth_call"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/acl/allowlists/fluent", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"DisallowMethods":["eth_call"]`)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/acl/allowlists/fluent", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/acl/allowlists/fluent", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// change history audited
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/configs/acl.allowlist.fluent/history?limit=2", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 2, strings.Count(resp.Body.String(), `"Operator":"admin@`))
+	assert.Contains(t, resp.Body.String(), `"Action":"delete"`)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/configs/acl.allowlist.fluent/history?limit=-1", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/invalidate", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	// network space unavailable
+	resp = serveTestRequest(s, http.MethodGet, "/v1/cfx/acl/allowlists", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// rate limit registry not served
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/acl/apply", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+}
+
+func TestAdminAuthorization(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/eth/acl/allowlists", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestIsLoopbackEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"127.0.0.1:28800", "localhost:28800", "[::1]:28800"} {
+		assert.True(t, isLoopbackEndpoint(endpoint), endpoint)
+	}
+
+	for _, endpoint := range []string{":28800", "0.0.0.0:28800", "10.0.0.1:28800", "[::]:28800", "28800"} {
+		assert.False(t, isLoopbackEndpoint(endpoint), endpoint)
+	}
+}
+
+func TestAdminCrossSiteRequest(t *testing.T) {
+	s := newTestServer(t)
+
+	post := func(contentType string, headers map[string]string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/eth/configs/apply", strings.NewReader(`[]`))
+		req.SetBasicAuth("ops", "secret")
+		req.Header.Set("Content-Type", contentType)
+
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+
+		return recorder.Code
+	}
+
+	// forged by cross-site form post
+	assert.Equal(t, http.StatusUnsupportedMediaType, post("text/plain", nil))
+	assert.Equal(t, http.StatusForbidden, post("text/plain", map[string]string{"Origin": "http://evil.com"}))
+	assert.Equal(t, http.StatusForbidden, post("application/json", map[string]string{"Sec-Fetch-Site": "cross-site"}))
+
+	// same-origin requests, eg., by dashboard
+	assert.Equal(t, http.StatusBadRequest, post("application/json", map[string]string{"Origin": "http://example.com"}))
+	assert.Equal(t, http.StatusBadRequest, post(
+		"application/json; charset=utf-8", map[string]string{"Sec-Fetch-Site": "same-origin"},
+	))
+}
+
+func TestAllowListAdminIfMatch(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", `{"name": "vip", "rules": {}}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/acl/allowlists/vip", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	etag := resp.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	update := func(rules string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/eth/acl/allowlists/vip", strings.NewReader(rules))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	resp = update(`{"allowMethods": ["eth_call"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotEqual(t, etag, resp.Header().Get("ETag"))
+
+	// concurrent update based on the stale version is rejected
+	resp = update(`{"allowMethods": ["eth_getLogs"]}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+func TestAllowListAdminRouteGroup(t *testing.T) {
+	s := newTestServer(t)
+	s.spaces["eth"].BuiltinRouteGroups = []string{"ethhttp", "etharchives"}
+
+	// unknown route group rejected
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", `{"name": "vip", "rules": {"routeGroup": "archive"}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// builtin group of the other space rejected
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", `{"name": "vip", "rules": {"routeGroup": "cfxarchives"}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/acl/allowlists", `{"name": "vip", "rules": {"routeGroup": "etharchives"}}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/acl/allowlists/vip", `{"routeGroup": "archive"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/archive/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/acl/allowlists/vip", `{"routeGroup": "archive"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
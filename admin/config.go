@@ -0,0 +1,267 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// default number of config change audits to list
+	defaultAuditHistoryLimit = 100
+)
+
+func (s *Server) registerConfigRoutes() {
+	s.handle(http.MethodGet, "/v1/config", s.getEffectiveConfig)
+	s.handle(http.MethodGet, "/v1/{network}/configs/{name}/history", s.listConfigHistory)
+	s.handle(http.MethodPost, "/v1/{network}/configs/invalidate", s.invalidateConfigCache)
+	s.handle(http.MethodPost, "/v1/{network}/configs/apply", s.applyConfigs)
+}
+
+// configChangeRequest change of config item to apply in batch.
+type configChangeRequest struct {
+	Name string `json:"name"`
+	// raw config value if JSON string, otherwise the JSON value itself, eg., allowlist rules
+	Value json.RawMessage `json:"value"`
+	// delete the config rather than store
+	Delete bool `json:"delete"`
+	// expected config version (see `ETag` response header) if specified, empty means the config
+	// must not exist yet
+	Version *string `json:"version"`
+}
+
+// configValue returns the raw config value to store.
+func (req *configChangeRequest) configValue() (string, error) {
+	raw := bytes.TrimSpace(req.Value)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", errors.New("value required")
+	}
+
+	if raw[0] != '"' {
+		return string(raw), nil
+	}
+
+	var val string
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return "", errors.WithMessage(err, "malformed value")
+	}
+
+	return val, nil
+}
+
+// applyConfigs applies a set of config changes (eg., a rate limit strategy along with the allowlist
+// and route group referring to it) atomically in a single transaction, so that the gateway would
+// never observe partially applied configs. All changes are validated before any is applied.
+func (s *Server) applyConfigs(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, network, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var reqs []*configChangeRequest
+	if err := decodeRequestBody(r, &reqs); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(reqs) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no config changes to apply"))
+		return
+	}
+
+	changes := make([]*mysql.ConfigChange, 0, len(reqs))
+	names := make(map[string]bool, len(reqs))
+
+	for _, req := range reqs {
+		if err := validateConfigChange(network, req, names); err != nil {
+			writeError(w, http.StatusBadRequest, errors.WithMessagef(err, "invalid change of config %q", req.Name))
+			return
+		}
+
+		change := &mysql.ConfigChange{Name: req.Name, Version: req.Version}
+		if !req.Delete {
+			val, _ := req.configValue()
+			change.Value = &val
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := validateChangedRouteGroups(space, changes); err != nil {
+		writeRouteGroupError(w, err)
+		return
+	}
+
+	if err := space.Store.ApplyConfigsBy(operator(r), changes); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if space.RateRegistry != nil {
+		if err := space.RateRegistry.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"applied": len(changes)})
+}
+
+// validateConfigChange validates the config change, and rejects duplicate changes of the same
+// config which are collected in the specified names set.
+func validateConfigChange(network string, req *configChangeRequest, names map[string]bool) error {
+	switch {
+	case len(req.Name) == 0:
+		return errors.New("name required")
+	case req.Name == mysql.MysqlConfKeyReorgVersion:
+		return errors.New("internal config not allowed to change")
+	case names[req.Name]:
+		return errors.New("duplicate changes")
+	}
+
+	names[req.Name] = true
+
+	if req.Delete {
+		return nil
+	}
+
+	val, err := req.configValue()
+	if err != nil {
+		return err
+	}
+
+	return mysql.ValidateConfig(network, req.Name, val)
+}
+
+// validateChangedRouteGroups rejects the changed allowlists pinned to unknown node route group,
+// taking the route groups created or deleted along with them into account.
+func validateChangedRouteGroups(space *Space, changes []*mysql.ConfigChange) error {
+	changedGroups := make(map[string]bool) // route group => whether exists after applied
+	for _, change := range changes {
+		if strings.HasPrefix(change.Name, mysql.NodeRouteGroupConfKeyPrefix) {
+			changedGroups[change.Name[len(mysql.NodeRouteGroupConfKeyPrefix):]] = change.Value != nil
+		}
+	}
+
+	for _, change := range changes {
+		if change.Value == nil || !strings.HasPrefix(change.Name, mysql.AclAllowListConfKeyPrefix) {
+			continue
+		}
+
+		al, err := mysql.DecodeAclAllowList(0, change.Name, *change.Value)
+		if err != nil {
+			return err
+		}
+
+		exists, changed := changedGroups[al.RouteGroup]
+		if !changed {
+			if err := validateAllowListRouteGroup(space, al); err != nil {
+				return errors.WithMessagef(err, "invalid change of config %q", change.Name)
+			}
+		} else if !exists {
+			return errors.WithMessagef(
+				mysql.ErrNodeRouteGroupNotFound, "invalid change of config %q: group %v deleted", change.Name, al.RouteGroup,
+			)
+		}
+	}
+
+	return nil
+}
+
+// invalidateConfigCache forces the cached configs to be reloaded from store, and applies
+// the reloaded configs immediately if rate limit registry served in process.
+func (s *Server) invalidateConfigCache(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	space.Store.InvalidateConfigCache()
+
+	if space.RateRegistry != nil {
+		if err := space.RateRegistry.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// listConfigHistory lists the change history of config by key in descending order of time.
+func (s *Server) listConfigHistory(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultAuditHistoryLimit
+	if v := r.URL.Query().Get("limit"); len(v) > 0 {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+			return
+		}
+	}
+
+	audits, err := space.Store.LoadConfigAudits(params["name"], limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, audits)
+}
+
+// effectiveConfig fully resolved configuration of the running instance with secrets redacted.
+type effectiveConfig struct {
+	// settings from config file overridden by env vars
+	Settings map[string]interface{} `json:"settings"`
+	// configs persisted in store of each network space, which override the settings at runtime
+	Stores map[string]map[string]interface{} `json:"stores"`
+}
+
+// getEffectiveConfig returns the effective configuration, in which values of secret-like keys
+// (eg., password, token or dsn) are redacted.
+//
+// Note, env vars are only respected for keys that also exist in the config file.
+func (s *Server) getEffectiveConfig(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	result := effectiveConfig{
+		Settings: redact(viper.AllSettings()).(map[string]interface{}),
+		Stores:   make(map[string]map[string]interface{}),
+	}
+
+	for network, space := range s.spaces {
+		if space == nil || space.Store == nil {
+			continue
+		}
+
+		confs, err := space.Store.ListConfigs("")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		values := make(map[string]interface{}, len(confs))
+		for name, raw := range confs {
+			var v interface{}
+			if err := json.Unmarshal([]byte(raw), &v); err != nil {
+				v = raw // not in JSON format
+			}
+
+			values[name] = redact(v)
+		}
+
+		result.Stores[network] = values
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
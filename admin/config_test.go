@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyConfigsAdminApi(t *testing.T) {
+	s := newTestServer(t)
+
+	strategy := mysql.RateLimitStrategyConfKeyPrefix + "vip"
+	allowList := mysql.AclAllowListConfKeyPrefix + "vip"
+	group := mysql.NodeRouteGroupConfKeyPrefix + "vip"
+
+	// nothing applied if any change invalid
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[
+		{"name": "`+group+`", "value": {"nodes": ["http://node"]}},
+		{"name": "`+strategy+`", "value": "malformed"}
+	]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[{"name": "reorg.version", "value": "1"}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	confs, err := s.spaces["eth"].Store.ListConfigs("")
+	assert.NoError(t, err)
+	assert.Empty(t, confs)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[
+		{"name": "`+strategy+`", "value": {}, "version": ""},
+		{"name": "`+allowList+`", "value": "{\"allowMethods\": [\"eth_call\"]}"},
+		{"name": "`+group+`", "value": {"nodes": ["http://node"]}}
+	]`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	confs, err = s.spaces["eth"].Store.ListConfigs("")
+	assert.NoError(t, err)
+	assert.Len(t, confs, 3)
+	assert.Equal(t, `{"allowMethods": ["eth_call"]}`, confs[allowList])
+
+	// conflicted with the existing config
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[
+		{"name": "`+allowList+`", "delete": true},
+		{"name": "`+strategy+`", "value": {}, "version": ""}
+	]`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[{"name": "`+allowList+`", "delete": true}]`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	confs, err = s.spaces["eth"].Store.ListConfigs("")
+	assert.NoError(t, err)
+	assert.NotContains(t, confs, allowList)
+
+	// allowlist pinned to route group, which is deleted or unknown
+	pinned := `{"name": "` + allowList + `", "value": "{\"routeGroup\": \"vip\"}"}`
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[`+pinned+`, {"name": "`+group+`", "delete": true}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[
+		{"name": "`+allowList+`", "value": "{\"routeGroup\": \"other\"}"}
+	]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[`+pinned+`]`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// created along with the route group
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/configs/apply", `[
+		{"name": "`+mysql.AclAllowListConfKeyPrefix+`new", "value": "{\"routeGroup\": \"new\"}"},
+		{"name": "`+mysql.NodeRouteGroupConfKeyPrefix+`new", "value": {"nodes": ["http://node"]}}
+	]`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
@@ -0,0 +1,206 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// max number of recent errors kept for dashboard
+	maxRecentErrors = 100
+
+	// metric name prefixes of RPC methods and rate limit
+	rpcDurationMetricPrefix  = "infura/rpc/duration/"
+	rpcRpcErrMetricPrefix    = "infura/rpc/rate/rpcErr/"
+	rpcNonRpcErrMetricPrefix = "infura/rpc/rate/nonRpcErr/"
+	rateLimitMetricPrefix    = "infura/rpc/ratelimit/"
+)
+
+func (s *Server) registerDashboardRoutes() {
+	s.handle(http.MethodGet, "/dashboard", s.serveDashboard)
+	s.handle(http.MethodGet, "/v1/stats", s.getStats)
+}
+
+// serveDashboard serves the web UI for gateway operations, which queries stats and manages
+// configs through the admin APIs.
+func (s *Server) serveDashboard(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(dashboardPage))
+}
+
+// methodStats live stats of RPC method.
+type methodStats struct {
+	Method    string  `json:"method"`
+	Count     int64   `json:"count"`
+	Qps       float64 `json:"qps"`       // one-minute moving average
+	LatencyMs float64 `json:"latencyMs"` // mean latency
+	P99Ms     float64 `json:"p99Ms"`
+	ErrorRate float64 `json:"errorRate"` // percentage of failures within the time window
+}
+
+// rateLimitStats live stats of rate limit outcome, eg., rejected.
+type rateLimitStats struct {
+	Outcome  string  `json:"outcome"` // rejected, bypassed or softRejected
+	Scope    string  `json:"scope"`   // scope, bypass tier or allowlist
+	Resource string  `json:"resource"`
+	Count    int64   `json:"count"`
+	Rate     float64 `json:"rate"` // one-minute moving average per second
+}
+
+// dashboardStats live stats of the gateway for dashboard.
+type dashboardStats struct {
+	// whether metrics enabled, otherwise all stats are empty
+	MetricsEnabled bool              `json:"metricsEnabled"`
+	TotalQps       float64           `json:"totalQps"`
+	Methods        []*methodStats    `json:"methods"`
+	RateLimits     []*rateLimitStats `json:"rateLimits"`
+	RecentErrors   []*errorEntry     `json:"recentErrors"`
+}
+
+// getStats returns live per-method QPS, rate limit hit rates and recent errors, which are
+// collected from the metrics registry in process.
+func (s *Server) getStats(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	stats := collectStats(metrics.InfuraRegistry)
+	stats.RecentErrors = s.errors.recent()
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func collectStats(registry gethmetrics.Registry) *dashboardStats {
+	stats := dashboardStats{MetricsEnabled: gethmetrics.Enabled}
+	methods := make(map[string]*methodStats)
+
+	method := func(name string) *methodStats {
+		if _, ok := methods[name]; !ok {
+			methods[name] = &methodStats{Method: name}
+		}
+
+		return methods[name]
+	}
+
+	registry.Each(func(name string, i interface{}) {
+		switch v := i.(type) {
+		case gethmetrics.Timer:
+			if !strings.HasPrefix(name, rpcDurationMetricPrefix) {
+				return
+			}
+
+			snapshot := v.Snapshot()
+			if name = strings.TrimPrefix(name, rpcDurationMetricPrefix); name == "all" {
+				stats.TotalQps = snapshot.Rate1()
+				return
+			}
+
+			ms := method(name)
+			ms.Count, ms.Qps = snapshot.Count(), snapshot.Rate1()
+			ms.LatencyMs = snapshot.Mean() / float64(time.Millisecond)
+			ms.P99Ms = snapshot.Percentile(0.99) / float64(time.Millisecond)
+		case metrics.Percentage:
+			for _, prefix := range []string{rpcRpcErrMetricPrefix, rpcNonRpcErrMetricPrefix} {
+				if strings.HasPrefix(name, prefix) {
+					method(strings.TrimPrefix(name, prefix)).ErrorRate += v.Value()
+				}
+			}
+		case gethmetrics.Meter:
+			if !strings.HasPrefix(name, rateLimitMetricPrefix) {
+				return
+			}
+
+			// outcome/scope/resource
+			parts := strings.SplitN(strings.TrimPrefix(name, rateLimitMetricPrefix), "/", 3)
+			if len(parts) != 3 {
+				return
+			}
+
+			snapshot := v.Snapshot()
+			stats.RateLimits = append(stats.RateLimits, &rateLimitStats{
+				Outcome:  parts[0],
+				Scope:    parts[1],
+				Resource: parts[2],
+				Count:    snapshot.Count(),
+				Rate:     snapshot.Rate1(),
+			})
+		}
+	})
+
+	for _, ms := range methods {
+		stats.Methods = append(stats.Methods, ms)
+	}
+
+	// busiest first
+	sort.Slice(stats.Methods, func(i, j int) bool {
+		if stats.Methods[i].Qps != stats.Methods[j].Qps {
+			return stats.Methods[i].Qps > stats.Methods[j].Qps
+		}
+
+		return stats.Methods[i].Method < stats.Methods[j].Method
+	})
+
+	sort.Slice(stats.RateLimits, func(i, j int) bool {
+		return stats.RateLimits[i].Rate > stats.RateLimits[j].Rate
+	})
+
+	return &stats
+}
+
+// errorEntry is a recent warning or error log.
+type errorEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// errorRecorder is a logrus hook to keep the recent warning and error logs in a ring buffer.
+type errorRecorder struct {
+	mu      sync.Mutex
+	entries []*errorEntry
+	next    int // index to write the next entry
+}
+
+func newErrorRecorder(size int) *errorRecorder {
+	return &errorRecorder{entries: make([]*errorEntry, size)}
+}
+
+func (er *errorRecorder) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (er *errorRecorder) Fire(entry *logrus.Entry) error {
+	ee := &errorEntry{Time: entry.Time, Level: entry.Level.String(), Message: entry.Message}
+	if err, ok := entry.Data[logrus.ErrorKey]; ok {
+		ee.Error = fmt.Sprint(err)
+	}
+
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	er.entries[er.next] = ee
+	er.next = (er.next + 1) % len(er.entries)
+
+	return nil
+}
+
+// recent returns the recent errors in descending order of time.
+func (er *errorRecorder) recent() []*errorEntry {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	result := make([]*errorEntry, 0, len(er.entries))
+	for i := 1; i <= len(er.entries); i++ {
+		if ee := er.entries[(er.next-i+len(er.entries))%len(er.entries)]; ee != nil {
+			result = append(result, ee)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,210 @@
+package admin
+
+// dashboardPage is the single page web UI of dashboard, which is embedded as a constant so that
+// no static files need to be deployed along with the binary.
+//
+// Note, backquote must not be used in the page.
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Confura Dashboard</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #222; background: #f6f7f9; }
+  header { background: #1f2937; color: #fff; padding: 12px 24px; display: flex; align-items: center; gap: 16px; }
+  header h1 { font-size: 18px; margin: 0; flex: 1; }
+  main { padding: 16px 24px; display: grid; grid-template-columns: 1fr 1fr; gap: 16px; }
+  section { background: #fff; border-radius: 6px; padding: 12px 16px; box-shadow: 0 1px 2px rgba(0,0,0,.08); overflow: auto; max-height: 420px; }
+  section.wide { grid-column: 1 / span 2; }
+  h2 { font-size: 15px; margin: 4px 0 8px; }
+  table { border-collapse: collapse; width: 100%; font-size: 13px; }
+  th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eee; white-space: nowrap; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  .ok { color: #15803d; } .bad { color: #b91c1c; } .muted { color: #888; }
+  textarea { width: 100%; height: 160px; font-family: monospace; font-size: 12px; box-sizing: border-box; }
+  .row { display: flex; gap: 8px; margin-bottom: 8px; align-items: center; }
+  #message { font-size: 13px; }
+</style>
+</head>
+<body>
+<header>
+  <h1>Confura Dashboard</h1>
+  <label>Network <select id="network"><option>eth</option><option>cfx</option></select></label>
+  <span id="updated" class="muted"></span>
+</header>
+<main>
+  <section class="wide">
+    <h2>RPC methods <span id="total" class="muted"></span></h2>
+    <div id="metricsDisabled" class="bad" hidden>Metrics are disabled, please enable metrics to collect live stats.</div>
+    <table><thead><tr><th>Method</th><th>QPS</th><th>Requests</th><th>Mean (ms)</th><th>P99 (ms)</th><th>Error rate</th></tr></thead>
+    <tbody id="methods"></tbody></table>
+  </section>
+  <section>
+    <h2>Rate limit</h2>
+    <table><thead><tr><th>Outcome</th><th>Scope</th><th>Resource</th><th>Rate (/s)</th><th>Hit rate</th><th>Total</th></tr></thead>
+    <tbody id="rateLimits"></tbody></table>
+  </section>
+  <section>
+    <h2>Upstream nodes</h2>
+    <table><thead><tr><th>Group</th><th>Node</th><th>Status</th><th>Availability</th><th>Mean latency</th></tr></thead>
+    <tbody id="nodes"></tbody></table>
+  </section>
+  <section class="wide">
+    <h2>Recent errors</h2>
+    <table><thead><tr><th>Time</th><th>Level</th><th>Message</th><th>Error</th></tr></thead>
+    <tbody id="errors"></tbody></table>
+  </section>
+  <section class="wide">
+    <h2>Edit configs</h2>
+    <div class="row">
+      <select id="kind">
+        <option value="ratelimit.strategy.">Rate limit strategy</option>
+        <option value="acl.allowlist.">ACL allowlist</option>
+        <option value="noderoute.group.">Node route group</option>
+      </select>
+      <input id="name" placeholder="name">
+      <button id="load">Load</button>
+      <button id="save">Save</button>
+      <button id="remove">Delete</button>
+      <span id="message"></span>
+    </div>
+    <textarea id="value" placeholder="JSON config value"></textarea>
+  </section>
+</main>
+<script>
+(function () {
+  var $ = function (id) { return document.getElementById(id); };
+
+  function esc(v) {
+    return String(v === undefined || v === null ? "" : v).replace(/[&<>"']/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c];
+    });
+  }
+
+  function num(v, digits) { return Number(v || 0).toFixed(digits); }
+
+  function api(method, path, body) {
+    var opts = { method: method, credentials: "same-origin", headers: {} };
+    if (body !== undefined) {
+      opts.headers["Content-Type"] = "application/json";
+      opts.body = JSON.stringify(body);
+    }
+
+    return fetch(path, opts).then(function (resp) {
+      return resp.text().then(function (text) {
+        var data = text ? JSON.parse(text) : null;
+        if (!resp.ok) { throw new Error((data && data.error) || resp.statusText); }
+        return data;
+      });
+    });
+  }
+
+  function network() { return $("network").value; }
+
+  function rows(id, items, render, empty) {
+    $(id).innerHTML = items && items.length ? items.map(render).join("")
+      : "<tr><td colspan='6' class='muted'>" + empty + "</td></tr>";
+  }
+
+  function refreshStats() {
+    return api("GET", "/v1/stats").then(function (stats) {
+      $("metricsDisabled").hidden = stats.metricsEnabled;
+      $("total").textContent = "(" + num(stats.totalQps, 2) + " QPS in total)";
+
+      rows("methods", stats.methods, function (m) {
+        return "<tr><td>" + esc(m.method) + "</td><td class='num'>" + num(m.qps, 2) + "</td><td class='num'>" + m.count +
+          "</td><td class='num'>" + num(m.latencyMs, 1) + "</td><td class='num'>" + num(m.p99Ms, 1) +
+          "</td><td class='num " + (m.errorRate > 1 ? "bad" : "") + "'>" + num(m.errorRate, 2) + "%</td></tr>";
+      }, "No requests yet");
+
+      rows("rateLimits", stats.rateLimits, function (rl) {
+        var hit = stats.totalQps > 0 ? rl.rate * 100 / stats.totalQps : 0;
+        return "<tr><td>" + esc(rl.outcome) + "</td><td>" + esc(rl.scope) + "</td><td>" + esc(rl.resource) +
+          "</td><td class='num'>" + num(rl.rate, 2) + "</td><td class='num'>" + num(hit, 2) + "%</td><td class='num'>" + rl.count + "</td></tr>";
+      }, "No rate limit hits");
+
+      rows("errors", stats.recentErrors, function (e) {
+        return "<tr><td>" + esc(new Date(e.time).toLocaleString()) + "</td><td class='bad'>" + esc(e.level) +
+          "</td><td>" + esc(e.message) + "</td><td>" + esc(e.error) + "</td></tr>";
+      }, "No recent errors");
+    });
+  }
+
+  function refreshNodes() {
+    var base = "/v1/" + network() + "/noderoute/groups";
+
+    return api("GET", base).then(function (groups) {
+      return Promise.all((groups || []).map(function (g) {
+        var group = g.name;
+        return api("GET", base + "/" + encodeURIComponent(group) + "/health").then(function (status) {
+          return status.map(function (s) { return { group: group, status: s }; });
+        }, function (err) {
+          return [{ group: group, error: err.message }];
+        });
+      }));
+    }).then(function (results) {
+      var items = [].concat.apply([], results);
+      rows("nodes", items, function (item) {
+        if (item.error) {
+          return "<tr><td>" + esc(item.group) + "</td><td class='muted'>-</td><td class='bad'>" + esc(item.error) + "</td></tr>";
+        }
+
+        var s = item.status || {};
+        return "<tr><td>" + esc(item.group) + "</td><td>" + esc(s.nodeName) + "</td><td class='" + (s.unhealthy ? "bad'>unhealthy" : "ok'>healthy") +
+          "</td><td class='num'>" + esc(s.availability) + "</td><td class='num'>" + esc(s.meanLatency) + "</td></tr>";
+      }, "No route groups");
+    }, function (err) {
+      rows("nodes", [], null, esc(err.message));
+    });
+  }
+
+  function refresh() {
+    Promise.all([refreshStats(), refreshNodes()]).then(function () {
+      $("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+    }, function (err) {
+      $("updated").textContent = "Failed to refresh: " + err.message;
+    });
+  }
+
+  function confName() { return $("kind").value + $("name").value.trim(); }
+
+  function notify(text, ok) {
+    $("message").textContent = text;
+    $("message").className = ok ? "ok" : "bad";
+  }
+
+  $("load").onclick = function () {
+    api("GET", "/v1/" + network() + "/configs/" + encodeURIComponent(confName()) + "/history?limit=1").then(function (audits) {
+      var latest = audits && audits[0];
+      if (!latest || latest.NewValue === null || latest.NewValue === undefined) { throw new Error("config not found"); }
+      $("value").value = JSON.stringify(JSON.parse(latest.NewValue), null, 2);
+      notify("Loaded", true);
+    }).catch(function (err) { notify(err.message, false); });
+  };
+
+  $("save").onclick = function () {
+    var value;
+    try { value = JSON.parse($("value").value); } catch (err) { return notify("Malformed JSON: " + err.message, false); }
+
+    api("POST", "/v1/" + network() + "/configs/apply", [{ name: confName(), value: value }])
+      .then(function () { notify("Saved", true); refresh(); })
+      .catch(function (err) { notify(err.message, false); });
+  };
+
+  $("remove").onclick = function () {
+    if (!confirm("Delete config " + confName() + "?")) { return; }
+
+    api("POST", "/v1/" + network() + "/configs/apply", [{ name: confName(), "delete": true }])
+      .then(function () { $("value").value = ""; notify("Deleted", true); refresh(); })
+      .catch(function (err) { notify(err.message, false); });
+  };
+
+  $("network").onchange = refresh;
+
+  refresh();
+  setInterval(refresh, 5000);
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboard(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	resp := httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+
+	// browsers authorized by basic auth with token as password
+	req.SetBasicAuth("ops", "secret")
+	resp = httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Header().Get("Content-Type"), "text/html")
+
+	s.errors.Fire(logrus.NewEntry(logrus.StandardLogger()).WithField(logrus.ErrorKey, "boom"))
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/stats", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var stats dashboardStats
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &stats))
+	if assert.Len(t, stats.RecentErrors, 1) {
+		assert.Equal(t, "boom", stats.RecentErrors[0].Error)
+	}
+}
+
+func TestErrorRecorder(t *testing.T) {
+	er := newErrorRecorder(2)
+
+	for _, msg := range []string{"a", "b", "c"} {
+		er.Fire(&logrus.Entry{Message: msg, Data: logrus.Fields{}})
+	}
+
+	recent := er.recent()
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "c", recent[0].Message)
+		assert.Equal(t, "b", recent[1].Message)
+	}
+}
@@ -0,0 +1,742 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	// timeout to query node health from node manager
+	nodeHealthQueryTimeout = 5 * time.Second
+
+	// default window to migrate websocket subscriptions of drained node
+	defaultNodeDrainWindow = 5 * time.Minute
+)
+
+var (
+	errNodeExists         = errors.New("node already exists in route group")
+	errNodeUrlMissing     = errors.New("node url must not be empty")
+	errNodeManagerMissing = errors.New("node manager RPC not configured")
+	errDrainsUnavailable  = errors.New("node drain progress not available in process")
+)
+
+// nodeRouteRequest request to add or update node of route group.
+type nodeRouteRequest struct {
+	Url     string    `json:"url"`
+	Weight  *int      `json:"weight"`
+	Drained *bool     `json:"drained"`
+	Tags    *[]string `json:"tags"` // capability tags, eg., `archive`, `pruned` or `trace`
+}
+
+// nodeDrainRequest request to drain node of route group.
+type nodeDrainRequest struct {
+	Url string `json:"url"`
+	// window to migrate websocket subscriptions, eg., `10m`
+	Window string `json:"window"`
+}
+
+// nodeRouteView node of route group with route options.
+type nodeRouteView struct {
+	Url     string           `json:"url"`
+	Weight  int              `json:"weight"`
+	Drained bool             `json:"drained"`
+	Drain   *mysql.NodeDrain `json:"drain,omitempty"`
+	Tags    []string         `json:"tags,omitempty"`
+	Lag     *uint64          `json:"lag,omitempty"` // blocks behind the group consensus height
+}
+
+// nodeRouteCanaryRequest request to set canary nodes of route group.
+type nodeRouteCanaryRequest struct {
+	Nodes   []string `json:"nodes"`
+	Percent int      `json:"percent"`
+}
+
+// nodeRouteShadowRequest request to set shadow nodes of route group.
+type nodeRouteShadowRequest struct {
+	Nodes []string `json:"nodes"`
+}
+
+// nodeRouteGroupView route group with route options of each node.
+type nodeRouteGroupView struct {
+	Name        string                         `json:"name"`
+	Nodes       []nodeRouteView                `json:"nodes"`
+	Canary      *mysql.NodeRouteCanary         `json:"canary,omitempty"`
+	Shadow      []string                       `json:"shadow,omitempty"`
+	Maintenance []*mysql.NodeMaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// newNodeRouteGroupView creates view of route group, along with the lag of each node in process
+// if reporter available.
+func newNodeRouteGroupView(grp *mysql.NodeRouteGroup, lags NodeLagReporter) *nodeRouteGroupView {
+	view := &nodeRouteGroupView{
+		Name:        grp.Name,
+		Nodes:       make([]nodeRouteView, 0, len(grp.Nodes)),
+		Canary:      grp.Canary,
+		Shadow:      grp.Shadow,
+		Maintenance: grp.Maintenance,
+	}
+
+	for _, url := range grp.Nodes {
+		nv := nodeRouteView{
+			Url: url, Weight: grp.Weight(url), Drained: grp.IsDrained(url), Drain: grp.Drains[url],
+			Tags: grp.Tags[url],
+		}
+
+		if lags != nil {
+			if lag, ok := lags.NodeLag(grp.Name, url); ok {
+				nv.Lag = &lag
+			}
+		}
+
+		view.Nodes = append(view.Nodes, nv)
+	}
+
+	return view
+}
+
+// Route groups are persisted in store, and then synchronized to node managers, which watch
+// the store changes and hot-reload the routers.
+func (s *Server) registerNodeRouteRoutes() {
+	s.handle(http.MethodGet, "/v1/{network}/noderoute/groups", s.listNodeRouteGroups)
+	s.handle(http.MethodGet, "/v1/{network}/noderoute/groups/{group}", s.getNodeRouteGroup)
+	s.handle(http.MethodPost, "/v1/{network}/noderoute/groups/{group}/nodes", s.addGroupNode)
+	s.handle(http.MethodPut, "/v1/{network}/noderoute/groups/{group}/nodes", s.updateGroupNode)
+	s.handle(http.MethodDelete, "/v1/{network}/noderoute/groups/{group}/nodes", s.deleteGroupNode)
+	s.handle(http.MethodGet, "/v1/{network}/noderoute/groups/{group}/health", s.getGroupHealth)
+	s.handle(http.MethodPost, "/v1/{network}/noderoute/groups/{group}/drain", s.drainGroupNode)
+	s.handle(http.MethodGet, "/v1/{network}/noderoute/drains", s.getDrainProgress)
+	s.handle(http.MethodPut, "/v1/{network}/noderoute/groups/{group}/canary", s.setGroupCanary)
+	s.handle(http.MethodDelete, "/v1/{network}/noderoute/groups/{group}/canary", s.deleteGroupCanary)
+	s.handle(http.MethodPut, "/v1/{network}/noderoute/groups/{group}/shadow", s.setGroupShadow)
+	s.handle(http.MethodDelete, "/v1/{network}/noderoute/groups/{group}/shadow", s.deleteGroupShadow)
+	s.handle(http.MethodPut, "/v1/{network}/noderoute/groups/{group}/maintenance", s.setGroupMaintenance)
+	s.handle(http.MethodDelete, "/v1/{network}/noderoute/groups/{group}/maintenance", s.deleteGroupMaintenance)
+	s.handle(http.MethodGet, "/v1/{network}/noderoute/rules", s.getRouteRules)
+	s.handle(http.MethodPut, "/v1/{network}/noderoute/rules", s.setRouteRules)
+}
+
+func (s *Server) listNodeRouteGroups(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	groups, err := space.Store.LoadNodeRouteGroups()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]*nodeRouteGroupView, 0, len(groups))
+	for _, grp := range groups {
+		result = append(result, newNodeRouteGroupView(grp, space.Lags))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getNodeRouteGroup(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// addGroupNode adds node into route group, which is created if not exists yet.
+func (s *Server) addGroupNode(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req nodeRouteRequest
+	if err := decodeNodeRouteRequest(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if grp.IsCanary(req.Url) || grp.IsShadow(req.Url) || !grp.AddNode(req.Url) {
+		writeError(w, http.StatusConflict, errNodeExists)
+		return
+	}
+
+	if err := applyNodeRouteRequest(grp, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// updateGroupNode updates weight, drain state or capability tags of node in route group.
+func (s *Server) updateGroupNode(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req nodeRouteRequest
+	if err := decodeNodeRouteRequest(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if !grp.HasNode(req.Url) {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if err := applyNodeRouteRequest(grp, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// drainGroupNode drains node of route group, which accepts no new traffic at once, while the
+// websocket subscriptions are migrated to other nodes gradually within the drain window. Node
+// could be undrained by updating the drain state.
+func (s *Server) drainGroupNode(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req nodeDrainRequest
+	if err := decodeRequestBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.Url) == 0 {
+		writeError(w, http.StatusBadRequest, errNodeUrlMissing)
+		return
+	}
+
+	window := defaultNodeDrainWindow
+	if len(req.Window) > 0 {
+		if window, err = time.ParseDuration(req.Window); err != nil || window < 0 {
+			writeError(w, http.StatusBadRequest, errors.Errorf("invalid drain window %v", req.Window))
+			return
+		}
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if !grp.HasNode(req.Url) {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	grp.StartDrain(req.Url, window)
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// getDrainProgress returns the drain progress of drained nodes in process, including the number
+// of in-flight requests and websocket subscriptions left, and the subscriptions migrated.
+func (s *Server) getDrainProgress(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if space.Drains == nil {
+		writeError(w, http.StatusServiceUnavailable, errDrainsUnavailable)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, space.Drains.DrainProgress())
+}
+
+// deleteGroupNode removes node of url query parameter from route group, which is deleted if
+// no node left.
+func (s *Server) deleteGroupNode(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	url := r.URL.Query().Get("url")
+	if len(url) == 0 {
+		writeError(w, http.StatusBadRequest, errNodeUrlMissing)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if !grp.RemoveNode(url) {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if len(grp.Nodes) > 0 {
+		err = storeNodeRouteGroup(r, space, grp, version)
+	} else {
+		// compare and delete in case of updated concurrently
+		err = space.Store.ApplyConfigsBy(operator(r), []*mysql.ConfigChange{
+			{Name: mysql.NodeRouteGroupConfKeyPrefix + grp.Name, Version: &version},
+		})
+	}
+
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// setGroupCanary sets canary nodes of route group to take a percentage of traffic, which could
+// be adjusted gradually to roll out or roll back the canary nodes.
+func (s *Server) setGroupCanary(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req nodeRouteCanaryRequest
+	if err := decodeRequestBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.Nodes) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("canary nodes must not be empty"))
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if err := grp.SetCanary(req.Nodes, req.Percent); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// deleteGroupCanary removes canary nodes of route group, so that all traffic is routed back
+// to the group nodes.
+func (s *Server) deleteGroupCanary(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 || grp.Canary == nil {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	grp.SetCanary(nil, 0)
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// setGroupShadow replaces the shadow nodes of route group, to which the RPC gateway mirrors a
+// sampled fraction of read traffic with divergences from the live responses logged.
+func (s *Server) setGroupShadow(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req nodeRouteShadowRequest
+	if err := decodeRequestBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.Nodes) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("shadow nodes must not be empty"))
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if err := grp.SetShadow(req.Nodes); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// deleteGroupShadow removes shadow nodes of route group, so that no traffic is mirrored.
+func (s *Server) deleteGroupShadow(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 || len(grp.Shadow) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	grp.SetShadow(nil)
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// setGroupMaintenance replaces the daily maintenance windows of route group, within which nodes
+// are drained by node managers automatically, eg., for nightly node restarts.
+func (s *Server) setGroupMaintenance(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var windows []*mysql.NodeMaintenanceWindow
+	if err := decodeRequestBody(r, &windows); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(windows) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("maintenance windows must not be empty"))
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if err := grp.SetMaintenance(windows); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNodeRouteGroupView(grp, space.Lags))
+}
+
+// deleteGroupMaintenance removes all maintenance windows of route group.
+func (s *Server) deleteGroupMaintenance(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	grp, version, err := loadNodeRouteGroup(space, params["group"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if len(version) == 0 || len(grp.Maintenance) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	grp.SetMaintenance(nil)
+
+	if err := storeNodeRouteGroup(r, space, grp, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// getRouteRules returns the ordered rules to route RPC methods to route groups.
+func (s *Server) getRouteRules(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := mysql.LoadNodeRouteRules(space.Store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rules == nil {
+		rules = []*mysql.NodeRouteRule{}
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// setRouteRules replaces the ordered rules to route RPC methods to route groups, which are
+// removed if empty. The rules are updated only if not modified since the version specified by
+// `If-Match` request header if any.
+func (s *Server) setRouteRules(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := readRequestBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := mysql.DecodeNodeRouteRules(string(data))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	change := &mysql.ConfigChange{Name: mysql.NodeRouteRulesConfKey}
+	if len(rules) > 0 {
+		val := string(data)
+		change.Value = &val
+	}
+
+	if version, ok := ifMatchVersion(r); ok {
+		change.Version = &version
+	}
+
+	if err := space.Store.ApplyConfigsBy(operator(r), []*mysql.ConfigChange{change}); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if change.Value != nil {
+		setConfigVersion(w, *change.Value)
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// getGroupHealth queries health status of each node in route group from node manager.
+func (s *Server) getGroupHealth(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(space.NodeRPCURL) == 0 {
+		writeError(w, http.StatusServiceUnavailable, errNodeManagerMissing)
+		return
+	}
+
+	client, err := rpc.DialHTTP(space.NodeRPCURL)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), nodeHealthQueryTimeout)
+	defer cancel()
+
+	var status []json.RawMessage
+	if err := client.CallContext(ctx, &status, "node_status", params["group"]); err != nil {
+		writeError(w, http.StatusBadGateway, errors.WithMessage(err, "failed to query node status"))
+		return
+	}
+
+	if len(status) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// loadNodeRouteGroup loads route group by name along with the config version to update in a
+// check-and-set manner, or returns an empty one with empty version if not found.
+func loadNodeRouteGroup(space *Space, name string) (*mysql.NodeRouteGroup, string, error) {
+	confName := mysql.NodeRouteGroupConfKeyPrefix + name
+
+	cfgmap, err := space.Store.LoadConfig(confName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfgVal, ok := cfgmap[confName].(string)
+	if !ok {
+		return &mysql.NodeRouteGroup{Name: name}, "", nil
+	}
+
+	grp, err := mysql.DecodeNodeRouteGroup(0, confName, cfgVal)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return grp, mysql.ConfigVersion(cfgVal), nil
+}
+
+// storeNodeRouteGroup persists route group on behalf of the operator for audit, only if not modified
+// by others since the version loaded, otherwise `mysql.ErrConfigConflict` returned.
+func storeNodeRouteGroup(r *http.Request, space *Space, grp *mysql.NodeRouteGroup, version string) error {
+	cfgVal, err := json.Marshal(grp)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal node route group")
+	}
+
+	confName := mysql.NodeRouteGroupConfKeyPrefix + grp.Name
+	return space.Store.StoreConfigIfMatch(operator(r), confName, string(cfgVal), version)
+}
+
+func decodeNodeRouteRequest(r *http.Request, req *nodeRouteRequest) error {
+	if err := decodeRequestBody(r, req); err != nil {
+		return err
+	}
+
+	if len(req.Url) == 0 {
+		return errNodeUrlMissing
+	}
+
+	return nil
+}
+
+func applyNodeRouteRequest(grp *mysql.NodeRouteGroup, req *nodeRouteRequest) error {
+	if req.Weight != nil {
+		if err := grp.SetWeight(req.Url, *req.Weight); err != nil {
+			return err
+		}
+	}
+
+	if req.Drained != nil {
+		grp.SetDrained(req.Url, *req.Drained)
+	}
+
+	if req.Tags != nil {
+		if err := grp.SetTags(req.Url, *req.Tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeRouteGroupAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node2:8545", "weight": 3}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// invalid weight or unknown node rejected
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "weight": 100}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node3:8545", "drained": true}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "drained": true}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `[{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": true},
+		{"url": "http://node2:8545", "weight": 3, "drained": false}
+	]}]`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/nodes?url=http://node2:8545", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": true}
+	]}`, resp.Body.String())
+
+	// route group deleted once no node left
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/nodes?url=http://node1:8545", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// changes audited
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/configs/noderoute.group.vip/history", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"Action":"delete"`)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip/health", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+}
+
+type testDrainReporter []*mysql.NodeDrainProgress
+
+func (r testDrainReporter) DrainProgress() []*mysql.NodeDrainProgress { return r }
+
+func TestNodeRouteGroupDrainAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/drain", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/drain", `{"url": "http://node1:8545", "window": "soon"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/drain", `{"url": "http://node1:8545", "window": "10m"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"drained":true`)
+	assert.Contains(t, resp.Body.String(), `"windowSecs":600`)
+
+	// drain window removed once undrained
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "drained": false}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false}
+	]}`, resp.Body.String())
+
+	// drain progress not available without RPC server in process
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/drains", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	s.spaces["eth"].Drains = testDrainReporter{{Group: "vip", Url: "http://node1:8545", Subscriptions: 3, Migrated: 2}}
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/drains", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"subscriptions":3,"migrated":2,"completed":false`)
+}
+
+type testLagReporter map[string]uint64
+
+func (r testLagReporter) NodeLag(group, url string) (uint64, bool) {
+	lag, ok := r[group+"/"+url]
+	return lag, ok
+}
+
+func TestNodeRouteGroupLagAdminApis(t *testing.T) {
+	s := newTestServer(t)
+	s.spaces["eth"].Lags = testLagReporter{"vip/http://node1:8545": 12}
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node2:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// lag omitted if unknown
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `[{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false, "lag": 12},
+		{"url": "http://node2:8545", "weight": 1, "drained": false}
+	]}]`, resp.Body.String())
+}
+
+func TestNodeRouteGroupCanaryAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/canary", `{"nodes": ["http://canary:8545"], "percent": 5}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// invalid percent or overlapped nodes rejected
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/canary", `{"nodes": ["http://canary:8545"], "percent": 101}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/canary", `{"nodes": ["http://node1:8545"], "percent": 5}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/canary", `{"nodes": ["http://canary:8545"], "percent": 5}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false}
+	], "canary": {"nodes": ["http://canary:8545"], "percent": 5}}`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://canary:8545"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// canary inherited once group nodes changed
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "weight": 2}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"percent":5`)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/canary", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/canary", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.NotContains(t, resp.Body.String(), "canary")
+}
+
+func TestNodeRouteGroupShadowAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/shadow", `{"nodes": ["http://shadow:8545"]}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// empty or overlapped nodes rejected
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/shadow", `{"nodes": []}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/shadow", `{"nodes": ["http://node1:8545"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/shadow", `{"nodes": ["http://shadow:8545"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false}
+	], "shadow": ["http://shadow:8545"]}`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://shadow:8545"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// shadow inherited once group nodes changed
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "weight": 2}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"shadow":["http://shadow:8545"]`)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/shadow", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/shadow", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.NotContains(t, resp.Body.String(), "shadow")
+}
+
+func TestNodeRouteGroupMaintenanceAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	window := `[{"nodes": ["http://node1:8545"], "start": "02:30", "durationSecs": 1800, "drainWindowSecs": 300}]`
+
+	resp := serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/maintenance", window)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/maintenance", `[{"nodes": ["http://node1:8545"], "start": "25:00", "durationSecs": 1800}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/maintenance", window)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false}
+	], "maintenance": [
+		{"nodes": ["http://node1:8545"], "start": "02:30", "durationSecs": 1800, "drainWindowSecs": 300}
+	]}`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/maintenance", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodDelete, "/v1/eth/noderoute/groups/vip/maintenance", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestNodeRouteGroupTagsAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "tags": ["archive"]}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false, "tags": ["archive"]}
+	]}`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "tags": ["archive", "pruned"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "tags": ["pruned", "trace"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false, "tags": ["pruned", "trace"]}
+	]}`, resp.Body.String())
+
+	// tags kept if not specified
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "weight": 2}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"tags":["pruned","trace"]`)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545", "tags": []}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "tags")
+}
+
+func TestNodeRouteRulesAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/rules", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `[]`, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/rules", `[{"method": "debug_*"}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/rules", `[{"method": "debug_[", "group": "etharchives"}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	rules := `[
+		{"method": "debug_*", "group": "etharchives"},
+		{"method": "eth_sendRawTransaction", "group": "ethsequencer"}
+	]`
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/rules", rules)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/rules", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, rules, resp.Body.String())
+
+	resp = serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/rules", `[]`)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/rules", "")
+	assert.JSONEq(t, `[]`, resp.Body.String())
+}
+
+func TestNodeRouteGroupConcurrentUpdate(t *testing.T) {
+	s := newTestServer(t)
+	space := s.spaces["eth"]
+
+	resp := serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	grp, version, err := loadNodeRouteGroup(space, "vip")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, version)
+
+	// modified by others since loaded
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/vip/nodes", `{"url": "http://node2:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/eth/noderoute/groups/vip/nodes", nil)
+	grp.SetDrained("http://node1:8545", true)
+	assert.ErrorIs(t, storeNodeRouteGroup(req, space, grp, version), mysql.ErrConfigConflict)
+
+	// created by others since loaded
+	grp, version, err = loadNodeRouteGroup(space, "new")
+	assert.NoError(t, err)
+	assert.Empty(t, version)
+
+	resp = serveTestRequest(s, http.MethodPost, "/v1/eth/noderoute/groups/new/nodes", `{"url": "http://node1:8545"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	grp.AddNode("http://node3:8545")
+	assert.ErrorIs(t, storeNodeRouteGroup(req, space, grp, version), mysql.ErrConfigConflict)
+
+	// none of the concurrent changes lost
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/noderoute/groups/vip", "")
+	assert.JSONEq(t, `{"name": "vip", "nodes": [
+		{"url": "http://node1:8545", "weight": 1, "drained": false},
+		{"url": "http://node2:8545", "weight": 1, "drained": false}
+	]}`, resp.Body.String())
+}
+
+func TestNodeRouteRulesIfMatch(t *testing.T) {
+	s := newTestServer(t)
+
+	rules := `[{"method": "debug_*", "group": "etharchives"}]`
+	resp := serveTestRequest(s, http.MethodPut, "/v1/eth/noderoute/rules", rules)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	etag := resp.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/eth/noderoute/rules", strings.NewReader(`[]`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"stale"`)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/eth/noderoute/rules", strings.NewReader(`[]`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
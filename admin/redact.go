@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "REDACTED"
+
+var (
+	// substrings of lower-cased config keys whose values are treated as secrets
+	secretKeyPatterns = []string{
+		"secret", "password", "passwd", "token", "dsn", "salt", "credential", "privatekey", "apikey",
+	}
+
+	// suffixes of lower-cased config keys whose values are treated as secrets, eg., `key` of
+	// TLS client identities or route keys, and `billingKey`
+	secretKeySuffixes = []string{"key"}
+)
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	for _, pattern := range secretKeyPatterns {
+		if strings.Contains(key, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns a copy of the config value, in which values of secret keys are redacted,
+// along with the credentials embedded in URLs (eg., userinfo, path or access token in query).
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if isSecretKey(key) && !isEmptyValue(val) {
+				result[key] = redactedValue
+			} else {
+				result[key] = redact(val)
+			}
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = redact(val)
+		}
+
+		return result
+	case []string:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = redactURL(val)
+		}
+
+		return result
+	case string:
+		return redactURL(v)
+	default:
+		return v
+	}
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// redactURL redacts password of userinfo, path segments and secret query parameters if value is an
+// URL. Path segments are all redacted, since API keys are commonly embedded in path of node URLs
+// (eg., `https://mainnet.infura.io/v3/<apiKey>`) or webhooks, which could not be told apart.
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+
+	redacted := false
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+		redacted = true
+	}
+
+	if path := strings.Trim(u.EscapedPath(), "/"); len(path) > 0 {
+		segments := strings.Split(path, "/")
+		for i := range segments {
+			segments[i] = redactedValue
+		}
+
+		u.Path, u.RawPath = "/"+strings.Join(segments, "/"), ""
+		redacted = true
+	}
+
+	if len(u.Fragment) > 0 {
+		u.Fragment = redactedValue
+		redacted = true
+	}
+
+	if query := u.Query(); len(query) > 0 {
+		for key := range query {
+			if isSecretKey(key) {
+				query.Set(key, redactedValue)
+				redacted = true
+			}
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	if !redacted {
+		return value
+	}
+
+	return u.String()
+}
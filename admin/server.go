@@ -0,0 +1,344 @@
+// Package admin provides REST APIs for operators to manage gateway configurations at runtime.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultShutdownTimeout is default timeout to shutdown admin server.
+	DefaultShutdownTimeout = 3 * time.Second
+)
+
+var (
+	errSpaceUnavailable = errors.New("network space unavailable (only `cfx` and `eth` acceptable)")
+	errNotFound         = errors.New("not found")
+	errUnauthorized     = errors.New("unauthorized")
+	errCrossSite        = errors.New("cross-site request forbidden")
+	errContentType      = errors.New("content type must be application/json")
+)
+
+// Config admin server configurations.
+type Config struct {
+	// served HTTP endpoint, admin server will be disabled if empty
+	Endpoint string
+	// bearer token to authorize admin requests, which could be empty only if endpoint is bound to
+	// loopback interface
+	AuthToken string
+}
+
+// MustNewConfigFromViper creates admin server config from viper, or returns false if disabled.
+func MustNewConfigFromViper() (*Config, bool) {
+	var conf Config
+	viper.MustUnmarshalKey("admin", &conf)
+
+	if len(conf.Endpoint) == 0 {
+		return nil, false
+	}
+
+	if len(conf.AuthToken) == 0 {
+		if !isLoopbackEndpoint(conf.Endpoint) {
+			logrus.WithField("endpoint", conf.Endpoint).Fatal(
+				"Admin server must be protected by authorization token unless bound to loopback interface",
+			)
+		}
+
+		logrus.Warn("Admin server is not protected by authorization token")
+	}
+
+	return &conf, true
+}
+
+// isLoopbackEndpoint checks whether the endpoint is bound to loopback interface only, eg.,
+// `127.0.0.1:28800` or `localhost:28800`, rather than all interfaces, eg., `:28800`.
+func isLoopbackEndpoint(endpoint string) bool {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// Space network space ("cfx" or "eth") resources to manage.
+type Space struct {
+	// store to persist configurations
+	Store mysql.ConfigManager
+	// rate limit registry in process to apply configurations, which is optional
+	RateRegistry *rate.Registry
+	// store to query usage aggregates of API keys, which is optional
+	Usages metering.Store
+	// node manager RPC URL to query node health, which is optional
+	NodeRPCURL string
+	// reporter of node drain progress in process, which is optional
+	Drains NodeDrainReporter
+	// reporter of node lags in process, which is optional
+	Lags NodeLagReporter
+	// builtin node route groups, which are always available to pin allowlist to
+	BuiltinRouteGroups []string
+}
+
+// NodeDrainReporter reports drain progress of full nodes, eg., RPC client provider.
+type NodeDrainReporter interface {
+	DrainProgress() []*mysql.NodeDrainProgress
+}
+
+// NodeLagReporter reports the number of blocks that full node lags behind the consensus height of
+// route group, eg., RPC client provider.
+type NodeLagReporter interface {
+	NodeLag(group, url string) (uint64, bool)
+}
+
+// NodeReporter reports runtime states of full nodes in process.
+type NodeReporter interface {
+	NodeDrainReporter
+	NodeLagReporter
+}
+
+// handlerFunc handles admin request with path parameters.
+type handlerFunc func(w http.ResponseWriter, r *http.Request, params map[string]string)
+
+type route struct {
+	method   string
+	segments []string
+	handler  handlerFunc
+}
+
+// match matches request path segments, and returns the path parameters if matched.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range rt.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Server serves admin REST APIs.
+type Server struct {
+	conf   *Config
+	spaces map[string]*Space // network space => resources
+	routes []*route
+	server *http.Server
+	errors *errorRecorder // recent errors for dashboard
+}
+
+// NewServer creates admin server to manage the specified network spaces.
+func NewServer(conf *Config, spaces map[string]*Space) *Server {
+	s := &Server{conf: conf, spaces: spaces, errors: newErrorRecorder(maxRecentErrors)}
+	s.server = &http.Server{Handler: s}
+
+	s.registerAclRoutes()
+	s.registerConfigRoutes()
+	s.registerUsageRoutes()
+	s.registerNodeRouteRoutes()
+	s.registerDashboardRoutes()
+
+	return s
+}
+
+// handle registers handler for the request method and path pattern, in which `{name}`
+// segment is used as a path parameter placeholder.
+func (s *Server) handle(method, pattern string, handler handlerFunc) {
+	s.routes = append(s.routes, &route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+// ServeHTTP implements `http.Handler`.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		// prompt for credentials to browse dashboard
+		w.Header().Set("WWW-Authenticate", `Basic realm="confura admin"`)
+		writeError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	if status, err := checkMutatingRequest(r); err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	segments := splitPath(r.URL.Path)
+	methodAllowed := true
+
+	for _, rt := range s.routes {
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+
+		if rt.method != r.Method {
+			methodAllowed = false
+			continue
+		}
+
+		rt.handler(w, r, params)
+		return
+	}
+
+	if !methodAllowed {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	writeError(w, http.StatusNotFound, errNotFound)
+}
+
+func (s *Server) authorize(r *http.Request) bool {
+	if len(s.conf.AuthToken) == 0 {
+		return true
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	// basic auth with token as password for browsers, eg., to access dashboard
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.conf.AuthToken)) == 1
+}
+
+// checkMutatingRequest guards against CSRF for requests that change states, since browsers attach
+// the cached basic auth credentials to cross-site requests, eg., `text/plain` form post. So, the
+// mutating requests must be same-origin and JSON typed (or without body), which could not be
+// forged cross-site without CORS preflight.
+func checkMutatingRequest(r *http.Request) (int, error) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return 0, nil
+	}
+
+	if isCrossSite(r) {
+		return http.StatusForbidden, errCrossSite
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if len(contentType) == 0 && r.ContentLength == 0 {
+		return 0, nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, errContentType
+	}
+
+	return 0, nil
+}
+
+// isCrossSite checks if request is sent by browser from other origins, which always sets the
+// `Sec-Fetch-Site` or `Origin` header, while non-browser clients (eg., curl) set neither.
+func isCrossSite(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); len(site) > 0 {
+		return site != "same-origin" && site != "none"
+	}
+
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
+
+// operator returns the operator identity of admin request for config change audit, which
+// falls back to the remote IP address if not specified.
+func operator(r *http.Request) string {
+	return handlers.GetOperator("admin", r)
+}
+
+// space returns the network space resources by path parameter.
+func (s *Server) space(params map[string]string) (*Space, string, error) {
+	network := strings.ToLower(params["network"])
+
+	space, ok := s.spaces[network]
+	if !ok || space == nil || space.Store == nil {
+		return nil, network, errSpaceUnavailable
+	}
+
+	return space, network, nil
+}
+
+// MustServeGraceful serves admin server in a goroutine until graceful shutdown.
+func (s *Server) MustServeGraceful(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	logger := logrus.WithField("endpoint", s.conf.Endpoint)
+
+	listener, err := net.Listen("tcp", s.conf.Endpoint)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to listen to admin endpoint")
+	}
+
+	logger.Info("Admin server started")
+	go s.server.Serve(listener)
+
+	// keep recent errors for dashboard
+	logrus.AddHook(s.errors)
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Failed to shutdown admin server")
+	} else {
+		logger.Info("Succeed to shutdown admin server")
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
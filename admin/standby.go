@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/Conflux-Chain/confura/util/standby"
+	"github.com/pkg/errors"
+)
+
+// RegisterStandby registers routes to inspect and promote the warm standby instance, which
+// are accessible for orchestrators to achieve failover.
+func (s *Server) RegisterStandby(ctl *standby.Controller) {
+	s.handle(http.MethodGet, "/v1/standby", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+
+	s.handle(http.MethodPost, "/v1/standby/promote", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if err := ctl.Promote(); err != nil {
+			writeError(w, promoteErrorStatus(err), err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+}
+
+func promoteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, standby.ErrNotReady):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, standby.ErrAlreadyPromoted), errors.Is(err, standby.ErrStandbyDisabled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/pkg/errors"
+)
+
+var (
+	errUsageStoreMissing = errors.New("usage metering not available")
+)
+
+// usageReport usage report of API key within a date range.
+type usageReport struct {
+	ApiKey            string            `json:"apiKey"`
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+	TotalRequests     uint64            `json:"totalRequests"`
+	TotalComputeUnits uint64            `json:"totalComputeUnits"`
+	Daily             []*metering.Usage `json:"daily"`
+}
+
+func (s *Server) registerUsageRoutes() {
+	s.handle(http.MethodGet, "/v1/{network}/usages/{key}", s.getUsage)
+}
+
+// getUsage reports the daily usages of API key between `from` and `to` dates inclusively,
+// which defaults to the current month till today (UTC).
+func (s *Server) getUsage(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	space, _, err := s.space(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if space.Usages == nil {
+		writeError(w, http.StatusServiceUnavailable, errUsageStoreMissing)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := now.AddDate(0, 0, 1-now.Day()).Format(metering.DateLayout)
+	to := now.Format(metering.DateLayout)
+
+	for name, date := range map[string]*string{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(name)
+		if len(v) == 0 {
+			continue
+		}
+
+		if _, err := time.Parse(metering.DateLayout, v); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Errorf("%v must be a date like 2006-01-02", name))
+			return
+		}
+
+		*date = v
+	}
+
+	usages, err := space.Usages.LoadUsages(params["key"], from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	report := usageReport{ApiKey: params["key"], From: from, To: to, Daily: usages}
+	for _, u := range usages {
+		report.TotalRequests += u.Requests
+		report.TotalComputeUnits += u.ComputeUnits
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
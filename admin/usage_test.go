@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageAdminApis(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.spaces["eth"].Usages.AddUsages([]*metering.Usage{
+		{ApiKey: "key", Date: "2024-01-01", Method: "eth_call", Requests: 2, ComputeUnits: 20},
+		{ApiKey: "key", Date: "2024-01-02", Method: "eth_getLogs", Requests: 1, ComputeUnits: 50},
+	})
+	assert.NoError(t, err)
+
+	resp := serveTestRequest(s, http.MethodGet, "/v1/eth/usages/key?from=2024-01-01&to=2024-01-31", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"totalRequests":3`)
+	assert.Contains(t, resp.Body.String(), `"totalComputeUnits":70`)
+
+	resp = serveTestRequest(s, http.MethodGet, "/v1/eth/usages/key?from=20240101", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
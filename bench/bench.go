@@ -0,0 +1,305 @@
+// Package bench replays traffic profiles against a running gateway to measure latency per method,
+// so as to validate tuning and catch regressions before deployment.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/jsonscan"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// Config configurations of benchmark.
+type Config struct {
+	// gateway endpoint to benchmark, eg., `http://127.0.0.1:22537`
+	Endpoint string
+	// number of concurrent workers
+	Concurrency int
+	// benchmark duration, 0 means unlimited
+	Duration time.Duration
+	// max number of requests, 0 means unlimited. If neither duration nor number of requests
+	// limited, each request of profile is sent once.
+	Requests int64
+	// max QPS over all workers, 0 means unlimited
+	Rate float64
+	// replays recorded traffic at the recorded pace scaled by speed, eg., 2 for twice as fast,
+	// or as fast as possible if 0. Requests are sent in the recorded order, and concurrency is
+	// the max number of in-flight requests.
+	Speed float64
+	// timeout of each request
+	Timeout time.Duration
+	// extra HTTP headers of requests, eg., access token
+	Headers map[string]string
+}
+
+// outcome of request sent
+const (
+	outcomeOk       = iota
+	outcomeRpcError // JSON-RPC error responded
+	outcomeFailure  // transport failure or HTTP error status
+)
+
+// result of request sent
+type result struct {
+	method  string
+	latency time.Duration
+	outcome int
+}
+
+// Runner sends requests of traffic profile to gateway concurrently.
+type Runner struct {
+	conf    Config
+	profile *Profile
+	client  *http.Client
+	limiter *rate.Limiter
+
+	sent int64 // number of requests sent, accessed atomically
+	ids  int64 // JSON-RPC request ID, accessed atomically
+}
+
+func NewRunner(conf Config, profile *Profile) (*Runner, error) {
+	if len(conf.Endpoint) == 0 {
+		return nil, errors.New("endpoint not specified")
+	}
+
+	if conf.Concurrency <= 0 || conf.Duration < 0 || conf.Requests < 0 || conf.Rate < 0 || conf.Timeout <= 0 {
+		return nil, errors.New("invalid benchmark config")
+	}
+
+	if conf.Speed < 0 || (conf.Speed > 0 && (!profile.Sequential || conf.Rate > 0)) {
+		return nil, errors.New("only recorded traffic could be replayed at pace, and without rate limit")
+	}
+
+	// recorded traffic is replayed only once at pace
+	if conf.Speed > 0 && (conf.Requests == 0 || conf.Requests > int64(len(profile.Requests))) {
+		conf.Requests = int64(len(profile.Requests))
+	}
+
+	if conf.Duration == 0 && conf.Requests == 0 {
+		conf.Requests = int64(len(profile.Requests))
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if conf.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(conf.Rate), 1)
+	}
+
+	return &Runner{
+		conf:    conf,
+		profile: profile,
+		client: &http.Client{
+			Timeout: conf.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        conf.Concurrency,
+				MaxIdleConnsPerHost: conf.Concurrency,
+			},
+		},
+		limiter: limiter,
+	}, nil
+}
+
+// Run sends requests until the duration elapsed, number of requests reached or context canceled,
+// and reports latency of requests per method.
+func (r *Runner) Run(ctx context.Context) *Report {
+	if r.conf.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.conf.Duration)
+		defer cancel()
+	}
+
+	results := make(chan result, r.conf.Concurrency)
+	collector := newCollector()
+
+	done := make(chan struct{})
+	go func() {
+		for res := range results {
+			collector.add(res)
+		}
+
+		close(done)
+	}()
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	var lag time.Duration
+
+	if r.conf.Speed > 0 {
+		lag = r.replay(ctx, &wg, results)
+	} else {
+		for i := 0; i < r.conf.Concurrency; i++ {
+			wg.Add(1)
+
+			go func(seed int64) {
+				defer wg.Done()
+				r.work(ctx, rand.New(rand.NewSource(seed)), results)
+			}(start.UnixNano() + int64(i))
+		}
+	}
+
+	wg.Wait()
+	close(results)
+	<-done
+
+	report := collector.report(time.Since(start))
+	report.MaxLagMs = float64(lag) / float64(time.Millisecond)
+
+	return report
+}
+
+// replay sends the recorded requests in order at the recorded pace scaled by speed, and returns
+// the max lag behind schedule, eg., due to too many in-flight requests. Note, requests recorded
+// out of order (by a few milliseconds) or without arrival time are sent along with the previous
+// one.
+func (r *Runner) replay(ctx context.Context, wg *sync.WaitGroup, results chan<- result) time.Duration {
+	inflight := make(chan struct{}, r.conf.Concurrency)
+	start := time.Now()
+	first := r.profile.Requests[0].Time
+
+	var offset, maxLag time.Duration
+
+	for i := int64(0); i < r.conf.Requests; i++ {
+		req := &r.profile.Requests[i]
+
+		// offset of the recorded arrival time, which never goes backwards
+		if first > 0 && req.Time > first {
+			recorded := time.Duration(float64(time.Duration(req.Time-first)*time.Millisecond) / r.conf.Speed)
+			if recorded > offset {
+				offset = recorded
+			}
+		}
+
+		if delay := time.Until(start.Add(offset)); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return maxLag
+			case <-time.After(delay):
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return maxLag
+		case inflight <- struct{}{}:
+		}
+
+		if lag := time.Since(start.Add(offset)); lag > maxLag {
+			maxLag = lag
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-inflight
+				wg.Done()
+			}()
+
+			r.sendAndReport(ctx, req, results)
+		}()
+	}
+
+	return maxLag
+}
+
+// work sends requests in sequence until done.
+func (r *Runner) work(ctx context.Context, rnd *rand.Rand, results chan<- result) {
+	for {
+		if r.conf.Requests > 0 && atomic.AddInt64(&r.sent, 1) > r.conf.Requests {
+			return
+		}
+
+		if err := r.limiter.Wait(ctx); err != nil { // canceled or timed out
+			return
+		}
+
+		if !r.sendAndReport(ctx, r.profile.pick(rnd), results) {
+			return
+		}
+	}
+}
+
+// sendAndReport sends the request and reports the result, or returns false if interrupted, which
+// is not reported.
+func (r *Runner) sendAndReport(ctx context.Context, req *Request, results chan<- result) bool {
+	start := time.Now()
+
+	outcome, err := r.send(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+
+	results <- result{method: req.Method, latency: time.Since(start), outcome: outcome}
+
+	return true
+}
+
+// send sends a JSON-RPC request to gateway, and returns the outcome.
+func (r *Runner) send(ctx context.Context, req *Request) (int, error) {
+	body, err := r.encode(req)
+	if err != nil {
+		return outcomeFailure, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.conf.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return outcomeFailure, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range r.conf.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return outcomeFailure, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return outcomeFailure, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return outcomeFailure, errors.Errorf("unexpected HTTP status %v", resp.StatusCode)
+	}
+
+	if errObj, ok := jsonscan.Field(data, "error"); ok && !jsonscan.IsNull(errObj) {
+		return outcomeRpcError, nil
+	}
+
+	if _, ok := jsonscan.Field(data, "result"); !ok {
+		return outcomeFailure, errors.New("malformed JSON-RPC response")
+	}
+
+	return outcomeOk, nil
+}
+
+// encode encodes the JSON-RPC request body with a unique ID.
+func (r *Runner) encode(req *Request) ([]byte, error) {
+	params := req.Params
+	if len(params) == 0 {
+		params = json.RawMessage("[]")
+	}
+
+	id := strconv.FormatInt(atomic.AddInt64(&r.ids, 1), 10)
+
+	return json.Marshal(struct {
+		Version string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+	}{"2.0", json.RawMessage(id), req.Method, params})
+}
@@ -0,0 +1,243 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := writeFile(t, "profile.yml", `
+requests:
+  - method: eth_blockNumber
+    weight: 3
+  - method: eth_getLogs
+    params: [{fromBlock: "0x1", toBlock: "0x2"}]
+`)
+
+	profile, err := LoadProfile(path)
+	assert.NoError(t, err)
+	assert.False(t, profile.Sequential)
+	assert.Equal(t, 4, profile.totalWeight)
+	assert.Nil(t, profile.Requests[0].Params)
+	assert.JSONEq(t, `[{"fromBlock":"0x1","toBlock":"0x2"}]`, string(profile.Requests[1].Params))
+
+	path = writeFile(t, "profile.json", `{"requests":[{"method":"cfx_epochNumber","params":["latest_mined"]}]}`)
+
+	profile, err = LoadProfile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["latest_mined"]`, string(profile.Requests[0].Params))
+
+	_, err = LoadProfile(writeFile(t, "empty.yml", "requests: []"))
+	assert.Equal(t, errEmptyProfile, err)
+}
+
+func TestLoadRecording(t *testing.T) {
+	path := writeFile(t, "traffic.jsonl", `{"method":"eth_chainId"}
+
+{"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000000","latest"]}
+`)
+
+	profile, err := LoadProfile(path)
+	assert.NoError(t, err)
+	assert.True(t, profile.Sequential)
+	assert.Equal(t, []string{"eth_chainId", "eth_getBalance"}, profile.Methods())
+
+	// replayed in order and repeatedly
+	for _, method := range []string{"eth_chainId", "eth_getBalance", "eth_chainId"} {
+		assert.Equal(t, method, profile.pick(nil).Method)
+	}
+
+	_, err = LoadProfile(writeFile(t, "bad.jsonl", `{"method":`))
+	assert.Error(t, err)
+}
+
+func TestParseMix(t *testing.T) {
+	profile, err := ParseMix("eth_blockNumber=8, eth_getBalance=2,net_version")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, profile.totalWeight)
+	assert.Equal(t, "net_version", profile.Requests[2].Method)
+	assert.Equal(t, 1, profile.Requests[2].Weight)
+	assert.NotNil(t, profile.Requests[1].Params)
+
+	picked := make(map[string]int)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1100; i++ {
+		picked[profile.pick(rnd).Method]++
+	}
+
+	assert.InDelta(t, 800, picked["eth_blockNumber"], 100)
+	assert.InDelta(t, 100, picked["net_version"], 50)
+
+	for _, mix := range []string{"", "eth_blockNumber=x", "eth_blockNumber=-1", "=1"} {
+		_, err := ParseMix(mix)
+		assert.Error(t, err, mix)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("Access-Token"))
+
+		var req struct {
+			ID     json.RawMessage
+			Method string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch req.Method {
+		case "eth_blockNumber":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x1"}`, req.ID)
+		case "eth_call":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"reverted"}}`, req.ID)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	profile, err := ParseMix("eth_blockNumber,eth_call,eth_getLogs")
+	assert.NoError(t, err)
+
+	runner, err := NewRunner(Config{
+		Endpoint:    server.URL,
+		Concurrency: 4,
+		Requests:    300,
+		Timeout:     time.Second,
+		Headers:     map[string]string{"Access-Token": "secret"},
+	}, profile)
+	assert.NoError(t, err)
+
+	report := runner.Run(context.Background())
+	assert.Equal(t, 300, report.Total.Requests)
+	assert.Len(t, report.Methods, 3)
+
+	var failed int
+	for _, mr := range report.Methods {
+		failed += mr.Errors + mr.Failures
+		assert.Positive(t, mr.Requests)
+		assert.True(t, mr.P50 <= mr.P99 && mr.P99 <= mr.Max)
+
+		switch mr.Method {
+		case "eth_blockNumber":
+			assert.Zero(t, mr.ErrorRate())
+		case "eth_call":
+			assert.Equal(t, mr.Requests, mr.Errors)
+		case "eth_getLogs":
+			assert.Equal(t, mr.Requests, mr.Failures)
+		}
+	}
+
+	assert.Equal(t, failed, report.Total.Errors+report.Total.Failures)
+}
+
+func TestRunnerDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	defer server.Close()
+
+	profile, _ := ParseMix("eth_chainId")
+
+	runner, err := NewRunner(Config{
+		Endpoint: server.URL, Concurrency: 2, Duration: 300 * time.Millisecond, Rate: 50, Timeout: time.Second,
+	}, profile)
+	assert.NoError(t, err)
+
+	report := runner.Run(context.Background())
+	assert.InDelta(t, 15, report.Total.Requests, 5) // limited by rate
+	assert.Zero(t, report.Total.ErrorRate())
+}
+
+func TestReportCompare(t *testing.T) {
+	baseline := &Report{Methods: []*MethodReport{
+		{Method: "eth_call", Requests: 100, P50: 10, P99: 50},
+		{Method: "eth_getLogs", Requests: 100, P50: 20, P99: 100},
+	}}
+
+	current := &Report{Methods: []*MethodReport{
+		{Method: "eth_call", Requests: 100, P50: 11, P99: 80},
+		{Method: "eth_getLogs", Requests: 100, Errors: 30, P50: 20, P99: 100},
+		{Method: "eth_chainId", Requests: 100, P50: 1, P99: 1},
+	}}
+
+	assert.Equal(t, []string{
+		"eth_call: P99 latency 50.00ms -> 80.00ms",
+		"eth_getLogs: error rate 0.00% -> 30.00%",
+	}, current.Compare(baseline, 0.2))
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	assert.NoError(t, current.Save(path))
+
+	loaded, err := LoadReport(path)
+	assert.NoError(t, err)
+	assert.Equal(t, current, loaded)
+}
+
+func TestRunnerReplay(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	var arrivals []time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Method string }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		mu.Lock()
+		methods = append(methods, req.Method)
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	defer server.Close()
+
+	// rotated recording, of which the last request is recorded out of order
+	path := writeFile(t, "traffic.jsonl.20261017T000000.000", `{"time":1700000000000,"method":"eth_chainId"}
+{"time":1700000000200,"method":"eth_blockNumber"}
+{"time":1700000000400,"method":"eth_gasPrice"}
+{"time":1700000000390,"method":"net_version"}
+`)
+
+	profile, err := LoadProfile(path)
+	assert.NoError(t, err)
+
+	runner, err := NewRunner(Config{
+		Endpoint: server.URL, Concurrency: 1, Speed: 2, Timeout: time.Second,
+	}, profile)
+	assert.NoError(t, err)
+
+	report := runner.Run(context.Background())
+	assert.Equal(t, 4, report.Total.Requests) // replayed only once
+
+	// replayed in order at twice the recorded pace
+	assert.Equal(t, []string{"eth_chainId", "eth_blockNumber", "eth_gasPrice", "net_version"}, methods)
+	assert.InDelta(t, 100, float64(arrivals[1].Sub(arrivals[0]))/float64(time.Millisecond), 50)
+	assert.InDelta(t, 200, float64(arrivals[2].Sub(arrivals[0]))/float64(time.Millisecond), 50)
+	assert.InDelta(t, 0, float64(arrivals[3].Sub(arrivals[2]))/float64(time.Millisecond), 50)
+
+	// replay at pace of method mix or with rate limit
+	mix, _ := ParseMix("eth_chainId")
+	_, err = NewRunner(Config{Endpoint: server.URL, Concurrency: 1, Speed: 1, Timeout: time.Second}, mix)
+	assert.Error(t, err)
+
+	_, err = NewRunner(Config{Endpoint: server.URL, Concurrency: 1, Speed: 1, Rate: 10, Timeout: time.Second}, profile)
+	assert.Error(t, err)
+}
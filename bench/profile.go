@@ -0,0 +1,263 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// params of synthetic requests for methods in mix, and methods not listed have no params
+	syntheticParams = map[string]string{
+		"eth_getBlockByNumber":                 `["latest", false]`,
+		"eth_getBalance":                       `["0x0000000000000000000000000000000000000000", "latest"]`,
+		"eth_getTransactionCount":              `["0x0000000000000000000000000000000000000000", "latest"]`,
+		"eth_getCode":                          `["0x0000000000000000000000000000000000000000", "latest"]`,
+		"eth_getBlockReceipts":                 `["latest"]`,
+		"eth_getLogs":                          `[{"fromBlock": "latest", "toBlock": "latest"}]`,
+		"eth_feeHistory":                       `["0x4", "latest", [25, 75]]`,
+		"cfx_getBlockByEpochNumber":            `["latest_state", false]`,
+		"cfx_getBalance":                       `["cfx:aaejuaaaaaaaaaaaaaaaaaaaaaaaaaaaaa2mhjju8k", "latest_state"]`,
+		"cfx_getNextNonce":                     `["cfx:aaejuaaaaaaaaaaaaaaaaaaaaaaaaaaaaa2mhjju8k", "latest_state"]`,
+		"cfx_getLogs":                          `[{"fromEpoch": "latest_state", "toEpoch": "latest_state"}]`,
+		"cfx_getBlocksByEpoch":                 `["latest_state"]`,
+		"cfx_getEpochReceipts":                 `["latest_state"]`,
+		"cfx_getConfirmationRiskByHash":        `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"eth_getTransactionByHash":             `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"eth_getTransactionReceipt":            `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"eth_getBlockTransactionCountByHash":   `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"cfx_getTransactionByHash":             `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"cfx_getTransactionReceipt":            `["0x0000000000000000000000000000000000000000000000000000000000000000"]`,
+		"eth_getBlockTransactionCountByNumber": `["latest"]`,
+	}
+
+	errEmptyProfile = errors.New("no requests in traffic profile")
+)
+
+// Request is a JSON-RPC request of traffic profile.
+type Request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	// relative frequency to pick the request, which defaults to 1
+	Weight int `json:"weight,omitempty"`
+	// arrival time in unix milliseconds of recorded request, which paces the replay
+	Time int64 `json:"time,omitempty"`
+}
+
+// Profile is the traffic profile to replay, which is either recorded traffic replayed in order,
+// or a method mix picked randomly by weight.
+type Profile struct {
+	Requests []Request
+	// whether requests are replayed in order, eg., recorded traffic
+	Sequential bool
+
+	totalWeight int
+	cursor      uint64 // index of the next request to replay in order, accessed atomically
+}
+
+// profileFile file format of traffic profile, which is encoded in JSON if the file extension is
+// `.json`, otherwise in YAML.
+type profileFile struct {
+	Requests []struct {
+		Method string      `json:"method" yaml:"method"`
+		Params interface{} `json:"params" yaml:"params"`
+		Weight int         `json:"weight" yaml:"weight"`
+	} `json:"requests" yaml:"requests"`
+}
+
+// LoadProfile loads traffic profile from file. Recorded traffic of a JSON-RPC request per line
+// (with `.jsonl` file extension, or rotated with timestamp suffix) is replayed in order, whereas
+// requests of JSON or YAML profile are picked randomly by weight.
+func LoadProfile(path string) (*Profile, error) {
+	if filepath.Ext(path) == ".jsonl" || strings.Contains(filepath.Base(path), ".jsonl.") {
+		return loadRecording(path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read profile file")
+	}
+
+	var file profileFile
+
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to decode profile file")
+	}
+
+	var profile Profile
+
+	for _, req := range file.Requests {
+		var params json.RawMessage
+
+		if req.Params != nil {
+			if params, err = json.Marshal(normalizeYAML(req.Params)); err != nil {
+				return nil, errors.WithMessagef(err, "invalid params of method %v", req.Method)
+			}
+		}
+
+		profile.Requests = append(profile.Requests, Request{
+			Method: req.Method, Params: params, Weight: req.Weight,
+		})
+	}
+
+	return &profile, profile.init()
+}
+
+// loadRecording loads recorded traffic of a JSON-RPC request per line, and skips blank lines.
+func loadRecording(path string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to open recording file")
+	}
+	defer f.Close()
+
+	profile := Profile{Sequential: true}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024) // allow large requests, eg., batch of raw transactions
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return nil, errors.WithMessagef(err, "invalid request at line %v", line)
+		}
+
+		profile.Requests = append(profile.Requests, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithMessage(err, "failed to read recording file")
+	}
+
+	return &profile, profile.init()
+}
+
+// ParseMix parses the synthetic method mix, eg., `eth_blockNumber=50,eth_getBalance=30`, and the
+// weight defaults to 1 if omitted. Well-known methods are requested with synthetic params, and
+// others without any params.
+func ParseMix(mix string) (*Profile, error) {
+	var profile Profile
+
+	for _, item := range strings.Split(mix, ",") {
+		if item = strings.TrimSpace(item); len(item) == 0 {
+			continue
+		}
+
+		method, weight := item, 1
+
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			w, err := strconv.Atoi(item[i+1:])
+			if err != nil {
+				return nil, errors.Errorf("invalid weight of method mix %v", item)
+			}
+
+			method, weight = item[:i], w
+		}
+
+		req := Request{Method: method, Weight: weight}
+		if params, ok := syntheticParams[method]; ok {
+			req.Params = json.RawMessage(params)
+		}
+
+		profile.Requests = append(profile.Requests, req)
+	}
+
+	return &profile, profile.init()
+}
+
+func (p *Profile) init() error {
+	if len(p.Requests) == 0 {
+		return errEmptyProfile
+	}
+
+	for i := range p.Requests {
+		req := &p.Requests[i]
+
+		if len(req.Method) == 0 {
+			return errors.Errorf("method not specified for request #%v", i)
+		}
+
+		if req.Weight < 0 {
+			return errors.Errorf("negative weight of method %v", req.Method)
+		}
+
+		if req.Weight == 0 {
+			req.Weight = 1
+		}
+
+		p.totalWeight += req.Weight
+	}
+
+	return nil
+}
+
+// Methods returns the distinct methods of profile.
+func (p *Profile) Methods() []string {
+	seen := make(map[string]bool)
+
+	var methods []string
+	for _, req := range p.Requests {
+		if !seen[req.Method] {
+			seen[req.Method] = true
+			methods = append(methods, req.Method)
+		}
+	}
+
+	return methods
+}
+
+// pick returns the next request to replay in order, or a random one by weight.
+func (p *Profile) pick(rnd *rand.Rand) *Request {
+	if p.Sequential {
+		i := atomic.AddUint64(&p.cursor, 1) - 1
+		return &p.Requests[i%uint64(len(p.Requests))]
+	}
+
+	n := rnd.Intn(p.totalWeight)
+	for i := range p.Requests {
+		if n -= p.Requests[i].Weight; n < 0 {
+			return &p.Requests[i]
+		}
+	}
+
+	return &p.Requests[len(p.Requests)-1]
+}
+
+// normalizeYAML converts maps decoded from YAML into JSON compatible ones with string keys.
+func normalizeYAML(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+
+		return m
+	case []interface{}:
+		for i := range val {
+			val[i] = normalizeYAML(val[i])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,206 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/montanaflynn/stats"
+	"github.com/pkg/errors"
+)
+
+// MethodReport latency percentiles (in milliseconds) and outcomes of requests for a method.
+type MethodReport struct {
+	Method   string  `json:"method"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`   // JSON-RPC errors responded
+	Failures int     `json:"failures"` // transport failures or HTTP error status
+	P50      float64 `json:"p50"`
+	P90      float64 `json:"p90"`
+	P99      float64 `json:"p99"`
+	Max      float64 `json:"max"`
+}
+
+// ErrorRate returns the ratio of requests failed or responded with JSON-RPC error.
+func (mr *MethodReport) ErrorRate() float64 {
+	if mr.Requests == 0 {
+		return 0
+	}
+
+	return float64(mr.Errors+mr.Failures) / float64(mr.Requests)
+}
+
+// Report benchmark report of requests per method, along with the total of all methods.
+type Report struct {
+	Duration time.Duration   `json:"duration"`
+	QPS      float64         `json:"qps"`
+	MaxLagMs float64         `json:"maxLagMs,omitempty"` // max lag behind schedule of replay
+	Total    MethodReport    `json:"total"`
+	Methods  []*MethodReport `json:"methods"` // sorted by method name
+}
+
+// LoadReport loads the JSON encoded report from file, eg., a baseline to compare with.
+func LoadReport(path string) (*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read report file")
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode report file")
+	}
+
+	return &report, nil
+}
+
+// Save saves the JSON encoded report into file.
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Print prints the report in table.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Duration: %v, QPS: %.2f", r.Duration.Round(time.Millisecond), r.QPS)
+	if r.MaxLagMs > 0 {
+		fmt.Fprintf(w, ", Max replay lag: %.2fms", r.MaxLagMs)
+	}
+	fmt.Fprint(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Method\tRequests\tErrors\tFailures\tP50(ms)\tP90(ms)\tP99(ms)\tMax(ms)\t")
+
+	for _, mr := range append(r.Methods, &r.Total) {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			mr.Method, mr.Requests, mr.Errors, mr.Failures, mr.P50, mr.P90, mr.P99, mr.Max,
+		)
+	}
+
+	tw.Flush()
+}
+
+// Compare compares the report with the baseline, and returns regressions of methods whose P50
+// or P99 latency grows, or error rate rises, by more than the tolerance ratio (eg., 0.2 for 20%).
+// Methods absent in the baseline are ignored.
+func (r *Report) Compare(baseline *Report, tolerance float64) []string {
+	baseMethods := make(map[string]*MethodReport)
+	for _, mr := range baseline.Methods {
+		baseMethods[mr.Method] = mr
+	}
+
+	var regressions []string
+
+	for _, mr := range r.Methods {
+		base, ok := baseMethods[mr.Method]
+		if !ok {
+			continue
+		}
+
+		if mr.P50 > base.P50*(1+tolerance) {
+			regressions = append(regressions, fmt.Sprintf(
+				"%v: P50 latency %.2fms -> %.2fms", mr.Method, base.P50, mr.P50,
+			))
+		}
+
+		if mr.P99 > base.P99*(1+tolerance) {
+			regressions = append(regressions, fmt.Sprintf(
+				"%v: P99 latency %.2fms -> %.2fms", mr.Method, base.P99, mr.P99,
+			))
+		}
+
+		if mr.ErrorRate() > base.ErrorRate()+tolerance {
+			regressions = append(regressions, fmt.Sprintf(
+				"%v: error rate %.2f%% -> %.2f%%", mr.Method, base.ErrorRate()*100, mr.ErrorRate()*100,
+			))
+		}
+	}
+
+	return regressions
+}
+
+// collector collects results of requests sent per method.
+type collector struct {
+	methods map[string]*methodCollector
+	total   methodCollector
+}
+
+type methodCollector struct {
+	latencies        stats.Float64Data // in milliseconds
+	errors, failures int
+}
+
+func newCollector() *collector {
+	return &collector{methods: make(map[string]*methodCollector)}
+}
+
+func (c *collector) add(res result) {
+	mc, ok := c.methods[res.method]
+	if !ok {
+		mc = &methodCollector{}
+		c.methods[res.method] = mc
+	}
+
+	mc.add(res)
+	c.total.add(res)
+}
+
+func (c *collector) report(elapsed time.Duration) *Report {
+	report := Report{
+		Duration: elapsed,
+		Total:    c.total.report("(total)"),
+	}
+
+	if elapsed > 0 {
+		report.QPS = float64(report.Total.Requests) / elapsed.Seconds()
+	}
+
+	for method, mc := range c.methods {
+		mr := mc.report(method)
+		report.Methods = append(report.Methods, &mr)
+	}
+
+	sort.Slice(report.Methods, func(i, j int) bool {
+		return report.Methods[i].Method < report.Methods[j].Method
+	})
+
+	return &report
+}
+
+func (mc *methodCollector) add(res result) {
+	mc.latencies = append(mc.latencies, float64(res.latency)/float64(time.Millisecond))
+
+	switch res.outcome {
+	case outcomeRpcError:
+		mc.errors++
+	case outcomeFailure:
+		mc.failures++
+	}
+}
+
+func (mc *methodCollector) report(method string) MethodReport {
+	mr := MethodReport{
+		Method:   method,
+		Requests: len(mc.latencies),
+		Errors:   mc.errors,
+		Failures: mc.failures,
+	}
+
+	if len(mc.latencies) > 0 {
+		// errors are impossible for non-empty data and valid percent
+		mr.P50, _ = mc.latencies.Percentile(50)
+		mr.P90, _ = mc.latencies.Percentile(90)
+		mr.P99, _ = mc.latencies.Percentile(99)
+		mr.Max, _ = mc.latencies.Max()
+	}
+
+	return mr
+}
@@ -1,15 +1,13 @@
 package acl
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/acl"
-	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -87,19 +85,30 @@ func addAllowList(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	dbs, err := storeCtx.GetMysqlStore(alCfg.Network)
+	confs, err := storeCtx.GetConfigManager(alCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
+	if len(allowList.RouteGroup) > 0 {
+		builtinGroups := node.BuiltinGroups(alCfg.Network)
+		if alCfg.Network == "eth" { // route groups of additional evm chains
+			builtinGroups = append(builtinGroups, rpc.MustLoadEvmChainRouteGroupsFromViper()...)
+		}
+		if err := mysql.ValidateNodeRouteGroup(confs, allowList.RouteGroup, builtinGroups); err != nil {
+			logrus.WithError(err).Info("Invalid route group of allowlist")
+			return
+		}
+	}
+
 	name := mysql.AclAllowListConfKeyPrefix + allowList.Name
-	cfgmap, err := dbs.LoadConfig(name)
+	cfgmap, err := confs.LoadConfig(name)
 	if err != nil {
 		logrus.WithField("allowList", allowList.Name).
 			WithError(err).
@@ -115,7 +124,7 @@ func addAllowList(cmd *cobra.Command, args []string) {
 	logrus.WithField("allowlist", *allowList).Info("Press the Enter Key to ", op)
 	fmt.Scanln() // wait for Enter Key
 
-	if err := dbs.StoreConfig(name, alCfg.Rules); err != nil {
+	if err := confs.StoreConfigBy(util.CliOperator(), name, alCfg.Rules); err != nil {
 		logrus.WithError(err).Info("Failed to ", op)
 		return
 	}
@@ -133,14 +142,14 @@ func delAllowList(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	dbs, err := storeCtx.GetMysqlStore(alCfg.Network)
+	confs, err := storeCtx.GetConfigManager(alCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
@@ -149,7 +158,7 @@ func delAllowList(cmd *cobra.Command, args []string) {
 	fmt.Scanln() // wait for Enter Key
 
 	name := mysql.AclAllowListConfKeyPrefix + alCfg.Name
-	removed, err := dbs.DeleteConfig(name)
+	removed, err := confs.DeleteConfigBy(util.CliOperator(), name)
 	if err != nil {
 		logrus.WithError(err).Info("Failed to delete the allow list")
 		return
@@ -166,18 +175,18 @@ func listAllowLists(cmd *cobra.Command, args []string) {
 	storeCtx := util.MustInitStoreContext()
 	defer storeCtx.Close()
 
-	dbs, err := storeCtx.GetMysqlStore(alCfg.Network)
+	confs, err := storeCtx.GetConfigManager(alCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
-	allowLists, _, err := dbs.LoadAclAllowListConfigs()
+	allowLists, _, err := confs.LoadAclAllowListConfigs()
 	if err != nil {
 		logrus.WithError(err).Info("Failed to load access control allowlist config")
 		return
@@ -204,39 +213,5 @@ func validateAllowListCmdConfig(validateName, validateRules bool) (*acl.AllowLis
 		return nil, nil
 	}
 
-	al := acl.NewAllowList(0, alCfg.Name)
-	if err := json.Unmarshal([]byte(alCfg.Rules), al); err != nil {
-		return nil, errors.WithMessage(err, "invalid allowlist rules config json")
-	}
-
-	if len(al.AllowMethods) > 0 && len(al.DisallowMethods) > 0 {
-		return nil, errors.New("The allow and disallow method sets can not be set at the same time")
-	}
-
-	if err := validateAllowListContractAddresses(al); err != nil {
-		return nil, errors.WithMessage(err, "invalid allowlist contract addresses")
-	}
-
-	return al, nil
-}
-
-func validateAllowListContractAddresses(al *acl.AllowList) error {
-	if strings.EqualFold(alCfg.Network, "eth") {
-		for _, caddr := range al.ContractAddresses {
-			if !common.IsHexAddress(caddr) {
-				return errors.Errorf("%v is not a hex address", caddr)
-			}
-		}
-		return nil
-	}
-
-	if strings.EqualFold(alCfg.Network, "cfx") {
-		for _, ctAddr := range al.ContractAddresses {
-			if _, err := cfxaddress.NewFromBase32(ctAddr); err != nil {
-				return errors.WithMessagef(err, "%v is not a valid base32 string", ctAddr)
-			}
-		}
-	}
-
-	return nil
+	return acl.ParseAllowList(alCfg.Name, alCfg.Rules, alCfg.Network)
 }
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/admin"
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/standby"
+	"github.com/sirupsen/logrus"
+)
+
+// startAdminServer starts admin server to manage configurations of core space and evm space
+// if admin endpoint configured.
+func startAdminServer(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	storeCtx util.StoreContext,
+	standbyCtl *standby.Controller,
+	cfxRateReg, ethRateReg *rate.Registry,
+	cfxNodes, ethNodes admin.NodeReporter,
+) {
+	conf, ok := admin.MustNewConfigFromViper()
+	if !ok {
+		if standbyCtl.Enabled() {
+			logrus.Fatal("Admin server must be configured to promote the warm standby instance")
+		}
+
+		return
+	}
+
+	spaces := make(map[string]*admin.Space)
+
+	if storeCtx.CfxConf != nil {
+		spaces["cfx"] = &admin.Space{
+			Store:        storeCtx.CfxConf,
+			RateRegistry: cfxRateReg,
+			NodeRPCURL:   node.Config().Router.NodeRPCURL,
+			Drains:       cfxNodes,
+			Lags:         cfxNodes,
+
+			BuiltinRouteGroups: node.BuiltinGroups("cfx"),
+		}
+
+		if storeCtx.CfxDB != nil {
+			spaces["cfx"].Usages = storeCtx.CfxDB
+		}
+	}
+
+	if storeCtx.EthConf != nil {
+		spaces["eth"] = &admin.Space{
+			Store:        storeCtx.EthConf,
+			RateRegistry: ethRateReg,
+			NodeRPCURL:   node.Config().Router.EthNodeRPCURL,
+			Drains:       ethNodes,
+			Lags:         ethNodes,
+
+			// route groups of additional evm chains are resolved by allowlists too
+			BuiltinRouteGroups: append(node.BuiltinGroups("eth"), rpc.MustLoadEvmChainRouteGroupsFromViper()...),
+		}
+
+		if storeCtx.EthDB != nil {
+			spaces["eth"].Usages = storeCtx.EthDB
+		}
+	}
+
+	server := admin.NewServer(conf, spaces)
+	server.RegisterStandby(standbyCtl)
+
+	go server.MustServeGraceful(ctx, wg)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Conflux-Chain/confura/bench"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchConf     bench.Config
+	benchProfile  string
+	benchMix      string
+	benchHeaders  []string
+	benchReport   string
+	benchBaseline string
+	benchTolerate float64
+
+	benchCmd = &cobra.Command{
+		Use:   "bench",
+		Short: "Benchmark a running gateway by replaying traffic profile, and report latency per method",
+		Run:   runBench,
+	}
+)
+
+func init() {
+	benchCmd.Flags().StringVar(
+		&benchConf.Endpoint, "endpoint", "http://127.0.0.1:22537", "gateway endpoint to benchmark",
+	)
+	benchCmd.Flags().StringVar(
+		&benchProfile, "profile", "",
+		"traffic profile file, either recorded traffic (.jsonl) or weighted requests (.json/.yml)",
+	)
+	benchCmd.Flags().StringVar(
+		&benchMix, "mix", "", "synthetic method mix, eg., eth_blockNumber=50,eth_getBalance=30",
+	)
+	benchCmd.Flags().IntVarP(
+		&benchConf.Concurrency, "concurrency", "c", 10, "number of concurrent workers",
+	)
+	benchCmd.Flags().DurationVarP(
+		&benchConf.Duration, "duration", "d", 0, "benchmark duration, 0 means unlimited",
+	)
+	benchCmd.Flags().Int64VarP(
+		&benchConf.Requests, "requests", "n", 0,
+		"max number of requests, 0 means unlimited (each request of profile sent once if duration unlimited too)",
+	)
+	benchCmd.Flags().Float64Var(
+		&benchConf.Rate, "rate", 0, "max QPS over all workers, 0 means unlimited",
+	)
+	benchCmd.Flags().Float64Var(
+		&benchConf.Speed, "speed", 0,
+		"replay recorded traffic at the recorded pace scaled by speed (eg., 2 for twice as fast), or as fast as possible if 0",
+	)
+	benchCmd.Flags().DurationVar(
+		&benchConf.Timeout, "timeout", 10*time.Second, "timeout of each request",
+	)
+	benchCmd.Flags().StringArrayVarP(
+		&benchHeaders, "header", "H", nil, "extra HTTP header of requests, eg., 'Access-Token: xxx'",
+	)
+	benchCmd.Flags().StringVar(
+		&benchReport, "report", "", "file to save the JSON report, eg., as baseline of later benchmarks",
+	)
+	benchCmd.Flags().StringVar(
+		&benchBaseline, "baseline", "", "baseline JSON report to check regressions against",
+	)
+	benchCmd.Flags().Float64Var(
+		&benchTolerate, "tolerance", 0.2, "tolerated ratio of latency growth or error rate rise against baseline",
+	)
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	if (len(benchProfile) == 0) == (len(benchMix) == 0) {
+		logrus.Fatal("Either traffic profile or method mix should be specified")
+	}
+
+	var profile *bench.Profile
+	var err error
+
+	if len(benchProfile) > 0 {
+		profile, err = bench.LoadProfile(benchProfile)
+	} else {
+		profile, err = bench.ParseMix(benchMix)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load traffic profile")
+	}
+
+	var baseline *bench.Report
+	if len(benchBaseline) > 0 {
+		if baseline, err = bench.LoadReport(benchBaseline); err != nil {
+			logrus.WithError(err).Fatal("Failed to load baseline report")
+		}
+	}
+
+	benchConf.Headers = make(map[string]string)
+	for _, header := range benchHeaders {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			logrus.WithField("header", header).Fatal("Invalid HTTP header")
+		}
+
+		benchConf.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	runner, err := bench.NewRunner(benchConf, profile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create benchmark runner")
+	}
+
+	// stop on termination signal, and still report requests sent so far
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	termChan := make(chan os.Signal, 1)
+	signal.Notify(termChan, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		<-termChan
+		logrus.Info("SIGTERM/SIGINT received, benchmark stopped")
+		cancel()
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"endpoint": benchConf.Endpoint,
+		"methods":  len(profile.Methods()),
+		"requests": len(profile.Requests),
+	}).Info("Benchmark started")
+
+	report := runner.Run(ctx)
+	report.Print(os.Stdout)
+
+	if len(benchReport) > 0 {
+		if err := report.Save(benchReport); err != nil {
+			logrus.WithError(err).Fatal("Failed to save benchmark report")
+		}
+	}
+
+	if baseline == nil {
+		return
+	}
+
+	if regressions := report.Compare(baseline, benchTolerate); len(regressions) > 0 {
+		logrus.WithField("regressions", regressions).Fatal("Benchmark regressed against baseline")
+	}
+
+	logrus.Info("No regression against baseline")
+}
@@ -0,0 +1,262 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+type confCmdConfig struct {
+	Network string // network space ("cfx" or "eth")
+	File    string // file to export configs to or import configs from
+}
+
+// confFile file format of exported configs, which is encoded in JSON if the file extension
+// is `.json`, otherwise in YAML.
+type confFile struct {
+	Configs map[string]string `json:"configs" yaml:"configs"` // config name => raw value
+}
+
+var (
+	confCfg confCmdConfig
+
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export all configs from config store to file",
+		Run:   exportConfigs,
+	}
+
+	importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import configs from file into config store atomically, overwriting existing ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			importConfigs(false)
+		},
+	}
+
+	seedCmd = &cobra.Command{
+		Use:   "seed",
+		Short: "Seed config store with configs from file, skipping existing ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			importConfigs(true)
+		},
+	}
+)
+
+func init() {
+	Cmd.AddCommand(exportCmd)
+	hookConfCmdFlags(exportCmd, false)
+
+	Cmd.AddCommand(importCmd)
+	hookConfCmdFlags(importCmd, true)
+
+	Cmd.AddCommand(seedCmd)
+	hookConfCmdFlags(seedCmd, true)
+}
+
+func hookConfCmdFlags(cmd *cobra.Command, fileRequired bool) {
+	cmd.Flags().StringVarP(
+		&confCfg.Network, "network", "n", "cfx", "RPC network space ('cfx' or 'eth')",
+	)
+	cmd.MarkFlagRequired("network")
+
+	if fileRequired {
+		cmd.Flags().StringVarP(&confCfg.File, "file", "f", "", "file to import configs from")
+		cmd.MarkFlagRequired("file")
+	} else {
+		cmd.Flags().StringVarP(&confCfg.File, "file", "f", "", "file to export configs to, or stdout if empty")
+	}
+}
+
+func exportConfigs(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	confs, ok := mustGetConfigManager(storeCtx)
+	if !ok {
+		return
+	}
+
+	values, err := confs.ListConfigs("")
+	if err != nil {
+		logrus.WithError(err).Info("Failed to list configs")
+		return
+	}
+
+	// internal state of sync service is not portable among environments
+	delete(values, mysql.MysqlConfKeyReorgVersion)
+
+	data, err := encodeConfFile(confCfg.File, &confFile{Configs: values})
+	if err != nil {
+		logrus.WithError(err).Info("Failed to encode configs")
+		return
+	}
+
+	if len(confCfg.File) == 0 {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := ioutil.WriteFile(confCfg.File, data, 0600); err != nil {
+		logrus.WithError(err).Info("Failed to write configs to file")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file": confCfg.File, "total": len(values),
+	}).Info("Configs exported")
+}
+
+// importConfigs imports configs from file in a single transaction, which only creates absent
+// configs if seeding.
+func importConfigs(seed bool) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	data, err := ioutil.ReadFile(confCfg.File)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to read configs file")
+		return
+	}
+
+	file, err := decodeConfFile(confCfg.File, data)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to decode configs file")
+		return
+	}
+
+	// validate all configs at first, so that no config is applied if any malformed
+	for _, name := range sortedNames(file.Configs) {
+		if name == mysql.MysqlConfKeyReorgVersion {
+			logrus.WithField("name", name).Info("Internal state config is not importable")
+			return
+		}
+
+		if err := mysql.ValidateConfig(confCfg.Network, name, file.Configs[name]); err != nil {
+			logrus.WithField("name", name).WithError(err).Info("Invalid config")
+			return
+		}
+	}
+
+	confs, ok := mustGetConfigManager(storeCtx)
+	if !ok {
+		return
+	}
+
+	existing, err := confs.ListConfigs("")
+	if err != nil {
+		logrus.WithError(err).Info("Failed to list existing configs")
+		return
+	}
+
+	changes := make(map[string]string)
+	var created, updated, unchanged []string
+
+	for _, name := range sortedNames(file.Configs) {
+		value := file.Configs[name]
+
+		old, ok := existing[name]
+		switch {
+		case !ok:
+			created = append(created, name)
+		case old == value || seed:
+			unchanged = append(unchanged, name)
+			continue
+		default:
+			updated = append(updated, name)
+		}
+
+		changes[name] = value
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"created": created, "updated": updated, "unchanged": len(unchanged),
+	})
+
+	if len(changes) == 0 {
+		logger.Info("No config changed")
+		return
+	}
+
+	if !seed {
+		logger.Info("Press the Enter Key to import the configs")
+		fmt.Scanln() // wait for Enter Key
+	}
+
+	if err := confs.StoreConfigsBy(util.CliOperator(), changes); err != nil {
+		logger.WithError(err).Info("Failed to import configs")
+		return
+	}
+
+	logger.Info("Succeeded to import configs")
+}
+
+func mustGetConfigManager(storeCtx util.StoreContext) (mysql.ConfigManager, bool) {
+	confs, err := storeCtx.GetConfigManager(confCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get config store by network")
+		return nil, false
+	}
+
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
+		return nil, false
+	}
+
+	return confs, true
+}
+
+func isJSONFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func encodeConfFile(path string, file *confFile) ([]byte, error) {
+	if isJSONFile(path) {
+		return json.MarshalIndent(file, "", "  ")
+	}
+
+	return yaml.Marshal(file)
+}
+
+func decodeConfFile(path string, data []byte) (*confFile, error) {
+	var file confFile
+
+	var err error
+	if isJSONFile(path) {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.UnmarshalStrict(data, &file)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(file.Configs) == 0 {
+		return nil, errors.New("no config found")
+	}
+
+	return &file, nil
+}
+
+func sortedNames(configs map[string]string) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,13 @@
+package conf
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "conf",
+	Short: "Config store bootstrap and seeding toolset",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
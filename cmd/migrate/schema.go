@@ -0,0 +1,163 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type migrateCmdConfig struct {
+	Network string // network space ("cfx" or "eth")
+	Target  uint32 // target version to migrate up to
+	Steps   int    // number of migrations to revert
+}
+
+var (
+	migrateCfg migrateCmdConfig
+
+	upCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending schema migrations",
+		Run:   migrateUp,
+	}
+
+	downCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert applied schema migrations",
+		Run:   migrateDown,
+	}
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "List status of all schema migrations",
+		Run:   migrateStatus,
+	}
+)
+
+func init() {
+	Cmd.AddCommand(upCmd)
+	hookMigrateCmdFlags(upCmd)
+	upCmd.Flags().Uint32VarP(
+		&migrateCfg.Target, "target", "t", 0, "target version to migrate up to (0 means the latest)",
+	)
+
+	Cmd.AddCommand(downCmd)
+	hookMigrateCmdFlags(downCmd)
+	downCmd.Flags().IntVarP(
+		&migrateCfg.Steps, "steps", "s", 1, "number of the latest applied migrations to revert",
+	)
+
+	Cmd.AddCommand(statusCmd)
+	hookMigrateCmdFlags(statusCmd)
+}
+
+func hookMigrateCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&migrateCfg.Network, "network", "n", "cfx", "network space ('cfx' or 'eth')",
+	)
+	cmd.MarkFlagRequired("network")
+}
+
+func migrateUp(cmd *cobra.Command, args []string) {
+	// never migrate on open, otherwise the target is ignored
+	storeCtx := util.MustInitStoreContextWithoutMigration()
+	defer storeCtx.Close()
+
+	migrator, ok := getSchemaMigrator(&storeCtx)
+	if !ok {
+		return
+	}
+
+	pending, err := migrator.Pending()
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load pending schema migrations")
+		return
+	}
+
+	if len(pending) == 0 {
+		logrus.Info("No pending schema migrations")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"pending": len(pending), "target": migrateCfg.Target,
+	}).Info("Press the Enter Key to apply schema migrations")
+	fmt.Scanln() // wait for Enter Key
+
+	migrated, err := migrator.Up(migrateCfg.Target)
+	if err != nil {
+		logrus.WithField("applied", len(migrated)).WithError(err).Info("Failed to apply schema migrations")
+		return
+	}
+
+	logrus.WithField("applied", len(migrated)).Info("Succeeded to apply schema migrations")
+}
+
+func migrateDown(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContextWithoutMigration()
+	defer storeCtx.Close()
+
+	if migrateCfg.Steps <= 0 {
+		logrus.WithField("steps", migrateCfg.Steps).Info("Steps must be positive")
+		return
+	}
+
+	migrator, ok := getSchemaMigrator(&storeCtx)
+	if !ok {
+		return
+	}
+
+	logrus.WithField("steps", migrateCfg.Steps).
+		Info("Press the Enter Key to revert schema migrations (data may be lost)!")
+	fmt.Scanln() // wait for Enter Key
+
+	reverted, err := migrator.Down(migrateCfg.Steps)
+	if err != nil {
+		logrus.WithField("reverted", len(reverted)).WithError(err).Info("Failed to revert schema migrations")
+		return
+	}
+
+	logrus.WithField("reverted", len(reverted)).Info("Succeeded to revert schema migrations")
+}
+
+func migrateStatus(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContextWithoutMigration()
+	defer storeCtx.Close()
+
+	migrator, ok := getSchemaMigrator(&storeCtx)
+	if !ok {
+		return
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load schema migrations status")
+		return
+	}
+
+	logrus.WithField("total", len(statuses)).Info("Schema migrations loaded:")
+
+	for _, s := range statuses {
+		logrus.WithFields(logrus.Fields{
+			"name": s.Name, "applied": s.Applied, "appliedAt": s.AppliedAt,
+		}).Info("Migration #", s.Version)
+	}
+}
+
+func getSchemaMigrator(storeCtx *util.StoreContext) (*mysql.SchemaMigrator, bool) {
+	dbs, err := storeCtx.GetMysqlStore(migrateCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return nil, false
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return nil, false
+	}
+
+	return dbs.SchemaMigrator(), true
+}
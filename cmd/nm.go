@@ -58,15 +58,18 @@ func startNodeManagerService(*cobra.Command, []string) {
 		startEvmSpaceNodeServer(ctx, &wg, storeCtx)
 	}
 
+	// propagate config changes made by other replicas
+	storeCtx.WatchConfigs(ctx)
+
 	util.GracefulShutdown(&wg, cancel)
 }
 
 func startNativeSpaceNodeServer(ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext) {
-	server, endpoint := node.Factory().CreatRpcServer(storeCtx.CfxDB)
+	server, endpoint := node.Factory().CreatRpcServer(storeCtx.CfxConf)
 	go server.MustServeGraceful(ctx, wg, endpoint, rpc.ProtocolHttp)
 }
 
 func startEvmSpaceNodeServer(ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext) {
-	server, endpoint := node.EthFactory().CreatRpcServer(storeCtx.EthDB)
+	server, endpoint := node.EthFactory().CreatRpcServer(storeCtx.EthConf)
 	go server.MustServeGraceful(ctx, wg, endpoint, rpc.ProtocolHttp)
 }
@@ -0,0 +1,291 @@
+package noderoute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type groupCmdConfig struct {
+	Network string // network space ("cfx" or "eth")
+	Group   string // route group
+	Url     string // node url
+	Weight  int    // node weight
+}
+
+var (
+	groupCfg groupCmdConfig
+
+	groupCmd = &cobra.Command{
+		Use:   "group",
+		Short: "Node route group management toolset",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	listGroupsCmd = &cobra.Command{
+		Use:   "ls",
+		Short: "List all node route groups",
+		Run:   listGroups,
+	}
+
+	addGroupNodeCmd = &cobra.Command{
+		Use:   "add",
+		Short: "Add node into route group",
+		Run:   addGroupNode,
+	}
+
+	delGroupNodeCmd = &cobra.Command{
+		Use:   "rm",
+		Short: "Remove node from route group",
+		Run:   delGroupNode,
+	}
+
+	weightGroupNodeCmd = &cobra.Command{
+		Use:   "weight",
+		Short: "Set weight of node in route group",
+		Run:   weightGroupNode,
+	}
+
+	drainGroupNodeCmd = &cobra.Command{
+		Use:   "drain",
+		Short: "Mark node as drained so that no new traffic routed",
+		Run: func(cmd *cobra.Command, args []string) {
+			drainGroupNode(true)
+		},
+	}
+
+	undrainGroupNodeCmd = &cobra.Command{
+		Use:   "undrain",
+		Short: "Unmark drained node to accept new traffic again",
+		Run: func(cmd *cobra.Command, args []string) {
+			drainGroupNode(false)
+		},
+	}
+
+	statusGroupCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show health status of nodes in route group from node manager",
+		Run:   statusGroup,
+	}
+)
+
+func init() {
+	Cmd.AddCommand(groupCmd)
+
+	groupCmd.AddCommand(listGroupsCmd)
+	hookGroupCmdFlags(listGroupsCmd, false, false)
+
+	groupCmd.AddCommand(addGroupNodeCmd)
+	hookGroupCmdFlags(addGroupNodeCmd, true, true)
+	addGroupNodeCmd.Flags().IntVarP(
+		&groupCfg.Weight, "weight", "w", mysql.DefaultNodeRouteWeight, "node weight",
+	)
+
+	groupCmd.AddCommand(delGroupNodeCmd)
+	hookGroupCmdFlags(delGroupNodeCmd, true, true)
+
+	groupCmd.AddCommand(weightGroupNodeCmd)
+	hookGroupCmdFlags(weightGroupNodeCmd, true, true)
+	weightGroupNodeCmd.Flags().IntVarP(&groupCfg.Weight, "weight", "w", 0, "node weight")
+	weightGroupNodeCmd.MarkFlagRequired("weight")
+
+	groupCmd.AddCommand(drainGroupNodeCmd)
+	hookGroupCmdFlags(drainGroupNodeCmd, true, true)
+
+	groupCmd.AddCommand(undrainGroupNodeCmd)
+	hookGroupCmdFlags(undrainGroupNodeCmd, true, true)
+
+	groupCmd.AddCommand(statusGroupCmd)
+	hookGroupCmdFlags(statusGroupCmd, true, false)
+}
+
+func listGroups(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	confs, err := storeCtx.GetConfigManager(groupCfg.Network)
+	if err != nil || confs == nil {
+		logrus.WithError(err).Info("Config store is unavailable")
+		return
+	}
+
+	routeGroups, err := confs.LoadNodeRouteGroups()
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load node route groups")
+		return
+	}
+
+	if len(routeGroups) == 0 {
+		logrus.Info("No node route group found")
+		return
+	}
+
+	logrus.WithField("total", len(routeGroups)).Info("Node route groups loaded:")
+
+	for name, grp := range routeGroups {
+		for _, url := range grp.Nodes {
+			logrus.WithFields(logrus.Fields{
+				"url":     url,
+				"weight":  grp.Weight(url),
+				"drained": grp.IsDrained(url),
+			}).Info("Group ", name)
+		}
+	}
+}
+
+func addGroupNode(cmd *cobra.Command, args []string) {
+	updateGroup(func(grp *mysql.NodeRouteGroup) error {
+		if !grp.AddNode(groupCfg.Url) {
+			return errors.New("node already exists in route group")
+		}
+
+		return grp.SetWeight(groupCfg.Url, groupCfg.Weight)
+	}, true)
+}
+
+func delGroupNode(cmd *cobra.Command, args []string) {
+	updateGroup(func(grp *mysql.NodeRouteGroup) error {
+		if !grp.RemoveNode(groupCfg.Url) {
+			return errors.New("node does not exist in route group")
+		}
+
+		return nil
+	}, false)
+}
+
+func weightGroupNode(cmd *cobra.Command, args []string) {
+	updateGroup(func(grp *mysql.NodeRouteGroup) error {
+		if !grp.HasNode(groupCfg.Url) {
+			return errors.New("node does not exist in route group")
+		}
+
+		return grp.SetWeight(groupCfg.Url, groupCfg.Weight)
+	}, false)
+}
+
+func drainGroupNode(drained bool) {
+	updateGroup(func(grp *mysql.NodeRouteGroup) error {
+		if !grp.HasNode(groupCfg.Url) {
+			return errors.New("node does not exist in route group")
+		}
+
+		grp.SetDrained(groupCfg.Url, drained)
+		return nil
+	}, false)
+}
+
+// updateGroup updates node route group in store, which will be hot-reloaded by node managers.
+func updateGroup(update func(grp *mysql.NodeRouteGroup) error, createIfAbsent bool) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	confs, err := storeCtx.GetConfigManager(groupCfg.Network)
+	if err != nil || confs == nil {
+		logrus.WithError(err).Info("Config store is unavailable")
+		return
+	}
+
+	routeGroups, err := confs.LoadNodeRouteGroups(groupCfg.Group)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to load node route group")
+		return
+	}
+
+	grp, ok := routeGroups[groupCfg.Group]
+	if !ok {
+		if !createIfAbsent {
+			logrus.WithField("group", groupCfg.Group).Info("Node route group does not exist")
+			return
+		}
+
+		grp = &mysql.NodeRouteGroup{Name: groupCfg.Group}
+	}
+
+	if err := update(grp); err != nil {
+		logrus.WithField("config", groupCfg).WithError(err).Info("Failed to update node route group")
+		return
+	}
+
+	logrus.WithField("routeGroup", grp).Info("Press the Enter Key to update node route group")
+	fmt.Scanln() // wait for Enter Key
+
+	if len(grp.Nodes) > 0 {
+		err = confs.StoreNodeRouteGroupBy(util.CliOperator(), grp)
+	} else { // delete node route group since no nodes left
+		err = confs.DelNodeRouteGroupBy(util.CliOperator(), grp.Name)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Info("Failed to save node route group")
+		return
+	}
+
+	logrus.Info("Node route group updated")
+}
+
+func statusGroup(cmd *cobra.Command, args []string) {
+	nodeRpcUrl := node.Config().Router.NodeRPCURL
+	if strings.EqualFold(groupCfg.Network, "eth") {
+		nodeRpcUrl = node.Config().Router.EthNodeRPCURL
+	}
+
+	if len(nodeRpcUrl) == 0 {
+		logrus.Info("Node manager RPC is not configured")
+		return
+	}
+
+	client, err := rpc.DialHTTP(nodeRpcUrl)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to dial node manager RPC")
+		return
+	}
+	defer client.Close()
+
+	var status []json.RawMessage
+	if err := client.Call(&status, "node_status", groupCfg.Group); err != nil {
+		logrus.WithError(err).Info("Failed to query node status")
+		return
+	}
+
+	if len(status) == 0 {
+		logrus.WithField("group", groupCfg.Group).Info("No node found in route group")
+		return
+	}
+
+	for _, s := range status {
+		fmt.Println(string(s))
+	}
+}
+
+func hookGroupCmdFlags(grpCmd *cobra.Command, hookGroup, hookUrl bool) {
+	{ // network space
+		grpCmd.Flags().StringVarP(
+			&groupCfg.Network, "network", "n", "cfx", "network space ('cfx' or 'eth')",
+		)
+		grpCmd.MarkFlagRequired("network")
+	}
+
+	if hookGroup { // route group
+		grpCmd.Flags().StringVarP(
+			&groupCfg.Group, "group", "g", "", "route group",
+		)
+		grpCmd.MarkFlagRequired("group")
+	}
+
+	if hookUrl { // node url
+		grpCmd.Flags().StringVarP(
+			&groupCfg.Url, "url", "u", "", "node url",
+		)
+		grpCmd.MarkFlagRequired("url")
+	}
+}
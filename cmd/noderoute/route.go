@@ -72,7 +72,14 @@ func addRoute(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	routeGroups, err := dbs.LoadNodeRouteGroups(routeCfg.Group)
+	// route groups might be managed by standalone config store
+	confs, err := storeCtx.GetConfigManager(routeCfg.Network)
+	if err != nil || confs == nil {
+		logrus.WithError(err).Info("Config store is unavailable")
+		return
+	}
+
+	routeGroups, err := confs.LoadNodeRouteGroups(routeCfg.Group)
 	if err != nil {
 		logrus.WithError(err).Info("Failed to load node route group")
 		return
@@ -19,6 +19,8 @@ type keysetCmdConfig struct {
 	LimitKey  string         // rate limit key
 	LimitType rate.LimitType // rate limit type (0 - by key, 1 - by IP)
 	Memo      string         // rate limit memo
+	Secret    string         // HMAC secret to verify signed requests
+	Clear     bool           // whether to clear HMAC secret
 }
 
 var (
@@ -48,6 +50,12 @@ var (
 		Run:   listKeys,
 	}
 
+	secretCmd = &cobra.Command{
+		Use:   "sk",
+		Short: "Set HMAC secret of rate limit key to verify signed requests",
+		Run:   setSecret,
+	}
+
 	genKeyCmd = &cobra.Command{
 		Use:   "gk",
 		Short: "Generate random rate limit key",
@@ -68,6 +76,10 @@ func init() {
 	Cmd.AddCommand(listKeysCmd)
 	hookKeysetCmdFlags(listKeysCmd, true, true, false, false)
 
+	Cmd.AddCommand(secretCmd)
+	hookKeysetCmdFlags(secretCmd, true, false, true, false)
+	hookKeysetCmdSecretFlags(secretCmd)
+
 	Cmd.AddCommand(genKeyCmd)
 	hookKeysetCmdFlags(genKeyCmd, false, false, false, true)
 }
@@ -93,7 +105,14 @@ func addKey(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	strategy, err := dbs.LoadRateLimitStrategy(keysetCfg.Strategy)
+	// strategies and allowlists might be managed by standalone config store
+	confs, err := storeCtx.GetConfigManager(keysetCfg.Network)
+	if err != nil || confs == nil {
+		logrus.WithError(err).Info("Config store is unavailable")
+		return
+	}
+
+	strategy, err := confs.LoadRateLimitStrategy(keysetCfg.Strategy)
 	if err != nil {
 		logrus.WithError(err).Info("Failed to load rate limit strategy")
 		return
@@ -107,7 +126,7 @@ func addKey(cmd *cobra.Command, args []string) {
 
 	var acl acl.AllowList
 	if len(keysetCfg.AllowList) > 0 {
-		allowList, err := dbs.LoadAclAllowList(keysetCfg.AllowList)
+		allowList, err := confs.LoadAclAllowList(keysetCfg.AllowList)
 		if err != nil {
 			logrus.WithError(err).Info("Failed to load access control allowlist")
 			return
@@ -202,7 +221,14 @@ func listKeys(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	strategy, err := dbs.LoadRateLimitStrategy(keysetCfg.Strategy)
+	// strategies and allowlists might be managed by standalone config store
+	confs, err := storeCtx.GetConfigManager(keysetCfg.Network)
+	if err != nil || confs == nil {
+		logrus.WithError(err).Info("Config store is unavailable")
+		return
+	}
+
+	strategy, err := confs.LoadRateLimitStrategy(keysetCfg.Strategy)
 	if err != nil {
 		logrus.Info("Invalid rate limit strategy")
 		return
@@ -227,7 +253,7 @@ func listKeys(cmd *cobra.Command, args []string) {
 	allowLists := make(map[uint32]*acl.AllowList)
 	for i, k := range keysets {
 		if k.AclID > 0 && allowLists[k.AclID] == nil {
-			acl, err := dbs.LoadAclAllowListById(k.AclID)
+			acl, err := confs.LoadAclAllowListById(k.AclID)
 			if err != nil {
 				logrus.WithField("aclID", k.AclID).WithError(err).Info("Failed to load allowlist")
 			} else {
@@ -245,6 +271,52 @@ func listKeys(cmd *cobra.Command, args []string) {
 	}
 }
 
+func setSecret(cmd *cobra.Command, args []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	err := validateKeysetCmdConfig(false, true, false)
+	if err != nil {
+		logrus.WithField("config", keysetCfg).WithError(err).Info("Invalid command config")
+		return
+	}
+
+	dbs, err := storeCtx.GetMysqlStore(keysetCfg.Network)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to get mysql store by network")
+		return
+	}
+
+	if dbs == nil {
+		logrus.Info("DB store is unavailable")
+		return
+	}
+
+	secret := strings.TrimSpace(keysetCfg.Secret)
+	if len(secret) == 0 && !keysetCfg.Clear { // generate random secret if not provided
+		if secret, err = rate.GenerateRandomSecret(); err != nil {
+			logrus.WithError(err).Info("Failed to generate random secret")
+			return
+		}
+	}
+
+	updated, err := dbs.SetRateLimitSecret(keysetCfg.LimitKey, secret)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to set secret of the rate limit key")
+		return
+	}
+
+	logger := logrus.WithField("limitKey", keysetCfg.LimitKey)
+	switch {
+	case !updated:
+		logger.Info("Rate limit key not existed")
+	case len(secret) == 0:
+		logger.Info("Secret of rate limit key cleared")
+	default:
+		logger.WithField("secret", secret).Info("Secret of rate limit key set")
+	}
+}
+
 func genKey(cmd *cobra.Command, args []string) {
 	err := validateKeysetCmdConfig(false, false, true)
 	if err != nil {
@@ -334,3 +406,12 @@ func hookKeysetCmdAllowListFlag(keysetCmd *cobra.Command) {
 		&keysetCfg.AllowList, "acl", "l", "", "allowlist used",
 	)
 }
+
+func hookKeysetCmdSecretFlags(keysetCmd *cobra.Command) {
+	keysetCmd.Flags().StringVar(
+		&keysetCfg.Secret, "secret", "", "HMAC secret, or random one generated if empty",
+	)
+	keysetCmd.Flags().BoolVar(
+		&keysetCfg.Clear, "clear", false, "clear HMAC secret to disable request signing",
+	)
+}
@@ -84,19 +84,19 @@ func addStrategy(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	dbs, err := storeCtx.GetMysqlStore(stratCfg.Network)
+	confs, err := storeCtx.GetConfigManager(stratCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
 	name := mysql.RateLimitStrategyConfKeyPrefix + stratCfg.Name
-	cfgmap, err := dbs.LoadConfig(name)
+	cfgmap, err := confs.LoadConfig(name)
 	if err != nil {
 		logrus.WithField("strategy", stratCfg.Name).
 			WithError(err).
@@ -104,9 +104,13 @@ func addStrategy(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// version to update in a check-and-set manner, empty means not existed yet
+	var version string
+
 	op := "add a new rate limit strategy"
-	if len(cfgmap) > 0 {
+	if val, ok := cfgmap[name].(string); ok {
 		op = "update an existed rate limit strategy"
+		version = mysql.ConfigVersion(val)
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -115,7 +119,8 @@ func addStrategy(cmd *cobra.Command, args []string) {
 	}).Info("Press the Enter Key to ", op)
 	fmt.Scanln() // wait for Enter Key
 
-	if err := dbs.StoreConfig(name, stratCfg.Rules); err != nil {
+	// reject if modified by others while waiting for confirmation
+	if err := confs.StoreConfigIfMatch(util.CliOperator(), name, stratCfg.Rules, version); err != nil {
 		logrus.WithError(err).Info("Failed to ", op)
 		return
 	}
@@ -133,14 +138,14 @@ func delStrategy(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	dbs, err := storeCtx.GetMysqlStore(stratCfg.Network)
+	confs, err := storeCtx.GetConfigManager(stratCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
@@ -149,7 +154,7 @@ func delStrategy(cmd *cobra.Command, args []string) {
 	fmt.Scanln() // wait for Enter Key
 
 	name := mysql.RateLimitStrategyConfKeyPrefix + stratCfg.Name
-	removed, err := dbs.DeleteConfig(name)
+	removed, err := confs.DeleteConfigBy(util.CliOperator(), name)
 	if err != nil {
 		logrus.WithError(err).Info("Failed to delete the rate limit strategy")
 		return
@@ -166,18 +171,18 @@ func listStrategies(cmd *cobra.Command, args []string) {
 	storeCtx := util.MustInitStoreContext()
 	defer storeCtx.Close()
 
-	dbs, err := storeCtx.GetMysqlStore(stratCfg.Network)
+	confs, err := storeCtx.GetConfigManager(stratCfg.Network)
 	if err != nil {
-		logrus.WithError(err).Info("Failed to get mysql store by network")
+		logrus.WithError(err).Info("Failed to get config store by network")
 		return
 	}
 
-	if dbs == nil {
-		logrus.Info("DB store is unavailable")
+	if confs == nil {
+		logrus.Info("Config store is unavailable")
 		return
 	}
 
-	strategies, _, err := dbs.LoadRateLimitStrategyConfigs()
+	strategies, _, err := confs.LoadRateLimitStrategyConfigs()
 	if err != nil {
 		logrus.WithError(err).Info("Failed to load rate limit strategies")
 		return
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/report"
+)
+
+var (
+	// daily usage report options
+	reportOpt struct {
+		once bool
+		date string
+	}
+
+	reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Start daily usage report service to deliver usages and errors of API keys per allowlist",
+		Run:   startReportService,
+	}
+)
+
+func init() {
+	reportCmd.Flags().BoolVar(
+		&reportOpt.once, "once", false, "report usages of the previous UTC day once and exit, eg., by cron job",
+	)
+
+	reportCmd.Flags().StringVar(
+		&reportOpt.date, "date", "", "report usages of the UTC date (2006-01-02) once and exit, eg., to backfill",
+	)
+
+	rootCmd.AddCommand(reportCmd)
+}
+
+func startReportService(*cobra.Command, []string) {
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	var spaces []*report.Space
+
+	if storeCtx.CfxConf != nil && storeCtx.CfxDB != nil {
+		spaces = append(spaces, &report.Space{
+			Name: "cfx", AllowLists: storeCtx.CfxConf, Keys: storeCtx.CfxDB, Usages: storeCtx.CfxDB,
+		})
+	}
+
+	if storeCtx.EthConf != nil && storeCtx.EthDB != nil {
+		spaces = append(spaces, &report.Space{
+			Name: "eth", AllowLists: storeCtx.EthConf, Keys: storeCtx.EthDB, Usages: storeCtx.EthDB,
+		})
+	}
+
+	if len(spaces) == 0 {
+		logrus.Fatal("No database configured to report usages")
+	}
+
+	reporter := report.MustNewReporterFromViper(spaces...)
+	if reporter == nil {
+		logrus.Fatal("Daily usage report not enabled")
+	}
+
+	if !reportOpt.once && len(reportOpt.date) == 0 {
+		util.StartAndGracefulShutdown(reporter.Run)
+		return
+	}
+
+	date := reportOpt.date
+	if len(date) == 0 {
+		date = time.Now().UTC().AddDate(0, 0, -1).Format(metering.DateLayout)
+	} else if _, err := time.Parse(metering.DateLayout, date); err != nil {
+		logrus.WithError(err).Fatal("Invalid report date")
+	}
+
+	if err := reporter.ReportDay(context.Background(), date); err != nil {
+		logrus.WithError(err).WithField("date", date).Fatal("Failed to deliver daily usage reports")
+	}
+
+	logrus.WithField("date", date).Info("Daily usage reports delivered")
+}
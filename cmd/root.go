@@ -7,17 +7,22 @@ import (
 	"sync"
 
 	"github.com/Conflux-Chain/confura/cmd/acl"
+	"github.com/Conflux-Chain/confura/cmd/conf"
+	"github.com/Conflux-Chain/confura/cmd/migrate"
 	"github.com/Conflux-Chain/confura/cmd/noderoute"
 	"github.com/Conflux-Chain/confura/cmd/ratelimit"
 	"github.com/Conflux-Chain/confura/cmd/test"
 	"github.com/Conflux-Chain/confura/cmd/util"
 	"github.com/Conflux-Chain/confura/config"
+	"github.com/Conflux-Chain/confura/util/standby"
+	"github.com/Conflux-Chain/confura/util/telemetry"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
 	flagVersion          bool
+	flagValidateConfig   bool
 	nodeServerEnabled    bool
 	rpcServerEnabled     bool
 	syncServerEnabled    bool
@@ -36,6 +41,11 @@ func init() {
 		&flagVersion, "version", "v", false, "If true, print version and exit",
 	)
 
+	// validate configurations and exit
+	rootCmd.Flags().BoolVar(
+		&flagValidateConfig, "validate-config", false, "If true, validate configurations and exit",
+	)
+
 	// boot flag for node management service
 	rootCmd.Flags().BoolVar(
 		&nodeServerEnabled, "nm", false, "whether to start node management service",
@@ -56,10 +66,17 @@ func init() {
 		&vfilterServerEnabled, "vf", false, "whether to start virtual filter service",
 	)
 
+	// environment to scope configs in stores for all commands
+	rootCmd.PersistentFlags().StringVar(
+		&util.ConfigEnv, "env", "", "environment (eg., prod or staging) to scope configs in stores",
+	)
+
 	rootCmd.AddCommand(test.Cmd)
 	rootCmd.AddCommand(ratelimit.Cmd)
 	rootCmd.AddCommand(noderoute.Cmd)
 	rootCmd.AddCommand(acl.Cmd)
+	rootCmd.AddCommand(migrate.Cmd)
+	rootCmd.AddCommand(conf.Cmd)
 }
 
 func start(cmd *cobra.Command, args []string) {
@@ -69,6 +86,12 @@ func start(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// validate configurations
+	if flagValidateConfig {
+		validateConfig()
+		return
+	}
+
 	if !nodeServerEnabled && !rpcServerEnabled && !syncServerEnabled && !vfilterServerEnabled {
 		logrus.Fatal("No services started")
 	}
@@ -87,9 +110,16 @@ func start(cmd *cobra.Command, args []string) {
 	}
 
 	if rpcServerEnabled { // start RPC
-		startNativeSpaceRpcServer(ctx, wg, storeCtx)
-		startEvmSpaceRpcServer(ctx, wg, storeCtx)
-		startNativeSpaceBridgeRpcServer(ctx, wg)
+		standbyCtl := standby.MustNewControllerFromViper()
+
+		cfxRateReg, cfxNodes := startNativeSpaceRpcServer(ctx, wg, storeCtx, standbyCtl)
+		ethRateReg, ethNodes := startEvmSpaceRpcServer(ctx, wg, storeCtx, standbyCtl)
+		startNativeSpaceBridgeRpcServer(ctx, wg, standbyCtl)
+
+		startAdminServer(ctx, wg, storeCtx, standbyCtl, cfxRateReg, ethRateReg, cfxNodes, ethNodes)
+
+		// run preflight checks if started in warm standby mode
+		go standbyCtl.Run(ctx)
 	}
 
 	if nodeServerEnabled { // start node management
@@ -101,9 +131,38 @@ func start(cmd *cobra.Command, args []string) {
 		startEvmSpaceVirtualFilterServer(ctx, wg, storeCtx)
 	}
 
+	// propagate config changes made by other replicas
+	storeCtx.WatchConfigs(ctx)
+
+	// start telemetry reporter if opted in
+	if reporter, ok := telemetry.MustNewReporterFromViper(enabledServices()...); ok {
+		go reporter.Run(ctx, wg)
+	}
+
 	util.GracefulShutdown(wg, cancel)
 }
 
+// enabledServices returns the names of all enabled services.
+func enabledServices() (services []string) {
+	if nodeServerEnabled {
+		services = append(services, "nm")
+	}
+
+	if rpcServerEnabled {
+		services = append(services, "rpc")
+	}
+
+	if syncServerEnabled {
+		services = append(services, "sync")
+	}
+
+	if vfilterServerEnabled {
+		services = append(services, "vf")
+	}
+
+	return services
+}
+
 // Execute is the command line entrypoint.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -9,15 +9,24 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/Conflux-Chain/confura/admin"
 	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/config"
+	"github.com/Conflux-Chain/confura/engine"
+	"github.com/Conflux-Chain/confura/grpcserver"
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc"
 	"github.com/Conflux-Chain/confura/rpc/handler"
+	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/store/redis"
 	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/Conflux-Chain/confura/util/relay"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/slowlog"
+	"github.com/Conflux-Chain/confura/util/standby"
 	vfclient "github.com/Conflux-Chain/confura/virtualfilter/client"
 	viperutil "github.com/Conflux-Chain/go-conflux-util/viper"
 )
@@ -67,27 +76,155 @@ func startRpcService(*cobra.Command, []string) {
 	storeCtx := util.MustInitStoreContext()
 	defer storeCtx.Close()
 
+	var cfxRateReg, ethRateReg *rate.Registry
+	var cfxNodes, ethNodes admin.NodeReporter
+
+	standbyCtl := standby.MustNewControllerFromViper()
+
 	if rpcOpt.cfxEnabled { // start core space RPC
-		startNativeSpaceRpcServer(ctx, &wg, storeCtx)
+		cfxRateReg, cfxNodes = startNativeSpaceRpcServer(ctx, &wg, storeCtx, standbyCtl)
 	}
 
 	if rpcOpt.ethEnabled { // start evm space RPC
-		startEvmSpaceRpcServer(ctx, &wg, storeCtx)
+		rpc.SetGatewayVersion(config.Version, config.GitCommit)
+		ethRateReg, ethNodes = startEvmSpaceRpcServer(ctx, &wg, storeCtx, standbyCtl)
 	}
 
 	if rpcOpt.cfxBridgeEnabled { // start core space bridge RPC
-		startNativeSpaceBridgeRpcServer(ctx, &wg)
+		startNativeSpaceBridgeRpcServer(ctx, &wg, standbyCtl)
+	}
+
+	// start admin server
+	startAdminServer(ctx, &wg, storeCtx, standbyCtl, cfxRateReg, ethRateReg, cfxNodes, ethNodes)
+
+	// start Engine API proxy
+	if proxy := engine.MustNewProxyFromViper(); proxy != nil {
+		go proxy.MustServeGraceful(ctx, &wg)
 	}
 
+	// propagate config changes made by other replicas
+	storeCtx.WatchConfigs(ctx)
+
+	// run preflight checks if started in warm standby mode
+	go standbyCtl.Run(ctx)
+
 	util.GracefulShutdown(&wg, cancel)
 }
 
-// startNativeSpaceRpcServer starts core space RPC server
-func startNativeSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext) {
+// routeGroupResolver resolves custom node route group by the authenticated route key.
+func routeGroupResolver(getRouteGroup func(key string) (node.Group, bool)) rate.RouteGroupResolver {
+	return func(ctx context.Context) (string, bool) {
+		authId, ok := handlers.GetAuthIdFromContext(ctx)
+		if !ok {
+			return "", false
+		}
+
+		grp, ok := getRouteGroup(authId)
+		return string(grp), ok
+	}
+}
+
+// nodeRouteReader returns the db store to load node routes, or nil if db not available so as to
+// avoid a non-nil interface holding nil pointer.
+func nodeRouteReader(db *mysql.MysqlStore) mysql.NodeRouteReader {
+	if db == nil {
+		return nil
+	}
+
+	return db
+}
+
+// reloadOnConfigChange reloads rate limit registry at once if config store supports to watch
+// config changes, eg., Consul config store.
+func reloadOnConfigChange(confStore mysql.ConfigManager, rateReg *rate.Registry) {
+	cs, ok := confStore.(mysql.ConfigWatcher)
+	if !ok {
+		return
+	}
+
+	cs.OnChange(func() {
+		if err := rateReg.Reload(); err != nil {
+			logrus.WithError(err).Error("Failed to reload rate limit configs on change")
+		}
+	})
+}
+
+// reloadQuotasOnConfigChange reloads usage quotas at once if config store supports to watch
+// config changes.
+func reloadQuotasOnConfigChange(confStore mysql.ConfigManager, meter *metering.Meter) {
+	if cs, ok := confStore.(mysql.ConfigWatcher); ok {
+		cs.OnChange(func() {
+			if err := meter.ReloadQuotas(confStore.LoadUsageQuotaConfigs); err != nil {
+				logrus.WithError(err).Error("Failed to reload usage quotas on change")
+			}
+		})
+	}
+}
+
+// autoReloadRouteRules reloads method route rules of client provider from config store
+// periodically, and at once on change if config store supports to watch config changes.
+func autoReloadRouteRules(confStore mysql.ConfigManager, provider interface {
+	ReloadRouteRules(loader func() ([]*mysql.NodeRouteRule, error)) error
+	AutoReloadRouteRules(interval time.Duration, loader func() ([]*mysql.NodeRouteRule, error))
+}) {
+	if confStore == nil {
+		return
+	}
+
+	loader := func() ([]*mysql.NodeRouteRule, error) {
+		return mysql.LoadNodeRouteRules(confStore)
+	}
+
+	go provider.AutoReloadRouteRules(15*time.Second, loader)
+
+	if cs, ok := confStore.(mysql.ConfigWatcher); ok {
+		cs.OnChange(func() {
+			if err := provider.ReloadRouteRules(loader); err != nil {
+				logrus.WithError(err).Error("Failed to reload node route rules on change")
+			}
+		})
+	}
+}
+
+// autoReloadInflightLimits reloads the max in-flight requests per full node of route groups
+// from config store periodically, and at once on change if config store supports to watch
+// config changes.
+func autoReloadInflightLimits(confStore mysql.ConfigManager, provider interface {
+	ReloadInflightLimits(loader func() (map[string]*mysql.NodeRouteGroup, error)) error
+	AutoReloadInflightLimits(interval time.Duration, loader func() (map[string]*mysql.NodeRouteGroup, error))
+}) {
+	if confStore == nil {
+		return
+	}
+
+	loader := func() (map[string]*mysql.NodeRouteGroup, error) {
+		return confStore.LoadNodeRouteGroups()
+	}
+
+	go provider.AutoReloadInflightLimits(15*time.Second, loader)
+
+	if cs, ok := confStore.(mysql.ConfigWatcher); ok {
+		cs.OnChange(func() {
+			if err := provider.ReloadInflightLimits(loader); err != nil {
+				logrus.WithError(err).Error("Failed to reload node in-flight limits on change")
+			}
+		})
+	}
+}
+
+// startNativeSpaceRpcServer starts core space RPC server, and returns the rate limit registry if available,
+// along with the client provider to report node drain progress.
+func startNativeSpaceRpcServer(
+	ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext, standbyCtl *standby.Controller,
+) (*rate.Registry, admin.NodeReporter) {
 	var rateReg *rate.Registry
 
 	router := node.Factory().CreateRouter()
-	clientProvider := node.NewCfxClientProvider(storeCtx.CfxDB, router)
+	clientProvider := node.NewCfxClientProvider(nodeRouteReader(storeCtx.CfxDB), router)
+	standbyCtl.AddPreflight("cfx.fullnode", func() error { return clientProvider.Preflight() })
+	standbyCtl.AddPreflight("cfx.noderoutes", clientProvider.WarmUpRouteCache)
+	autoReloadRouteRules(storeCtx.CfxConf, clientProvider)
+	autoReloadInflightLimits(storeCtx.CfxConf, clientProvider)
 	relayer := relay.MustNewTxnRelayerFromViper()
 
 	option := rpc.CfxAPIOption{
@@ -105,9 +242,17 @@ func startNativeSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx
 
 		rateKeyLoader := rate.NewKeyLoader(storeCtx.CfxDB.LoadRateLimitKeyInfos)
 		rateReg = rate.NewRegistry(rateKeyLoader, acl.NewCfxValidator)
+		standbyCtl.AddPreflight("cfx.ratelimitkeys", rateKeyLoader.WarmUp)
+
+		rateReg.SetRouteGroupResolver(routeGroupResolver(clientProvider.GetRouteGroup))
 
 		// periodically reload rate limit settings from db
-		go rateReg.AutoReload(15*time.Second, storeCtx.CfxDB.LoadRateLimitConfigs)
+		go rateReg.AutoReload(15*time.Second, storeCtx.CfxConf.LoadRateLimitConfigs)
+		reloadOnConfigChange(storeCtx.CfxConf, rateReg)
+		standbyCtl.AddPreflight("cfx.ratelimit", rateReg.Reload)
+
+		// persist slow queries into db if configured
+		rpc.SetSlowQueryStore(slowlog.SpaceCfx, storeCtx.CfxDB)
 	}
 
 	if storeCtx.CfxCache != nil {
@@ -133,32 +278,72 @@ func startNativeSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx
 		option.LogApiHandler = handler.NewCfxLogsApiHandler(storeCtx.CfxDB, prunedHandler)
 	}
 
+	// initialize usage metering
+	var meter *metering.Meter
+	if storeCtx.CfxDB != nil {
+		if m, ok := metering.MustNewMeterFromViper(storeCtx.CfxDB); ok {
+			meter = m
+			go meter.Run(ctx, wg)
+
+			// periodically reload usage quotas from config store
+			go meter.AutoReloadQuotas(15*time.Second, storeCtx.CfxConf.LoadUsageQuotaConfigs)
+			reloadQuotasOnConfigChange(storeCtx.CfxConf, meter)
+		}
+	}
+
 	// initialize RPC server
 	exposedModules := viper.GetStringSlice("rpc.exposedModules")
-	server := rpc.MustNewNativeSpaceServer(rateReg, clientProvider, gasHandler, exposedModules, option)
+	server := rpc.MustNewNativeSpaceServer(rateReg, meter, clientProvider, gasHandler, exposedModules, option)
 
-	// serve HTTP endpoint
-	httpEndpoint := viper.GetString("rpc.endpoint")
-	go server.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
+	// terminate TLS natively if configured
+	server.EnableTLS()
 
-	// serve Websocket endpoint
-	if wsEndpoint := viper.GetString("rpc.wsEndpoint"); len(wsEndpoint) > 0 {
-		go server.MustServeGraceful(ctx, wg, wsEndpoint, rpcutil.ProtocolWS)
-	}
+	// serve endpoints once promoted if started in warm standby mode
+	standbyCtl.Serve(func() {
+		// serve HTTP endpoint
+		httpEndpoint := viper.GetString("rpc.endpoint")
+		go server.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
 
-	// serve debug endpoint
-	if debugEndpoint := viper.GetString("rpc.debugEndpoint"); len(debugEndpoint) > 0 {
-		server := rpc.MustNewDebugServer()
-		go server.MustServeGraceful(ctx, wg, debugEndpoint, rpcutil.ProtocolHttp)
-	}
+		// serve Websocket endpoint
+		if wsEndpoint := viper.GetString("rpc.wsEndpoint"); len(wsEndpoint) > 0 {
+			go server.MustServeGraceful(ctx, wg, wsEndpoint, rpcutil.ProtocolWS)
+		}
+
+		// serve Server-Sent Events endpoint
+		if sseEndpoint := viper.GetString("rpc.sseEndpoint"); len(sseEndpoint) > 0 {
+			server.EnableSSE("cfx")
+			go server.MustServeGraceful(ctx, wg, sseEndpoint, rpcutil.ProtocolSSE)
+		}
+
+		// serve IPC endpoint for co-located services
+		if ipcPath, ok := server.EnableIPC("rpc.ipc"); ok {
+			go server.MustServeGraceful(ctx, wg, ipcPath, rpcutil.ProtocolIPC)
+		}
+
+		// serve debug endpoint
+		if debugEndpoint := viper.GetString("rpc.debugEndpoint"); len(debugEndpoint) > 0 {
+			server := rpc.MustNewDebugServer()
+			go server.MustServeGraceful(ctx, wg, debugEndpoint, rpcutil.ProtocolHttp)
+		}
+	})
+
+	return rateReg, clientProvider
 }
 
-// startEvmSpaceRpcServer starts evm space RPC server
-func startEvmSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext) {
+// startEvmSpaceRpcServer starts evm space RPC server, and returns the rate limit registry if available,
+// along with the client provider to report node drain progress.
+func startEvmSpaceRpcServer(
+	ctx context.Context, wg *sync.WaitGroup, storeCtx util.StoreContext, standbyCtl *standby.Controller,
+) (*rate.Registry, admin.NodeReporter) {
 	var rateReg *rate.Registry
 
 	router := node.EthFactory().CreateRouter()
-	clientProvider := node.NewEthClientProvider(storeCtx.EthDB, router)
+	clientProvider := node.NewEthClientProvider(nodeRouteReader(storeCtx.EthDB), router)
+	standbyCtl.AddPreflight("eth.fullnode", func() error { return clientProvider.Preflight() })
+	standbyCtl.AddPreflight("eth.noderoutes", clientProvider.WarmUpRouteCache)
+	autoReloadRouteRules(storeCtx.EthConf, clientProvider)
+	autoReloadInflightLimits(storeCtx.EthConf, clientProvider)
+	clientProvider.MustStartSequencerTrackerFromViper()
 	relayer := relay.MustNewEthTxnRelayerFromViper()
 
 	option := rpc.EthAPIOption{
@@ -174,42 +359,90 @@ func startEvmSpaceRpcServer(ctx context.Context, wg *sync.WaitGroup, storeCtx ut
 		// initialize store handler
 		option.StoreHandler = handler.NewEthStoreHandler(storeCtx.EthDB, nil)
 		// initialize logs api handler
-		option.LogApiHandler = handler.NewEthLogsApiHandler(storeCtx.EthDB)
+		option.LogApiHandler = handler.MustNewEthLogsApiHandlerFromViper(storeCtx.EthDB)
 
 		rateKeyLoader := rate.NewKeyLoader(storeCtx.EthDB.LoadRateLimitKeyInfos)
 		rateReg = rate.NewRegistry(rateKeyLoader, acl.NewEthValidator)
+		standbyCtl.AddPreflight("eth.ratelimitkeys", rateKeyLoader.WarmUp)
+
+		rateReg.SetRouteGroupResolver(routeGroupResolver(clientProvider.GetRouteGroup))
 
 		// periodically reload rate limit settings from db
-		go rateReg.AutoReload(15*time.Second, storeCtx.EthDB.LoadRateLimitConfigs)
+		go rateReg.AutoReload(15*time.Second, storeCtx.EthConf.LoadRateLimitConfigs)
+		reloadOnConfigChange(storeCtx.EthConf, rateReg)
+		standbyCtl.AddPreflight("eth.ratelimit", rateReg.Reload)
+
+		// persist slow queries into db if configured
+		rpc.SetSlowQueryStore(slowlog.SpaceEth, storeCtx.EthDB)
+	}
+
+	// initialize usage metering
+	var meter *metering.Meter
+	if storeCtx.EthDB != nil {
+		if m, ok := metering.MustNewMeterFromViper(storeCtx.EthDB); ok {
+			meter = m
+			go meter.Run(ctx, wg)
+
+			// periodically reload usage quotas from config store
+			go meter.AutoReloadQuotas(15*time.Second, storeCtx.EthConf.LoadUsageQuotaConfigs)
+			reloadQuotasOnConfigChange(storeCtx.EthConf, meter)
+		}
 	}
 
 	// initialize RPC server
 	exposedModules := viper.GetStringSlice("ethrpc.exposedModules")
-	server := rpc.MustNewEvmSpaceServer(rateReg, clientProvider, exposedModules, option)
+	server := rpc.MustNewEvmSpaceServer(rateReg, meter, clientProvider, exposedModules, option)
 
-	// serve HTTP endpoint
-	httpEndpoint := viper.GetString("ethrpc.endpoint")
-	go server.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
+	// terminate TLS natively if configured
+	server.EnableTLS()
 
-	// serve Websocket endpoint
-	if wsEndpoint := viper.GetString("ethrpc.wsEndpoint"); len(wsEndpoint) > 0 {
-		go server.MustServeGraceful(ctx, wg, wsEndpoint, rpcutil.ProtocolWS)
-	}
+	// serve endpoints once promoted if started in warm standby mode
+	standbyCtl.Serve(func() {
+		// serve HTTP endpoint
+		httpEndpoint := viper.GetString("ethrpc.endpoint")
+		go server.MustServeGraceful(ctx, wg, httpEndpoint, rpcutil.ProtocolHttp)
 
-	// serve debug endpoint
-	if debugEndpoint := viper.GetString("ethrpc.debugEndpoint"); len(debugEndpoint) > 0 {
-		server := rpc.MustNewDebugServer()
-		go server.MustServeGraceful(ctx, wg, debugEndpoint, rpcutil.ProtocolHttp)
-	}
+		// serve Websocket endpoint
+		if wsEndpoint := viper.GetString("ethrpc.wsEndpoint"); len(wsEndpoint) > 0 {
+			go server.MustServeGraceful(ctx, wg, wsEndpoint, rpcutil.ProtocolWS)
+		}
+
+		// serve Server-Sent Events endpoint
+		if sseEndpoint := viper.GetString("ethrpc.sseEndpoint"); len(sseEndpoint) > 0 {
+			server.EnableSSE("eth")
+			go server.MustServeGraceful(ctx, wg, sseEndpoint, rpcutil.ProtocolSSE)
+		}
+
+		// serve IPC endpoint for co-located services
+		if ipcPath, ok := server.EnableIPC("ethrpc.ipc"); ok {
+			go server.MustServeGraceful(ctx, wg, ipcPath, rpcutil.ProtocolIPC)
+		}
+
+		// serve gRPC endpoint
+		if grpcEndpoint := viper.GetString("ethrpc.grpcEndpoint"); len(grpcEndpoint) > 0 {
+			server := grpcserver.MustNewServer("evm_space_grpc", server)
+			go server.MustServeGraceful(ctx, wg, grpcEndpoint)
+		}
+
+		// serve debug endpoint
+		if debugEndpoint := viper.GetString("ethrpc.debugEndpoint"); len(debugEndpoint) > 0 {
+			server := rpc.MustNewDebugServer()
+			go server.MustServeGraceful(ctx, wg, debugEndpoint, rpcutil.ProtocolHttp)
+		}
+	})
+
+	return rateReg, clientProvider
 }
 
 // startNativeSpaceBridgeRpcServer starts core space bridge RPC server
-func startNativeSpaceBridgeRpcServer(ctx context.Context, wg *sync.WaitGroup) {
+func startNativeSpaceBridgeRpcServer(ctx context.Context, wg *sync.WaitGroup, standbyCtl *standby.Controller) {
 	var config rpc.CfxBridgeServerConfig
 
 	viperutil.MustUnmarshalKey("rpc.cfxBridge", &config)
 	logrus.WithField("config", config).Info("Start to run cfx bridge rpc server")
 
 	server := rpc.MustNewNativeSpaceBridgeServer(&config)
-	go server.MustServeGraceful(ctx, wg, config.Endpoint, rpcutil.ProtocolHttp)
+	standbyCtl.Serve(func() {
+		go server.MustServeGraceful(ctx, wg, config.Endpoint, rpcutil.ProtocolHttp)
+	})
 }
@@ -1,10 +1,12 @@
 package util
 
 import (
+	"context"
 	"errors"
 	"strings"
 
 	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/store/consul"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/store/redis"
 	"github.com/Conflux-Chain/confura/util/rpc"
@@ -17,13 +19,33 @@ type StoreContext struct {
 	CfxDB    *mysql.MysqlStore
 	EthDB    *mysql.MysqlStore
 	CfxCache *redis.RedisStore
+
+	// config stores, which are Consul config stores if enabled, otherwise the db stores
+	CfxConf mysql.ConfigManager
+	EthConf mysql.ConfigManager
 }
 
+// ConfigEnv is the environment to scope configs in stores, which overrides that of config file
+// if specified, eg., by command line flag.
+var ConfigEnv string
+
 func MustInitStoreContext() StoreContext {
+	return mustInitStoreContext(true)
+}
+
+// MustInitStoreContextWithoutMigration inits store context without applying the pending schema
+// migrations automatically whatever configured, eg., to manage schema migrations manually.
+func MustInitStoreContextWithoutMigration() StoreContext {
+	return mustInitStoreContext(false)
+}
+
+func mustInitStoreContext(autoMigrate bool) StoreContext {
 	var ctx StoreContext
 
 	// prepare core space db store
 	if config := mysql.MustNewConfigFromViper(); config.Enabled {
+		config.Env = configEnv(config.Env)
+		config.AutoMigrate = config.AutoMigrate && autoMigrate
 		ctx.CfxDB = config.MustOpenOrCreate(mysql.StoreOption{
 			Disabler: store.StoreConfig(),
 		})
@@ -31,6 +53,8 @@ func MustInitStoreContext() StoreContext {
 
 	// prepare evm space db store
 	if ethConfig := mysql.MustNewEthStoreConfigFromViper(); ethConfig.Enabled {
+		ethConfig.Env = configEnv(ethConfig.Env)
+		ethConfig.AutoMigrate = ethConfig.AutoMigrate && autoMigrate
 		ctx.EthDB = ethConfig.MustOpenOrCreate(mysql.StoreOption{
 			Disabler: store.EthStoreConfig(),
 		})
@@ -41,9 +65,43 @@ func MustInitStoreContext() StoreContext {
 		ctx.CfxCache = redis
 	}
 
+	// prepare config stores
+	if config := consul.MustNewConfigFromViper(); config.Enabled {
+		config.Env = configEnv(config.Env)
+		ctx.CfxConf = config.MustOpen()
+	} else if ctx.CfxDB != nil {
+		ctx.CfxConf = ctx.CfxDB
+	}
+
+	if ethConfig := consul.MustNewEthStoreConfigFromViper(); ethConfig.Enabled {
+		ethConfig.Env = configEnv(ethConfig.Env)
+		ctx.EthConf = ethConfig.MustOpen()
+	} else if ctx.EthDB != nil {
+		ctx.EthConf = ctx.EthDB
+	}
+
 	return ctx
 }
 
+// configEnv returns the environment to scope configs, which prefers `ConfigEnv` if specified.
+func configEnv(env string) string {
+	if len(ConfigEnv) > 0 {
+		return ConfigEnv
+	}
+
+	return env
+}
+
+// WatchConfigs watches config changes from config stores if supported (eg., Consul) until
+// context done.
+func (ctx *StoreContext) WatchConfigs(c context.Context) {
+	for _, conf := range []mysql.ConfigManager{ctx.CfxConf, ctx.EthConf} {
+		if cs, ok := conf.(mysql.ConfigWatcher); ok {
+			go cs.Watch(c)
+		}
+	}
+}
+
 func (ctx *StoreContext) Close() {
 	if ctx.CfxDB != nil {
 		ctx.CfxDB.Close()
@@ -70,6 +128,18 @@ func (ctx *StoreContext) GetMysqlStore(network string) (store *mysql.MysqlStore,
 	}
 }
 
+// GetConfigManager returns config store by network space
+func (ctx *StoreContext) GetConfigManager(network string) (mysql.ConfigManager, error) {
+	switch {
+	case strings.EqualFold(network, "eth"):
+		return ctx.EthConf, nil
+	case strings.EqualFold(network, "cfx"):
+		return ctx.CfxConf, nil
+	default:
+		return nil, errors.New("invalid network space (only `cfx` and `eth` acceptable)")
+	}
+}
+
 // SyncContext context to hold sdk clients for blockchain interoperation.
 type SyncContext struct {
 	StoreContext
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+)
+
+// CliOperator returns the operator identity of command line tools, which is used to audit
+// config changes made from the command line.
+func CliOperator() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	hostname, _ := os.Hostname()
+
+	return fmt.Sprintf("cli:%v@%v", username, hostname)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/Conflux-Chain/confura/util/metrics"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,6 +34,9 @@ func GracefulShutdown(wg *sync.WaitGroup, cancel context.CancelFunc) {
 	logrus.Info("Waiting for shutdown...")
 	wg.Wait()
 
+	// Report metrics collected since the last periodical report.
+	metrics.Flush()
+
 	logrus.Info("Shutdown gracefully")
 }
 
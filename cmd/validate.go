@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/Conflux-Chain/confura/admin"
+	"github.com/Conflux-Chain/confura/cmd/util"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// validateConfig loads the configurations from config file, env vars and config stores, and
+// validates them without starting any service, which exits on validation failure.
+func validateConfig() {
+	// stores are opened to validate both the store settings and connectivity
+	storeCtx := util.MustInitStoreContext()
+	defer storeCtx.Close()
+
+	admin.MustNewConfigFromViper()
+
+	var numConfs, numFailures int
+
+	for _, network := range []string{"cfx", "eth"} {
+		confStore, _ := storeCtx.GetConfigManager(network)
+		if confStore == nil {
+			continue
+		}
+
+		confs, err := confStore.ListConfigs("")
+		if err != nil {
+			logrus.WithField("network", network).WithError(err).Fatal("Failed to list configs from store")
+		}
+
+		names := make([]string, 0, len(confs))
+		for name := range confs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			numConfs++
+
+			if err := mysql.ValidateConfig(network, name, confs[name]); err != nil {
+				numFailures++
+				logrus.WithFields(logrus.Fields{
+					"network": network, "name": name,
+				}).WithError(err).Error("Invalid config in store")
+			}
+		}
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"configs": numConfs, "failures": numFailures})
+	if numFailures > 0 {
+		logger.Fatal("Config validation failed")
+	}
+
+	logger.Info("Config validation passed")
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// length of JWT secret in bytes as required by Engine API
+	jwtSecretLength = 32
+)
+
+var (
+	// JWT header of HS256 algorithm, which is the only one supported by Engine API
+	jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	errInvalidToken = errors.New("invalid JWT token")
+)
+
+// jwtClaims claims of Engine API JWT token, which only requires the issued-at time.
+type jwtClaims struct {
+	IssuedAt int64 `json:"iat"`
+}
+
+// loadJwtSecret loads the hex encoded JWT secret, which is read from file if secret not specified.
+func loadJwtSecret(secret, file string) ([]byte, error) {
+	if len(secret) == 0 && len(file) > 0 {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to read JWT secret file")
+		}
+
+		secret = string(data)
+	}
+
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(secret), "0x"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid hex encoded JWT secret")
+	}
+
+	if len(key) != jwtSecretLength {
+		return nil, errors.Errorf("JWT secret must be %v bytes, got %v", jwtSecretLength, len(key))
+	}
+
+	return key, nil
+}
+
+// signJwt issues a HS256 JWT token at the specified time.
+func signJwt(secret []byte, now time.Time) string {
+	claims, _ := json.Marshal(jwtClaims{IssuedAt: now.Unix()})
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(secret, unsigned))
+}
+
+// verifyJwt verifies the HS256 JWT token, of which the issued-at time must be within the max skew.
+func verifyJwt(secret []byte, token string, now time.Time, maxSkew time.Duration) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidToken
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errInvalidToken
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return errors.New("unsupported JWT algorithm")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, jwtSignature(secret, parts[0]+"."+parts[1])) {
+		return errors.New("invalid JWT signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errInvalidToken
+	}
+
+	if skew := now.Sub(time.Unix(claims.IssuedAt, 0)); skew > maxSkew || skew < -maxSkew {
+		return errors.New("stale JWT token")
+	}
+
+	return nil
+}
+
+func jwtSignature(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+
+	return mac.Sum(nil)
+}
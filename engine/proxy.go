@@ -0,0 +1,303 @@
+// Package engine provides the Engine API (`engine_*`) reverse proxy, so that consensus or rollup
+// nodes could reach the execution clients through the gateway with JWT authentication.
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultShutdownTimeout is default timeout to shutdown Engine API proxy.
+	DefaultShutdownTimeout = 3 * time.Second
+
+	// max size of request body, which is large enough for execution payloads
+	maxRequestBodySize = 128 * 1024 * 1024
+
+	// JSON-RPC error codes
+	errCodeInvalidRequest   = -32600
+	errCodeMethodNotAllowed = -32601
+	errCodeUpstream         = -32603
+)
+
+// Config Engine API proxy configurations.
+type Config struct {
+	// served HTTP endpoint, Engine API proxy will be disabled if empty
+	Endpoint string
+	// methods allowed to proxy, which supports wildcard pattern, eg., `engine_*`
+	Methods []string `default:"[engine_*,eth_chainId,eth_blockNumber,eth_syncing,eth_getBlockByNumber,eth_getBlockByHash,eth_getProof]"`
+	// max clock skew of the issued-at time of JWT token
+	MaxTokenSkew time.Duration `default:"60s"`
+	// timeout to request execution client
+	Timeout time.Duration `default:"10s"`
+	// execution clients to proxy, each of which is served at path `/{name}`
+	Upstreams []UpstreamConfig
+}
+
+// UpstreamConfig execution client to proxy Engine API.
+type UpstreamConfig struct {
+	// name of execution client, which is also the URL path to serve
+	Name string
+	// authenticated Engine API URL of execution client, eg., http://127.0.0.1:8551
+	Url string
+	// hex encoded JWT secret shared with execution client
+	JwtSecret string
+	// file of hex encoded JWT secret shared with execution client if `JwtSecret` not specified
+	JwtSecretFile string
+	// hex encoded JWT secret to authenticate consensus node, which defaults to the upstream one
+	ClientJwtSecret string
+	// file of hex encoded JWT secret to authenticate consensus node if `ClientJwtSecret` not
+	// specified
+	ClientJwtSecretFile string
+}
+
+// upstream execution client with the loaded JWT secrets.
+type upstream struct {
+	name         string
+	url          string
+	secret       []byte // to sign requests to execution client
+	clientSecret []byte // to authenticate consensus node
+}
+
+// MustNewConfigFromViper creates Engine API proxy config from viper, or returns false if disabled.
+func MustNewConfigFromViper() (*Config, bool) {
+	var conf Config
+	viper.MustUnmarshalKey("engine", &conf)
+
+	if len(conf.Endpoint) == 0 {
+		return nil, false
+	}
+
+	return &conf, true
+}
+
+// Proxy reverse proxies the authenticated Engine API requests to execution clients, in which
+// requests are re-signed with the JWT secret of each execution client. Responses are never
+// cached, since Engine API requests are stateful.
+type Proxy struct {
+	conf      *Config
+	upstreams map[string]*upstream // name => upstream
+	client    *http.Client
+	server    *http.Server
+}
+
+// NewProxy creates Engine API proxy, which returns error if any JWT secret is invalid.
+func NewProxy(conf *Config) (*Proxy, error) {
+	p := &Proxy{
+		conf:      conf,
+		upstreams: make(map[string]*upstream),
+		client:    &http.Client{Timeout: conf.Timeout},
+	}
+	p.server = &http.Server{Handler: p}
+
+	for _, uc := range conf.Upstreams {
+		if len(uc.Name) == 0 || strings.Contains(uc.Name, "/") || len(uc.Url) == 0 {
+			return nil, errors.Errorf("invalid upstream %q", uc.Name)
+		}
+
+		if _, ok := p.upstreams[uc.Name]; ok {
+			return nil, errors.Errorf("duplicate upstream %q", uc.Name)
+		}
+
+		secret, err := loadJwtSecret(uc.JwtSecret, uc.JwtSecretFile)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "upstream %q", uc.Name)
+		}
+
+		clientSecret := secret
+		if len(uc.ClientJwtSecret) > 0 || len(uc.ClientJwtSecretFile) > 0 {
+			if clientSecret, err = loadJwtSecret(uc.ClientJwtSecret, uc.ClientJwtSecretFile); err != nil {
+				return nil, errors.WithMessagef(err, "upstream %q client", uc.Name)
+			}
+		}
+
+		p.upstreams[uc.Name] = &upstream{
+			name: uc.Name, url: uc.Url, secret: secret, clientSecret: clientSecret,
+		}
+	}
+
+	if len(p.upstreams) == 0 {
+		return nil, errors.New("no upstream configured")
+	}
+
+	return p, nil
+}
+
+// MustNewProxyFromViper creates Engine API proxy from viper, or returns nil if disabled.
+func MustNewProxyFromViper() *Proxy {
+	conf, ok := MustNewConfigFromViper()
+	if !ok {
+		return nil
+	}
+
+	p, err := NewProxy(conf)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create Engine API proxy")
+	}
+
+	return p
+}
+
+// ServeHTTP implements `http.Handler`.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	up, ok := p.upstreams[strings.Trim(r.URL.Path, "/")]
+	if !ok {
+		http.Error(w, "upstream not found", http.StatusNotFound)
+		return
+	}
+
+	logger := logrus.WithField("upstream", up.name)
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if err := verifyJwt(up.clientSecret, token, time.Now(), p.conf.MaxTokenSkew); err != nil {
+		logger.WithError(err).Debug("Engine API request unauthorized")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body) > maxRequestBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if code, err := p.checkMethods(body); err != nil {
+		logger.WithError(err).Info("Engine API request rejected")
+		writeRpcError(w, code, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := p.forward(r.Context(), w, up, body); err != nil {
+		logger.WithError(err).Info("Failed to proxy Engine API request")
+		writeRpcError(w, errCodeUpstream, errors.WithMessage(err, "execution client unavailable"))
+	}
+}
+
+// checkMethods checks if all methods of the single or batch request are allowed.
+func (p *Proxy) checkMethods(body []byte) (int, error) {
+	type message struct {
+		Method string `json:"method"`
+	}
+
+	var msgs []message
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &msgs); err != nil || len(msgs) == 0 {
+			return errCodeInvalidRequest, errors.New("invalid batch request")
+		}
+	} else {
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return errCodeInvalidRequest, errors.New("invalid request")
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	for _, msg := range msgs {
+		if !p.allowed(msg.Method) {
+			return errCodeMethodNotAllowed, errors.Errorf("method %v not allowed", msg.Method)
+		}
+	}
+
+	return 0, nil
+}
+
+// allowed checks if the method matches any allowed method pattern.
+func (p *Proxy) allowed(method string) bool {
+	for _, pattern := range p.conf.Methods {
+		if matched, _ := path.Match(pattern, method); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forward sends the request to execution client with a freshly signed JWT token, and copies the
+// response back.
+func (p *Proxy) forward(ctx context.Context, w http.ResponseWriter, up *upstream, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, up.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signJwt(up.secret, time.Now()))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+
+	return nil
+}
+
+// MustServeGraceful serves Engine API proxy in a goroutine until graceful shutdown.
+func (p *Proxy) MustServeGraceful(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	logger := logrus.WithField("endpoint", p.conf.Endpoint)
+
+	listener, err := net.Listen("tcp", p.conf.Endpoint)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to listen to Engine API proxy endpoint")
+	}
+
+	logger.WithField("upstreams", len(p.upstreams)).Info("Engine API proxy started")
+	go p.server.Serve(listener)
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+
+	if err := p.server.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Failed to shutdown Engine API proxy")
+	} else {
+		logger.Info("Succeed to shutdown Engine API proxy")
+	}
+}
+
+// writeRpcError writes JSON-RPC error response without request ID.
+func writeRpcError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]interface{}{"code": code, "message": err.Error()},
+	})
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSecret       = strings.Repeat("ab", jwtSecretLength)
+	testClientSecret = strings.Repeat("cd", jwtSecretLength)
+)
+
+func TestJwt(t *testing.T) {
+	secret, _ := hex.DecodeString(testSecret)
+	now := time.Now()
+
+	token := signJwt(secret, now)
+	assert.NoError(t, verifyJwt(secret, token, now.Add(30*time.Second), time.Minute))
+
+	// stale token
+	assert.Error(t, verifyJwt(secret, token, now.Add(2*time.Minute), time.Minute))
+	assert.Error(t, verifyJwt(secret, token, now.Add(-2*time.Minute), time.Minute))
+
+	// mismatched secret
+	other, _ := hex.DecodeString(testClientSecret)
+	assert.Error(t, verifyJwt(other, token, now, time.Minute))
+
+	// malformed token
+	assert.Error(t, verifyJwt(secret, "", now, time.Minute))
+	assert.Error(t, verifyJwt(secret, token[:len(token)-2], now, time.Minute))
+}
+
+func TestLoadJwtSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jwt.hex")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("0x"+testSecret+"\n"), 0600))
+
+	secret, err := loadJwtSecret("", file)
+	assert.NoError(t, err)
+	assert.Len(t, secret, jwtSecretLength)
+
+	_, err = loadJwtSecret("0xabcd", "")
+	assert.Error(t, err)
+
+	_, err = loadJwtSecret("", filepath.Join(t.TempDir(), "absent.hex"))
+	assert.Error(t, err)
+}
+
+func TestProxy(t *testing.T) {
+	upstreamSecret, _ := hex.DecodeString(testSecret)
+	clientSecret, _ := hex.DecodeString(testClientSecret)
+
+	// execution client that only accepts requests signed with the upstream secret
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := verifyJwt(upstreamSecret, token, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	proxy, err := NewProxy(&Config{
+		Methods:      []string{"engine_*", "eth_chainId"},
+		MaxTokenSkew: time.Minute,
+		Timeout:      time.Second,
+		Upstreams: []UpstreamConfig{{
+			Name: "geth0", Url: server.URL, JwtSecret: testSecret, ClientJwtSecret: testClientSecret,
+		}},
+	})
+	assert.NoError(t, err)
+
+	serve := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		proxy.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	token := signJwt(clientSecret, time.Now())
+	body := `{"jsonrpc":"2.0","id":1,"method":"engine_forkchoiceUpdatedV1","params":[]}`
+
+	resp := serve("/geth0", token, body)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+	assert.Contains(t, resp.Body.String(), "engine_forkchoiceUpdatedV1")
+
+	// unknown upstream
+	assert.Equal(t, http.StatusNotFound, serve("/geth1", token, body).Code)
+
+	// signed with the upstream secret rather than the client one
+	assert.Equal(t, http.StatusUnauthorized, serve("/geth0", signJwt(upstreamSecret, time.Now()), body).Code)
+
+	// method not allowed in batch
+	resp = serve("/geth0", token, `[{"method":"eth_chainId"},{"method":"admin_addPeer"}]`)
+
+	var rpcResp struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rpcResp))
+	assert.Equal(t, errCodeMethodNotAllowed, rpcResp.Error.Code)
+}
+
+func TestNewProxyInvalidConfig(t *testing.T) {
+	_, err := NewProxy(&Config{})
+	assert.Error(t, err)
+
+	_, err = NewProxy(&Config{Upstreams: []UpstreamConfig{{Name: "geth0", Url: "http://127.0.0.1:8551"}}})
+	assert.Error(t, err)
+
+	_, err = NewProxy(&Config{Upstreams: []UpstreamConfig{
+		{Name: "geth0", Url: "http://127.0.0.1:8551", JwtSecret: testSecret},
+		{Name: "geth0", Url: "http://127.0.0.1:8552", JwtSecret: testSecret},
+	}})
+	assert.Error(t, err)
+}
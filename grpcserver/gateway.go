@@ -0,0 +1,216 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Conflux-Chain/confura/grpcserver/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultBlock = "latest"
+
+type callArgs struct {
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Gas      string `json:"gas,omitempty"`
+	GasPrice string `json:"gasPrice,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+type logFilter struct {
+	FromBlock string     `json:"fromBlock,omitempty"`
+	ToBlock   string     `json:"toBlock,omitempty"`
+	BlockHash string     `json:"blockHash,omitempty"`
+	Addresses []string   `json:"address,omitempty"`
+	Topics    [][]string `json:"topics,omitempty"`
+}
+
+func newCallArgs(req *pb.CallRequest) callArgs {
+	return callArgs{
+		From:     req.From,
+		To:       req.To,
+		Gas:      req.Gas,
+		GasPrice: req.GasPrice,
+		Value:    req.Value,
+		Data:     req.Data,
+	}
+}
+
+func newLogFilter(filter *pb.LogFilter) logFilter {
+	if filter == nil {
+		return logFilter{}
+	}
+
+	result := logFilter{
+		FromBlock: filter.FromBlock,
+		ToBlock:   filter.ToBlock,
+		BlockHash: filter.BlockHash,
+		Addresses: filter.Addresses,
+	}
+
+	for _, topics := range filter.Topics {
+		// empty set matches any topic
+		if len(topics.GetValues()) == 0 {
+			result.Topics = append(result.Topics, nil)
+		} else {
+			result.Topics = append(result.Topics, topics.Values)
+		}
+	}
+
+	return result
+}
+
+func blockOrLatest(block string) string {
+	if len(block) == 0 {
+		return defaultBlock
+	}
+
+	return block
+}
+
+func (s *Server) quantity(ctx context.Context, method string, args ...interface{}) (*pb.Quantity, error) {
+	var result string
+	if err := s.call(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+
+	return &pb.Quantity{Value: result}, nil
+}
+
+func (s *Server) data(ctx context.Context, method string, args ...interface{}) (*pb.Data, error) {
+	var result string
+	if err := s.call(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+
+	return &pb.Data{Value: result}, nil
+}
+
+func (s *Server) object(ctx context.Context, method string, args ...interface{}) (*pb.JsonObject, error) {
+	var result json.RawMessage
+	if err := s.call(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+
+	return &pb.JsonObject{Json: result}, nil
+}
+
+func (s *Server) ChainId(ctx context.Context, _ *pb.Empty) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_chainId")
+}
+
+func (s *Server) BlockNumber(ctx context.Context, _ *pb.Empty) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_blockNumber")
+}
+
+func (s *Server) GasPrice(ctx context.Context, _ *pb.Empty) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_gasPrice")
+}
+
+func (s *Server) GetBalance(ctx context.Context, req *pb.AccountRequest) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_getBalance", req.Address, blockOrLatest(req.Block))
+}
+
+func (s *Server) GetTransactionCount(ctx context.Context, req *pb.AccountRequest) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_getTransactionCount", req.Address, blockOrLatest(req.Block))
+}
+
+func (s *Server) GetCode(ctx context.Context, req *pb.AccountRequest) (*pb.Data, error) {
+	return s.data(ctx, "eth_getCode", req.Address, blockOrLatest(req.Block))
+}
+
+func (s *Server) GetStorageAt(ctx context.Context, req *pb.StorageRequest) (*pb.Data, error) {
+	return s.data(ctx, "eth_getStorageAt", req.Address, req.Position, blockOrLatest(req.Block))
+}
+
+func (s *Server) Call(ctx context.Context, req *pb.CallRequest) (*pb.Data, error) {
+	return s.data(ctx, "eth_call", newCallArgs(req), blockOrLatest(req.Block))
+}
+
+func (s *Server) EstimateGas(ctx context.Context, req *pb.CallRequest) (*pb.Quantity, error) {
+	return s.quantity(ctx, "eth_estimateGas", newCallArgs(req), blockOrLatest(req.Block))
+}
+
+func (s *Server) SendRawTransaction(ctx context.Context, req *pb.Data) (*pb.Hash, error) {
+	var result string
+	if err := s.call(ctx, &result, "eth_sendRawTransaction", req.Value); err != nil {
+		return nil, err
+	}
+
+	return &pb.Hash{Value: result}, nil
+}
+
+func (s *Server) GetBlockByNumber(ctx context.Context, req *pb.BlockByNumberRequest) (*pb.JsonObject, error) {
+	return s.object(ctx, "eth_getBlockByNumber", blockOrLatest(req.Block), req.FullTransactions)
+}
+
+func (s *Server) GetBlockByHash(ctx context.Context, req *pb.BlockByHashRequest) (*pb.JsonObject, error) {
+	return s.object(ctx, "eth_getBlockByHash", req.Hash, req.FullTransactions)
+}
+
+func (s *Server) GetTransactionByHash(ctx context.Context, req *pb.Hash) (*pb.JsonObject, error) {
+	return s.object(ctx, "eth_getTransactionByHash", req.Value)
+}
+
+func (s *Server) GetTransactionReceipt(ctx context.Context, req *pb.Hash) (*pb.JsonObject, error) {
+	return s.object(ctx, "eth_getTransactionReceipt", req.Value)
+}
+
+func (s *Server) GetLogs(ctx context.Context, req *pb.LogFilter) (*pb.JsonObject, error) {
+	return s.object(ctx, "eth_getLogs", newLogFilter(req))
+}
+
+func (s *Server) Request(ctx context.Context, req *pb.JsonRpcRequest) (*pb.JsonObject, error) {
+	if len(req.Method) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "method not specified")
+	}
+
+	result, err := s.request(ctx, req.Method, req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.JsonObject{Json: result}, nil
+}
+
+func (s *Server) Subscribe(req *pb.SubscribeRequest, stream pb.EthGateway_SubscribeServer) error {
+	args := []interface{}{req.Kind}
+
+	switch req.Kind {
+	case "newHeads":
+	case "logs":
+		args = append(args, newLogFilter(req.Filter))
+	default:
+		return status.Errorf(codes.InvalidArgument, "unsupported subscription kind %q", req.Kind)
+	}
+
+	ctx := stream.Context()
+	ch := make(chan json.RawMessage, 64)
+
+	client, sub, err := s.subscribe(ctx, ch, args...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			if err != nil {
+				return status.Error(codes.Unavailable, err.Error())
+			}
+
+			return nil
+		case msg := <-ch:
+			if err := stream.Send(&pb.JsonObject{Json: msg}); err != nil {
+				return err
+			}
+		}
+	}
+}
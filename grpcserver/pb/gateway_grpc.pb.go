@@ -0,0 +1,713 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: gateway.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// EthGatewayClient is the client API for EthGateway service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EthGatewayClient interface {
+	ChainId(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error)
+	BlockNumber(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error)
+	GasPrice(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error)
+	GetBalance(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Quantity, error)
+	GetTransactionCount(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Quantity, error)
+	GetCode(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Data, error)
+	GetStorageAt(ctx context.Context, in *StorageRequest, opts ...grpc.CallOption) (*Data, error)
+	Call(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*Data, error)
+	EstimateGas(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*Quantity, error)
+	SendRawTransaction(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Hash, error)
+	GetBlockByNumber(ctx context.Context, in *BlockByNumberRequest, opts ...grpc.CallOption) (*JsonObject, error)
+	GetBlockByHash(ctx context.Context, in *BlockByHashRequest, opts ...grpc.CallOption) (*JsonObject, error)
+	GetTransactionByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*JsonObject, error)
+	GetTransactionReceipt(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*JsonObject, error)
+	GetLogs(ctx context.Context, in *LogFilter, opts ...grpc.CallOption) (*JsonObject, error)
+	// Request forwards JSON-RPC request for the methods not mirrored.
+	Request(ctx context.Context, in *JsonRpcRequest, opts ...grpc.CallOption) (*JsonObject, error)
+	// Subscribe streams the `newHeads` or `logs` subscription events.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (EthGateway_SubscribeClient, error)
+}
+
+type ethGatewayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEthGatewayClient(cc grpc.ClientConnInterface) EthGatewayClient {
+	return &ethGatewayClient{cc}
+}
+
+func (c *ethGatewayClient) ChainId(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/ChainId", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) BlockNumber(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/BlockNumber", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GasPrice(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GasPrice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetBalance(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetBalance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetTransactionCount(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetTransactionCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetCode(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Data, error) {
+	out := new(Data)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetCode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetStorageAt(ctx context.Context, in *StorageRequest, opts ...grpc.CallOption) (*Data, error) {
+	out := new(Data)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetStorageAt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) Call(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*Data, error) {
+	out := new(Data)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/Call", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) EstimateGas(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*Quantity, error) {
+	out := new(Quantity)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/EstimateGas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) SendRawTransaction(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Hash, error) {
+	out := new(Hash)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/SendRawTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetBlockByNumber(ctx context.Context, in *BlockByNumberRequest, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetBlockByNumber", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetBlockByHash(ctx context.Context, in *BlockByHashRequest, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetBlockByHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetTransactionByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetTransactionByHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetTransactionReceipt(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetTransactionReceipt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) GetLogs(ctx context.Context, in *LogFilter, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/GetLogs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) Request(ctx context.Context, in *JsonRpcRequest, opts ...grpc.CallOption) (*JsonObject, error) {
+	out := new(JsonObject)
+	err := c.cc.Invoke(ctx, "/confura.gateway.v1.EthGateway/Request", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethGatewayClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (EthGateway_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EthGateway_ServiceDesc.Streams[0], "/confura.gateway.v1.EthGateway/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ethGatewaySubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EthGateway_SubscribeClient interface {
+	Recv() (*JsonObject, error)
+	grpc.ClientStream
+}
+
+type ethGatewaySubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *ethGatewaySubscribeClient) Recv() (*JsonObject, error) {
+	m := new(JsonObject)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EthGatewayServer is the server API for EthGateway service.
+// All implementations must embed UnimplementedEthGatewayServer
+// for forward compatibility
+type EthGatewayServer interface {
+	ChainId(context.Context, *Empty) (*Quantity, error)
+	BlockNumber(context.Context, *Empty) (*Quantity, error)
+	GasPrice(context.Context, *Empty) (*Quantity, error)
+	GetBalance(context.Context, *AccountRequest) (*Quantity, error)
+	GetTransactionCount(context.Context, *AccountRequest) (*Quantity, error)
+	GetCode(context.Context, *AccountRequest) (*Data, error)
+	GetStorageAt(context.Context, *StorageRequest) (*Data, error)
+	Call(context.Context, *CallRequest) (*Data, error)
+	EstimateGas(context.Context, *CallRequest) (*Quantity, error)
+	SendRawTransaction(context.Context, *Data) (*Hash, error)
+	GetBlockByNumber(context.Context, *BlockByNumberRequest) (*JsonObject, error)
+	GetBlockByHash(context.Context, *BlockByHashRequest) (*JsonObject, error)
+	GetTransactionByHash(context.Context, *Hash) (*JsonObject, error)
+	GetTransactionReceipt(context.Context, *Hash) (*JsonObject, error)
+	GetLogs(context.Context, *LogFilter) (*JsonObject, error)
+	// Request forwards JSON-RPC request for the methods not mirrored.
+	Request(context.Context, *JsonRpcRequest) (*JsonObject, error)
+	// Subscribe streams the `newHeads` or `logs` subscription events.
+	Subscribe(*SubscribeRequest, EthGateway_SubscribeServer) error
+	mustEmbedUnimplementedEthGatewayServer()
+}
+
+// UnimplementedEthGatewayServer must be embedded to have forward compatible implementations.
+type UnimplementedEthGatewayServer struct {
+}
+
+func (UnimplementedEthGatewayServer) ChainId(context.Context, *Empty) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChainId not implemented")
+}
+func (UnimplementedEthGatewayServer) BlockNumber(context.Context, *Empty) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlockNumber not implemented")
+}
+func (UnimplementedEthGatewayServer) GasPrice(context.Context, *Empty) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GasPrice not implemented")
+}
+func (UnimplementedEthGatewayServer) GetBalance(context.Context, *AccountRequest) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedEthGatewayServer) GetTransactionCount(context.Context, *AccountRequest) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionCount not implemented")
+}
+func (UnimplementedEthGatewayServer) GetCode(context.Context, *AccountRequest) (*Data, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCode not implemented")
+}
+func (UnimplementedEthGatewayServer) GetStorageAt(context.Context, *StorageRequest) (*Data, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStorageAt not implemented")
+}
+func (UnimplementedEthGatewayServer) Call(context.Context, *CallRequest) (*Data, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
+}
+func (UnimplementedEthGatewayServer) EstimateGas(context.Context, *CallRequest) (*Quantity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EstimateGas not implemented")
+}
+func (UnimplementedEthGatewayServer) SendRawTransaction(context.Context, *Data) (*Hash, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendRawTransaction not implemented")
+}
+func (UnimplementedEthGatewayServer) GetBlockByNumber(context.Context, *BlockByNumberRequest) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockByNumber not implemented")
+}
+func (UnimplementedEthGatewayServer) GetBlockByHash(context.Context, *BlockByHashRequest) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockByHash not implemented")
+}
+func (UnimplementedEthGatewayServer) GetTransactionByHash(context.Context, *Hash) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionByHash not implemented")
+}
+func (UnimplementedEthGatewayServer) GetTransactionReceipt(context.Context, *Hash) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionReceipt not implemented")
+}
+func (UnimplementedEthGatewayServer) GetLogs(context.Context, *LogFilter) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogs not implemented")
+}
+func (UnimplementedEthGatewayServer) Request(context.Context, *JsonRpcRequest) (*JsonObject, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Request not implemented")
+}
+func (UnimplementedEthGatewayServer) Subscribe(*SubscribeRequest, EthGateway_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedEthGatewayServer) mustEmbedUnimplementedEthGatewayServer() {}
+
+// UnsafeEthGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EthGatewayServer will
+// result in compilation errors.
+type UnsafeEthGatewayServer interface {
+	mustEmbedUnimplementedEthGatewayServer()
+}
+
+func RegisterEthGatewayServer(s grpc.ServiceRegistrar, srv EthGatewayServer) {
+	s.RegisterService(&EthGateway_ServiceDesc, srv)
+}
+
+func _EthGateway_ChainId_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).ChainId(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/ChainId",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).ChainId(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_BlockNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).BlockNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/BlockNumber",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).BlockNumber(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GasPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GasPrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GasPrice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GasPrice(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetBalance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetBalance(ctx, req.(*AccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetTransactionCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetTransactionCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetTransactionCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetTransactionCount(ctx, req.(*AccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetCode(ctx, req.(*AccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetStorageAt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StorageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetStorageAt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetStorageAt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetStorageAt(ctx, req.(*StorageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_Call_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).Call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/Call",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).Call(ctx, req.(*CallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_EstimateGas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).EstimateGas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/EstimateGas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).EstimateGas(ctx, req.(*CallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_SendRawTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Data)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).SendRawTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/SendRawTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).SendRawTransaction(ctx, req.(*Data))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetBlockByNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockByNumberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetBlockByNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetBlockByNumber",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetBlockByNumber(ctx, req.(*BlockByNumberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetBlockByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetBlockByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetBlockByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetBlockByHash(ctx, req.(*BlockByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetTransactionByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Hash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetTransactionByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetTransactionByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetTransactionByHash(ctx, req.(*Hash))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetTransactionReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Hash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetTransactionReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetTransactionReceipt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetTransactionReceipt(ctx, req.(*Hash))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_GetLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).GetLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/GetLogs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).GetLogs(ctx, req.(*LogFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_Request_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JsonRpcRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthGatewayServer).Request(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/confura.gateway.v1.EthGateway/Request",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthGatewayServer).Request(ctx, req.(*JsonRpcRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthGateway_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EthGatewayServer).Subscribe(m, &ethGatewaySubscribeServer{stream})
+}
+
+type EthGateway_SubscribeServer interface {
+	Send(*JsonObject) error
+	grpc.ServerStream
+}
+
+type ethGatewaySubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *ethGatewaySubscribeServer) Send(m *JsonObject) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// EthGateway_ServiceDesc is the grpc.ServiceDesc for EthGateway service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EthGateway_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "confura.gateway.v1.EthGateway",
+	HandlerType: (*EthGatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ChainId",
+			Handler:    _EthGateway_ChainId_Handler,
+		},
+		{
+			MethodName: "BlockNumber",
+			Handler:    _EthGateway_BlockNumber_Handler,
+		},
+		{
+			MethodName: "GasPrice",
+			Handler:    _EthGateway_GasPrice_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _EthGateway_GetBalance_Handler,
+		},
+		{
+			MethodName: "GetTransactionCount",
+			Handler:    _EthGateway_GetTransactionCount_Handler,
+		},
+		{
+			MethodName: "GetCode",
+			Handler:    _EthGateway_GetCode_Handler,
+		},
+		{
+			MethodName: "GetStorageAt",
+			Handler:    _EthGateway_GetStorageAt_Handler,
+		},
+		{
+			MethodName: "Call",
+			Handler:    _EthGateway_Call_Handler,
+		},
+		{
+			MethodName: "EstimateGas",
+			Handler:    _EthGateway_EstimateGas_Handler,
+		},
+		{
+			MethodName: "SendRawTransaction",
+			Handler:    _EthGateway_SendRawTransaction_Handler,
+		},
+		{
+			MethodName: "GetBlockByNumber",
+			Handler:    _EthGateway_GetBlockByNumber_Handler,
+		},
+		{
+			MethodName: "GetBlockByHash",
+			Handler:    _EthGateway_GetBlockByHash_Handler,
+		},
+		{
+			MethodName: "GetTransactionByHash",
+			Handler:    _EthGateway_GetTransactionByHash_Handler,
+		},
+		{
+			MethodName: "GetTransactionReceipt",
+			Handler:    _EthGateway_GetTransactionReceipt_Handler,
+		},
+		{
+			MethodName: "GetLogs",
+			Handler:    _EthGateway_GetLogs_Handler,
+		},
+		{
+			MethodName: "Request",
+			Handler:    _EthGateway_Request_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _EthGateway_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gateway.proto",
+}
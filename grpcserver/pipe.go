@@ -0,0 +1,78 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+var errListenerClosed = errors.New("listener closed")
+
+// pipeListener is an in-memory listener, which accepts connections dialed in process.
+type pipeListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Accept implements the net.Listener interface.
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// Close implements the net.Listener interface.
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr implements the net.Listener interface.
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+// Dial creates an in-memory connection, of which the server side reports the specified remote
+// address, eg., the gRPC peer address, so as to identify client IP address by RPC middlewares.
+func (l *pipeListener) Dial(ctx context.Context, remoteAddr net.Addr) (net.Conn, error) {
+	server, client := net.Pipe()
+
+	select {
+	case l.conns <- &peerConn{Conn: server, remoteAddr: remoteAddr}:
+		return client, nil
+	case <-ctx.Done():
+	case <-l.closed:
+	}
+
+	server.Close()
+	client.Close()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errListenerClosed
+}
+
+// peerConn overrides the remote address of the underlying connection.
+type peerConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *peerConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
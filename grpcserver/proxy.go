@@ -0,0 +1,251 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+	"github.com/openweb3/go-rpc-provider"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// JSON-RPC error codes to convert into gRPC status codes.
+const (
+	errCodeInvalidParams  = -32602
+	errCodeMethodNotFound = -32601
+	errCodeInvalidRequest = -32600
+	errCodeLimitExceeded  = -32005
+	errCodeQuotaExceeded  = -32007
+	errCodeTimeout        = -32008
+)
+
+var defaultRemoteAddr net.Addr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+type jsonRpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type jsonRpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type jsonRpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *jsonRpcError   `json:"error"`
+}
+
+// call sends JSON-RPC request with arguments to the RPC server, and unmarshals the result into
+// `result` if not nil.
+func (s *Server) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	params, err := json.Marshal(args)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid params: %v", err)
+	}
+
+	raw, err := s.request(ctx, method, params)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, result); err != nil {
+		return status.Errorf(codes.Internal, "invalid result: %v", err)
+	}
+
+	return nil
+}
+
+// request sends JSON-RPC request with raw params over the HTTP handler of RPC server, and returns
+// the raw result.
+func (s *Server) request(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	if len(params) == 0 {
+		params = json.RawMessage("[]")
+	}
+
+	body, err := json.Marshal(jsonRpcRequest{Version: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiKeyPath(md), bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = peerAddr(ctx).String()
+
+	if ua := md.Get("user-agent"); len(ua) > 0 {
+		req.Header.Set("User-Agent", ua[0])
+	}
+
+	if origin := md.Get(mdKeyOrigin); len(origin) > 0 {
+		req.Header.Set("Origin", origin[0])
+	}
+
+	rw := newResponseBuffer()
+	s.httpHandler.ServeHTTP(rw, req)
+
+	var resp jsonRpcResponse
+	if err := json.Unmarshal(rw.body.Bytes(), &resp); err != nil {
+		// rejected by HTTP middlewares without JSON-RPC response
+		return nil, status.Error(httpStatusCode(rw.statusCode()), string(bytes.TrimSpace(rw.body.Bytes())))
+	}
+
+	if resp.Error != nil {
+		return nil, status.Error(jsonRpcStatusCode(resp.Error.Code), resp.Error.Message)
+	}
+
+	return resp.Result, nil
+}
+
+// subscribe dials the websocket handler of RPC server in memory to subscribe events, of which
+// the subscription is cancelled along with the returned client closed.
+func (s *Server) subscribe(ctx context.Context, ch chan<- json.RawMessage, args ...interface{}) (
+	*rpc.Client, *rpc.ClientSubscription, error,
+) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var origin string
+	if values := md.Get(mdKeyOrigin); len(values) > 0 {
+		origin = values[0]
+	}
+
+	remoteAddr := peerAddr(ctx)
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return s.wsListener.Dial(ctx, remoteAddr)
+		},
+	}
+
+	client, err := rpc.DialWebsocketWithDialer(ctx, "ws://grpc"+apiKeyPath(md), origin, dialer)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Unavailable, "failed to connect: %v", err)
+	}
+
+	sub, err := client.EthSubscribe(ctx, ch, args...)
+	if err != nil {
+		client.Close()
+		return nil, nil, toStatusError(err)
+	}
+
+	return client, sub, nil
+}
+
+// apiKeyPath returns the URL path to identify API key as JSON-RPC server does.
+func apiKeyPath(md metadata.MD) string {
+	if values := md.Get(mdKeyApiKey); len(values) > 0 {
+		return "/" + url.PathEscape(values[0])
+	}
+
+	return "/"
+}
+
+// peerAddr returns the gRPC client address.
+func peerAddr(ctx context.Context) net.Addr {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr
+	}
+
+	return defaultRemoteAddr
+}
+
+// toStatusError converts RPC client error into gRPC status error.
+func toStatusError(err error) error {
+	if rpcErr, ok := err.(rpc.Error); ok {
+		return status.Error(jsonRpcStatusCode(rpcErr.ErrorCode()), rpcErr.Error())
+	}
+
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func jsonRpcStatusCode(code int) codes.Code {
+	switch code {
+	case errCodeInvalidParams, errCodeInvalidRequest:
+		return codes.InvalidArgument
+	case errCodeMethodNotFound:
+		return codes.Unimplemented
+	case errCodeLimitExceeded, errCodeQuotaExceeded:
+		return codes.ResourceExhausted
+	case errCodeTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Unknown
+	}
+}
+
+func httpStatusCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// responseBuffer is the http.ResponseWriter to buffer the response of in-memory HTTP call.
+type responseBuffer struct {
+	header http.Header
+	code   int // zero until header written
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header)}
+}
+
+func (rw *responseBuffer) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseBuffer) Write(p []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	return rw.body.Write(p)
+}
+
+// WriteHeader keeps the status code of the first call, same as the HTTP server does.
+func (rw *responseBuffer) WriteHeader(code int) {
+	if rw.code == 0 {
+		rw.code = code
+	}
+}
+
+// statusCode returns the written status code, which defaults to 200 OK.
+func (rw *responseBuffer) statusCode() int {
+	if rw.code == 0 {
+		return http.StatusOK
+	}
+
+	return rw.code
+}
@@ -0,0 +1,124 @@
+// Package grpcserver serves the gRPC gateway service, which mirrors the main evm space JSON-RPC
+// methods with typed messages so that internal services could talk to the gateway via generated
+// stubs over multiplexed HTTP/2 connections.
+//
+// Requests are proxied to the JSON-RPC server handlers in process, so that all the middlewares,
+// including access control, rate limit and metering, apply in the same way as HTTP/WS requests.
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/grpcserver/pb"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+)
+
+const (
+	// metadata key to specify the API key (access token)
+	mdKeyApiKey = "x-api-key"
+	// metadata key to specify the request origin
+	mdKeyOrigin = "origin"
+)
+
+// Server serves gRPC gateway service on top of a JSON-RPC server.
+type Server struct {
+	pb.UnimplementedEthGatewayServer
+
+	name        string
+	httpHandler http.Handler
+	wsServer    *http.Server
+	wsListener  *pipeListener
+	grpcServer  *grpc.Server
+}
+
+// MustNewServer creates an instance of Server to proxy gRPC requests to the specified JSON-RPC
+// server, of which websocket handler is used for subscriptions.
+func MustNewServer(name string, rpcServer *rpcutil.Server) *Server {
+	httpHandler, ok := rpcServer.Handler(rpcutil.ProtocolHttp)
+	if !ok {
+		logrus.WithField("name", name).Fatal("HTTP handler unavailable for gRPC server")
+	}
+
+	wsHandler, ok := rpcServer.Handler(rpcutil.ProtocolWS)
+	if !ok {
+		logrus.WithField("name", name).Fatal("Websocket handler unavailable for gRPC server")
+	}
+
+	server := &Server{
+		name:        name,
+		httpHandler: httpHandler,
+		wsServer:    &http.Server{Handler: wsHandler},
+		wsListener:  newPipeListener(),
+		grpcServer:  grpc.NewServer(),
+	}
+
+	pb.RegisterEthGatewayServer(server.grpcServer, server)
+
+	return server
+}
+
+// MustServe serves gRPC server in blocking way or panics if failed.
+func (s *Server) MustServe(endpoint string) {
+	logger := logrus.WithFields(logrus.Fields{
+		"name":     s.name,
+		"endpoint": endpoint,
+	})
+
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to listen to endpoint")
+	}
+
+	// serve websocket connections in memory for subscriptions
+	go s.wsServer.Serve(s.wsListener)
+
+	logger.Info("gRPC server started")
+
+	if err := s.grpcServer.Serve(listener); err != nil {
+		logger.WithError(err).Error("gRPC server stopped with error")
+	}
+}
+
+// MustServeGraceful serves gRPC server in a goroutine until graceful shutdown.
+func (s *Server) MustServeGraceful(ctx context.Context, wg *sync.WaitGroup, endpoint string) {
+	wg.Add(1)
+	defer wg.Done()
+
+	go s.MustServe(endpoint)
+
+	<-ctx.Done()
+
+	s.shutdown()
+}
+
+// shutdown stops gRPC server gracefully up to the configured timeout, and then closes all
+// connections, including subscription streams which never complete on their own.
+func (s *Server) shutdown() {
+	viper.SetDefault("rpc.shutdownTimeout", rpcutil.DefaultShutdownTimeout)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(viper.GetDuration("rpc.shutdownTimeout")):
+		logrus.WithField("name", s.name).Warn("Timeout to stop gRPC server gracefully")
+		s.grpcServer.Stop()
+	}
+
+	s.wsServer.Close()
+
+	logrus.WithField("name", s.name).Info("Succeed to shutdown gRPC server")
+}
+
+func (s *Server) String() string { return s.name }
@@ -0,0 +1,142 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/grpcserver/pb"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testEthAPI struct{}
+
+func (testEthAPI) ChainId() string { return "0x47" }
+
+func (testEthAPI) GetBalance(address, block string) (string, error) {
+	if block != defaultBlock {
+		return "", errors.New("unexpected block")
+	}
+
+	return "0x64", nil
+}
+
+func (testEthAPI) GetCode(address, block string) (string, error) {
+	return "", errors.New("execution reverted")
+}
+
+func (testEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			notifier.Notify(sub.ID, map[string]int{"number": i})
+		}
+	}()
+
+	return sub, nil
+}
+
+// rejectApiKey rejects requests of the specified access token.
+func rejectApiKey(apiKey string) handlers.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handlers.GetAccessToken(r) == apiKey {
+				http.Error(w, "access denied", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTestClient(t *testing.T) pb.EthGatewayClient {
+	rpcServer := rpcutil.MustNewServer("test_rpc", map[string]interface{}{"eth": testEthAPI{}}, rejectApiKey("bad"))
+	server := MustNewServer("test_grpc", rpcServer)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.grpcServer.Serve(listener)
+	go server.wsServer.Serve(server.wsListener)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.shutdown()
+	})
+
+	return pb.NewEthGatewayClient(conn)
+}
+
+func TestServerUnary(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	chainId, err := client.ChainId(ctx, &pb.Empty{})
+	require.NoError(t, err)
+	assert.Equal(t, "0x47", chainId.Value)
+
+	balance, err := client.GetBalance(ctx, &pb.AccountRequest{Address: "0x0"})
+	require.NoError(t, err)
+	assert.Equal(t, "0x64", balance.Value)
+
+	_, err = client.GetCode(ctx, &pb.AccountRequest{Address: "0x0"})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	_, err = client.GasPrice(ctx, &pb.Empty{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	result, err := client.Request(ctx, &pb.JsonRpcRequest{Method: "eth_chainId"})
+	require.NoError(t, err)
+	assert.Equal(t, `"0x47"`, string(result.Json))
+
+	badCtx := metadata.AppendToOutgoingContext(ctx, mdKeyApiKey, "bad")
+	_, err = client.ChainId(badCtx, &pb.Empty{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestServerSubscribe(t *testing.T) {
+	client := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{Kind: "unknown"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err = client.Subscribe(ctx, &pb.SubscribeRequest{Kind: "newHeads"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		assert.JSONEq(t, fmt.Sprintf(`{"number":%v}`, i), string(msg.Json))
+	}
+}
@@ -0,0 +1,176 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// JSON-RPC error code when method not found
+	errCodeMethodNotFound = -32601
+)
+
+// capabilityConfig configurations to probe capabilities of evm space full nodes once connected,
+// so that requests are routed to the full nodes that support the RPC method.
+type capabilityConfig struct {
+	// switch to turn on/off capability probing
+	Enabled bool
+	// timeout to probe a full node
+	Timeout time.Duration `default:"3s"`
+	// methods not implemented by all clients to probe whether supported
+	Methods []string `default:"[eth_getBlockReceipts,parity_getBlockReceipts,eth_getProof,trace_block,debug_traceTransaction]"`
+}
+
+// Capabilities of full node, eg., geth, erigon, nethermind or kroma-geth.
+type Capabilities struct {
+	Client      string          `json:"client"`                // client implementation
+	Version     string          `json:"version"`               // client version
+	Namespaces  map[string]bool `json:"namespaces,omitempty"`  // RPC namespaces, nil if unknown
+	Unsupported map[string]bool `json:"unsupported,omitempty"` // unsupported RPC methods
+}
+
+// Supports checks if RPC method is supported, which is regarded as supported if unknown.
+func (c *Capabilities) Supports(method string) bool {
+	if c.Unsupported[method] {
+		return false
+	}
+
+	if c.Namespaces == nil {
+		return true
+	}
+
+	if idx := strings.Index(method, "_"); idx > 0 {
+		return c.Namespaces[method[:idx]]
+	}
+
+	return true
+}
+
+// parseClientImpl parses client implementation from client version, eg., `Geth/v1.11.6-stable`.
+func parseClientImpl(version string) string {
+	if strings.Contains(strings.ToLower(version), "kroma") {
+		return "kroma-geth"
+	}
+
+	impl := version
+	if idx := strings.Index(version, "/"); idx >= 0 {
+		impl = version[:idx]
+	}
+
+	return strings.ToLower(impl)
+}
+
+// capabilityRegistry keeps capabilities of full nodes, which are probed once connected if
+// enabled, and updated whenever method not found responded by full node.
+type capabilityRegistry struct {
+	conf *capabilityConfig
+
+	mu   sync.Mutex
+	caps map[string]*Capabilities // node url => capabilities
+}
+
+func newCapabilityRegistry(conf *capabilityConfig) *capabilityRegistry {
+	return &capabilityRegistry{conf: conf, caps: make(map[string]*Capabilities)}
+}
+
+// probe probes capabilities of full node asynchronously, which is skipped if disabled.
+func (r *capabilityRegistry) probe(client interface{}, url string) {
+	if r == nil || !r.conf.Enabled {
+		return
+	}
+
+	c, ok := client.(interface {
+		Provider() *providers.MiddlewarableProvider
+	})
+	if !ok {
+		return
+	}
+
+	go func() {
+		caps := r.probeOnce(c.Provider().CallContext)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// keep unsupported methods learned in the meantime
+		if learned, ok := r.caps[url]; ok {
+			for method := range learned.Unsupported {
+				caps.Unsupported[method] = true
+			}
+		}
+
+		r.caps[url] = caps
+
+		logrus.WithFields(logrus.Fields{
+			"url": url, "capabilities": caps,
+		}).Info("Full node capabilities probed")
+	}()
+}
+
+func (r *capabilityRegistry) probeOnce(
+	call func(ctx context.Context, result interface{}, method string, args ...interface{}) error,
+) *Capabilities {
+	ctx, cancel := context.WithTimeout(context.Background(), r.conf.Timeout)
+	defer cancel()
+
+	caps := Capabilities{Unsupported: make(map[string]bool)}
+
+	if err := call(ctx, &caps.Version, "web3_clientVersion"); err == nil {
+		caps.Client = parseClientImpl(caps.Version)
+	}
+
+	var modules map[string]string // namespace => version
+	if err := call(ctx, &modules, "rpc_modules"); err == nil && len(modules) > 0 {
+		caps.Namespaces = make(map[string]bool, len(modules))
+		for ns := range modules {
+			caps.Namespaces[ns] = true
+		}
+	}
+
+	// methods are supported unless method not found, eg., invalid params without any argument
+	for _, method := range r.conf.Methods {
+		var result interface{}
+		if err := call(ctx, &result, method); isMethodNotFound(err) {
+			caps.Unsupported[method] = true
+		}
+	}
+
+	return &caps
+}
+
+func isMethodNotFound(err error) bool {
+	rpcErr, ok := err.(rpc.Error)
+	return ok && rpcErr.ErrorCode() == errCodeMethodNotFound
+}
+
+// supports checks if full node supports the RPC method.
+func (r *capabilityRegistry) supports(url, method string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if caps, ok := r.caps[url]; ok {
+		return caps.Supports(method)
+	}
+
+	return true
+}
+
+// setUnsupported marks RPC method as unsupported by full node.
+func (r *capabilityRegistry) setUnsupported(url, method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	caps, ok := r.caps[url]
+	if !ok {
+		caps = &Capabilities{Unsupported: make(map[string]bool)}
+		r.caps[url] = caps
+	}
+
+	caps.Unsupported[method] = true
+}
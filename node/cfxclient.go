@@ -6,8 +6,27 @@ import (
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/rpc"
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
 )
 
+// CfxClientWithContext returns a shallow copy of core space client, of which RPC requests are bound
+// to the context, eg., to cancel the RPC call to full node once timed out. Note, requests of the
+// namespaced RPC clients (eg., `Trace()` or `Debug()`) are not bound, and client is returned as it
+// is if not supported.
+func CfxClientWithContext(ctx context.Context, client sdk.ClientOperator) sdk.ClientOperator {
+	cfx, ok := client.(*sdk.Client)
+	if !ok {
+		return client
+	}
+
+	bound := *cfx
+	bound.MiddlewarableProvider = providers.NewMiddlewarableProvider(
+		rpc.NewContextProvider(ctx, cfx.MiddlewarableProvider),
+	)
+
+	return &bound
+}
+
 // CfxClientProvider provides core space client by router.
 type CfxClientProvider struct {
 	*clientProvider
@@ -17,10 +36,13 @@ func newCfxClient(url string) (interface{}, error) {
 	return rpc.NewCfxClient(url, rpc.WithClientHookMetrics(true))
 }
 
-func NewCfxClientProvider(db *mysql.MysqlStore, router Router) *CfxClientProvider {
-	return &CfxClientProvider{
+func NewCfxClientProvider(db mysql.NodeRouteReader, router Router) *CfxClientProvider {
+	cp := &CfxClientProvider{
 		clientProvider: newClientProvider(db, router, newCfxClient),
 	}
+	cp.lags = newLagTracker(&cfg.LagRouting, cp.clients, cfxLatestHeight)
+
+	return cp
 }
 
 // GetClient gets client of specific group (or use normal HTTP group as default.
@@ -44,6 +66,40 @@ func (p *CfxClientProvider) GetClientByIP(ctx context.Context, groups ...Group)
 	return client.(sdk.ClientOperator), nil
 }
 
+// GetDistinctClients gets clients of at most n distinct full nodes in group by route key, which
+// are neither saturated nor lagging behind, eg., to spread oversized batch across full nodes.
+func (p *CfxClientProvider) GetDistinctClients(key string, n int, group Group) (clients []sdk.ClientOperator) {
+	for _, client := range p.distinctClients(key, n, group) {
+		clients = append(clients, client.(sdk.ClientOperator))
+	}
+
+	return clients
+}
+
+// InSyncClient returns client of another full node in group that is in sync with the consensus
+// height if the specified one is lagging, eg., to serve requests at the latest epoch.
+func (p *CfxClientProvider) InSyncClient(
+	ctx context.Context, group Group, client sdk.ClientOperator,
+) sdk.ClientOperator {
+	if synced, ok := p.getInSyncClient(remoteAddrFromContext(ctx), group, client.GetNodeURL()); ok {
+		return synced.(sdk.ClientOperator)
+	}
+
+	return client
+}
+
+// Preflight connects to full node of specific group (or use normal HTTP group as default)
+// and checks the node status.
+func (p *CfxClientProvider) Preflight(groups ...Group) error {
+	client, err := p.GetClient(preflightRouteKey, groups...)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.GetStatus()
+	return err
+}
+
 func cfxNodeGroup(groups ...Group) Group {
 	grp := GroupCfxHttp
 	if len(groups) > 0 {
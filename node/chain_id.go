@@ -0,0 +1,68 @@
+package node
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// errChainIdUnverified is returned if failed to get the chain ID of full node for validation.
+var errChainIdUnverified = errors.New("chain ID not verified")
+
+// chainIdValidationConfig configurations to validate chain ID of full nodes, so that full nodes
+// of the wrong chain, eg., due to misconfigured URL, never serve requests.
+type chainIdValidationConfig struct {
+	// switch to turn on/off chain ID validation
+	Enabled bool
+	// expected chain ID of core space groups, 0 to skip validation
+	CfxChainId uint64
+	// expected chain ID of evm space groups, 0 to skip validation
+	EthChainId uint64
+	// expected chain ID of specific groups (eg., node route groups), which overrides the space
+	// wide chain ID
+	Groups map[string]uint64
+	// interval to re-verify chain ID of the registered full nodes
+	Interval time.Duration `default:"1m"`
+}
+
+// expectedChainId returns the expected chain ID of the group, or false if not validated.
+func (c *chainIdValidationConfig) expectedChainId(group Group) (uint64, bool) {
+	if !c.Enabled {
+		return 0, false
+	}
+
+	if chainId, ok := c.Groups[string(group)]; ok {
+		return chainId, chainId > 0
+	}
+
+	chainId := c.CfxChainId
+	if group.Space() == "eth" {
+		chainId = c.EthChainId
+	}
+
+	return chainId, chainId > 0
+}
+
+// validateChainId checks if the chain ID of full node matches the expected one of group. Note,
+// RPC failures are not regarded as mismatch, but `errChainIdUnverified` is returned instead.
+func validateChainId(group Group, n Node) error {
+	expected, ok := cfg.ChainIdValidation.expectedChainId(group)
+	if !ok {
+		return nil
+	}
+
+	chainId, err := n.ChainId()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"group": group, "node": n.Name(),
+		}).WithError(err).Warn("Failed to get chain ID of node for validation")
+		return errors.WithMessage(errChainIdUnverified, err.Error())
+	}
+
+	if chainId != expected {
+		return errors.Errorf("chain ID mismatch, expected %v got %v", expected, chainId)
+	}
+
+	return nil
+}
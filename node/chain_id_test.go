@@ -0,0 +1,109 @@
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chainIdNode struct {
+	Node
+
+	chainId uint64
+	err     error
+}
+
+func (n *chainIdNode) Name() string { return "node" }
+
+func (n *chainIdNode) ChainId() (uint64, error) { return n.chainId, n.err }
+
+func withChainIdValidation(t *testing.T, conf chainIdValidationConfig) {
+	prev := cfg.ChainIdValidation
+	cfg.ChainIdValidation = conf
+
+	t.Cleanup(func() { cfg.ChainIdValidation = prev })
+}
+
+func TestExpectedChainId(t *testing.T) {
+	conf := chainIdValidationConfig{
+		CfxChainId: 1029,
+		EthChainId: 255,
+		Groups:     map[string]uint64{"ethsepolia": 2358, "ethdevnet": 0},
+	}
+
+	// disabled
+	_, ok := conf.expectedChainId(GroupEthHttp)
+	assert.False(t, ok)
+
+	conf.Enabled = true
+
+	chainId, ok := conf.expectedChainId(GroupCfxHttp)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1029), chainId)
+
+	chainId, ok = conf.expectedChainId(GroupEthLogs)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(255), chainId)
+
+	// group overrides the space wide chain ID
+	chainId, ok = conf.expectedChainId(Group("ethsepolia"))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2358), chainId)
+
+	_, ok = conf.expectedChainId(Group("ethdevnet"))
+	assert.False(t, ok)
+
+	// validation skipped for the space
+	conf.CfxChainId = 0
+	_, ok = conf.expectedChainId(GroupCfxHttp)
+	assert.False(t, ok)
+}
+
+func TestValidateChainId(t *testing.T) {
+	withChainIdValidation(t, chainIdValidationConfig{Enabled: true, EthChainId: 255})
+
+	assert.NoError(t, validateChainId(GroupEthHttp, &chainIdNode{chainId: 255}))
+	assert.Error(t, validateChainId(GroupEthHttp, &chainIdNode{chainId: 1}))
+
+	err := validateChainId(GroupEthHttp, &chainIdNode{err: errors.New("connection refused")})
+	assert.True(t, errors.Is(err, errChainIdUnverified))
+
+	// not validated
+	assert.NoError(t, validateChainId(GroupCfxHttp, &chainIdNode{chainId: 1}))
+}
+
+func TestStatusChainIdQuarantine(t *testing.T) {
+	withChainIdValidation(t, chainIdValidationConfig{Enabled: true, EthChainId: 255, Interval: time.Hour})
+
+	// rejected if mismatched
+	status := NewStatus(GroupEthHttp, "node")
+	assert.Error(t, status.initChainId(&chainIdNode{chainId: 1}))
+
+	// quarantined if failed to get chain ID
+	n := &chainIdNode{err: errors.New("connection refused")}
+	status = NewStatus(GroupEthHttp, "node")
+	assert.NoError(t, status.initChainId(n))
+	assert.True(t, status.unhealthy)
+	assert.Error(t, status.checkHealth(0))
+
+	// re-verified on every heartbeat until verified regardless of interval
+	status.verifyChainId(n)
+	assert.Error(t, status.checkHealth(0))
+
+	n.chainId, n.err = 255, nil
+	status.verifyChainId(n)
+	assert.NoError(t, status.chainIdErr)
+
+	// RPC failures never regarded as mismatch once verified
+	status.chainIdCheckedAt = time.Time{}
+	n.err = errors.New("connection refused")
+	status.verifyChainId(n)
+	assert.NoError(t, status.chainIdErr)
+
+	// healthy node not quarantined
+	status = NewStatus(GroupEthHttp, "node")
+	assert.NoError(t, status.initChainId(&chainIdNode{chainId: 255}))
+	assert.False(t, status.unhealthy)
+}
@@ -2,11 +2,13 @@ package node
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
 	"github.com/Conflux-Chain/confura/util/rpc"
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	"github.com/pkg/errors"
@@ -16,6 +18,9 @@ import (
 const (
 	RouteKeyCacheSize       = 5000
 	RouteCacheExpirationTTL = 60 * time.Second
+
+	// route key to select full node for preflight checks
+	preflightRouteKey = "preflight"
 )
 
 var (
@@ -33,25 +38,69 @@ type clientProvider struct {
 	factory clientFactory
 	mu      sync.Mutex
 
-	// db store to load node route configs
-	db *mysql.MysqlStore
+	// store to load node route configs
+	db mysql.NodeRouteReader
 	// route key cache: route key => route group
 	routeKeyCache *util.ExpirableLruCache
 
 	// group => node name => RPC client
 	clients *util.ConcurrentMap
+
+	// ordered rules to route RPC methods to node groups
+	rulesMu    sync.RWMutex
+	routeRules []*mysql.NodeRouteRule
+
+	// limiter of in-flight requests per full node of route group
+	inflight *inflightLimiter
+	// tracker of in-flight requests and subscriptions per full node to drain
+	drains *drainTracker
+	// capabilities of full nodes, nil if not supported for the space
+	capabilities *capabilityRegistry
+	// capability tags of full nodes per route group
+	tags *tagRegistry
+	// shadow nodes per route group to mirror read traffic
+	shadows *shadowRegistry
+	// tracker of full nodes lagging behind the consensus height of group, nil if disabled
+	lags *lagTracker
 }
 
-func newClientProvider(db *mysql.MysqlStore, router Router, factory clientFactory) *clientProvider {
+func newClientProvider(db mysql.NodeRouteReader, router Router, factory clientFactory) *clientProvider {
 	return &clientProvider{
 		db:            db,
 		router:        router,
 		factory:       factory,
 		clients:       &util.ConcurrentMap{},
 		routeKeyCache: util.NewExpirableLruCache(RouteKeyCacheSize, RouteCacheExpirationTTL),
+		inflight:      newInflightLimiter(&cfg.Inflight),
+		drains:        newDrainTracker(),
+		tags:          newTagRegistry(),
+		shadows:       newShadowRegistry(),
 	}
 }
 
+// WarmUpRouteCache loads node routes from db to warm up route key cache.
+func (p *clientProvider) WarmUpRouteCache() error {
+	if p.db == nil { // db not available
+		return nil
+	}
+
+	routes, err := p.db.LoadNodeRoutes(mysql.NodeRouteFilter{Limit: RouteKeyCacheSize * 3 / 4})
+	if err != nil {
+		return errors.WithMessage(err, "failed to load node routes")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, route := range routes {
+		p.routeKeyCache.Add(route.RouteKey, Group(route.Group))
+	}
+
+	logrus.WithField("totalRoutes", len(routes)).Info("Node routes loaded to cache")
+
+	return nil
+}
+
 // getOrRegisterGroup gets or registers node group
 func (p *clientProvider) getOrRegisterGroup(group Group) *util.ConcurrentMap {
 	v, _ := p.clients.LoadOrStoreFn(group, func(k interface{}) interface{} {
@@ -130,10 +179,112 @@ func (p *clientProvider) populateCache(token string) (grp Group, ok bool) {
 	return grp, true
 }
 
+// MethodRouteGroup returns the route group of RPC method by the first matched route rule.
+func (p *clientProvider) MethodRouteGroup(method string) (Group, bool) {
+	p.rulesMu.RLock()
+	defer p.rulesMu.RUnlock()
+
+	for _, rule := range p.routeRules {
+		if rule.Match(method) {
+			return Group(rule.Group), true
+		}
+	}
+
+	return "", false
+}
+
+// ReloadRouteRules reloads the method route rules, which are removed if not configured.
+func (p *clientProvider) ReloadRouteRules(loader func() ([]*mysql.NodeRouteRule, error)) error {
+	rules, err := loader()
+	if err != nil {
+		return err
+	}
+
+	p.rulesMu.Lock()
+	defer p.rulesMu.Unlock()
+
+	p.routeRules = rules
+
+	return nil
+}
+
+// AutoReloadRouteRules reloads the method route rules periodically to hot-reload the config
+// changes.
+func (p *clientProvider) AutoReloadRouteRules(interval time.Duration, loader func() ([]*mysql.NodeRouteRule, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// load immediately at first
+	if err := p.ReloadRouteRules(loader); err != nil {
+		logrus.WithError(err).Error("Failed to load node route rules")
+	}
+
+	for range ticker.C {
+		if err := p.ReloadRouteRules(loader); err != nil {
+			logrus.WithError(err).Error("Failed to load node route rules")
+		}
+	}
+}
+
+// ReloadInflightLimits reloads the max in-flight requests per full node of route groups, along
+// with the drain windows of drained nodes, the capability tags of nodes and the shadow nodes.
+func (p *clientProvider) ReloadInflightLimits(loader func() (map[string]*mysql.NodeRouteGroup, error)) error {
+	routeGroups, err := loader()
+	if err != nil {
+		return err
+	}
+
+	p.inflight.reload(routeGroups)
+	p.drains.reload(routeGroups)
+	p.tags.reload(routeGroups)
+	p.shadows.reload(routeGroups)
+
+	return nil
+}
+
+// TrackSubscription tracks websocket subscription delegated to full node of the specified url,
+// which is migrated (eg., by closing client connection to reconnect) within the drain window
+// once node drained. Returns function to untrack once unsubscribed.
+func (p *clientProvider) TrackSubscription(url string, migrate func()) func() {
+	return p.drains.trackSubscription(url, migrate)
+}
+
+// DrainProgress returns the drain progress of drained full nodes in process.
+func (p *clientProvider) DrainProgress() []*mysql.NodeDrainProgress {
+	return p.drains.progress()
+}
+
+// AutoReloadInflightLimits reloads the max in-flight requests per full node of route groups
+// periodically to hot-reload the config changes.
+func (p *clientProvider) AutoReloadInflightLimits(
+	interval time.Duration, loader func() (map[string]*mysql.NodeRouteGroup, error),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// load immediately at first
+	if err := p.ReloadInflightLimits(loader); err != nil {
+		logrus.WithError(err).Error("Failed to load node in-flight limits")
+	}
+
+	for range ticker.C {
+		if err := p.ReloadInflightLimits(loader); err != nil {
+			logrus.WithError(err).Error("Failed to load node in-flight limits")
+		}
+	}
+}
+
+// Available checks if the full node of url in group is available to route requests explicitly,
+// eg., for read-your-writes consistency, which is neither drained nor lagging behind or down as
+// far as the gateway could tell. Note, health of full node is determined by node manager, which
+// is only reflected by the router.
+func (p *clientProvider) Available(group Group, url string) bool {
+	nodeName := rpc.Url2NodeName(url)
+	return !p.drains.drained(nodeName) && !p.lags.unavailable(group, nodeName)
+}
+
 // getClient gets client based on keyword and node group type.
 func (p *clientProvider) getClient(key string, group Group) (interface{}, error) {
-	clients := p.getOrRegisterGroup(group)
-
 	logger := logrus.WithFields(logrus.Fields{
 		"key":   key,
 		"group": group,
@@ -145,6 +296,71 @@ func (p *clientProvider) getClient(key string, group Group) (interface{}, error)
 		return nil, ErrClientUnavailable
 	}
 
+	if p.inflight.saturated(group, rpc.Url2NodeName(url)) {
+		url = p.reroute(group, key, url)
+	}
+
+	return p.getClientByUrl(url, group, logger)
+}
+
+// reroute routes key to another full node of the same group that is not saturated, or returns
+// the saturated one if not found, of which requests are queued then.
+func (p *clientProvider) reroute(group Group, key, saturated string) string {
+	for i := 1; i <= cfg.Inflight.RerouteAttempts; i++ {
+		url := p.router.Route(group, []byte(fmt.Sprintf("%v#%v", key, i)))
+		if len(url) == 0 || url == saturated {
+			continue
+		}
+
+		if nodeName := rpc.Url2NodeName(url); !p.inflight.saturated(group, nodeName) {
+			metrics.Registry.Nodes.Inflight(group.Space(), string(group), nodeName, "rerouted").Mark(1)
+			return url
+		}
+	}
+
+	return saturated
+}
+
+// distinctClients routes key to at most n distinct full nodes of group, which are neither saturated
+// nor lagging behind, by probing the derived route keys as rerouting does. Note, the full nodes are
+// healthy and not drained as routed by the router.
+func (p *clientProvider) distinctClients(key string, n int, group Group) (clients []interface{}) {
+	logger := logrus.WithFields(logrus.Fields{
+		"key":   key,
+		"group": group,
+	})
+
+	routed := make(map[string]bool)
+
+	for i := 0; len(clients) < n && i < n+cfg.Inflight.RerouteAttempts; i++ {
+		routeKey := key
+		if i > 0 {
+			routeKey = fmt.Sprintf("%v#%v", key, i)
+		}
+
+		url := p.router.Route(group, []byte(routeKey))
+		if len(url) == 0 || routed[url] {
+			continue
+		}
+
+		routed[url] = true
+
+		nodeName := rpc.Url2NodeName(url)
+		if p.inflight.saturated(group, nodeName) || p.lags.lagging(group, nodeName) {
+			continue
+		}
+
+		if client, err := p.getClientByUrl(url, group, logger); err == nil {
+			clients = append(clients, client)
+		}
+	}
+
+	return clients
+}
+
+// getClientByUrl gets or creates client of the specified full node URL in node group.
+func (p *clientProvider) getClientByUrl(url string, group Group, logger *logrus.Entry) (interface{}, error) {
+	clients := p.getOrRegisterGroup(group)
 	nodeName := rpc.Url2NodeName(url)
 
 	logger = logger.WithFields(logrus.Fields{
@@ -157,7 +373,13 @@ func (p *clientProvider) getClient(key string, group Group) (interface{}, error)
 		// TODO improvements required
 		// 1. Necessary retry? (but longer timeout). Better to let user side to decide.
 		// 2. Different metrics for different full nodes.
-		return p.factory(url)
+		client, err := p.factory(url)
+		if err == nil {
+			p.inflight.hook(client, group, url, p.drains)
+			p.capabilities.probe(client, url)
+		}
+
+		return client, err
 	})
 
 	if err != nil {
@@ -53,22 +53,26 @@ func init() {
 		GroupEthFilter: {
 			Nodes: cfg.EthFilterNodes,
 		},
+		GroupEthArchives: {
+			Nodes: cfg.EthArchiveNodes,
+		},
 	}
 }
 
 type config struct {
-	Endpoint       string `default:":22530"`
-	EthEndpoint    string `default:":28530"`
-	URLs           []string
-	EthURLs        []string
-	WSURLs         []string
-	EthWSURLs      []string
-	LogNodes       []string
-	EthLogNodes    []string
-	FilterNodes    []string
-	EthFilterNodes []string
-	ArchiveNodes   []string
-	HashRing       struct {
+	Endpoint        string `default:":22530"`
+	EthEndpoint     string `default:":28530"`
+	URLs            []string
+	EthURLs         []string
+	WSURLs          []string
+	EthWSURLs       []string
+	LogNodes        []string
+	EthLogNodes     []string
+	FilterNodes     []string
+	EthFilterNodes  []string
+	ArchiveNodes    []string
+	EthArchiveNodes []string
+	HashRing        struct {
 		PartitionCount    int     `default:"15739"`
 		ReplicationFactor int     `default:"51"`
 		Load              float64 `default:"1.25"`
@@ -86,7 +90,13 @@ type config struct {
 			SuccessCounter uint64        `default:"60"`
 		}
 	}
-	Router struct {
+	HeadTracker       headTrackerConfig
+	LagRouting        lagRoutingConfig
+	Sequencer         sequencerConfig
+	Inflight          inflightConfig
+	Capability        capabilityConfig
+	ChainIdValidation chainIdValidationConfig
+	Router            struct {
 		RedisURL        string
 		NodeRPCURL      string
 		EthNodeRPCURL   string
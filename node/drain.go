@@ -0,0 +1,216 @@
+package node
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// interval to migrate websocket subscriptions of drained nodes
+	drainMigrateInterval = time.Second
+)
+
+// nodeDrain drain of full node in route group.
+type nodeDrain struct {
+	group    Group
+	url      string
+	drain    mysql.NodeDrain
+	migrated int
+}
+
+// drainTracker tracks the in-flight requests and websocket subscriptions per full node, so that
+// subscriptions of drained nodes could be migrated to other nodes gradually within the drain
+// window, and the drain progress could be reported.
+type drainTracker struct {
+	mu       sync.Mutex
+	drains   map[string]*nodeDrain        // node name => drain
+	inflight map[string]int               // node name => in-flight requests
+	subs     map[string]map[uint64]func() // node name => subscription id => migrate func
+	nextSub  uint64
+	started  bool
+}
+
+func newDrainTracker() *drainTracker {
+	return &drainTracker{
+		drains:   make(map[string]*nodeDrain),
+		inflight: make(map[string]int),
+		subs:     make(map[string]map[uint64]func()),
+	}
+}
+
+// reload reloads drain windows of drained nodes (including nodes under maintenance) from route
+// groups, and starts to migrate subscriptions if not started yet.
+func (t *drainTracker) reload(routeGroups map[string]*mysql.NodeRouteGroup) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	drains := make(map[string]*nodeDrain)
+	for name, grp := range routeGroups {
+		for url, drain := range grp.DrainsAt(now) {
+			nodeName := rpc.Url2NodeName(url)
+			nd := &nodeDrain{group: Group(name), url: url, drain: *drain}
+
+			// keep progress if drain not changed
+			if old, ok := t.drains[nodeName]; ok && old.drain == *drain {
+				nd.migrated = old.migrated
+			}
+
+			drains[nodeName] = nd
+		}
+	}
+
+	t.drains = drains
+
+	if len(drains) > 0 && !t.started {
+		t.started = true
+		go t.run()
+	}
+}
+
+// drained checks if full node is drained (or under maintenance) in any route group.
+func (t *drainTracker) drained(nodeName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.drains[nodeName]
+	return ok
+}
+
+// trackRequest tracks in-flight request to full node, and returns function to untrack once
+// completed.
+func (t *drainTracker) trackRequest(nodeName string) func() {
+	t.mu.Lock()
+	t.inflight[nodeName]++
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if t.inflight[nodeName]--; t.inflight[nodeName] <= 0 {
+			delete(t.inflight, nodeName)
+		}
+	}
+}
+
+// trackSubscription tracks websocket subscription delegated to full node, which is migrated
+// by the specified function if node drained. Returns function to untrack once unsubscribed.
+func (t *drainTracker) trackSubscription(url string, migrate func()) func() {
+	nodeName := rpc.Url2NodeName(url)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextSub++
+	id := t.nextSub
+
+	if _, ok := t.subs[nodeName]; !ok {
+		t.subs[nodeName] = make(map[uint64]func())
+	}
+
+	t.subs[nodeName][id] = migrate
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if delete(t.subs[nodeName], id); len(t.subs[nodeName]) == 0 {
+			delete(t.subs, nodeName)
+		}
+	}
+}
+
+func (t *drainTracker) run() {
+	ticker := time.NewTicker(drainMigrateInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, migrate := range t.dueMigrations(now) {
+			// migrated out of lock, since subscription is untracked once migrated
+			migrate()
+		}
+	}
+}
+
+// dueMigrations picks the subscriptions of drained nodes to migrate, which are spread evenly
+// over the remaining drain window to avoid reconnection storm.
+func (t *drainTracker) dueMigrations(now time.Time) (result []func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for nodeName, nd := range t.drains {
+		subs := t.subs[nodeName]
+		if len(subs) == 0 {
+			continue
+		}
+
+		n := len(subs)
+		if remaining := nd.drain.Deadline().Sub(now); remaining > drainMigrateInterval {
+			n = int(math.Ceil(float64(n) * float64(drainMigrateInterval) / float64(remaining)))
+		}
+
+		picked := 0
+		for id, migrate := range subs {
+			if picked >= n {
+				break
+			}
+
+			// untracked at once so that not migrated twice
+			delete(subs, id)
+			result = append(result, migrate)
+			picked++
+		}
+
+		nd.migrated += picked
+		metrics.Registry.Nodes.DrainMigrated(nd.group.Space(), string(nd.group), nodeName).Mark(int64(picked))
+
+		logrus.WithFields(logrus.Fields{
+			"group":     nd.group,
+			"node":      nodeName,
+			"migrated":  nd.migrated,
+			"remaining": len(subs),
+		}).Debug("Migrating websocket subscriptions of drained node")
+	}
+
+	return result
+}
+
+// progress returns the drain progress of all drained nodes.
+func (t *drainTracker) progress() []*mysql.NodeDrainProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]*mysql.NodeDrainProgress, 0, len(t.drains))
+	for nodeName, nd := range t.drains {
+		inflight, subs := t.inflight[nodeName], len(t.subs[nodeName])
+
+		result = append(result, &mysql.NodeDrainProgress{
+			Group:            string(nd.group),
+			Url:              nd.url,
+			Deadline:         nd.drain.Deadline(),
+			InflightRequests: inflight,
+			Subscriptions:    subs,
+			Migrated:         nd.migrated,
+			Completed:        inflight == 0 && subs == 0,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Group != result[j].Group {
+			return result[i].Group < result[j].Group
+		}
+
+		return result[i].Url < result[j].Url
+	})
+
+	return result
+}
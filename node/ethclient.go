@@ -8,6 +8,7 @@ import (
 	"github.com/Conflux-Chain/confura/store/mysql"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
 	"github.com/openweb3/web3go"
+	"github.com/sirupsen/logrus"
 )
 
 type Web3goClient struct {
@@ -20,9 +21,21 @@ func (w3c *Web3goClient) NodeName() string {
 	return rpcutil.Url2NodeName(w3c.URL)
 }
 
+// WithContext returns a shallow copy of client, of which RPC requests are bound to the context,
+// eg., to propagate the deadline of RPC call to full node.
+func (w3c *Web3goClient) WithContext(ctx context.Context) *Web3goClient {
+	return &Web3goClient{
+		Client: web3go.NewClientWithProvider(rpcutil.NewContextProvider(ctx, w3c.Provider())),
+		URL:    w3c.URL,
+	}
+}
+
 // EthClientProvider provides evm space client by router.
 type EthClientProvider struct {
 	*clientProvider
+
+	// tracker of the active sequencer to send transactions, nil if disabled
+	sequencer *SequencerTracker
 }
 
 func newEthClient(url string) (interface{}, error) {
@@ -34,10 +47,12 @@ func newEthClient(url string) (interface{}, error) {
 	return &Web3goClient{client, url}, nil
 }
 
-func NewEthClientProvider(db *mysql.MysqlStore, router Router) *EthClientProvider {
+func NewEthClientProvider(db mysql.NodeRouteReader, router Router) *EthClientProvider {
 	cp := &EthClientProvider{
 		clientProvider: newClientProvider(db, router, newEthClient),
 	}
+	cp.capabilities = newCapabilityRegistry(&cfg.Capability)
+	cp.lags = newLagTracker(&cfg.LagRouting, cp.clients, ethLatestHeight)
 
 	return cp
 }
@@ -63,6 +78,70 @@ func (p *EthClientProvider) GetClientByIP(ctx context.Context, groups ...Group)
 	return client.(*Web3goClient), nil
 }
 
+// GetClientByTag gets client of full node tagged with the capability in group by route key, eg.,
+// archive node to serve historical state.
+func (p *EthClientProvider) GetClientByTag(key string, group Group, tag string) (*Web3goClient, error) {
+	client, err := p.getClientByTag(key, group, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*Web3goClient), nil
+}
+
+// InSyncClient returns client of another full node in group that is in sync with the consensus
+// height if the specified one is lagging, eg., to serve requests at the latest block.
+func (p *EthClientProvider) InSyncClient(ctx context.Context, group Group, client *Web3goClient) *Web3goClient {
+	if synced, ok := p.getInSyncClient(remoteAddrFromContext(ctx), group, client.URL); ok {
+		return synced.(*Web3goClient)
+	}
+
+	return client
+}
+
+// GetClientByURL gets client of the specified full node URL in group, eg., to route requests
+// to the same full node.
+func (p *EthClientProvider) GetClientByURL(url string, group Group) (*Web3goClient, error) {
+	client, err := p.getClientByUrl(url, group, logrus.WithField("group", group))
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*Web3goClient), nil
+}
+
+// GetDistinctClients gets clients of at most n distinct full nodes in group by route key, which
+// are neither saturated nor lagging behind, eg., to spread oversized batch across full nodes.
+func (p *EthClientProvider) GetDistinctClients(key string, n int, group Group) (clients []*Web3goClient) {
+	for _, client := range p.distinctClients(key, n, group) {
+		clients = append(clients, client.(*Web3goClient))
+	}
+
+	return clients
+}
+
+// GroupClients returns clients of all the connected full nodes in group.
+func (p *EthClientProvider) GroupClients(group Group) (clients []*Web3goClient) {
+	p.getOrRegisterGroup(group).Range(func(key, value interface{}) bool {
+		clients = append(clients, value.(*Web3goClient))
+		return true
+	})
+
+	return clients
+}
+
+// SupportsMethod checks if the full node of specified URL supports the RPC method, which is
+// regarded as supported if capabilities unknown yet.
+func (p *EthClientProvider) SupportsMethod(url, method string) bool {
+	return p.capabilities.supports(url, method)
+}
+
+// SetMethodUnsupported marks the RPC method as unsupported by the full node of specified URL,
+// eg., once method not found responded.
+func (p *EthClientProvider) SetMethodUnsupported(url, method string) {
+	p.capabilities.setUnsupported(url, method)
+}
+
 func (p *EthClientProvider) GetClientRandom() (*Web3goClient, error) {
 	key := fmt.Sprintf("random_key_%v", rand.Int())
 	client, err := p.getClient(key, GroupEthHttp)
@@ -70,6 +149,18 @@ func (p *EthClientProvider) GetClientRandom() (*Web3goClient, error) {
 	return client.(*Web3goClient), err
 }
 
+// Preflight connects to full node of specific group (or use normal HTTP group as default)
+// and checks the chain ID.
+func (p *EthClientProvider) Preflight(groups ...Group) error {
+	client, err := p.GetClient(preflightRouteKey, groups...)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Eth.ChainId()
+	return err
+}
+
 func ethNodeGroup(groups ...Group) Group {
 	grp := GroupEthHttp
 	if len(groups) > 0 {
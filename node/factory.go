@@ -61,7 +61,7 @@ func newFactory(nf nodeFactory, rpcSrvEndpoint string, groupConf map[Group]UrlCo
 }
 
 // CreatRpcServer creates node manager RPC server
-func (f *factory) CreatRpcServer(db *mysql.MysqlStore) (*rpc.Server, string) {
+func (f *factory) CreatRpcServer(db mysql.NodeRouteGroupStore) (*rpc.Server, string) {
 	return MustNewServer(db, f.nodeFactory, f.groupConf), f.rpcSrvEndpoint
 }
 
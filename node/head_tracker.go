@@ -0,0 +1,219 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// HeadPolicyQuorum answers the highest head reached by a quorum of nodes.
+	HeadPolicyQuorum = "quorum"
+	// HeadPolicyMinLag answers the highest head among nodes, i.e. the least lagging node.
+	HeadPolicyMinLag = "minLag"
+)
+
+// headTrackerConfig configurations of head tracker to answer the latest block number by consensus
+// among full nodes.
+type headTrackerConfig struct {
+	// switch to turn on/off head tracker
+	Enabled bool
+	// interval to poll the latest block number of full nodes
+	Interval time.Duration `default:"1s"`
+	// timeout to poll the latest block number of a full node
+	Timeout time.Duration `default:"3s"`
+	// policy to aggregate heads, `quorum` or `minLag`
+	Policy string `default:"quorum"`
+	// number of nodes for `quorum` policy, or the majority of nodes if 0
+	Quorum int
+	// heads not updated within the duration are excluded, eg., node is down, and so is the
+	// aggregated head if not confirmed by enough nodes within the duration
+	StaleTimeout time.Duration `default:"10s"`
+}
+
+// nodeHead is the latest head reported by full node.
+type nodeHead struct {
+	number    uint64
+	updatedAt time.Time
+}
+
+// HeadTracker aggregates the latest block numbers of all connected full nodes in a group, so that
+// the answer keeps consistent regardless of which node served the call. Besides, the aggregated
+// head never goes backward unless expired.
+type HeadTracker struct {
+	conf    headTrackerConfig
+	clients *util.ConcurrentMap // node name => RPC client
+	latest  func(ctx context.Context, client interface{}) (uint64, error)
+
+	mu        sync.Mutex
+	heads     map[string]nodeHead // node name => head
+	head      uint64              // aggregated head
+	updatedAt int64               // unix nano when the aggregated head confirmed lastly
+}
+
+func newHeadTracker(
+	conf headTrackerConfig,
+	clients *util.ConcurrentMap,
+	latest func(ctx context.Context, client interface{}) (uint64, error),
+) *HeadTracker {
+	return &HeadTracker{
+		conf:    conf,
+		clients: clients,
+		latest:  latest,
+		heads:   make(map[string]nodeHead),
+	}
+}
+
+// MustNewHeadTrackerFromViper creates a head tracker for the group of evm space full nodes and
+// starts to poll in a separate goroutine, or returns nil if disabled.
+func (p *EthClientProvider) MustNewHeadTrackerFromViper(group Group) *HeadTracker {
+	conf := cfg.HeadTracker
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Policy != HeadPolicyQuorum && conf.Policy != HeadPolicyMinLag {
+		logrus.WithField("policy", conf.Policy).Fatal("Invalid head tracker policy")
+	}
+
+	// bound to the poll context, so that a hanging node never blocks polling others
+	latest := func(ctx context.Context, client interface{}) (uint64, error) {
+		return ethLatestHeight(client.(*Web3goClient).WithContext(ctx))
+	}
+
+	tracker := newHeadTracker(conf, p.getOrRegisterGroup(group), latest)
+
+	logrus.WithFields(logrus.Fields{
+		"group": group, "config": conf,
+	}).Info("Head tracker started")
+
+	go tracker.run()
+
+	return tracker
+}
+
+// run polls heads periodically, which lives as long as the process.
+func (t *HeadTracker) run() {
+	ticker := time.NewTicker(t.conf.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.poll()
+	}
+}
+
+// poll requests the latest block numbers of all connected full nodes concurrently.
+func (t *HeadTracker) poll() {
+	var wg sync.WaitGroup
+
+	t.clients.Range(func(key, value interface{}) bool {
+		wg.Add(1)
+
+		go func(nodeName string, client interface{}) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), t.conf.Timeout)
+			defer cancel()
+
+			number, err := t.latest(ctx, client)
+			if err != nil {
+				logrus.WithField("node", nodeName).WithError(err).Debug("Head tracker failed to poll node")
+				return
+			}
+
+			t.Report(nodeName, number)
+		}(key.(string), value)
+
+		return true
+	})
+
+	wg.Wait()
+}
+
+// Report reports the latest head of full node and updates the aggregated head.
+func (t *HeadTracker) Report(nodeName string, number uint64) {
+	t.report(nodeName, number, time.Now())
+}
+
+func (t *HeadTracker) report(nodeName string, number uint64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.heads[nodeName] = nodeHead{number: number, updatedAt: now}
+
+	var numbers []uint64
+	for name, head := range t.heads {
+		if now.Sub(head.updatedAt) > t.conf.StaleTimeout {
+			delete(t.heads, name)
+			continue
+		}
+
+		numbers = append(numbers, head.number)
+	}
+
+	head := t.aggregate(numbers)
+	if head == 0 { // not enough nodes
+		return
+	}
+
+	// aggregated head might go backward once expired, eg., all nodes were down for a while
+	if head > atomic.LoadUint64(&t.head) || t.expired(now) {
+		atomic.StoreUint64(&t.head, head)
+	}
+
+	atomic.StoreInt64(&t.updatedAt, now.UnixNano())
+}
+
+// expired checks if the aggregated head not confirmed by enough nodes within the stale timeout.
+func (t *HeadTracker) expired(now time.Time) bool {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&t.updatedAt))) > t.conf.StaleTimeout
+}
+
+// aggregate returns the aggregated head according to policy, or 0 if not enough nodes.
+func (t *HeadTracker) aggregate(numbers []uint64) uint64 {
+	if len(numbers) == 0 {
+		return 0
+	}
+
+	// in descending order
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] > numbers[j] })
+
+	if t.conf.Policy == HeadPolicyMinLag {
+		return numbers[0]
+	}
+
+	quorum := t.conf.Quorum
+	if quorum <= 0 {
+		quorum = len(numbers)/2 + 1
+	}
+
+	if quorum > len(numbers) {
+		return 0
+	}
+
+	// the highest head reached by at least quorum nodes
+	return numbers[quorum-1]
+}
+
+// Head returns the aggregated head, or false if not available yet, expired or tracker is nil.
+func (t *HeadTracker) Head() (uint64, bool) {
+	return t.headAt(time.Now())
+}
+
+func (t *HeadTracker) headAt(now time.Time) (uint64, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	head := atomic.LoadUint64(&t.head)
+	if head == 0 || t.expired(now) {
+		return 0, false
+	}
+
+	return head, true
+}
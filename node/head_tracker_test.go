@@ -0,0 +1,110 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHeadTracker(policy string, quorum int) *HeadTracker {
+	return newHeadTracker(headTrackerConfig{
+		Timeout: 100 * time.Millisecond, Policy: policy, Quorum: quorum, StaleTimeout: 10 * time.Second,
+	}, &util.ConcurrentMap{}, nil)
+}
+
+func TestHeadTrackerAggregate(t *testing.T) {
+	quorum := newTestHeadTracker(HeadPolicyQuorum, 0)
+	assert.Equal(t, uint64(0), quorum.aggregate(nil))
+	assert.Equal(t, uint64(10), quorum.aggregate([]uint64{10}))
+	// majority of 3 nodes
+	assert.Equal(t, uint64(11), quorum.aggregate([]uint64{12, 10, 11}))
+	// majority of 4 nodes
+	assert.Equal(t, uint64(11), quorum.aggregate([]uint64{13, 10, 12, 11}))
+
+	quorum = newTestHeadTracker(HeadPolicyQuorum, 2)
+	assert.Equal(t, uint64(12), quorum.aggregate([]uint64{13, 10, 12, 11}))
+	// not enough nodes
+	assert.Equal(t, uint64(0), quorum.aggregate([]uint64{13}))
+
+	minLag := newTestHeadTracker(HeadPolicyMinLag, 0)
+	assert.Equal(t, uint64(13), minLag.aggregate([]uint64{13, 10, 12, 11}))
+}
+
+func TestHeadTrackerReport(t *testing.T) {
+	tracker := newTestHeadTracker(HeadPolicyQuorum, 2)
+	now := time.Now()
+
+	_, ok := tracker.headAt(now)
+	assert.False(t, ok)
+
+	// not enough nodes
+	tracker.report("node1", 10, now)
+	_, ok = tracker.headAt(now)
+	assert.False(t, ok)
+
+	tracker.report("node2", 12, now)
+	head, ok := tracker.headAt(now)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), head)
+
+	// never goes backward
+	tracker.report("node2", 9, now)
+	head, _ = tracker.headAt(now)
+	assert.Equal(t, uint64(10), head)
+
+	// stale heads excluded
+	now = now.Add(5 * time.Second)
+	tracker.report("node3", 15, now)
+	tracker.report("node3", 16, now.Add(6*time.Second))
+	assert.NotContains(t, tracker.heads, "node1")
+	assert.NotContains(t, tracker.heads, "node2")
+
+	// expired if not confirmed by enough nodes
+	_, ok = tracker.headAt(now.Add(16 * time.Second))
+	assert.False(t, ok)
+
+	// might go backward once expired
+	now = now.Add(20 * time.Second)
+	tracker.report("node1", 8, now)
+	tracker.report("node2", 9, now)
+	head, ok = tracker.headAt(now)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(8), head)
+
+	// disabled
+	var disabled *HeadTracker
+	_, ok = disabled.Head()
+	assert.False(t, ok)
+}
+
+func TestHeadTrackerPollTimeout(t *testing.T) {
+	tracker := newTestHeadTracker(HeadPolicyMinLag, 0)
+	tracker.clients.Store("node1", "hanging")
+	tracker.clients.Store("node2", "healthy")
+	tracker.clients.Store("node3", "failing")
+
+	tracker.latest = func(ctx context.Context, client interface{}) (uint64, error) {
+		switch client {
+		case "hanging":
+			<-ctx.Done()
+			return 0, ctx.Err()
+		case "healthy":
+			return 10, nil
+		default:
+			return 0, errors.New("connection refused")
+		}
+	}
+
+	start := time.Now()
+	tracker.poll()
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	head, ok := tracker.Head()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), head)
+	assert.Len(t, tracker.heads, 1)
+}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/openweb3/go-rpc-provider"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/pkg/errors"
+)
+
+// inflightConfig configurations to handle requests beyond the max in-flight requests of full
+// node, which is configured per route group.
+type inflightConfig struct {
+	// max duration to wait for an in-flight slot of the full node before rejected
+	QueueTimeout time.Duration `default:"100ms"`
+	// max attempts to reroute requests to other full nodes of the same group if saturated
+	RerouteAttempts int `default:"3"`
+}
+
+// errNodeOverloaded is returned if too many in-flight requests of the full node.
+func errNodeOverloaded(node string) error {
+	return errors.Errorf("too many in-flight requests to full node %v", node)
+}
+
+// inflightLimiter limits the number of in-flight requests per full node of route group, so
+// that a slow full node can't accumulate unbounded pending requests.
+type inflightLimiter struct {
+	conf *inflightConfig
+
+	mu     sync.Mutex
+	limits map[Group]int                      // group => max in-flight requests per node
+	sems   map[Group]map[string]chan struct{} // group => node name => semaphore
+}
+
+func newInflightLimiter(conf *inflightConfig) *inflightLimiter {
+	return &inflightLimiter{
+		conf:   conf,
+		limits: make(map[Group]int),
+		sems:   make(map[Group]map[string]chan struct{}),
+	}
+}
+
+// reload updates the max in-flight requests per node of route groups, in which semaphores of
+// the changed groups are recreated, while the in-flight requests are still released to the old
+// ones.
+func (l *inflightLimiter) reload(routeGroups map[string]*mysql.NodeRouteGroup) {
+	limits := make(map[Group]int)
+	for name, grp := range routeGroups {
+		if grp.MaxInflight > 0 {
+			limits[Group(name)] = grp.MaxInflight
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for grp := range l.sems {
+		if l.limits[grp] != limits[grp] {
+			delete(l.sems, grp)
+		}
+	}
+
+	l.limits = limits
+}
+
+// semaphore returns the semaphore of full node in group, or nil if not limited.
+func (l *inflightLimiter) semaphore(group Group, node string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limits[group]
+	if !ok {
+		return nil
+	}
+
+	if _, ok := l.sems[group]; !ok {
+		l.sems[group] = make(map[string]chan struct{})
+	}
+
+	sem, ok := l.sems[group][node]
+	if !ok {
+		sem = make(chan struct{}, limit)
+		l.sems[group][node] = sem
+	}
+
+	return sem
+}
+
+// saturated checks if the max in-flight requests of full node in group reached.
+func (l *inflightLimiter) saturated(group Group, node string) bool {
+	sem := l.semaphore(group, node)
+	return sem != nil && len(sem) >= cap(sem)
+}
+
+// acquire acquires an in-flight slot of full node in group, which waits for the queue timeout
+// at most if saturated. Returns function to release the slot if succeeded.
+func (l *inflightLimiter) acquire(ctx context.Context, group Group, node string) (func(), error) {
+	sem := l.semaphore(group, node)
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-sem }
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	metrics.Registry.Nodes.Inflight(group.Space(), string(group), node, "queued").Mark(1)
+
+	timer := time.NewTimer(l.conf.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	metrics.Registry.Nodes.Inflight(group.Space(), string(group), node, "rejected").Mark(1)
+
+	return nil, errNodeOverloaded(node)
+}
+
+// hook hooks middlewares into RPC client of full node in group to limit in-flight requests,
+// which are also tracked for drain progress.
+func (l *inflightLimiter) hook(client interface{}, group Group, url string, drains *drainTracker) {
+	c, ok := client.(interface {
+		Provider() *providers.MiddlewarableProvider
+	})
+	if !ok {
+		return
+	}
+
+	node := rpcutil.Url2NodeName(url)
+
+	c.Provider().HookCallContext(func(handler providers.CallContextFunc) providers.CallContextFunc {
+		return func(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+			release, err := l.acquire(ctx, group, node)
+			if err != nil {
+				return err
+			}
+			defer release()
+			defer drains.trackRequest(node)()
+
+			return handler(ctx, result, method, args...)
+		}
+	})
+
+	c.Provider().HookBatchCallContext(func(handler providers.BatchCallContextFunc) providers.BatchCallContextFunc {
+		return func(ctx context.Context, b []rpc.BatchElem) error {
+			release, err := l.acquire(ctx, group, node)
+			if err != nil {
+				return err
+			}
+			defer release()
+			defer drains.trackRequest(node)()
+
+			return handler(ctx, b)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package node
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// lagRoutingConfig configurations to route requests at the latest block away from the full nodes
+// lagging behind the consensus height of route group.
+type lagRoutingConfig struct {
+	// switch to turn on/off lag-aware routing
+	Enabled bool
+	// interval to poll the latest height of connected full nodes
+	Interval time.Duration `default:"1s"`
+	// max number of blocks (or epochs) behind the consensus height, beyond which node is lagging
+	MaxLag uint64 `default:"5"`
+	// heights not updated within the duration are excluded, eg., node is down
+	StaleTimeout time.Duration `default:"10s"`
+}
+
+// lagTracker tracks the latest heights of connected full nodes per group, of which the median is
+// regarded as the consensus height of group, so that lagging nodes could be told.
+type lagTracker struct {
+	conf    *lagRoutingConfig
+	clients *util.ConcurrentMap // group => node name => RPC client
+	latest  func(client interface{}) (uint64, error)
+
+	mu    sync.Mutex
+	heads map[Group]map[string]nodeHead // group => node name => head
+	lags  map[Group]map[string]uint64   // group => node name => lag behind consensus height
+	down  map[Group]map[string]bool     // group => node name => whether height polls timed out
+}
+
+// newLagTracker creates lag tracker of the connected full nodes and starts to poll in a separate
+// goroutine, or returns nil if disabled.
+func newLagTracker(
+	conf *lagRoutingConfig, clients *util.ConcurrentMap, latest func(client interface{}) (uint64, error),
+) *lagTracker {
+	if !conf.Enabled {
+		return nil
+	}
+
+	t := &lagTracker{
+		conf:    conf,
+		clients: clients,
+		latest:  latest,
+		heads:   make(map[Group]map[string]nodeHead),
+		lags:    make(map[Group]map[string]uint64),
+		down:    make(map[Group]map[string]bool),
+	}
+
+	logrus.WithField("config", conf).Info("Lag-aware routing enabled")
+
+	go t.run()
+
+	return t
+}
+
+// run polls heights periodically, which lives as long as the process.
+func (t *lagTracker) run() {
+	ticker := time.NewTicker(t.conf.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.poll()
+		t.update(time.Now())
+	}
+}
+
+// poll requests the latest heights of all connected full nodes concurrently.
+func (t *lagTracker) poll() {
+	var wg sync.WaitGroup
+
+	t.clients.Range(func(grp, clients interface{}) bool {
+		clients.(*util.ConcurrentMap).Range(func(key, client interface{}) bool {
+			wg.Add(1)
+
+			go func(group Group, nodeName string, client interface{}) {
+				defer wg.Done()
+
+				number, err := t.latest(client)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"group": group, "node": nodeName,
+					}).WithError(err).Debug("Lag tracker failed to poll node")
+					return
+				}
+
+				t.report(group, nodeName, number, time.Now())
+			}(grp.(Group), key.(string), client)
+
+			return true
+		})
+
+		return true
+	})
+
+	wg.Wait()
+}
+
+// report reports the latest height of full node in group.
+func (t *lagTracker) report(group Group, nodeName string, number uint64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	heads, ok := t.heads[group]
+	if !ok {
+		heads = make(map[string]nodeHead)
+		t.heads[group] = heads
+	}
+
+	heads[nodeName] = nodeHead{number: number, updatedAt: now}
+	delete(t.down[group], nodeName)
+}
+
+// update updates the lags of full nodes behind the consensus height of group, excluding the stale
+// heights, of which full nodes are regarded as down until reported again.
+func (t *lagTracker) update(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lags := make(map[Group]map[string]uint64, len(t.heads))
+
+	for group, heads := range t.heads {
+		numbers := make([]uint64, 0, len(heads))
+		for name, head := range heads {
+			if now.Sub(head.updatedAt) > t.conf.StaleTimeout {
+				delete(heads, name)
+
+				if _, ok := t.down[group]; !ok {
+					t.down[group] = make(map[string]bool)
+				}
+
+				t.down[group][name] = true
+				continue
+			}
+
+			numbers = append(numbers, head.number)
+		}
+
+		if len(numbers) == 0 {
+			continue
+		}
+
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		consensus := numbers[len(numbers)/2]
+
+		lags[group] = make(map[string]uint64, len(heads))
+		for name, head := range heads {
+			var lag uint64
+			if head.number < consensus {
+				lag = consensus - head.number
+			}
+
+			lags[group][name] = lag
+			metrics.Registry.Nodes.Lag(group.Space(), string(group), name).Update(int64(lag))
+		}
+	}
+
+	t.lags = lags
+}
+
+// lag returns the number of blocks (or epochs) that full node lags behind the consensus height of
+// group, or false if unknown or tracker is nil.
+func (t *lagTracker) lag(group Group, nodeName string) (uint64, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lag, ok := t.lags[group][nodeName]
+	return lag, ok
+}
+
+// lagging checks if full node lags too far behind the consensus height of group, which is regarded
+// as in sync if unknown.
+func (t *lagTracker) lagging(group Group, nodeName string) bool {
+	lag, ok := t.lag(group, nodeName)
+	return ok && lag > t.conf.MaxLag
+}
+
+// unavailable checks if full node is lagging too far behind the consensus height of group, or down
+// since height polls timed out, which is regarded as available if tracker is nil.
+func (t *lagTracker) unavailable(group Group, nodeName string) bool {
+	if t == nil {
+		return false
+	}
+
+	if t.lagging(group, nodeName) {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.down[group][nodeName]
+}
+
+// getInSyncClient gets client of full node in sync with the consensus height of group by route key
+// if the full node of url is lagging, which is routed as usual at first, and then chosen among the
+// connected full nodes. Returns false if not lagging or no full node in sync.
+func (p *clientProvider) getInSyncClient(key string, group Group, url string) (interface{}, bool) {
+	lagging := rpc.Url2NodeName(url)
+	if !p.lags.lagging(group, lagging) {
+		return nil, false
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"key":     key,
+		"group":   group,
+		"lagging": lagging,
+	})
+
+	rerouted := metrics.Registry.Nodes.LagRerouted(group.Space(), string(group), lagging)
+
+	for i := 1; i <= cfg.Inflight.RerouteAttempts; i++ {
+		url := p.router.Route(group, []byte(fmt.Sprintf("%v#%v", key, i)))
+		if len(url) > 0 && !p.lags.lagging(group, rpc.Url2NodeName(url)) {
+			if client, err := p.getClientByUrl(url, group, logger); err == nil {
+				rerouted.Mark(1)
+				return client, true
+			}
+		}
+	}
+
+	var names []string
+	clients := p.getOrRegisterGroup(group)
+	clients.Range(func(k, v interface{}) bool {
+		if name := k.(string); !p.lags.lagging(group, name) {
+			names = append(names, name)
+		}
+
+		return true
+	})
+
+	if len(names) == 0 {
+		logger.Warn("No full node in sync to reroute requests at the latest block")
+		return nil, false
+	}
+
+	sort.Strings(names)
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	client, ok := clients.Load(names[h.Sum32()%uint32(len(names))])
+	if ok {
+		rerouted.Mark(1)
+	}
+
+	return client, ok
+}
+
+// NodeLag returns the number of blocks (or epochs) that full node of url lags behind the consensus
+// height of group, or false if unknown, eg., lag-aware routing disabled.
+func (p *clientProvider) NodeLag(group, url string) (uint64, bool) {
+	return p.lags.lag(Group(group), rpc.Url2NodeName(url))
+}
+
+// ethLatestHeight returns the latest block number of evm space full node client.
+func ethLatestHeight(client interface{}) (uint64, error) {
+	bn, err := client.(*Web3goClient).Eth.BlockNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	if bn == nil {
+		return 0, errors.New("invalid block number")
+	}
+
+	return bn.Uint64(), nil
+}
+
+// cfxLatestHeight returns the latest mined epoch number of core space full node client.
+func cfxLatestHeight(client interface{}) (uint64, error) {
+	epoch, err := client.(sdk.ClientOperator).GetEpochNumber(types.EpochLatestMined)
+	if err != nil {
+		return 0, err
+	}
+
+	if epoch == nil {
+		return 0, errors.New("invalid epoch number")
+	}
+
+	return epoch.ToInt().Uint64(), nil
+}
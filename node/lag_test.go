@@ -0,0 +1,59 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLagTrackerUnavailable(t *testing.T) {
+	tracker := &lagTracker{
+		conf:    &lagRoutingConfig{MaxLag: 5, StaleTimeout: 10 * time.Second},
+		clients: &util.ConcurrentMap{},
+		heads:   make(map[Group]map[string]nodeHead),
+		lags:    make(map[Group]map[string]uint64),
+		down:    make(map[Group]map[string]bool),
+	}
+
+	now := time.Now()
+	tracker.report(GroupEthHttp, "node1", 100, now)
+	tracker.report(GroupEthHttp, "node2", 100, now)
+	tracker.report(GroupEthHttp, "node3", 90, now)
+	tracker.update(now)
+
+	assert.False(t, tracker.unavailable(GroupEthHttp, "node1"))
+	assert.True(t, tracker.unavailable(GroupEthHttp, "node3"))
+
+	// unknown node regarded as available
+	assert.False(t, tracker.unavailable(GroupEthHttp, "node4"))
+
+	// down once height polls timed out
+	now = now.Add(11 * time.Second)
+	tracker.report(GroupEthHttp, "node1", 101, now)
+	tracker.report(GroupEthHttp, "node3", 101, now)
+	tracker.update(now)
+
+	assert.True(t, tracker.unavailable(GroupEthHttp, "node2"))
+	assert.False(t, tracker.unavailable(GroupEthHttp, "node3"))
+
+	// available again once reported
+	tracker.report(GroupEthHttp, "node2", 101, now)
+	tracker.update(now)
+	assert.False(t, tracker.unavailable(GroupEthHttp, "node2"))
+
+	// disabled
+	var disabled *lagTracker
+	assert.False(t, disabled.unavailable(GroupEthHttp, "node2"))
+}
+
+func TestDrainTrackerDrained(t *testing.T) {
+	tracker := newDrainTracker()
+	assert.False(t, tracker.drained("node1"))
+
+	tracker.drains["node1"] = &nodeDrain{group: GroupEthHttp, url: "http://node1", drain: mysql.NodeDrain{}}
+	assert.True(t, tracker.drained("node1"))
+	assert.False(t, tracker.drained("node2"))
+}
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"fmt"
 	"strings"
 	"sync"
 
@@ -25,6 +26,23 @@ type Manager struct {
 
 	nodeName2Epochs map[string]uint64 // node name => epoch
 	midEpoch        uint64            // middle epoch of managed full nodes.
+
+	weights map[string]int  // node name => weight, which is 1 by default
+	drained map[string]bool // node name => drained, which accepts no new traffic
+}
+
+// ringMember is a replica of node in hash ring, so that node of more weight gets more keys.
+type ringMember struct {
+	Node
+	replica int
+}
+
+func (rm *ringMember) String() string {
+	return replicaName(rm.Name(), rm.replica)
+}
+
+func replicaName(nodeName string, replica int) string {
+	return fmt.Sprintf("%v#%d", nodeName, replica)
 }
 
 func NewManager(group Group) *Manager {
@@ -38,6 +56,8 @@ func NewManagerWithRepartition(group Group, resolver RepartitionResolver) *Manag
 		resolver:        resolver,
 		nodeName2Epochs: make(map[string]uint64),
 		hashRing:        consistent.New(nil, cfg.HashRingRaw()),
+		weights:         make(map[string]int),
+		drained:         make(map[string]bool),
 	}
 }
 
@@ -59,7 +79,11 @@ func (m *Manager) Add(nodes ...Node) {
 	for _, n := range nodes {
 		if _, ok := m.nodes[n.Name()]; !ok {
 			m.nodes[n.Name()] = n
-			m.hashRing.Add(n)
+
+			// quarantined node is added into hash ring once reported healthy
+			if !n.Status().unhealthy {
+				m.ringAdd(n)
+			}
 		}
 	}
 }
@@ -74,7 +98,7 @@ func (m *Manager) Remove(nodeNames ...string) {
 			node.Close()
 			delete(m.nodes, nn)
 			delete(m.nodeName2Epochs, nn)
-			m.hashRing.Remove(nn)
+			m.ringRemove(nn)
 		}
 	}
 }
@@ -102,6 +126,59 @@ func (m *Manager) List() []Node {
 	return nodes
 }
 
+// Configure updates node weights and drained nodes by node name, and then rebuilds the hash
+// ring. Note, drained nodes are still monitored but accept no new traffic.
+func (m *Manager) Configure(weights map[string]int, drained map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.nodes {
+		m.ringRemove(name)
+	}
+
+	m.weights, m.drained = weights, drained
+
+	for _, n := range m.nodes {
+		if !n.Status().unhealthy {
+			m.ringAdd(n)
+		}
+	}
+}
+
+// RouteOptions returns the weight and drain state of node by name.
+func (m *Manager) RouteOptions(nodeName string) (weight int, drained bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if weight = m.weights[nodeName]; weight <= 0 {
+		weight = 1
+	}
+
+	return weight, m.drained[nodeName]
+}
+
+// ringAdd adds node into hash ring with replicas as per weight, unless drained.
+func (m *Manager) ringAdd(n Node) {
+	if m.drained[n.Name()] {
+		return
+	}
+
+	m.hashRing.Add(n)
+
+	for i := 1; i < m.weights[n.Name()]; i++ {
+		m.hashRing.Add(&ringMember{Node: n, replica: i})
+	}
+}
+
+// ringRemove removes node along with all replicas from hash ring.
+func (m *Manager) ringRemove(nodeName string) {
+	m.hashRing.Remove(nodeName)
+
+	for i := 1; i < m.weights[nodeName]; i++ {
+		m.hashRing.Remove(replicaName(nodeName, i))
+	}
+}
+
 // String implements stringer interface
 func (m *Manager) String() string {
 	m.mu.RLock()
@@ -124,7 +201,7 @@ func (m *Manager) Distribute(key []byte) Node {
 	defer m.mu.RUnlock()
 
 	// Use repartition resolver to distribute if configured.
-	if name, ok := m.resolver.Get(k); ok {
+	if name, ok := m.resolver.Get(k); ok && !m.drained[name] {
 		return m.nodes[name]
 	}
 
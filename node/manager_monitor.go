@@ -51,7 +51,9 @@ func (m *Manager) ReportUnhealthy(nodeName string, remind bool, reason error) {
 	}
 
 	// remove unhealthy node from hash ring
-	m.hashRing.Remove(nodeName)
+	m.mu.Lock()
+	m.ringRemove(nodeName)
+	m.mu.Unlock()
 
 	// FIXME update repartition cache if configured
 }
@@ -61,6 +63,11 @@ func (m *Manager) ReportHealthy(nodeName string) {
 	// alert
 	logrus.WithField("node", nodeName).Warn("Node became healthy now")
 
-	// add recovered node into hash ring again
-	m.hashRing.Add(m.nodes[nodeName])
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// add recovered node into hash ring again unless drained
+	if n, ok := m.nodes[nodeName]; ok {
+		m.ringAdd(n)
+	}
 }
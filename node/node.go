@@ -28,6 +28,7 @@ type Node interface {
 	Status() Status
 
 	LatestEpochNumber() (uint64, error)
+	ChainId() (uint64, error)
 
 	Close()
 }
@@ -105,7 +106,13 @@ func NewEthNode(group Group, name, url string, hm HealthMonitor) (*EthNode, erro
 		Client:   eth,
 	}
 
-	n.atomicStatus.Store(NewStatus(group, name))
+	status := NewStatus(group, name)
+	if err := status.initChainId(n); err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	n.atomicStatus.Store(status)
 
 	go n.monitor(ctx, n, hm)
 
@@ -127,6 +134,20 @@ func (n *EthNode) LatestEpochNumber() (uint64, error) {
 	return block.Uint64(), nil
 }
 
+// ChainId returns the chain ID of the evm space fullnode
+func (n *EthNode) ChainId() (uint64, error) {
+	chainId, err := n.Eth.ChainId()
+	if err != nil {
+		return 0, err
+	}
+
+	if chainId == nil {
+		return 0, errors.New("invalid chain id")
+	}
+
+	return *chainId, nil
+}
+
 // CfxNode represents a core space fullnode with friendly name and health status.
 type CfxNode struct {
 	sdk.ClientOperator
@@ -147,7 +168,13 @@ func NewCfxNode(group Group, name, url string, hm HealthMonitor) (*CfxNode, erro
 		ClientOperator: cfx,
 	}
 
-	n.atomicStatus.Store(NewStatus(group, name))
+	status := NewStatus(group, name)
+	if err := status.initChainId(n); err != nil {
+		cfx.Close()
+		return nil, err
+	}
+
+	n.atomicStatus.Store(status)
 
 	go n.monitor(ctx, n, hm)
 
@@ -169,6 +196,16 @@ func (n *CfxNode) LatestEpochNumber() (uint64, error) {
 	return epoch.ToInt().Uint64(), nil
 }
 
+// ChainId returns the chain ID of the core space fullnode
+func (n *CfxNode) ChainId() (uint64, error) {
+	status, err := n.GetStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(status.ChainID), nil
+}
+
 func (n *CfxNode) Close() {
 	n.baseNode.Close()
 	n.ClientOperator.Close()
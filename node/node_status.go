@@ -29,6 +29,7 @@ type HealthMonitor interface {
 
 // Status represents the node status, including current epoch number and health status.
 type Status struct {
+	group    Group
 	nodeName string
 
 	metric *statusMetrics
@@ -40,6 +41,13 @@ type Status struct {
 	unhealthy        bool
 	unhealthReportAt time.Time
 
+	chainIdErr       error
+	chainIdCheckedAt time.Time
+
+	// route options of node in group, which are filled on query
+	weight  int
+	drained bool
+
 	latestHeartBeatErrs *ring.Ring
 }
 
@@ -48,6 +56,7 @@ func NewStatus(group Group, nodeName string) Status {
 	hbErrRingBuf.SetCapacity(int(2 * cfg.Monitor.Unhealth.Failures))
 
 	return Status{
+		group:    group,
 		nodeName: nodeName,
 		metric: newStatusMetrics(
 			metrics.Registry.Nodes.NodeLatency(group.Space(), group.String(), nodeName),
@@ -60,6 +69,7 @@ func NewStatus(group Group, nodeName string) Status {
 // Update heartbeats with node and updates health status.
 func (s *Status) Update(n Node, monitor HealthMonitor) {
 	s.heartbeat(n)
+	s.verifyChainId(n)
 	s.updateHealth(monitor)
 }
 
@@ -80,6 +90,9 @@ func (s *Status) MarshalJSON() ([]byte, error) {
 		Unhealthy        bool   `json:"unhealthy"`
 		UnhealthReportAt string `json:"unhealthReportAt"`
 
+		Weight  int  `json:"weight"`
+		Drained bool `json:"drained"`
+
 		LatestHeartBeatErrs []string `json:"latestHeartBeatErrs"`
 	}
 
@@ -97,6 +110,8 @@ func (s *Status) MarshalJSON() ([]byte, error) {
 		FailureCounter:   s.failureCounter,
 		Unhealthy:        s.unhealthy,
 		UnhealthReportAt: s.unhealthReportAt.Format(time.RFC3339),
+		Weight:           s.weight,
+		Drained:          s.drained,
 	}
 
 	hbErrors := s.latestHeartBeatErrs.Values()
@@ -127,6 +142,52 @@ func (s *Status) heartbeat(n Node) {
 	}
 }
 
+// initChainId validates chain ID of node on registration, which is rejected if mismatched, or
+// quarantined (ie., regarded as unhealthy) until verified if failed to get the chain ID.
+func (s *Status) initChainId(n Node) error {
+	s.chainIdCheckedAt = time.Now()
+
+	err := validateChainId(s.group, n)
+	if errors.Cause(err) != errChainIdUnverified {
+		return err
+	}
+
+	s.chainIdErr = err
+	s.unhealthy = true
+	s.unhealthReportAt = s.chainIdCheckedAt
+
+	logrus.WithField("status", s).WithError(err).Warn("Node quarantined until chain ID verified")
+
+	return nil
+}
+
+// verifyChainId re-verifies chain ID of node periodically if validation enabled, or on every
+// heartbeat if quarantined until verified.
+func (s *Status) verifyChainId(n Node) {
+	if _, ok := cfg.ChainIdValidation.expectedChainId(s.group); !ok {
+		return
+	}
+
+	quarantined := errors.Cause(s.chainIdErr) == errChainIdUnverified
+	if !quarantined && time.Since(s.chainIdCheckedAt) < cfg.ChainIdValidation.Interval {
+		return
+	}
+
+	s.chainIdCheckedAt = time.Now()
+
+	err := validateChainId(s.group, n)
+	if !quarantined && errors.Cause(err) == errChainIdUnverified {
+		// keep the validation result as it is upon RPC failures
+		return
+	}
+
+	s.chainIdErr = err
+
+	if s.chainIdErr != nil && !quarantined {
+		logrus.WithField("status", s).WithError(s.chainIdErr).Error("Node chain ID validation failed")
+	}
+}
+
 // updateHealth reports health status to monitor.
 func (s *Status) updateHealth(monitor HealthMonitor) {
 	reason := s.checkHealth(monitor.HealthyEpoch())
@@ -161,6 +222,11 @@ func (s *Status) updateHealth(monitor HealthMonitor) {
 
 // checkHealth checks health status with collected node information.
 func (s *Status) checkHealth(targetEpoch uint64) error {
+	// wrong chain
+	if s.chainIdErr != nil {
+		return s.chainIdErr
+	}
+
 	// RPC failures
 	if s.failureCounter >= cfg.Monitor.Unhealth.Failures {
 		return errors.Errorf("RPC failures (%v)", s.failureCounter)
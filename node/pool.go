@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/cespare/xxhash"
 	"github.com/pkg/errors"
 )
 
@@ -16,15 +17,59 @@ type nodePool struct {
 	// node cluster managers by group:
 	// group name => node cluster manager
 	managers map[Group]*Manager
+	// percentage of traffic routed to canary nodes by group:
+	// group name => canary percent
+	canaries map[Group]int
 }
 
 func newNodePool(nf nodeFactory) *nodePool {
 	return &nodePool{
 		nf:       nf,
 		managers: make(map[Group]*Manager),
+		canaries: make(map[Group]int),
 	}
 }
 
+// canaryGroup returns the group to manage canary nodes of specific group, which keeps the
+// space prefix of group name for metrics.
+func canaryGroup(grp Group) Group {
+	return grp + "-canary"
+}
+
+// setCanary sets percentage of traffic routed to the canary nodes of specific group.
+func (p *nodePool) setCanary(grp Group, percent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if percent > 0 {
+		p.canaries[grp] = percent
+	} else {
+		delete(p.canaries, grp)
+	}
+}
+
+// route routes key to node of specific group, in which the canary nodes take a stable subset
+// of keys as per the canary percent if configured.
+func (p *nodePool) route(grp Group, key []byte) string {
+	p.mu.Lock()
+	m, canary := p.managers[grp], p.managers[canaryGroup(grp)]
+	percent := p.canaries[grp]
+	p.mu.Unlock()
+
+	// salted to be independent of the hash ring distribution
+	if canary != nil && percent > 0 && xxhash.Sum64(append([]byte("canary:"), key...))%100 < uint64(percent) {
+		if url := canary.Route(key); len(url) > 0 {
+			return url
+		}
+	}
+
+	if m != nil {
+		return m.Route(key)
+	}
+
+	return ""
+}
+
 // add adds some node(s) into specific pool group
 func (p *nodePool) add(grp Group, urls ...string) error {
 	if len(urls) == 0 {
@@ -81,6 +126,29 @@ func (p *nodePool) del(grp Group, urls ...string) {
 	}
 }
 
+// configure updates node weights and drained nodes by url for specific pool group
+func (p *nodePool) configure(grp Group, weights map[string]int, drained []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.managers[grp]
+	if !ok { // group not existed
+		return
+	}
+
+	nameWeights := make(map[string]int)
+	for url, weight := range weights {
+		nameWeights[rpc.Url2NodeName(url)] = weight
+	}
+
+	nameDrained := make(map[string]bool)
+	for _, url := range drained {
+		nameDrained[rpc.Url2NodeName(url)] = true
+	}
+
+	m.Configure(nameWeights, nameDrained)
+}
+
 // get gets url of (all or with some excluded) nodes by group
 func (p *nodePool) get(grp Group, excluded ...string) (urls []string) {
 	p.mu.Lock()
@@ -124,7 +192,9 @@ func (p *nodePool) status(grp Group, included ...string) (res []Status) {
 	// get all group node status
 	for _, n := range mgr.List() {
 		if len(includeset) == 0 || includeset[n.Name()] {
-			res = append(res, n.Status())
+			status := n.Status()
+			status.weight, status.drained = mgr.RouteOptions(n.Name())
+			res = append(res, status)
 		}
 	}
 
@@ -143,15 +213,6 @@ func (p *nodePool) groups() (res []Group) {
 	return res
 }
 
-// manager returns the node manager for specific group
-func (p *nodePool) manager(group Group) (*Manager, bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	m, ok := p.managers[group]
-	return m, ok
-}
-
 func dedupNodeUrls(urls []string) (dedups []string) {
 	dupset := make(map[string]bool)
 
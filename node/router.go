@@ -30,10 +30,13 @@ const (
 	GroupCfxArchives Group = "cfxarchives"
 
 	// evm space fullnode groups
-	GroupEthHttp   Group = "ethhttp"
-	GroupEthWs     Group = "ethws"
-	GroupEthFilter Group = "ethfilter"
-	GroupEthLogs   Group = "ethlogs"
+	GroupEthHttp     Group = "ethhttp"
+	GroupEthWs       Group = "ethws"
+	GroupEthFilter   Group = "ethfilter"
+	GroupEthLogs     Group = "ethlogs"
+	GroupEthArchives Group = "etharchives"
+	// active sequencer of L2 chain to accept transactions
+	GroupEthSequencer Group = "ethsequencer"
 )
 
 // Space parses space from group name
@@ -49,6 +52,21 @@ func (g Group) String() string {
 	return string(g)
 }
 
+// BuiltinGroups returns names of the builtin node groups of the network space ("cfx" or "eth").
+func BuiltinGroups(space string) []string {
+	groups := []Group{GroupCfxHttp, GroupCfxWs, GroupCfxFilter, GroupCfxLogs, GroupCfxArchives}
+	if space == "eth" {
+		groups = []Group{GroupEthHttp, GroupEthWs, GroupEthFilter, GroupEthLogs, GroupEthArchives, GroupEthSequencer}
+	}
+
+	names := make([]string, 0, len(groups))
+	for _, grp := range groups {
+		names = append(names, string(grp))
+	}
+
+	return names
+}
+
 // Router is used to route RPC requests to multiple full nodes.
 type Router interface {
 	// Route returns the full node URL for specified group and key.
@@ -0,0 +1,248 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+// sequencerConfig configurations of sequencer tracker to route transactions to the active
+// sequencer of L2 chain (eg., Kroma), which fails over automatically once the sequencer role
+// moved to another node.
+type sequencerConfig struct {
+	// switch to turn on/off sequencer tracker
+	Enabled bool
+	// sequencer candidates in order of preference
+	Nodes []sequencerNode
+	// interval to poll the sequencer status of candidates
+	Interval time.Duration `default:"1s"`
+	// timeout to poll the sequencer status of a candidate
+	Timeout time.Duration `default:"3s"`
+	// rollup node admin API to check if sequencer is active
+	ActiveMethod string `default:"admin_sequencerActive"`
+	// rollup node API to query the sync status, eg., `kroma_syncStatus` for Kroma
+	SyncStatusMethod string `default:"optimism_syncStatus"`
+	// active sequencer of which the unsafe L2 head not advanced within the duration is regarded
+	// as stalled, 0 to disable
+	StallTimeout time.Duration `default:"30s"`
+}
+
+// sequencerNode is a sequencer candidate, which consists of the execution client to accept
+// transactions and the rollup node to report the sequencer status.
+type sequencerNode struct {
+	// execution client URL to send transactions
+	Url string
+	// rollup node URL to query the sequencer status
+	RollupUrl string
+}
+
+// sequencerStatus is the sequencer status reported by rollup node.
+type sequencerStatus struct {
+	active     bool
+	unsafeHead uint64
+}
+
+// SequencerTracker polls the sequencer status of candidates to discover the active sequencer, so
+// that transactions are always sent to it rather than being forwarded by other nodes.
+type SequencerTracker struct {
+	conf  sequencerConfig
+	probe func(ctx context.Context, sn sequencerNode) (*sequencerStatus, error)
+
+	heads  map[string]nodeHead // node url => unsafe L2 head, only accessed by poll
+	active atomic.Value        // url of the active sequencer, or empty if not available
+}
+
+func newSequencerTracker(
+	conf sequencerConfig, probe func(ctx context.Context, sn sequencerNode) (*sequencerStatus, error),
+) *SequencerTracker {
+	tracker := &SequencerTracker{
+		conf:  conf,
+		probe: probe,
+		heads: make(map[string]nodeHead),
+	}
+
+	tracker.active.Store("")
+
+	return tracker
+}
+
+// MustStartSequencerTrackerFromViper starts to track the active sequencer in a separate goroutine
+// if enabled, so that transactions are routed to it.
+func (p *EthClientProvider) MustStartSequencerTrackerFromViper() {
+	conf := cfg.Sequencer
+	if !conf.Enabled {
+		return
+	}
+
+	if len(conf.Nodes) == 0 {
+		logrus.Fatal("No sequencer node configured")
+	}
+
+	clients := make(map[string]*rpc.Client)
+	for _, sn := range conf.Nodes {
+		if len(sn.Url) == 0 || len(sn.RollupUrl) == 0 {
+			logrus.WithField("node", sn).Fatal("Invalid sequencer node")
+		}
+
+		client, err := rpc.DialHTTP(sn.RollupUrl)
+		if err != nil {
+			logrus.WithField("rollupUrl", sn.RollupUrl).WithError(err).Fatal("Failed to create rollup node client")
+		}
+
+		clients[sn.RollupUrl] = client
+	}
+
+	tracker := newSequencerTracker(conf, func(ctx context.Context, sn sequencerNode) (*sequencerStatus, error) {
+		client := clients[sn.RollupUrl]
+
+		var status sequencerStatus
+		if err := client.CallContext(ctx, &status.active, conf.ActiveMethod); err != nil {
+			return nil, err
+		}
+
+		if !status.active || conf.StallTimeout <= 0 {
+			return &status, nil
+		}
+
+		var syncStatus struct {
+			UnsafeL2 struct {
+				Number uint64 `json:"number"`
+			} `json:"unsafe_l2"`
+		}
+
+		if err := client.CallContext(ctx, &syncStatus, conf.SyncStatusMethod); err != nil {
+			return nil, err
+		}
+
+		status.unsafeHead = syncStatus.UnsafeL2.Number
+
+		return &status, nil
+	})
+
+	// discover the active sequencer before serving
+	tracker.poll()
+
+	logrus.WithFields(logrus.Fields{
+		"config": conf, "active": tracker.Active(),
+	}).Info("Sequencer tracker started")
+
+	go tracker.run()
+
+	p.sequencer = tracker
+}
+
+// ActiveSequencer returns the URL of the active sequencer, or false if not available.
+func (p *EthClientProvider) ActiveSequencer() (string, bool) {
+	url := p.sequencer.Active()
+	return url, len(url) > 0
+}
+
+// run polls sequencer status periodically, which lives as long as the process.
+func (t *SequencerTracker) run() {
+	ticker := time.NewTicker(t.conf.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.poll()
+	}
+}
+
+// poll requests the sequencer status of all candidates concurrently, and then elects the first
+// active one in order of preference.
+func (t *SequencerTracker) poll() {
+	statuses := make([]*sequencerStatus, len(t.conf.Nodes))
+
+	var wg sync.WaitGroup
+	for i, sn := range t.conf.Nodes {
+		wg.Add(1)
+
+		go func(i int, sn sequencerNode) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), t.conf.Timeout)
+			defer cancel()
+
+			status, err := t.probe(ctx, sn)
+			if err != nil {
+				logrus.WithField("node", rpcutil.Url2NodeName(sn.RollupUrl)).
+					WithError(err).
+					Debug("Sequencer tracker failed to poll node")
+				return
+			}
+
+			statuses[i] = status
+		}(i, sn)
+	}
+
+	wg.Wait()
+
+	t.elect(statuses, time.Now())
+}
+
+// elect elects the active sequencer by the polled statuses, of which candidates failed to respond
+// or stalled are skipped.
+func (t *SequencerTracker) elect(statuses []*sequencerStatus, now time.Time) {
+	var active string
+
+	for i, status := range statuses {
+		if status == nil || !status.active || t.stalled(t.conf.Nodes[i].Url, status.unsafeHead, now) {
+			continue
+		}
+
+		if len(active) == 0 {
+			active = t.conf.Nodes[i].Url
+		}
+	}
+
+	prev := t.active.Load().(string)
+	if prev == active {
+		return
+	}
+
+	t.active.Store(active)
+
+	logger := logrus.WithFields(logrus.Fields{
+		"from": rpcutil.Url2NodeName(prev), "to": rpcutil.Url2NodeName(active),
+	})
+
+	switch {
+	case len(prev) == 0:
+		logger.Info("Active sequencer discovered, transactions routed to the sequencer")
+	case len(active) == 0:
+		logger.Warn("No active sequencer available, transactions routed to normal nodes")
+		metrics.Registry.Nodes.SequencerFailovers("eth").Mark(1)
+	default:
+		logger.Warn("Sequencer role moved, transactions routed to the new active sequencer")
+		metrics.Registry.Nodes.SequencerFailovers("eth").Mark(1)
+	}
+}
+
+// stalled checks if the unsafe L2 head of active sequencer not advanced within the stall timeout.
+func (t *SequencerTracker) stalled(url string, unsafeHead uint64, now time.Time) bool {
+	if t.conf.StallTimeout <= 0 {
+		return false
+	}
+
+	head, ok := t.heads[url]
+	if !ok || unsafeHead > head.number {
+		t.heads[url] = nodeHead{number: unsafeHead, updatedAt: now}
+		return false
+	}
+
+	return now.Sub(head.updatedAt) > t.conf.StallTimeout
+}
+
+// Active returns the URL of the active sequencer, or empty if not available or tracker is nil.
+func (t *SequencerTracker) Active() string {
+	if t == nil {
+		return ""
+	}
+
+	return t.active.Load().(string)
+}
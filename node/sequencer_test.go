@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSequencerTracker(stallTimeout time.Duration) *SequencerTracker {
+	return newSequencerTracker(sequencerConfig{
+		Nodes: []sequencerNode{
+			{Url: "http://seq0", RollupUrl: "http://rollup0"},
+			{Url: "http://seq1", RollupUrl: "http://rollup1"},
+			{Url: "http://seq2", RollupUrl: "http://rollup2"},
+		},
+		Timeout:      time.Second,
+		StallTimeout: stallTimeout,
+	}, nil)
+}
+
+func TestSequencerTrackerPoll(t *testing.T) {
+	var mu sync.Mutex
+	statuses := map[string]*sequencerStatus{
+		"http://rollup1": {active: true},
+		"http://rollup2": {active: true},
+	}
+
+	tracker := newTestSequencerTracker(0)
+	tracker.probe = func(ctx context.Context, sn sequencerNode) (*sequencerStatus, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if status, ok := statuses[sn.RollupUrl]; ok {
+			return status, nil
+		}
+
+		return nil, errors.New("connection refused")
+	}
+
+	// failed to respond or inactive candidates skipped in order of preference
+	tracker.poll()
+	assert.Equal(t, "http://seq1", tracker.Active())
+
+	// fail over once the sequencer role moved
+	mu.Lock()
+	statuses["http://rollup1"] = &sequencerStatus{active: false}
+	mu.Unlock()
+
+	tracker.poll()
+	assert.Equal(t, "http://seq2", tracker.Active())
+
+	// no active sequencer available
+	mu.Lock()
+	delete(statuses, "http://rollup2")
+	mu.Unlock()
+
+	tracker.poll()
+	assert.Empty(t, tracker.Active())
+
+	// nil tracker
+	var disabled *SequencerTracker
+	assert.Empty(t, disabled.Active())
+}
+
+func TestSequencerTrackerElect(t *testing.T) {
+	tracker := newTestSequencerTracker(30 * time.Second)
+	now := time.Now()
+
+	tracker.elect([]*sequencerStatus{nil, {active: true, unsafeHead: 10}, {active: true, unsafeHead: 10}}, now)
+	assert.Equal(t, "http://seq1", tracker.Active())
+
+	// preferred candidate takes over once active
+	tracker.elect([]*sequencerStatus{{active: true, unsafeHead: 10}, {active: true, unsafeHead: 11}, nil}, now)
+	assert.Equal(t, "http://seq0", tracker.Active())
+
+	// stalled sequencer skipped, even though still reported active
+	now = now.Add(31 * time.Second)
+	tracker.elect([]*sequencerStatus{{active: true, unsafeHead: 10}, {active: true, unsafeHead: 12}, nil}, now)
+	assert.Equal(t, "http://seq1", tracker.Active())
+
+	// elected again once unsafe head advanced
+	tracker.elect([]*sequencerStatus{{active: true, unsafeHead: 11}, {active: true, unsafeHead: 12}, nil}, now)
+	assert.Equal(t, "http://seq0", tracker.Active())
+
+	tracker.elect([]*sequencerStatus{nil, nil, {active: false}}, now)
+	assert.Empty(t, tracker.Active())
+}
+
+func TestSequencerTrackerStalled(t *testing.T) {
+	tracker := newTestSequencerTracker(30 * time.Second)
+	now := time.Now()
+
+	assert.False(t, tracker.stalled("http://seq0", 10, now))
+	assert.False(t, tracker.stalled("http://seq0", 10, now.Add(30*time.Second)))
+	assert.True(t, tracker.stalled("http://seq0", 10, now.Add(31*time.Second)))
+
+	// stall timer reset once head advanced
+	assert.False(t, tracker.stalled("http://seq0", 11, now.Add(32*time.Second)))
+	assert.False(t, tracker.stalled("http://seq0", 11, now.Add(62*time.Second)))
+	assert.True(t, tracker.stalled("http://seq0", 11, now.Add(63*time.Second)))
+
+	// tracked per node
+	assert.False(t, tracker.stalled("http://seq1", 1, now.Add(63*time.Second)))
+
+	// stall detection disabled
+	tracker = newTestSequencerTracker(0)
+	assert.False(t, tracker.stalled("http://seq0", 10, now))
+	assert.False(t, tracker.stalled("http://seq0", 10, now.Add(time.Hour)))
+}
@@ -1,22 +1,39 @@
 package node
 
 import (
+	"context"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// interval to check maintenance windows of route groups
+	maintenanceCheckInterval = 15 * time.Second
+
+	ctxKeyOperator = handlers.CtxKey("Infura-Node-Operator")
+)
+
 var (
 	errDbNotAvailableForPersistence = errors.New("db not available for persistence")
 )
 
+// configWatcher watches config changes, eg., made by other replicas.
+type configWatcher interface {
+	OnChange(listener func())
+}
+
 // MustNewServer creates node management RPC server
-func MustNewServer(db *mysql.MysqlStore, nf nodeFactory, grpConf map[Group]UrlConfig) *rpc.Server {
+func MustNewServer(db mysql.NodeRouteGroupStore, nf nodeFactory, grpConf map[Group]UrlConfig) *rpc.Server {
 	npool := newNodePool(nf)
+	handler := &apiHandler{dbs: db, pool: npool}
 
 	if db != nil {
 		// load node route group config from db
@@ -29,6 +46,15 @@ func MustNewServer(db *mysql.MysqlStore, nf nodeFactory, grpConf map[Group]UrlCo
 		for _, grp := range routeGroups {
 			grpConf[Group(grp.Name)] = UrlConfig{Nodes: grp.Nodes}
 		}
+
+		handler.persistedGroups = routeGroups
+
+		// propagate node route group changes from the store in real time
+		if watcher, ok := db.(configWatcher); ok {
+			watcher.OnChange(handler.syncRouteGroups)
+		}
+
+		go handler.scheduleMaintenance()
 	}
 
 	// add group nodes to the pool
@@ -41,32 +67,56 @@ func MustNewServer(db *mysql.MysqlStore, nf nodeFactory, grpConf map[Group]UrlCo
 		}
 	}
 
+	// apply node weights, drained nodes and canary nodes of persisted route groups
+	for name, grp := range handler.persistedGroups {
+		npool.configure(Group(name), grp.Weights, grp.DrainedAt(time.Now()))
+		handler.syncCanary(Group(name), grp)
+	}
+
 	return rpc.MustNewServer("node", map[string]interface{}{
-		"node": &api{h: &apiHandler{dbs: db, pool: npool}},
+		"node": &api{h: handler},
+	}, operatorMiddleware)
+}
+
+// operatorMiddleware injects the operator identity of node management request into context, so
+// that route group changes persisted are audited on behalf of the operator.
+func operatorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKeyOperator, handlers.GetOperator("node", r))
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// operatorFromContext returns the operator identity of node management request.
+func operatorFromContext(ctx context.Context) string {
+	if op, ok := ctx.Value(ctxKeyOperator).(string); ok {
+		return op
+	}
+
+	return mysql.DefaultConfigOperator
+}
+
 // api node management RPC APIs.
 type api struct {
 	h *apiHandler
 }
 
-func (api *api) Add(group Group, url string, saveGrps ...bool) error {
+func (api *api) Add(ctx context.Context, group Group, url string, saveGrps ...bool) error {
 	var saveGrp bool
 	if len(saveGrps) > 0 {
 		saveGrp = saveGrps[0]
 	}
 
-	return api.h.addGroupNode(group, url, saveGrp)
+	return api.h.addGroupNode(operatorFromContext(ctx), group, url, saveGrp)
 }
 
-func (api *api) Remove(group Group, url string, saveGrps ...bool) error {
+func (api *api) Remove(ctx context.Context, group Group, url string, saveGrps ...bool) error {
 	var saveGrp bool
 	if len(saveGrps) > 0 {
 		saveGrp = saveGrps[0]
 	}
 
-	return api.h.delGroupNode(group, url, saveGrp)
+	return api.h.delGroupNode(operatorFromContext(ctx), group, url, saveGrp)
 }
 
 // List returns the URL list of all nodes.
@@ -92,11 +142,7 @@ func (api *api) ListAll() map[Group][]string {
 // Route implements the Router interface. It routes the specified key to any node
 // and return the node URL.
 func (api *api) Route(group Group, key hexutil.Bytes) string {
-	if m, ok := api.h.pool.manager(group); ok {
-		return m.Route(key)
-	}
-
-	return ""
+	return api.h.pool.route(group, key)
 }
 
 // apiHandler rpc handler for node api
@@ -106,10 +152,115 @@ type apiHandler struct {
 	// node pool
 	pool *nodePool
 	// db store to save node route configs
-	dbs *mysql.MysqlStore
+	dbs mysql.NodeRouteGroupStore
+	// node route groups persisted in store
+	persistedGroups map[string]*mysql.NodeRouteGroup
+}
+
+// syncRouteGroups synchronizes node route groups from store to the node pool.
+func (h *apiHandler) syncRouteGroups() {
+	routeGroups, err := h.dbs.LoadNodeRouteGroups()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load node route groups to synchronize")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, grp := range routeGroups {
+		// remove stale nodes at first
+		h.pool.del(Group(name), h.pool.get(Group(name), grp.Nodes...)...)
+
+		if err := h.pool.add(Group(name), grp.Nodes...); err != nil {
+			logrus.WithField("group", grp).WithError(err).Error("Failed to synchronize node route group")
+		}
+
+		h.pool.configure(Group(name), grp.Weights, grp.DrainedAt(time.Now()))
+		h.syncCanary(Group(name), grp)
+	}
+
+	// remove all nodes of the deleted route groups
+	for name := range h.persistedGroups {
+		if _, ok := routeGroups[name]; !ok {
+			h.pool.del(Group(name), h.pool.get(Group(name))...)
+			h.syncCanary(Group(name), nil)
+		}
+	}
+
+	h.persistedGroups = routeGroups
+}
+
+// scheduleMaintenance drains nodes of route groups once maintenance windows start, and then
+// re-enables them once windows end.
+func (h *apiHandler) scheduleMaintenance() {
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+
+	// drained nodes by group, including the nodes under maintenance
+	applied := make(map[string][]string)
+
+	for now := range ticker.C {
+		h.mu.Lock()
+
+		for name, grp := range h.persistedGroups {
+			if len(grp.Maintenance) == 0 {
+				continue
+			}
+
+			drained := grp.DrainedAt(now)
+			if equalStrings(drained, applied[name]) {
+				continue
+			}
+
+			h.pool.configure(Group(name), grp.Weights, drained)
+			applied[name] = drained
+
+			logrus.WithFields(logrus.Fields{
+				"group":   name,
+				"drained": drained,
+			}).Info("Drained nodes changed by maintenance windows of route group")
+		}
+
+		h.mu.Unlock()
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// syncCanary synchronizes canary nodes of route group to the node pool, or removes the canary
+// nodes if route group is nil or has no canary nodes.
+func (h *apiHandler) syncCanary(grp Group, routeGroup *mysql.NodeRouteGroup) {
+	var nodes []string
+	var percent int
+
+	if routeGroup != nil && routeGroup.Canary != nil {
+		nodes, percent = routeGroup.Canary.Nodes, routeGroup.Canary.Percent
+	}
+
+	canaryGrp := canaryGroup(grp)
+	h.pool.del(canaryGrp, h.pool.get(canaryGrp, nodes...)...)
+
+	if err := h.pool.add(canaryGrp, nodes...); err != nil {
+		logrus.WithField("group", grp).WithError(err).Error("Failed to synchronize canary nodes of route group")
+	}
+
+	h.pool.setCanary(grp, percent)
 }
 
-func (h *apiHandler) addGroupNode(grp Group, url string, saveGrp bool) error {
+func (h *apiHandler) addGroupNode(operator string, grp Group, url string, saveGrp bool) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -125,12 +276,9 @@ func (h *apiHandler) addGroupNode(grp Group, url string, saveGrp bool) error {
 		return err
 	}
 
-	routeGroup := &mysql.NodeRouteGroup{
-		Name:  string(grp),
-		Nodes: dedupNodeUrls(h.pool.get(grp)),
-	}
+	routeGroup := h.newRouteGroup(grp, dedupNodeUrls(h.pool.get(grp)))
 
-	if err := h.dbs.StoreNodeRouteGroup(routeGroup); err != nil {
+	if err := h.dbs.StoreNodeRouteGroupBy(operator, routeGroup); err != nil {
 		h.pool.del(grp, url) // revert in-memory update
 		return err
 	}
@@ -138,7 +286,7 @@ func (h *apiHandler) addGroupNode(grp Group, url string, saveGrp bool) error {
 	return nil
 }
 
-func (h *apiHandler) delGroupNode(grp Group, url string, saveGrp bool) (err error) {
+func (h *apiHandler) delGroupNode(operator string, grp Group, url string, saveGrp bool) (err error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -151,15 +299,12 @@ func (h *apiHandler) delGroupNode(grp Group, url string, saveGrp bool) (err erro
 		return errDbNotAvailableForPersistence
 	}
 
-	updateRtGrp := &mysql.NodeRouteGroup{
-		Name:  string(grp),
-		Nodes: dedupNodeUrls(h.pool.get(grp, url)),
-	}
+	updateRtGrp := h.newRouteGroup(grp, dedupNodeUrls(h.pool.get(grp, url)))
 
 	if len(updateRtGrp.Nodes) > 0 { // update node set for the route group
-		err = h.dbs.StoreNodeRouteGroup(updateRtGrp)
+		err = h.dbs.StoreNodeRouteGroupBy(operator, updateRtGrp)
 	} else { // delete node route group since no nodes will be left after delete
-		err = h.dbs.DelNodeRouteGroup(updateRtGrp.Name)
+		err = h.dbs.DelNodeRouteGroupBy(operator, updateRtGrp.Name)
 	}
 
 	if err == nil {
@@ -168,3 +313,50 @@ func (h *apiHandler) delGroupNode(grp Group, url string, saveGrp bool) (err erro
 
 	return err
 }
+
+// newRouteGroup creates route group of the specified nodes to persist, with node weights,
+// drained nodes (along with drain windows), canary nodes, shadow nodes, maintenance windows and
+// in-flight limit inherited from the persisted one.
+func (h *apiHandler) newRouteGroup(grp Group, nodes []string) *mysql.NodeRouteGroup {
+	routeGroup := &mysql.NodeRouteGroup{Name: string(grp), Nodes: nodes}
+
+	persisted, ok := h.persistedGroups[string(grp)]
+	if !ok {
+		return routeGroup
+	}
+
+	routeGroup.Canary = persisted.Canary
+	routeGroup.Shadow = persisted.Shadow
+	routeGroup.MaxInflight = persisted.MaxInflight
+
+	// keep maintenance windows of the remaining nodes only
+	for _, w := range persisted.Maintenance {
+		window := *w
+		window.Nodes = nil
+
+		for _, url := range w.Nodes {
+			if routeGroup.HasNode(url) {
+				window.Nodes = append(window.Nodes, url)
+			}
+		}
+
+		if len(window.Nodes) > 0 {
+			routeGroup.Maintenance = append(routeGroup.Maintenance, &window)
+		}
+	}
+
+	for _, url := range nodes {
+		routeGroup.SetWeight(url, persisted.Weight(url))
+		routeGroup.SetDrained(url, persisted.IsDrained(url))
+
+		if drain, ok := persisted.Drains[url]; ok && persisted.IsDrained(url) {
+			if routeGroup.Drains == nil {
+				routeGroup.Drains = make(map[string]*mysql.NodeDrain)
+			}
+
+			routeGroup.Drains[url] = drain
+		}
+	}
+
+	return routeGroup
+}
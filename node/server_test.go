@@ -0,0 +1,74 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+type serverTestNode struct {
+	Node
+	name, url string
+}
+
+func (n *serverTestNode) Name() string   { return n.name }
+func (n *serverTestNode) Url() string    { return n.url }
+func (n *serverTestNode) String() string { return n.name }
+func (n *serverTestNode) Status() Status { return Status{} }
+func (n *serverTestNode) Close()         {}
+
+// operatorRecorder records the operators of route group changes.
+type operatorRecorder struct {
+	mysql.NodeRouteGroupStore
+	operators []string
+}
+
+func (s *operatorRecorder) StoreNodeRouteGroupBy(operator string, routeGrp *mysql.NodeRouteGroup) error {
+	s.operators = append(s.operators, operator)
+	return nil
+}
+
+func (s *operatorRecorder) DelNodeRouteGroupBy(operator, group string) error {
+	s.operators = append(s.operators, operator)
+	return nil
+}
+
+func TestServerOperator(t *testing.T) {
+	store := &operatorRecorder{}
+	pool := newNodePool(func(group Group, name, url string, hm HealthMonitor) (Node, error) {
+		return &serverTestNode{name: name, url: url}, nil
+	})
+
+	server := rpc.MustNewServer("node", map[string]interface{}{
+		"node": &api{h: &apiHandler{dbs: store, pool: pool}},
+	}, operatorMiddleware)
+
+	handler, _ := server.Handler(rpc.ProtocolHttp)
+
+	call := func(method, operator string) {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":["vip","http://127.0.0.1:8545",true]}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:12345"
+
+		if len(operator) > 0 {
+			req.Header.Set(handlers.OperatorHeader, operator)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		assert.NotContains(t, recorder.Body.String(), `"error"`)
+	}
+
+	call("node_add", "alice")
+	call("node_remove", "")
+
+	assert.Equal(t, []string{"node:alice", "node@10.0.0.1"}, store.operators)
+	assert.Empty(t, pool.get("vip"))
+}
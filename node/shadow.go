@@ -0,0 +1,45 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+)
+
+// shadowRegistry keeps the shadow nodes per route group, which are reloaded along with the other
+// options of route groups.
+type shadowRegistry struct {
+	mu     sync.Mutex
+	groups map[Group][]string // route group => shadow node urls
+}
+
+func newShadowRegistry() *shadowRegistry {
+	return &shadowRegistry{groups: make(map[Group][]string)}
+}
+
+func (r *shadowRegistry) reload(routeGroups map[string]*mysql.NodeRouteGroup) {
+	groups := make(map[Group][]string)
+	for name, grp := range routeGroups {
+		if len(grp.Shadow) > 0 {
+			groups[Group(name)] = grp.Shadow
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.groups = groups
+}
+
+func (r *shadowRegistry) nodes(group Group) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.groups[group]
+}
+
+// ShadowNodes returns urls of the shadow nodes of route group, which are not in live rotation
+// but receive a sampled fraction of the read traffic mirrored.
+func (p *clientProvider) ShadowNodes(group Group) []string {
+	return p.shadows.nodes(group)
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrTaggedClientUnavailable = errors.New("no full node of the required capability available")
+)
+
+// tagRegistry keeps the capability tags of full nodes per route group, which are reloaded along
+// with the other options of route groups.
+type tagRegistry struct {
+	mu     sync.Mutex
+	groups map[Group]*mysql.NodeRouteGroup // route groups with any tagged node
+}
+
+func newTagRegistry() *tagRegistry {
+	return &tagRegistry{groups: make(map[Group]*mysql.NodeRouteGroup)}
+}
+
+func (r *tagRegistry) reload(routeGroups map[string]*mysql.NodeRouteGroup) {
+	groups := make(map[Group]*mysql.NodeRouteGroup)
+	for name, grp := range routeGroups {
+		if len(grp.Tags) > 0 {
+			groups[Group(name)] = grp
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.groups = groups
+}
+
+// candidates returns urls of nodes in group capable of the tag excluding the drained ones, or
+// false if the capability is not tagged in group at all, in which case any node is regarded
+// capable. For archive capability, nodes not tagged as pruned are capable if no archive node
+// tagged.
+func (r *tagRegistry) candidates(group Group, tag string, now time.Time) ([]string, bool) {
+	r.mu.Lock()
+	grp, ok := r.groups[group]
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	urls := grp.TaggedNodes(tag)
+
+	if len(urls) == 0 && tag == mysql.NodeTagArchive && len(grp.TaggedNodes(mysql.NodeTagPruned)) > 0 {
+		for _, url := range grp.Nodes {
+			if !grp.HasTag(url, mysql.NodeTagPruned) {
+				urls = append(urls, url)
+			}
+		}
+	} else if len(urls) == 0 {
+		return nil, false
+	}
+
+	return excludeStrings(urls, grp.DrainedAt(now)), true
+}
+
+func excludeStrings(values, excluded []string) (res []string) {
+	set := make(map[string]bool, len(excluded))
+	for _, v := range excluded {
+		set[v] = true
+	}
+
+	for _, v := range values {
+		if !set[v] {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// getClientByTag gets client of full node capable of the tag in group by route key, which is
+// routed as usual if the capability is not tagged in group.
+func (p *clientProvider) getClientByTag(key string, group Group, tag string) (interface{}, error) {
+	urls, ok := p.tags.candidates(group, tag, time.Now())
+	if !ok {
+		return p.getClient(key, group)
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"key":   key,
+		"group": group,
+		"tag":   tag,
+	})
+
+	if len(urls) == 0 {
+		logger.WithError(ErrTaggedClientUnavailable).Error("Failed to get full node client from provider")
+		return nil, ErrTaggedClientUnavailable
+	}
+
+	// prefer the full node routed as usual if capable
+	if url := p.router.Route(group, []byte(key)); len(url) > 0 {
+		for _, v := range urls {
+			if v == url {
+				return p.getClientByUrl(url, group, logger)
+			}
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return p.getClientByUrl(urls[h.Sum32()%uint32(len(urls))], group, logger)
+}
@@ -100,11 +100,18 @@ func nativeSpaceApis(
 
 // evmSpaceApis returns the collection of built-in RPC APIs for EVM space.
 func evmSpaceApis(clientProvider *node.EthClientProvider, option ...EthAPIOption) ([]API, error) {
-	return []API{
+	eth := mustNewEthAPI(clientProvider, option...)
+
+	apis := []API{
 		{
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   mustNewEthAPI(clientProvider, option...),
+			Service:   eth,
+			Public:    true,
+		}, {
+			Namespace: "gw",
+			Version:   "1.0",
+			Service:   newGwAPI(eth),
 			Public:    true,
 		}, {
 			Namespace: "web3",
@@ -116,6 +123,11 @@ func evmSpaceApis(clientProvider *node.EthClientProvider, option ...EthAPIOption
 			Version:   "1.0",
 			Service:   &netAPI{},
 			Public:    true,
+		}, {
+			Namespace: "txpool",
+			Version:   "1.0",
+			Service:   &ethTxPoolAPI{conf: ethTxPoolConf},
+			Public:    true,
 		}, {
 			Namespace: "trace",
 			Version:   "1.0",
@@ -127,7 +139,18 @@ func evmSpaceApis(clientProvider *node.EthClientProvider, option ...EthAPIOption
 			Service:   &parityAPI{},
 			Public:    false,
 		},
-	}, nil
+	}
+
+	if ethRollupProxy != nil {
+		apis = append(apis, API{
+			Namespace: ethRollupProxy.conf.Namespace,
+			Version:   "1.0",
+			Service:   ethRollupProxy,
+			Public:    true,
+		})
+	}
+
+	return apis, nil
 }
 
 // nativeSpaceBridgeApis adapts evm space RPCs to core space RPCs.
@@ -0,0 +1,376 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/node"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ctxKeyBatchFanout = handlers.CtxKey("Infura-RPC-Batch-Fanout")
+)
+
+// batchFanoutConfig configurations to fan out oversized batch requests.
+type batchFanoutConfig struct {
+	// switch to turn on/off batch fan-out
+	Enabled bool
+	// batch size above which items are spread across multiple full nodes
+	Threshold int `default:"100"`
+	// maximum number of batch items delegated to a single full node at a time
+	MaxItemsPerNode int `default:"50"`
+	// maximum number of batch items per second delegated to a single full node, 0 for unlimited
+	MaxQpsPerNode float64
+	// methods of batch items to fan out, eg., `eth_getBalance`. Note, batch items are forwarded to
+	// full nodes in raw, so gateway logic of the method (eg., store, cache or rerouting to archive
+	// nodes) is bypassed, and only methods plainly delegated to full node should be configured.
+	Methods []string
+}
+
+// batchCallFunc sends batch items to full node as a single batch.
+type batchCallFunc func(ctx context.Context, elems []rpc.BatchElem) error
+
+// fanoutTarget full node to delegate batch items to.
+type fanoutTarget struct {
+	url  string
+	call batchCallFunc
+	// checks if the full node supports the RPC method
+	supports func(method string) bool
+}
+
+// fanoutResponse prefetched response of batch item along with the full node that served it.
+type fanoutResponse struct {
+	node string
+	resp *rpc.JsonRpcMessage
+}
+
+// batchFanout spreads oversized batch across multiple full nodes, with each full node serving no
+// more than the configured ceiling of batch items at a time.
+type batchFanout struct {
+	conf    *batchFanoutConfig
+	methods map[string]bool
+
+	// node name => limiter of batch items per second
+	limiters sync.Map
+
+	// middleware chain to admit batch items ahead of prefetching
+	chain *middlewares.Chain
+
+	// resolves at most n distinct full nodes to fan out batch items, overridable in tests
+	resolve func(ctx context.Context, n int) []*fanoutTarget
+}
+
+func mustNewBatchFanoutFromViper() *batchFanout {
+	var conf batchFanoutConfig
+	viper.MustUnmarshalKey("rpc.batchFanout", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Threshold < 0 || conf.MaxItemsPerNode <= 0 || conf.MaxQpsPerNode < 0 || len(conf.Methods) == 0 {
+		logrus.WithField("config", conf).Fatal("Invalid batch fan-out config")
+	}
+
+	logrus.WithField("config", conf).Info("Batch fan-out RPC middleware enabled")
+
+	return newBatchFanout(&conf)
+}
+
+func newBatchFanout(conf *batchFanoutConfig) *batchFanout {
+	return &batchFanout{
+		conf:    conf,
+		methods: stringSet(conf.Methods),
+		chain:   middlewares.DefaultChain,
+		resolve: resolveFanoutTargets,
+	}
+}
+
+// Batch prefetches the eligible items of oversized batch, which are split into chunks and sent to
+// distinct full nodes concurrently. The prefetched responses are then served by `Call` when batch
+// items are handled as usual by the RPC server, which assembles the responses in the original
+// order. Items of failed chunks fall back to the normal routing.
+//
+// Note, eligible items are admitted (eg., by ACL and rate limit) one by one ahead of prefetching,
+// so that only the admitted items are sent to full nodes, and rejected items are responded with
+// the rejection without hitting any full node.
+func (bf *batchFanout) Batch(next rpc.HandleBatchFunc) rpc.HandleBatchFunc {
+	if bf == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msgs []*rpc.JsonRpcMessage) []*rpc.JsonRpcMessage {
+		if len(msgs) <= bf.conf.Threshold {
+			return next(ctx, msgs)
+		}
+
+		items := bf.eligibleItems(ctx, msgs)
+		if len(items) == 0 {
+			return next(ctx, msgs)
+		}
+
+		numChunks := (len(items) + bf.conf.MaxItemsPerNode - 1) / bf.conf.MaxItemsPerNode
+
+		targets := bf.resolve(ctx, numChunks)
+		if len(targets) < 2 { // nothing to fan out
+			return next(ctx, msgs)
+		}
+
+		ctx, items = bf.chain.Admit(ctx, items)
+		if len(items) == 0 {
+			return next(ctx, msgs)
+		}
+
+		if responses := bf.prefetch(ctx, targets, items); len(responses) > 0 {
+			ctx = context.WithValue(ctx, ctxKeyBatchFanout, responses)
+		}
+
+		return next(ctx, msgs)
+	}
+}
+
+// Call serves the response of batch item prefetched by `Batch` if any.
+func (bf *batchFanout) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if bf == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		responses, ok := ctx.Value(ctxKeyBatchFanout).(map[*rpc.JsonRpcMessage]*fanoutResponse)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		prefetched, ok := responses[msg]
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		handlers.RecordUpstream(ctx, prefetched.node)
+
+		return prefetched.resp
+	}
+}
+
+// eligibleItems returns the batch items to fan out, of which the methods are configured and not
+// routed specially, eg., by method route rules or sticky routing.
+func (bf *batchFanout) eligibleItems(ctx context.Context, msgs []*rpc.JsonRpcMessage) (items []*rpc.JsonRpcMessage) {
+	for _, msg := range msgs {
+		if !bf.methods[msg.Method] || msg.ID == nil || localRpcMethods[msg.Method] {
+			continue
+		}
+
+		if ethRollupProxy.isRollupRpcMethod(msg.Method) || isEthWriteRpcMethod(msg.Method) {
+			continue
+		}
+
+		if _, ok := rawParams(msg.Params); !ok { // named params or malformed
+			continue
+		}
+
+		switch p := ctx.Value(ctxKeyClientProvider).(type) {
+		case *node.EthClientProvider:
+			if _, ruled := p.MethodRouteGroup(msg.Method); ruled {
+				continue
+			}
+
+			grp, _ := fanoutRouteGroup(ctx, p.GetRouteGroup, node.GroupEthHttp)
+			if _, sticky := ethStickyRouter.Route(ctx, msg.Method, grp, p.Available); sticky {
+				continue
+			}
+		case *node.CfxClientProvider:
+			if _, ruled := p.MethodRouteGroup(msg.Method); ruled {
+				continue
+			}
+		default:
+			return nil
+		}
+
+		items = append(items, msg)
+	}
+
+	return items
+}
+
+// prefetch sends chunks of batch items to the full nodes concurrently, with chunks assigned to
+// full nodes in turn, and returns the responses of batch items by message.
+func (bf *batchFanout) prefetch(
+	ctx context.Context, targets []*fanoutTarget, items []*rpc.JsonRpcMessage,
+) map[*rpc.JsonRpcMessage]*fanoutResponse {
+	assignments := make([][][]*rpc.JsonRpcMessage, len(targets))
+
+	for i, chunkIdx := 0, 0; i < len(items); i, chunkIdx = i+bf.conf.MaxItemsPerNode, chunkIdx+1 {
+		end := i + bf.conf.MaxItemsPerNode
+		if end > len(items) {
+			end = len(items)
+		}
+
+		t := chunkIdx % len(targets)
+		assignments[t] = append(assignments[t], items[i:end])
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	responses := make(map[*rpc.JsonRpcMessage]*fanoutResponse, len(items))
+
+	for i := range targets {
+		if len(assignments[i]) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(target *fanoutTarget, chunks [][]*rpc.JsonRpcMessage) {
+			defer wg.Done()
+
+			// chunks of the same full node are sent sequentially to bound the items in flight
+			for _, chunk := range chunks {
+				chunkResponses := bf.send(ctx, target, chunk)
+
+				mu.Lock()
+				for msg, resp := range chunkResponses {
+					responses[msg] = resp
+				}
+				mu.Unlock()
+			}
+		}(targets[i], assignments[i])
+	}
+
+	wg.Wait()
+
+	return responses
+}
+
+// send sends a chunk of batch items to the full node, or returns nil if failed, eg., due to
+// network error or rate limited, so that the batch items fall back to the normal routing.
+func (bf *batchFanout) send(
+	ctx context.Context, target *fanoutTarget, chunk []*rpc.JsonRpcMessage,
+) map[*rpc.JsonRpcMessage]*fanoutResponse {
+	var msgs []*rpc.JsonRpcMessage
+	var elems []rpc.BatchElem
+
+	for _, msg := range chunk {
+		if target.supports != nil && !target.supports(msg.Method) {
+			continue
+		}
+
+		args, _ := rawParams(msg.Params)
+		elems = append(elems, rpc.BatchElem{Method: msg.Method, Args: args, Result: new(json.RawMessage)})
+		msgs = append(msgs, msg)
+	}
+
+	if len(elems) == 0 {
+		return nil
+	}
+
+	nodeName := rpcutil.Url2NodeName(target.url)
+	logger := logrus.WithFields(logrus.Fields{"node": nodeName, "items": len(elems)})
+
+	if err := bf.limiter(nodeName).WaitN(ctx, len(elems)); err != nil {
+		logger.WithError(err).Debug("Batch fan-out rate limited by full node")
+		return nil
+	}
+
+	if err := target.call(ctx, elems); err != nil {
+		logger.WithError(err).Info("Batch fan-out failed to delegate batch items to full node")
+		return nil
+	}
+
+	responses := make(map[*rpc.JsonRpcMessage]*fanoutResponse, len(elems))
+
+	for i, elem := range elems {
+		if elem.Error != nil {
+			responses[msgs[i]] = &fanoutResponse{node: nodeName, resp: msgs[i].ErrorResponse(elem.Error)}
+			continue
+		}
+
+		result := *elem.Result.(*json.RawMessage)
+		if len(result) == 0 {
+			result = json.RawMessage("null")
+		}
+
+		responses[msgs[i]] = &fanoutResponse{
+			node: nodeName,
+			resp: &rpc.JsonRpcMessage{Version: "2.0", ID: msgs[i].ID, Result: result},
+		}
+	}
+
+	return responses
+}
+
+// limiter returns the limiter of batch items per second delegated to the full node.
+func (bf *batchFanout) limiter(nodeName string) *rate.Limiter {
+	if bf.conf.MaxQpsPerNode == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	burst := int(math.Ceil(bf.conf.MaxQpsPerNode))
+	if burst < bf.conf.MaxItemsPerNode { // so that a whole chunk could be admitted
+		burst = bf.conf.MaxItemsPerNode
+	}
+
+	v, _ := bf.limiters.LoadOrStore(nodeName, rate.NewLimiter(rate.Limit(bf.conf.MaxQpsPerNode), burst))
+	return v.(*rate.Limiter)
+}
+
+// fanoutRouteGroup resolves the route group and key of batch items, which is the route group
+// pinned to the caller if any, otherwise the default group routed by remote IP address.
+func fanoutRouteGroup(
+	ctx context.Context, getRouteGroup func(key string) (node.Group, bool), defaultGroup node.Group,
+) (node.Group, string) {
+	if grp, routeKey, ok := routeGroupFromContext(ctx, getRouteGroup); ok {
+		return grp, routeKey
+	}
+
+	ip, _ := handlers.GetIPAddressFromContext(ctx)
+	return defaultGroup, ip
+}
+
+// resolveFanoutTargets resolves at most n distinct full nodes of the client provider in context,
+// which are healthy and neither saturated nor lagging behind.
+func resolveFanoutTargets(ctx context.Context, n int) (targets []*fanoutTarget) {
+	switch p := ctx.Value(ctxKeyClientProvider).(type) {
+	case *node.EthClientProvider:
+		grp, routeKey := fanoutRouteGroup(ctx, p.GetRouteGroup, node.GroupEthHttp)
+
+		for _, client := range p.GetDistinctClients(routeKey, n, grp) {
+			url := client.URL
+			targets = append(targets, &fanoutTarget{
+				url:  url,
+				call: client.Provider().BatchCallContext,
+				supports: func(method string) bool {
+					return p.SupportsMethod(url, method)
+				},
+			})
+		}
+	case *node.CfxClientProvider:
+		grp, routeKey := fanoutRouteGroup(ctx, p.GetRouteGroup, node.GroupCfxHttp)
+
+		for _, client := range p.GetDistinctClients(routeKey, n, grp) {
+			targets = append(targets, &fanoutTarget{
+				url:  client.GetNodeURL(),
+				call: cfxBatchCall(client),
+			})
+		}
+	}
+
+	return targets
+}
+
+// cfxBatchCall adapts core space client, which is bounded by the client request timeout.
+func cfxBatchCall(client sdk.ClientOperator) batchCallFunc {
+	return func(ctx context.Context, elems []rpc.BatchElem) error {
+		return client.BatchCallRPC(elems)
+	}
+}
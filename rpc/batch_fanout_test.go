@@ -0,0 +1,264 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFanoutNode full node that echoes the first param of batch items as result.
+type fakeFanoutNode struct {
+	url    string
+	fail   bool
+	mu     sync.Mutex
+	chunks []int // sizes of batches received
+}
+
+func (n *fakeFanoutNode) target() *fanoutTarget {
+	return &fanoutTarget{url: n.url, call: n.batchCall}
+}
+
+func (n *fakeFanoutNode) batchCall(ctx context.Context, elems []rpc.BatchElem) error {
+	n.mu.Lock()
+	n.chunks = append(n.chunks, len(elems))
+	n.mu.Unlock()
+
+	if n.fail {
+		return errors.New("connection refused")
+	}
+
+	for i := range elems {
+		if elems[i].Method == "eth_call" {
+			elems[i].Error = errors.New("execution reverted")
+			continue
+		}
+
+		var arg string
+		json.Unmarshal(elems[i].Args[0].(json.RawMessage), &arg)
+
+		result, _ := json.Marshal(fmt.Sprintf("%v@%v", arg, n.url))
+		*elems[i].Result.(*json.RawMessage) = result
+	}
+
+	return nil
+}
+
+func newTestBatch(size int, method string) []*rpc.JsonRpcMessage {
+	msgs := make([]*rpc.JsonRpcMessage, size)
+	for i := range msgs {
+		msgs[i] = &rpc.JsonRpcMessage{
+			Version: "2.0",
+			ID:      json.RawMessage(fmt.Sprint(i)),
+			Method:  method,
+			Params:  json.RawMessage(fmt.Sprintf(`["%v"]`, i)),
+		}
+	}
+
+	return msgs
+}
+
+// serveTestBatch handles batch items sequentially through the middleware chain as RPC server
+// does, and items not prefetched are responded by `fallback`.
+func serveTestBatch(
+	bf *batchFanout, ctx context.Context, msgs []*rpc.JsonRpcMessage, fallback string,
+) []*rpc.JsonRpcMessage {
+	call := bf.chain.HandleCallMsg(bf.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Version: "2.0", ID: msg.ID, Result: json.RawMessage(`"` + fallback + `"`)}
+	}))
+
+	batch := bf.Batch(func(ctx context.Context, msgs []*rpc.JsonRpcMessage) []*rpc.JsonRpcMessage {
+		var resps []*rpc.JsonRpcMessage
+		for _, msg := range msgs {
+			resps = append(resps, call(ctx, msg))
+		}
+
+		return resps
+	})
+
+	return batch(ctx, msgs)
+}
+
+func newTestBatchFanout(nodes ...*fakeFanoutNode) (*batchFanout, context.Context) {
+	bf := newBatchFanout(&batchFanoutConfig{
+		Enabled:         true,
+		Threshold:       4,
+		MaxItemsPerNode: 3,
+		Methods:         []string{"eth_getBalance", "eth_call"},
+	})
+	bf.chain = middlewares.NewChain()
+
+	bf.resolve = func(ctx context.Context, n int) (targets []*fanoutTarget) {
+		for i := 0; i < n && i < len(nodes); i++ {
+			targets = append(targets, nodes[i].target())
+		}
+
+		return targets
+	}
+
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+
+	return bf, ctx
+}
+
+func TestBatchFanoutOrdering(t *testing.T) {
+	nodes := []*fakeFanoutNode{{url: "node1"}, {url: "node2"}, {url: "node3"}}
+	bf, ctx := newTestBatchFanout(nodes...)
+
+	msgs := newTestBatch(10, "eth_getBalance")
+	resps := serveTestBatch(bf, ctx, msgs, "fallback")
+
+	assert.Equal(t, 10, len(resps))
+
+	for i, resp := range resps {
+		assert.Equal(t, msgs[i].ID, resp.ID)
+		assert.Nil(t, resp.Error)
+
+		// chunks of 3 items assigned to full nodes in turn
+		expected := fmt.Sprintf(`"%v@node%v"`, i, (i/3)%3+1)
+		assert.JSONEq(t, expected, string(resp.Result))
+	}
+}
+
+func TestBatchFanoutDistribution(t *testing.T) {
+	nodes := []*fakeFanoutNode{{url: "node1"}, {url: "node2"}, {url: "node3"}, {url: "node4"}, {url: "node5"}}
+	bf, ctx := newTestBatchFanout(nodes...)
+
+	serveTestBatch(bf, ctx, newTestBatch(10, "eth_getBalance"), "fallback")
+
+	// 4 chunks delegated to 4 distinct full nodes, none of which exceeds the per node ceiling
+	for i, n := range nodes[:4] {
+		assert.Equal(t, 1, len(n.chunks), "node%v", i+1)
+		assert.LessOrEqual(t, n.chunks[0], 3)
+	}
+
+	assert.Empty(t, nodes[4].chunks)
+
+	// not oversized
+	bf, ctx = newTestBatchFanout(nodes...)
+	resps := serveTestBatch(bf, ctx, newTestBatch(4, "eth_getBalance"), "fallback")
+
+	for _, resp := range resps {
+		assert.JSONEq(t, `"fallback"`, string(resp.Result))
+	}
+
+	// single full node available
+	bf, ctx = newTestBatchFanout(&fakeFanoutNode{url: "node1"})
+	resps = serveTestBatch(bf, ctx, newTestBatch(10, "eth_getBalance"), "fallback")
+
+	for _, resp := range resps {
+		assert.JSONEq(t, `"fallback"`, string(resp.Result))
+	}
+
+	// methods not configured
+	bf, ctx = newTestBatchFanout(nodes...)
+	resps = serveTestBatch(bf, ctx, newTestBatch(10, "eth_getLogs"), "fallback")
+
+	for _, resp := range resps {
+		assert.JSONEq(t, `"fallback"`, string(resp.Result))
+	}
+}
+
+func TestBatchFanoutPartialFailure(t *testing.T) {
+	nodes := []*fakeFanoutNode{{url: "node1"}, {url: "node2", fail: true}}
+	bf, ctx := newTestBatchFanout(nodes...)
+
+	msgs := newTestBatch(8, "eth_getBalance")
+	msgs[0].Method = "eth_call"
+
+	resps := serveTestBatch(bf, ctx, msgs, "fallback")
+	assert.Equal(t, 8, len(resps))
+
+	// RPC error of batch item responded as it is
+	assert.Equal(t, msgs[0].ID, resps[0].ID)
+	assert.NotNil(t, resps[0].Error)
+	assert.Contains(t, resps[0].Error.Message, "execution reverted")
+
+	for i := 1; i < 8; i++ {
+		assert.Equal(t, msgs[i].ID, resps[i].ID)
+
+		if chunk := i / 3; chunk%2 == 0 { // served by node1
+			assert.JSONEq(t, fmt.Sprintf(`"%v@node1"`, i), string(resps[i].Result))
+		} else { // node2 failed, and fall back to the normal routing
+			assert.JSONEq(t, `"fallback"`, string(resps[i].Result))
+		}
+	}
+}
+
+func TestBatchFanoutMaxQpsPerNode(t *testing.T) {
+	nodes := []*fakeFanoutNode{{url: "node1"}, {url: "node2"}}
+	bf, ctx := newTestBatchFanout(nodes...)
+	bf.conf.MaxQpsPerNode = 0.001 // burst of a single chunk
+
+	// never wait for more tokens beyond deadline
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	// the first chunk of each node is admitted by burst
+	assert.NoError(t, bf.limiter("node1").WaitN(context.Background(), 3))
+	assert.Error(t, bf.limiter("node1").WaitN(ctx, 3))
+
+	// items rate limited fall back to the normal routing
+	resps := serveTestBatch(bf, ctx, newTestBatch(12, "eth_getBalance"), "fallback")
+
+	for i, resp := range resps {
+		if chunk := i / 3; chunk == 1 { // the first chunk of node2
+			assert.JSONEq(t, fmt.Sprintf(`"%v@node2"`, i), string(resp.Result))
+		} else {
+			assert.JSONEq(t, `"fallback"`, string(resp.Result))
+		}
+	}
+}
+
+func TestBatchFanoutAdmission(t *testing.T) {
+	nodes := []*fakeFanoutNode{{url: "node1"}, {url: "node2"}}
+	bf, ctx := newTestBatchFanout(nodes...)
+
+	// items of odd IDs rate limited
+	admissions := make(map[string]int)
+	bf.chain.Use(middlewares.Middleware{
+		Name: "qpsRateLimit", Stage: middlewares.StageRateLimit,
+		Call: func(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+			return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+				admissions[string(msg.ID)]++
+
+				var id int
+				json.Unmarshal(msg.ID, &id)
+				if id%2 == 1 {
+					return msg.ErrorResponse(errors.New("rate limited"))
+				}
+
+				return next(ctx, msg)
+			}
+		},
+	})
+
+	msgs := newTestBatch(12, "eth_getBalance")
+	resps := serveTestBatch(bf, ctx, msgs, "fallback")
+	assert.Equal(t, 12, len(resps))
+
+	// only admitted items sent to full nodes
+	assert.Equal(t, []int{3}, nodes[0].chunks)
+	assert.Equal(t, []int{3}, nodes[1].chunks)
+
+	for i, resp := range resps {
+		// admitted only once
+		assert.Equal(t, 1, admissions[string(msgs[i].ID)], i)
+
+		if i%2 == 1 {
+			assert.NotNil(t, resp.Error)
+		} else {
+			assert.Nil(t, resp.Error)
+			assert.Contains(t, string(resp.Result), fmt.Sprintf(`"%v@node`, i))
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/memory"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var errLatestBlockNotFound = errors.New("latest block not found")
+
+// EthCallCacheConfig configurations of `eth_call` result cache.
+type EthCallCacheConfig struct {
+	// switch to turn on/off `eth_call` cache
+	Enabled bool
+	// max number of cached results
+	Size int `default:"10000"`
+	// expiration duration of cached results
+	TTL time.Duration `default:"3s"`
+	// interval to refresh the latest block hash of each full node
+	LatestBlockTTL time.Duration `default:"1s"`
+	// contracts to cache, or all contracts if empty
+	Allowlist []string
+	// contracts never to cache
+	Denylist []string
+}
+
+// EthCallCache caches `eth_call` results keyed by call parameters and the resolved block hash,
+// so that identical calls at the same block are served from memory. Only calls at the latest
+// block or by block hash are cached.
+type EthCallCache struct {
+	results      *util.ExpirableLruCache // call key => result
+	latestHashes *nodeExpiryCaches       // node name => latest block hash
+
+	allowlist map[common.Address]bool
+	denylist  map[common.Address]bool
+}
+
+// MustNewEthCallCacheFromViper creates an instance of EthCallCache from viper, or nil if disabled.
+func MustNewEthCallCacheFromViper() *EthCallCache {
+	var conf EthCallCacheConfig
+	viper.MustUnmarshalKey("ethrpc.callCache", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	for _, addr := range append(conf.Allowlist, conf.Denylist...) {
+		if !common.IsHexAddress(addr) {
+			logrus.WithField("address", addr).Fatal("Invalid contract address for eth_call cache")
+		}
+	}
+
+	logrus.WithField("config", conf).Info("Cache for eth_call enabled")
+
+	cache := NewEthCallCache(conf)
+	memory.RegisterCache("ethCall", cache.results.Bytes)
+
+	return cache
+}
+
+func NewEthCallCache(conf EthCallCacheConfig) *EthCallCache {
+	return &EthCallCache{
+		results:      util.NewSizedExpirableLruCache(conf.Size, conf.TTL, callResultSize),
+		latestHashes: newNodeExpiryCaches(conf.LatestBlockTTL),
+		allowlist:    toAddressSet(conf.Allowlist),
+		denylist:     toAddressSet(conf.Denylist),
+	}
+}
+
+// callResultSize returns the size in bytes of cached call result along with the key.
+func callResultSize(key, value interface{}) int64 {
+	return int64(len(key.(string)) + len(value.(hexutil.Bytes)))
+}
+
+func toAddressSet(addrs []string) map[common.Address]bool {
+	set := make(map[common.Address]bool, len(addrs))
+	for _, addr := range addrs {
+		set[common.HexToAddress(addr)] = true
+	}
+
+	return set
+}
+
+// Cacheable checks if calls to the contract could be cached.
+func (cache *EthCallCache) Cacheable(contract *common.Address) bool {
+	if contract == nil { // contract creation
+		return false
+	}
+
+	if cache.denylist[*contract] {
+		return false
+	}
+
+	return len(cache.allowlist) == 0 || cache.allowlist[*contract]
+}
+
+// Call executes `eth_call` with cache, which delegates to the full node directly if cache is nil.
+func (cache *EthCallCache) Call(
+	client *node.Web3goClient, request web3Types.CallRequest, blockNumOrHash *web3Types.BlockNumberOrHash,
+) (hexutil.Bytes, error) {
+	if cache == nil || !cache.Cacheable(request.To) {
+		return client.Eth.Call(request, blockNumOrHash)
+	}
+
+	key, ok, err := cache.key(client, request, blockNumOrHash)
+	if err != nil || !ok {
+		return client.Eth.Call(request, blockNumOrHash)
+	}
+
+	val, hit := cache.results.Get(key)
+	metrics.Registry.RPC.StoreHit("eth_call", "cache").Mark(hit)
+
+	if hit {
+		return val.(hexutil.Bytes), nil
+	}
+
+	result, err := client.Eth.Call(request, blockNumOrHash)
+	if err == nil { // errors (eg., reverted) are not cached
+		cache.results.Add(key, hexutil.Bytes(result))
+	}
+
+	return result, err
+}
+
+// key returns the cache key of call, or false if the block could not be resolved to hash.
+func (cache *EthCallCache) key(
+	client *node.Web3goClient, request web3Types.CallRequest, blockNumOrHash *web3Types.BlockNumberOrHash,
+) (string, bool, error) {
+	blockHash, ok, err := cache.resolveBlockHash(client, blockNumOrHash)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.Join([]string{
+		blockHash.Hex(), request.To.Hex(), crypto.Keccak256Hash(data).Hex(),
+	}, ":"), true, nil
+}
+
+func (cache *EthCallCache) resolveBlockHash(
+	client *node.Web3goClient, blockNumOrHash *web3Types.BlockNumberOrHash,
+) (common.Hash, bool, error) {
+	if blockNumOrHash != nil {
+		if hash, ok := blockNumOrHash.Hash(); ok {
+			return hash, true, nil
+		}
+
+		if bn, ok := blockNumOrHash.Number(); ok && bn != web3Types.LatestBlockNumber {
+			return common.Hash{}, false, nil
+		}
+	}
+
+	val, err := cache.latestHashes.getOrUpdate(client.NodeName(), func() (interface{}, error) {
+		// decode block hash only rather than the whole block
+		var block *struct {
+			Hash common.Hash `json:"hash"`
+		}
+
+		err := client.CallContext(
+			context.Background(), &block, "eth_getBlockByNumber", web3Types.LatestBlockNumber, false,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if block == nil {
+			return nil, errLatestBlockNotFound
+		}
+
+		return block.Hash, nil
+	})
+
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+
+	return val.(common.Hash), true, nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/openweb3/web3go"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEthCallTestClient(t *testing.T, calls *int32) *node.Web3goClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{} = "0x01"
+		if req.Method == "eth_getBlockByNumber" {
+			result = map[string]interface{}{"hash": common.HexToHash("0xabc")}
+		} else {
+			atomic.AddInt32(calls, 1)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	return &node.Web3goClient{Client: web3go.MustNewClient(server.URL), URL: server.URL}
+}
+
+func TestEthCallCache(t *testing.T) {
+	var calls int32
+	client := newEthCallTestClient(t, &calls)
+
+	contract := common.HexToAddress("0x1")
+	denied := common.HexToAddress("0x2")
+
+	cache := NewEthCallCache(EthCallCacheConfig{
+		Size: 10, TTL: time.Minute, LatestBlockTTL: time.Minute, Denylist: []string{denied.Hex()},
+	})
+
+	latest := web3Types.BlockNumberOrHashWithNumber(web3Types.LatestBlockNumber)
+	request := web3Types.CallRequest{To: &contract, Data: []byte{1}}
+
+	// cached at the latest block
+	for i := 0; i < 3; i++ {
+		result, err := cache.Call(client, request, &latest)
+		assert.NoError(t, err)
+		assert.Equal(t, "0x01", result.String())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// different call data
+	cache.Call(client, web3Types.CallRequest{To: &contract, Data: []byte{2}}, &latest)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// size of 2 cached results, each with key `blockHash:contract:callHash` and 1 byte result
+	assert.Equal(t, int64(2*(66+1+42+1+66+1)), cache.results.Bytes())
+
+	// denied contract
+	cache.Call(client, web3Types.CallRequest{To: &denied}, &latest)
+	cache.Call(client, web3Types.CallRequest{To: &denied}, &latest)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// explicit block number not cached
+	number := web3Types.BlockNumberOrHashWithNumber(1)
+	cache.Call(client, request, &number)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// pass through if disabled
+	var disabled *EthCallCache
+	disabled.Call(client, request, &latest)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/Conflux-Chain/confura/node"
+)
+
+// ethMethodTranslations RPC methods that are translated to the equivalent ones if unsupported
+// by full node.
+var ethMethodTranslations = map[string]string{
+	rpcMethodEthGetBlockReceipts:    rpcMethodParityGetBlockReceipts,
+	rpcMethodParityGetBlockReceipts: rpcMethodEthGetBlockReceipts,
+}
+
+// methodUnsupportedError JSON-RPC error when RPC method unsupported by any full node of the group,
+// which is the same as full node responds.
+type methodUnsupportedError struct {
+	method string
+}
+
+func (e *methodUnsupportedError) Error() string {
+	return fmt.Sprintf("the method %v does not exist/is not available", e.method)
+}
+
+func (e *methodUnsupportedError) ErrorCode() int { return errCodeMethodNotFound }
+
+// capableEthClient returns the full node client if it supports the RPC method (or the translated
+// one), otherwise another connected full node of the same group that supports, rather than
+// forwarding blindly to get method not found.
+func capableEthClient(
+	p *node.EthClientProvider, client *node.Web3goClient, group node.Group, method string,
+) (*node.Web3goClient, error) {
+	supports := func(url string) bool {
+		if p.SupportsMethod(url, method) {
+			return true
+		}
+
+		alt, ok := ethMethodTranslations[method]
+		return ok && p.SupportsMethod(url, alt)
+	}
+
+	if supports(client.URL) {
+		return client, nil
+	}
+
+	for _, peer := range p.GroupClients(group) {
+		if peer.URL != client.URL && supports(peer.URL) {
+			return peer, nil
+		}
+	}
+
+	return nil, &methodUnsupportedError{method: method}
+}
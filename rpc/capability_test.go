@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapableEthClient(t *testing.T) {
+	urls := []string{"http://127.0.0.1:18545", "http://127.0.0.1:28545"}
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: urls,
+	}))
+
+	primary, err := provider.GetClientByURL(urls[0], node.GroupEthHttp)
+	assert.NoError(t, err)
+
+	_, err = provider.GetClientByURL(urls[1], node.GroupEthHttp)
+	assert.NoError(t, err)
+
+	// supported if capabilities unknown
+	client, err := capableEthClient(provider, primary, node.GroupEthHttp, "trace_block")
+	assert.NoError(t, err)
+	assert.Equal(t, urls[0], client.URL)
+
+	// routed to the other full node that supports
+	provider.SetMethodUnsupported(urls[0], "trace_block")
+
+	client, err = capableEthClient(provider, primary, node.GroupEthHttp, "trace_block")
+	assert.NoError(t, err)
+	assert.Equal(t, urls[1], client.URL)
+
+	// unsupported by any full node
+	provider.SetMethodUnsupported(urls[1], "trace_block")
+
+	_, err = capableEthClient(provider, primary, node.GroupEthHttp, "trace_block")
+	assert.Error(t, err)
+
+	// translated to the equivalent method
+	provider.SetMethodUnsupported(urls[0], rpcMethodEthGetBlockReceipts)
+
+	client, err = capableEthClient(provider, primary, node.GroupEthHttp, rpcMethodEthGetBlockReceipts)
+	assert.NoError(t, err)
+	assert.Equal(t, urls[0], client.URL)
+}
@@ -51,6 +51,7 @@ func (api *cfxAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	go func() {
 		defer dSub.unsubscribe()
 		defer counter.Dec(1)
+		defer api.provider.TrackSubscription(psCtx.cfx.GetNodeURL(), psCtx.rpcClient.Close)()
 
 		for {
 			select {
@@ -118,6 +119,7 @@ func (api *cfxAPI) Epochs(ctx context.Context, subEpoch *types.Epoch) (*rpc.Subs
 	go func() {
 		defer dSub.unsubscribe()
 		defer counter.Dec(1)
+		defer api.provider.TrackSubscription(psCtx.cfx.GetNodeURL(), psCtx.rpcClient.Close)()
 
 		for {
 			select {
@@ -179,6 +181,7 @@ func (api *cfxAPI) Logs(ctx context.Context, filter types.LogFilter) (*rpc.Subsc
 	go func() {
 		defer dSub.unsubscribe()
 		defer counter.Dec(1)
+		defer api.provider.TrackSubscription(psCtx.cfx.GetNodeURL(), psCtx.rpcClient.Close)()
 
 		for {
 			select {
@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// route key to get client for chain head, so that the same full node is requested
+	chainHeadRouteKey = "chain_head"
+	// duration to cache the chain head, including failure to fetch it
+	chainHeadCacheTTL = time.Second
+)
+
+// chainHeadCache caches the chain head fetched from full node on demand, which is used to resolve
+// the block tags of `getLogs` filter against limits.
+type chainHeadCache struct {
+	latest func() (uint64, error)
+
+	mu        sync.Mutex
+	number    uint64
+	err       error
+	updatedAt time.Time
+}
+
+// newChainHeadCache creates chain head cache of the client provider, or returns nil if the client
+// provider is not supported.
+func newChainHeadCache(clientProvider interface{}) *chainHeadCache {
+	switch p := clientProvider.(type) {
+	case *node.EthClientProvider:
+		if p == nil {
+			return nil
+		}
+
+		return &chainHeadCache{latest: func() (uint64, error) {
+			w3c, err := p.GetClient(chainHeadRouteKey, node.GroupEthHttp)
+			if err != nil {
+				return 0, err
+			}
+
+			bn, err := w3c.Eth.BlockNumber()
+			if err != nil {
+				return 0, err
+			}
+
+			if bn == nil {
+				return 0, errors.New("invalid block number")
+			}
+
+			return bn.Uint64(), nil
+		}}
+	case *node.CfxClientProvider:
+		if p == nil {
+			return nil
+		}
+
+		return &chainHeadCache{latest: func() (uint64, error) {
+			cfx, err := p.GetClient(chainHeadRouteKey, node.GroupCfxHttp)
+			if err != nil {
+				return 0, err
+			}
+
+			epoch, err := cfx.GetEpochNumber(types.EpochLatestMined)
+			if err != nil {
+				return 0, err
+			}
+
+			if epoch == nil {
+				return 0, errors.New("invalid epoch number")
+			}
+
+			return epoch.ToInt().Uint64(), nil
+		}}
+	default:
+		return nil
+	}
+}
+
+// Head returns the cached chain head, which is fetched again once expired. Returns false if
+// failed to fetch the chain head.
+func (c *chainHeadCache) Head() (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now := time.Now(); now.Sub(c.updatedAt) > chainHeadCacheTTL {
+		c.number, c.err = c.latest()
+		c.updatedAt = now
+
+		if c.err != nil {
+			logrus.WithError(c.err).Debug("Failed to fetch chain head")
+		}
+	}
+
+	return c.number, c.err == nil
+}
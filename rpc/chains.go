@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// evmChainConfig configurations of additional evm chain served by the same gateway.
+type evmChainConfig struct {
+	// chain name, which is also the URL path prefix to serve, eg., `/{name}/{accessToken}`
+	Name string
+	// hostnames to serve besides the URL path prefix
+	Hosts []string
+	// full nodes of group `ethhttp`
+	URLs []string
+	// full nodes of group `ethws`
+	WSURLs []string
+	// full nodes of group `ethlogs`
+	LogNodes []string
+	// full nodes of group `ethfilter`
+	FilterNodes []string
+	// full nodes of group `etharchives`
+	ArchiveNodes []string
+	// custom node route groups, eg., dedicated full nodes of VIP users, which is not a map since
+	// map keys are lowercased by viper, while group names are case sensitive
+	RouteGroups []evmChainRouteGroup
+	// API keys pinned to the custom node route groups, which is not a map since map keys are
+	// case insensitive by viper
+	RouteKeys []evmChainRouteKey
+}
+
+// evmChainRouteGroup custom node route group of additional chain.
+type evmChainRouteGroup struct {
+	Name  string
+	Nodes []string
+}
+
+// evmChainRouteKey API key pinned to the custom node route group of additional chain.
+type evmChainRouteKey struct {
+	Key   string
+	Group string
+}
+
+// evmChainNodeRoutes node routes of additional chain loaded from config, since the node route
+// store is shared by the default chain.
+type evmChainNodeRoutes map[string]string // route key => route group
+
+func newEvmChainNodeRoutes(routeKeys []evmChainRouteKey) evmChainNodeRoutes {
+	routes := make(evmChainNodeRoutes)
+	for _, rk := range routeKeys {
+		routes[rk.Key] = rk.Group
+	}
+
+	return routes
+}
+
+func (routes evmChainNodeRoutes) FindNodeRoute(routeKey string) (*mysql.NodeRoute, error) {
+	grp, ok := routes[routeKey]
+	if !ok {
+		return nil, nil
+	}
+
+	return &mysql.NodeRoute{RouteKey: routeKey, Group: grp}, nil
+}
+
+func (routes evmChainNodeRoutes) LoadNodeRoutes(filter mysql.NodeRouteFilter) (res []*mysql.NodeRoute, err error) {
+	if len(filter.KeySet) == 0 {
+		for key, grp := range routes {
+			res = append(res, &mysql.NodeRoute{RouteKey: key, Group: grp})
+		}
+	}
+
+	for _, key := range filter.KeySet {
+		if grp, ok := routes[key]; ok {
+			res = append(res, &mysql.NodeRoute{RouteKey: key, Group: grp})
+		}
+	}
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+
+	return res, nil
+}
+
+// evmChain is an additional evm chain served by the same gateway, which has its own full node
+// groups, caches, rate limit ceiling and metrics.
+type evmChain struct {
+	name   string
+	hosts  map[string]bool
+	server *rpcutil.Server
+}
+
+func mustLoadEvmChainConfigsFromViper() []evmChainConfig {
+	var conf struct {
+		Chains []evmChainConfig
+	}
+	viper.MustUnmarshalKey("ethrpc", &conf)
+
+	return conf.Chains
+}
+
+// MustLoadEvmChainRouteGroupsFromViper returns names of the custom node route groups of additional
+// evm chains, which allowlists could be pinned to besides the builtin and persisted ones.
+func MustLoadEvmChainRouteGroupsFromViper() (groups []string) {
+	for _, chainConf := range mustLoadEvmChainConfigsFromViper() {
+		for _, grp := range chainConf.RouteGroups {
+			groups = append(groups, grp.Name)
+		}
+	}
+
+	return groups
+}
+
+func mustNewEvmChainsFromViper(
+	registry *rate.Registry, meter *metering.Meter, exposedModules []string,
+) (chains []*evmChain) {
+	names := make(map[string]bool)
+	for _, chainConf := range mustLoadEvmChainConfigsFromViper() {
+		logger := logrus.WithField("chain", chainConf.Name)
+
+		if len(chainConf.Name) == 0 || strings.Contains(chainConf.Name, "/") || names[chainConf.Name] {
+			logger.Fatal("Invalid or duplicate evm chain name")
+		}
+
+		if len(chainConf.URLs) == 0 {
+			logger.Fatal("No full node configured for evm chain")
+		}
+
+		if err := validateEvmChainRoutes(chainConf); err != nil {
+			logger.WithError(err).Fatal("Invalid node route groups of evm chain")
+		}
+
+		names[chainConf.Name] = true
+		chains = append(chains, mustNewEvmChain(chainConf, registry, meter, exposedModules))
+
+		logger.WithField("hosts", chainConf.Hosts).Info("Additional evm chain served")
+	}
+
+	return chains
+}
+
+func mustNewEvmChain(
+	conf evmChainConfig, registry *rate.Registry, meter *metering.Meter, exposedModules []string,
+) *evmChain {
+	group2Urls := map[node.Group][]string{
+		node.GroupEthHttp:     conf.URLs,
+		node.GroupEthWs:       conf.WSURLs,
+		node.GroupEthLogs:     conf.LogNodes,
+		node.GroupEthFilter:   conf.FilterNodes,
+		node.GroupEthArchives: conf.ArchiveNodes,
+	}
+
+	for _, grp := range conf.RouteGroups {
+		group2Urls[node.Group(grp.Name)] = grp.Nodes
+	}
+
+	// requests are pinned to the custom route groups of the chain by API key or allowlist
+	clientProvider := node.NewEthClientProvider(newEvmChainNodeRoutes(conf.RouteKeys), node.NewLocalRouter(group2Urls))
+
+	ctxMiddlewares := []handlers.Middleware{
+		httpMiddleware(registry, meter, clientProvider),
+		evmChainContext(conf.Name, cache.NewEth()),
+	}
+
+	chain := &evmChain{
+		name:  conf.Name,
+		hosts: make(map[string]bool),
+		server: mustNewEvmSpaceServer(
+			evmSpaceRpcServerName+"_"+conf.Name, clientProvider, exposedModules, nil, ctxMiddlewares,
+		),
+	}
+
+	for _, host := range conf.Hosts {
+		chain.hosts[strings.ToLower(host)] = true
+	}
+
+	return chain
+}
+
+// validateEvmChainRoutes validates the custom node route groups and the API keys pinned to them.
+func validateEvmChainRoutes(conf evmChainConfig) error {
+	groups := make(map[string]bool)
+
+	for _, grp := range conf.RouteGroups {
+		switch node.Group(grp.Name) {
+		case node.GroupEthHttp, node.GroupEthWs, node.GroupEthLogs, node.GroupEthFilter, node.GroupEthArchives:
+			return errors.Errorf("route group %v conflicts with builtin node group", grp.Name)
+		}
+
+		if len(grp.Name) == 0 || len(grp.Nodes) == 0 {
+			return errors.Errorf("route group %q without full node", grp.Name)
+		}
+
+		if groups[grp.Name] {
+			return errors.Errorf("duplicate route group %v", grp.Name)
+		}
+
+		groups[grp.Name] = true
+	}
+
+	for _, rk := range conf.RouteKeys {
+		if len(rk.Key) == 0 {
+			return errors.New("empty API key pinned to route group")
+		}
+
+		if !groups[rk.Group] {
+			return errors.Errorf("API key %v pinned to unknown route group %v", rk.Key, rk.Group)
+		}
+	}
+
+	return nil
+}
+
+// evmChainContext injects the chain name and memory cache into context.
+func evmChainContext(name string, ethCache *cache.EthCache) handlers.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), handlers.CtxKeyChain, name)
+			ctx = context.WithValue(ctx, ctxKeyEthCache, ethCache)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// evmChainDispatcher dispatches requests to the additional chain by hostname or URL path prefix,
+// which is trimmed before dispatched, otherwise requests are served by the default chain.
+func evmChainDispatcher(chains []*evmChain) handlers.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			chain, path, ok := matchEvmChain(chains, r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var protocol rpcutil.Protocol = rpcutil.ProtocolHttp
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				protocol = rpcutil.ProtocolWS
+			}
+
+			handler, ok := chain.server.Handler(protocol)
+			if !ok {
+				http.Error(w, "protocol not supported", http.StatusBadRequest)
+				return
+			}
+
+			r = r.Clone(r.Context())
+			r.URL.Path, r.URL.RawPath = path, ""
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchEvmChain matches the chain of request by hostname at first, and then by URL path prefix.
+func matchEvmChain(chains []*evmChain, r *http.Request) (*evmChain, string, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+	for _, chain := range chains {
+		if chain.hosts[host] {
+			return chain, r.URL.Path, true
+		}
+	}
+
+	for _, chain := range chains {
+		prefix := "/" + chain.name
+
+		if r.URL.Path == prefix {
+			return chain, "/", true
+		}
+
+		if strings.HasPrefix(r.URL.Path, prefix+"/") {
+			return chain, strings.TrimPrefix(r.URL.Path, prefix), true
+		}
+	}
+
+	return nil, "", false
+}
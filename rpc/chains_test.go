@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type chainTestAPI struct{}
+
+func (api *chainTestAPI) Ping() string { return "pong" }
+
+func TestEvmChainDispatcher(t *testing.T) {
+	var servedPath, servedChain string
+
+	// records the dispatched request rather than serving JSON-RPC
+	recorder := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			servedPath = r.URL.Path
+			servedChain, _ = handlers.GetChainFromContext(r.Context())
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	chain := &evmChain{
+		name:  "sepolia",
+		hosts: map[string]bool{"sepolia.example.com": true},
+		server: rpcutil.MustNewServer(
+			"test", map[string]interface{}{"test": &chainTestAPI{}},
+			evmChainContext("sepolia", cache.NewEth()), recorder,
+		),
+	}
+
+	handler := evmChainDispatcher([]*evmChain{chain})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servedPath, servedChain = r.URL.Path, ""
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		host, path string
+		chain      string
+		served     string
+	}{
+		{"example.com", "/sepolia/key", "sepolia", "/key"},
+		{"example.com", "/sepolia", "sepolia", "/"},
+		{"sepolia.example.com:443", "/key", "sepolia", "/key"},
+		{"example.com", "/key", "", "/key"},
+		{"example.com", "/sepolia2/key", "", "/sepolia2/key"},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		r.Host = tc.host
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Equal(t, tc.chain, servedChain, tc.path)
+		assert.Equal(t, tc.served, servedPath, tc.path)
+	}
+}
+
+func TestEvmChainRoutes(t *testing.T) {
+	conf := evmChainConfig{
+		URLs:        []string{"http://127.0.0.1:8545"},
+		RouteGroups: []evmChainRouteGroup{{Name: "VIP", Nodes: []string{"http://127.0.0.1:8645"}}},
+		RouteKeys:   []evmChainRouteKey{{Key: "VipKey", Group: "VIP"}},
+	}
+	assert.NoError(t, validateEvmChainRoutes(conf))
+
+	routes := newEvmChainNodeRoutes(conf.RouteKeys)
+	provider := node.NewEthClientProvider(routes, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: conf.URLs,
+		"VIP":             conf.RouteGroups[0].Nodes,
+	}))
+
+	// API key is case sensitive
+	grp, ok := provider.GetRouteGroup("VipKey")
+	assert.True(t, ok)
+	assert.Equal(t, node.Group("VIP"), grp)
+
+	grp, ok = provider.GetRouteGroup("vipkey")
+	assert.True(t, ok)
+	assert.Empty(t, grp)
+
+	client, err := provider.GetClient("vipkey")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://127.0.0.1:8545", client.URL)
+	}
+
+	client, err = provider.GetClient("VipKey", "VIP")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://127.0.0.1:8645", client.URL)
+	}
+
+	loaded, _ := routes.LoadNodeRoutes(mysql.NodeRouteFilter{KeySet: []string{"VipKey", "other"}})
+	assert.Equal(t, []*mysql.NodeRoute{{RouteKey: "VipKey", Group: "VIP"}}, loaded)
+
+	// conflicts with builtin node group
+	logs := evmChainRouteGroup{Name: string(node.GroupEthLogs), Nodes: []string{"http://127.0.0.1:8745"}}
+	invalid := conf
+	invalid.RouteGroups = append([]evmChainRouteGroup{logs}, conf.RouteGroups...)
+	assert.Error(t, validateEvmChainRoutes(invalid))
+
+	// duplicate route group
+	invalid.RouteGroups = append([]evmChainRouteGroup{conf.RouteGroups[0]}, conf.RouteGroups...)
+	assert.Error(t, validateEvmChainRoutes(invalid))
+
+	// pinned to unknown route group, which is case sensitive
+	conf.RouteKeys = append(conf.RouteKeys, evmChainRouteKey{Key: "key", Group: "vip"})
+	assert.Error(t, validateEvmChainRoutes(conf))
+}
+
+func TestEvmChainRouteGroupsFromViper(t *testing.T) {
+	viper.SetConfigType("yaml")
+	assert.NoError(t, viper.ReadConfig(strings.NewReader(`
+ethrpc:
+  chains:
+    - name: kroma-sepolia
+      urls: ["http://127.0.0.1:8545"]
+      routeGroups:
+        - name: VIP
+          nodes: ["http://127.0.0.1:8645"]
+      routeKeys:
+        - key: VipKey
+          group: VIP
+`)))
+	defer viper.Reset()
+
+	chains := mustLoadEvmChainConfigsFromViper()
+	if assert.Len(t, chains, 1) {
+		assert.NoError(t, validateEvmChainRoutes(chains[0]))
+	}
+
+	// group name case preserved
+	assert.Equal(t, []string{"VIP"}, MustLoadEvmChainRouteGroupsFromViper())
+}
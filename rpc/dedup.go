@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// dedupConfig configurations of request deduplication, by which identical concurrent read
+// requests (same method and params) are coalesced into a single upstream call, whose response
+// is fanned out to all waiters.
+type dedupConfig struct {
+	// switch to turn on/off request deduplication
+	Enabled bool
+	// read methods to deduplicate
+	Methods []string
+	// duration to reuse the successful response of completed call, zero means only concurrent
+	// requests are coalesced
+	Window time.Duration
+	// timeout of the shared upstream call, which is detached from the requests coalesced
+	Timeout time.Duration `default:"30s"`
+}
+
+// dedupCall the in-flight or recently completed upstream call shared by identical requests.
+type dedupCall struct {
+	done chan struct{} // closed once responded
+	resp *rpc.JsonRpcMessage
+}
+
+// requestDeduper coalesces identical read requests to cut upstream load on hot data, with the
+// dedup rate metered per method.
+type requestDeduper struct {
+	conf    dedupConfig
+	evm     bool // whether to deduplicate requests of evm space or core space
+	methods map[string]bool
+
+	mu    sync.Mutex
+	calls map[string]*dedupCall // dedup key => shared call
+}
+
+func mustNewRequestDeduperFromViper(key string, evm bool) *requestDeduper {
+	var conf dedupConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if len(conf.Methods) == 0 || conf.Window < 0 || conf.Timeout <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid request deduplication config")
+	}
+
+	logrus.WithField("config", conf).Info("Request deduplication enabled")
+
+	return newRequestDeduper(conf, evm)
+}
+
+func newRequestDeduper(conf dedupConfig, evm bool) *requestDeduper {
+	deduper := &requestDeduper{
+		conf:    conf,
+		evm:     evm,
+		methods: make(map[string]bool),
+		calls:   make(map[string]*dedupCall),
+	}
+
+	for _, method := range conf.Methods {
+		deduper.methods[method] = true
+	}
+
+	return deduper
+}
+
+// Call coalesces identical requests of the configured methods, which passes through if deduper
+// is nil. Note, it must be executed after the full node client injected into context, so that
+// requests routed to different groups (eg., archive nodes) are never coalesced. Streaming
+// requests are never coalesced either, since responses are written to each client directly.
+func (d *requestDeduper) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if d == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if !d.methods[msg.Method] {
+			return next(ctx, msg)
+		}
+
+		if _, ok := responseStreamFromContext(ctx); ok {
+			return next(ctx, msg)
+		}
+
+		if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != d.evm {
+			return next(ctx, msg)
+		}
+
+		group, ok := ctx.Value(ctxKeyClientGroup).(node.Group)
+		if !ok { // not routed to full node, eg., answered by gateway
+			return next(ctx, msg)
+		}
+
+		key, ok := dedupKey(group, msg)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		call, leader := d.join(key)
+		metrics.Registry.RPC.Percentage(msg.Method, "deduped").Mark(!leader)
+
+		if leader {
+			go d.do(ctx, key, call, next, msg)
+		}
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return msg.ErrorResponse(ctx.Err())
+		}
+
+		// error response is never shared, eg., the leader request rejected by full node
+		if !leader && (call.resp == nil || call.resp.Error != nil) {
+			return next(ctx, msg)
+		}
+
+		if call.resp == nil {
+			return nil
+		}
+
+		return copyResponse(call.resp, msg.ID)
+	}
+}
+
+// do requests upstream for the shared call, which is detached from the leader request, so that
+// the waiters are not affected once the leader request cancelled, eg., client disconnected.
+func (d *requestDeduper) do(
+	ctx context.Context, key string, call *dedupCall, next rpc.HandleCallMsgFunc, msg *rpc.JsonRpcMessage,
+) {
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, d.conf.Timeout)
+	defer cancel()
+
+	// rebind the full node client to the detached context
+	if client := ctx.Value(ctxKeyClient); client != nil {
+		ctx = context.WithValue(ctx, ctxKeyClient, bindClient(ctx, client))
+	}
+
+	call.resp = safeCall(ctx, next, msg)
+	close(call.done)
+
+	d.complete(key, call)
+}
+
+// join returns the shared call of the key, and whether the caller is the leader to request
+// upstream, which is the case if no in-flight or reusable call.
+func (d *requestDeduper) join(key string) (*dedupCall, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if call, ok := d.calls[key]; ok {
+		return call, false
+	}
+
+	call := &dedupCall{done: make(chan struct{})}
+	d.calls[key] = call
+
+	return call, true
+}
+
+// complete removes the completed call, which is delayed for the reuse window if succeeded.
+func (d *requestDeduper) complete(key string, call *dedupCall) {
+	forget := func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.calls[key] == call {
+			delete(d.calls, key)
+		}
+	}
+
+	if d.conf.Window > 0 && call.resp != nil && call.resp.Error == nil {
+		time.AfterFunc(d.conf.Window, forget)
+	} else {
+		forget()
+	}
+}
+
+// copyResponse deep copies the shared response with the request ID, since the response might
+// be modified by middlewares of each request, eg., error normalization.
+func copyResponse(resp *rpc.JsonRpcMessage, id json.RawMessage) *rpc.JsonRpcMessage {
+	cp := *resp
+	cp.ID = id
+	cp.Result = append(json.RawMessage(nil), resp.Result...)
+
+	if resp.Error != nil {
+		jerr := *resp.Error
+		cp.Error = &jerr
+	}
+
+	return &cp
+}
+
+// detachedContext keeps the values of parent context, but is never cancelled along with it.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// dedupKey returns the key of request by route group, method and compacted params, or false if
+// params malformed.
+func dedupKey(group node.Group, msg *rpc.JsonRpcMessage) (string, bool) {
+	var params bytes.Buffer
+	if len(msg.Params) > 0 {
+		if err := json.Compact(&params, msg.Params); err != nil {
+			return "", false
+		}
+	}
+
+	return string(group) + "/" + msg.Method + "/" + params.String(), true
+}
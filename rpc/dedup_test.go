@@ -0,0 +1,250 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupKey(t *testing.T) {
+	msg := &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x01", "latest"]`)}
+	key, ok := dedupKey(node.GroupEthHttp, msg)
+	assert.True(t, ok)
+
+	// whitespaces ignored
+	msg.Params = json.RawMessage(`["0x01","latest"]`)
+	compacted, ok := dedupKey(node.GroupEthHttp, msg)
+	assert.True(t, ok)
+	assert.Equal(t, key, compacted)
+
+	// different route group
+	other, _ := dedupKey(node.Group("archive"), msg)
+	assert.NotEqual(t, key, other)
+
+	msg.Params = json.RawMessage(`["0x01",`)
+	_, ok = dedupKey(node.GroupEthHttp, msg)
+	assert.False(t, ok)
+}
+
+func TestRequestDeduper(t *testing.T) {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+
+	deduper := newRequestDeduper(dedupConfig{Methods: []string{"eth_getBalance"}, Timeout: time.Second}, true)
+
+	var calls int32
+	release := make(chan struct{})
+
+	handler := deduper.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(`"0x10"`)}
+	})
+
+	var wg sync.WaitGroup
+	resps := make([]*rpc.JsonRpcMessage, 5)
+
+	for i := range resps {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			id, _ := json.Marshal(i)
+			resps[i] = handler(ctx, &rpc.JsonRpcMessage{
+				ID: id, Method: "eth_getBalance", Params: json.RawMessage(`["0x01","latest"]`),
+			})
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// coalesced into a single upstream call
+	assert.Equal(t, int32(1), calls)
+
+	for i, resp := range resps {
+		id, _ := json.Marshal(i)
+		assert.Equal(t, json.RawMessage(id), resp.ID)
+		assert.Equal(t, json.RawMessage(`"0x10"`), resp.Result)
+	}
+
+	// completed call is not reused without window
+	handler(ctx, &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x01","latest"]`)})
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestRequestDeduperWindow(t *testing.T) {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+
+	deduper := newRequestDeduper(dedupConfig{
+		Methods: []string{"eth_getBalance"}, Window: 50 * time.Millisecond, Timeout: time.Second,
+	}, true)
+
+	var calls int32
+	handler := deduper.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		atomic.AddInt32(&calls, 1)
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x10"`)}
+	})
+
+	msg := &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x01"]`)}
+
+	handler(ctx, msg)
+	handler(ctx, msg)
+	assert.Equal(t, int32(1), calls)
+
+	// expired
+	time.Sleep(100 * time.Millisecond)
+	handler(ctx, msg)
+	assert.Equal(t, int32(2), calls)
+
+	// core space requests pass through
+	handler(context.Background(), msg)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestRequestDeduperConcurrentWaiters(t *testing.T) {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+
+	deduper := newRequestDeduper(dedupConfig{Methods: []string{"eth_getBalance"}, Timeout: time.Second}, true)
+
+	var calls int32
+	release := make(chan struct{})
+
+	handler := deduper.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		// the shared call is never cancelled along with the leader request
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			if ctx.Err() != nil {
+				return msg.ErrorResponse(ctx.Err())
+			}
+
+			return &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(`"0x10"`)}
+		}
+
+		return msg.ErrorResponse(errors.New("not coalesced"))
+	})
+
+	// mutate responses concurrently like middlewares of each request, eg., error normalization
+	mutate := func(resp *rpc.JsonRpcMessage) {
+		resp.Result[0] = ' '
+		resp.Error = nil
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	leaderDone := make(chan *rpc.JsonRpcMessage)
+
+	go func() {
+		leaderDone <- handler(leaderCtx, &rpc.JsonRpcMessage{
+			ID: json.RawMessage("0"), Method: "eth_getBalance", Params: json.RawMessage(`["0x01"]`),
+		})
+	}()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	resps := make([]*rpc.JsonRpcMessage, 20)
+
+	for i := range resps {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			id, _ := json.Marshal(i + 1)
+			resps[i] = handler(ctx, &rpc.JsonRpcMessage{
+				ID: id, Method: "eth_getBalance", Params: json.RawMessage(`["0x01"]`),
+			})
+
+			if resps[i].Error == nil {
+				mutate(resps[i])
+			}
+		}(i)
+	}
+
+	// leader cancelled, eg., client disconnected
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if resp := <-leaderDone; assert.NotNil(t, resp.Error) {
+		assert.Equal(t, context.Canceled.Error(), resp.Error.Message)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	for i, resp := range resps {
+		id, _ := json.Marshal(i + 1)
+		assert.Equal(t, json.RawMessage(id), resp.ID)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, json.RawMessage(` 0x10"`), resp.Result)
+	}
+}
+
+func TestRequestDeduperErrorNotShared(t *testing.T) {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+
+	deduper := newRequestDeduper(dedupConfig{Methods: []string{"eth_getBalance"}, Timeout: time.Second}, true)
+
+	var calls int32
+	release := make(chan struct{})
+
+	handler := deduper.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			return msg.ErrorResponse(errors.New("header not found"))
+		}
+
+		return &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(`"0x10"`)}
+	})
+
+	var wg sync.WaitGroup
+	resps := make([]*rpc.JsonRpcMessage, 5)
+
+	for i := range resps {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if i > 0 { // join after the leader
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			id, _ := json.Marshal(i)
+			resps[i] = handler(ctx, &rpc.JsonRpcMessage{
+				ID: id, Method: "eth_getBalance", Params: json.RawMessage(`["0x01"]`),
+			})
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// the waiters requested upstream on their own
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+	assert.NotNil(t, resps[0].Error)
+
+	for _, resp := range resps[1:] {
+		assert.Nil(t, resp.Error)
+	}
+}
@@ -0,0 +1,313 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util/openrpc"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+const (
+	rpcMethodRpcDiscover = "rpc_discover"
+
+	// method name of service discovery specified by OpenRPC
+	openrpcMethodDiscover = "rpc.discover"
+
+	// HTTP path to serve the OpenRPC document, which could be prefixed with access token
+	openrpcDocumentPath = "openrpc.json"
+
+	// version of the served API namespaces
+	discoveryApiVersion = "1.0"
+)
+
+var (
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	subscriptionType = reflect.TypeOf((*rpc.Subscription)(nil))
+)
+
+// restrictedService is implemented by RPC services with methods that are only available to the
+// callers whose allowlist explicitly allows them.
+type restrictedService interface {
+	restrictedMethods() []string
+}
+
+// discoveredMethod RPC method served by gateway.
+type discoveredMethod struct {
+	namespace  string
+	method     openrpc.Method
+	restricted bool
+}
+
+// discoveryAPI provides the `rpc` namespace for capability discovery, which overrides the
+// built-in `rpc_modules` of RPC server to describe exactly the methods callable by the caller,
+// including the gateway extensions but excluding the ones forbidden by allowlist.
+type discoveryAPI struct {
+	title   string
+	methods []discoveredMethod // sorted by method name
+}
+
+// newDiscoveryAPI creates the discovery API of the RPC services to serve by namespace, which is
+// described along with them.
+func newDiscoveryAPI(title string, services map[string]interface{}) *discoveryAPI {
+	api := &discoveryAPI{title: title}
+
+	for namespace, svc := range services {
+		api.methods = append(api.methods, discoverMethods(namespace, svc)...)
+	}
+
+	api.methods = append(api.methods, discoverMethods(rpc.MetadataApi, api)...)
+
+	sort.Slice(api.methods, func(i, j int) bool {
+		return api.methods[i].method.Name < api.methods[j].method.Name
+	})
+
+	return api
+}
+
+// Modules returns the API namespaces with any method callable by the caller.
+func (api *discoveryAPI) Modules(ctx context.Context) map[string]string {
+	modules := make(map[string]string)
+	for _, m := range api.callableMethods(ctx) {
+		modules[m.namespace] = discoveryApiVersion
+	}
+
+	return modules
+}
+
+// Discover returns the OpenRPC document of methods callable by the caller.
+func (api *discoveryAPI) Discover(ctx context.Context) *openrpc.Document {
+	doc := &openrpc.Document{
+		OpenRPC: openrpc.Version,
+		Info:    openrpc.Info{Title: api.title, Version: gatewayClientVersion()},
+		Methods: []openrpc.Method{},
+	}
+
+	for _, m := range api.callableMethods(ctx) {
+		doc.Methods = append(doc.Methods, m.method)
+	}
+
+	return doc
+}
+
+func (api *discoveryAPI) callableMethods(ctx context.Context) (result []discoveredMethod) {
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok { // no access control
+		for _, m := range api.methods {
+			if !m.restricted {
+				result = append(result, m)
+			}
+		}
+
+		return result
+	}
+
+	al, hasAllowList := registry.AllowList(ctx)
+
+	for _, m := range api.methods {
+		switch {
+		case !hasAllowList:
+			if m.restricted {
+				continue
+			}
+		case m.restricted:
+			if !al.ExplicitlyAllows(m.method.Name) {
+				continue
+			}
+		case !al.AllowsMethod(m.method.Name):
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+// discoverMethods discovers the RPC methods of service by reflection in the same way as RPC
+// server registers, including the subscriptions that are served by `${namespace}_subscribe`.
+func discoverMethods(namespace string, svc interface{}) []discoveredMethod {
+	var restricted []string
+	if rs, ok := svc.(restrictedService); ok {
+		restricted = rs.restrictedMethods()
+	}
+
+	var methods []discoveredMethod
+	var subscriptions []interface{}
+
+	typ := reflect.TypeOf(svc)
+	for i := 0; i < typ.NumMethod(); i++ {
+		fn := typ.Method(i)
+		if len(fn.PkgPath) > 0 || !isCallbackType(fn.Type) {
+			continue
+		}
+
+		name := strings.ToLower(fn.Name[:1]) + fn.Name[1:]
+
+		if isSubscriptionCallback(fn.Type) {
+			subscriptions = append(subscriptions, name)
+			continue
+		}
+
+		method := namespace + "_" + name
+		methods = append(methods, discoveredMethod{
+			namespace:  namespace,
+			method:     describeMethod(method, fn.Type),
+			restricted: isRestrictedRpcMethod(method, restricted),
+		})
+	}
+
+	if len(subscriptions) > 0 {
+		methods = append(methods, discoveredMethod{
+			namespace: namespace,
+			method: openrpc.Method{
+				Name: namespace + "_subscribe",
+				Params: []openrpc.ContentDescriptor{{
+					Name:     "subscription",
+					Required: true,
+					Schema:   map[string]interface{}{"type": "string", "enum": subscriptions},
+				}},
+				Result: &openrpc.ContentDescriptor{Name: "subscriptionId", Schema: openrpc.AnySchema()},
+			},
+		}, discoveredMethod{
+			namespace: namespace,
+			method: openrpc.Method{
+				Name: namespace + "_unsubscribe",
+				Params: []openrpc.ContentDescriptor{{
+					Name:     "subscriptionId",
+					Required: true,
+					Schema:   openrpc.AnySchema(),
+				}},
+				Result: &openrpc.ContentDescriptor{Name: "result", Schema: openrpc.AnySchema()},
+			},
+		})
+	}
+
+	return methods
+}
+
+// isCallbackType checks if the method returns at most one value and/or error as the last one.
+func isCallbackType(fntype reflect.Type) bool {
+	switch fntype.NumOut() {
+	case 0, 1:
+		return true
+	case 2:
+		return fntype.Out(0) != errorType && fntype.Out(1) == errorType
+	default:
+		return false
+	}
+}
+
+func isSubscriptionCallback(fntype reflect.Type) bool {
+	return fntype.NumIn() > 1 && fntype.In(1) == contextType &&
+		fntype.NumOut() == 2 && fntype.Out(0) == subscriptionType
+}
+
+// describeMethod describes the params and result of RPC method by its Go type, where the trailing
+// pointer params are optional.
+func describeMethod(name string, fntype reflect.Type) openrpc.Method {
+	// skip receiver and context
+	firstArg := 1
+	if fntype.NumIn() > firstArg && fntype.In(firstArg) == contextType {
+		firstArg++
+	}
+
+	params := []openrpc.ContentDescriptor{}
+	for i := firstArg; i < fntype.NumIn(); i++ {
+		params = append(params, openrpc.ContentDescriptor{
+			Name:        fmt.Sprintf("arg%v", i-firstArg),
+			Description: fntype.In(i).String(),
+			Schema:      openrpc.AnySchema(),
+		})
+	}
+
+	for i := len(params) - 1; i >= 0; i-- {
+		if fntype.In(i+firstArg).Kind() != reflect.Ptr {
+			for j := 0; j <= i; j++ {
+				params[j].Required = true
+			}
+
+			break
+		}
+	}
+
+	method := openrpc.Method{Name: name, Params: params}
+	if fntype.NumOut() > 0 && fntype.Out(0) != errorType {
+		method.Result = &openrpc.ContentDescriptor{
+			Name:        "result",
+			Description: fntype.Out(0).String(),
+			Schema:      openrpc.AnySchema(),
+		}
+	}
+
+	return method
+}
+
+// discoveryAlias serves `rpc.discover` as specified by OpenRPC, which could not be registered
+// since the method name is not separated by underscore.
+func discoveryAlias(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if msg.Method == openrpcMethodDiscover {
+			msg.Method = rpcMethodRpcDiscover
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// discoveryMiddleware serves the OpenRPC document at `/openrpc.json` or
+// `/${accessToken}/openrpc.json` by translating into `rpc_discover` call.
+func discoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := parseDiscoveryPath(r.URL.Path)
+		if !ok || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resp, rw, err := serveShimCall(next, r, token, rpcMethodRpcDiscover)
+		if err != nil {
+			writeRestError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if resp == nil { // rejected by HTTP middlewares
+			rw.writeTo(w)
+			return
+		}
+
+		if resp.Error != nil {
+			writeRestJson(w, restStatusCode(resp.Error.Code), resp.Error)
+			return
+		}
+
+		writeRestJson(w, http.StatusOK, resp.Result)
+	})
+}
+
+// parseDiscoveryPath parses the access token (optional) from the path of OpenRPC document.
+func parseDiscoveryPath(path string) (token string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(segments) == 1 && segments[0] == openrpcDocumentPath:
+		return "", true
+	case len(segments) == 2 && segments[1] == openrpcDocumentPath:
+		return segments[0], true
+	default:
+		return "", false
+	}
+}
+
+// withDiscoveryAPI serves the discovery API along with the RPC services of server.
+func withDiscoveryAPI(serverName string, services map[string]interface{}) map[string]interface{} {
+	services[rpc.MetadataApi] = newDiscoveryAPI(serverName, services)
+	return services
+}
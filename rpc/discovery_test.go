@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type discoveryTestService struct{}
+
+func (s *discoveryTestService) Version() string { return "1.0" }
+
+func (s *discoveryTestService) Content(ctx context.Context) (json.RawMessage, error) {
+	return nil, nil
+}
+
+func (s *discoveryTestService) Balance(ctx context.Context, addr string, block *uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (s *discoveryTestService) Heads(ctx context.Context) (*rpc.Subscription, error) {
+	return nil, nil
+}
+
+func (s *discoveryTestService) Invalid() (int, int) { return 0, 0 }
+
+func (s *discoveryTestService) restrictedMethods() []string {
+	return []string{"test_content"}
+}
+
+func TestDiscoveryAPI(t *testing.T) {
+	api := newDiscoveryAPI("test", map[string]interface{}{"test": &discoveryTestService{}})
+
+	var names []string
+	for _, m := range api.methods {
+		names = append(names, m.method.Name)
+	}
+
+	assert.Equal(t, []string{
+		"rpc_discover", "rpc_modules",
+		"test_balance", "test_content", "test_subscribe", "test_unsubscribe", "test_version",
+	}, names)
+
+	// restricted methods not callable without allowlist
+	doc := api.Discover(context.Background())
+	assert.Len(t, doc.Methods, 6)
+	assert.Equal(t, map[string]string{"rpc": "1.0", "test": "1.0"}, api.Modules(context.Background()))
+
+	for _, m := range doc.Methods {
+		switch m.Name {
+		case "test_balance":
+			assert.Len(t, m.Params, 2)
+			assert.True(t, m.Params[0].Required)
+			assert.False(t, m.Params[1].Required)
+			assert.Equal(t, "uint64", m.Result.Description)
+		case "test_subscribe":
+			assert.Equal(t, []interface{}{"heads"}, m.Params[0].Schema.(map[string]interface{})["enum"])
+		case "test_version":
+			assert.Empty(t, m.Params)
+		}
+	}
+}
+
+func TestParseDiscoveryPath(t *testing.T) {
+	token, ok := parseDiscoveryPath("/openrpc.json")
+	assert.True(t, ok)
+	assert.Empty(t, token)
+
+	token, ok = parseDiscoveryPath("/abc/openrpc.json")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", token)
+
+	_, ok = parseDiscoveryPath("/abc/v1/openrpc.json")
+	assert.False(t, ok)
+
+	_, ok = parseDiscoveryPath("/abc")
+	assert.False(t, ok)
+}
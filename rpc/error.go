@@ -1,10 +1,18 @@
 package rpc
 
 import (
+	"fmt"
+
 	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/pkg/errors"
 )
 
+const (
+	// JSON-RPC error code when request or response exceeds limits (EIP-1474)
+	errCodeLimitExceeded = -32005
+)
+
 // rpc errors conform to fullnode
 
 var (
@@ -23,6 +31,16 @@ var (
 		"invalid epoch range (from epoch larger than to epoch)",
 	)
 
+	errMethodRestricted = errors.New("method restricted, which must be explicitly allowed by allowlist")
+
+	errArchiveNodeUnavailable = errors.New(
+		"no archive node available to serve state proofs, which are unavailable on pruned full nodes",
+	)
+
+	errHistoricalStateUnavailable = errors.New(
+		"no archive node available to serve historical state, which is pruned on full nodes",
+	)
+
 	ErrInvalidEthLogFilter = errors.Errorf(
 		"Filter must provide one of the following: %v, %v",
 		"(1) a block number range through `fromBlock` and `toBlock`",
@@ -54,3 +72,28 @@ func ErrExceedLogFilterTopicLimit(size int) error {
 		store.MaxLogFilterTopicCount, size,
 	)
 }
+
+// getLogsLimitExceededError error when `eth_getLogs` query exceeds the limits of the rate limit
+// strategy, along with the allowed limits.
+type getLogsLimitExceededError struct {
+	reason string
+	limits *rate.GetLogsLimits
+}
+
+func (e *getLogsLimitExceededError) Error() string {
+	limit := func(v uint64) string {
+		if v == 0 {
+			return "unlimited"
+		}
+
+		return fmt.Sprint(v)
+	}
+
+	return fmt.Sprintf(
+		"%v; allowed limits: max block range %v, max addresses %v, max topics %v, max logs %v",
+		e.reason, limit(e.limits.MaxBlockRange), limit(uint64(e.limits.MaxAddresses)),
+		limit(uint64(e.limits.MaxTopics)), limit(uint64(e.limits.MaxLogs)),
+	)
+}
+
+func (e *getLogsLimitExceededError) ErrorCode() int { return errCodeLimitExceeded }
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"math/big"
 
 	"github.com/Conflux-Chain/confura/node"
@@ -18,7 +19,8 @@ import (
 )
 
 const (
-	rpcMethodEthGetLogs = "eth_getLogs"
+	rpcMethodEthGetLogs  = "eth_getLogs"
+	rpcMethodEthGetProof = "eth_getProof"
 )
 
 var (
@@ -39,6 +41,16 @@ type ethAPI struct {
 
 	provider         *node.EthClientProvider
 	inputBlockMetric metrics.InputBlockMetric
+	callCache        *cache.EthCallCache
+	headTracker      *node.HeadTracker
+	prewarmer        *headPrewarmer
+	negativeCache    *negativeCache
+	pendingNonce     *pendingNonceAggregator
+	simulation       *simulationConfig
+	filterEmulator   *logFilterEmulator
+	privateTx        *privateTxRelay
+	historicalState  *historicalStateRouter
+	pubsub           ethPubsubOption
 
 	// return empty data before eSpace hardfork block number
 	hardforkBlockNumber web3Types.BlockNumber
@@ -64,11 +76,60 @@ func mustNewEthAPI(provider *node.EthClientProvider, option ...EthAPIOption) *et
 		opt = option[0]
 	}
 
-	return &ethAPI{
+	// emulate log filters only if event logs available in store
+	var filterEmulator *logFilterEmulator
+	if opt.LogApiHandler != nil {
+		filterEmulator = mustNewLogFilterEmulatorFromViper("ethrpc.filterEmulation")
+	}
+
+	headTracker := provider.MustNewHeadTrackerFromViper(node.GroupEthHttp)
+
+	api := &ethAPI{
 		EthAPIOption:        opt,
 		provider:            provider,
+		callCache:           cache.MustNewEthCallCacheFromViper(),
+		headTracker:         headTracker,
+		prewarmer:           mustNewHeadPrewarmerFromViper("ethrpc.prewarm", provider, headTracker),
+		pendingNonce:        mustNewPendingNonceAggregatorFromViper("ethrpc.pendingNonce"),
+		simulation:          mustNewSimulationConfigFromViper("ethrpc.simulation"),
+		filterEmulator:      filterEmulator,
+		privateTx:           mustNewPrivateTxRelayFromViper("ethrpc.privateTx", provider),
+		pubsub: ethPubsubOption{
+			replay:    mustNewPubsubReplayConfigFromViper("ethrpc.pubsubReplay"),
+			reconnect: mustNewPubsubReconnectConfigFromViper("ethrpc.pubsubReconnect"),
+			provider:  provider,
+		},
 		hardforkBlockNumber: util.GetEthHardforkBlockNumber(*chainId),
 	}
+
+	api.negativeCache = mustNewNegativeCacheFromViper("ethrpc.negativeCache", api.latestHead)
+	api.historicalState = mustNewHistoricalStateRouterFromViper("ethrpc.historicalState", provider, api.latestHead)
+
+	return api
+}
+
+// latestHead returns the latest head from head tracker or cache pre-warming if enabled, otherwise
+// the cached block number of the full node that serves the request.
+func (api *ethAPI) latestHead(ctx context.Context) (uint64, bool) {
+	if head, ok := api.headTracker.Head(); ok {
+		return head, true
+	}
+
+	if head, ok := api.prewarmer.latest(); ok {
+		return head, true
+	}
+
+	w3c, ok := ctx.Value(ctxKeyClient).(*node.Web3goClient)
+	if !ok {
+		return 0, false
+	}
+
+	bn, err := GetEthCacheFromContext(ctx).GetBlockNumber(w3c)
+	if err != nil {
+		return 0, false
+	}
+
+	return bn.ToInt().Uint64(), true
 }
 
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in
@@ -78,6 +139,10 @@ func (api *ethAPI) GetBlockByHash(
 ) (*web3Types.Block, error) {
 	metrics.Registry.RPC.Percentage("eth_getBlockByHash", "fullTx").Mark(fullTx)
 
+	if api.negativeCache.missing(ctx, "eth_getBlockByHash", blockHash) {
+		return nil, nil
+	}
+
 	logger := logrus.WithFields(logrus.Fields{
 		"blockHash": blockHash.Hex(), "includeTxs": fullTx,
 	})
@@ -95,19 +160,47 @@ func (api *ethAPI) GetBlockByHash(
 
 	logger.Debug("Delegating eth_getBlockByHash rpc request to fullnode")
 
-	return GetEthClientFromContext(ctx).Eth.BlockByHash(blockHash, fullTx)
+	block, err := GetEthClientFromContext(ctx).Eth.BlockByHash(blockHash, fullTx)
+	if err == nil && block == nil {
+		api.negativeCache.markMissing(ctx, "eth_getBlockByHash", blockHash)
+	}
+
+	return block, err
+}
+
+// GetBlockReceipts returns receipts of all transactions in the block, which is translated to
+// `parity_getBlockReceipts` if unsupported by full node.
+func (api *ethAPI) GetBlockReceipts(
+	ctx context.Context, blockNumOrHash *web3Types.BlockNumberOrHash,
+) ([]web3Types.Receipt, error) {
+	if receipts, ok := api.prewarmer.receipts(ctx, blockNumOrHash); ok {
+		return receipts, nil
+	}
+
+	return blockReceipts(ctx, api.provider, GetEthClientFromContext(ctx), rpcMethodEthGetBlockReceipts, blockNumOrHash)
 }
 
 // ChainId returns the chainID value for transaction replay protection.
 func (api *ethAPI) ChainId(ctx context.Context) (*hexutil.Uint64, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetChainId(w3c.Client)
+	return GetEthCacheFromContext(ctx).GetChainId(w3c.Client)
 }
 
 // BlockNumber returns the block number of the chain head.
 func (api *ethAPI) BlockNumber(ctx context.Context) (*hexutil.Big, error) {
+	// answer by consensus among full nodes of the default group
+	if GetClientGroupFromContext(ctx) == node.GroupEthHttp {
+		if head, ok := api.headTracker.Head(); ok {
+			return (*hexutil.Big)(new(big.Int).SetUint64(head)), nil
+		}
+	}
+
+	if head, ok := api.prewarmer.blockNumber(ctx); ok {
+		return head, nil
+	}
+
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetBlockNumber(w3c)
+	return GetEthCacheFromContext(ctx).GetBlockNumber(w3c)
 }
 
 // GetBalance returns the amount of wei for the given address in the state of the
@@ -115,7 +208,11 @@ func (api *ethAPI) BlockNumber(ctx context.Context) (*hexutil.Big, error) {
 func (api *ethAPI) GetBalance(
 	ctx context.Context, address common.Address, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (*hexutil.Big, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_getBalance", blockNumOrHash)
+	if err != nil {
+		return nil, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_getBalance", w3c.Eth)
 	balance, err := w3c.Eth.Balance(address, blockNumOrHash)
 	return (*hexutil.Big)(balance), err
@@ -138,6 +235,16 @@ func (api *ethAPI) GetBlockByNumber(
 	w3c := GetEthClientFromContext(ctx)
 	api.inputBlockMetric.Update1(&blockNum, "eth_getBlockByNumber", w3c.Eth)
 
+	if block, ok := api.prewarmer.block(ctx, blockNum, fullTx); ok {
+		return block, nil
+	}
+
+	// only block number might be missing, eg., future block
+	cacheable := blockNum >= 0
+	if cacheable && api.negativeCache.missing(ctx, "eth_getBlockByNumber", blockNum) {
+		return nil, nil
+	}
+
 	if !store.EthStoreConfig().IsChainBlockDisabled() && !util.IsInterfaceValNil(api.StoreHandler) {
 		block, err := api.StoreHandler.GetBlockByNumber(ctx, &blockNum, fullTx)
 		metrics.Registry.RPC.StoreHit("eth_getBlockByNumber", "store").Mark(err == nil)
@@ -151,7 +258,12 @@ func (api *ethAPI) GetBlockByNumber(
 
 	logger.Debug("Delegating eth_getBlockByNumber rpc request to fullnode")
 
-	return w3c.Eth.BlockByNumber(blockNum, fullTx)
+	block, err := w3c.Eth.BlockByNumber(blockNum, fullTx)
+	if cacheable && err == nil && block == nil {
+		api.negativeCache.markMissing(ctx, "eth_getBlockByNumber", blockNum)
+	}
+
+	return block, err
 }
 
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index.
@@ -180,15 +292,23 @@ func (api *ethAPI) ProtocolVersion(ctx context.Context) (string, error) {
 
 // GasPrice returns the current gas price in wei.
 func (api *ethAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
+	if price, ok := api.prewarmer.gasPrice(ctx); ok {
+		return price, nil
+	}
+
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetGasPrice(w3c.Client)
+	return GetEthCacheFromContext(ctx).GetGasPrice(w3c.Client)
 }
 
 // GetStorageAt returns the value from a storage position at a given address.
 func (api *ethAPI) GetStorageAt(
 	ctx context.Context, address common.Address, location *hexutil.Big, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (common.Hash, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_getStorageAt", blockNumOrHash)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_getStorageAt", w3c.Eth)
 	return w3c.Eth.StorageAt(address, (*big.Int)(location), blockNumOrHash)
 }
@@ -198,18 +318,47 @@ func (api *ethAPI) GetStorageAt(
 func (api *ethAPI) GetCode(
 	ctx context.Context, account common.Address, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (hexutil.Bytes, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_getCode", blockNumOrHash)
+	if err != nil {
+		return nil, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_getCode", w3c.Eth)
 	return w3c.Eth.CodeAt(account, blockNumOrHash)
 }
 
+// GetProof returns the account and storage values of the specified account including the
+// Merkle-proof, which is always served by archive nodes.
+func (api *ethAPI) GetProof(
+	ctx context.Context, account common.Address, storageKeys []string, blockNumOrHash web3Types.BlockNumberOrHash,
+) (json.RawMessage, error) {
+	w3c := GetEthClientFromContext(ctx)
+	api.inputBlockMetric.Update2(&blockNumOrHash, rpcMethodEthGetProof, w3c.Eth)
+
+	var result json.RawMessage
+	err := w3c.Provider().CallContext(ctx, &result, rpcMethodEthGetProof, account, storageKeys, blockNumOrHash)
+
+	return result, err
+}
+
 // GetTransactionCount returns the number of transactions (nonce) sent from the given account.
 // The block number can be nil, in which case the nonce is taken from the latest known block.
 func (api *ethAPI) GetTransactionCount(
 	ctx context.Context, account common.Address, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (*hexutil.Big, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_getTransactionCount", blockNumOrHash)
+	if err != nil {
+		return nil, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_getTransactionCount", w3c.Eth)
+
+	// answer the max pending nonce among full nodes
+	if api.pendingNonce != nil && isPendingBlock(blockNumOrHash) {
+		count, err := api.pendingNonce.TransactionCount(ctx, api.provider, account)
+		return (*hexutil.Big)(count), err
+	}
+
 	count, err := w3c.Eth.TransactionCount(account, blockNumOrHash)
 	return (*hexutil.Big)(count), err
 }
@@ -219,6 +368,11 @@ func (api *ethAPI) GetTransactionCount(
 // If the transaction was a contract creation use the TransactionReceipt method to get the
 // contract address after the transaction has been mined.
 func (api *ethAPI) SendRawTransaction(ctx context.Context, signedTx hexutil.Bytes) (common.Hash, error) {
+	// submit to private relays rather than the public mempool to protect from MEV
+	if allowList, ok := api.privateTx.privateAllowList(ctx); ok {
+		return api.privateTx.Send(allowList, signedTx)
+	}
+
 	w3c := GetEthClientFromContext(ctx)
 
 	if api.TxnHandler != nil {
@@ -238,9 +392,13 @@ func (api *ethAPI) SubmitTransaction(ctx context.Context, signedTx hexutil.Bytes
 func (api *ethAPI) Call(
 	ctx context.Context, request web3Types.CallRequest, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (hexutil.Bytes, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_call", blockNumOrHash)
+	if err != nil {
+		return nil, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_call", w3c.Eth)
-	return w3c.Eth.Call(request, blockNumOrHash)
+	return api.callCache.Call(w3c, request, blockNumOrHash)
 }
 
 // EstimateGas generates and returns an estimate of how much gas is necessary to allow the transaction
@@ -250,7 +408,11 @@ func (api *ethAPI) Call(
 func (api *ethAPI) EstimateGas(
 	ctx context.Context, request web3Types.CallRequest, blockNumOrHash *web3Types.BlockNumberOrHash,
 ) (*hexutil.Big, error) {
-	w3c := GetEthClientFromContext(ctx)
+	w3c, err := api.historicalState.client(ctx, "eth_estimateGas", blockNumOrHash)
+	if err != nil {
+		return nil, err
+	}
+
 	api.inputBlockMetric.Update2(blockNumOrHash, "eth_estimateGas", w3c.Eth)
 	gas, err := w3c.Eth.EstimateGas(request, blockNumOrHash)
 	return (*hexutil.Big)(gas), err
@@ -258,6 +420,10 @@ func (api *ethAPI) EstimateGas(
 
 // TransactionByHash returns the transaction with the given hash.
 func (api *ethAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*web3Types.TransactionDetail, error) {
+	if api.negativeCache.missing(ctx, "eth_getTransactionByHash", hash) {
+		return nil, nil
+	}
+
 	logger := logrus.WithField("txHash", hash.Hex())
 
 	if !store.EthStoreConfig().IsChainTxnDisabled() && !util.IsInterfaceValNil(api.StoreHandler) {
@@ -274,12 +440,22 @@ func (api *ethAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (
 	logger.Debug("Delegating eth_getTransactionByHash rpc request to fullnode")
 
 	w3c := GetEthClientFromContext(ctx)
-	return w3c.Eth.TransactionByHash(hash)
+
+	tx, err := w3c.Eth.TransactionByHash(hash)
+	if err == nil && tx == nil {
+		api.negativeCache.markMissing(ctx, "eth_getTransactionByHash", hash)
+	}
+
+	return tx, err
 }
 
 // TransactionReceipt returns the receipt of a transaction by transaction hash.
 // Note that the receipt is not available for pending transactions.
 func (api *ethAPI) GetTransactionReceipt(ctx context.Context, txHash common.Hash) (*web3Types.Receipt, error) {
+	if api.negativeCache.missing(ctx, "eth_getTransactionReceipt", txHash) {
+		return nil, nil
+	}
+
 	logger := logrus.WithField("txHash", txHash.Hex())
 
 	if !store.EthStoreConfig().IsChainReceiptDisabled() && !util.IsInterfaceValNil(api.StoreHandler) {
@@ -299,6 +475,8 @@ func (api *ethAPI) GetTransactionReceipt(ctx context.Context, txHash common.Hash
 	receipt, err := w3c.Eth.TransactionReceipt(txHash)
 	if err != nil {
 		metrics.Registry.RPC.Percentage("eth_getTransactionReceipt", "notfound").Mark(receipt == nil)
+	} else if receipt == nil {
+		api.negativeCache.markMissing(ctx, "eth_getTransactionReceipt", txHash)
 	}
 
 	return receipt, err
@@ -307,7 +485,14 @@ func (api *ethAPI) GetTransactionReceipt(ctx context.Context, txHash common.Hash
 // GetLogs returns an array of all logs matching a given filter object.
 func (api *ethAPI) GetLogs(ctx context.Context, fq web3Types.FilterQuery) ([]web3Types.Log, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return api.getLogs(ctx, w3c, &fq, rpcMethodEthGetLogs)
+	logs, err := api.getLogs(ctx, w3c, &fq, rpcMethodEthGetLogs)
+
+	// stream logs queried from store, unless already streamed from full node
+	if stream, ok := responseStreamFromContext(ctx); ok && err == nil && !stream.streamed() {
+		return nil, stream.writeArray(ctx, logs)
+	}
+
+	return logs, err
 }
 
 // getLogs helper method to get logs from store or fullnode.
@@ -332,19 +517,49 @@ func (api *ethAPI) getLogs(
 		return ethEmptyLogs, err
 	}
 
+	// limits of rate limit strategy only apply to `eth_getLogs`
+	limits, limited := getLogsLimitsFromContext(ctx)
+	limited = limited && rpcMethod == rpcMethodEthGetLogs
+
+	if limited {
+		if err := validateEthLogFilterLimits(limits, flag, fq); err != nil {
+			return ethEmptyLogs, err
+		}
+	}
+
 	// return empty directly if filter block range before eSpace hardfork
 	if fq.ToBlock != nil && *fq.ToBlock <= api.hardforkBlockNumber {
 		return ethEmptyLogs, nil
 	}
 
+	var logs []web3Types.Log
+	var err error
+
 	if api.LogApiHandler != nil {
-		logs, hitStore, err := api.LogApiHandler.GetLogs(ctx, w3c.Client.Eth, fq, rpcMethod)
+		var hitStore bool
+		logs, hitStore, err = api.LogApiHandler.GetLogs(ctx, w3c.Client.Eth, fq, rpcMethod)
 		metrics.Registry.RPC.StoreHit(rpcMethod, "store").Mark(hitStore)
-		return uniformEthLogs(logs), err
+		logs = uniformEthLogs(logs)
+	} else if stream, ok := responseStreamFromContext(ctx); ok && stream.canProxy(w3c.URL) {
+		// fail over to fullnode if no handler configured, whose logs are streamed to client
+		var check func(count int) error
+		if limited {
+			check = func(count int) error { return validateEthLogsCount(limits, count) }
+		}
+
+		return nil, stream.proxy(ctx, w3c.URL, rpcMethod, check, fq)
+	} else {
+		// fail over to fullnode if no handler configured
+		logs, err = w3c.Eth.Logs(*fq)
+	}
+
+	if err == nil && limited {
+		if err := validateEthLogsLimits(limits, logs); err != nil {
+			return ethEmptyLogs, err
+		}
 	}
 
-	// fail over to fullnode if no handler configured
-	return w3c.Eth.Logs(*fq)
+	return logs, err
 }
 
 // GetBlockTransactionCountByHash returns the total number of transactions in the given block.
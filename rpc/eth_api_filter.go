@@ -51,7 +51,12 @@ func (api *ethAPI) NewFilter(ctx context.Context, fq web3Types.FilterQuery) (*rp
 		return fid, errVirtualFilterProxyErrorOrNil(err)
 	}
 
-	return w3c.Filter.NewLogFilter(&fq)
+	fid, err := w3c.Filter.NewLogFilter(&fq)
+	if err == nil {
+		api.filterEmulator.record(fid, &fq)
+	}
+
+	return fid, err
 }
 
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
@@ -90,6 +95,8 @@ func (api *ethAPI) UninstallFilter(ctx context.Context, fid rpc.ID) (bool, error
 		return ok, errVirtualFilterProxyErrorOrNil(err)
 	}
 
+	api.filterEmulator.remove(fid)
+
 	w3c := GetEthClientFromContext(ctx)
 	return w3c.Filter.UninstallFilter(fid)
 }
@@ -105,6 +112,9 @@ func (api *ethAPI) GetFilterChanges(ctx context.Context, fid rpc.ID) (interface{
 		return res, errVirtualFilterProxyErrorOrNil(err)
 	}
 
+	// keep the emulated log filter alive as long as polled
+	api.filterEmulator.get(fid)
+
 	w3c := GetEthClientFromContext(ctx)
 	return w3c.Filter.GetFilterChanges(fid)
 }
@@ -112,17 +122,22 @@ func (api *ethAPI) GetFilterChanges(ctx context.Context, fid rpc.ID) (interface{
 // GetFilterLogs returns the logs for the filter with the given id.
 // If the filter could not be found an empty array of logs is returned.
 func (api *ethAPI) GetFilterLogs(ctx context.Context, fid rpc.ID) ([]web3Types.Log, error) {
-	if api.VirtualFilterClient == nil {
-		// delegate to full node if no virtual filter client provided
+	var fq *web3Types.FilterQuery
+
+	if api.VirtualFilterClient != nil {
+		var err error
+		if fq, err = api.VirtualFilterClient.GetLogFilter(fid); err != nil {
+			return ethEmptyLogs, errVirtualFilterProxyErrorOrNil(err)
+		}
+	} else if emulated, ok := api.filterEmulator.get(fid); ok {
+		// get logs from store with the emulated log filter criteria
+		fq = emulated
+	} else {
+		// delegate to full node if neither virtual filter client provided nor filter emulated
 		w3c := GetEthClientFromContext(ctx)
 		return w3c.Filter.GetFilterLogs(fid)
 	}
 
-	fq, err := api.VirtualFilterClient.GetLogFilter(fid)
-	if err != nil {
-		return ethEmptyLogs, errVirtualFilterProxyErrorOrNil(err)
-	}
-
 	w3c, err := api.provider.GetClientByIP(ctx, node.GroupEthLogs)
 	if err != nil {
 		return ethEmptyLogs, errors.WithMessage(err, "failed to get client by ip")
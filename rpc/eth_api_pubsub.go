@@ -18,8 +18,9 @@ import (
 // to flooding attack;
 // 2. `newPendingTransactions` and `syncing` are not implemented in the fullnode yet.
 
-// NewHeads send a notification each time a new header (block) is appended to the chain.
-func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+// NewHeads send a notification each time a new header (block) is appended to the chain, which
+// could be resumed from the last delivered block if subscription replay enabled.
+func (api *ethAPI) NewHeads(ctx context.Context, opts *pubsubReplayOptions) (*rpc.Subscription, error) {
 	psCtx, supported, err := api.pubsubCtxFromContext(ctx)
 
 	if !supported {
@@ -35,7 +36,7 @@ func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	rpcSub := psCtx.notifier.CreateSubscription()
 
 	headersCh := make(chan *types.Header, pubsubChannelBufferSize)
-	dClient := getOrNewEthDelegateClient(psCtx.eth)
+	dClient := getOrNewEthDelegateClient(psCtx.eth, api.pubsub)
 
 	dSub, err := dClient.delegateSubscribeNewHeads(rpcSub.ID, headersCh)
 	if err != nil {
@@ -43,6 +44,13 @@ func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 		return &rpc.Subscription{}, errSubscriptionProxyError
 	}
 
+	replayed, err := replayPubsub(dSub, opts, nil)
+	if err != nil {
+		return &rpc.Subscription{}, err
+	}
+
+	deduper := newReplayDeduper(replayed)
+
 	logger := logrus.WithField("rpcSubID", rpcSub.ID)
 
 	nodeName := rpcutil.Url2NodeName(psCtx.eth.URL)
@@ -52,10 +60,19 @@ func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	go func() {
 		defer dSub.unsubscribe()
 		defer counter.Dec(1)
+		defer api.provider.TrackSubscription(psCtx.eth.URL, psCtx.rpcClient.Close)()
+
+		for _, blockHeader := range replayed {
+			psCtx.notifier.Notify(rpcSub.ID, blockHeader)
+		}
 
 		for {
 			select {
 			case blockHeader := <-headersCh:
+				if deduper.replayed(blockHeader) {
+					continue
+				}
+
 				logger.WithField("blockHeader", blockHeader).Debug("Received new block header from pubsub delegate")
 				psCtx.notifier.Notify(rpcSub.ID, blockHeader)
 
@@ -78,8 +95,11 @@ func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	return rpcSub, nil
 }
 
-// Logs creates a subscription that fires for all new log that match the given filter criteria.
-func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Subscription, error) {
+// Logs creates a subscription that fires for all new log that match the given filter criteria,
+// which could be resumed from the last delivered block if subscription replay enabled.
+func (api *ethAPI) Logs(
+	ctx context.Context, filter types.FilterQuery, opts *pubsubReplayOptions,
+) (*rpc.Subscription, error) {
 	metrics.Registry.PubSub.InputLogFilter("eth").Mark(!isEmptyEthLogFilter(filter))
 
 	psCtx, supported, err := api.pubsubCtxFromContext(ctx)
@@ -96,7 +116,7 @@ func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Sub
 	rpcSub := psCtx.notifier.CreateSubscription()
 
 	logsCh := make(chan *types.Log, pubsubChannelBufferSize)
-	dClient := getOrNewEthDelegateClient(psCtx.eth)
+	dClient := getOrNewEthDelegateClient(psCtx.eth, api.pubsub)
 
 	dSub, err := dClient.delegateSubscribeLogs(rpcSub.ID, logsCh, filter)
 	if err != nil {
@@ -104,6 +124,16 @@ func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Sub
 		return &rpc.Subscription{}, errSubscriptionProxyError
 	}
 
+	replayed, err := replayPubsub(dSub, opts, func(item interface{}) bool {
+		log, ok := item.(*types.Log)
+		return ok && matchEthPubSubLogFilter(log, &filter)
+	})
+	if err != nil {
+		return &rpc.Subscription{}, err
+	}
+
+	deduper := newReplayDeduper(replayed)
+
 	logger := logrus.WithField("rpcSubID", rpcSub.ID)
 
 	nodeName := rpcutil.Url2NodeName(psCtx.eth.URL)
@@ -113,10 +143,19 @@ func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Sub
 	go func() {
 		defer dSub.unsubscribe()
 		defer counter.Dec(1)
+		defer api.provider.TrackSubscription(psCtx.eth.URL, psCtx.rpcClient.Close)()
+
+		for _, log := range replayed {
+			psCtx.notifier.Notify(rpcSub.ID, log)
+		}
 
 		for {
 			select {
 			case log := <-logsCh:
+				if deduper.replayed(log) {
+					continue
+				}
+
 				logger.WithField("log", log).Debug("Received new log from pubsub delegate")
 				psCtx.notifier.Notify(rpcSub.ID, log)
 
@@ -139,6 +178,33 @@ func (api *ethAPI) Logs(ctx context.Context, filter types.FilterQuery) (*rpc.Sub
 	return rpcSub, nil
 }
 
+// replayPubsub returns the buffered notifications matching the filter to resume subscription from
+// the last delivered block, or unsubscribes the delegate subscription if failed.
+func replayPubsub(
+	dSub *delegateSubscription, opts *pubsubReplayOptions, match func(item interface{}) bool,
+) ([]interface{}, error) {
+	if opts == nil || opts.LastBlock == nil {
+		return nil, nil
+	}
+
+	items, err := dSub.dCtx.replay.since(uint64(*opts.LastBlock))
+	if err != nil {
+		dSub.unsubscribe()
+		return nil, err
+	}
+
+	var replayed []interface{}
+	for _, item := range items {
+		if match == nil || match(item) {
+			replayed = append(replayed, item)
+		}
+	}
+
+	metrics.Registry.PubSub.Replayed("eth").Mark(int64(len(replayed)))
+
+	return replayed, nil
+}
+
 type epubsubContext struct {
 	notifier  *rpc.Notifier
 	rpcClient *rpc.Client
@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+)
+
+const (
+	rpcMethodEthSimulateV1 = "eth_simulateV1"
+	rpcMethodEthCallMany   = "eth_callMany"
+)
+
+// simulationConfig configurations of block-level simulation methods, i.e., `eth_simulateV1` and
+// erigon's `eth_callMany`.
+type simulationConfig struct {
+	// max number of blocks (or bundles) simulated in a request
+	MaxBlocks int `default:"16"`
+	// max number of calls simulated in a request
+	MaxCalls int `default:"100"`
+	// methods only available to callers whose allowlist explicitly allows them
+	Restricted []string `default:"[eth_simulateV1,eth_callMany]"`
+}
+
+func mustNewSimulationConfigFromViper(key string) *simulationConfig {
+	var conf simulationConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	return &conf
+}
+
+// simulatedBlocks is the common structure of simulation params, which is only decoded to count
+// the simulated blocks and calls, and passed through to full node as it is.
+type simulatedBlocks []struct {
+	Calls        []json.RawMessage `json:"calls"`        // eth_simulateV1
+	Transactions []json.RawMessage `json:"transactions"` // eth_callMany
+}
+
+// validate checks the simulated blocks and calls against limits.
+func (conf *simulationConfig) validate(blocks simulatedBlocks) error {
+	if len(blocks) > conf.MaxBlocks {
+		return errors.Errorf("too many blocks to simulate, max %v allowed", conf.MaxBlocks)
+	}
+
+	var calls int
+	for _, b := range blocks {
+		calls += len(b.Calls) + len(b.Transactions)
+	}
+
+	if calls > conf.MaxCalls {
+		return errors.Errorf("too many calls to simulate, max %v allowed", conf.MaxCalls)
+	}
+
+	return nil
+}
+
+// SimulateV1 simulates calls of multiple blocks on top of the specified block.
+func (api *ethAPI) SimulateV1(
+	ctx context.Context, payload json.RawMessage, blockNumOrHash *json.RawMessage,
+) (json.RawMessage, error) {
+	var opts struct {
+		BlockStateCalls simulatedBlocks `json:"blockStateCalls"`
+	}
+
+	if err := json.Unmarshal(payload, &opts); err != nil {
+		return nil, errors.WithMessage(err, "invalid simulation payload")
+	}
+
+	args := []interface{}{payload}
+	if blockNumOrHash != nil {
+		args = append(args, *blockNumOrHash)
+	}
+
+	return api.simulate(ctx, rpcMethodEthSimulateV1, opts.BlockStateCalls, args...)
+}
+
+// CallMany simulates bundles of transactions on top of the specified simulation context.
+func (api *ethAPI) CallMany(
+	ctx context.Context, bundles json.RawMessage, simulationContext json.RawMessage,
+	stateOverride *json.RawMessage, timeout *json.RawMessage,
+) (json.RawMessage, error) {
+	var blocks simulatedBlocks
+	if err := json.Unmarshal(bundles, &blocks); err != nil {
+		return nil, errors.WithMessage(err, "invalid simulation bundles")
+	}
+
+	args := []interface{}{bundles, simulationContext}
+	if stateOverride != nil || timeout != nil {
+		args = append(args, stateOverride)
+	}
+
+	if timeout != nil {
+		args = append(args, *timeout)
+	}
+
+	return api.simulate(ctx, rpcMethodEthCallMany, blocks, args...)
+}
+
+func (api *ethAPI) restrictedMethods() []string {
+	return api.simulation.Restricted
+}
+
+func (api *ethAPI) simulate(
+	ctx context.Context, method string, blocks simulatedBlocks, args ...interface{},
+) (json.RawMessage, error) {
+	if isRestrictedRpcMethod(method, api.simulation.Restricted) && !isExplicitlyAllowed(ctx, method) {
+		return nil, errMethodRestricted
+	}
+
+	if err := api.simulation.validate(blocks); err != nil {
+		return nil, err
+	}
+
+	var result json.RawMessage
+	err := GetEthClientFromContext(ctx).Provider().CallContext(ctx, &result, method, args...)
+
+	return result, err
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEthAPISimulateLimits(t *testing.T) {
+	api := &ethAPI{simulation: &simulationConfig{
+		MaxBlocks:  2,
+		MaxCalls:   3,
+		Restricted: []string{rpcMethodEthSimulateV1, rpcMethodEthCallMany},
+	}}
+
+	payload := json.RawMessage(`{"blockStateCalls": [{"calls": [{}, {}]}, {"calls": [{}]}]}`)
+
+	// restricted without allowlist
+	_, err := api.SimulateV1(context.Background(), payload, nil)
+	assert.Equal(t, errMethodRestricted, err)
+
+	api.simulation.Restricted = nil
+
+	_, err = api.SimulateV1(context.Background(), json.RawMessage(`{"blockStateCalls": [{}, {}, {}]}`), nil)
+	assert.EqualError(t, err, "too many blocks to simulate, max 2 allowed")
+
+	bundles := json.RawMessage(`[{"transactions": [{}, {}]}, {"transactions": [{}, {}]}]`)
+	_, err = api.CallMany(context.Background(), bundles, json.RawMessage(`{}`), nil, nil)
+	assert.EqualError(t, err, "too many calls to simulate, max 3 allowed")
+
+	_, err = api.CallMany(context.Background(), json.RawMessage(`{}`), json.RawMessage(`{}`), nil, nil)
+	assert.Error(t, err)
+
+	assert.NoError(t, api.simulation.validate(simulatedBlocks{{Calls: make([]json.RawMessage, 3)}}))
+}
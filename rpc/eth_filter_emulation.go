@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	web3Types "github.com/openweb3/web3go/types"
+)
+
+// filterEmulationConfig configurations of log filter emulation.
+type filterEmulationConfig struct {
+	// switch to turn on/off log filter emulation
+	Enabled bool `default:"true"`
+	// max number of log filters to emulate
+	Size int `default:"10000"`
+	// expiration of log filter since last polled, which is the same as full node by default
+	TTL time.Duration `default:"5m"`
+}
+
+// logFilterEmulator records criteria of log filters installed on full nodes, so that
+// `eth_getFilterLogs` could be served from the synced log store along with the unfinalized
+// tail from full node, the same as `eth_getLogs`.
+type logFilterEmulator struct {
+	filters *util.ExpirableLruCache // filter ID => filter query
+}
+
+func mustNewLogFilterEmulatorFromViper(key string) *logFilterEmulator {
+	var conf filterEmulationConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	return newLogFilterEmulator(conf)
+}
+
+func newLogFilterEmulator(conf filterEmulationConfig) *logFilterEmulator {
+	return &logFilterEmulator{filters: util.NewExpirableLruCache(conf.Size, conf.TTL)}
+}
+
+// record records criteria of the installed log filter, which is nil safe.
+func (e *logFilterEmulator) record(fid *rpc.ID, fq *web3Types.FilterQuery) {
+	if e != nil && fid != nil {
+		e.filters.Add(*fid, fq)
+	}
+}
+
+// get returns a copy of the log filter criteria, of which the expiration is refreshed.
+func (e *logFilterEmulator) get(fid rpc.ID) (*web3Types.FilterQuery, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	val, ok := e.filters.Get(fid)
+	if !ok {
+		return nil, false
+	}
+
+	e.filters.Add(fid, val)
+
+	fq := *val.(*web3Types.FilterQuery)
+	return &fq, true
+}
+
+// remove removes the uninstalled log filter, which is nil safe.
+func (e *logFilterEmulator) remove(fid rpc.ID) {
+	if e != nil {
+		e.filters.Remove(fid)
+	}
+}
@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogFilterEmulator(t *testing.T) {
+	emulator := newLogFilterEmulator(filterEmulationConfig{Size: 10, TTL: time.Minute})
+
+	fid, fromBlock := rpc.ID("0x1"), web3Types.BlockNumber(100)
+	emulator.record(&fid, &web3Types.FilterQuery{FromBlock: &fromBlock})
+
+	fq, ok := emulator.get(fid)
+	assert.True(t, ok)
+	assert.Equal(t, fromBlock, *fq.FromBlock)
+
+	// criteria is not affected by log filter normalization
+	latest := web3Types.LatestBlockNumber
+	fq.FromBlock = &latest
+
+	fq, _ = emulator.get(fid)
+	assert.Equal(t, fromBlock, *fq.FromBlock)
+
+	emulator.remove(fid)
+	_, ok = emulator.get(fid)
+	assert.False(t, ok)
+
+	// nil safe if disabled
+	var disabled *logFilterEmulator
+	disabled.record(&fid, fq)
+	_, ok = disabled.get(fid)
+	assert.False(t, ok)
+}
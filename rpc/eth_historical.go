@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cache size of block hash => block number to tell historical state requests by block hash
+	historicalBlockHashCacheSize = 10000
+)
+
+// historicalStateConfig configurations to route historical state requests (eg., `eth_call` at an
+// old block) to the full nodes tagged as archive in node route groups, which take effect only if
+// nodes of the group are tagged.
+type historicalStateConfig struct {
+	// number of recent blocks whose state is retained by pruned full nodes, beyond which state
+	// requests are historical
+	RecentBlocks uint64 `default:"128"`
+}
+
+// historicalStateRouter routes historical state requests to archive nodes of the route group.
+type historicalStateRouter struct {
+	conf     historicalStateConfig
+	provider *node.EthClientProvider
+	head     func(ctx context.Context) (uint64, bool)
+
+	// block hash => block number
+	blockNumbers *util.ExpirableLruCache
+}
+
+func mustNewHistoricalStateRouterFromViper(
+	key string, provider *node.EthClientProvider, head func(ctx context.Context) (uint64, bool),
+) *historicalStateRouter {
+	var conf historicalStateConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	return newHistoricalStateRouter(conf, provider, head)
+}
+
+func newHistoricalStateRouter(
+	conf historicalStateConfig, provider *node.EthClientProvider, head func(ctx context.Context) (uint64, bool),
+) *historicalStateRouter {
+	return &historicalStateRouter{
+		conf:         conf,
+		provider:     provider,
+		head:         head,
+		blockNumbers: util.NewExpirableLruCache(historicalBlockHashCacheSize, time.Hour),
+	}
+}
+
+// client returns the full node client to query state at the block, which is rerouted to archive
+// node of the same route group if historical.
+func (r *historicalStateRouter) client(
+	ctx context.Context, method string, blockNumOrHash *web3Types.BlockNumberOrHash,
+) (*node.Web3goClient, error) {
+	w3c := GetEthClientFromContext(ctx)
+
+	if !r.isHistorical(ctx, w3c, blockNumOrHash) {
+		return w3c, nil
+	}
+
+	ip, _ := handlers.GetIPAddressFromContext(ctx)
+
+	client, err := r.provider.GetClientByTag(ip, GetClientGroupFromContext(ctx), mysql.NodeTagArchive)
+	if err == node.ErrTaggedClientUnavailable {
+		return nil, errHistoricalStateUnavailable
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if client.URL == w3c.URL {
+		return w3c, nil
+	}
+
+	metrics.Registry.RPC.HistoricalStateRerouted(method).Mark(1)
+	handlers.RecordUpstream(ctx, rpcutil.Url2NodeName(client.URL))
+
+	logrus.WithFields(logrus.Fields{
+		"method": method,
+		"block":  blockNumOrHash,
+		"from":   w3c.URL,
+		"to":     client.URL,
+	}).Debug("Historical state request rerouted to archive node")
+
+	if _, ok := ctx.Deadline(); ok { // propagate deadline to full node
+		return client.WithContext(ctx), nil
+	}
+
+	return client, nil
+}
+
+// isHistorical checks if the state at block is beyond the recent blocks retained by pruned nodes,
+// where block tags other than `earliest` always refer to the recent blocks.
+func (r *historicalStateRouter) isHistorical(
+	ctx context.Context, w3c *node.Web3goClient, blockNumOrHash *web3Types.BlockNumberOrHash,
+) bool {
+	if blockNumOrHash == nil { // latest block
+		return false
+	}
+
+	var number uint64
+
+	if bn, ok := blockNumOrHash.Number(); ok {
+		if bn == web3Types.EarliestBlockNumber {
+			return true
+		}
+
+		if bn < 0 { // latest, pending, safe or finalized
+			return false
+		}
+
+		number = uint64(bn)
+	} else if hash, ok := blockNumOrHash.Hash(); ok {
+		if number, ok = r.blockNumber(w3c, hash); !ok {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	head, ok := r.head(ctx)
+	return ok && number+r.conf.RecentBlocks < head
+}
+
+// blockNumber resolves the block number of block hash, which is cached since never changed.
+func (r *historicalStateRouter) blockNumber(w3c *node.Web3goClient, hash common.Hash) (uint64, bool) {
+	if v, ok := r.blockNumbers.Get(hash); ok {
+		return v.(uint64), true
+	}
+
+	block, err := w3c.Eth.BlockByHash(hash, false)
+	if err != nil || block == nil || block.Number == nil {
+		return 0, false
+	}
+
+	number := block.Number.Uint64()
+	r.blockNumbers.Add(hash, number)
+
+	return number, true
+}
@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoricalStateRouterIsHistorical(t *testing.T) {
+	r := newHistoricalStateRouter(historicalStateConfig{RecentBlocks: 128}, nil, func(ctx context.Context) (uint64, bool) {
+		return 1000, true
+	})
+
+	ctx := context.Background()
+	blockNumber := func(bn web3Types.BlockNumber) *web3Types.BlockNumberOrHash {
+		bnh := web3Types.BlockNumberOrHashWithNumber(bn)
+		return &bnh
+	}
+
+	assert.False(t, r.isHistorical(ctx, nil, nil))
+	assert.False(t, r.isHistorical(ctx, nil, blockNumber(web3Types.LatestBlockNumber)))
+	assert.False(t, r.isHistorical(ctx, nil, blockNumber(web3Types.PendingBlockNumber)))
+	assert.True(t, r.isHistorical(ctx, nil, blockNumber(web3Types.EarliestBlockNumber)))
+
+	assert.False(t, r.isHistorical(ctx, nil, blockNumber(872)))
+	assert.True(t, r.isHistorical(ctx, nil, blockNumber(871)))
+
+	// block hash resolved from cache
+	hash := common.HexToHash("0x01")
+	r.blockNumbers.Add(hash, uint64(100))
+
+	bnh := web3Types.BlockNumberOrHashWithHash(hash, false)
+	assert.True(t, r.isHistorical(ctx, nil, &bnh))
+
+	// head unknown
+	r.head = func(ctx context.Context) (uint64, bool) { return 0, false }
+	assert.False(t, r.isHistorical(ctx, nil, blockNumber(1)))
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+// negativeCacheConfig configurations to cache "not found" results, eg., missing transaction
+// hashes or future block numbers, so that bots polling for data that doesn't exist yet are not
+// passed through to full nodes.
+type negativeCacheConfig struct {
+	// switch to turn on/off negative caching
+	Enabled bool
+	// duration to cache "not found" result, which is invalidated on new head anyway
+	TTL time.Duration `default:"3s"`
+	// max number of "not found" results to cache
+	Size int `default:"100000"`
+}
+
+// negativeCache caches "not found" results along with the head when cached, which are regarded
+// as stale once new head arrives.
+type negativeCache struct {
+	conf  negativeCacheConfig
+	cache *util.ExpirableLruCache // group + method + params => head when cached
+	head  func(ctx context.Context) (uint64, bool)
+}
+
+func mustNewNegativeCacheFromViper(key string, head func(ctx context.Context) (uint64, bool)) *negativeCache {
+	var conf negativeCacheConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.TTL <= 0 || conf.Size <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid negative cache config")
+	}
+
+	logrus.WithField("config", conf).Info("Negative caching for known-missing data enabled")
+
+	return newNegativeCache(conf, head)
+}
+
+func newNegativeCache(conf negativeCacheConfig, head func(ctx context.Context) (uint64, bool)) *negativeCache {
+	return &negativeCache{
+		conf:  conf,
+		cache: util.NewExpirableLruCache(conf.Size, conf.TTL),
+		head:  head,
+	}
+}
+
+// negativeCacheKey returns the cache key of the RPC method and params, which is scoped to the
+// route group since full nodes of different groups might have different data, eg., archive.
+func negativeCacheKey(ctx context.Context, method string, params ...interface{}) string {
+	group, _ := ctx.Value(ctxKeyClientGroup).(node.Group)
+	return fmt.Sprintf("%v/%v/%v", group, method, params)
+}
+
+// missing checks if the RPC method and params are known as "not found", which is always false
+// if negative cache is nil.
+func (c *negativeCache) missing(ctx context.Context, method string, params ...interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	key := negativeCacheKey(ctx, method, params...)
+
+	cached, ok := c.cache.Get(key)
+	if ok {
+		// invalidated on new head
+		if head, headOk := c.head(ctx); headOk && head > cached.(uint64) {
+			c.cache.Remove(key)
+			ok = false
+		}
+	}
+
+	metrics.Registry.RPC.StoreHit(method, "negative").Mark(ok)
+
+	return ok
+}
+
+// markMissing caches the RPC method and params as "not found" at the current head.
+func (c *negativeCache) markMissing(ctx context.Context, method string, params ...interface{}) {
+	if c == nil {
+		return
+	}
+
+	head, _ := c.head(ctx)
+	c.cache.Add(negativeCacheKey(ctx, method, params...), head)
+}
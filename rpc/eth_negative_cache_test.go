@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegativeCache(t *testing.T) {
+	var head uint64 = 100
+
+	nc := newNegativeCache(negativeCacheConfig{TTL: 50 * time.Millisecond, Size: 10}, func(ctx context.Context) (uint64, bool) {
+		return atomic.LoadUint64(&head), true
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKeyClientGroup, node.GroupEthHttp)
+	txHash := common.HexToHash("0x01")
+
+	assert.False(t, nc.missing(ctx, "eth_getTransactionByHash", txHash))
+
+	nc.markMissing(ctx, "eth_getTransactionByHash", txHash)
+	assert.True(t, nc.missing(ctx, "eth_getTransactionByHash", txHash))
+
+	// scoped to route group and method
+	archiveCtx := context.WithValue(context.Background(), ctxKeyClientGroup, node.Group("archive"))
+	assert.False(t, nc.missing(archiveCtx, "eth_getTransactionByHash", txHash))
+	assert.False(t, nc.missing(ctx, "eth_getTransactionReceipt", txHash))
+
+	// invalidated on new head
+	atomic.StoreUint64(&head, 101)
+	assert.False(t, nc.missing(ctx, "eth_getTransactionByHash", txHash))
+
+	// expired
+	nc.markMissing(ctx, "eth_getTransactionByHash", txHash)
+	time.Sleep(60 * time.Millisecond)
+	assert.False(t, nc.missing(ctx, "eth_getTransactionByHash", txHash))
+
+	// nil negative cache
+	var nilCache *negativeCache
+	nilCache.markMissing(ctx, "eth_getTransactionByHash", txHash)
+	assert.False(t, nilCache.missing(ctx, "eth_getTransactionByHash", txHash))
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	rpcMethodEthSendRawTransaction = "eth_sendRawTransaction"
+)
+
+// pendingNonceConfig configurations to aggregate pending nonce across full nodes, since each
+// full node answers by its own txpool.
+type pendingNonceConfig struct {
+	// switch to turn on/off pending nonce aggregation
+	Enabled bool
+	// duration to cache the aggregated pending nonce per address
+	CacheTTL time.Duration `default:"1s"`
+	// max number of addresses to cache
+	CacheSize int `default:"10000"`
+	// timeout to query pending nonce of a full node
+	Timeout time.Duration `default:"3s"`
+}
+
+// nonceFetcher fetches the pending nonce from a full node.
+type nonceFetcher func(ctx context.Context) (*big.Int, error)
+
+// pendingNonceAggregator answers the max pending nonce among all connected full nodes of the
+// group to which transactions are routed, so that it keeps consistent regardless of which txpool
+// is hit.
+type pendingNonceAggregator struct {
+	conf  pendingNonceConfig
+	cache *util.ExpirableLruCache // group + address => *big.Int
+}
+
+func mustNewPendingNonceAggregatorFromViper(key string) *pendingNonceAggregator {
+	var conf pendingNonceConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	logrus.WithField("config", conf).Info("Pending nonce aggregation across full nodes enabled")
+
+	return newPendingNonceAggregator(conf)
+}
+
+func newPendingNonceAggregator(conf pendingNonceConfig) *pendingNonceAggregator {
+	return &pendingNonceAggregator{
+		conf:  conf,
+		cache: util.NewExpirableLruCache(conf.CacheSize, conf.CacheTTL),
+	}
+}
+
+// isPendingBlock checks if the block parameter is the `pending` block tag.
+func isPendingBlock(blockNumOrHash *web3Types.BlockNumberOrHash) bool {
+	if blockNumOrHash == nil {
+		return false
+	}
+
+	bn, ok := blockNumOrHash.Number()
+	return ok && bn == web3Types.PendingBlockNumber
+}
+
+// TransactionCount returns the max pending nonce of account among the active sequencer if any
+// and all connected full nodes of the group to which transactions are routed.
+func (a *pendingNonceAggregator) TransactionCount(
+	ctx context.Context, provider *node.EthClientProvider, account common.Address,
+) (*big.Int, error) {
+	group, ok := provider.MethodRouteGroup(rpcMethodEthSendRawTransaction)
+	if !ok {
+		group = GetClientGroupFromContext(ctx)
+	}
+
+	clients := provider.GroupClients(group)
+
+	if url, ok := provider.ActiveSequencer(); ok {
+		if client, err := provider.GetClientByURL(url, node.GroupEthSequencer); err == nil {
+			clients = append(clients, client)
+		}
+	}
+
+	if len(clients) == 0 { // no full node connected yet
+		clients = append(clients, GetEthClientFromContext(ctx))
+	}
+
+	pending := web3Types.BlockNumberOrHashWithNumber(web3Types.PendingBlockNumber)
+
+	fetchers := make([]nonceFetcher, 0, len(clients))
+	for _, client := range clients {
+		client := client
+		fetchers = append(fetchers, func(ctx context.Context) (*big.Int, error) {
+			return client.WithContext(ctx).Eth.TransactionCount(account, &pending)
+		})
+	}
+
+	return a.aggregate(string(group)+account.Hex(), fetchers)
+}
+
+// aggregate returns the cached pending nonce if any, otherwise fetches pending nonce from all
+// full nodes concurrently and returns the max one. Returns error if all full nodes failed.
+func (a *pendingNonceAggregator) aggregate(key string, fetchers []nonceFetcher) (*big.Int, error) {
+	if v, ok := a.cache.Get(key); ok {
+		return v.(*big.Int), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.conf.Timeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		nonce   *big.Int
+		lastErr error
+	)
+
+	for _, fetch := range fetchers {
+		wg.Add(1)
+
+		go func(fetch nonceFetcher) {
+			defer wg.Done()
+
+			n, err := fetch(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				lastErr = err
+				return
+			}
+
+			if n != nil && (nonce == nil || n.Cmp(nonce) > 0) {
+				nonce = n
+			}
+		}(fetch)
+	}
+
+	wg.Wait()
+
+	if nonce == nil {
+		return nil, lastErr
+	}
+
+	a.cache.Add(key, nonce)
+
+	return nonce, nil
+}
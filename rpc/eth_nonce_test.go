@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPendingBlock(t *testing.T) {
+	pending := web3Types.BlockNumberOrHashWithNumber(web3Types.PendingBlockNumber)
+	assert.True(t, isPendingBlock(&pending))
+
+	latest := web3Types.BlockNumberOrHashWithNumber(web3Types.LatestBlockNumber)
+	assert.False(t, isPendingBlock(&latest))
+	assert.False(t, isPendingBlock(nil))
+}
+
+func TestPendingNonceAggregate(t *testing.T) {
+	aggregator := newPendingNonceAggregator(pendingNonceConfig{
+		CacheTTL: time.Minute, CacheSize: 10, Timeout: time.Second,
+	})
+
+	fetcher := func(nonce int64, err error) nonceFetcher {
+		return func(ctx context.Context) (*big.Int, error) {
+			if err != nil {
+				return nil, err
+			}
+
+			return big.NewInt(nonce), nil
+		}
+	}
+
+	nonce, err := aggregator.aggregate("0x1", []nonceFetcher{
+		fetcher(5, nil), fetcher(7, nil), fetcher(0, errors.New("node down")), fetcher(6, nil),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), nonce.Int64())
+
+	// cached per address
+	nonce, err = aggregator.aggregate("0x1", []nonceFetcher{fetcher(9, nil)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), nonce.Int64())
+
+	// all full nodes failed
+	_, err = aggregator.aggregate("0x2", []nonceFetcher{fetcher(0, errors.New("node down"))})
+	assert.Error(t, err)
+}
@@ -0,0 +1,294 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// prewarmConfig configurations to pre-warm caches in background once new head arrives, so that
+// the thundering herd of "latest block" queries after each block is absorbed by the cache.
+type prewarmConfig struct {
+	// switch to turn on/off cache pre-warming
+	Enabled bool
+	// interval to poll the new head, or the aggregated head if head tracker enabled
+	Interval time.Duration `default:"500ms"`
+	// number of recent heads to cache, so that queries by number right after `latest` are hit
+	Retention int `default:"4"`
+	// timeout to pre-warm a new head
+	Timeout time.Duration `default:"3s"`
+}
+
+// headSnapshot data of a head pre-warmed in background.
+type headSnapshot struct {
+	block     *web3Types.Block // with transaction hashes only
+	fullBlock *web3Types.Block // with full transactions
+	receipts  []web3Types.Receipt
+	gasPrice  *hexutil.Big
+}
+
+// headPrewarmer fetches the new block, its receipts and gas price once new head arrives, which
+// are served to requests routed to the default group of evm space.
+type headPrewarmer struct {
+	conf        prewarmConfig
+	provider    *node.EthClientProvider
+	headTracker *node.HeadTracker
+
+	mu    sync.RWMutex
+	head  uint64                   // the latest pre-warmed head
+	heads map[uint64]*headSnapshot // block number => snapshot
+}
+
+func mustNewHeadPrewarmerFromViper(
+	key string, provider *node.EthClientProvider, headTracker *node.HeadTracker,
+) *headPrewarmer {
+	var conf prewarmConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Interval <= 0 || conf.Retention <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid cache pre-warming config")
+	}
+
+	prewarmer := newHeadPrewarmer(conf, provider, headTracker)
+	go prewarmer.run()
+
+	logrus.WithField("config", conf).Info("Cache pre-warming on new head started")
+
+	return prewarmer
+}
+
+func newHeadPrewarmer(
+	conf prewarmConfig, provider *node.EthClientProvider, headTracker *node.HeadTracker,
+) *headPrewarmer {
+	return &headPrewarmer{
+		conf:        conf,
+		provider:    provider,
+		headTracker: headTracker,
+		heads:       make(map[uint64]*headSnapshot),
+	}
+}
+
+// run polls new head periodically, which lives as long as the process.
+func (p *headPrewarmer) run() {
+	ticker := time.NewTicker(p.conf.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := p.poll(); err != nil {
+			logrus.WithError(err).Debug("Failed to pre-warm caches on new head")
+		}
+	}
+}
+
+// poll pre-warms caches if new head arrived.
+func (p *headPrewarmer) poll() error {
+	w3c, err := p.provider.GetClientRandom()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get client")
+	}
+
+	head, ok := p.headTracker.Head()
+	if !ok {
+		bn, err := w3c.Eth.BlockNumber()
+		if err != nil {
+			return errors.WithMessage(err, "failed to get block number")
+		}
+
+		head = bn.Uint64()
+	}
+
+	if latest, _ := p.latest(); head <= latest {
+		return nil
+	}
+
+	snapshot, err := p.fetch(w3c, head)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to fetch head %v", head)
+	}
+
+	p.add(head, snapshot)
+
+	return nil
+}
+
+// fetch fetches data of the head concurrently.
+func (p *headPrewarmer) fetch(w3c *node.Web3goClient, head uint64) (*headSnapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.conf.Timeout)
+	defer cancel()
+
+	w3c = w3c.WithContext(ctx)
+	number := web3Types.BlockNumber(head)
+	bnh := web3Types.BlockNumberOrHashWithNumber(number)
+
+	var (
+		snapshot headSnapshot
+		errs     [4]error
+		wg       sync.WaitGroup
+	)
+
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+		snapshot.block, errs[0] = w3c.Eth.BlockByNumber(number, false)
+	}()
+
+	go func() {
+		defer wg.Done()
+		snapshot.fullBlock, errs[1] = w3c.Eth.BlockByNumber(number, true)
+	}()
+
+	go func() {
+		defer wg.Done()
+		snapshot.receipts, errs[2] = blockReceipts(ctx, p.provider, w3c, rpcMethodEthGetBlockReceipts, &bnh)
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		var price *big.Int
+		price, errs[3] = w3c.Eth.GasPrice()
+		snapshot.gasPrice = (*hexutil.Big)(price)
+	}()
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if snapshot.block == nil || snapshot.fullBlock == nil || snapshot.block.Hash != snapshot.fullBlock.Hash {
+		return nil, errors.New("block not available or reorged")
+	}
+
+	return &snapshot, nil
+}
+
+// add caches snapshot of the new head, and evicts the old ones beyond retention. Besides, all
+// the old ones are evicted if chain reorg detected.
+func (p *headPrewarmer) add(head uint64, snapshot *headSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if parent, ok := p.heads[head-1]; ok && parent.block.Hash != snapshot.block.ParentHash {
+		p.heads = make(map[uint64]*headSnapshot)
+	}
+
+	p.head = head
+	p.heads[head] = snapshot
+
+	for number := range p.heads {
+		if number+uint64(p.conf.Retention) <= head {
+			delete(p.heads, number)
+		}
+	}
+}
+
+// latest returns the latest pre-warmed head, or false if not available yet or prewarmer is nil.
+func (p *headPrewarmer) latest() (uint64, bool) {
+	if p == nil {
+		return 0, false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.head, p.head > 0
+}
+
+// snapshot returns the pre-warmed snapshot of block number (or `latest` tag) for requests routed
+// to the default group, which is always missed if prewarmer is nil.
+func (p *headPrewarmer) snapshot(ctx context.Context, method string, blockNum web3Types.BlockNumber) (*headSnapshot, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	if grp, ok := ctx.Value(ctxKeyClientGroup).(node.Group); !ok || grp != node.GroupEthHttp {
+		return nil, false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	number := uint64(blockNum)
+	if blockNum == web3Types.LatestBlockNumber {
+		number = p.head
+	} else if blockNum < 0 { // other block tags, eg., pending or finalized
+		return nil, false
+	}
+
+	snapshot, ok := p.heads[number]
+	metrics.Registry.RPC.StoreHit(method, "prewarm").Mark(ok)
+
+	return snapshot, ok
+}
+
+// blockNumber returns the latest pre-warmed head for requests routed to the default group.
+func (p *headPrewarmer) blockNumber(ctx context.Context) (*hexutil.Big, bool) {
+	if _, ok := p.snapshot(ctx, "eth_blockNumber", web3Types.LatestBlockNumber); !ok {
+		return nil, false
+	}
+
+	head, _ := p.latest()
+
+	return (*hexutil.Big)(new(big.Int).SetUint64(head)), true
+}
+
+// block returns the pre-warmed block of block number for requests routed to the default group.
+func (p *headPrewarmer) block(ctx context.Context, blockNum web3Types.BlockNumber, fullTx bool) (*web3Types.Block, bool) {
+	snapshot, ok := p.snapshot(ctx, "eth_getBlockByNumber", blockNum)
+	if !ok {
+		return nil, false
+	}
+
+	if fullTx {
+		return snapshot.fullBlock, true
+	}
+
+	return snapshot.block, true
+}
+
+// receipts returns the pre-warmed block receipts for requests routed to the default group.
+func (p *headPrewarmer) receipts(ctx context.Context, blockNumOrHash *web3Types.BlockNumberOrHash) ([]web3Types.Receipt, bool) {
+	blockNum := web3Types.LatestBlockNumber
+	if blockNumOrHash != nil {
+		if num, ok := blockNumOrHash.Number(); ok {
+			blockNum = num
+		} else {
+			return nil, false
+		}
+	}
+
+	snapshot, ok := p.snapshot(ctx, "eth_getBlockReceipts", blockNum)
+	if !ok {
+		return nil, false
+	}
+
+	return snapshot.receipts, true
+}
+
+// gasPrice returns the gas price pre-warmed at the latest head for requests routed to the
+// default group.
+func (p *headPrewarmer) gasPrice(ctx context.Context) (*hexutil.Big, bool) {
+	snapshot, ok := p.snapshot(ctx, "eth_gasPrice", web3Types.LatestBlockNumber)
+	if !ok {
+		return nil, false
+	}
+
+	return snapshot.gasPrice, true
+}
@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadPrewarmer(t *testing.T) {
+	var head uint64 = 10
+
+	blockHash := func(number uint64) string {
+		return fmt.Sprintf("0x%064x", number)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		number := atomic.LoadUint64(&head)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_blockNumber":
+			resp["result"] = fmt.Sprintf("0x%x", number)
+		case "eth_getBlockByNumber":
+			resp["result"] = map[string]interface{}{
+				"number": fmt.Sprintf("0x%x", number), "hash": blockHash(number), "parentHash": blockHash(number - 1),
+				"difficulty": "0x0", "transactions": []interface{}{}, "uncles": []interface{}{},
+			}
+		case rpcMethodEthGetBlockReceipts:
+			resp["result"] = []interface{}{}
+		case "eth_gasPrice":
+			resp["result"] = "0x3b9aca00"
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: {server.URL},
+	}))
+
+	prewarmer := newHeadPrewarmer(prewarmConfig{Retention: 2, Timeout: time.Second}, provider, nil)
+	assert.NoError(t, prewarmer.poll())
+
+	ctx := context.WithValue(context.Background(), ctxKeyClientGroup, node.GroupEthHttp)
+
+	bn, ok := prewarmer.blockNumber(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), bn.ToInt().Uint64())
+
+	block, ok := prewarmer.block(ctx, web3Types.LatestBlockNumber, true)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), block.Number.Uint64())
+
+	_, ok = prewarmer.receipts(ctx, nil)
+	assert.True(t, ok)
+
+	price, ok := prewarmer.gasPrice(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1e9), price.ToInt().Uint64())
+
+	// other groups or block tags are never served
+	_, ok = prewarmer.block(context.WithValue(ctx, ctxKeyClientGroup, node.Group("archive")), 10, false)
+	assert.False(t, ok)
+
+	_, ok = prewarmer.block(ctx, web3Types.PendingBlockNumber, false)
+	assert.False(t, ok)
+
+	// evicted beyond retention
+	atomic.StoreUint64(&head, 11)
+	assert.NoError(t, prewarmer.poll())
+	atomic.StoreUint64(&head, 12)
+	assert.NoError(t, prewarmer.poll())
+
+	_, ok = prewarmer.block(ctx, 10, false)
+	assert.False(t, ok)
+
+	block, ok = prewarmer.block(ctx, 11, false)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(11), block.Number.Uint64())
+
+	// nil prewarmer
+	var nilPrewarmer *headPrewarmer
+	_, ok = nilPrewarmer.blockNumber(ctx)
+	assert.False(t, ok)
+}
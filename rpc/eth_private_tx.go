@@ -0,0 +1,299 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rate"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/openweb3/web3go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RPC method of private RPC (eg., Flashbots Protect) to submit transaction privately
+	rpcMethodEthSendPrivateTransaction = "eth_sendPrivateTransaction"
+
+	// status of private transaction for callbacks
+	privateTxStatusSubmitted = "submitted"
+	privateTxStatusFailed    = "failed"
+	privateTxStatusIncluded  = "included"
+	privateTxStatusExpired   = "expired"
+)
+
+// privateRelayConfig private transaction relay, eg., Flashbots Protect RPC or block builders.
+type privateRelayConfig struct {
+	Name string
+	Url  string
+	// RPC method to submit raw transaction, `eth_sendRawTransaction` for private RPC, or
+	// `eth_sendPrivateTransaction` with `{"tx": rawTx}` as parameter.
+	Method string
+}
+
+// privateTxConfig configurations to submit raw transactions of allowlists flagged as private tx
+// to private relays rather than the public mempool.
+type privateTxConfig struct {
+	// switch to turn on/off private transactions
+	Enabled bool
+	// private relays to submit transactions concurrently
+	Relays []privateRelayConfig
+	// timeout to submit transaction to a relay
+	Timeout time.Duration `default:"5s"`
+	// URL to post status changes of private transactions, no callbacks if empty
+	CallbackUrl string
+	// interval to poll transaction receipt for status callbacks
+	PollInterval time.Duration `default:"3s"`
+	// transactions not included within the duration are regarded as expired
+	MaxPending time.Duration `default:"10m"`
+}
+
+// privateTxStatus status change of private transaction posted to the callback URL.
+type privateTxStatus struct {
+	TxHash      common.Hash `json:"txHash"`
+	AllowList   string      `json:"allowList"`
+	Relays      []string    `json:"relays,omitempty"` // relays that accepted the transaction
+	Status      string      `json:"status"`
+	BlockNumber *uint64     `json:"blockNumber,omitempty"`
+	Reverted    bool        `json:"reverted,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Timestamp   int64       `json:"timestamp"`
+}
+
+// privateRelay RPC client of private relay.
+type privateRelay struct {
+	privateRelayConfig
+
+	client *web3go.Client
+}
+
+// privateTxRelay submits raw transactions to private relays to protect from MEV, and tracks
+// the transaction status for callbacks.
+type privateTxRelay struct {
+	conf     privateTxConfig
+	relays   []*privateRelay
+	provider *node.EthClientProvider // to poll transaction receipt
+	callback *http.Client
+}
+
+func mustNewPrivateTxRelayFromViper(key string, provider *node.EthClientProvider) *privateTxRelay {
+	var conf privateTxConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	relay, err := newPrivateTxRelay(conf, provider)
+	if err != nil {
+		logrus.WithField("config", conf).WithError(err).Fatal("Failed to create private transaction relay")
+	}
+
+	logrus.WithField("config", conf).Info("Private transaction relay enabled")
+
+	return relay
+}
+
+func newPrivateTxRelay(conf privateTxConfig, provider *node.EthClientProvider) (*privateTxRelay, error) {
+	if len(conf.Relays) == 0 {
+		return nil, errors.New("no private relay configured")
+	}
+
+	ptr := &privateTxRelay{
+		conf:     conf,
+		provider: provider,
+		callback: &http.Client{Timeout: conf.Timeout},
+	}
+
+	for _, rc := range conf.Relays {
+		if len(rc.Name) == 0 || len(rc.Url) == 0 {
+			return nil, errors.Errorf("name or url of private relay must not be empty")
+		}
+
+		if len(rc.Method) == 0 {
+			rc.Method = rpcMethodEthSendRawTransaction
+		}
+
+		if rc.Method != rpcMethodEthSendRawTransaction && rc.Method != rpcMethodEthSendPrivateTransaction {
+			return nil, errors.Errorf("unsupported method %v of private relay %v", rc.Method, rc.Name)
+		}
+
+		client, err := rpcutil.NewEthClient(rc.Url, rpcutil.WithClientRequestTimeout(conf.Timeout))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to connect to private relay %v", rc.Name)
+		}
+
+		ptr.relays = append(ptr.relays, &privateRelay{privateRelayConfig: rc, client: client})
+	}
+
+	return ptr, nil
+}
+
+// privateAllowList returns name of the allowlist assigned to the request if flagged as private
+// tx, which is always false if privateTxRelay is nil.
+func (r *privateTxRelay) privateAllowList(ctx context.Context) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok {
+		return "", false
+	}
+
+	if al, ok := registry.AllowList(ctx); ok && al.PrivateTx {
+		return al.Name, true
+	}
+
+	return "", false
+}
+
+// Send submits raw transaction to all private relays concurrently, and succeeds if accepted by
+// any relay. Note, it is never broadcast to the public mempool.
+func (r *privateTxRelay) Send(allowList string, signedTx hexutil.Bytes) (common.Hash, error) {
+	txHash := crypto.Keccak256Hash(signedTx)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted []string
+		lastErr  error
+	)
+
+	for _, relay := range r.relays {
+		wg.Add(1)
+
+		go func(relay *privateRelay) {
+			defer wg.Done()
+
+			err := relay.send(r.conf.Timeout, signedTx)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				metrics.Registry.RPC.PrivateTx(relay.Name, "failure").Mark(1)
+				logrus.WithFields(logrus.Fields{
+					"relay": relay.Name, "txHash": txHash,
+				}).WithError(err).Info("Failed to submit private transaction to relay")
+
+				lastErr = err
+				return
+			}
+
+			metrics.Registry.RPC.PrivateTx(relay.Name, "success").Mark(1)
+			accepted = append(accepted, relay.Name)
+		}(relay)
+	}
+
+	wg.Wait()
+
+	status := &privateTxStatus{TxHash: txHash, AllowList: allowList, Relays: accepted}
+
+	if len(accepted) == 0 {
+		status.Status, status.Error = privateTxStatusFailed, lastErr.Error()
+		go r.notify(status)
+
+		return common.Hash{}, errors.WithMessage(lastErr, "failed to submit private transaction")
+	}
+
+	status.Status = privateTxStatusSubmitted
+	go r.track(status)
+
+	return txHash, nil
+}
+
+func (relay *privateRelay) send(timeout time.Duration, signedTx hexutil.Bytes) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var result interface{}
+
+	if relay.Method == rpcMethodEthSendPrivateTransaction {
+		params := map[string]interface{}{"tx": signedTx}
+		return relay.client.Provider().CallContext(ctx, &result, relay.Method, params)
+	}
+
+	return relay.client.Provider().CallContext(ctx, &result, relay.Method, signedTx)
+}
+
+// track notifies the submitted status, and polls transaction receipt until included or expired.
+func (r *privateTxRelay) track(status *privateTxStatus) {
+	if len(r.conf.CallbackUrl) == 0 {
+		return
+	}
+
+	r.notify(status)
+
+	ticker := time.NewTicker(r.conf.PollInterval)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(r.conf.MaxPending)
+
+	for range ticker.C {
+		if time.Now().After(deadline) {
+			r.notify(&privateTxStatus{
+				TxHash: status.TxHash, AllowList: status.AllowList, Status: privateTxStatusExpired,
+			})
+			return
+		}
+
+		client, err := r.provider.GetClientRandom()
+		if err != nil {
+			continue
+		}
+
+		receipt, err := client.Eth.TransactionReceipt(status.TxHash)
+		if err != nil || receipt == nil {
+			continue
+		}
+
+		r.notify(&privateTxStatus{
+			TxHash:      status.TxHash,
+			AllowList:   status.AllowList,
+			Status:      privateTxStatusIncluded,
+			BlockNumber: &receipt.BlockNumber,
+			Reverted:    receipt.Status != nil && *receipt.Status == 0,
+		})
+		return
+	}
+}
+
+// notify posts the status change of private transaction to the callback URL if configured.
+func (r *privateTxRelay) notify(status *privateTxStatus) {
+	if len(r.conf.CallbackUrl) == 0 {
+		return
+	}
+
+	status.Timestamp = time.Now().Unix()
+
+	logger := logrus.WithField("status", status)
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		logger.WithError(err).Error("Failed to marshal private transaction status")
+		return
+	}
+
+	resp, err := r.callback.Post(r.conf.CallbackUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.WithError(err).Info("Failed to post private transaction status callback")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.WithField("httpStatus", resp.Status).Info("Private transaction status callback rejected")
+	}
+}
@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPrivateRelayServer creates a private relay that records the submitted JSON-RPC requests.
+func newPrivateRelayServer(accept bool, requests chan<- map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		requests <- req
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req["id"]}
+		if accept {
+			resp["result"] = "0x01"
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "rejected"}
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestPrivateTxRelaySend(t *testing.T) {
+	requests := make(chan map[string]interface{}, 2)
+
+	protect := newPrivateRelayServer(true, requests)
+	defer protect.Close()
+
+	builder := newPrivateRelayServer(false, requests)
+	defer builder.Close()
+
+	relay, err := newPrivateTxRelay(privateTxConfig{
+		Relays: []privateRelayConfig{
+			{Name: "protect", Url: protect.URL},
+			{Name: "builder", Url: builder.URL, Method: rpcMethodEthSendPrivateTransaction},
+		},
+		Timeout: time.Second,
+	}, nil)
+	assert.NoError(t, err)
+
+	signedTx := hexutil.Bytes{0x01, 0x02, 0x03}
+
+	// accepted by any relay
+	txHash, err := relay.Send("vip", signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.Keccak256Hash(signedTx), txHash)
+
+	methods := make(map[string]interface{})
+	for i := 0; i < 2; i++ {
+		req := <-requests
+		methods[req["method"].(string)] = req["params"].([]interface{})[0]
+	}
+
+	assert.Equal(t, signedTx.String(), methods[rpcMethodEthSendRawTransaction])
+	assert.Equal(t, map[string]interface{}{"tx": signedTx.String()}, methods[rpcMethodEthSendPrivateTransaction])
+}
+
+func TestPrivateTxRelayFailedCallback(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+
+	builder := newPrivateRelayServer(false, requests)
+	defer builder.Close()
+
+	statuses := make(chan privateTxStatus, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status privateTxStatus
+		json.NewDecoder(r.Body).Decode(&status)
+		statuses <- status
+	}))
+	defer callback.Close()
+
+	relay, err := newPrivateTxRelay(privateTxConfig{
+		Relays:      []privateRelayConfig{{Name: "builder", Url: builder.URL}},
+		Timeout:     time.Second,
+		CallbackUrl: callback.URL,
+	}, nil)
+	assert.NoError(t, err)
+
+	// rejected by all relays
+	_, err = relay.Send("vip", hexutil.Bytes{0x01})
+	assert.Error(t, err)
+
+	select {
+	case status := <-statuses:
+		assert.Equal(t, privateTxStatusFailed, status.Status)
+		assert.Equal(t, "vip", status.AllowList)
+		assert.NotEmpty(t, status.Error)
+	case <-time.After(3 * time.Second):
+		t.Fatal("status callback not received")
+	}
+}
+
+func TestNewPrivateTxRelayInvalid(t *testing.T) {
+	_, err := newPrivateTxRelay(privateTxConfig{}, nil)
+	assert.Error(t, err)
+
+	_, err = newPrivateTxRelay(privateTxConfig{
+		Relays: []privateRelayConfig{{Name: "relay", Url: "http://127.0.0.1:8545", Method: "eth_call"}},
+	}, nil)
+	assert.Error(t, err)
+}
@@ -1,29 +1,47 @@
 package rpc
 
 import (
+	"context"
+
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
 	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/openweb3/go-rpc-provider"
 	"github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+// ethPubsubOption options of eth pubsub delegate client.
+type ethPubsubOption struct {
+	replay    pubsubReplayConfig
+	reconnect pubsubReconnectConfig
+	provider  *node.EthClientProvider // to backfill missed blocks via HTTP once reconnected
+}
+
 // ethDelegateClient eth client delegated for pubsub subscription
 type ethDelegateClient struct {
 	*node.Web3goClient
 
 	delegateContexts util.ConcurrentMap // context name => *delegateContext
+
+	option ethPubsubOption
 }
 
-func getOrNewEthDelegateClient(eth *node.Web3goClient) *ethDelegateClient {
+func getOrNewEthDelegateClient(eth *node.Web3goClient, option ethPubsubOption) *ethDelegateClient {
 	nodeName := rpcutil.Url2NodeName(eth.URL)
-	client, _ := delegateClients.LoadOrStore(nodeName, &ethDelegateClient{Web3goClient: eth})
+	client, _ := delegateClients.LoadOrStore(nodeName, &ethDelegateClient{Web3goClient: eth, option: option})
 	return client.(*ethDelegateClient)
 }
 
 func (client *ethDelegateClient) getDelegateCtx(ctxName string) *delegateContext {
-	dctx, _ := client.delegateContexts.LoadOrStore(ctxName, newDelegateContext())
+	dctx, _ := client.delegateContexts.LoadOrStoreFn(ctxName, func(k interface{}) interface{} {
+		return newDelegateContext(withReplay(newReplayBuffer(client.option.replay)))
+	})
+
 	return dctx.(*delegateContext)
 }
 
@@ -38,30 +56,69 @@ func (client *ethDelegateClient) delegateSubscribeNewHeads(
 }
 
 func (client *ethDelegateClient) proxySubscribeNewHeads(dctx *delegateContext) error {
+	if !ethDelegateLoops.start() {
+		return errPubsubShutdown
+	}
+
 	nhCh := make(chan *types.Header, pubsubChannelBufferSize)
 	csub, err := client.Eth.SubscribeNewHead(nhCh)
 	if err != nil {
 		logrus.WithField("nodeURL", client.URL).
 			WithError(err).
 			Info("ETH Pub/Sub NewHead proxy subscription conn error")
+		ethDelegateLoops.done()
 		return err
 	}
 
+	// notifications may be missed since the last upstream subscription
+	dctx.replay.reset()
+
 	go func() { // run subscription loop
+		defer ethDelegateLoops.done()
 		dctx.setStatus(delegateStatusOK)
 		defer dctx.setStatus(delegateStatusInit)
 
+		var lastBlock uint64                // last delivered block number
+		var backfilled map[common.Hash]bool // hashes of backfilled headers to skip
+
 		for dctx.getStatus() == delegateStatusOK {
 			select {
+			case <-ethDelegateLoops.stop: // graceful shutdown
+				dctx.stop(csub)
 			case err = <-csub.Err():
-				logrus.WithField("nodeURL", client.URL).
-					WithError(err).
-					Info("ETH Pub/Sub NewHeads proxy subscription delegate error")
+				logger := logrus.WithField("nodeURL", client.URL)
+				logger.WithError(err).Info("ETH Pub/Sub NewHeads proxy subscription delegate error")
 
-				dctx.setStatus(delegateStatusErr)
+				metrics.Registry.PubSub.Disconnects("eth", client.NodeName()).Mark(1)
 				csub.Unsubscribe()
+
+				if client.option.reconnect.reconnect(logger, "eth", client.NodeName(), func() (err error) {
+					csub, err = client.Eth.SubscribeNewHead(nhCh)
+					return err
+				}) {
+					if lastBlock, backfilled, err = client.backfillNewHeads(dctx, lastBlock); err == nil {
+						continue
+					}
+
+					logger.WithError(err).Info("ETH Pub/Sub failed to backfill missed block headers")
+					csub.Unsubscribe()
+				}
+
+				dctx.setStatus(delegateStatusErr)
 				dctx.cancel(err)
 			case h := <-nhCh: // notify all delegated subscriptions
+				if h.Number == nil {
+					dctx.notify(h)
+					continue
+				}
+
+				if len(backfilled) > 0 && h.HeaderExtra.Hash != nil && backfilled[*h.HeaderExtra.Hash] {
+					continue
+				}
+
+				lastBlock = h.Number.Uint64()
+
+				dctx.replay.add(lastBlock, h)
 				dctx.notify(h)
 			}
 		}
@@ -72,6 +129,68 @@ func (client *ethDelegateClient) proxySubscribeNewHeads(dctx *delegateContext) e
 	return nil
 }
 
+// backfillClient returns HTTP client of the same full node (or at least the same route key) to
+// backfill the missed blocks.
+func (client *ethDelegateClient) backfillClient(lastBlock uint64) (*node.Web3goClient, uint64, error) {
+	if client.option.provider == nil {
+		return nil, 0, errors.New("no client provider to backfill")
+	}
+
+	w3c, err := client.option.provider.GetClient(client.NodeName(), node.GroupEthHttp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	head, err := w3c.Eth.BlockNumber()
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get block number")
+	}
+
+	if head.Uint64() > lastBlock+client.option.reconnect.MaxBackfillBlocks {
+		return nil, 0, errors.Errorf("too many missed blocks since %v to backfill", lastBlock)
+	}
+
+	return w3c, head.Uint64(), nil
+}
+
+// backfillNewHeads notifies block headers missed since the last delivered block via HTTP, and
+// returns the latest backfilled block along with hashes of the backfilled headers.
+func (client *ethDelegateClient) backfillNewHeads(
+	dctx *delegateContext, lastBlock uint64,
+) (uint64, map[common.Hash]bool, error) {
+	if lastBlock == 0 { // nothing delivered yet
+		return 0, nil, nil
+	}
+
+	w3c, head, err := client.backfillClient(lastBlock)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	backfilled := make(map[common.Hash]bool)
+
+	for bn := lastBlock + 1; bn <= head; bn++ {
+		var header *types.Header
+		err := w3c.Provider().CallContext(context.Background(), &header, "eth_getBlockByNumber", hexutil.Uint64(bn), false)
+		if err != nil {
+			return 0, nil, errors.WithMessagef(err, "failed to get block header %v", bn)
+		}
+
+		if header == nil || header.HeaderExtra.Hash == nil {
+			return 0, nil, errors.Errorf("block header %v not found", bn)
+		}
+
+		backfilled[*header.HeaderExtra.Hash] = true
+
+		dctx.replay.add(bn, header)
+		dctx.notify(header)
+	}
+
+	metrics.Registry.PubSub.Backfilled("eth", "new_heads").Mark(int64(len(backfilled)))
+
+	return head, backfilled, nil
+}
+
 func (client *ethDelegateClient) delegateSubscribeLogs(
 	subId rpc.ID, channel chan *types.Log, filter types.FilterQuery) (*delegateSubscription, error) {
 
@@ -87,30 +206,67 @@ func (client *ethDelegateClient) delegateSubscribeLogs(
 }
 
 func (client *ethDelegateClient) proxySubscribeLogs(dctx *delegateContext) error {
+	if !ethDelegateLoops.start() {
+		return errPubsubShutdown
+	}
+
 	logsCh := make(chan types.Log, pubsubChannelBufferSize)
 	csub, err := client.Eth.SubscribeFilterLogs(types.FilterQuery{}, logsCh)
 	if err != nil {
 		logrus.WithField("nodeURL", client.URL).
 			WithError(err).
 			Info("ETH Pub/Sub Logs proxy subscription conn error")
+		ethDelegateLoops.done()
 		return err
 	}
 
+	// notifications may be missed since the last upstream subscription
+	dctx.replay.reset()
+
 	go func() { // run subscription loop
+		defer ethDelegateLoops.done()
 		dctx.setStatus(delegateStatusOK)
 		defer dctx.setStatus(delegateStatusInit)
 
+		var lastBlock uint64       // block number of the last delivered log
+		var backfilledBlock uint64 // logs up to this block have been backfilled
+
 		for dctx.getStatus() == delegateStatusOK {
 			select {
+			case <-ethDelegateLoops.stop: // graceful shutdown
+				dctx.stop(csub)
 			case err = <-csub.Err():
-				logrus.WithField("nodeURL", client.URL).
-					WithError(err).
-					Info("ETH Pub/Sub Logs delegate subscription delegate error")
+				logger := logrus.WithField("nodeURL", client.URL)
+				logger.WithError(err).Info("ETH Pub/Sub Logs delegate subscription delegate error")
 
-				dctx.setStatus(delegateStatusErr)
+				metrics.Registry.PubSub.Disconnects("eth", client.NodeName()).Mark(1)
 				csub.Unsubscribe()
+
+				if client.option.reconnect.reconnect(logger, "eth", client.NodeName(), func() (err error) {
+					csub, err = client.Eth.SubscribeFilterLogs(types.FilterQuery{}, logsCh)
+					return err
+				}) {
+					if backfilledBlock, err = client.backfillLogs(dctx, lastBlock); err == nil {
+						lastBlock = backfilledBlock
+						continue
+					}
+
+					logger.WithError(err).Info("ETH Pub/Sub failed to backfill missed logs")
+					csub.Unsubscribe()
+				}
+
+				dctx.setStatus(delegateStatusErr)
 				dctx.cancel(err)
 			case l := <-logsCh: // notify all delegated subscriptions
+				if !l.Removed && l.BlockNumber <= backfilledBlock {
+					continue // already backfilled
+				}
+
+				if l.BlockNumber > lastBlock {
+					lastBlock = l.BlockNumber
+				}
+
+				dctx.replay.add(l.BlockNumber, &l)
 				dctx.notify(&l)
 			}
 		}
@@ -121,6 +277,35 @@ func (client *ethDelegateClient) proxySubscribeLogs(dctx *delegateContext) error
 	return nil
 }
 
+// backfillLogs notifies logs missed since the block of last delivered log via HTTP, and returns
+// the latest backfilled block.
+func (client *ethDelegateClient) backfillLogs(dctx *delegateContext, lastBlock uint64) (uint64, error) {
+	if lastBlock == 0 { // nothing delivered yet
+		return 0, nil
+	}
+
+	w3c, head, err := client.backfillClient(lastBlock)
+	if err != nil || head <= lastBlock {
+		return lastBlock, err
+	}
+
+	fromBlock, toBlock := types.BlockNumber(lastBlock+1), types.BlockNumber(head)
+
+	logs, err := w3c.Eth.Logs(types.FilterQuery{FromBlock: &fromBlock, ToBlock: &toBlock})
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed to get logs from %v to %v", fromBlock, toBlock)
+	}
+
+	for i := range logs {
+		dctx.replay.add(logs[i].BlockNumber, &logs[i])
+		dctx.notify(&logs[i])
+	}
+
+	metrics.Registry.PubSub.Backfilled("eth", "logs").Mark(int64(len(logs)))
+
+	return head, nil
+}
+
 func matchEthPubSubLogFilter(log *types.Log, filter *types.FilterQuery) bool {
 	if len(filter.Addresses) == 0 && len(filter.Topics) == 0 {
 		return true
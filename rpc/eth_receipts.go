@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/openweb3/go-rpc-provider"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	rpcMethodEthGetBlockReceipts    = "eth_getBlockReceipts"
+	rpcMethodParityGetBlockReceipts = "parity_getBlockReceipts"
+
+	// JSON-RPC error code when method not found
+	errCodeMethodNotFound = -32601
+)
+
+// isMethodNotFound checks if the full node responds with method not found error.
+func isMethodNotFound(err error) bool {
+	rpcErr, ok := err.(rpc.Error)
+	return ok && rpcErr.ErrorCode() == errCodeMethodNotFound
+}
+
+// blockReceipts queries receipts of block from full node by the requested method, which is
+// translated to the other variant if unsupported by full node, so that clients get uniform
+// interface regardless of the full node implementation.
+func blockReceipts(
+	ctx context.Context,
+	provider *node.EthClientProvider,
+	w3c *node.Web3goClient,
+	method string,
+	blockNumOrHash *web3Types.BlockNumberOrHash,
+) ([]web3Types.Receipt, error) {
+	if blockNumOrHash == nil {
+		latest := web3Types.BlockNumberOrHashWithNumber(web3Types.LatestBlockNumber)
+		blockNumOrHash = &latest
+	}
+
+	methods := []string{method, ethMethodTranslations[method]}
+
+	// full node is known to support the other variant only
+	if !provider.SupportsMethod(w3c.URL, methods[0]) && provider.SupportsMethod(w3c.URL, methods[1]) {
+		methods = methods[1:]
+	}
+
+	var err error
+
+	stream, streaming := responseStreamFromContext(ctx)
+	streaming = streaming && stream.canProxy(w3c.URL)
+
+	for _, m := range methods {
+		var receipts []web3Types.Receipt
+		if streaming {
+			err = stream.proxy(ctx, w3c.URL, m, nil, blockNumOrHash)
+		} else {
+			err = w3c.Provider().CallContext(ctx, &receipts, m, blockNumOrHash)
+		}
+
+		if !isMethodNotFound(err) {
+			metrics.Registry.RPC.Percentage(method, "translated").Mark(m != method)
+			return receipts, err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"node": w3c.NodeName(), "method": m,
+		}).Info("Block receipts method unsupported by full node")
+
+		provider.SetMethodUnsupported(w3c.URL, m)
+	}
+
+	return nil, err
+}
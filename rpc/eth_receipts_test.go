@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockReceiptsTranslation(t *testing.T) {
+	var legacyCalls int32
+
+	// legacy full node that supports `parity_getBlockReceipts` only
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if req.Method == rpcMethodParityGetBlockReceipts {
+			atomic.AddInt32(&legacyCalls, 1)
+			resp["result"] = []interface{}{}
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: {server.URL},
+	}))
+
+	w3c, err := provider.GetClientByURL(server.URL, node.GroupEthHttp)
+	assert.NoError(t, err)
+
+	receipts, err := blockReceipts(context.Background(), provider, w3c, rpcMethodEthGetBlockReceipts, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, receipts)
+	assert.False(t, provider.SupportsMethod(server.URL, rpcMethodEthGetBlockReceipts))
+
+	// translated directly once unsupported method learned
+	_, err = blockReceipts(context.Background(), provider, w3c, rpcMethodEthGetBlockReceipts, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&legacyCalls))
+
+	_, err = blockReceipts(context.Background(), provider, w3c, rpcMethodParityGetBlockReceipts, nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// rollupConfig configurations of rollup RPC proxy to the rollup nodes (eg., op-node or kroma-node)
+// of L2 chain.
+type rollupConfig struct {
+	// switch to turn on/off rollup RPC proxy
+	Enabled bool
+	// namespace of rollup RPC methods, eg., `optimism` for op-node or `kroma` for kroma-node
+	Namespace string `default:"optimism"`
+	// rollup node urls, which are requested in turn and failed over upon error
+	Nodes []string
+	// duration to cache the rollup config, which barely changes
+	ConfigCacheTTL time.Duration `default:"1h"`
+	// methods only available to callers whose allowlist explicitly allows them
+	Restricted []string
+}
+
+// ethRollupAPI provides rollup RPC proxy API, which is served by the rollup nodes rather than the
+// evm space full nodes.
+type ethRollupAPI struct {
+	conf    rollupConfig
+	clients []*rpc.Client
+	next    uint32 // index of the rollup node to request next
+
+	mu             sync.Mutex
+	config         json.RawMessage // cached rollup config
+	configExpireAt time.Time
+}
+
+func mustNewRollupAPIFromViper(key string) *ethRollupAPI {
+	var conf rollupConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if len(conf.Nodes) == 0 || len(conf.Namespace) == 0 {
+		logrus.WithField("config", conf).Fatal("Invalid rollup RPC proxy config")
+	}
+
+	api, err := newRollupAPI(conf)
+	if err != nil {
+		logrus.WithField("config", conf).WithError(err).Fatal("Failed to create rollup RPC proxy")
+	}
+
+	logrus.WithField("config", conf).Info("Rollup RPC proxy enabled")
+
+	return api
+}
+
+func newRollupAPI(conf rollupConfig) (*ethRollupAPI, error) {
+	api := &ethRollupAPI{conf: conf}
+
+	for _, url := range conf.Nodes {
+		client, err := rpc.DialContext(context.Background(), url)
+		if err != nil {
+			return nil, err
+		}
+
+		api.clients = append(api.clients, client)
+	}
+
+	return api, nil
+}
+
+// isRollupRpcMethod checks if the RPC method is served by rollup nodes, which returns false if
+// api is nil.
+func (api *ethRollupAPI) isRollupRpcMethod(method string) bool {
+	return api != nil && strings.HasPrefix(method, api.conf.Namespace+"_")
+}
+
+// SyncStatus returns the sync status of rollup node, eg., the unsafe, safe and finalized L2 heads.
+func (api *ethRollupAPI) SyncStatus(ctx context.Context) (json.RawMessage, error) {
+	return api.call(ctx, "syncStatus")
+}
+
+// RollupConfig returns the rollup config of L2 chain, which is cached for a while.
+func (api *ethRollupAPI) RollupConfig(ctx context.Context) (json.RawMessage, error) {
+	method := api.conf.Namespace + "_rollupConfig"
+	if !api.allowed(ctx, method) {
+		return nil, errMethodRestricted
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if api.config != nil && time.Now().Before(api.configExpireAt) {
+		return api.config, nil
+	}
+
+	config, err := api.forward(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+
+	api.config, api.configExpireAt = config, time.Now().Add(api.conf.ConfigCacheTTL)
+
+	return config, nil
+}
+
+// OutputAtBlock returns the L2 output root at the specified block.
+func (api *ethRollupAPI) OutputAtBlock(ctx context.Context, blockNum hexutil.Uint64) (json.RawMessage, error) {
+	return api.call(ctx, "outputAtBlock", blockNum)
+}
+
+func (api *ethRollupAPI) call(ctx context.Context, name string, args ...interface{}) (json.RawMessage, error) {
+	method := api.conf.Namespace + "_" + name
+	if !api.allowed(ctx, method) {
+		return nil, errMethodRestricted
+	}
+
+	return api.forward(ctx, method, args...)
+}
+
+// forward requests rollup nodes in turn, and fails over to the next one upon error.
+func (api *ethRollupAPI) forward(ctx context.Context, method string, args ...interface{}) (json.RawMessage, error) {
+	start := atomic.AddUint32(&api.next, 1)
+
+	var err error
+	for i := range api.clients {
+		client := api.clients[(int(start)+i)%len(api.clients)]
+
+		var result json.RawMessage
+		if err = client.CallContext(ctx, &result, method, args...); err == nil {
+			return result, nil
+		}
+
+		if _, ok := err.(rpc.Error); ok { // responded by rollup node
+			return nil, err
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, errors.WithMessage(err, "rollup node unavailable")
+}
+
+func (api *ethRollupAPI) restrictedMethods() []string {
+	return api.conf.Restricted
+}
+
+// allowed checks if the method is not restricted, or explicitly allowed by the allowlist of caller.
+func (api *ethRollupAPI) allowed(ctx context.Context, method string) bool {
+	return !isRestrictedRpcMethod(method, api.conf.Restricted) || isExplicitlyAllowed(ctx, method)
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEthRollupAPI(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		atomic.AddInt32(&calls, 1)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "kroma_rollupConfig":
+			resp["result"] = map[string]interface{}{"l2_chain_id": 255}
+		case "kroma_outputAtBlock":
+			resp["result"] = map[string]interface{}{"blockRef": map[string]interface{}{"number": req.Params[0]}}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	// the first rollup node is down, which is failed over
+	api, err := newRollupAPI(rollupConfig{
+		Namespace:      "kroma",
+		Nodes:          []string{"http://127.0.0.1:1", server.URL},
+		ConfigCacheTTL: time.Minute,
+		Restricted:     []string{"kroma_outputAtBlock"},
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, api.isRollupRpcMethod("kroma_syncStatus"))
+	assert.False(t, api.isRollupRpcMethod("eth_syncing"))
+	assert.False(t, (*ethRollupAPI)(nil).isRollupRpcMethod("kroma_syncStatus"))
+
+	for i := 0; i < 3; i++ {
+		config, err := api.RollupConfig(context.Background())
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"l2_chain_id": 255}`, string(config))
+	}
+
+	// rollup config cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// error responded by rollup node is not failed over
+	_, err = api.SyncStatus(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// restricted without allowlist
+	_, err = api.OutputAtBlock(context.Background(), 16)
+	assert.Equal(t, errMethodRestricted, err)
+
+	api.conf.Restricted = nil
+	output, err := api.OutputAtBlock(context.Background(), 16)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"blockRef": {"number": "0x10"}}`, string(output))
+}
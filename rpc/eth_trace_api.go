@@ -11,7 +11,13 @@ import (
 type ethTraceAPI struct{}
 
 func (api *ethTraceAPI) Block(ctx context.Context, blockNumOrHash types.BlockNumberOrHash) ([]types.LocalizedTrace, error) {
-	return GetEthClientFromContext(ctx).Trace.Blocks(blockNumOrHash)
+	w3c := GetEthClientFromContext(ctx)
+
+	if stream, ok := responseStreamFromContext(ctx); ok && stream.canProxy(w3c.URL) {
+		return nil, stream.proxy(ctx, w3c.URL, "trace_block", nil, blockNumOrHash)
+	}
+
+	return w3c.Trace.Blocks(blockNumOrHash)
 }
 
 func (api *ethTraceAPI) Filter(ctx context.Context, filter types.TraceFilter) ([]types.LocalizedTrace, error) {
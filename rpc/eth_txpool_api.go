@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+)
+
+// txPoolConfig configurations of evm space txpool RPC proxy.
+type txPoolConfig struct {
+	// node route group to serve txpool methods, or the default group if empty
+	RouteGroup string
+	// methods only available to callers whose allowlist explicitly allows them
+	Restricted []string `default:"[txpool_content]"`
+}
+
+func mustNewTxPoolConfigFromViper(key string) *txPoolConfig {
+	var conf txPoolConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	return &conf
+}
+
+// isEthTxPoolRpcMethod checks if the RPC method belongs to txpool namespace.
+func isEthTxPoolRpcMethod(method string) bool {
+	return strings.HasPrefix(method, "txpool_")
+}
+
+// ethTxPoolAPI provides evm space txpool RPC proxy API.
+type ethTxPoolAPI struct {
+	conf *txPoolConfig
+}
+
+// Content returns the pending and queued transactions of txpool.
+func (api *ethTxPoolAPI) Content(ctx context.Context) (json.RawMessage, error) {
+	return api.call(ctx, "txpool_content")
+}
+
+// Status returns the number of pending and queued transactions of txpool.
+func (api *ethTxPoolAPI) Status(ctx context.Context) (json.RawMessage, error) {
+	return api.call(ctx, "txpool_status")
+}
+
+// Inspect returns the textual summary of pending and queued transactions of txpool.
+func (api *ethTxPoolAPI) Inspect(ctx context.Context) (json.RawMessage, error) {
+	return api.call(ctx, "txpool_inspect")
+}
+
+func (api *ethTxPoolAPI) restrictedMethods() []string {
+	return api.conf.Restricted
+}
+
+func (api *ethTxPoolAPI) call(ctx context.Context, method string) (json.RawMessage, error) {
+	if !api.allowed(ctx, method) {
+		return nil, errMethodRestricted
+	}
+
+	var result json.RawMessage
+	err := GetEthClientFromContext(ctx).Provider().CallContext(ctx, &result, method)
+
+	return result, err
+}
+
+// allowed checks if the method is not restricted, or explicitly allowed by the allowlist of caller.
+func (api *ethTxPoolAPI) allowed(ctx context.Context, method string) bool {
+	return !isRestrictedRpcMethod(method, api.conf.Restricted) || isExplicitlyAllowed(ctx, method)
+}
@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEthTxPoolAPIAllowed(t *testing.T) {
+	api := &ethTxPoolAPI{conf: &txPoolConfig{Restricted: []string{"txpool_content"}}}
+
+	assert.True(t, api.allowed(context.Background(), "txpool_status"))
+	assert.True(t, api.allowed(context.Background(), "txpool_inspect"))
+
+	// restricted without allowlist
+	assert.False(t, api.allowed(context.Background(), "txpool_content"))
+
+	al := &acl.AllowList{AllowMethods: []string{"eth_*", "txpool_*"}}
+	assert.True(t, al.ExplicitlyAllows("txpool_content"))
+
+	al = &acl.AllowList{DisallowMethods: []string{"debug_*"}}
+	assert.False(t, al.ExplicitlyAllows("txpool_content"))
+}
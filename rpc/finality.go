@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	blockTagSafe      = "safe"
+	blockTagFinalized = "finalized"
+)
+
+var (
+	// fields of object param that accept block tag, eg., log filter or EIP-1898 block param
+	blockTagFields = []string{"fromBlock", "toBlock", "blockNumber"}
+)
+
+// finalityConfig configurations to resolve `safe` and `finalized` block tags by the sync status of
+// rollup nodes, rather than leaving each full node to answer independently.
+type finalityConfig struct {
+	// switch to turn on/off finality-aware block tag resolution
+	Enabled bool
+	// interval to poll the sync status of rollup nodes
+	Interval time.Duration `default:"2s"`
+	// timeout to poll the sync status of a rollup node
+	Timeout time.Duration `default:"3s"`
+}
+
+// finalityResolver resolves `safe` and `finalized` block tags into block numbers by the highest
+// L2 heads reported by rollup nodes, which never go backward. So, clients never see finality
+// regress regardless of which full node served the request.
+type finalityResolver struct {
+	conf   finalityConfig
+	rollup *ethRollupAPI
+
+	safe      uint64 // the latest safe L2 block number
+	finalized uint64 // the latest finalized L2 block number
+}
+
+func mustNewFinalityResolverFromViper(key string, rollup *ethRollupAPI) *finalityResolver {
+	var conf finalityConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if rollup == nil {
+		logrus.Fatal("Rollup RPC proxy must be enabled to resolve finality-aware block tags")
+	}
+
+	resolver := &finalityResolver{conf: conf, rollup: rollup}
+
+	logrus.WithField("config", conf).Info("Finality-aware block tag resolution enabled")
+
+	go resolver.run()
+
+	return resolver
+}
+
+// run polls the sync status of rollup nodes periodically, which lives as long as the process.
+func (r *finalityResolver) run() {
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+
+	r.poll()
+
+	for range ticker.C {
+		r.poll()
+	}
+}
+
+// poll requests the sync status of all rollup nodes concurrently.
+func (r *finalityResolver) poll() {
+	method := r.rollup.conf.Namespace + "_syncStatus"
+
+	var wg sync.WaitGroup
+	for i, client := range r.rollup.clients {
+		wg.Add(1)
+
+		go func(url string, client *rpc.Client) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), r.conf.Timeout)
+			defer cancel()
+
+			var status struct {
+				SafeL2 struct {
+					Number uint64 `json:"number"`
+				} `json:"safe_l2"`
+				FinalizedL2 struct {
+					Number uint64 `json:"number"`
+				} `json:"finalized_l2"`
+			}
+
+			if err := client.CallContext(ctx, &status, method); err != nil {
+				logrus.WithField("url", url).WithError(err).Debug("Failed to poll sync status of rollup node")
+				return
+			}
+
+			r.report(status.SafeL2.Number, status.FinalizedL2.Number)
+		}(r.rollup.conf.Nodes[i], client)
+	}
+
+	wg.Wait()
+}
+
+// report updates the safe and finalized L2 block numbers, which are only moved forward.
+func (r *finalityResolver) report(safe, finalized uint64) {
+	advance(&r.safe, safe)
+	advance(&r.finalized, finalized)
+}
+
+// advance atomically updates the block number if the new one is higher.
+func advance(addr *uint64, number uint64) {
+	for {
+		current := atomic.LoadUint64(addr)
+		if number <= current || atomic.CompareAndSwapUint64(addr, current, number) {
+			return
+		}
+	}
+}
+
+// resolve returns the block number of `safe` or `finalized` block tag, or false if not available.
+func (r *finalityResolver) resolve(tag string) (uint64, bool) {
+	var number uint64
+
+	switch tag {
+	case blockTagSafe:
+		number = atomic.LoadUint64(&r.safe)
+	case blockTagFinalized:
+		number = atomic.LoadUint64(&r.finalized)
+	}
+
+	return number, number > 0
+}
+
+// resolveBlockTags replaces `safe` and `finalized` block tags in the positional params with block
+// numbers, including the block tag fields of object params. Returns false if nothing replaced.
+func (r *finalityResolver) resolveBlockTags(params json.RawMessage) (json.RawMessage, bool) {
+	if !bytes.Contains(params, []byte(`"`+blockTagSafe+`"`)) &&
+		!bytes.Contains(params, []byte(`"`+blockTagFinalized+`"`)) {
+		return params, false
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil {
+		return params, false
+	}
+
+	var replaced bool
+	for i, arg := range args {
+		if resolved, ok := r.resolveBlockTag(arg); ok {
+			args[i], replaced = resolved, true
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(arg, &fields); err != nil || fields == nil {
+			continue
+		}
+
+		var fieldReplaced bool
+		for _, field := range blockTagFields {
+			if resolved, ok := r.resolveBlockTag(fields[field]); ok {
+				fields[field], fieldReplaced = resolved, true
+			}
+		}
+
+		if fieldReplaced {
+			args[i], _ = json.Marshal(fields)
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		return params, false
+	}
+
+	resolved, err := json.Marshal(args)
+	if err != nil {
+		return params, false
+	}
+
+	return resolved, true
+}
+
+// resolveBlockTag resolves the JSON block tag into hex encoded block number.
+func (r *finalityResolver) resolveBlockTag(value json.RawMessage) (json.RawMessage, bool) {
+	var tag string
+	if len(value) == 0 || value[0] != '"' || json.Unmarshal(value, &tag) != nil {
+		return nil, false
+	}
+
+	number, ok := r.resolve(tag)
+	if !ok {
+		return nil, false
+	}
+
+	resolved, _ := json.Marshal(hexutil.Uint64(number))
+
+	return resolved, true
+}
+
+// Call resolves `safe` and `finalized` block tags of evm space requests, which passes through if
+// resolver is nil or the request is of an additional evm chain.
+func (r *finalityResolver) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if r == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if _, ok := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); !ok {
+			return next(ctx, msg)
+		}
+
+		if chain, ok := handlers.GetChainFromContext(ctx); ok && len(chain) > 0 {
+			return next(ctx, msg)
+		}
+
+		params, ok := r.resolveBlockTags(msg.Params)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		resolved := *msg
+		resolved.Params = params
+
+		return next(ctx, &resolved)
+	}
+}
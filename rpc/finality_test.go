@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinalityResolverReport(t *testing.T) {
+	resolver := &finalityResolver{}
+
+	_, ok := resolver.resolve(blockTagSafe)
+	assert.False(t, ok)
+
+	resolver.report(100, 80)
+	resolver.report(90, 70) // never goes backward
+
+	number, ok := resolver.resolve(blockTagSafe)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(100), number)
+
+	number, ok = resolver.resolve(blockTagFinalized)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(80), number)
+
+	_, ok = resolver.resolve("latest")
+	assert.False(t, ok)
+}
+
+func TestFinalityResolverResolveBlockTags(t *testing.T) {
+	resolver := &finalityResolver{}
+
+	// not resolved yet
+	_, ok := resolver.resolveBlockTags(json.RawMessage(`["safe", false]`))
+	assert.False(t, ok)
+
+	resolver.report(0x100, 0x80)
+
+	params, ok := resolver.resolveBlockTags(json.RawMessage(`["safe", false]`))
+	assert.True(t, ok)
+	assert.JSONEq(t, `["0x100", false]`, string(params))
+
+	params, ok = resolver.resolveBlockTags(json.RawMessage(`[{"to": "0x1"}, "finalized"]`))
+	assert.True(t, ok)
+	assert.JSONEq(t, `[{"to": "0x1"}, "0x80"]`, string(params))
+
+	params, ok = resolver.resolveBlockTags(json.RawMessage(`[{"fromBlock": "finalized", "toBlock": "safe", "topics": []}]`))
+	assert.True(t, ok)
+	assert.JSONEq(t, `[{"fromBlock": "0x80", "toBlock": "0x100", "topics": []}]`, string(params))
+
+	_, ok = resolver.resolveBlockTags(json.RawMessage(`["0x1", "latest"]`))
+	assert.False(t, ok)
+
+	// block tag in data field is untouched
+	_, ok = resolver.resolveBlockTags(json.RawMessage(`[{"data": "safe"}, "latest"]`))
+	assert.False(t, ok)
+}
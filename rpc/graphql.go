@@ -0,0 +1,204 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	graphqlNamespace   = "graphql"
+	graphqlPathSegment = "graphql"
+
+	rpcMethodGraphqlQuery = "graphql_query"
+)
+
+// graphqlConfig configurations to serve GraphQL endpoint for evm space.
+type graphqlConfig struct {
+	// switch to turn on/off GraphQL endpoint
+	Enabled bool
+	// GraphQL path of full node relative to the RPC URL
+	UpstreamPath string `default:"/graphql"`
+	// timeout to query full node
+	Timeout time.Duration `default:"10s"`
+}
+
+func mustNewGraphqlConfigFromViper() *graphqlConfig {
+	var conf graphqlConfig
+	viper.MustUnmarshalKey("ethrpc.graphql", &conf)
+
+	if conf.Enabled && !strings.HasPrefix(conf.UpstreamPath, "/") {
+		logrus.WithField("upstreamPath", conf.UpstreamPath).
+			Fatal("Invalid upstream path configured for GraphQL")
+	}
+
+	return &conf
+}
+
+// graphqlRequest is the GraphQL over HTTP request (EIP-1767).
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphqlAPI proxies GraphQL queries to the GraphQL server of full nodes.
+type graphqlAPI struct {
+	upstreamPath string
+	client       *http.Client
+}
+
+func newGraphqlAPI(conf *graphqlConfig) *graphqlAPI {
+	return &graphqlAPI{
+		upstreamPath: conf.UpstreamPath,
+		client:       &http.Client{Timeout: conf.Timeout},
+	}
+}
+
+// Query delegates GraphQL query to full node, and returns the GraphQL response as it is,
+// including the `errors` field if any.
+func (api *graphqlAPI) Query(ctx context.Context, req graphqlRequest) (json.RawMessage, error) {
+	if len(req.Query) == 0 {
+		return nil, errInvalidGraphqlRequest
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	w3c := GetEthClientFromContext(ctx)
+	url := strings.TrimRight(w3c.URL, "/") + api.upstreamPath
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.client.Do(httpReq)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to query full node GraphQL")
+	}
+	defer resp.Body.Close()
+
+	result, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read full node GraphQL response")
+	}
+
+	// GraphQL server may respond errors with 400 status code
+	if !json.Valid(result) {
+		return nil, errors.Errorf("full node GraphQL unavailable with status %v", resp.Status)
+	}
+
+	return result, nil
+}
+
+var errInvalidGraphqlRequest = errors.New("GraphQL query not specified")
+
+// graphqlMiddleware serves GraphQL requests at `/graphql` or `/${accessToken}/graphql` by
+// translating them into `graphql_query` JSON-RPC calls, so that the same access control, rate
+// limit, metering and node routing apply as other JSON-RPC methods.
+func graphqlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := parseGraphqlPath(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// CORS preflight handled by RPC server
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, rewriteShimRequest(r, token, nil))
+			return
+		}
+
+		gqlReq, err := parseGraphqlRequest(r)
+		if err != nil {
+			writeGraphqlError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		resp, rw, err := serveShimCall(next, r, token, rpcMethodGraphqlQuery, gqlReq)
+		if err != nil {
+			writeGraphqlError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if resp == nil { // rejected by HTTP middlewares
+			rw.writeTo(w)
+			return
+		}
+
+		if resp.Error != nil {
+			writeGraphqlError(w, http.StatusOK, resp.Error.Message)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp.Result)
+	})
+}
+
+// parseGraphqlPath parses the access token (optional) from GraphQL request path.
+func parseGraphqlPath(path string) (token string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(segments) == 1 && segments[0] == graphqlPathSegment:
+		return "", true
+	case len(segments) == 2 && segments[1] == graphqlPathSegment:
+		return segments[0], true
+	default:
+		return "", false
+	}
+}
+
+// parseGraphqlRequest parses GraphQL request from query string for GET method, or from
+// JSON body for POST method.
+func parseGraphqlRequest(r *http.Request) (*graphqlRequest, error) {
+	var req graphqlRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+
+		if vars := query.Get("variables"); len(vars) > 0 {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				return nil, errors.WithMessage(err, "invalid variables")
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, errors.WithMessage(err, "invalid request body")
+		}
+	default:
+		return nil, errors.Errorf("method %v not allowed", r.Method)
+	}
+
+	if len(req.Query) == 0 {
+		return nil, errInvalidGraphqlRequest
+	}
+
+	return &req, nil
+}
+
+func writeGraphqlError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
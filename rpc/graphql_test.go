@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGraphqlPath(t *testing.T) {
+	testCases := []struct {
+		path  string
+		token string
+		ok    bool
+	}{
+		{"/graphql", "", true},
+		{"/graphql/", "", true},
+		{"/key/graphql", "key", true},
+		{"/", "", false},
+		{"/key", "", false},
+		{"/key/graphql/extra", "", false},
+	}
+
+	for _, tc := range testCases {
+		token, ok := parseGraphqlPath(tc.path)
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.token, token, tc.path)
+	}
+}
+
+func TestGraphqlMiddleware(t *testing.T) {
+	handler := graphqlMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var msg struct {
+			Method string            `json:"method"`
+			Params []*graphqlRequest `json:"params"`
+		}
+		require.NoError(t, json.Unmarshal(body, &msg))
+
+		if msg.Method != rpcMethodGraphqlQuery || r.URL.Path != "/key" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+
+		assert.Equal(t, "{ block { number } }", msg.Params[0].Query)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"data":{"block":{"number":1}}}}`))
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	// POST
+	body := `{"query":"{ block { number } }"}`
+	resp := serve(httptest.NewRequest(http.MethodPost, "/key/graphql", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"data":{"block":{"number":1}}}`, resp.Body.String())
+
+	// GET
+	resp = serve(httptest.NewRequest(http.MethodGet, "/key/graphql?query=%7B%20block%20%7B%20number%20%7D%20%7D", nil))
+	assert.JSONEq(t, `{"data":{"block":{"number":1}}}`, resp.Body.String())
+
+	// JSON-RPC error
+	resp = serve(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"errors":[{"message":"method not found"}]}`, resp.Body.String())
+
+	// rejected by HTTP middlewares, which is written back as it is
+	resp = serve(httptest.NewRequest(http.MethodPost, "/denied/graphql", strings.NewReader(body)))
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("Retry-After"))
+	assert.Equal(t, "access denied\n", resp.Body.String())
+
+	// bad request
+	resp = serve(httptest.NewRequest(http.MethodPost, "/key/graphql", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
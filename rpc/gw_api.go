@@ -0,0 +1,227 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	rpcMethodGwGetLogs = "gw_getLogs"
+)
+
+var (
+	errInvalidLogsCursor = errors.New("invalid cursor, which must be returned by the previous page of the same filter")
+)
+
+// gwLogsConfig configurations of the paginated `gw_getLogs` extension.
+type gwLogsConfig struct {
+	// default number of logs per page
+	PageSize int `default:"1000"`
+	// max number of logs per page
+	MaxPageSize int `default:"10000"`
+	// number of blocks queried at a time, which is halved if too many logs matched
+	BlockChunk uint64 `default:"1000"`
+	// max duration to query a page, beyond which the partial page is returned with cursor
+	Timeout time.Duration `default:"10s"`
+}
+
+// gwLogsOptions pagination options of `gw_getLogs`.
+type gwLogsOptions struct {
+	// continuation token returned by the previous page, or empty for the first page
+	Cursor string `json:"cursor"`
+	// number of logs per page
+	PageSize int `json:"pageSize"`
+}
+
+// gwLogsPage a page of event logs.
+type gwLogsPage struct {
+	Logs []web3Types.Log `json:"logs"`
+	// continuation token to fetch the next page, or empty if no more logs
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// gwLogsCursor position of the next log to fetch, which is encoded as continuation token.
+type gwLogsCursor struct {
+	Block    uint64 `json:"b"`
+	LogIndex uint64 `json:"i"`
+	ToBlock  uint64 `json:"t"` // resolved to block of the first page, eg., `latest`
+	Filter   string `json:"f"` // hash of filter criteria
+}
+
+func (c *gwLogsCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeGwLogsCursor(token string) (*gwLogsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidLogsCursor
+	}
+
+	var cursor gwLogsCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errInvalidLogsCursor
+	}
+
+	return &cursor, nil
+}
+
+// gwFilterHash returns the hash of log filter criteria except block range.
+func gwFilterHash(fq *web3Types.FilterQuery) string {
+	data, _ := json.Marshal([]interface{}{fq.FromBlock, fq.Addresses, fq.Topics})
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:8])
+}
+
+// gwAPI provides gateway extension APIs in evm space.
+type gwAPI struct {
+	eth  *ethAPI
+	conf gwLogsConfig
+}
+
+func newGwAPI(eth *ethAPI) *gwAPI {
+	var conf gwLogsConfig
+	viper.MustUnmarshalKey("ethrpc.gwLogs", &conf)
+
+	return &gwAPI{eth: eth, conf: conf}
+}
+
+// GetLogs returns a page of event logs matching the block range filter, along with the cursor
+// to fetch the next page. Huge block range is split into chunks internally, so that millions of
+// logs could be fetched page by page without hitting response size limits or upstream timeouts.
+func (api *gwAPI) GetLogs(
+	ctx context.Context, fq web3Types.FilterQuery, opts *gwLogsOptions,
+) (*gwLogsPage, error) {
+	if opts == nil {
+		opts = &gwLogsOptions{}
+	}
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = api.conf.PageSize
+	}
+
+	if pageSize > api.conf.MaxPageSize {
+		return nil, errors.Errorf("page size exceeds the max %v allowed", api.conf.MaxPageSize)
+	}
+
+	if fq.BlockHash != nil {
+		return nil, errors.New("block hash filter not supported, please use eth_getLogs instead")
+	}
+
+	w3c := GetEthClientFromContext(ctx)
+
+	cursor, err := api.resolveCursor(w3c, &fq, opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.getLogsPage(ctx, cursor, pageSize, func(from, to uint64) ([]web3Types.Log, error) {
+		chunk := fq
+		fromBlock, toBlock := web3Types.BlockNumber(from), web3Types.BlockNumber(to)
+		chunk.FromBlock, chunk.ToBlock = &fromBlock, &toBlock
+
+		return api.eth.getLogs(ctx, w3c, &chunk, rpcMethodGwGetLogs)
+	})
+}
+
+// resolveCursor decodes the cursor, or resolves the block range of the first page.
+func (api *gwAPI) resolveCursor(w3c *node.Web3goClient, fq *web3Types.FilterQuery, token string) (*gwLogsCursor, error) {
+	if len(token) > 0 {
+		cursor, err := decodeGwLogsCursor(token)
+		if err != nil || cursor.Filter != gwFilterHash(fq) {
+			return nil, errInvalidLogsCursor
+		}
+
+		return cursor, nil
+	}
+
+	resolved := *fq
+	if err := NormalizeEthLogFilter(w3c.Client, LogFilterTypeBlockRange, &resolved, api.eth.hardforkBlockNumber); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateEthLogFilter(LogFilterTypeBlockRange, &resolved); err != nil {
+		return nil, err
+	}
+
+	return &gwLogsCursor{
+		Block:   uint64(*resolved.FromBlock),
+		ToBlock: uint64(*resolved.ToBlock),
+		Filter:  gwFilterHash(fq),
+	}, nil
+}
+
+// getLogsPage fetches logs chunk by chunk from the cursor, until page is full or timed out.
+func (api *gwAPI) getLogsPage(
+	ctx context.Context, cursor *gwLogsCursor, pageSize int, getLogs func(from, to uint64) ([]web3Types.Log, error),
+) (*gwLogsPage, error) {
+	page := &gwLogsPage{Logs: []web3Types.Log{}}
+	deadline := time.Now().Add(api.conf.Timeout)
+	chunk := api.conf.BlockChunk
+
+	for pos := cursor.Block; pos <= cursor.ToBlock; {
+		if len(page.Logs) > 0 && time.Now().After(deadline) { // return the partial page
+			next := *cursor
+			next.Block, next.LogIndex = pos, 0
+			page.Cursor = next.encode()
+
+			return page, nil
+		}
+
+		to := pos + chunk - 1
+		if to > cursor.ToBlock || to < pos {
+			to = cursor.ToBlock
+		}
+
+		logs, err := getLogs(pos, to)
+		if err == store.ErrGetLogsResultSetTooLarge && to > pos { // narrow down the chunk
+			chunk = (to - pos + 1) / 2
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			// skip logs fetched by previous page
+			if log.BlockNumber == cursor.Block && uint64(log.Index) < cursor.LogIndex {
+				continue
+			}
+
+			if len(page.Logs) == pageSize {
+				next := *cursor
+				next.Block, next.LogIndex = log.BlockNumber, uint64(log.Index)
+				page.Cursor = next.encode()
+
+				return page, nil
+			}
+
+			page.Logs = append(page.Logs, log)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pos = to + 1
+		if pos == 0 { // overflow
+			break
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGwLogsCursor(t *testing.T) {
+	cursor := &gwLogsCursor{Block: 100, LogIndex: 3, ToBlock: 200, Filter: "abc"}
+
+	decoded, err := decodeGwLogsCursor(cursor.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = decodeGwLogsCursor("not a cursor")
+	assert.Equal(t, errInvalidLogsCursor, err)
+}
+
+func TestGwGetLogsPage(t *testing.T) {
+	api := &gwAPI{conf: gwLogsConfig{BlockChunk: 10, Timeout: time.Minute}}
+
+	// 2 logs per block in range [0, 99], and at most 6 logs per query
+	getLogs := func(from, to uint64) ([]web3Types.Log, error) {
+		if (to-from+1)*2 > 6 {
+			return nil, store.ErrGetLogsResultSetTooLarge
+		}
+
+		var logs []web3Types.Log
+		for bn := from; bn <= to; bn++ {
+			logs = append(logs, web3Types.Log{BlockNumber: bn, Index: 0}, web3Types.Log{BlockNumber: bn, Index: 1})
+		}
+
+		return logs, nil
+	}
+
+	cursor := &gwLogsCursor{ToBlock: 99}
+
+	var fetched []web3Types.Log
+	for pages := 0; ; pages++ {
+		page, err := api.getLogsPage(context.Background(), cursor, 7, getLogs)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Logs), 7)
+
+		fetched = append(fetched, page.Logs...)
+
+		if len(page.Cursor) == 0 {
+			assert.Equal(t, 28, pages)
+			break
+		}
+
+		cursor, err = decodeGwLogsCursor(page.Cursor)
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, fetched, 200)
+	for i, log := range fetched {
+		assert.Equal(t, uint64(i/2), log.BlockNumber)
+		assert.Equal(t, uint(i%2), log.Index)
+	}
+}
@@ -3,13 +3,12 @@ package handler
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"strings"
 
 	"github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc/throttle"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	"github.com/Conflux-Chain/go-conflux-sdk/types"
 	"github.com/go-redis/redis/v8"
@@ -63,21 +62,12 @@ func (h *CfxPrunedLogsHandler) GetLogs(ctx context.Context, filter types.LogFilt
 }
 
 func (h *CfxPrunedLogsHandler) getLogsByUser(ctx context.Context, filter types.LogFilter) ([]types.Log, bool, error) {
-	request, ok := ctx.Value("request").(*http.Request)
-	if !ok {
-		// WebSocket have no http.Request object.
-		return nil, false, nil
-	}
-
-	if request.URL == nil {
+	// access token is injected for both HTTP and websocket requests
+	key, ok := handlers.GetAccessTokenFromContext(ctx)
+	if !ok || len(key) == 0 {
 		return nil, false, nil
 	}
 
-	key := strings.TrimLeft(request.URL.Path, "/")
-	if idx := strings.Index(key, "/"); idx > 0 {
-		key = key[:idx]
-	}
-
 	user, ok, err := h.store.GetUserByKey(key)
 	if err != nil {
 		logrus.WithError(err).WithField("key", key).Warn("Failed to get user by key")
@@ -3,25 +3,50 @@ package handler
 import (
 	"context"
 	"errors"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Conflux-Chain/confura/rpc/ethbridge"
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/store/mysql"
 	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
 	"github.com/openweb3/web3go/client"
 	"github.com/openweb3/web3go/types"
+	"github.com/sirupsen/logrus"
 )
 
+// EthLogsApiConfig configurations to get evm space event logs from store.
+type EthLogsApiConfig struct {
+	// whether to get event logs of finalized blocks only from store, and the unfinalized tail
+	// from fullnode
+	FinalizedOnly bool `default:"true"`
+	// expiration of the cached finalized block number
+	FinalizedTTL time.Duration `default:"1s"`
+}
+
 // EthLogsApiHandler RPC handler to get evm space event logs from store or fullnode.
 type EthLogsApiHandler struct {
-	ms *mysql.MysqlStore
+	ms   *mysql.MysqlStore
+	conf EthLogsApiConfig
 
 	networkId atomic.Value
+
+	mu                sync.Mutex
+	finalized         uint64
+	finalizedExpireAt time.Time
+}
+
+func MustNewEthLogsApiHandlerFromViper(ms *mysql.MysqlStore) *EthLogsApiHandler {
+	var conf EthLogsApiConfig
+	viper.MustUnmarshalKey("ethrpc.logStore", &conf)
+
+	return NewEthLogsApiHandler(ms, conf)
 }
 
-func NewEthLogsApiHandler(ms *mysql.MysqlStore) *EthLogsApiHandler {
-	return &EthLogsApiHandler{ms: ms}
+func NewEthLogsApiHandler(ms *mysql.MysqlStore, conf EthLogsApiConfig) *EthLogsApiHandler {
+	return &EthLogsApiHandler{ms: ms, conf: conf}
 }
 
 func (handler *EthLogsApiHandler) GetLogs(
@@ -130,7 +155,7 @@ func (handler *EthLogsApiHandler) splitLogFilter(
 	eth *client.RpcEthClient,
 	filter *types.FilterQuery,
 ) (*store.LogFilter, *types.FilterQuery, error) {
-	maxBlock, ok, err := handler.ms.MaxEpoch()
+	maxBlock, ok, err := handler.maxStoreBlock(eth)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -219,6 +244,52 @@ func (handler *EthLogsApiHandler) splitLogFilterByBlockRange(
 	return &dbFilter, &fnFilter, nil
 }
 
+// maxStoreBlock returns the max block number of which event logs are served from store, which
+// is capped by the finalized block if configured.
+func (handler *EthLogsApiHandler) maxStoreBlock(eth *client.RpcEthClient) (uint64, bool, error) {
+	maxBlock, ok, err := handler.ms.MaxEpoch()
+	if err != nil || !ok || !handler.conf.FinalizedOnly {
+		return maxBlock, ok, err
+	}
+
+	finalized, err := handler.finalizedBlock(eth)
+	if err != nil {
+		// delegate to fullnode if the finalized block is unknown
+		logrus.WithError(err).Debug("Failed to get finalized block for event logs from store")
+		return 0, false, nil
+	}
+
+	if finalized < maxBlock {
+		maxBlock = finalized
+	}
+
+	return maxBlock, true, nil
+}
+
+// finalizedBlock returns the finalized block number, which is cached for a while.
+func (handler *EthLogsApiHandler) finalizedBlock(eth *client.RpcEthClient) (uint64, error) {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	if time.Now().Before(handler.finalizedExpireAt) {
+		return handler.finalized, nil
+	}
+
+	block, err := eth.BlockByNumber(types.FinalizedBlockNumber, false)
+	if err != nil {
+		return 0, err
+	}
+
+	if block == nil || block.Number == nil {
+		return 0, errors.New("unknown finalized block")
+	}
+
+	handler.finalized = block.Number.Uint64()
+	handler.finalizedExpireAt = time.Now().Add(handler.conf.FinalizedTTL)
+
+	return handler.finalized, nil
+}
+
 func (handler *EthLogsApiHandler) GetNetworkId(eth *client.RpcEthClient) (uint32, error) {
 	if val := handler.networkId.Load(); val != nil {
 		return val.(uint32), nil
@@ -0,0 +1,270 @@
+package rpc
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// min number of latency samples to estimate the hedge delay by percentile
+	hedgeMinSamples = 16
+	// number of latency samples observed to refresh the hedge delay
+	hedgeRefreshSamples = 16
+)
+
+// hedgeConfig configurations of hedged requests, by which the same request is fired at another
+// full node of the same group if the primary one hasn't responded within the hedge delay, and
+// the response that comes first is returned.
+type hedgeConfig struct {
+	// switch to turn on/off hedged requests
+	Enabled bool
+	// cheap read methods to hedge
+	Methods []string
+	// percentile of the recent latencies of method as hedge delay, in range (0, 1)
+	Percentile float64 `default:"0.95"`
+	// min hedge delay
+	MinDelay time.Duration `default:"20ms"`
+	// max hedge delay, which is also used before enough latencies observed
+	MaxDelay time.Duration `default:"1s"`
+	// number of recent latencies to observe per method
+	Window int `default:"256"`
+	// max number of in-flight hedged requests, beyond which requests are not hedged
+	MaxInflight int64 `default:"100"`
+	// max attempts to pick another full node of the same group
+	Attempts int `default:"3"`
+}
+
+// latencyWindow keeps the recent latencies of a method to estimate the hedge delay.
+type latencyWindow struct {
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int // index to put the next sample
+	observed int // number of samples observed since the delay refreshed
+	delay    time.Duration
+}
+
+// observe records latency, and refreshes the delay once enough samples observed.
+func (w *latencyWindow) observe(latency time.Duration, conf *hedgeConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < conf.Window {
+		w.samples = append(w.samples, latency)
+	} else {
+		w.samples[w.next] = latency
+	}
+
+	w.next = (w.next + 1) % conf.Window
+
+	if w.observed++; w.observed < hedgeRefreshSamples || len(w.samples) < hedgeMinSamples {
+		return
+	}
+
+	w.observed = 0
+
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	delay := sorted[int(float64(len(sorted)-1)*conf.Percentile)]
+	if delay < conf.MinDelay {
+		delay = conf.MinDelay
+	}
+
+	if delay > conf.MaxDelay {
+		delay = conf.MaxDelay
+	}
+
+	w.delay = delay
+}
+
+// hedgeDelay returns the hedge delay, or the max delay if not enough samples observed.
+func (w *latencyWindow) hedgeDelay(conf *hedgeConfig) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.delay == 0 {
+		return conf.MaxDelay
+	}
+
+	return w.delay
+}
+
+// hedgeResult response of the primary or hedged request.
+type hedgeResult struct {
+	resp   *rpc.JsonRpcMessage
+	hedged bool
+}
+
+// requestHedger hedges requests of slow full nodes to cut tail latency, with the hedge rate and
+// win rate metered per method.
+type requestHedger struct {
+	conf     hedgeConfig
+	evm      bool                      // whether to hedge requests of evm space or core space
+	windows  map[string]*latencyWindow // method => recent latencies
+	inflight int64
+}
+
+func mustNewRequestHedgerFromViper(key string, evm bool) *requestHedger {
+	var conf hedgeConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if len(conf.Methods) == 0 || conf.Percentile <= 0 || conf.Percentile >= 1 ||
+		conf.Window <= 0 || conf.Attempts <= 0 || conf.MinDelay > conf.MaxDelay {
+		logrus.WithField("config", conf).Fatal("Invalid hedged requests config")
+	}
+
+	logrus.WithField("config", conf).Info("Hedged requests to full nodes enabled")
+
+	return newRequestHedger(conf, evm)
+}
+
+func newRequestHedger(conf hedgeConfig, evm bool) *requestHedger {
+	hedger := &requestHedger{conf: conf, evm: evm, windows: make(map[string]*latencyWindow)}
+
+	for _, method := range conf.Methods {
+		hedger.windows[method] = &latencyWindow{}
+	}
+
+	return hedger
+}
+
+// Call hedges requests of the configured methods, which passes through if hedger is nil. Note,
+// it must be executed after the full node client injected into context.
+func (h *requestHedger) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if h == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		window, ok := h.windows[msg.Method]
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		// the same response stream never written by both full nodes
+		if _, ok := responseStreamFromContext(ctx); ok {
+			return next(ctx, msg)
+		}
+
+		if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != h.evm {
+			return next(ctx, msg)
+		}
+
+		primary, ok := clientUrlFromContext(ctx)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		// buffered so that the late one never blocks
+		results := make(chan hedgeResult, 2)
+
+		// each attempt is cancellable, so that the losing one is aborted once returned
+		primaryCtx, cancelPrimary := attemptContext(ctx)
+		defer cancelPrimary()
+
+		start := time.Now()
+		go func() {
+			resp := safeCall(primaryCtx, next, msg)
+			// lower bound of latency if aborted as the losing one
+			window.observe(time.Since(start), &h.conf)
+			results <- hedgeResult{resp: resp}
+		}()
+
+		timer := time.NewTimer(window.hedgeDelay(&h.conf))
+		defer timer.Stop()
+
+		select {
+		case result := <-results:
+			metrics.Registry.RPC.Percentage(msg.Method, "hedged").Mark(false)
+			return result.resp
+		case <-timer.C:
+		}
+
+		hedgeCtx, ok := h.hedgeContext(ctx, primary)
+		metrics.Registry.RPC.Percentage(msg.Method, "hedged").Mark(ok)
+
+		if !ok {
+			return (<-results).resp
+		}
+
+		hedgeCtx, cancelHedge := attemptContext(hedgeCtx)
+		defer cancelHedge()
+
+		go func() {
+			defer atomic.AddInt64(&h.inflight, -1)
+			results <- hedgeResult{resp: safeCall(hedgeCtx, next, msg), hedged: true}
+		}()
+
+		result := <-results
+		if result.resp == nil || result.resp.Error != nil { // wait for the other one if failed
+			if other := <-results; other.resp != nil && other.resp.Error == nil {
+				result = other
+			}
+		}
+
+		metrics.Registry.RPC.Percentage(msg.Method, "hedgeWon").Mark(result.hedged)
+
+		return result.resp
+	}
+}
+
+// hedgeContext injects client of another full node in the same group into context for hedged
+// request, or returns false if too many in-flight hedged requests or no other full node available.
+// Note, the in-flight counter is increased if succeeded.
+func (h *requestHedger) hedgeContext(ctx context.Context, primary string) (context.Context, bool) {
+	if atomic.AddInt64(&h.inflight, 1) > h.conf.MaxInflight {
+		atomic.AddInt64(&h.inflight, -1)
+		return nil, false
+	}
+
+	client, ok := peerClient(ctx.Value(ctxKeyClientProvider), GetClientGroupFromContext(ctx), primary, h.conf.Attempts)
+	if !ok {
+		atomic.AddInt64(&h.inflight, -1)
+		return nil, false
+	}
+
+	return context.WithValue(ctx, ctxKeyClient, bindClient(ctx, client)), true
+}
+
+// attemptContext derives a cancellable context of request attempt, to which the full node client
+// in context is bound, so that the in-flight request to full node is aborted once cancelled.
+func attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	client := bindClientContext(attemptCtx, ctx.Value(ctxKeyClient))
+
+	return context.WithValue(attemptCtx, ctxKeyClient, client), cancel
+}
+
+// safeCall calls the handler in a separate goroutine, in which panic is recovered as error
+// response, since it is out of the scope of panic recovery middleware.
+func safeCall(ctx context.Context, next rpc.HandleCallMsgFunc, msg *rpc.JsonRpcMessage) (resp *rpc.JsonRpcMessage) {
+	defer func() {
+		if err := recover(); err != nil {
+			debug.PrintStack()
+
+			logrus.WithFields(logrus.Fields{
+				"method": msg.Method, "panicErr": err,
+			}).Error("Hedged request panic recovered")
+
+			resp = msg.ErrorResponse(errors.New("internal server error"))
+		}
+	}()
+
+	return next(ctx, msg)
+}
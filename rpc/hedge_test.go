@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	conf := &hedgeConfig{Percentile: 0.9, MinDelay: 5 * time.Millisecond, MaxDelay: time.Second, Window: 32}
+	window := &latencyWindow{}
+
+	// not enough samples
+	assert.Equal(t, time.Second, window.hedgeDelay(conf))
+
+	for i := 1; i <= 96; i++ {
+		window.observe(time.Duration(i)*time.Millisecond, conf)
+	}
+
+	// percentile of the recent 32 samples, i.e., 65ms ~ 96ms
+	assert.Equal(t, 92*time.Millisecond, window.hedgeDelay(conf))
+
+	for i := 0; i < 32; i++ {
+		window.observe(time.Millisecond, conf)
+	}
+
+	assert.Equal(t, 5*time.Millisecond, window.hedgeDelay(conf))
+}
+
+func TestRequestHedger(t *testing.T) {
+	urls := []string{"http://127.0.0.1:18545", "http://127.0.0.1:28545"}
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: urls,
+	}))
+
+	primary, err := provider.GetClientByURL(urls[0], node.GroupEthHttp)
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+	ctx = context.WithValue(ctx, ctxKeyClient, primary)
+
+	hedger := newRequestHedger(hedgeConfig{
+		Methods: []string{"eth_getBalance"}, Percentile: 0.95, MaxDelay: 20 * time.Millisecond,
+		Window: 16, MaxInflight: 1, Attempts: 100,
+	}, true)
+
+	// primary full node is slow
+	handler := hedger.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		url := GetEthClientFromContext(ctx).URL
+		if url == urls[0] {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		result, _ := json.Marshal(url)
+		return &rpc.JsonRpcMessage{Result: result}
+	})
+
+	resp := handler(ctx, &rpc.JsonRpcMessage{Method: "eth_getBalance"})
+	assert.JSONEq(t, `"`+urls[1]+`"`, string(resp.Result))
+
+	// not hedged for other methods
+	resp = handler(ctx, &rpc.JsonRpcMessage{Method: "eth_call"})
+	assert.JSONEq(t, `"`+urls[0]+`"`, string(resp.Result))
+}
+
+func TestRequestHedgerCancelLoser(t *testing.T) {
+	urls := []string{"http://127.0.0.1:18545", "http://127.0.0.1:28545"}
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: urls,
+	}))
+
+	primary, err := provider.GetClientByURL(urls[0], node.GroupEthHttp)
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	ctx = context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp)
+	ctx = context.WithValue(ctx, ctxKeyClient, primary)
+
+	hedger := newRequestHedger(hedgeConfig{
+		Methods: []string{"eth_getBalance"}, Percentile: 0.95, MaxDelay: 20 * time.Millisecond,
+		Window: 16, MaxInflight: 1, Attempts: 100,
+	}, true)
+
+	// primary full node is stuck until request aborted
+	aborted := make(chan error, 1)
+	handler := hedger.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		url := GetEthClientFromContext(ctx).URL
+		if url == urls[0] {
+			select {
+			case <-ctx.Done():
+				aborted <- ctx.Err()
+			case <-time.After(5 * time.Second):
+				aborted <- nil
+			}
+		}
+
+		result, _ := json.Marshal(url)
+		return &rpc.JsonRpcMessage{Result: result}
+	})
+
+	resp := handler(ctx, &rpc.JsonRpcMessage{Method: "eth_getBalance"})
+	assert.JSONEq(t, `"`+urls[1]+`"`, string(resp.Result))
+
+	// the losing request aborted once hedged one won
+	select {
+	case err := <-aborted:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "losing request not aborted")
+	}
+
+	// context of caller never cancelled
+	assert.NoError(t, ctx.Err())
+}
@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// shimResponse is the JSON-RPC response of HTTP shim call.
+type shimResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// serveShimCall translates HTTP request of other protocols (eg., GraphQL or REST) into JSON-RPC
+// call to the access token path, and serves it by the JSON-RPC handler so that all the HTTP and
+// RPC middlewares apply. It returns nil response if rejected by HTTP middlewares without JSON-RPC
+// response, in which case the buffered response should be written back as it is.
+func serveShimCall(
+	next http.Handler, r *http.Request, token, method string, params ...interface{},
+) (*shimResponse, *shimResponseWriter, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw := newShimResponseWriter()
+	next.ServeHTTP(rw, rewriteShimRequest(r, token, body))
+
+	var resp shimResponse
+	if err := json.Unmarshal(rw.body.Bytes(), &resp); err != nil {
+		return nil, rw, nil
+	}
+
+	return &resp, rw, nil
+}
+
+// rewriteShimRequest rewrites HTTP request as JSON-RPC request to the access token path.
+func rewriteShimRequest(r *http.Request, token string, body []byte) *http.Request {
+	req := r.Clone(r.Context())
+	req.URL.Path = "/" + token
+	req.URL.RawPath = ""
+	req.URL.RawQuery = ""
+
+	if body != nil {
+		req.Method = http.MethodPost
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req
+}
+
+// shimResponseWriter is the http.ResponseWriter to buffer the response of shim call.
+type shimResponseWriter struct {
+	header http.Header
+	code   int // zero until header written
+	body   bytes.Buffer
+}
+
+func newShimResponseWriter() *shimResponseWriter {
+	return &shimResponseWriter{header: make(http.Header)}
+}
+
+func (rw *shimResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *shimResponseWriter) Write(p []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	return rw.body.Write(p)
+}
+
+// WriteHeader keeps the status code of the first call, same as the HTTP server does.
+func (rw *shimResponseWriter) WriteHeader(code int) {
+	if rw.code == 0 {
+		rw.code = code
+	}
+}
+
+// writeTo writes the buffered response back as it is.
+func (rw *shimResponseWriter) writeTo(w http.ResponseWriter) {
+	for k, v := range rw.header {
+		w.Header()[k] = v
+	}
+
+	if rw.code != 0 {
+		w.WriteHeader(rw.code)
+	}
+
+	w.Write(rw.body.Bytes())
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"bytes"
+
+	"github.com/openweb3/go-rpc-provider"
+)
+
+var (
+	// block (or epoch) tags that refer to the chain head, of which the requests should never be
+	// served by lagging full nodes
+	latestBlockTags = [][]byte{
+		[]byte(`"latest"`), []byte(`"pending"`), []byte(`"latest_state"`), []byte(`"latest_mined"`),
+	}
+
+	// RPC methods that query the chain head without any block param
+	latestHeadRpcMethods = map[string]bool{
+		"eth_blockNumber": true,
+		"cfx_epochNumber": true,
+	}
+)
+
+// isLatestTaggedRequest checks if the RPC request queries the chain head, i.e. either tagged with
+// the latest block (or epoch) in params or the head query method.
+func isLatestTaggedRequest(msg *rpc.JsonRpcMessage) bool {
+	if latestHeadRpcMethods[msg.Method] {
+		return true
+	}
+
+	for _, tag := range latestBlockTags {
+		if bytes.Contains(msg.Params, tag) {
+			return true
+		}
+	}
+
+	return false
+}
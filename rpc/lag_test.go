@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLatestTaggedRequest(t *testing.T) {
+	newMsg := func(method, params string) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Method: method, Params: json.RawMessage(params)}
+	}
+
+	assert.True(t, isLatestTaggedRequest(newMsg("eth_blockNumber", `[]`)))
+	assert.True(t, isLatestTaggedRequest(newMsg("eth_getBalance", `["0x01", "latest"]`)))
+	assert.True(t, isLatestTaggedRequest(newMsg("eth_getTransactionCount", `["0x01", "pending"]`)))
+	assert.True(t, isLatestTaggedRequest(newMsg("eth_getLogs", `[{"fromBlock": "0x10", "toBlock": "latest"}]`)))
+	assert.True(t, isLatestTaggedRequest(newMsg("cfx_getBalance", `["cfx:aa", "latest_state"]`)))
+
+	assert.False(t, isLatestTaggedRequest(newMsg("eth_getBalance", `["0x01", "0x10"]`)))
+	assert.False(t, isLatestTaggedRequest(newMsg("eth_getBlockByNumber", `["finalized", false]`)))
+	assert.False(t, isLatestTaggedRequest(newMsg("eth_getTransactionByHash", `["0x01"]`)))
+}
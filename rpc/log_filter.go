@@ -1,10 +1,14 @@
 package rpc
 
 import (
+	"context"
+	"fmt"
 	"math/bits"
 
 	"github.com/Conflux-Chain/confura/store"
 	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	"github.com/Conflux-Chain/go-conflux-sdk/types"
 	"github.com/ethereum/go-ethereum/common"
@@ -149,6 +153,57 @@ func ValidateEthLogFilter(flag LogFilterType, filter *web3Types.FilterQuery) err
 	return nil
 }
 
+// getLogsLimitsFromContext returns the `eth_getLogs` limits of rate limit strategy assigned to
+// the request context.
+func getLogsLimitsFromContext(ctx context.Context) (*rate.GetLogsLimits, bool) {
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok {
+		return nil, false
+	}
+
+	return registry.GetLogsLimits(ctx)
+}
+
+// validateEthLogFilterLimits validates the normalized log filter against `eth_getLogs` limits.
+func validateEthLogFilterLimits(limits *rate.GetLogsLimits, flag LogFilterType, filter *web3Types.FilterQuery) error {
+	if limits.MaxBlockRange > 0 && flag&LogFilterTypeBlockRange != 0 {
+		if span := uint64(*filter.ToBlock-*filter.FromBlock) + 1; span > limits.MaxBlockRange {
+			return &getLogsLimitExceededError{fmt.Sprintf("block range %v too large", span), limits}
+		}
+	}
+
+	if limits.MaxAddresses > 0 && len(filter.Addresses) > limits.MaxAddresses {
+		reason := fmt.Sprintf("too many addresses %v", len(filter.Addresses))
+		return &getLogsLimitExceededError{reason, limits}
+	}
+
+	var numTopics int
+	for i := range filter.Topics {
+		numTopics += len(filter.Topics[i])
+	}
+
+	if limits.MaxTopics > 0 && numTopics > limits.MaxTopics {
+		return &getLogsLimitExceededError{fmt.Sprintf("too many topics %v", numTopics), limits}
+	}
+
+	return nil
+}
+
+// validateEthLogsLimits validates the number of returned logs against `eth_getLogs` limits.
+func validateEthLogsLimits(limits *rate.GetLogsLimits, logs []web3Types.Log) error {
+	return validateEthLogsCount(limits, len(logs))
+}
+
+// validateEthLogsCount validates the number of returned logs against `eth_getLogs` limits.
+func validateEthLogsCount(limits *rate.GetLogsLimits, count int) error {
+	if limits.MaxLogs > 0 && count > limits.MaxLogs {
+		reason := fmt.Sprintf("too many logs %v returned, please narrow down the filter", count)
+		return &getLogsLimitExceededError{reason, limits}
+	}
+
+	return nil
+}
+
 func NormalizeLogFilter(cfx sdk.ClientOperator, flag LogFilterType, filter *types.LogFilter) error {
 	// set default epoch range if not set and convert to numbered epoch if necessary
 	if flag&LogFilterTypeEpochRange != 0 {
@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/ethereum/go-ethereum/common"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEthLogFilterLimits(t *testing.T) {
+	limits := &rate.GetLogsLimits{MaxBlockRange: 100, MaxTopics: 2, MaxLogs: 1}
+
+	from, to := web3Types.BlockNumber(1), web3Types.BlockNumber(100)
+	filter := &web3Types.FilterQuery{
+		FromBlock: &from,
+		ToBlock:   &to,
+		Addresses: make([]common.Address, 20),
+		Topics:    [][]common.Hash{{{}}, {{}}},
+	}
+
+	assert.NoError(t, validateEthLogFilterLimits(limits, LogFilterTypeBlockRange, filter))
+
+	to = 101
+	err := validateEthLogFilterLimits(limits, LogFilterTypeBlockRange, filter)
+	assert.EqualError(t, err, "block range 101 too large; allowed limits: "+
+		"max block range 100, max addresses unlimited, max topics 2, max logs 1")
+	assert.Equal(t, errCodeLimitExceeded, err.(*getLogsLimitExceededError).ErrorCode())
+
+	// block hash filter has no block range
+	assert.NoError(t, validateEthLogFilterLimits(limits, LogFilterTypeBlockHash, filter))
+
+	filter.Topics = append(filter.Topics, []common.Hash{{}})
+	assert.Error(t, validateEthLogFilterLimits(limits, LogFilterTypeBlockHash, filter))
+
+	assert.NoError(t, validateEthLogsLimits(limits, make([]web3Types.Log, 1)))
+	assert.Error(t, validateEthLogsLimits(limits, make([]web3Types.Log, 2)))
+}
@@ -2,15 +2,18 @@ package rpc
 
 import (
 	"context"
-
-	"github.com/Conflux-Chain/confura/rpc/cache"
 )
 
-// netAPI provides evm space net RPC proxy API.
+// netAPI provides evm space net RPC API.
 type netAPI struct{}
 
-// Version returns the current network id.
+// Version returns the current network id, which is cached from upstream full nodes.
 func (api *netAPI) Version(ctx context.Context) (string, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetNetVersion(w3c.Client)
+	return GetEthCacheFromContext(ctx).GetNetVersion(w3c.Client)
+}
+
+// Listening always returns true since the gateway is listening for network connections.
+func (api *netAPI) Listening() bool {
+	return true
 }
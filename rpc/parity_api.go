@@ -3,12 +3,17 @@ package rpc
 import (
 	"context"
 
+	"github.com/Conflux-Chain/confura/node"
+
 	"github.com/openweb3/web3go/types"
 )
 
 // parityAPI provides evm space parity RPC proxy API.
 type parityAPI struct{}
 
+// GetBlockReceipts returns receipts of the block, which is translated to `eth_getBlockReceipts`
+// if unsupported by full node.
 func (api *parityAPI) GetBlockReceipts(ctx context.Context, blockNumOrHash *types.BlockNumberOrHash) ([]types.Receipt, error) {
-	return GetEthClientFromContext(ctx).Parity.BlockReceipts(blockNumOrHash)
+	provider := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider)
+	return blockReceipts(ctx, provider, GetEthClientFromContext(ctx), rpcMethodParityGetBlockReceipts, blockNumOrHash)
 }
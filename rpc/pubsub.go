@@ -38,11 +38,72 @@ var (
 	errSubscriptionProxyError = errors.New("subscription proxy error")
 	// errDelegateNotReady returned when the delegate is not ready for service.
 	errDelegateNotReady = errors.New("delegate not ready")
+	// errPubsubShutdown returned when the delegate is shutdown along with RPC server.
+	errPubsubShutdown = errors.New("subscription proxy shutdown")
+
+	// run loops of delegate subscriptions for core space and evm space respectively
+	cfxDelegateLoops = newDelegateLoops()
+	ethDelegateLoops = newDelegateLoops()
 
 	// delegateClients cache store delegate clients
 	delegateClients util.ConcurrentMap // node name => *delegateClient
 )
 
+// delegateLoops tracks the run loops of delegate subscriptions, which are stopped to unsubscribe
+// the upstream subscriptions on graceful shutdown, rather than being dropped along with process.
+type delegateLoops struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+	stop    chan struct{} // closed once shutdown
+}
+
+func newDelegateLoops() *delegateLoops {
+	return &delegateLoops{stop: make(chan struct{})}
+}
+
+// start tracks a run loop to start, or returns false if already shutdown.
+func (l *delegateLoops) start() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stopped {
+		return false
+	}
+
+	l.wg.Add(1)
+
+	return true
+}
+
+// done untracks the run loop once exited, or failed to start.
+func (l *delegateLoops) done() {
+	l.wg.Done()
+}
+
+// shutdown stops all run loops, and then waits for them to exit until the context is done.
+func (l *delegateLoops) shutdown(ctx context.Context) {
+	l.mu.Lock()
+	if !l.stopped {
+		l.stopped = true
+		close(l.stop)
+	}
+	l.mu.Unlock()
+
+	exited := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+		logrus.Info("Pub/Sub upstream subscriptions closed")
+	case <-ctx.Done():
+		logrus.Warn("Timeout to close Pub/Sub upstream subscriptions")
+	}
+}
+
 type delegateSubFilter func(item interface{}) bool // result filter for delegate subscription
 
 // delegateSubscription is a subscription established through the delegateClient's `Subscribe` methods.
@@ -126,6 +187,8 @@ type delegateContext struct {
 	delegateSubs util.ConcurrentMap // client subscription ID => *delegateSubscription
 
 	epoch *types.Epoch // epochs subscription type
+
+	replay *replayBuffer // notifications for subscription replay, nil if disabled
 }
 
 // functional options to set delegateContext
@@ -146,6 +209,12 @@ func withEpoch(epoch *types.Epoch) delegateCtxOption {
 	}
 }
 
+func withReplay(replay *replayBuffer) delegateCtxOption {
+	return func(ctx *delegateContext) {
+		ctx.replay = replay
+	}
+}
+
 func (dctx *delegateContext) getStatus() delegateStatus {
 	return delegateStatus(atomic.LoadUint32((*uint32)(&dctx.status)))
 }
@@ -190,6 +259,14 @@ func (dctx *delegateContext) deregisterDelegateSub(subId rpc.ID) *delegateSubscr
 	return nil
 }
 
+// stop unsubscribes the upstream subscription, and then cancels all delegated subscriptions,
+// eg., on graceful shutdown.
+func (dctx *delegateContext) stop(csub interface{ Unsubscribe() }) {
+	dctx.setStatus(delegateStatusErr)
+	csub.Unsubscribe()
+	dctx.cancel(errPubsubShutdown)
+}
+
 // cancel all delegated subscriptions
 func (dctx *delegateContext) cancel(err error) {
 	dctx.lock.Lock()
@@ -258,21 +335,29 @@ func (client *delegateClient) delegateSubscribeNewHeads(
 }
 
 func (client *delegateClient) proxySubscribeNewHeads(dctx *delegateContext) error {
+	if !cfxDelegateLoops.start() {
+		return errPubsubShutdown
+	}
+
 	nhCh := make(chan types.BlockHeader, pubsubChannelBufferSize)
 	csub, err := client.SubscribeNewHeads(nhCh)
 	if err != nil {
 		logrus.WithField("nodeURL", client.GetNodeURL()).
 			WithError(err).
 			Info("CFX Pub/Sub NewHead proxy subscription conn error")
+		cfxDelegateLoops.done()
 		return err
 	}
 
 	go func() { // run subscription loop
+		defer cfxDelegateLoops.done()
 		dctx.setStatus(delegateStatusOK)
 		defer dctx.setStatus(delegateStatusInit)
 
 		for dctx.getStatus() == delegateStatusOK {
 			select {
+			case <-cfxDelegateLoops.stop: // graceful shutdown
+				dctx.stop(csub)
 			case err = <-csub.Err():
 				logrus.WithField("nodeURL", client.GetNodeURL()).
 					WithError(err).
@@ -309,6 +394,10 @@ func (client *delegateClient) delegateSubscribeEpochs(
 }
 
 func (client *delegateClient) proxySubscribeEpochs(dctx *delegateContext) error {
+	if !cfxDelegateLoops.start() {
+		return errPubsubShutdown
+	}
+
 	logger := logrus.WithFields(logrus.Fields{
 		"nodeURL":      client.GetNodeURL(),
 		"subEpochType": dctx.epoch,
@@ -318,15 +407,19 @@ func (client *delegateClient) proxySubscribeEpochs(dctx *delegateContext) error
 	csub, err := client.SubscribeEpochs(epochCh, *dctx.epoch)
 	if err != nil {
 		logger.WithError(err).Info("CFX Pub/Sub Epochs proxy subscription conn error")
+		cfxDelegateLoops.done()
 		return err
 	}
 
 	go func() { // run subscription loop
+		defer cfxDelegateLoops.done()
 		dctx.setStatus(delegateStatusOK)
 		defer dctx.setStatus(delegateStatusInit)
 
 		for dctx.getStatus() == delegateStatusOK {
 			select {
+			case <-cfxDelegateLoops.stop: // graceful shutdown
+				dctx.stop(csub)
 			case err = <-csub.Err():
 				logger.WithError(err).Info("CFX Pub/Sub Epochs proxy subscription delegate error")
 
@@ -359,21 +452,29 @@ func (client *delegateClient) delegateSubscribeLogs(
 }
 
 func (client *delegateClient) proxySubscribeLogs(dctx *delegateContext) error {
+	if !cfxDelegateLoops.start() {
+		return errPubsubShutdown
+	}
+
 	logsCh := make(chan types.SubscriptionLog, pubsubChannelBufferSize)
 	csub, err := client.SubscribeLogs(logsCh, types.LogFilter{})
 	if err != nil {
 		logrus.WithField("nodeURL", client.GetNodeURL()).
 			WithError(err).
 			Info("CFX Pub/Sub Logs subscription conn error")
+		cfxDelegateLoops.done()
 		return err
 	}
 
 	go func() { // run subscription loop
+		defer cfxDelegateLoops.done()
 		dctx.setStatus(delegateStatusOK)
 		defer dctx.setStatus(delegateStatusInit)
 
 		for dctx.getStatus() == delegateStatusOK {
 			select {
+			case <-cfxDelegateLoops.stop: // graceful shutdown
+				dctx.stop(csub)
 			case err = <-csub.Err():
 				logrus.WithField("nodeURL", client.GetNodeURL()).
 					WithError(err).
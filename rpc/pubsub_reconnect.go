@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+// pubsubReconnectConfig configurations to reconnect upstream websocket once dropped, so that the
+// shared subscriptions are re-established without closing client subscriptions.
+type pubsubReconnectConfig struct {
+	// switch to turn on/off reconnection, or client subscriptions are closed once upstream dropped
+	Enabled bool `default:"true"`
+	// max attempts to reconnect, after which client subscriptions are closed
+	MaxAttempts int `default:"10"`
+	// backoff of the first attempt, which is doubled for each subsequent attempt
+	InitialBackoff time.Duration `default:"1s"`
+	// max backoff between attempts
+	MaxBackoff time.Duration `default:"30s"`
+	// max number of missed blocks to backfill via HTTP, beyond which client subscriptions are
+	// closed since gap unrecoverable
+	MaxBackfillBlocks uint64 `default:"1000"`
+}
+
+func mustNewPubsubReconnectConfigFromViper(key string) pubsubReconnectConfig {
+	var conf pubsubReconnectConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if conf.Enabled && (conf.MaxAttempts <= 0 || conf.InitialBackoff <= 0 || conf.MaxBackoff < conf.InitialBackoff) {
+		logrus.WithField("config", conf).Fatal("Invalid upstream subscription reconnect config")
+	}
+
+	return conf
+}
+
+// backoff returns the jittered exponential backoff before the specified attempt (from 0), which
+// is randomized in range [backoff/2, backoff] to avoid reconnecting at the same time.
+func (conf *pubsubReconnectConfig) backoff(attempt int) time.Duration {
+	backoff := conf.MaxBackoff
+	if attempt < 32 {
+		if d := conf.InitialBackoff << uint(attempt); d > 0 && d < backoff {
+			backoff = d
+		}
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// reconnect retries to resubscribe with backoff, until succeeded or attempts exhausted.
+func (conf *pubsubReconnectConfig) reconnect(logger *logrus.Entry, space, nodeName string, subscribe func() error) bool {
+	if !conf.Enabled {
+		return false
+	}
+
+	for i := 0; i < conf.MaxAttempts; i++ {
+		time.Sleep(conf.backoff(i))
+
+		err := subscribe()
+		if err == nil {
+			metrics.Registry.PubSub.Reconnects(space, nodeName, "success").Mark(1)
+			logger.WithField("attempts", i+1).Info("Pub/Sub proxy subscription reconnected")
+			return true
+		}
+
+		metrics.Registry.PubSub.Reconnects(space, nodeName, "failure").Mark(1)
+		logger.WithError(err).WithField("attempt", i+1).Info("Failed to reconnect Pub/Sub proxy subscription")
+	}
+
+	return false
+}
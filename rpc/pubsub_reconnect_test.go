@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubsubReconnectBackoff(t *testing.T) {
+	conf := pubsubReconnectConfig{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		backoff := conf.backoff(attempt)
+		assert.GreaterOrEqual(t, int64(backoff), int64(expected/2))
+		assert.LessOrEqual(t, int64(backoff), int64(expected))
+	}
+
+	// no overflow
+	assert.LessOrEqual(t, int64(conf.backoff(100)), int64(conf.MaxBackoff))
+}
+
+func TestPubsubReconnect(t *testing.T) {
+	conf := pubsubReconnectConfig{
+		Enabled: true, MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond,
+	}
+	logger := logrus.WithField("test", true)
+
+	var attempts int
+	ok := conf.reconnect(logger, "eth", "node", func() error {
+		if attempts++; attempts < 2 {
+			return errors.New("connection refused")
+		}
+
+		return nil
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 2, attempts)
+
+	attempts = 0
+	ok = conf.reconnect(logger, "eth", "node", func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	assert.False(t, ok)
+	assert.Equal(t, 3, attempts)
+
+	conf.Enabled = false
+	assert.False(t, conf.reconnect(logger, "eth", "node", func() error { return nil }))
+}
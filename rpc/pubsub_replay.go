@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errReplayWindowExceeded = errors.New(
+		"last block out of subscription replay window, please backfill the missing blocks by polling",
+	)
+)
+
+// pubsubReplayConfig configurations of subscription replay, by which a reconnecting websocket
+// client could resume `logs` or `newHeads` subscription from the last delivered block.
+type pubsubReplayConfig struct {
+	// switch to turn on/off subscription replay
+	Enabled bool
+	// duration to retain notifications for replay
+	Window time.Duration `default:"30s"`
+	// max number of notifications retained for replay per subscription type
+	Size int `default:"10000"`
+}
+
+func mustNewPubsubReplayConfigFromViper(key string) pubsubReplayConfig {
+	var conf pubsubReplayConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if conf.Enabled && (conf.Window <= 0 || conf.Size <= 0) {
+		logrus.WithField("config", conf).Fatal("Invalid subscription replay config")
+	}
+
+	return conf
+}
+
+// pubsubReplayOptions subscription options to resume from the last delivered block.
+type pubsubReplayOptions struct {
+	// notifications of blocks after the last delivered block are replayed
+	LastBlock *hexutil.Uint64 `json:"lastBlock"`
+}
+
+type replayItem struct {
+	block    uint64
+	received time.Time
+	item     interface{}
+}
+
+// replayBuffer ring buffer of notifications fed by the shared upstream subscription.
+type replayBuffer struct {
+	mu     sync.Mutex
+	window time.Duration
+	items  []replayItem // ring buffer
+	start  int          // index of the oldest item
+	count  int          // number of buffered items
+}
+
+// newReplayBuffer creates a replay buffer, or nil if subscription replay disabled.
+func newReplayBuffer(conf pubsubReplayConfig) *replayBuffer {
+	if !conf.Enabled {
+		return nil
+	}
+
+	return &replayBuffer{window: conf.Window, items: make([]replayItem, conf.Size)}
+}
+
+// add appends notification of the specified block, which evicts the oldest one if full.
+func (b *replayBuffer) add(block uint64, item interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.items[(b.start+b.count)%len(b.items)] = replayItem{block, time.Now(), item}
+
+	if b.count < len(b.items) {
+		b.count++
+	} else {
+		b.start = (b.start + 1) % len(b.items)
+	}
+}
+
+// reset drops all buffered notifications, eg., when upstream subscription re-established, since
+// notifications may be missed in between.
+func (b *replayBuffer) reset() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.start, b.count = 0, 0
+	for i := range b.items {
+		b.items[i] = replayItem{}
+	}
+}
+
+// since returns the buffered notifications of blocks after the last delivered block, or error if
+// some notifications are out of the replay window.
+func (b *replayBuffer) since(lastBlock uint64) ([]interface{}, error) {
+	if b == nil {
+		return nil, errors.New("subscription replay disabled")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// evict the expired notifications
+	for b.count > 0 && time.Since(b.items[b.start].received) > b.window {
+		b.items[b.start] = replayItem{}
+		b.start, b.count = (b.start+1)%len(b.items), b.count-1
+	}
+
+	if b.count == 0 || b.items[b.start].block > lastBlock+1 {
+		return nil, errReplayWindowExceeded
+	}
+
+	var result []interface{}
+	for i := 0; i < b.count; i++ {
+		if item := b.items[(b.start+i)%len(b.items)]; item.block > lastBlock {
+			result = append(result, item.item)
+		}
+	}
+
+	return result, nil
+}
+
+// replayDeduper skips the live notifications which have already been replayed, since the
+// delegate subscription is registered before taking the replay snapshot to avoid gaps.
+type replayDeduper map[interface{}]struct{}
+
+func newReplayDeduper(replayed []interface{}) replayDeduper {
+	deduper := make(replayDeduper, len(replayed))
+	for _, item := range replayed {
+		deduper[item] = struct{}{}
+	}
+
+	return deduper
+}
+
+// replayed checks if the live notification has already been replayed.
+func (d replayDeduper) replayed(item interface{}) bool {
+	if len(d) == 0 {
+		return false
+	}
+
+	if _, ok := d[item]; ok {
+		delete(d, item)
+		return true
+	}
+
+	// notifications are in order, so the remaining ones will never be delivered
+	for k := range d {
+		delete(d, k)
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	buf := newReplayBuffer(pubsubReplayConfig{Enabled: true, Window: time.Minute, Size: 3})
+
+	_, err := buf.since(10)
+	assert.Equal(t, errReplayWindowExceeded, err)
+
+	for block := uint64(10); block <= 13; block++ {
+		buf.add(block, block)
+	}
+
+	// block 10 evicted since buffer full
+	items, err := buf.since(11)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{uint64(12), uint64(13)}, items)
+
+	items, err = buf.since(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{uint64(11), uint64(12), uint64(13)}, items)
+
+	_, err = buf.since(9)
+	assert.Equal(t, errReplayWindowExceeded, err)
+
+	// nothing to replay since up to date
+	items, err = buf.since(13)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	buf.reset()
+	_, err = buf.since(13)
+	assert.Equal(t, errReplayWindowExceeded, err)
+
+	// expired
+	buf.window = time.Millisecond
+	buf.add(14, 14)
+	time.Sleep(5 * time.Millisecond)
+	_, err = buf.since(13)
+	assert.Equal(t, errReplayWindowExceeded, err)
+
+	// disabled
+	buf = newReplayBuffer(pubsubReplayConfig{})
+	assert.Nil(t, buf)
+	buf.add(1, 1)
+	_, err = buf.since(0)
+	assert.Error(t, err)
+}
+
+func TestReplayDeduper(t *testing.T) {
+	a, b, c := new(int), new(int), new(int)
+
+	deduper := newReplayDeduper([]interface{}{a, b})
+	assert.True(t, deduper.replayed(b))
+	assert.False(t, deduper.replayed(c))
+	assert.False(t, deduper.replayed(a))
+}
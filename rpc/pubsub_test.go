@@ -1,8 +1,11 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Conflux-Chain/confura/util"
 	"github.com/Conflux-Chain/go-conflux-sdk/types"
@@ -11,6 +14,46 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+type unsubscribeCounter int32
+
+func (c *unsubscribeCounter) Unsubscribe() { atomic.AddInt32((*int32)(c), 1) }
+
+func TestDelegateLoopsShutdown(t *testing.T) {
+	loops := newDelegateLoops()
+	dctx := newDelegateContext()
+
+	dsub, err := dctx.registerDelegateSub(nil, rpc.NewID(), make(chan interface{}))
+	assert.NoError(t, err)
+
+	var csub unsubscribeCounter
+	for i := 0; i < 3; i++ {
+		assert.True(t, loops.start())
+
+		go func() { // run subscription loop
+			defer loops.done()
+			dctx.setStatus(delegateStatusOK)
+
+			for dctx.getStatus() == delegateStatusOK {
+				<-loops.stop
+				dctx.stop(&csub)
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	loops.shutdown(ctx)
+
+	// upstream subscriptions unsubscribed, and delegated subscriptions cancelled
+	assert.Equal(t, int32(3), atomic.LoadInt32((*int32)(&csub)))
+	assert.Equal(t, errPubsubShutdown, <-dsub.err)
+
+	// never started once shutdown
+	assert.False(t, loops.start())
+	loops.shutdown(ctx)
+}
+
 func TestDelegateContextRegister(t *testing.T) {
 	dctx := newDelegateContext()
 
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/jsonscan"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// rawProxyConfig configurations of raw forwarding, by which params of pass-through methods are
+// forwarded to full node as they are, and the raw result is responded to client, rather than
+// decoded into typed values and encoded again by the RPC server.
+type rawProxyConfig struct {
+	// switch to turn on/off raw forwarding
+	Enabled bool
+	// pass-through methods to forward raw, eg., `eth_getProof`. Note, gateway logic of the method
+	// (eg., store, cache or rerouting to archive nodes) is bypassed, so only methods plainly
+	// delegated to full node should be configured.
+	Methods []string
+}
+
+// rawProxy forwards requests of pass-through methods to the routed full node in raw, so as to cut
+// CPU and GC pressure at high QPS.
+type rawProxy struct {
+	evm     bool // whether to forward requests of evm space or core space
+	methods map[string]bool
+}
+
+func mustNewRawProxyFromViper(key string, evm bool) *rawProxy {
+	var conf rawProxyConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if len(conf.Methods) == 0 {
+		logrus.WithField("config", conf).Fatal("Invalid raw forwarding config")
+	}
+
+	logrus.WithField("config", conf).Info("Raw forwarding enabled")
+
+	return newRawProxy(conf, evm)
+}
+
+func newRawProxy(conf rawProxyConfig, evm bool) *rawProxy {
+	return &rawProxy{evm: evm, methods: stringSet(conf.Methods)}
+}
+
+// Call forwards requests of the configured methods to the full node injected into context in raw,
+// which passes through if proxy is nil. Note, it must be executed after the other proxy middlewares
+// (eg., deduplication and verification), so that they apply as usual.
+func (p *rawProxy) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if p == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if !p.methods[msg.Method] {
+			return next(ctx, msg)
+		}
+
+		if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != p.evm {
+			return next(ctx, msg)
+		}
+
+		if _, ok := responseStreamFromContext(ctx); ok {
+			return next(ctx, msg)
+		}
+
+		call, ok := rawCaller(ctx.Value(ctxKeyClient))
+		if !ok { // not routed to full node
+			return next(ctx, msg)
+		}
+
+		// named params or malformed, which is reported by RPC server
+		args, ok := rawParams(msg.Params)
+		metrics.Registry.RPC.Percentage(msg.Method, "rawForwarded").Mark(ok)
+
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		var result json.RawMessage
+		if err := call(ctx, &result, msg.Method, args...); err != nil {
+			return msg.ErrorResponse(err)
+		}
+
+		if len(result) == 0 {
+			result = json.RawMessage("null")
+		}
+
+		return &rpc.JsonRpcMessage{Version: "2.0", ID: msg.ID, Result: result}
+	}
+}
+
+// rawParams splits the positional params into raw JSON args, which is empty if params absent.
+func rawParams(params json.RawMessage) ([]interface{}, bool) {
+	if len(params) == 0 || jsonscan.IsNull(params) {
+		return nil, true
+	}
+
+	var args []interface{}
+	ok := jsonscan.ForEachElement(params, func(_ int, arg []byte) bool {
+		args = append(args, json.RawMessage(arg))
+		return true
+	})
+
+	return args, ok
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawParams(t *testing.T) {
+	args, ok := rawParams(json.RawMessage(`["0x1", {"to":"0x2"}, [true]]`))
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{
+		json.RawMessage(`"0x1"`), json.RawMessage(`{"to":"0x2"}`), json.RawMessage(`[true]`),
+	}, args)
+
+	for _, params := range []string{``, `null`, `[]`} {
+		args, ok := rawParams(json.RawMessage(params))
+		assert.True(t, ok)
+		assert.Empty(t, args)
+	}
+
+	// named params
+	_, ok = rawParams(json.RawMessage(`{"address":"0x1"}`))
+	assert.False(t, ok)
+}
+
+func TestRawProxyPassThrough(t *testing.T) {
+	proxy := newRawProxy(rawProxyConfig{Methods: []string{"eth_getProof"}}, true)
+
+	var called int
+	handler := proxy.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		called++
+		return &rpc.JsonRpcMessage{}
+	})
+
+	// other method
+	handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_blockNumber"})
+	// other space
+	handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getProof", Params: json.RawMessage(`[]`)})
+	assert.Equal(t, 2, called)
+
+	// disabled
+	var disabled *rawProxy
+	disabled.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		called++
+		return nil
+	})(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getProof"})
+	assert.Equal(t, 3, called)
+}
@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/traffic"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+var (
+	// cfxTrafficRecorder records sanitized requests of core space, nil if disabled.
+	cfxTrafficRecorder *traffic.Recorder
+
+	// ethTrafficRecorder records sanitized requests of evm space, nil if disabled.
+	ethTrafficRecorder *traffic.Recorder
+)
+
+// trafficRecordMiddleware records requests once arrived, so that requests rejected later (eg., by
+// rate limit) are replayed as well. Requests of additional chains served by the gateway are not
+// recorded.
+func trafficRecordMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if cfxTrafficRecorder == nil && ethTrafficRecorder == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		var recorder *traffic.Recorder
+
+		switch ctx.Value(ctxKeyClientProvider).(type) {
+		case *node.CfxClientProvider:
+			recorder = cfxTrafficRecorder
+		case *node.EthClientProvider:
+			recorder = ethTrafficRecorder
+		}
+
+		if _, ok := handlers.GetChainFromContext(ctx); !ok && recorder != nil {
+			recorder.Record(time.Now(), msg)
+		}
+
+		return next(ctx, msg)
+	}
+}
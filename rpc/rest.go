@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+const restPathPrefix = "v1"
+
+var (
+	errRestNotFound = errors.New("not found")
+
+	hashRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+)
+
+// restConfig configurations to serve REST APIs for evm space.
+type restConfig struct {
+	// switch to turn on/off REST APIs
+	Enabled bool
+}
+
+func mustNewRestConfigFromViper() *restConfig {
+	var conf restConfig
+	viper.MustUnmarshalKey("ethrpc.rest", &conf)
+
+	return &conf
+}
+
+// restCall is the JSON-RPC call translated from REST request.
+type restCall struct {
+	method string
+	params []interface{}
+}
+
+// restMiddleware serves lightweight REST APIs for common read methods at `/v1/...` or
+// `/${accessToken}/v1/...` by translating them into JSON-RPC calls:
+//
+//	GET /v1/blocks/{number|tag|hash}[?full=true]	eth_getBlockByNumber or eth_getBlockByHash
+//	GET /v1/txs/{hash}				eth_getTransactionByHash
+//	GET /v1/txs/{hash}/receipt			eth_getTransactionReceipt
+//	GET /v1/accounts/{address}/balance[?block=]	eth_getBalance
+//
+// Block number could be either decimal or hex encoded.
+func restMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, resource, ok := parseRestPath(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// CORS preflight handled by RPC server
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, rewriteShimRequest(r, token, nil))
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			writeRestError(w, http.StatusMethodNotAllowed, errors.Errorf("method %v not allowed", r.Method))
+			return
+		}
+
+		call, err := parseRestCall(resource, r)
+		if err != nil {
+			writeRestError(w, http.StatusNotFound, err)
+			return
+		}
+
+		resp, rw, err := serveShimCall(next, r, token, call.method, call.params...)
+		if err != nil {
+			writeRestError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if resp == nil { // rejected by HTTP middlewares
+			rw.writeTo(w)
+			return
+		}
+
+		if resp.Error != nil {
+			writeRestJson(w, restStatusCode(resp.Error.Code), resp.Error)
+			return
+		}
+
+		if len(resp.Result) == 0 || string(resp.Result) == "null" {
+			writeRestError(w, http.StatusNotFound, errRestNotFound)
+			return
+		}
+
+		writeRestJson(w, http.StatusOK, resp.Result)
+	})
+}
+
+// parseRestPath parses the access token (optional) and resource path segments from REST
+// request path.
+func parseRestPath(path string) (token string, resource []string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	if len(segments) > 1 && segments[0] == restPathPrefix {
+		return "", segments[1:], true
+	}
+
+	if len(segments) > 2 && segments[1] == restPathPrefix {
+		return segments[0], segments[2:], true
+	}
+
+	return "", nil, false
+}
+
+// parseRestCall translates REST resource into JSON-RPC call.
+func parseRestCall(resource []string, r *http.Request) (*restCall, error) {
+	query := r.URL.Query()
+
+	switch {
+	case len(resource) == 2 && resource[0] == "blocks":
+		full, _ := strconv.ParseBool(query.Get("full"))
+
+		if hashRegexp.MatchString(resource[1]) {
+			return &restCall{"eth_getBlockByHash", []interface{}{resource[1], full}}, nil
+		}
+
+		return &restCall{"eth_getBlockByNumber", []interface{}{restBlockParam(resource[1]), full}}, nil
+	case len(resource) == 2 && resource[0] == "txs":
+		return &restCall{"eth_getTransactionByHash", []interface{}{resource[1]}}, nil
+	case len(resource) == 3 && resource[0] == "txs" && resource[2] == "receipt":
+		return &restCall{"eth_getTransactionReceipt", []interface{}{resource[1]}}, nil
+	case len(resource) == 3 && resource[0] == "accounts" && resource[2] == "balance":
+		block := query.Get("block")
+		if len(block) == 0 {
+			block = "latest"
+		}
+
+		return &restCall{"eth_getBalance", []interface{}{resource[1], restBlockParam(block)}}, nil
+	default:
+		return nil, errors.Errorf("resource /%v/%v not found", restPathPrefix, strings.Join(resource, "/"))
+	}
+}
+
+// restBlockParam converts decimal block number into hex, and keeps block tag or hash as it is.
+func restBlockParam(block string) string {
+	if num, err := strconv.ParseUint(block, 10, 64); err == nil {
+		return hexutil.EncodeUint64(num)
+	}
+
+	return block
+}
+
+func restStatusCode(rpcErrCode int) int {
+	switch rpcErrCode {
+	case -32600, -32602: // invalid request or params
+		return http.StatusBadRequest
+	case -32601: // method not found
+		return http.StatusNotFound
+	case -32005, -32007: // limit or quota exceeded
+		return http.StatusTooManyRequests
+	case -32008: // request timed out
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeRestError(w http.ResponseWriter, statusCode int, err error) {
+	writeRestJson(w, statusCode, map[string]string{"message": err.Error()})
+}
+
+func writeRestJson(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	json.NewEncoder(w).Encode(value)
+}
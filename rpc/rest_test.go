@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRestCall(t *testing.T) {
+	hash := "0x6f8bd3bf29dd5b4a3b8d0b4b26e8b2bd1c4df2c3b2a8a3e0c6b5f1d7e2a9b4c1"
+
+	testCases := []struct {
+		path   string
+		method string
+		params []interface{}
+	}{
+		{"/v1/blocks/100", "eth_getBlockByNumber", []interface{}{"0x64", false}},
+		{"/key/v1/blocks/latest?full=true", "eth_getBlockByNumber", []interface{}{"latest", true}},
+		{"/v1/blocks/" + hash, "eth_getBlockByHash", []interface{}{hash, false}},
+		{"/v1/txs/" + hash, "eth_getTransactionByHash", []interface{}{hash}},
+		{"/v1/txs/" + hash + "/receipt", "eth_getTransactionReceipt", []interface{}{hash}},
+		{"/v1/accounts/0x01/balance", "eth_getBalance", []interface{}{"0x01", "latest"}},
+		{"/v1/accounts/0x01/balance?block=16", "eth_getBalance", []interface{}{"0x01", "0x10"}},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+
+		_, resource, ok := parseRestPath(r.URL.Path)
+		require.True(t, ok, tc.path)
+
+		call, err := parseRestCall(resource, r)
+		require.NoError(t, err, tc.path)
+		assert.Equal(t, tc.method, call.method, tc.path)
+		assert.Equal(t, tc.params, call.params, tc.path)
+	}
+
+	_, _, ok := parseRestPath("/key")
+	assert.False(t, ok)
+
+	_, resource, ok := parseRestPath("/v1/unknown")
+	assert.True(t, ok)
+
+	_, err := parseRestCall(resource, httptest.NewRequest(http.MethodGet, "/v1/unknown", nil))
+	assert.Error(t, err)
+}
+
+func TestRestMiddleware(t *testing.T) {
+	handler := restMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var msg struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		require.NoError(t, json.Unmarshal(body, &msg))
+
+		switch {
+		case r.URL.Path == "/denied":
+			http.Error(w, "access denied", http.StatusForbidden)
+		case msg.Method == "eth_getBalance":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x64"}`))
+		case msg.Method == "eth_getTransactionByHash":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+		default:
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`))
+		}
+	}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder
+	}
+
+	resp := serve(http.MethodGet, "/key/v1/accounts/0x01/balance")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `"0x64"`, resp.Body.String())
+
+	resp = serve(http.MethodGet, "/v1/txs/0x01")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serve(http.MethodGet, "/v1/blocks/0xzz")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"code":-32602,"message":"invalid params"}`, resp.Body.String())
+
+	resp = serve(http.MethodGet, "/denied/v1/blocks/latest")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = serve(http.MethodPost, "/v1/blocks/latest")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+
+	resp = serve(http.MethodGet, "/v1/unknown")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
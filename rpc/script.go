@@ -0,0 +1,232 @@
+package rpc
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/script"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// scriptConfig configurations of Lua scripting hooks, by which requests and responses are
+// inspected or rewritten, eg., to rewrite block tags, inject default gas or drop params.
+type scriptConfig struct {
+	// switch to turn on/off scripting hooks
+	Enabled bool
+	// default budget to run a hook function of script
+	Budget script.Budget
+	// scripts executed in order
+	Scripts []scriptHookConfig
+}
+
+type scriptHookConfig struct {
+	// path of Lua script file
+	File string
+	// route groups to apply script, or all groups if empty
+	Groups []string
+	// methods to apply script, or all methods if empty
+	Methods []string
+	// max duration to run a hook function, which overrides the default budget if not zero
+	Timeout time.Duration
+}
+
+// scriptHook the compiled script applied to requests of route groups and methods.
+type scriptHook struct {
+	script  *script.Script
+	name    string          // name for logs and metrics, eg., file name
+	groups  map[string]bool // empty for all groups
+	methods map[string]bool // empty for all methods
+}
+
+func (h *scriptHook) matches(group node.Group, method string) bool {
+	return (len(h.groups) == 0 || h.groups[string(group)]) &&
+		(len(h.methods) == 0 || h.methods[method])
+}
+
+// scriptRunner runs scripting hooks on requests routed to full nodes, which fails open if script
+// errors or exceeds the time budget.
+type scriptRunner struct {
+	evm   bool // whether to run scripts on requests of evm space or core space
+	hooks []*scriptHook
+}
+
+func mustNewScriptRunnerFromViper(key string, evm bool) *scriptRunner {
+	var conf scriptConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	runner, err := newScriptRunner(conf, evm)
+	if err != nil {
+		logrus.WithError(err).WithField("config", conf).Fatal("Failed to load scripting hooks")
+	}
+
+	logrus.WithField("config", conf).Info("Scripting hooks enabled")
+
+	return runner
+}
+
+func newScriptRunner(conf scriptConfig, evm bool) (*scriptRunner, error) {
+	runner := &scriptRunner{evm: evm}
+
+	for _, sc := range conf.Scripts {
+		budget := conf.Budget
+		if sc.Timeout > 0 {
+			budget.Timeout = sc.Timeout
+		}
+
+		s, err := script.Load(sc.File, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		runner.hooks = append(runner.hooks, &scriptHook{
+			script:  s,
+			name:    filepath.Base(sc.File),
+			groups:  stringSet(sc.Groups),
+			methods: stringSet(sc.Methods),
+		})
+	}
+
+	return runner, nil
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
+
+// Call runs the `on_request` hooks before requests delegated to full node, and `on_response` hooks
+// in the same order once responded, which passes through if runner is nil. Note, it must be
+// executed after the full node client injected into context, so that scripts apply per group.
+func (r *scriptRunner) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if r == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != r.evm {
+			return next(ctx, msg)
+		}
+
+		group, ok := ctx.Value(ctxKeyClientGroup).(node.Group)
+		if !ok { // not routed to full node, eg., answered by gateway
+			return next(ctx, msg)
+		}
+
+		var hooks []*scriptHook
+		for _, h := range r.hooks {
+			if h.matches(group, msg.Method) {
+				hooks = append(hooks, h)
+			}
+		}
+
+		if len(hooks) == 0 {
+			return next(ctx, msg)
+		}
+
+		req := &script.Request{Method: msg.Method, Params: msg.Params}
+
+		for _, h := range hooks {
+			rewritten, rejected, err := h.script.OnRequest(req)
+			if err != nil {
+				r.onError(h, msg.Method, "request", err)
+				continue
+			}
+
+			if rejected != nil {
+				metrics.Registry.RPC.Script(h.name, "rejected").Mark(1)
+				return msg.ErrorResponse(rejected)
+			}
+
+			if rewritten != nil {
+				metrics.Registry.RPC.Script(h.name, "rewritten").Mark(1)
+				req = rewritten
+			}
+		}
+
+		// shallow copy with the rewritten params
+		rewrittenMsg := *msg
+		rewrittenMsg.Params = req.Params
+
+		resp := next(ctx, &rewrittenMsg)
+		if resp == nil || isResponseStreamed(ctx) {
+			return resp
+		}
+
+		return r.onResponse(hooks, req, resp)
+	}
+}
+
+// onResponse runs the `on_response` hooks to rewrite response.
+func (r *scriptRunner) onResponse(
+	hooks []*scriptHook, req *script.Request, resp *rpc.JsonRpcMessage,
+) *rpc.JsonRpcMessage {
+	result := &script.Response{Result: resp.Result}
+	if resp.Error != nil {
+		result.Error = &script.Error{Code: resp.Error.Code, Message: resp.Error.Message}
+	}
+
+	var changed bool
+
+	for _, h := range hooks {
+		rewritten, err := h.script.OnResponse(req, result)
+		if err != nil {
+			r.onError(h, req.Method, "response", err)
+			continue
+		}
+
+		if rewritten != nil {
+			metrics.Registry.RPC.Script(h.name, "rewritten").Mark(1)
+			result, changed = rewritten, true
+		}
+	}
+
+	if !changed {
+		return resp
+	}
+
+	// shallow copy with the rewritten result or error
+	rewrittenResp := *resp
+	rewrittenResp.Result, rewrittenResp.Error = result.Result, nil
+
+	if result.Error != nil {
+		rewrittenResp.Result = nil
+		rewrittenResp.Error = &rpc.JsonError{Code: result.Error.Code, Message: result.Error.Message}
+
+		// keep error data (eg., revert reason) if error code unchanged
+		if resp.Error != nil && resp.Error.Code == result.Error.Code {
+			rewrittenResp.Error.Data = resp.Error.Data
+		}
+	} else if len(rewrittenResp.Result) == 0 {
+		rewrittenResp.Result = []byte("null")
+	}
+
+	return &rewrittenResp
+}
+
+func (r *scriptRunner) onError(h *scriptHook, method, hook string, err error) {
+	outcome := "error"
+	if err == script.ErrBudgetExceeded {
+		outcome = "budgetExceeded"
+	}
+
+	metrics.Registry.RPC.Script(h.name, outcome).Mark(1)
+
+	logrus.WithFields(logrus.Fields{
+		"script": h.name,
+		"method": method,
+		"hook":   hook,
+	}).WithError(err).Warn("Scripting hook failed and skipped")
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/script"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScriptRunner(t *testing.T, source string, groups, methods []string) *scriptRunner {
+	dir, err := ioutil.TempDir("", "script")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	file := filepath.Join(dir, "hook.lua")
+	require.NoError(t, ioutil.WriteFile(file, []byte(source), 0644))
+
+	runner, err := newScriptRunner(scriptConfig{
+		Budget:  script.Budget{Timeout: 50 * time.Millisecond, CallStackSize: 120, RegistrySize: 5120},
+		Scripts: []scriptHookConfig{{File: file, Groups: groups, Methods: methods}},
+	}, true)
+	require.NoError(t, err)
+
+	return runner
+}
+
+func newTestScriptContext(group node.Group) context.Context {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	return context.WithValue(ctx, ctxKeyClientGroup, group)
+}
+
+func TestScriptRunnerRewrite(t *testing.T) {
+	runner := newTestScriptRunner(t, `
+function on_request(req)
+	if req.params[2] == "pending" then
+		req.params[2] = "latest"
+		return req
+	end
+end
+
+function on_response(req, resp)
+	if resp.error ~= nil then
+		resp.error.message = "rewritten"
+		return resp
+	end
+end
+`, []string{"ethhttp"}, []string{"eth_getBalance"})
+
+	var received json.RawMessage
+	handler := runner.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		received = msg.Params
+		return &rpc.JsonRpcMessage{ID: msg.ID, Error: &rpc.JsonError{Code: -32000, Message: "failed", Data: "0x"}}
+	})
+
+	msg := &rpc.JsonRpcMessage{
+		ID:     json.RawMessage("1"),
+		Method: "eth_getBalance",
+		Params: json.RawMessage(`["0x01","pending"]`),
+	}
+
+	resp := handler(newTestScriptContext(node.GroupEthHttp), msg)
+	assert.JSONEq(t, `["0x01","latest"]`, string(received))
+	assert.JSONEq(t, `["0x01","pending"]`, string(msg.Params)) // original request unchanged
+	assert.Equal(t, &rpc.JsonError{Code: -32000, Message: "rewritten", Data: "0x"}, resp.Error)
+
+	// other route group
+	handler(newTestScriptContext(node.Group("archive")), msg)
+	assert.JSONEq(t, `["0x01","pending"]`, string(received))
+
+	// other method
+	msg.Method = "eth_getCode"
+	handler(newTestScriptContext(node.GroupEthHttp), msg)
+	assert.JSONEq(t, `["0x01","pending"]`, string(received))
+}
+
+func TestScriptRunnerReject(t *testing.T) {
+	runner := newTestScriptRunner(t, `
+function on_request(req)
+	return nil, {code = -32602, message = "rejected"}
+end
+`, nil, nil)
+
+	var called bool
+	handler := runner.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		called = true
+		return &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(`"0x0"`)}
+	})
+
+	resp := handler(newTestScriptContext(node.GroupEthHttp), &rpc.JsonRpcMessage{
+		ID: json.RawMessage("1"), Method: "eth_getLogs", Params: json.RawMessage(`[{}]`),
+	})
+	assert.False(t, called)
+	assert.Equal(t, -32602, resp.Error.Code)
+	assert.Equal(t, "rejected", resp.Error.Message)
+}
+
+func TestScriptRunnerFailOpen(t *testing.T) {
+	runner := newTestScriptRunner(t, `
+function on_request(req)
+	while true do end
+end
+`, nil, nil)
+
+	handler := runner.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{ID: msg.ID, Result: msg.Params}
+	})
+
+	resp := handler(newTestScriptContext(node.GroupEthHttp), &rpc.JsonRpcMessage{
+		ID: json.RawMessage("1"), Method: "eth_call", Params: json.RawMessage(`[{},"latest"]`),
+	})
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, `[{},"latest"]`, string(resp.Result))
+}
@@ -3,8 +3,11 @@ package rpc
 import (
 	infuraNode "github.com/Conflux-Chain/confura/node"
 	"github.com/Conflux-Chain/confura/rpc/handler"
+	"github.com/Conflux-Chain/confura/util/metering"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,6 +26,7 @@ const (
 // public will be exposed.
 func MustNewNativeSpaceServer(
 	registry *rate.Registry,
+	meter *metering.Meter,
 	clientProvider *infuraNode.CfxClientProvider,
 	gashandler *handler.GasStationHandler,
 	exposedModules []string,
@@ -38,9 +42,21 @@ func MustNewNativeSpaceServer(
 		)
 	}
 
-	middleware := httpMiddleware(registry, clientProvider)
+	compression := middlewares.MustNewCompressionFromViper()
+	cors := middlewares.MustNewCorsFromViper(registry)
+	rateLimitHeaders := middlewares.MustNewRateLimitHeadersFromViper()
+	middleware := httpMiddleware(registry, meter, clientProvider)
 
-	return rpc.MustNewServer(nativeSpaceRpcServerName, exposedApis, middleware)
+	server := rpc.MustNewServer(
+		nativeSpaceRpcServerName, withDiscoveryAPI(nativeSpaceRpcServerName, exposedApis),
+		compression, cors, rateLimitHeaders, discoveryMiddleware, middleware,
+		memoryBudget.Http, requestLimiter.Http, signatureVerifier.Http,
+	)
+
+	// unsubscribe upstream once websocket connections closed on graceful shutdown
+	server.RegisterOnShutdown(cfxDelegateLoops.shutdown)
+
+	return server
 }
 
 // MustNewEvmSpaceServer new evm space RPC server by specifying router, and exposed modules.
@@ -48,9 +64,44 @@ func MustNewNativeSpaceServer(
 // list is empty, all RPC API endpoints designated public will be exposed.
 func MustNewEvmSpaceServer(
 	registry *rate.Registry,
+	meter *metering.Meter,
 	clientProvider *infuraNode.EthClientProvider,
 	exposedModules []string,
 	option ...EthAPIOption,
+) *rpc.Server {
+	// compress responses, handle CORS and report rate limit status including the shims
+	// translating other protocols into JSON-RPC calls
+	outerMiddlewares := []handlers.Middleware{
+		middlewares.MustNewCompressionFromViper(),
+		middlewares.MustNewCorsFromViper(registry),
+		middlewares.MustNewRateLimitHeadersFromViper(),
+	}
+
+	// serve additional chains by URL path prefix or hostname
+	if chains := mustNewEvmChainsFromViper(registry, meter, exposedModules); len(chains) > 0 {
+		outerMiddlewares = append(outerMiddlewares, evmChainDispatcher(chains))
+	}
+
+	server := mustNewEvmSpaceServer(
+		evmSpaceRpcServerName, clientProvider, exposedModules,
+		outerMiddlewares, []handlers.Middleware{httpMiddleware(registry, meter, clientProvider)},
+		option...,
+	)
+
+	// unsubscribe upstream once websocket connections closed on graceful shutdown
+	server.RegisterOnShutdown(ethDelegateLoops.shutdown)
+
+	return server
+}
+
+// mustNewEvmSpaceServer new evm space RPC server with the outer middlewares executed before
+// the protocol shims, and the context middlewares to inject values for RPC call middlewares.
+func mustNewEvmSpaceServer(
+	name string,
+	clientProvider *infuraNode.EthClientProvider,
+	exposedModules []string,
+	outerMiddlewares, ctxMiddlewares []handlers.Middleware,
+	option ...EthAPIOption,
 ) *rpc.Server {
 	// retrieve all available evm space rpc apis
 	allApis, err := evmSpaceApis(clientProvider, option...)
@@ -65,9 +116,29 @@ func MustNewEvmSpaceServer(
 		)
 	}
 
-	middleware := httpMiddleware(registry, clientProvider)
+	httpMiddlewares := append([]handlers.Middleware{}, outerMiddlewares...)
+
+	// serve GraphQL endpoint on top of JSON-RPC
+	if conf := mustNewGraphqlConfigFromViper(); conf.Enabled {
+		exposedApis[graphqlNamespace] = newGraphqlAPI(conf)
+		httpMiddlewares = append(httpMiddlewares, graphqlMiddleware)
+	}
+
+	// serve REST APIs on top of JSON-RPC
+	if conf := mustNewRestConfigFromViper(); conf.Enabled {
+		httpMiddlewares = append(httpMiddlewares, restMiddleware)
+	}
+
+	// serve OpenRPC document for capability discovery
+	httpMiddlewares = append(httpMiddlewares, discoveryMiddleware)
+
+	httpMiddlewares = append(httpMiddlewares, ctxMiddlewares...)
+	httpMiddlewares = append(httpMiddlewares, memoryBudget.Http, requestLimiter.Http, signatureVerifier.Http)
+
+	// stream large responses once request verified
+	httpMiddlewares = append(httpMiddlewares, mustNewResponseStreamerFromViper("ethrpc.streaming").Http)
 
-	return rpc.MustNewServer(evmSpaceRpcServerName, exposedApis, middleware)
+	return rpc.MustNewServer(name, withDiscoveryAPI(name, exposedApis), httpMiddlewares...)
 }
 
 type CfxBridgeServerConfig struct {
@@ -88,7 +159,10 @@ func MustNewNativeSpaceBridgeServer(config *CfxBridgeServerConfig) *rpc.Server {
 		logrus.WithError(err).Fatal("Failed to new CFX bridge RPC server with bad exposed modules")
 	}
 
-	return rpc.MustNewServer(nativeSpaceBridgeRpcServerName, exposedApis)
+	return rpc.MustNewServer(
+		nativeSpaceBridgeRpcServerName, withDiscoveryAPI(nativeSpaceBridgeRpcServerName, exposedApis),
+		middlewares.MustNewCorsFromViper(nil),
+	)
 }
 
 // MustNewDebugServer new debug RPC server for internal debugging use.
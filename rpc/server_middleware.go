@@ -3,58 +3,253 @@ package rpc
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/Conflux-Chain/confura/util/accesslog"
+	"github.com/Conflux-Chain/confura/util/analytics"
+	"github.com/Conflux-Chain/confura/util/memory"
+	"github.com/Conflux-Chain/confura/util/metering"
 	"github.com/Conflux-Chain/confura/util/rate"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	"github.com/Conflux-Chain/confura/util/slowlog"
+	"github.com/Conflux-Chain/confura/util/traffic"
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
 	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
 )
 
 const (
 	ctxKeyClientProvider = handlers.CtxKey("Infura-RPC-Client-Provider")
 	ctxKeyClient         = handlers.CtxKey("Infura-RPC-Client")
 	ctxKeyClientGroup    = handlers.CtxKey("Infura-RPC-Client-Group")
+	ctxKeyEthCache       = handlers.CtxKey("Infura-RPC-Eth-Cache")
+	ctxKeyResponseStream = handlers.CtxKey("Infura-RPC-Response-Stream")
 )
 
-// go-rpc-provider only supports static middlewares for RPC server.
-func init() {
-	// middlewares executed in order
+var (
+	// requestLimiter enforces request and response size limits for RPC server.
+	requestLimiter *middlewares.RequestLimiter
 
-	// panic recovery
-	rpc.HookHandleCallMsg(middlewares.Recover)
+	// memoryBudget applies backpressure to large requests under memory pressure, nil if disabled.
+	memoryBudget *middlewares.MemoryBudget
 
-	// anti-injection
-	rpc.HookHandleCallMsg(middlewares.AntiInjection)
+	// signatureVerifier verifies HMAC signed requests for RPC server, nil if disabled.
+	signatureVerifier *middlewares.SignatureVerifier
 
-	// auth
-	rpc.HookHandleCallMsg(middlewares.Auth())
+	// ethStickyRouter routes requests to the full node that accepted transaction for evm space.
+	ethStickyRouter *stickyRouter
 
-	// allow lists
-	rpc.HookHandleCallMsg(middlewares.Allowlists)
+	// ethTxPoolConf configures the route group and restricted methods of evm space txpool.
+	ethTxPoolConf *txPoolConfig
 
-	// rate limit
-	rpc.HookHandleCallMsg(middlewares.DailyMaxReqRateLimit)
-	rpc.HookHandleCallMsg(middlewares.QpsRateLimit)
-
-	// metrics
-	rpc.HookHandleBatch(middlewares.MetricsBatch)
-	rpc.HookHandleCallMsg(middlewares.Metrics)
+	// ethRollupProxy proxies rollup RPC methods to the rollup nodes of L2 chain, nil if disabled.
+	ethRollupProxy *ethRollupAPI
+)
 
-	// log
-	rpc.HookHandleBatch(middlewares.LogBatch)
-	rpc.HookHandleCallMsg(middlewares.Log)
+// go-rpc-provider only supports static middlewares for RPC server, so the ordered middleware
+// chain is hooked instead, which could be extended by plugins or `middlewares.Use`.
+func init() {
+	chain := middlewares.DefaultChain
+
+	// ingress
+	cfxTrafficRecorder = traffic.MustNewRecorderFromViper("rpc.recorder", "cfx")
+	ethTrafficRecorder = traffic.MustNewRecorderFromViper("ethrpc.recorder", "eth")
+	chain.Use(
+		// panic recovery
+		middlewares.Middleware{Name: "recover", Stage: middlewares.StageIngress, Call: middlewares.Recover},
+		// in-flight calls tracking for graceful shutdown
+		middlewares.Middleware{Name: "inflight", Stage: middlewares.StageIngress, Call: middlewares.InFlight},
+		// OpenRPC service discovery alias
+		middlewares.Middleware{Name: "discovery", Stage: middlewares.StageIngress, Call: discoveryAlias},
+		// anti-injection
+		middlewares.Middleware{Name: "antiInjection", Stage: middlewares.StageIngress, Call: middlewares.AntiInjection},
+		// traffic recording for replay
+		middlewares.Middleware{Name: "trafficRecord", Stage: middlewares.StageIngress, Call: trafficRecordMiddleware},
+	)
 
-	// cfx/eth client
-	rpc.HookHandleCallMsg(clientMiddleware)
+	// auth
+	signatureVerifier = middlewares.MustNewSignatureVerifierFromViper()
+	chain.Use(
+		middlewares.Middleware{Name: "auth", Stage: middlewares.StageAuth, Call: middlewares.Auth()},
+		// signed requests
+		middlewares.Middleware{Name: "signature", Stage: middlewares.StageAuth, Call: signatureVerifier.Call},
+	)
+
+	// ACL
+	chain.Use(
+		// allow lists
+		middlewares.Middleware{Name: "allowlists", Stage: middlewares.StageACL, Call: middlewares.Allowlists},
+		// response redaction
+		middlewares.Middleware{Name: "redaction", Stage: middlewares.StageACL, Call: middlewares.Redaction},
+	)
 
-	// invalid json rpc request without `ID`
-	rpc.HookHandleCallMsg(rpc.PreventMessagesWithouID)
+	// rate limit
+	requestLimiter = middlewares.MustNewRequestLimiterFromViper()
+	costEstimator := middlewares.MustNewCostEstimatorFromViper()
+	rate.MustInitAdaptiveControllerFromViper()
+	memory.MustInitFromViper()
+	memoryBudget = middlewares.MustNewMemoryBudgetFromViper()
+	chain.Use(
+		// failure metering, outermost to account the rejections of rate limit
+		middlewares.Middleware{Name: "failureMetering", Stage: middlewares.StageRateLimit, Call: middlewares.FailureMetering},
+		// size limits
+		middlewares.Middleware{
+			Name: "limits", Stage: middlewares.StageRateLimit,
+			Call: requestLimiter.Call, Batch: requestLimiter.Batch,
+		},
+		// cost estimation
+		middlewares.Middleware{
+			Name: "cost", Stage: middlewares.StageRateLimit,
+			Call: costEstimator.Call, Batch: costEstimator.Batch,
+		},
+		middlewares.Middleware{Name: "adaptiveShedding", Stage: middlewares.StageRateLimit, Call: middlewares.AdaptiveShedding},
+		middlewares.Middleware{Name: "dailyRateLimit", Stage: middlewares.StageRateLimit, Call: middlewares.DailyMaxReqRateLimit},
+		middlewares.Middleware{Name: "qpsRateLimit", Stage: middlewares.StageRateLimit, Call: middlewares.QpsRateLimit},
+		// usage metering
+		middlewares.Middleware{Name: "metering", Stage: middlewares.StageRateLimit, Call: middlewares.Metering},
+		// admission control under overload
+		middlewares.Middleware{
+			Name: "admission", Stage: middlewares.StageRateLimit,
+			Call: middlewares.MustNewAdmissionControlFromViper().Call,
+		},
+		// backpressure under memory pressure
+		middlewares.Middleware{Name: "memoryBudget", Stage: middlewares.StageRateLimit, Call: memoryBudget.Call},
+	)
+
+	// observe
+	slowQueryLog = slowlog.MustNewLoggerFromViper()
+	chain.Use(
+		middlewares.Middleware{
+			Name: "metrics", Stage: middlewares.StageObserve,
+			Call: middlewares.Metrics, Batch: middlewares.MetricsBatch,
+		},
+		middlewares.Middleware{
+			Name: "log", Stage: middlewares.StageObserve,
+			Call: middlewares.Log, Batch: middlewares.LogBatch,
+		},
+		middlewares.Middleware{
+			Name: "accessLog", Stage: middlewares.StageObserve,
+			Call: accesslog.MustNewLoggerFromViper().Call,
+		},
+		// slow query log
+		middlewares.Middleware{Name: "slowLog", Stage: middlewares.StageObserve, Call: slowQueryMiddleware},
+		// analytics events
+		middlewares.Middleware{
+			Name: "analytics", Stage: middlewares.StageObserve,
+			Call: analytics.MustNewPipelineFromViper().Call,
+		},
+	)
+
+	// route
+	ethRollupProxy = mustNewRollupAPIFromViper("ethrpc.rollup")
+	ethStickyRouter = mustNewStickyRouterFromViper("ethrpc.sticky")
+	ethTxPoolConf = mustNewTxPoolConfigFromViper("ethrpc.txpool")
+	batchFanout := mustNewBatchFanoutFromViper()
+	chain.Use(
+		// upstream error normalization
+		middlewares.Middleware{
+			Name: "errorNormalization", Stage: middlewares.StageRoute,
+			Call: middlewares.MustNewErrorNormalizerFromViper().Call,
+		},
+		// batch fan-out
+		middlewares.Middleware{
+			Name: "batchFanout", Stage: middlewares.StageRoute,
+			Call: batchFanout.Call, Batch: batchFanout.Batch,
+		},
+		// method timeouts
+		middlewares.Middleware{
+			Name: "timeouts", Stage: middlewares.StageRoute,
+			Call: middlewares.MustNewMethodTimeoutFromViper().Call,
+		},
+		// shadow traffic
+		middlewares.Middleware{
+			Name: "shadow", Stage: middlewares.StageRoute,
+			Call: mustNewShadowMirrorFromViper("rpc.shadow", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethShadow", Stage: middlewares.StageRoute,
+			Call: mustNewShadowMirrorFromViper("ethrpc.shadow", true).Call,
+		},
+		// finality-aware block tags
+		middlewares.Middleware{
+			Name: "finality", Stage: middlewares.StageRoute,
+			Call: mustNewFinalityResolverFromViper("ethrpc.finality", ethRollupProxy).Call,
+		},
+		// cfx/eth client
+		middlewares.Middleware{Name: "client", Stage: middlewares.StageRoute, Call: clientMiddleware},
+	)
+
+	// proxy
+	chain.Use(
+		// request/response rewriting by scripts
+		middlewares.Middleware{
+			Name: "script", Stage: middlewares.StageProxy,
+			Call: mustNewScriptRunnerFromViper("rpc.scripting", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethScript", Stage: middlewares.StageProxy,
+			Call: mustNewScriptRunnerFromViper("ethrpc.scripting", true).Call,
+		},
+		// identical read requests coalescing
+		middlewares.Middleware{
+			Name: "dedup", Stage: middlewares.StageProxy,
+			Call: mustNewRequestDeduperFromViper("rpc.dedup", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethDedup", Stage: middlewares.StageProxy,
+			Call: mustNewRequestDeduperFromViper("ethrpc.dedup", true).Call,
+		},
+		// hedged requests to another full node
+		middlewares.Middleware{
+			Name: "hedge", Stage: middlewares.StageProxy,
+			Call: mustNewRequestHedgerFromViper("rpc.hedge", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethHedge", Stage: middlewares.StageProxy,
+			Call: mustNewRequestHedgerFromViper("ethrpc.hedge", true).Call,
+		},
+		// response verification across full nodes
+		middlewares.Middleware{
+			Name: "verifier", Stage: middlewares.StageProxy,
+			Call: mustNewResponseVerifierFromViper("rpc.verifier", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethVerifier", Stage: middlewares.StageProxy,
+			Call: mustNewResponseVerifierFromViper("ethrpc.verifier", true).Call,
+		},
+		// invalid json rpc request without `ID`
+		middlewares.Middleware{Name: "requireId", Stage: middlewares.StageProxy, Call: rpc.PreventMessagesWithouID},
+		// raw forwarding of pass-through methods
+		middlewares.Middleware{
+			Name: "rawProxy", Stage: middlewares.StageProxy,
+			Call: mustNewRawProxyFromViper("rpc.rawProxy", false).Call,
+		},
+		middlewares.Middleware{
+			Name: "ethRawProxy", Stage: middlewares.StageProxy,
+			Call: mustNewRawProxyFromViper("ethrpc.rawProxy", true).Call,
+		},
+	)
+
+	// extra middlewares of plugins
+	middlewares.MustLoadPluginsFromViper(chain)
+
+	rpc.HookHandleBatch(chain.HandleBatch)
+	rpc.HookHandleCallMsg(chain.HandleCallMsg)
+
+	logrus.WithField("middlewares", chain.Names()).Debug("RPC middleware chain hooked")
 }
 
 // Inject values into context for static RPC call middlewares, e.g. rate limit
-func httpMiddleware(registry *rate.Registry, clientProvider interface{}) handlers.Middleware {
+func httpMiddleware(
+	registry *rate.Registry, meter *metering.Meter, clientProvider interface{},
+) handlers.Middleware {
+	// chain head fetched on demand and shared among requests
+	headCache := newChainHeadCache(clientProvider)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -66,15 +261,24 @@ func httpMiddleware(registry *rate.Registry, clientProvider interface{}) handler
 			ctx = context.WithValue(ctx, handlers.CtxKeyReqOrigin, r.Header.Get("Origin"))
 			ctx = context.WithValue(ctx, handlers.CtxKeyUserAgent, r.Header.Get("User-Agent"))
 			ctx = context.WithValue(ctx, handlers.CtxKeyRealIP, handlers.GetIPAddress(r))
+			ctx = context.WithValue(ctx, handlers.CtxKeyTraceId, handlers.GetTraceId(r))
 
 			if registry != nil {
 				ctx = context.WithValue(ctx, handlers.CtxKeyRateRegistry, registry)
 			}
 
+			if meter != nil {
+				ctx = context.WithValue(ctx, handlers.CtxKeyUsageMeter, meter)
+			}
+
 			if clientProvider != nil {
 				ctx = context.WithValue(ctx, ctxKeyClientProvider, clientProvider)
 			}
 
+			if headCache != nil {
+				ctx = handlers.WithChainHead(ctx, headCache.Head)
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -82,14 +286,39 @@ func httpMiddleware(registry *rate.Registry, clientProvider interface{}) handler
 
 func clientMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if localRpcMethods[msg.Method] { // answered by gateway without any full node
+			return next(ctx, msg)
+		}
+
+		if ethRollupProxy.isRollupRpcMethod(msg.Method) { // answered by rollup nodes
+			return next(ctx, msg)
+		}
+
 		var client interface{}
 		var grp node.Group
 		var err error
 
+		// never serve the chain head by full nodes lagging behind
+		latest := isLatestTaggedRequest(msg)
+
 		if cfxProvider, ok := ctx.Value(ctxKeyClientProvider).(*node.CfxClientProvider); ok {
-			client, grp, err = getCfxClientFromProviderWithContext(ctx, msg.Method, cfxProvider)
+			var cfx sdk.ClientOperator
+			if cfx, grp, err = getCfxClientFromProviderWithContext(ctx, msg.Method, cfxProvider); err == nil && latest {
+				cfx = cfxProvider.InSyncClient(ctx, grp, cfx)
+			}
+
+			client = cfx
 		} else if ethProvider, ok := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); ok {
-			client, grp, err = getEthClientFromProviderWithContext(ctx, msg.Method, ethProvider)
+			var w3c *node.Web3goClient
+			if w3c, grp, err = getEthClientFromProviderWithContext(ctx, msg.Method, ethProvider); err == nil {
+				if latest {
+					w3c = ethProvider.InSyncClient(ctx, grp, w3c)
+				}
+
+				w3c, err = capableEthClient(ethProvider, w3c, grp, msg.Method)
+			}
+
+			client = w3c
 		} else {
 			return next(ctx, msg)
 		}
@@ -98,10 +327,50 @@ func clientMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 			return msg.ErrorResponse(err)
 		}
 
-		ctx = context.WithValue(ctx, ctxKeyClient, client)
 		ctx = context.WithValue(ctx, ctxKeyClientGroup, grp)
 
-		return next(ctx, msg)
+		if url, ok := clientUrl(client); ok {
+			handlers.RecordUpstream(ctx, rpcutil.Url2NodeName(url))
+		}
+
+		ctx = context.WithValue(ctx, ctxKeyClient, bindClient(ctx, client))
+
+		w3c, ok := client.(*node.Web3goClient)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		resp := next(ctx, msg)
+
+		// stick to the full node that accepted transaction to read your writes
+		if isEthWriteRpcMethod(msg.Method) && resp != nil && resp.Error == nil {
+			ethStickyRouter.Stick(ctx, grp, w3c.URL)
+		}
+
+		return resp
+	}
+}
+
+// bindClient binds RPC requests of the full node client to the context if any deadline, so that
+// the deadline is propagated to full node, and requests are cancelled once timed out.
+func bindClient(ctx context.Context, client interface{}) interface{} {
+	if _, ok := ctx.Deadline(); !ok {
+		return client
+	}
+
+	return bindClientContext(ctx, client)
+}
+
+// bindClientContext binds RPC requests of full node client to the context regardless of deadline,
+// eg., to abort the in-flight request once context cancelled.
+func bindClientContext(ctx context.Context, client interface{}) interface{} {
+	switch c := client.(type) {
+	case *node.Web3goClient:
+		return c.WithContext(ctx)
+	case sdk.ClientOperator:
+		return node.CfxClientWithContext(ctx, c)
+	default:
+		return client
 	}
 }
 
@@ -117,21 +386,115 @@ func GetClientGroupFromContext(ctx context.Context) node.Group {
 	return ctx.Value(ctxKeyClientGroup).(node.Group)
 }
 
+// GetEthCacheFromContext returns the evm space memory cache of the requested chain, or the
+// default one if not specified.
+func GetEthCacheFromContext(ctx context.Context) *cache.EthCache {
+	if c, ok := ctx.Value(ctxKeyEthCache).(*cache.EthCache); ok {
+		return c
+	}
+
+	return cache.EthDefault
+}
+
+// routeGroupFromContext resolves the node route group that request is pinned to, along with the
+// key to route among the group nodes. Route group configured for the API key takes precedence
+// over the one pinned by the assigned allowlist.
+func routeGroupFromContext(
+	ctx context.Context, getRouteGroup func(key string) (node.Group, bool),
+) (grp node.Group, routeKey string, ok bool) {
+	authId, authenticated := handlers.GetAuthIdFromContext(ctx)
+	if authenticated {
+		if grp, ok := getRouteGroup(authId); ok && len(grp) > 0 {
+			return grp, authId, true
+		}
+	}
+
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok {
+		return "", "", false
+	}
+
+	al, ok := registry.AllowList(ctx)
+	if !ok || len(al.RouteGroup) == 0 {
+		return "", "", false
+	}
+
+	routeKey = authId
+	if !authenticated {
+		routeKey, _ = handlers.GetIPAddressFromContext(ctx)
+	}
+
+	return node.Group(al.RouteGroup), routeKey, true
+}
+
+// isRestrictedRpcMethod checks if the RPC method is in the restricted methods list.
+func isRestrictedRpcMethod(method string, restricted []string) bool {
+	for _, m := range restricted {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isExplicitlyAllowed checks if the restricted RPC method is explicitly allowed by the allowlist
+// of caller, which is rejected if no allowlist available.
+func isExplicitlyAllowed(ctx context.Context, method string) bool {
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok {
+		return false
+	}
+
+	al, ok := registry.AllowList(ctx)
+	return ok && al.ExplicitlyAllows(method)
+}
+
 func getEthClientFromProviderWithContext(
 	ctx context.Context, rpcMethod string, p *node.EthClientProvider) (*node.Web3goClient, node.Group, error) {
+	// transactions must be sent to the active sequencer of L2 chain if available
+	if isEthWriteRpcMethod(rpcMethod) {
+		if url, ok := p.ActiveSequencer(); ok {
+			client, err := p.GetClientByURL(url, node.GroupEthSequencer)
+			return client, node.GroupEthSequencer, err
+		}
+	}
+
 	grp := node.GroupEthHttp
+	ruleGrp, ruled := p.MethodRouteGroup(rpcMethod)
 
 	switch {
-	case rpcMethod == rpcMethodEthGetLogs:
+	case ruled: // method route rules take precedence over the built-in routes
+		grp = ruleGrp
+	case rpcMethod == rpcMethodEthGetLogs, rpcMethod == rpcMethodGwGetLogs:
 		grp = node.GroupEthLogs
 	case isEthFilterRpcMethod(rpcMethod):
 		grp = node.GroupEthFilter
+	case rpcMethod == rpcMethodEthGetProof:
+		client, err := p.GetClientByIP(ctx, node.GroupEthArchives)
+		if err == node.ErrClientUnavailable {
+			err = errArchiveNodeUnavailable
+		}
+
+		return client, node.GroupEthArchives, err
+	case isEthTxPoolRpcMethod(rpcMethod) && len(ethTxPoolConf.RouteGroup) > 0:
+		grp = node.Group(ethTxPoolConf.RouteGroup)
 	default:
-		if authId, ok := handlers.GetAuthIdFromContext(ctx); ok {
-			grp, ok := p.GetRouteGroup(authId)
-			if ok && len(grp) > 0 {
-				client, err := p.GetClient(authId, grp)
-				return client, grp, err
+		if grp, routeKey, ok := routeGroupFromContext(ctx, p.GetRouteGroup); ok {
+			if sn, ok := ethStickyRouter.Route(ctx, rpcMethod, grp, p.Available); ok {
+				if client, err := p.GetClientByURL(sn.url, sn.group); err == nil {
+					return client, sn.group, nil
+				}
+			}
+
+			client, err := p.GetClient(routeKey, grp)
+			return client, grp, err
+		}
+
+		// read your writes
+		if sn, ok := ethStickyRouter.Route(ctx, rpcMethod, grp, p.Available); ok {
+			if client, err := p.GetClientByURL(sn.url, sn.group); err == nil {
+				return client, sn.group, nil
 			}
 		}
 	}
@@ -143,19 +506,19 @@ func getEthClientFromProviderWithContext(
 func getCfxClientFromProviderWithContext(
 	ctx context.Context, rpcMethod string, p *node.CfxClientProvider) (sdk.ClientOperator, node.Group, error) {
 	grp := node.GroupCfxHttp
+	ruleGrp, ruled := p.MethodRouteGroup(rpcMethod)
 
 	switch {
+	case ruled: // method route rules take precedence over the built-in routes
+		grp = ruleGrp
 	case rpcMethod == rpcMethodCfxGetLogs:
 		grp = node.GroupCfxLogs
 	case isCfxFilterRpcMethod(rpcMethod):
 		grp = node.GroupCfxFilter
 	default:
-		if authId, ok := handlers.GetAuthIdFromContext(ctx); ok {
-			grp, ok := p.GetRouteGroup(authId)
-			if ok && len(grp) > 0 {
-				client, err := p.GetClient(authId, grp)
-				return client, grp, err
-			}
+		if grp, routeKey, ok := routeGroupFromContext(ctx, p.GetRouteGroup); ok {
+			client, err := p.GetClient(routeKey, grp)
+			return client, grp, err
 		}
 	}
 
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/openweb3/go-rpc-provider/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProofRoutedToArchives(t *testing.T) {
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp:     {"http://127.0.0.1:18545"},
+		node.GroupEthArchives: {"http://127.0.0.1:28545"},
+	}))
+
+	client, grp, err := getEthClientFromProviderWithContext(context.Background(), rpcMethodEthGetProof, provider)
+	assert.NoError(t, err)
+	assert.Equal(t, node.GroupEthArchives, grp)
+	assert.Equal(t, "http://127.0.0.1:28545", client.URL)
+
+	// never fall back to pruned full nodes
+	provider = node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: {"http://127.0.0.1:18545"},
+	}))
+
+	_, _, err = getEthClientFromProviderWithContext(context.Background(), rpcMethodEthGetProof, provider)
+	assert.Equal(t, errArchiveNodeUnavailable, err)
+}
+
+// fakeNodeRouteReader pins route keys to node route groups in memory.
+type fakeNodeRouteReader map[string]string
+
+func (f fakeNodeRouteReader) FindNodeRoute(routeKey string) (*mysql.NodeRoute, error) {
+	if grp, ok := f[routeKey]; ok {
+		return &mysql.NodeRoute{RouteKey: routeKey, Group: grp}, nil
+	}
+
+	return nil, nil
+}
+
+func (f fakeNodeRouteReader) LoadNodeRoutes(filter mysql.NodeRouteFilter) ([]*mysql.NodeRoute, error) {
+	return nil, nil
+}
+
+func TestRouteGroupPinnedByApiKey(t *testing.T) {
+	provider := node.NewEthClientProvider(fakeNodeRouteReader{"vipKey": "vip"}, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: {"http://127.0.0.1:18545"},
+		"vip":             {"http://127.0.0.1:28545"},
+	}))
+
+	vipCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "vipKey")
+	client, grp, err := getEthClientFromProviderWithContext(vipCtx, "eth_blockNumber", provider)
+	assert.NoError(t, err)
+	assert.Equal(t, node.Group("vip"), grp)
+	assert.Equal(t, "http://127.0.0.1:28545", client.URL)
+
+	// key without route pinned uses the default group
+	otherCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "otherKey")
+	client, grp, err = getEthClientFromProviderWithContext(otherCtx, "eth_blockNumber", provider)
+	assert.NoError(t, err)
+	assert.Equal(t, node.GroupEthHttp, grp)
+	assert.Equal(t, "http://127.0.0.1:18545", client.URL)
+}
+
+// blockingProvider blocks RPC requests until context done, and reports the context error.
+type blockingProvider struct {
+	interfaces.Provider
+
+	cancelled chan error
+}
+
+func (p *blockingProvider) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	<-ctx.Done()
+	p.cancelled <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestMethodTimeoutCancelsCfxCall(t *testing.T) {
+	provider := &blockingProvider{cancelled: make(chan error, 1)}
+	cfx, err := sdk.NewClientWithProvider(provider)
+	assert.NoError(t, err)
+
+	timeout := middlewares.NewMethodTimeout(middlewares.TimeoutConfig{Default: 50 * time.Millisecond})
+	handler := timeout.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		// bound as the client middleware does
+		client := bindClient(ctx, cfx).(sdk.ClientOperator)
+
+		if _, err := client.GetEpochNumber(); err != nil {
+			return msg.ErrorResponse(err)
+		}
+
+		return &rpc.JsonRpcMessage{}
+	})
+
+	resp := handler(context.Background(), &rpc.JsonRpcMessage{Method: "cfx_epochNumber"})
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, middlewares.ErrCodeTimeout, resp.Error.Code)
+	}
+
+	// the call to full node cancelled rather than left running
+	select {
+	case err := <-provider.cancelled:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "call to full node not cancelled once timed out")
+	}
+
+	// not bound without deadline
+	assert.Equal(t, sdk.ClientOperator(cfx), bindClient(context.Background(), cfx))
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// outcomes of response diffing
+	diffOutcomeMatch    = "match"
+	diffOutcomeMismatch = "mismatch"
+	diffOutcomeError    = "error"
+	diffOutcomeSkipped  = "skipped"
+
+	// max length of JSON logged for mismatched responses
+	shadowMaxLogLength = 1024
+)
+
+// shadowConfig configurations of shadow traffic, by which a sampled fraction of read requests
+// is mirrored to the shadow nodes of the route group requests routed to, so as to validate them
+// before put into live rotation. Shadow nodes are managed along with the other node states of
+// route group, eg., by `PUT /v1/{network}/noderoute/groups/{group}/shadow` of admin server.
+type shadowConfig struct {
+	// switch to turn on/off shadow traffic
+	Enabled bool
+	// fraction of read requests to mirror, in range (0, 1]
+	Ratio float64 `default:"0.01"`
+	// methods to mirror, or all read methods if empty
+	Methods []string
+	// timeout of mirrored request
+	Timeout time.Duration `default:"5s"`
+	// max number of in-flight mirrored requests, beyond which requests are not mirrored
+	MaxInflight int64 `default:"100"`
+}
+
+// shadowNode is a full node that receives mirrored requests, of which responses are discarded.
+type shadowNode struct {
+	name   string
+	client *rpc.Client
+}
+
+// shadowNodeProvider provides the shadow nodes of route group, eg., the full node client provider.
+type shadowNodeProvider interface {
+	ShadowNodes(group node.Group) []string
+}
+
+// shadowMirror mirrors sampled read requests to shadow full nodes, and diffs the responses
+// against the live ones with divergences logged.
+type shadowMirror struct {
+	conf     shadowConfig
+	evm      bool // whether to mirror requests of evm space or core space
+	methods  map[string]bool
+	inflight int64
+
+	mu    sync.Mutex
+	nodes map[string]*shadowNode // url => shadow node connected
+}
+
+func mustNewShadowMirrorFromViper(key string, evm bool) *shadowMirror {
+	var conf shadowConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Ratio <= 0 || conf.Ratio > 1 {
+		logrus.WithField("config", conf).Fatal("Invalid shadow traffic config")
+	}
+
+	logrus.WithField("config", conf).Info("Shadow traffic to full nodes enabled")
+
+	return newShadowMirror(conf, evm)
+}
+
+func newShadowMirror(conf shadowConfig, evm bool) *shadowMirror {
+	mirror := &shadowMirror{
+		conf:    conf,
+		evm:     evm,
+		methods: make(map[string]bool),
+		nodes:   make(map[string]*shadowNode),
+	}
+
+	for _, method := range conf.Methods {
+		mirror.methods[method] = true
+	}
+
+	return mirror
+}
+
+// node returns the shadow node of url, which is connected on demand.
+func (m *shadowMirror) node(url string) (*shadowNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sn, ok := m.nodes[url]; ok {
+		return sn, nil
+	}
+
+	client, err := rpc.DialContext(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+
+	sn := &shadowNode{name: rpcutil.Url2NodeName(url), client: client}
+	m.nodes[url] = sn
+
+	return sn, nil
+}
+
+// shadowNodes returns urls of the shadow nodes of route group that request routed to.
+func (m *shadowMirror) shadowNodes(ctx context.Context) []string {
+	provider, ok := ctx.Value(ctxKeyClientProvider).(shadowNodeProvider)
+	if !ok {
+		return nil
+	}
+
+	group, ok := ctx.Value(ctxKeyClientGroup).(node.Group)
+	if !ok { // not routed to full node, eg., answered by gateway
+		return nil
+	}
+
+	return provider.ShadowNodes(group)
+}
+
+// isShadowableRpcMethod checks if the RPC method is free of side effects and not bound to a
+// specific full node, eg., transaction submission, filters and subscriptions.
+func isShadowableRpcMethod(method string) bool {
+	if isEthFilterRpcMethod(method) || isCfxFilterRpcMethod(method) {
+		return false
+	}
+
+	if strings.HasSuffix(method, "_subscribe") || strings.HasSuffix(method, "_unsubscribe") {
+		return false
+	}
+
+	lower := strings.ToLower(method)
+	return !strings.Contains(lower, "send") && !strings.Contains(lower, "submit")
+}
+
+// sampled checks if the request should be mirrored.
+func (m *shadowMirror) sampled(ctx context.Context, method string) bool {
+	if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != m.evm {
+		return false
+	}
+
+	if len(m.methods) > 0 && !m.methods[method] {
+		return false
+	}
+
+	return isShadowableRpcMethod(method) && rand.Float64() < m.conf.Ratio
+}
+
+// Call mirrors the sampled read requests asynchronously to the shadow nodes of route group once
+// responded successfully, which passes through if mirror is nil. Note, it must be executed after
+// the full node client injected into context.
+func (m *shadowMirror) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if m == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		resp := next(ctx, msg)
+
+		if resp == nil || resp.Error != nil || isResponseStreamed(ctx) || !m.sampled(ctx, msg.Method) {
+			return resp
+		}
+
+		for _, url := range m.shadowNodes(ctx) {
+			sn, err := m.node(url)
+			if err != nil {
+				logrus.WithField("url", url).WithError(err).Info("Failed to connect to shadow node")
+				continue
+			}
+
+			if atomic.AddInt64(&m.inflight, 1) > m.conf.MaxInflight {
+				atomic.AddInt64(&m.inflight, -1)
+				metrics.Registry.RPC.Shadow(sn.name, diffOutcomeSkipped).Mark(1)
+				continue
+			}
+
+			go func(sn *shadowNode) {
+				defer atomic.AddInt64(&m.inflight, -1)
+				m.mirror(sn, msg, resp.Result)
+			}(sn)
+		}
+
+		return resp
+	}
+}
+
+// mirror sends request to the shadow full node, and diffs the response with the live one.
+func (m *shadowMirror) mirror(sn *shadowNode, msg *rpc.JsonRpcMessage, expected json.RawMessage) string {
+	outcome := diffOutcomeMatch
+	defer func() {
+		metrics.Registry.RPC.Shadow(sn.name, outcome).Mark(1)
+	}()
+
+	logger := logrus.WithFields(logrus.Fields{
+		"node":   sn.name,
+		"method": msg.Method,
+		"params": truncateJSON(msg.Params),
+	})
+
+	var args []json.RawMessage
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &args); err != nil {
+			outcome = diffOutcomeSkipped // named params not supported
+			return outcome
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.conf.Timeout)
+	defer cancel()
+
+	var actual json.RawMessage
+	if err := sn.client.CallContext(ctx, &actual, msg.Method, rawArgs(args)...); err != nil {
+		outcome = diffOutcomeError
+		logger.WithError(err).Info("Shadow node failed to respond mirrored request")
+		return outcome
+	}
+
+	if !jsonEqual(expected, actual) {
+		outcome = diffOutcomeMismatch
+		logger.WithFields(logrus.Fields{
+			"expected": truncateJSON(expected),
+			"actual":   truncateJSON(actual),
+		}).Warn("Shadow node response diverged from live one")
+	}
+
+	return outcome
+}
+
+func rawArgs(args []json.RawMessage) []interface{} {
+	res := make([]interface{}, len(args))
+	for i := range args {
+		res[i] = args[i]
+	}
+
+	return res
+}
+
+// jsonEqual checks if two JSON values are semantically equal, regardless of formatting and the
+// letter case of hex strings.
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(normalizeJSON(va), normalizeJSON(vb))
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if strings.HasPrefix(val, "0x") || strings.HasPrefix(val, "0X") {
+			return strings.ToLower(val)
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = normalizeJSON(val[i])
+		}
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = normalizeJSON(val[k])
+		}
+	}
+
+	return v
+}
+
+func truncateJSON(data json.RawMessage) string {
+	if len(data) > shadowMaxLogLength {
+		return string(data[:shadowMaxLogLength]) + "..."
+	}
+
+	return string(data)
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonEqual(t *testing.T) {
+	assert.True(t, jsonEqual(json.RawMessage(`{"a": "0xAB", "b": [1, 2]}`), json.RawMessage(`{"b":[1,2],"a":"0xab"}`)))
+	assert.True(t, jsonEqual(json.RawMessage(`null`), json.RawMessage(`null`)))
+	assert.False(t, jsonEqual(json.RawMessage(`{"a": "AB"}`), json.RawMessage(`{"a": "ab"}`)))
+	assert.False(t, jsonEqual(json.RawMessage(`[1, 2]`), json.RawMessage(`[2, 1]`)))
+	assert.False(t, jsonEqual(json.RawMessage(`"0x1"`), json.RawMessage(`invalid`)))
+}
+
+func TestIsShadowableRpcMethod(t *testing.T) {
+	assert.True(t, isShadowableRpcMethod("eth_getBalance"))
+	assert.True(t, isShadowableRpcMethod("cfx_getStatus"))
+	assert.False(t, isShadowableRpcMethod("eth_sendRawTransaction"))
+	assert.False(t, isShadowableRpcMethod("cfx_sendRawTransaction"))
+	assert.False(t, isShadowableRpcMethod("eth_submitTransaction"))
+	assert.False(t, isShadowableRpcMethod("eth_newFilter"))
+	assert.False(t, isShadowableRpcMethod("eth_subscribe"))
+}
+
+func TestShadowMirror(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0xA"}
+		if req.Method == "eth_chainId" {
+			resp = map[string]interface{}{
+				"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32000, "message": "oops"},
+			}
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	mirror := newShadowMirror(shadowConfig{Ratio: 1, Timeout: time.Second, MaxInflight: 1}, true)
+
+	sn, err := mirror.node(server.URL)
+	assert.NoError(t, err)
+
+	msg := &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x1", "latest"]`)}
+	assert.Equal(t, diffOutcomeMatch, mirror.mirror(sn, msg, json.RawMessage(`"0xa"`)))
+	assert.Equal(t, diffOutcomeMismatch, mirror.mirror(sn, msg, json.RawMessage(`"0xb"`)))
+
+	msg = &rpc.JsonRpcMessage{Method: "eth_chainId"}
+	assert.Equal(t, diffOutcomeError, mirror.mirror(sn, msg, json.RawMessage(`"0x1"`)))
+
+	msg = &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`{"address": "0x1"}`)}
+	assert.Equal(t, diffOutcomeSkipped, mirror.mirror(sn, msg, json.RawMessage(`"0xa"`)))
+}
+
+func TestShadowMirrorRouteGroup(t *testing.T) {
+	var mirrored int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		atomic.AddInt32(&mirrored, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0xa"})
+	}))
+	defer server.Close()
+
+	// shadow nodes managed along with the other node states of route group
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{}))
+	assert.NoError(t, provider.ReloadInflightLimits(func() (map[string]*mysql.NodeRouteGroup, error) {
+		return map[string]*mysql.NodeRouteGroup{
+			"vip": {Name: "vip", Nodes: []string{"http://127.0.0.1:1"}, Shadow: []string{server.URL}},
+		}, nil
+	}))
+
+	mirror := newShadowMirror(shadowConfig{Ratio: 1, Timeout: time.Second, MaxInflight: 10}, true)
+	handler := mirror.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(`"0xa"`)}
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKeyClientProvider, provider)
+	msg := &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x1", "latest"]`)}
+
+	// mirrored to shadow nodes of the route group only
+	handler(context.WithValue(ctx, ctxKeyClientGroup, node.GroupEthHttp), msg)
+	handler(context.WithValue(ctx, ctxKeyClientGroup, node.Group("vip")), msg)
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&mirrored) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&mirrored))
+}
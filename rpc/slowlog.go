@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/slowlog"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+var (
+	// slowQueryLog logs the RPC calls exceeding latency or block range threshold.
+	slowQueryLog *slowlog.Logger
+)
+
+// SetSlowQueryStore sets the store to persist slow queries of the space, if slow query log
+// enabled with store sink.
+func SetSlowQueryStore(space string, store slowlog.Store) {
+	slowQueryLog.SetStore(space, store)
+}
+
+// slowQueryMiddleware logs the slow RPC calls, which passes through if slow query log disabled.
+func slowQueryMiddleware(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if slowQueryLog == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		start := time.Now()
+
+		ctx = handlers.WithUpstreamRecorder(ctx)
+		resp := next(ctx, msg)
+
+		duration, blockRange := time.Since(start), blockRangeOf(msg)
+		if !slowQueryLog.IsSlow(duration, blockRange) {
+			return resp
+		}
+
+		query := &slowlog.Query{
+			Time:       start,
+			Space:      slowlog.SpaceCfx,
+			Method:     msg.Method,
+			Params:     slowQueryLog.TruncateParams(msg.Params),
+			DurationMs: int64(duration / time.Millisecond),
+			BlockRange: blockRange,
+		}
+
+		if _, ok := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); ok {
+			query.Space = slowlog.SpaceEth
+		}
+
+		query.Upstream, _ = handlers.GetUpstreamFromContext(ctx)
+		query.TraceId, _ = handlers.GetTraceIdFromContext(ctx)
+		query.Caller, _ = handlers.GetAuthIdFromContext(ctx)
+
+		if resp != nil && resp.Error != nil {
+			query.ErrorCode = resp.Error.Code
+		}
+
+		slowQueryLog.Log(query)
+
+		return resp
+	}
+}
+
+// blockRangeOf returns the number of blocks (or epochs) queried by the filter params, e.g.,
+// `eth_getLogs` or `cfx_getLogs`, or 0 if not a filter or not bounded by numbers.
+func blockRangeOf(msg *rpc.JsonRpcMessage) uint64 {
+	var args []json.RawMessage
+	if err := json.Unmarshal(msg.Params, &args); err != nil || len(args) == 0 {
+		return 0
+	}
+
+	var filter struct {
+		FromBlock, ToBlock string
+		FromEpoch, ToEpoch string
+	}
+
+	if err := json.Unmarshal(args[0], &filter); err != nil {
+		return 0
+	}
+
+	from, to := filter.FromBlock, filter.ToBlock
+	if len(from) == 0 && len(to) == 0 {
+		from, to = filter.FromEpoch, filter.ToEpoch
+	}
+
+	fromNum, err := hexutil.DecodeUint64(from)
+	if err != nil {
+		return 0
+	}
+
+	toNum, err := hexutil.DecodeUint64(to)
+	if err != nil || toNum < fromNum {
+		return 0
+	}
+
+	return toNum - fromNum + 1
+}
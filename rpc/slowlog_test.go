@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockRangeOf(t *testing.T) {
+	newMsg := func(params string) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Params: json.RawMessage(params)}
+	}
+
+	assert.Equal(t, uint64(16), blockRangeOf(newMsg(`[{"fromBlock": "0x1", "toBlock": "0x10"}]`)))
+	assert.Equal(t, uint64(1), blockRangeOf(newMsg(`[{"fromEpoch": "0x10", "toEpoch": "0x10"}]`)))
+	assert.Zero(t, blockRangeOf(newMsg(`[{"fromBlock": "0x1", "toBlock": "latest"}]`)))
+	assert.Zero(t, blockRangeOf(newMsg(`[{"fromBlock": "0x10", "toBlock": "0x1"}]`)))
+	assert.Zero(t, blockRangeOf(newMsg(`["0x1", "latest"]`)))
+	assert.Zero(t, blockRangeOf(newMsg(``)))
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+// stickyConfig configurations of read-your-writes consistency, by which requests of a client are
+// routed to the full node that accepted its transaction for a while.
+type stickyConfig struct {
+	// switch to turn on/off sticky routing
+	Enabled bool
+	// duration to stick to the full node after transaction submitted
+	Window time.Duration `default:"30s"`
+	// max number of sticky clients
+	Size int `default:"100000"`
+	// methods to route sticky, or all methods if empty
+	Methods []string
+}
+
+// stickyNode is the full node a client sticks to.
+type stickyNode struct {
+	url   string
+	group node.Group
+}
+
+// stickyRouter keeps track of the full nodes that accepted transactions of clients, which are
+// identified by access token along with IP address, since an access token might be shared by
+// many clients, eg., of a DApp.
+type stickyRouter struct {
+	clients *util.ExpirableLruCache // client key => stickyNode
+	methods map[string]bool
+}
+
+func mustNewStickyRouterFromViper(key string) *stickyRouter {
+	var conf stickyConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	logrus.WithField("config", conf).Info("Sticky routing after transaction submitted enabled")
+
+	return newStickyRouter(conf)
+}
+
+func newStickyRouter(conf stickyConfig) *stickyRouter {
+	router := &stickyRouter{
+		clients: util.NewExpirableLruCache(conf.Size, conf.Window),
+		methods: make(map[string]bool),
+	}
+
+	for _, method := range conf.Methods {
+		router.methods[method] = true
+	}
+
+	return router
+}
+
+func stickyClientKey(ctx context.Context) (key string, ok bool) {
+	ip, ok := handlers.GetIPAddressFromContext(ctx)
+	if !ok || len(ip) == 0 {
+		return "", false
+	}
+
+	key = "ip:" + ip
+	if token, ok := handlers.GetAccessTokenFromContext(ctx); ok && len(token) > 0 {
+		key = "token:" + token + "/" + key
+	}
+
+	// full nodes differ among chains served by the same gateway
+	if chain, ok := handlers.GetChainFromContext(ctx); ok {
+		key = chain + "/" + key
+	}
+
+	return key, true
+}
+
+// Stick sticks the client to the full node that accepted its transaction.
+func (r *stickyRouter) Stick(ctx context.Context, group node.Group, url string) {
+	if r == nil {
+		return
+	}
+
+	if key, ok := stickyClientKey(ctx); ok {
+		r.clients.Add(key, stickyNode{url: url, group: group})
+	}
+}
+
+// Route returns the full node that client sticks to if any, which requires the node group to be
+// the same, unless the transaction was sent to the active sequencer, which has the most recent
+// state among full nodes of any group. Besides, the full node must be still available, eg., not
+// drained, otherwise requests are routed as usual.
+func (r *stickyRouter) Route(
+	ctx context.Context, method string, group node.Group, available func(group node.Group, url string) bool,
+) (stickyNode, bool) {
+	if r == nil {
+		return stickyNode{}, false
+	}
+
+	if len(r.methods) > 0 && !r.methods[method] {
+		return stickyNode{}, false
+	}
+
+	key, ok := stickyClientKey(ctx)
+	if !ok {
+		return stickyNode{}, false
+	}
+
+	var sn stickyNode
+	if val, ok := r.clients.Get(key); ok {
+		sn = val.(stickyNode)
+	}
+
+	sticky := len(sn.url) > 0 && (sn.group == group || sn.group == node.GroupEthSequencer) &&
+		available(sn.group, sn.url)
+	metrics.Registry.RPC.Percentage(method, "sticky").Mark(sticky)
+
+	return sn, sticky
+}
+
+func isEthWriteRpcMethod(method string) bool {
+	return method == "eth_sendRawTransaction" || method == "eth_submitTransaction"
+}
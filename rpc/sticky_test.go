@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickyRouter(t *testing.T) {
+	router := newStickyRouter(stickyConfig{
+		Window: time.Minute, Size: 10, Methods: []string{"eth_getTransactionCount"},
+	})
+
+	ctx := context.WithValue(context.Background(), handlers.CtxKeyRealIP, "127.0.0.1")
+	otherCtx := context.WithValue(context.Background(), handlers.CtxKeyRealIP, "127.0.0.2")
+
+	unavailable := make(map[string]bool)
+	available := func(group node.Group, url string) bool { return !unavailable[url] }
+
+	_, ok := router.Route(ctx, "eth_getTransactionCount", node.GroupEthHttp, available)
+	assert.False(t, ok)
+
+	router.Stick(ctx, node.GroupEthHttp, "http://node1")
+
+	sn, ok := router.Route(ctx, "eth_getTransactionCount", node.GroupEthHttp, available)
+	assert.True(t, ok)
+	assert.Equal(t, stickyNode{url: "http://node1", group: node.GroupEthHttp}, sn)
+
+	// method not sticky
+	_, ok = router.Route(ctx, "eth_blockNumber", node.GroupEthHttp, available)
+	assert.False(t, ok)
+
+	// different group
+	_, ok = router.Route(ctx, "eth_getTransactionCount", node.GroupEthLogs, available)
+	assert.False(t, ok)
+
+	// different client
+	_, ok = router.Route(otherCtx, "eth_getTransactionCount", node.GroupEthHttp, available)
+	assert.False(t, ok)
+
+	// transaction sent to the active sequencer sticks reads of any group
+	router.Stick(ctx, node.GroupEthSequencer, "http://sequencer")
+
+	for _, grp := range []node.Group{node.GroupEthHttp, node.GroupEthLogs} {
+		sn, ok = router.Route(ctx, "eth_getTransactionCount", grp, available)
+		assert.True(t, ok)
+		assert.Equal(t, stickyNode{url: "http://sequencer", group: node.GroupEthSequencer}, sn)
+	}
+
+	// routed as usual once the full node unavailable, eg., drained
+	unavailable["http://sequencer"] = true
+	_, ok = router.Route(ctx, "eth_getTransactionCount", node.GroupEthHttp, available)
+	assert.False(t, ok)
+
+	// disabled
+	var disabled *stickyRouter
+	disabled.Stick(ctx, node.GroupEthHttp, "http://node1")
+	_, ok = disabled.Route(ctx, "eth_getTransactionCount", node.GroupEthHttp, available)
+	assert.False(t, ok)
+}
+
+func TestStickyClientKey(t *testing.T) {
+	ctx := context.WithValue(context.Background(), handlers.CtxKeyRealIP, "127.0.0.1")
+
+	key, ok := stickyClientKey(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "ip:127.0.0.1", key)
+
+	// clients sharing the same access token are told apart by IP address
+	tokenCtx := context.WithValue(ctx, handlers.CtxKeyAccessToken, "token")
+	key, ok = stickyClientKey(tokenCtx)
+	assert.True(t, ok)
+	assert.Equal(t, "token:token/ip:127.0.0.1", key)
+
+	otherCtx := context.WithValue(context.Background(), handlers.CtxKeyRealIP, "127.0.0.2")
+	otherKey, _ := stickyClientKey(context.WithValue(otherCtx, handlers.CtxKeyAccessToken, "token"))
+	assert.NotEqual(t, key, otherKey)
+
+	key, ok = stickyClientKey(context.WithValue(tokenCtx, handlers.CtxKeyChain, "kroma"))
+	assert.True(t, ok)
+	assert.Equal(t, "kroma/token:token/ip:127.0.0.1", key)
+
+	// unknown client
+	_, ok = stickyClientKey(context.WithValue(context.Background(), handlers.CtxKeyAccessToken, "token"))
+	assert.False(t, ok)
+}
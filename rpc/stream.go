@@ -0,0 +1,410 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/jsonscan"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// methods of large array results that support response streaming
+	streamableMethods = map[string]bool{
+		rpcMethodEthGetLogs:          true,
+		rpcMethodEthGetBlockReceipts: true,
+		"trace_block":                true,
+	}
+
+	errInvalidStreamResponse = errors.New("invalid response from full node")
+)
+
+// streamConfig configurations of response streaming, by which large array results of evm space
+// are written to client incrementally as data arrives from full node or store, rather than
+// buffered in memory per request.
+type streamConfig struct {
+	// switch to turn on/off response streaming
+	Enabled bool
+	// methods to stream responses, which must be streamable
+	Methods []string
+	// size of data buffered before flushed to client
+	FlushSize int `default:"65536"`
+}
+
+// responseStreamer streams responses of single HTTP JSON-RPC calls to the configured methods,
+// whereas batch and websocket requests are served as usual.
+type responseStreamer struct {
+	conf    streamConfig
+	methods map[string]bool
+	client  *http.Client // to request full nodes
+}
+
+func mustNewResponseStreamerFromViper(key string) *responseStreamer {
+	var conf streamConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if len(conf.Methods) == 0 || conf.FlushSize <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid response streaming config")
+	}
+
+	for _, method := range conf.Methods {
+		if !streamableMethods[method] {
+			logrus.WithField("method", method).Fatal("Response streaming not supported for method")
+		}
+	}
+
+	logrus.WithField("config", conf).Info("Response streaming enabled")
+
+	return newResponseStreamer(conf)
+}
+
+func newResponseStreamer(conf streamConfig) *responseStreamer {
+	streamer := &responseStreamer{
+		conf:    conf,
+		methods: make(map[string]bool),
+		client:  &http.Client{},
+	}
+
+	for _, method := range conf.Methods {
+		streamer.methods[method] = true
+	}
+
+	return streamer
+}
+
+// Http injects the response stream into context for single JSON-RPC call to the configured
+// methods, which passes through if streamer is nil. Once response streamed, the response buffered
+// by RPC server is discarded. Note, it should be the innermost HTTP middleware, so that request
+// body has been limited and verified.
+func (s *responseStreamer) Http(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		// batch, notification or malformed request
+		call, ok := jsonscan.ScanRequest(data)
+		if !ok || len(call.ID) == 0 || !s.methods[string(call.Method)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		stream := &responseStream{streamer: s, w: w, method: string(call.Method), id: call.ID}
+		ctx := context.WithValue(r.Context(), ctxKeyResponseStream, stream)
+
+		next.ServeHTTP(&streamWriter{ResponseWriter: w, stream: stream}, r.WithContext(ctx))
+
+		// abort connection so that client is aware of the truncated response
+		if stream.aborted {
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// streamWriter discards the response buffered by RPC server once the response streamed.
+type streamWriter struct {
+	http.ResponseWriter
+	stream *responseStream
+}
+
+func (w *streamWriter) WriteHeader(statusCode int) {
+	if !w.stream.committed {
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	if w.stream.committed {
+		return len(data), nil
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// responseStream writes JSON-RPC response of array result to client incrementally. Data is
+// buffered until the flush size reached, before which any error could be returned as usual;
+// otherwise, the response is truncated and the connection aborted.
+type responseStream struct {
+	streamer *responseStreamer
+	w        http.ResponseWriter
+	method   string
+	id       json.RawMessage
+
+	buf       bytes.Buffer
+	size      int  // bytes of result written
+	count     int  // number of array elements written
+	committed bool // whether any data written to client
+	aborted   bool // whether failed after committed
+}
+
+// responseStreamFromContext returns the response stream of request, if any.
+func responseStreamFromContext(ctx context.Context) (*responseStream, bool) {
+	stream, ok := ctx.Value(ctxKeyResponseStream).(*responseStream)
+	return stream, ok
+}
+
+// isResponseStreamed checks if response of request has been streamed to client, in which case the
+// result returned by RPC API is discarded.
+func isResponseStreamed(ctx context.Context) bool {
+	stream, ok := responseStreamFromContext(ctx)
+	return ok && stream.streamed()
+}
+
+// streamed checks if response has been written to client.
+func (s *responseStream) streamed() bool {
+	return s.committed
+}
+
+// canProxy checks if the full node of url could be proxied, which requires HTTP protocol.
+func (s *responseStream) canProxy(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// writeArray streams the elements of slice to client, eg., logs queried from store.
+func (s *responseStream) writeArray(ctx context.Context, slice interface{}) error {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return errors.Errorf("slice expected, got %v", v.Kind())
+	}
+
+	s.open()
+	s.buf.WriteByte('[')
+
+	for i := 0; i < v.Len(); i++ {
+		elem, err := json.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return s.fail(err)
+		}
+
+		if err := s.writeElement(ctx, elem); err != nil {
+			return s.fail(err)
+		}
+	}
+
+	s.buf.WriteByte(']')
+
+	return s.close()
+}
+
+// proxy requests full node of url by HTTP, and streams the array result to client, which is
+// checked by the number of elements written if check specified.
+func (s *responseStream) proxy(
+	ctx context.Context, url, method string, check func(count int) error, params ...interface{},
+) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.streamer.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("full node unavailable with status %v", resp.Status)
+	}
+
+	return s.copyResponse(ctx, json.NewDecoder(resp.Body), check)
+}
+
+// copyResponse decodes JSON-RPC response of full node, and streams the array result to client.
+func (s *responseStream) copyResponse(ctx context.Context, decoder *json.Decoder, check func(count int) error) error {
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('{') {
+		return errInvalidStreamResponse
+	}
+
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return errInvalidStreamResponse
+		}
+
+		switch tok {
+		case "error":
+			var rpcErr rpc.JsonError
+			if err := decoder.Decode(&rpcErr); err != nil {
+				return errInvalidStreamResponse
+			}
+
+			return &rpcErr
+		case "result":
+			return s.copyResult(ctx, decoder, check)
+		default: // eg., `jsonrpc` and `id`
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return errInvalidStreamResponse
+			}
+		}
+	}
+
+	return errInvalidStreamResponse
+}
+
+// copyResult streams the array (or null) result of full node to client element by element.
+func (s *responseStream) copyResult(ctx context.Context, decoder *json.Decoder, check func(count int) error) error {
+	tok, err := decoder.Token()
+	if err != nil {
+		return errInvalidStreamResponse
+	}
+
+	s.open()
+
+	switch tok {
+	case nil: // eg., block not found
+		s.buf.WriteString("null")
+		return s.close()
+	case json.Delim('['):
+	default:
+		return s.fail(errInvalidStreamResponse)
+	}
+
+	s.buf.WriteByte('[')
+
+	for decoder.More() {
+		var elem json.RawMessage
+		if err := decoder.Decode(&elem); err != nil {
+			return s.fail(errInvalidStreamResponse)
+		}
+
+		if err := s.writeElement(ctx, elem); err != nil {
+			return s.fail(err)
+		}
+
+		if check != nil {
+			if err := check(s.count); err != nil {
+				return s.fail(err)
+			}
+		}
+	}
+
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim(']') {
+		return s.fail(errInvalidStreamResponse)
+	}
+
+	s.buf.WriteByte(']')
+
+	return s.close()
+}
+
+// open writes the JSON-RPC response up to result.
+func (s *responseStream) open() {
+	s.buf.Reset()
+	s.size, s.count = 0, 0
+
+	s.buf.WriteString(`{"jsonrpc":"2.0","id":`)
+	s.buf.Write(s.id)
+	s.buf.WriteString(`,"result":`)
+}
+
+// writeElement writes element of array result, which is flushed to client once the flush size
+// reached.
+func (s *responseStream) writeElement(ctx context.Context, elem []byte) error {
+	if s.count > 0 {
+		s.buf.WriteByte(',')
+	}
+
+	s.buf.Write(elem)
+	s.size += len(elem) + 1
+	s.count++
+
+	if requestLimiter != nil {
+		if err := requestLimiter.CheckResponseSize(ctx, s.size); err != nil {
+			return err
+		}
+	}
+
+	if s.buf.Len() < s.streamer.conf.FlushSize {
+		return nil
+	}
+
+	return s.flush()
+}
+
+// flush writes the buffered data to client.
+func (s *responseStream) flush() error {
+	if !s.committed {
+		s.w.Header().Set("Content-Type", "application/json")
+		s.w.WriteHeader(http.StatusOK)
+		s.committed = true
+	}
+
+	_, err := s.w.Write(s.buf.Bytes())
+	s.buf.Reset()
+
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return err
+}
+
+// close writes the end of JSON-RPC response and flushes to client.
+func (s *responseStream) close() error {
+	s.buf.WriteString("}\n")
+
+	if err := s.flush(); err != nil {
+		return s.fail(err)
+	}
+
+	metrics.Registry.RPC.StreamedBytes(s.method).Mark(int64(s.size))
+
+	return nil
+}
+
+// fail discards the buffered data, and marks the response aborted if any data written to client
+// already, in which case the error could never be returned.
+func (s *responseStream) fail(err error) error {
+	s.buf.Reset()
+
+	if s.committed && !s.aborted {
+		s.aborted = true
+		metrics.Registry.RPC.StreamAborted(s.method).Mark(1)
+
+		logrus.WithFields(logrus.Fields{
+			"method": s.method,
+			"size":   s.size,
+		}).WithError(err).Info("Response streaming aborted")
+	}
+
+	return err
+}
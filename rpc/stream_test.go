@@ -0,0 +1,189 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStreamHandler serves JSON-RPC request by streaming the result via handle, or responds the
+// result (or error) returned by handle as usual if not streamed.
+func newTestStreamHandler(
+	conf streamConfig, handle func(ctx context.Context, stream *responseStream) (interface{}, error),
+) http.Handler {
+	return newResponseStreamer(conf).Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		stream, _ := responseStreamFromContext(r.Context())
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if result, err := handle(r.Context(), stream); err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func serveTestStream(handler http.Handler, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	return recorder
+}
+
+func newTestUpstream(t *testing.T, resp string) *httptest.Server {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "trace_block", req.Method)
+
+		w.Write([]byte(resp))
+	}))
+	t.Cleanup(upstream.Close)
+
+	return upstream
+}
+
+func TestResponseStreamerProxy(t *testing.T) {
+	upstream := newTestUpstream(t, `{"jsonrpc":"2.0","id":1,"result":[{"a":1}, {"b":[2,3]}]}`)
+
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{"trace_block"}, FlushSize: 8},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			require.NotNil(t, stream)
+			assert.True(t, stream.canProxy(upstream.URL))
+			return nil, stream.proxy(ctx, upstream.URL, "trace_block", nil, "0x1")
+		},
+	)
+
+	resp := serveTestStream(handler, `{"jsonrpc":"2.0","id":"abc","method":"trace_block","params":["0x1"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.True(t, resp.Flushed)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"abc","result":[{"a":1},{"b":[2,3]}]}`, resp.Body.String())
+}
+
+func TestResponseStreamerProxyNull(t *testing.T) {
+	upstream := newTestUpstream(t, `{"jsonrpc":"2.0","id":1,"result":null}`)
+
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{"trace_block"}, FlushSize: 8},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			return nil, stream.proxy(ctx, upstream.URL, "trace_block", nil, "0x1")
+		},
+	)
+
+	resp := serveTestStream(handler, `{"jsonrpc":"2.0","id":1,"method":"trace_block","params":["0x1"]}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":null}`, resp.Body.String())
+}
+
+func TestResponseStreamerUpstreamError(t *testing.T) {
+	upstream := newTestUpstream(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`)
+
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{"trace_block"}, FlushSize: 8},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			err := stream.proxy(ctx, upstream.URL, "trace_block", nil, "0x1")
+			assert.True(t, isMethodNotFound(err))
+			assert.False(t, stream.streamed())
+			return nil, err
+		},
+	)
+
+	// responded as usual since not streamed yet
+	resp := serveTestStream(handler, `{"jsonrpc":"2.0","id":1,"method":"trace_block","params":["0x1"]}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"method not found"}}`, resp.Body.String())
+}
+
+func TestResponseStreamerCheck(t *testing.T) {
+	upstream := newTestUpstream(t, `{"jsonrpc":"2.0","id":1,"result":[1,2,3,4,5,6,7,8]}`)
+	errTooMany := errors.New("too many")
+
+	check := func(count int) error {
+		if count > 5 {
+			return errTooMany
+		}
+
+		return nil
+	}
+
+	// error returned as usual if not flushed yet
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{"trace_block"}, FlushSize: 1024},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			return nil, stream.proxy(ctx, upstream.URL, "trace_block", check, "0x1")
+		},
+	)
+
+	resp := serveTestStream(handler, `{"jsonrpc":"2.0","id":1,"method":"trace_block","params":["0x1"]}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"too many"}}`, resp.Body.String())
+
+	// connection aborted once flushed
+	handler = newTestStreamHandler(
+		streamConfig{Methods: []string{"trace_block"}, FlushSize: 8},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			return nil, stream.proxy(ctx, upstream.URL, "trace_block", check, "0x1")
+		},
+	)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serveTestStream(handler, `{"jsonrpc":"2.0","id":1,"method":"trace_block","params":["0x1"]}`)
+	})
+}
+
+func TestResponseStreamerWriteArray(t *testing.T) {
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{rpcMethodEthGetLogs}, FlushSize: 16},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			logs := []map[string]string{{"address": "0x01"}, {"address": "0x02"}}
+			return nil, stream.writeArray(ctx, logs)
+		},
+	)
+
+	resp := serveTestStream(handler, `{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{}]}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":7,"result":[{"address":"0x01"},{"address":"0x02"}]}`, resp.Body.String())
+
+	// empty array
+	handler = newTestStreamHandler(
+		streamConfig{Methods: []string{rpcMethodEthGetLogs}, FlushSize: 16},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			return nil, stream.writeArray(ctx, []int{})
+		},
+	)
+
+	resp = serveTestStream(handler, `{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{}]}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":7,"result":[]}`, resp.Body.String())
+}
+
+func TestResponseStreamerPassThrough(t *testing.T) {
+	handler := newTestStreamHandler(
+		streamConfig{Methods: []string{rpcMethodEthGetLogs}, FlushSize: 16},
+		func(ctx context.Context, stream *responseStream) (interface{}, error) {
+			assert.Nil(t, stream)
+			return "0x1", nil
+		},
+	)
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`, // other method
+		`[{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{}]}]`, // batch
+		`{"jsonrpc":"2.0","method":"eth_getLogs","params":[{}]}`,          // notification
+	} {
+		serveTestStream(handler, body)
+	}
+}
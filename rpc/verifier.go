@@ -0,0 +1,288 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	rpcutil "github.com/Conflux-Chain/confura/util/rpc"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// block or epoch tags that resolve to different blocks among full nodes
+	relativeBlockTags = []string{
+		`"latest"`, `"pending"`, `"safe"`, `"finalized"`, `"earliest"`,
+		`"latest_state"`, `"latest_mined"`, `"latest_confirmed"`, `"latest_checkpoint"`, `"latest_finalized"`,
+	}
+)
+
+// verifierConfig configurations of response verification, by which a sampled fraction of read
+// requests is also sent to another full node of the same group to compare the responses.
+type verifierConfig struct {
+	// switch to turn on/off response verification
+	Enabled bool
+	// fraction of read requests to verify, in range (0, 1]
+	Ratio float64 `default:"0.001"`
+	// methods to verify, or all read methods if empty
+	Methods []string
+	// timeout of verification request
+	Timeout time.Duration `default:"5s"`
+	// max number of in-flight verification requests, beyond which requests are not verified
+	MaxInflight int64 `default:"100"`
+	// max attempts to pick another full node of the same group
+	Attempts int `default:"3"`
+}
+
+// rawCallFunc calls RPC method of full node with the raw JSON result.
+type rawCallFunc func(ctx context.Context, result interface{}, method string, args ...interface{}) error
+
+// responseVerifier verifies responses across full nodes to catch a corrupted or out-of-sync
+// full node early, with divergences logged and metered.
+type responseVerifier struct {
+	conf     verifierConfig
+	evm      bool // whether to verify requests of evm space or core space
+	methods  map[string]bool
+	inflight int64
+}
+
+func mustNewResponseVerifierFromViper(key string, evm bool) *responseVerifier {
+	var conf verifierConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Ratio <= 0 || conf.Ratio > 1 || conf.Attempts <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid response verification config")
+	}
+
+	logrus.WithField("config", conf).Info("Response verification across full nodes enabled")
+
+	return newResponseVerifier(conf, evm)
+}
+
+func newResponseVerifier(conf verifierConfig, evm bool) *responseVerifier {
+	verifier := &responseVerifier{conf: conf, evm: evm, methods: make(map[string]bool)}
+
+	for _, method := range conf.Methods {
+		verifier.methods[method] = true
+	}
+
+	return verifier
+}
+
+// isDeterministicRequest checks if the request is expected to have the same response among
+// full nodes, i.e., not relative to the latest block of each full node.
+func isDeterministicRequest(msg *rpc.JsonRpcMessage) bool {
+	if len(msg.Params) == 0 {
+		return false
+	}
+
+	params := string(msg.Params)
+	for _, tag := range relativeBlockTags {
+		if strings.Contains(params, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sampled checks if the request should be verified.
+func (v *responseVerifier) sampled(ctx context.Context, msg *rpc.JsonRpcMessage) bool {
+	if _, evm := ctx.Value(ctxKeyClientProvider).(*node.EthClientProvider); evm != v.evm {
+		return false
+	}
+
+	if len(v.methods) > 0 && !v.methods[msg.Method] {
+		return false
+	}
+
+	return isShadowableRpcMethod(msg.Method) && isDeterministicRequest(msg) && rand.Float64() < v.conf.Ratio
+}
+
+// Call verifies the sampled responses asynchronously, which passes through if verifier is nil.
+// Note, it must be executed after the full node client injected into context.
+func (v *responseVerifier) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if v == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		resp := next(ctx, msg)
+
+		if resp == nil || resp.Error != nil || isResponseStreamed(ctx) || !v.sampled(ctx, msg) {
+			return resp
+		}
+
+		primary, ok := clientUrlFromContext(ctx)
+		if !ok {
+			return resp
+		}
+
+		if atomic.AddInt64(&v.inflight, 1) > v.conf.MaxInflight {
+			atomic.AddInt64(&v.inflight, -1)
+			metrics.Registry.RPC.Verification(rpcutil.Url2NodeName(primary), diffOutcomeSkipped).Mark(1)
+			return resp
+		}
+
+		provider, group := ctx.Value(ctxKeyClientProvider), GetClientGroupFromContext(ctx)
+
+		go func() {
+			defer atomic.AddInt64(&v.inflight, -1)
+
+			peer, call, ok := v.peer(provider, group, primary)
+			if !ok { // no other full node available
+				metrics.Registry.RPC.Verification(rpcutil.Url2NodeName(primary), diffOutcomeSkipped).Mark(1)
+				return
+			}
+
+			v.verify(primary, peer, call, msg, resp.Result)
+		}()
+
+		return resp
+	}
+}
+
+// peer picks another full node of the same group other than the primary one.
+func (v *responseVerifier) peer(provider interface{}, group node.Group, primary string) (string, rawCallFunc, bool) {
+	client, ok := peerClient(provider, group, primary, v.conf.Attempts)
+	if !ok {
+		return "", nil, false
+	}
+
+	url, ok := clientUrl(client)
+	if !ok {
+		return "", nil, false
+	}
+
+	call, ok := rawCaller(client)
+
+	return url, call, ok
+}
+
+// peerClient picks client of another full node in the same group other than the primary one
+// within the max attempts.
+func peerClient(provider interface{}, group node.Group, primary string, attempts int) (interface{}, bool) {
+	for i := 0; i < attempts; i++ {
+		key := strconv.FormatUint(rand.Uint64(), 10)
+
+		switch p := provider.(type) {
+		case *node.EthClientProvider:
+			client, err := p.GetClient(key, group)
+			if err != nil {
+				return nil, false
+			}
+
+			if client.URL != primary {
+				return client, true
+			}
+		case *node.CfxClientProvider:
+			client, err := p.GetClient(key, group)
+			if err != nil {
+				return nil, false
+			}
+
+			if client.GetNodeURL() != primary {
+				return client, true
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// verify sends request to the peer full node, and compares the response with the primary one.
+func (v *responseVerifier) verify(
+	primary, peer string, call rawCallFunc, msg *rpc.JsonRpcMessage, expected json.RawMessage,
+) string {
+	primaryName, peerName := rpcutil.Url2NodeName(primary), rpcutil.Url2NodeName(peer)
+
+	outcome := diffOutcomeMatch
+	defer func() {
+		metrics.Registry.RPC.Verification(primaryName, outcome).Mark(1)
+		metrics.Registry.RPC.Verification(peerName, outcome).Mark(1)
+	}()
+
+	logger := logrus.WithFields(logrus.Fields{
+		"primary": primaryName,
+		"peer":    peerName,
+		"method":  msg.Method,
+		"params":  truncateJSON(msg.Params),
+	})
+
+	var args []json.RawMessage
+	if err := json.Unmarshal(msg.Params, &args); err != nil {
+		outcome = diffOutcomeSkipped // named params not supported
+		return outcome
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.conf.Timeout)
+	defer cancel()
+
+	var actual json.RawMessage
+	if err := call(ctx, &actual, msg.Method, rawArgs(args)...); err != nil {
+		outcome = diffOutcomeError
+		logger.WithError(err).Info("Peer full node failed to respond verification request")
+		return outcome
+	}
+
+	if !jsonEqual(expected, actual) {
+		outcome = diffOutcomeMismatch
+		logger.WithFields(logrus.Fields{
+			"expected": truncateJSON(expected),
+			"actual":   truncateJSON(actual),
+		}).Warn("Responses diverged among full nodes")
+	}
+
+	return outcome
+}
+
+// clientUrlFromContext returns url of the full node that served the request.
+func clientUrlFromContext(ctx context.Context) (string, bool) {
+	return clientUrl(ctx.Value(ctxKeyClient))
+}
+
+// clientUrl returns url of the full node client.
+func clientUrl(client interface{}) (string, bool) {
+	switch client := client.(type) {
+	case *node.Web3goClient:
+		return client.URL, true
+	case sdk.ClientOperator:
+		return client.GetNodeURL(), true
+	default:
+		return "", false
+	}
+}
+
+// rawCaller returns the function to call RPC method of full node client with the raw JSON result.
+func rawCaller(client interface{}) (rawCallFunc, bool) {
+	switch client := client.(type) {
+	case *node.Web3goClient:
+		return client.Provider().CallContext, true
+	case sdk.ClientOperator:
+		return cfxRawCall(client), true
+	default:
+		return nil, false
+	}
+}
+
+// cfxRawCall adapts core space client, which is bounded by the client request timeout.
+func cfxRawCall(client sdk.ClientOperator) rawCallFunc {
+	return func(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+		return client.CallRPC(result, method, args...)
+	}
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/node"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDeterministicRequest(t *testing.T) {
+	assert.True(t, isDeterministicRequest(&rpc.JsonRpcMessage{Params: json.RawMessage(`["0xabc"]`)}))
+	assert.True(t, isDeterministicRequest(&rpc.JsonRpcMessage{Params: json.RawMessage(`["0x1", "0x10"]`)}))
+	assert.False(t, isDeterministicRequest(&rpc.JsonRpcMessage{}))
+	assert.False(t, isDeterministicRequest(&rpc.JsonRpcMessage{Params: json.RawMessage(`["0x1", "latest"]`)}))
+	assert.False(t, isDeterministicRequest(&rpc.JsonRpcMessage{Params: json.RawMessage(`[{"fromBlock": "pending"}]`)}))
+	assert.False(t, isDeterministicRequest(&rpc.JsonRpcMessage{Params: json.RawMessage(`["0x1", "latest_state"]`)}))
+}
+
+func TestResponseVerifierPeer(t *testing.T) {
+	verifier := newResponseVerifier(verifierConfig{Attempts: 100}, true)
+
+	urls := []string{"http://127.0.0.1:18545", "http://127.0.0.1:28545"}
+	provider := node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: urls,
+	}))
+
+	peer, _, ok := verifier.peer(provider, node.GroupEthHttp, urls[0])
+	assert.True(t, ok)
+	assert.Equal(t, urls[1], peer)
+
+	// no other full node available
+	provider = node.NewEthClientProvider(nil, node.NewLocalRouter(map[node.Group][]string{
+		node.GroupEthHttp: urls[:1],
+	}))
+
+	_, _, ok = verifier.peer(provider, node.GroupEthHttp, urls[0])
+	assert.False(t, ok)
+}
+
+func TestResponseVerifierVerify(t *testing.T) {
+	verifier := newResponseVerifier(verifierConfig{}, true)
+	msg := &rpc.JsonRpcMessage{Method: "eth_getBlockByHash", Params: json.RawMessage(`["0xabc", false]`)}
+
+	newCall := func(result string, err error) rawCallFunc {
+		return func(ctx context.Context, res interface{}, method string, args ...interface{}) error {
+			if err != nil {
+				return err
+			}
+
+			assert.Len(t, args, 2)
+			return json.Unmarshal([]byte(result), res)
+		}
+	}
+
+	outcome := verifier.verify("http://node1", "http://node2", newCall(`{"number": "0xA"}`, nil), msg, json.RawMessage(`{"number":"0xa"}`))
+	assert.Equal(t, diffOutcomeMatch, outcome)
+
+	outcome = verifier.verify("http://node1", "http://node2", newCall(`null`, nil), msg, json.RawMessage(`{"number":"0xa"}`))
+	assert.Equal(t, diffOutcomeMismatch, outcome)
+
+	outcome = verifier.verify("http://node1", "http://node2", newCall("", errors.New("oops")), msg, json.RawMessage(`{}`))
+	assert.Equal(t, diffOutcomeError, outcome)
+}
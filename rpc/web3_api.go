@@ -2,15 +2,62 @@ package rpc
 
 import (
 	"context"
+	"fmt"
+	"runtime"
 
-	"github.com/Conflux-Chain/confura/rpc/cache"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
 )
 
-// web3API provides evm space web3 RPC proxy API.
+var (
+	// localRpcMethods RPC methods answered by the gateway itself, which never burn capacity of
+	// upstream full nodes.
+	localRpcMethods = map[string]bool{
+		"web3_sha3":     true,
+		"net_listening": true,
+	}
+
+	// gateway version and git commit injected at build time
+	gatewayVersion, gatewayGitCommit string
+)
+
+// SetGatewayVersion sets the gateway version reported by `web3_clientVersion`.
+func SetGatewayVersion(version, gitCommit string) {
+	gatewayVersion, gatewayGitCommit = version, gitCommit
+}
+
+// web3API provides evm space web3 RPC API, which is answered by the gateway itself.
 type web3API struct{}
 
-// ClientVersion returns the current client version.
+// ClientVersion returns the gateway version along with the client version of upstream full nodes.
 func (api *web3API) ClientVersion(ctx context.Context) (string, error) {
 	w3c := GetEthClientFromContext(ctx)
-	return cache.EthDefault.GetClientVersion(w3c.Client)
+
+	upstream, err := GetEthCacheFromContext(ctx).GetClientVersion(w3c.Client)
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to get client version of upstream full node")
+		return gatewayClientVersion(), nil
+	}
+
+	return fmt.Sprintf("%v (upstream: %v)", gatewayClientVersion(), upstream), nil
+}
+
+// Sha3 returns the Keccak-256 hash of the given data.
+func (api *web3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
+	return crypto.Keccak256(input)
+}
+
+// gatewayClientVersion returns the gateway version, eg., `Confura/v1.0.0-3f2a1b9/linux-amd64`.
+func gatewayClientVersion() string {
+	version := gatewayVersion
+	if len(version) == 0 {
+		version = "dev"
+	}
+
+	if len(gatewayGitCommit) >= 7 {
+		version += "-" + gatewayGitCommit[:7]
+	}
+
+	return fmt.Sprintf("Confura/%v/%v-%v", version, runtime.GOOS, runtime.GOARCH)
 }
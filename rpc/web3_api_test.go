@@ -0,0 +1,20 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeb3APISha3(t *testing.T) {
+	api := &web3API{}
+
+	hash := api.Sha3(hexutil.MustDecode("0x68656c6c6f20776f726c64"))
+	assert.Equal(t, "0x47173285a8d7341e5e972fc677286384f802f8ef42a5ec5f03bbfa254cb01fad", hash.String())
+}
+
+func TestGatewayClientVersion(t *testing.T) {
+	assert.True(t, strings.HasPrefix(gatewayClientVersion(), "Confura/dev/"))
+}
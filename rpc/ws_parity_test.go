@@ -0,0 +1,194 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/gorilla/websocket"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+// parityUpstream fake full node, which counts the requests of each method.
+type parityUpstream struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newParityUpstream(balance string) *parityUpstream {
+	upstream := &parityUpstream{calls: make(map[string]int)}
+
+	results := map[string]string{
+		"eth_chainId":    `"0x1"`,
+		"eth_getBalance": `"` + balance + `"`,
+		"eth_getCode":    `"0x"`,
+	}
+
+	respond := func(msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		upstream.mu.Lock()
+		upstream.calls[msg.Method]++
+		upstream.mu.Unlock()
+
+		resp := &rpc.JsonRpcMessage{Version: "2.0", ID: msg.ID}
+		if result, ok := results[msg.Method]; ok {
+			resp.Result = json.RawMessage(result)
+		} else {
+			resp.Error = &rpc.JsonError{Code: -32601, Message: "method not found"}
+		}
+
+		return resp
+	}
+
+	upstream.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var batch []*rpc.JsonRpcMessage
+		if err := json.Unmarshal(body, &batch); err == nil {
+			resps := make([]*rpc.JsonRpcMessage, 0, len(batch))
+			for _, msg := range batch {
+				resps = append(resps, respond(msg))
+			}
+
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var msg rpc.JsonRpcMessage
+		json.Unmarshal(body, &msg)
+		json.NewEncoder(w).Encode(respond(&msg))
+	}))
+
+	return upstream
+}
+
+func (u *parityUpstream) numCalls(method string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.calls[method]
+}
+
+// newParityClient returns function to send raw JSON-RPC message over HTTP or websocket.
+func newParityClient(t *testing.T, protocol, url string) func(req string) []byte {
+	if protocol == "http" {
+		return func(req string) []byte {
+			resp, err := http.Post(url, "application/json", strings.NewReader(req))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			defer resp.Body.Close()
+
+			data, _ := ioutil.ReadAll(resp.Body)
+			return data
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return func(req string) []byte {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(req)))
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+
+		return data
+	}
+}
+
+// TestWebsocketParity verifies that regular RPC methods are served over websocket with the same
+// routing, caching, rate limiting and batch support as HTTP.
+func TestWebsocketParity(t *testing.T) {
+	for _, protocol := range []string{"http", "ws"} {
+		t.Run(protocol, func(t *testing.T) {
+			normal, vip := newParityUpstream("0x1"), newParityUpstream("0x2")
+			defer normal.Close()
+			defer vip.Close()
+
+			kloader := rate.NewKeyLoader(func(filter *rate.KeysetFilter) ([]*rate.KeyInfo, error) {
+				return []*rate.KeyInfo{{Key: "vipKey"}}, nil
+			})
+			registry := rate.NewRegistry(kloader, acl.NewEthValidator)
+
+			strategy := rate.NewStrategy(1, rate.DefaultStrategy)
+			strategy.LimitOptions["eth_getCode_qps"] = rate.FixedWindowOption{Interval: time.Minute, Quota: 1}
+
+			go registry.AutoReload(time.Hour, func() (*rate.Config, error) {
+				return &rate.Config{Strategies: map[uint32]*rate.Strategy{1: strategy}}, nil
+			})
+			assert.Eventually(t, func() bool { return registry.Reload() == nil }, time.Second, time.Millisecond)
+
+			chain := mustNewEvmChain(evmChainConfig{
+				Name:        "parity" + protocol,
+				URLs:        []string{normal.URL},
+				RouteGroups: []evmChainRouteGroup{{Name: "vip", Nodes: []string{vip.URL}}},
+				RouteKeys:   []evmChainRouteKey{{Key: "vipKey", Group: "vip"}},
+			}, registry, nil, nil)
+
+			server := httptest.NewServer(evmChainDispatcher([]*evmChain{chain})(http.NotFoundHandler()))
+			defer server.Close()
+
+			anonymous := newParityClient(t, protocol, server.URL+"/parity"+protocol)
+			pinned := newParityClient(t, protocol, server.URL+"/parity"+protocol+"/vipKey")
+
+			call := func(client func(string) []byte, req string) (resp rpc.JsonRpcMessage) {
+				assert.NoError(t, json.Unmarshal(client(req), &resp))
+				return resp
+			}
+
+			getBalance := `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance",` +
+				`"params":["0x0000000000000000000000000000000000000001","latest"]}`
+			chainId := `{"jsonrpc":"2.0","id":2,"method":"eth_chainId","params":[]}`
+			getCode := `{"jsonrpc":"2.0","id":3,"method":"eth_getCode",` +
+				`"params":["0x0000000000000000000000000000000000000001","latest"]}`
+
+			// routed by API key
+			assert.Equal(t, json.RawMessage(`"0x1"`), call(anonymous, getBalance).Result)
+			assert.Equal(t, json.RawMessage(`"0x2"`), call(pinned, getBalance).Result)
+
+			// served from cache once requested
+			assert.Equal(t, json.RawMessage(`"0x1"`), call(anonymous, chainId).Result)
+			calls := normal.numCalls("eth_chainId")
+
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, json.RawMessage(`"0x1"`), call(anonymous, chainId).Result)
+			}
+			assert.Equal(t, calls, normal.numCalls("eth_chainId"))
+
+			// rate limited
+			assert.Nil(t, call(anonymous, getCode).Error)
+			assert.NotNil(t, call(anonymous, getCode).Error)
+			assert.Equal(t, 1, normal.numCalls("eth_getCode"))
+
+			// batch
+			var resps []*rpc.JsonRpcMessage
+			data := anonymous("[" + getBalance + "," + chainId + "]")
+			assert.NoError(t, json.Unmarshal(data, &resps))
+
+			if assert.Len(t, resps, 2) {
+				results := make(map[string]string)
+				for _, resp := range resps {
+					results[string(resp.ID)] = string(resp.Result)
+				}
+
+				assert.Equal(t, map[string]string{"1": `"0x1"`, "2": `"0x1"`}, results)
+			}
+
+			// batch response is not mixed up with the previous responses
+			assert.True(t, bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")))
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// max number of operations per Consul transaction
+const maxTxnOps = 64
+
+var (
+	// errTxnConflict transaction aborted due to check-and-set conflict
+	errTxnConflict = errors.New("consul transaction conflict")
+)
+
+// kvPair key value pair of Consul KV store.
+type kvPair struct {
+	Key         string
+	CreateIndex uint64
+	ModifyIndex uint64
+	Flags       uint64 // opaque value, eg., config ID
+	Value       []byte // base64 encoded in json
+}
+
+// txnOp operation of Consul KV transaction.
+type txnOp struct {
+	KV *txnKVOp `json:"KV"`
+}
+
+type txnKVOp struct {
+	Verb  string // eg., `set`, `cas`, `delete-cas`
+	Key   string
+	Value []byte `json:",omitempty"`
+	Flags uint64 `json:",omitempty"`
+	Index uint64 `json:",omitempty"`
+}
+
+// client is a minimal Consul KV HTTP API client, which supports blocking queries for watches.
+type client struct {
+	addr   string // eg., http://127.0.0.1:8500
+	token  string
+	client *http.Client
+}
+
+func newClient(conf *Config) *client {
+	return &client{
+		addr:  fmt.Sprintf("%v://%v", conf.Scheme, conf.Address),
+		token: conf.Token,
+		// leave enough time for blocking queries, which are added with random jitter up to wait/16
+		client: &http.Client{Timeout: conf.WaitTime + conf.WaitTime/16 + conf.RequestTimeout},
+	}
+}
+
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	reqUrl := c.addr + path
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.token) > 0 {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	return c.client.Do(req)
+}
+
+// get gets key value pair, or nil if not found.
+func (c *client) get(ctx context.Context, key string) (*kvPair, error) {
+	pairs, _, err := c.list(ctx, key, false, 0, 0)
+	if err != nil || len(pairs) == 0 {
+		return nil, err
+	}
+
+	return pairs[0], nil
+}
+
+// list lists key value pairs by key or key prefix if recursive. If waitIndex is greater than
+// 0, it blocks until changes after the index or wait time elapsed, and also returns the index
+// for the next blocking query.
+func (c *client) list(
+	ctx context.Context, key string, recurse bool, waitIndex uint64, wait time.Duration,
+) ([]*kvPair, uint64, error) {
+	query := url.Values{}
+	if recurse {
+		query.Set("recurse", "true")
+	}
+
+	if waitIndex > 0 {
+		query.Set("index", strconv.FormatUint(waitIndex, 10))
+		query.Set("wait", wait.String())
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/v1/kv/"+key, query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, index, nil
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, errors.Errorf("unexpected consul response status %v: %s", resp.StatusCode, data)
+	}
+
+	var pairs []*kvPair
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, 0, errors.WithMessage(err, "malformed consul kv response")
+	}
+
+	return pairs, index, nil
+}
+
+// txn executes operations atomically, and returns `errTxnConflict` if any check-and-set
+// operation failed.
+func (c *client) txn(ctx context.Context, ops []*txnOp) error {
+	resp, err := c.do(ctx, http.MethodPut, "/v1/txn", nil, ops)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return errTxnConflict
+	}
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	return errors.Errorf("unexpected consul response status %v: %s", resp.StatusCode, data)
+}
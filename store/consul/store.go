@@ -0,0 +1,882 @@
+// Package consul provides Consul KV backed config store for multi-replica deployments, which
+// propagates config changes to all replicas in near real time by Consul blocking queries.
+package consul
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/store/encryption"
+	"github.com/Conflux-Chain/confura/store/mysql"
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	_ mysql.ConfigManager = (*ConfigStore)(nil) // ensure ConfigStore implements ConfigManager interface
+	_ mysql.ConfigWatcher = (*ConfigStore)(nil) // ensure ConfigStore implements ConfigWatcher interface
+
+	errConcurrentModification = errors.WithMessage(mysql.ErrConfigConflict, "please retry")
+)
+
+// Config Consul config store configurations.
+type Config struct {
+	Enabled bool
+
+	// Consul agent address
+	Address string `default:"127.0.0.1:8500"`
+	// URI scheme, `http` or `https`
+	Scheme string `default:"http"`
+	// ACL token to access Consul KV store
+	Token string
+	// key prefix under which configs are stored
+	Prefix string
+	// environment (eg., `prod` or `staging`) to scope configs, whose keys are further prefixed
+	// with the environment if specified
+	Env string
+	// max duration for blocking queries to watch config changes
+	WaitTime time.Duration `default:"5m"`
+	// timeout for non-blocking requests
+	RequestTimeout time.Duration `default:"10s"`
+	// interval to retry watching on error
+	RetryInterval time.Duration `default:"5s"`
+	// encryption of sensitive config values at rest
+	Encryption encryption.Config
+}
+
+func mustNewConfigFromViper(key, defaultPrefix string) *Config {
+	var conf Config
+	viper.MustUnmarshalKey(key, &conf)
+
+	if len(conf.Prefix) == 0 {
+		conf.Prefix = defaultPrefix
+	}
+
+	if !strings.HasSuffix(conf.Prefix, "/") {
+		conf.Prefix += "/"
+	}
+
+	return &conf
+}
+
+// MustNewConfigFromViper creates core space Consul config store configurations from viper.
+func MustNewConfigFromViper() *Config {
+	return mustNewConfigFromViper("store.consul", "confura/cfx/")
+}
+
+// MustNewEthStoreConfigFromViper creates evm space Consul config store configurations from viper.
+func MustNewEthStoreConfigFromViper() *Config {
+	return mustNewConfigFromViper("ethstore.consul", "confura/eth/")
+}
+
+// ConfigStore is Consul KV backed config store, which serves reads from the in-memory snapshot
+// kept up to date by watching the config key prefix.
+//
+// Be noted the config ID is allocated from a sequence key once config created, and kept in the
+// flags of the config key until the config deleted. For configs stored before, the Consul create
+// index of the config key is used as the config ID instead.
+type ConfigStore struct {
+	conf   *Config
+	client *client
+	// cipher to encrypt sensitive config values at rest, nil if encryption disabled
+	cipher *encryption.Cipher
+
+	mu        sync.RWMutex
+	index     uint64             // Consul index of the snapshot
+	configs   map[string]*kvPair // config name => kv pair
+	listeners []func()           // listeners to notify config changes
+}
+
+// MustOpen opens Consul config store with the initial configs loaded or exits on error.
+func (conf *Config) MustOpen() *ConfigStore {
+	cs := &ConfigStore{
+		conf:    conf,
+		client:  newClient(conf),
+		cipher:  conf.Encryption.MustNewCipher(),
+		configs: make(map[string]*kvPair),
+	}
+
+	if err := cs.sync(); err != nil {
+		logrus.WithError(err).WithField("address", conf.Address).Fatal("Failed to load configs from consul")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"address": conf.Address, "prefix": cs.prefix(),
+	}).Info("Consul config store opened")
+
+	return cs
+}
+
+// prefix returns the key prefix scoped to the environment.
+func (cs *ConfigStore) prefix() string {
+	if len(cs.conf.Env) == 0 {
+		return cs.conf.Prefix
+	}
+
+	return cs.conf.Prefix + cs.conf.Env + "/"
+}
+
+func (cs *ConfigStore) configKey(confName string) string {
+	return cs.prefix() + "configs/" + confName
+}
+
+func (cs *ConfigStore) seqKey() string {
+	return cs.prefix() + "seq/configs"
+}
+
+func (cs *ConfigStore) auditKeyPrefix(confName string) string {
+	return cs.prefix() + "audits/" + confName + "/"
+}
+
+func (cs *ConfigStore) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), cs.conf.RequestTimeout)
+}
+
+// OnChange registers listener to be notified once configs changed.
+func (cs *ConfigStore) OnChange(listener func()) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.listeners = append(cs.listeners, listener)
+}
+
+// Watch watches config changes by blocking queries until context done.
+func (cs *ConfigStore) Watch(ctx context.Context) {
+	for {
+		cs.mu.RLock()
+		index := cs.index
+		cs.mu.RUnlock()
+
+		// in case of index reset, eg., Consul snapshot restored
+		if index == 0 {
+			index = 1
+		}
+
+		pairs, newIndex, err := cs.client.list(ctx, cs.prefix()+"configs/", true, index, cs.conf.WaitTime)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to watch configs from consul")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cs.conf.RetryInterval):
+			}
+
+			continue
+		}
+
+		if newIndex != index {
+			cs.update(pairs, newIndex)
+		}
+	}
+}
+
+// sync loads all configs from Consul to refresh the snapshot immediately.
+func (cs *ConfigStore) sync() error {
+	ctx, cancel := cs.requestContext()
+	defer cancel()
+
+	pairs, index, err := cs.client.list(ctx, cs.prefix()+"configs/", true, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	cs.update(pairs, index)
+	return nil
+}
+
+// update updates the snapshot, and notifies listeners if any config changed.
+func (cs *ConfigStore) update(pairs []*kvPair, index uint64) {
+	configs := make(map[string]*kvPair, len(pairs))
+	for _, kv := range pairs {
+		name := strings.TrimPrefix(kv.Key, cs.prefix()+"configs/")
+		configs[name] = kv
+	}
+
+	cs.mu.Lock()
+
+	changed := len(configs) != len(cs.configs)
+	for name, kv := range configs {
+		if old, ok := cs.configs[name]; !ok || old.ModifyIndex != kv.ModifyIndex {
+			changed = true
+			break
+		}
+	}
+
+	cs.configs, cs.index = configs, index
+	listeners := cs.listeners
+
+	cs.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	logrus.WithField("index", index).Debug("Consul configs changed")
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// snapshot returns the config kv pairs whose names have the specified prefix.
+func (cs *ConfigStore) snapshot(namePrefix string) map[string]*kvPair {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	result := make(map[string]*kvPair)
+	for name, kv := range cs.configs {
+		if strings.HasPrefix(name, namePrefix) {
+			result[name] = kv
+		}
+	}
+
+	return result
+}
+
+func (cs *ConfigStore) lookup(confName string) (*kvPair, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	kv, ok := cs.configs[confName]
+	return kv, ok
+}
+
+// value returns the config value, which is decrypted if encrypted.
+func (cs *ConfigStore) value(confName string, kv *kvPair) (string, error) {
+	val, err := cs.cipher.Decrypt(confName, string(kv.Value))
+	if err != nil {
+		return "", &mysql.DecodeError{ConfName: confName, Err: err}
+	}
+
+	return val, nil
+}
+
+// ConfigStore
+
+func (cs *ConfigStore) LoadConfig(confNames ...string) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(confNames))
+
+	for _, name := range confNames {
+		kv, ok := cs.lookup(name)
+		if !ok {
+			continue
+		}
+
+		val, err := cs.value(name, kv)
+		if err != nil {
+			return nil, err
+		}
+
+		res[name] = val
+	}
+
+	return res, nil
+}
+
+func (cs *ConfigStore) StoreConfig(confName string, confVal interface{}) error {
+	return cs.StoreConfigBy(mysql.DefaultConfigOperator, confName, confVal)
+}
+
+func (cs *ConfigStore) DeleteConfig(confName string) (bool, error) {
+	return cs.DeleteConfigBy(mysql.DefaultConfigOperator, confName)
+}
+
+// ConfigLister
+
+func (cs *ConfigStore) ListConfigs(namePrefix string) (map[string]string, error) {
+	pairs := cs.snapshot(namePrefix)
+
+	res := make(map[string]string, len(pairs))
+	for name, kv := range pairs {
+		val, err := cs.value(name, kv)
+		if err != nil {
+			return nil, err
+		}
+
+		res[name] = val
+	}
+
+	return res, nil
+}
+
+// ConfigAuditStore
+
+// StoreConfigBy creates or updates config along with audit record in a check-and-set
+// transaction, which fails if the config modified concurrently.
+func (cs *ConfigStore) StoreConfigBy(operator, confName string, confVal interface{}) error {
+	newVal := confVal.(string)
+	return cs.ApplyConfigsBy(operator, []*mysql.ConfigChange{{Name: confName, Value: &newVal}})
+}
+
+// StoreConfigIfMatch creates or updates config along with audit record in a check-and-set
+// transaction only if the config version matches.
+func (cs *ConfigStore) StoreConfigIfMatch(operator, confName string, confVal interface{}, version string) error {
+	newVal := confVal.(string)
+	return cs.ApplyConfigsBy(operator, []*mysql.ConfigChange{
+		{Name: confName, Value: &newVal, Version: &version},
+	})
+}
+
+// DeleteConfigBy deletes config along with audit record in a check-and-set transaction.
+func (cs *ConfigStore) DeleteConfigBy(operator, confName string) (bool, error) {
+	ctx, cancel := cs.requestContext()
+	defer cancel()
+
+	old, err := cs.client.get(ctx, cs.configKey(confName))
+	if err != nil || old == nil {
+		return false, err
+	}
+
+	oldVal, err := cs.value(confName, old)
+	if err != nil {
+		return false, err
+	}
+
+	audit := &mysql.ConfigAudit{
+		ConfName: confName,
+		Action:   mysql.ConfigAuditActionDelete,
+		Operator: operator,
+		OldValue: &oldVal,
+	}
+
+	op := &txnKVOp{Verb: "delete-cas", Key: cs.configKey(confName), Index: old.ModifyIndex}
+	if err := cs.commit(ctx, []*txnKVOp{op}, audit); err != nil {
+		return false, err
+	}
+
+	return true, cs.sync()
+}
+
+// StoreConfigsBy creates or updates configs along with audit records in a single check-and-set
+// transaction, which is bounded by the max number of operations per Consul transaction.
+func (cs *ConfigStore) StoreConfigsBy(operator string, confs map[string]string) error {
+	changes := make([]*mysql.ConfigChange, 0, len(confs))
+	for confName, newVal := range confs {
+		newVal := newVal
+		changes = append(changes, &mysql.ConfigChange{Name: confName, Value: &newVal})
+	}
+
+	return cs.ApplyConfigsBy(operator, changes)
+}
+
+// ApplyConfigsBy applies the config changes along with audit records in a single check-and-set
+// transaction, which is bounded by the max number of operations per Consul transaction.
+func (cs *ConfigStore) ApplyConfigsBy(operator string, changes []*mysql.ConfigChange) error {
+	// along with the operation to allocate config IDs
+	if 2*len(changes)+1 > maxTxnOps {
+		return errors.Errorf("too many config changes to apply in a transaction (max %v)", (maxTxnOps-1)/2)
+	}
+
+	ctx, cancel := cs.requestContext()
+	defer cancel()
+
+	ops := make([]*txnKVOp, 0, len(changes))
+	audits := make([]*mysql.ConfigAudit, 0, len(changes))
+
+	for _, change := range changes {
+		op, audit, err := cs.changeOp(ctx, operator, change)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to apply config %v", change.Name)
+		}
+
+		if op != nil {
+			ops, audits = append(ops, op), append(audits, audit)
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if seqOp, err := cs.allocConfigIDs(ctx, ops); err != nil {
+		return err
+	} else if seqOp != nil {
+		ops = append(ops, seqOp)
+	}
+
+	if err := cs.commit(ctx, ops, audits...); err != nil {
+		return err
+	}
+
+	return cs.sync()
+}
+
+// changeOp builds the check-and-set operation along with audit record for the config change,
+// which returns nil operation if nothing to change, eg., delete a non-existent config.
+func (cs *ConfigStore) changeOp(
+	ctx context.Context, operator string, change *mysql.ConfigChange,
+) (*txnKVOp, *mysql.ConfigAudit, error) {
+	old, err := cs.client.get(ctx, cs.configKey(change.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var oldVal *string
+	if old != nil {
+		v, err := cs.value(change.Name, old)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		oldVal = &v
+	}
+
+	if change.Version != nil {
+		var oldVersion string
+		if oldVal != nil {
+			oldVersion = mysql.ConfigVersion(*oldVal)
+		}
+
+		if oldVersion != *change.Version {
+			return nil, nil, mysql.ErrConfigConflict
+		}
+	}
+
+	audit := &mysql.ConfigAudit{
+		ConfName: change.Name,
+		Action:   mysql.ConfigAuditActionStore,
+		Operator: operator,
+		OldValue: oldVal,
+		NewValue: change.Value,
+	}
+
+	// check-and-set with index 0 to create only if the key not existed
+	op := &txnKVOp{Verb: "cas", Key: cs.configKey(change.Name)}
+	if old != nil {
+		op.Index, op.Flags = old.ModifyIndex, old.Flags
+	}
+
+	if change.Value == nil {
+		if old == nil {
+			return nil, nil, nil
+		}
+
+		op.Verb, audit.Action = "delete-cas", mysql.ConfigAuditActionDelete
+	} else {
+		newVal, err := cs.cipher.Encrypt(change.Name, *change.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		op.Value = []byte(newVal)
+	}
+
+	return op, audit, nil
+}
+
+// allocConfigIDs allocates IDs from the sequence for the configs to create, and returns the
+// check-and-set operation to advance the sequence, which is nil if no config to create.
+func (cs *ConfigStore) allocConfigIDs(ctx context.Context, ops []*txnKVOp) (*txnKVOp, error) {
+	var creates []*txnKVOp
+	for _, op := range ops {
+		if op.Verb == "cas" && op.Index == 0 {
+			creates = append(creates, op)
+		}
+	}
+
+	if len(creates) == 0 {
+		return nil, nil
+	}
+
+	seq, err := cs.client.get(ctx, cs.seqKey())
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID, seqIndex uint64
+	if seq != nil {
+		if lastID, err = strconv.ParseUint(string(seq.Value), 10, 64); err != nil {
+			return nil, errors.WithMessage(err, "malformed config ID sequence")
+		}
+
+		seqIndex = seq.ModifyIndex
+	} else {
+		// initialized beyond IDs of the configs stored before
+		for _, kv := range cs.snapshot("") {
+			if id, err := configID(kv); err == nil && uint64(id) > lastID {
+				lastID = uint64(id)
+			}
+		}
+	}
+
+	if lastID+uint64(len(creates)) > math.MaxUint32 {
+		return nil, errors.New("config ID overflows")
+	}
+
+	for _, op := range creates {
+		lastID++
+		op.Flags = lastID
+	}
+
+	// check-and-set to abort the transaction if allocated concurrently
+	return &txnKVOp{
+		Verb: "cas", Key: cs.seqKey(), Value: []byte(strconv.FormatUint(lastID, 10)), Index: seqIndex,
+	}, nil
+}
+
+// configID returns the config ID kept in the flags of config key, or the create index for the
+// configs stored before, which is rejected if overflows.
+func configID(kv *kvPair) (uint32, error) {
+	id := kv.Flags
+	if id == 0 {
+		id = kv.CreateIndex
+	}
+
+	if id > math.MaxUint32 {
+		return 0, errors.Errorf("config ID %v of key %v overflows", id, kv.Key)
+	}
+
+	return uint32(id), nil
+}
+
+// commit commits the config change operations along with the audit records atomically.
+func (cs *ConfigStore) commit(ctx context.Context, ops []*txnKVOp, audits ...*mysql.ConfigAudit) error {
+	txnOps := make([]*txnOp, 0, len(ops)+len(audits))
+	for _, op := range ops {
+		txnOps = append(txnOps, &txnOp{KV: op})
+	}
+
+	now := time.Now()
+	for i, audit := range audits {
+		// IDs kept unique for audits committed at the same time
+		audit.ID, audit.CreatedAt = uint64(now.UnixNano())+uint64(i), now
+
+		// config values audited are as sensitive as configs, whose copy is encrypted so that
+		// audits of callers remain in plaintext
+		stored := *audit
+
+		var err error
+		if stored.OldValue, err = cs.cipher.EncryptValue(audit.ConfName, audit.OldValue); err != nil {
+			return err
+		}
+
+		if stored.NewValue, err = cs.cipher.EncryptValue(audit.ConfName, audit.NewValue); err != nil {
+			return err
+		}
+
+		auditVal, err := json.Marshal(&stored)
+		if err != nil {
+			return errors.WithMessage(err, "failed to marshal config audit")
+		}
+
+		// zero padded to keep audit keys in chronological order
+		auditKey := fmt.Sprintf("%v%020d", cs.auditKeyPrefix(audit.ConfName), audit.ID)
+		txnOps = append(txnOps, &txnOp{KV: &txnKVOp{Verb: "set", Key: auditKey, Value: auditVal}})
+	}
+
+	err := cs.client.txn(ctx, txnOps)
+	if errors.Is(err, errTxnConflict) {
+		return errConcurrentModification
+	}
+
+	return err
+}
+
+func (cs *ConfigStore) LoadConfigAudits(confName string, limit int) ([]*mysql.ConfigAudit, error) {
+	ctx, cancel := cs.requestContext()
+	defer cancel()
+
+	pairs, _, err := cs.client.list(ctx, cs.auditKeyPrefix(confName), true, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// in descending order of time
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key > pairs[j].Key
+	})
+
+	if limit > 0 && len(pairs) > limit {
+		pairs = pairs[:limit]
+	}
+
+	audits := make([]*mysql.ConfigAudit, 0, len(pairs))
+	for _, kv := range pairs {
+		var audit mysql.ConfigAudit
+		if err := json.Unmarshal(kv.Value, &audit); err != nil {
+			return nil, errors.WithMessagef(err, "malformed config audit %v", kv.Key)
+		}
+
+		if audit.OldValue, err = cs.cipher.DecryptValue(confName, audit.OldValue); err != nil {
+			return nil, &mysql.DecodeError{ConfName: confName, Err: err}
+		}
+
+		if audit.NewValue, err = cs.cipher.DecryptValue(confName, audit.NewValue); err != nil {
+			return nil, &mysql.DecodeError{ConfName: confName, Err: err}
+		}
+
+		audits = append(audits, &audit)
+	}
+
+	return audits, nil
+}
+
+// ConfigCacheInvalidator
+
+// InvalidateConfigCache reloads all configs from Consul to refresh the snapshot.
+func (cs *ConfigStore) InvalidateConfigCache() {
+	if err := cs.sync(); err != nil {
+		logrus.WithError(err).Warn("Failed to reload configs from consul")
+	}
+}
+
+// AclAllowListStore
+
+func (cs *ConfigStore) LoadAclAllowList(name string) (*acl.AllowList, error) {
+	confName := mysql.AclAllowListConfKeyPrefix + name
+
+	kv, ok := cs.lookup(confName)
+	if !ok {
+		return nil, errors.WithMessage(mysql.ErrConfigNotFound, confName)
+	}
+
+	id, err := configID(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := cs.value(confName, kv)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.DecodeAclAllowList(id, confName, val)
+}
+
+func (cs *ConfigStore) LoadAclAllowListById(aclID uint32) (*acl.AllowList, error) {
+	for name, kv := range cs.snapshot(mysql.AclAllowListConfKeyPrefix) {
+		if id, err := configID(kv); err == nil && id == aclID {
+			val, err := cs.value(name, kv)
+			if err != nil {
+				return nil, err
+			}
+
+			return mysql.DecodeAclAllowList(aclID, name, val)
+		}
+	}
+
+	return nil, errors.WithMessagef(mysql.ErrConfigNotFound, "allowlist #%v", aclID)
+}
+
+func (cs *ConfigStore) LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[uint32][md5.Size]byte, error) {
+	configs := cs.snapshot(mysql.AclAllowListConfKeyPrefix)
+	if len(configs) == 0 {
+		return nil, nil, nil
+	}
+
+	allowLists := make(map[uint32]*acl.AllowList)
+	checksums := make(map[uint32][md5.Size]byte)
+
+	for name, kv := range configs {
+		id, err := configID(kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid access control allowlist config")
+			continue
+		}
+
+		val, err := cs.value(name, kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid access control allowlist config")
+			continue
+		}
+
+		al, err := mysql.DecodeAclAllowList(id, name, val)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid access control allowlist config")
+			continue
+		}
+
+		allowLists[id] = al
+		checksums[id] = md5.Sum([]byte(val))
+	}
+
+	return allowLists, checksums, nil
+}
+
+// RateLimitStrategyStore
+
+func (cs *ConfigStore) LoadRateLimitConfigs() (*rate.Config, error) {
+	strategies, csStrategies, err := cs.LoadRateLimitStrategyConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	allowLists, csAllowLists, err := cs.LoadAclAllowListConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate.Config{
+		CheckSums: rate.ConfigCheckSums{
+			Strategies: csStrategies,
+			AllowLists: csAllowLists,
+		},
+		Strategies: strategies,
+		AllowLists: allowLists,
+	}, nil
+}
+
+func (cs *ConfigStore) LoadRateLimitStrategy(name string) (*rate.Strategy, error) {
+	confName := mysql.RateLimitStrategyConfKeyPrefix + name
+
+	kv, ok := cs.lookup(confName)
+	if !ok {
+		return nil, errors.WithMessage(mysql.ErrConfigNotFound, confName)
+	}
+
+	id, err := configID(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := cs.value(confName, kv)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.DecodeRateLimitStrategy(id, confName, val)
+}
+
+func (cs *ConfigStore) LoadRateLimitStrategyConfigs() (map[uint32]*rate.Strategy, map[uint32][md5.Size]byte, error) {
+	configs := cs.snapshot(mysql.RateLimitStrategyConfKeyPrefix)
+	if len(configs) == 0 {
+		return nil, nil, nil
+	}
+
+	strategies := make(map[uint32]*rate.Strategy)
+	checksums := make(map[uint32][md5.Size]byte)
+
+	for name, kv := range configs {
+		id, err := configID(kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid rate limit strategy config")
+			continue
+		}
+
+		val, err := cs.value(name, kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid rate limit strategy config")
+			continue
+		}
+
+		strategy, err := mysql.DecodeRateLimitStrategy(id, name, val)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid rate limit strategy config")
+			continue
+		}
+
+		strategies[id] = strategy
+		checksums[id] = md5.Sum([]byte(val))
+	}
+
+	return strategies, checksums, nil
+}
+
+// UsageQuotaStore
+
+func (cs *ConfigStore) LoadUsageQuotaConfigs() (map[uint32]*metering.Quota, error) {
+	quotas := make(map[uint32]*metering.Quota)
+
+	for name, kv := range cs.snapshot(mysql.UsageQuotaConfKeyPrefix) {
+		id, err := configID(kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid usage quota config")
+			continue
+		}
+
+		val, err := cs.value(name, kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid usage quota config")
+			continue
+		}
+
+		quota, err := mysql.DecodeUsageQuota(id, name, val)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid usage quota config")
+			continue
+		}
+
+		quotas[id] = quota
+	}
+
+	return quotas, nil
+}
+
+// NodeRouteGroupStore
+
+func (cs *ConfigStore) StoreNodeRouteGroupBy(operator string, routeGrp *mysql.NodeRouteGroup) error {
+	cfgVal, err := json.Marshal(routeGrp)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal node route group")
+	}
+
+	return cs.StoreConfigBy(operator, mysql.NodeRouteGroupConfKeyPrefix+routeGrp.Name, string(cfgVal))
+}
+
+func (cs *ConfigStore) DelNodeRouteGroupBy(operator, group string) error {
+	_, err := cs.DeleteConfigBy(operator, mysql.NodeRouteGroupConfKeyPrefix+group)
+	return err
+}
+
+func (cs *ConfigStore) LoadNodeRouteGroups(inclusiveGroups ...string) (map[string]*mysql.NodeRouteGroup, error) {
+	configs := cs.snapshot(mysql.NodeRouteGroupConfKeyPrefix)
+
+	if len(inclusiveGroups) > 0 {
+		inclusive := make(map[string]*kvPair)
+		for _, grp := range inclusiveGroups {
+			confName := mysql.NodeRouteGroupConfKeyPrefix + grp
+			if kv, ok := configs[confName]; ok {
+				inclusive[confName] = kv
+			}
+		}
+
+		configs = inclusive
+	}
+
+	if len(configs) == 0 { // no data
+		return nil, nil
+	}
+
+	res := make(map[string]*mysql.NodeRouteGroup)
+
+	for name, kv := range configs {
+		id, err := configID(kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid node route config")
+			continue
+		}
+
+		val, err := cs.value(name, kv)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid node route config")
+			continue
+		}
+
+		grp, err := mysql.DecodeNodeRouteGroup(id, name, val)
+		if err != nil {
+			logrus.WithField("key", kv.Key).WithError(err).Warn("Invalid node route config")
+			continue
+		}
+
+		res[grp.Name] = grp
+	}
+
+	return res, nil
+}
@@ -0,0 +1,207 @@
+// Package encryption provides envelope encryption of sensitive config values at rest, which is
+// shared by config stores of all backends, eg., MySQL and Consul.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ValuePrefix is the prefix of encrypted config value, which is versioned for key or algorithm
+	// rotation.
+	ValuePrefix = "enc:v1:"
+)
+
+var (
+	// ErrKeyNotConfigured is returned if encrypted config value is decrypted without key.
+	ErrKeyNotConfigured = errors.New("encryption key not configured")
+	// ErrMalformedValue is returned if encrypted config value is malformed.
+	ErrMalformedValue = errors.New("malformed encrypted value")
+)
+
+// Config represents the envelope encryption configurations of sensitive config values at rest,
+// eg., node route groups with credentials embedded in upstream node URLs.
+type Config struct {
+	// name of env var that provides the base64 encoded AES key of 16, 24 or 32 bytes
+	KeyEnv string
+	// file to read the base64 encoded key from, eg., mounted by KMS agent or secrets manager,
+	// which is used only if key env var not specified
+	KeyFile string
+	// prefixes of config names whose values are encrypted at rest
+	ConfigKeys []string
+}
+
+// MustNewCipher creates the cipher of config values, or returns nil if encryption disabled.
+func (config *Config) MustNewCipher() *Cipher {
+	key, err := config.loadKey()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load config encryption key")
+	}
+
+	if len(key) == 0 {
+		if len(config.ConfigKeys) > 0 {
+			logrus.Fatal("Config encryption key required to encrypt config values")
+		}
+
+		return nil
+	}
+
+	cc, err := NewCipher(key, config.ConfigKeys)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create config cipher")
+	}
+
+	logrus.WithField("configKeys", config.ConfigKeys).Info("Config values encryption at rest enabled")
+
+	return cc
+}
+
+// loadKey loads the base64 encoded key from env var or key file, or returns nil if neither
+// specified.
+func (config *Config) loadKey() ([]byte, error) {
+	var encoded string
+
+	switch {
+	case len(config.KeyEnv) > 0:
+		encoded = os.Getenv(config.KeyEnv)
+		if len(encoded) == 0 {
+			return nil, errors.Errorf("env var %v not set", config.KeyEnv)
+		}
+	case len(config.KeyFile) > 0:
+		data, err := ioutil.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to read key file")
+		}
+
+		encoded = string(data)
+	default:
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.WithMessage(err, "malformed base64 encoded key")
+	}
+
+	return key, nil
+}
+
+// Cipher encrypts values of the designated configs with AES-GCM, whose config name is
+// authenticated as additional data so that encrypted values could not be swapped between configs.
+//
+// Be noted nil cipher is valid, which never encrypts any config value.
+type Cipher struct {
+	aead     cipher.AEAD
+	prefixes []string
+}
+
+// NewCipher creates the cipher with AES key of 16, 24 or 32 bytes, which encrypts values of configs
+// whose names have any of the specified prefixes.
+func NewCipher(key []byte, prefixes []string) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead, prefixes: prefixes}, nil
+}
+
+// Designated checks whether value of the config should be encrypted.
+func (cc *Cipher) Designated(confName string) bool {
+	if cc == nil {
+		return false
+	}
+
+	for _, prefix := range cc.prefixes {
+		if strings.HasPrefix(confName, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Encrypt encrypts the config value if designated, otherwise returns the value as it is.
+func (cc *Cipher) Encrypt(confName, confVal string) (string, error) {
+	if !cc.Designated(confName) {
+		return confVal, nil
+	}
+
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithMessage(err, "failed to generate nonce")
+	}
+
+	sealed := cc.aead.Seal(nonce, nonce, []byte(confVal), []byte(confName))
+
+	return ValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the config value if encrypted, so that values stored in plaintext before
+// encryption enabled are still readable, and encrypted once changed.
+func (cc *Cipher) Decrypt(confName, confVal string) (string, error) {
+	if !strings.HasPrefix(confVal, ValuePrefix) {
+		return confVal, nil
+	}
+
+	if cc == nil {
+		return "", ErrKeyNotConfigured
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(confVal, ValuePrefix))
+	if err != nil || len(sealed) < cc.aead.NonceSize() {
+		return "", ErrMalformedValue
+	}
+
+	nonceSize := cc.aead.NonceSize()
+
+	plaintext, err := cc.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(confName))
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to decrypt")
+	}
+
+	return string(plaintext), nil
+}
+
+// DecryptValue decrypts the optional config value, eg., of config audit.
+func (cc *Cipher) DecryptValue(confName string, confVal *string) (*string, error) {
+	if confVal == nil {
+		return nil, nil
+	}
+
+	val, err := cc.Decrypt(confName, *confVal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &val, nil
+}
+
+// EncryptValue encrypts the optional config value if designated.
+func (cc *Cipher) EncryptValue(confName string, confVal *string) (*string, error) {
+	if confVal == nil || !cc.Designated(confName) {
+		return confVal, nil
+	}
+
+	val, err := cc.Encrypt(confName, *confVal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &val, nil
+}
@@ -1,11 +1,13 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	stdLog "log"
 	"os"
 	"time"
 
+	"github.com/Conflux-Chain/confura/store/encryption"
 	"github.com/Conflux-Chain/go-conflux-util/viper"
 	gosql "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
@@ -31,6 +33,11 @@ var allModels = []interface{}{
 type Config struct {
 	Enabled bool
 
+	// database driver, available options are `mysql` and `sqlite`
+	Driver string `default:"mysql"`
+	// database file path for SQLite driver
+	SqlitePath string `default:"confura.db"`
+
 	Host     string `default:"127.0.0.1:3306"`
 	Username string
 	Password string
@@ -38,14 +45,34 @@ type Config struct {
 
 	Dsn string // to support one line config
 
+	// read replicas to split reads from the primary (writer) database
+	Replicas ReplicaConfig
+
 	ConnMaxLifetime time.Duration `default:"3m"`
+	ConnMaxIdleTime time.Duration // max idle time of connection, 0 means no limit
 	MaxOpenConns    int           `default:"10"`
 	MaxIdleConns    int           `default:"10"`
 
+	// timeout of SQL statement, 0 means no timeout
+	StatementTimeout time.Duration
+	// threshold to log slow SQL statement, 0 means never log
+	SlowSqlThreshold time.Duration `default:"200ms"`
+
 	AddressIndexedLogEnabled    bool   `default:"true"`
 	AddressIndexedLogPartitions uint32 `default:"100"`
 
 	MaxBnRangedArchiveLogPartitions uint32 `default:"5"`
+	// whether to apply pending schema migrations automatically on startup
+	AutoMigrate bool `default:"true"`
+	// number of latest blocks to keep event logs for, 0 means no limit
+	LogRetentionBlocks uint64
+	// interval to refresh the cached rate limit and access control configs from db
+	ConfigCacheTTL time.Duration `default:"1m"`
+	// environment (eg., `prod` or `staging`) to scope configs, so that one database could serve
+	// multiple gateway environments, empty means the default environment
+	Env string
+	// encryption of sensitive config values at rest
+	Encryption encryption.Config
 }
 
 func mustNewConfigFromViper(key string) *Config {
@@ -56,7 +83,7 @@ func mustNewConfigFromViper(key string) *Config {
 		return &Config{}
 	}
 
-	if len(cfg.Dsn) == 0 {
+	if len(cfg.Dsn) == 0 || cfg.IsSqlite() {
 		return &cfg
 	}
 
@@ -84,6 +111,10 @@ func MustNewEthStoreConfigFromViper() *Config {
 
 // MustOpenOrCreate creates an instance of store or exits on any erorr.
 func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
+	if config.IsSqlite() {
+		return config.mustOpenOrCreateSqlite(option)
+	}
+
 	newCreated := config.mustCreateDatabaseIfAbsent()
 
 	db := config.mustNewDB(config.Database)
@@ -99,9 +130,11 @@ func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
 		newCreated = (len(tables) == 0)
 	}
 
+	migrator := NewSchemaMigrator(db)
+
 	if newCreated {
-		if err := db.Migrator().CreateTable(allModels...); err != nil {
-			logrus.WithError(err).Fatal("Failed to create tables")
+		if _, err := migrator.Up(0); err != nil {
+			logrus.WithError(err).Fatal("Failed to migrate schema for new database")
 		}
 
 		ls := NewAddressIndexedLogStore(db, NewContractStore(db), config.AddressIndexedLogPartitions)
@@ -112,43 +145,89 @@ func (config *Config) MustOpenOrCreate(option StoreOption) *MysqlStore {
 		}
 	}
 
+	if !newCreated {
+		config.mustMigrateExisting(migrator)
+	}
+
 	if sqlDb, err := db.DB(); err != nil {
 		logrus.WithError(err).Fatal("Failed to init mysql db")
 	} else {
-		sqlDb.SetConnMaxLifetime(config.ConnMaxLifetime)
-		sqlDb.SetMaxOpenConns(config.MaxOpenConns)
-		sqlDb.SetMaxIdleConns(config.MaxIdleConns)
+		config.setConnPool(sqlDb)
+	}
+
+	config.mustUseInstrumenter(db)
+
+	ms := mustNewStore(db, config, option)
+
+	if replicas := config.mustOpenReplicas(); replicas != nil {
+		if err := db.Use(replicas); err != nil {
+			logrus.WithError(err).Fatal("Failed to enable read replicas")
+		}
+
+		go replicas.schedule(config.Replicas.HealthCheckInterval)
+		ms.replicas = replicas
+
+		logrus.WithField("replicas", len(replicas.replicas)).Info("MySQL read replicas enabled")
 	}
 
 	logrus.Info("MySQL database initialized")
 
-	return mustNewStore(db, config, option)
+	return ms
 }
 
-func (config *Config) mustNewDB(database string) *gorm.DB {
-	logrusLogLevel := logrus.GetLevel()
-	gLogLevel := gormLogger.Warn
+// mustMigrateExisting applies pending schema migrations for existing database if auto migration
+// enabled, otherwise only warns about the pending migrations.
+func (config *Config) mustMigrateExisting(migrator *SchemaMigrator) {
+	if !migrator.db.Migrator().HasTable(&schemaMigration{}) {
+		// database created before versioned migrations introduced, whose tables are
+		// regarded as initial schema.
+		if err := migrator.baseline(1); err != nil {
+			logrus.WithError(err).Fatal("Failed to baseline schema migrations")
+		}
+	}
 
-	// map log level of logrus to that of gorm
-	switch {
-	case logrusLogLevel <= logrus.ErrorLevel:
-		gLogLevel = gormLogger.Error
-	case logrusLogLevel >= logrus.DebugLevel:
-		// gorm info log level is kind of too verbose
-		gLogLevel = gormLogger.Info
+	pending, err := migrator.Pending()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load pending schema migrations")
 	}
 
-	// create gorm logger by customizing the default logger
-	gLogger := gormLogger.New(
-		stdLog.New(os.Stdout, "\r\n", stdLog.LstdFlags), // io writer
-		gormLogger.Config{
-			SlowThreshold:             time.Millisecond * 200, // slow SQL threshold (200ms)
-			LogLevel:                  gLogLevel,              // log level
-			IgnoreRecordNotFoundError: true,                   // never logging on ErrRecordNotFound error
-			Colorful:                  true,                   // use colorful print
-		},
-	)
+	if len(pending) == 0 {
+		return
+	}
+
+	if !config.AutoMigrate {
+		logrus.WithField("pendingMigrations", len(pending)).
+			Warn("Pending schema migrations found, please run `migrate up` to apply")
+		return
+	}
 
+	if _, err := migrator.Up(0); err != nil {
+		logrus.WithError(err).Fatal("Failed to apply pending schema migrations")
+	}
+}
+
+// setConnPool applies the connection pool settings to database.
+func (config *Config) setConnPool(db *sql.DB) {
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+}
+
+// mustUseInstrumenter enables the plugin to enforce statement timeout and instrument SQL
+// statements.
+func (config *Config) mustUseInstrumenter(db *gorm.DB) {
+	err := db.Use(&statementInstrumenter{
+		timeout:       config.StatementTimeout,
+		slowThreshold: config.SlowSqlThreshold,
+	})
+
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to enable SQL statement instrumenter")
+	}
+}
+
+func (config *Config) mustNewDB(database string) *gorm.DB {
 	// refer to https://github.com/go-sql-driver/mysql#dsn-data-source-name
 	dsn := fmt.Sprintf("%v:%v@tcp(%v)/%v?parseTime=true", config.Username, config.Password, config.Host, database)
 	if database == config.Database && len(config.Dsn) > 0 {
@@ -156,7 +235,7 @@ func (config *Config) mustNewDB(database string) *gorm.DB {
 	}
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: gLogger,
+		Logger: config.newGormLogger(),
 	})
 
 	if err != nil {
@@ -166,6 +245,31 @@ func (config *Config) mustNewDB(database string) *gorm.DB {
 	return db
 }
 
+func (config *Config) newGormLogger() gormLogger.Interface {
+	logrusLogLevel := logrus.GetLevel()
+	gLogLevel := gormLogger.Warn
+
+	// map log level of logrus to that of gorm
+	switch {
+	case logrusLogLevel <= logrus.ErrorLevel:
+		gLogLevel = gormLogger.Error
+	case logrusLogLevel >= logrus.DebugLevel:
+		// gorm info log level is kind of too verbose
+		gLogLevel = gormLogger.Info
+	}
+
+	// create gorm logger by customizing the default logger
+	return gormLogger.New(
+		stdLog.New(os.Stdout, "\r\n", stdLog.LstdFlags), // io writer
+		gormLogger.Config{
+			SlowThreshold:             0,         // slow SQL logged by instrumenter instead
+			LogLevel:                  gLogLevel, // log level
+			IgnoreRecordNotFoundError: true,      // never logging on ErrRecordNotFound error
+			Colorful:                  true,      // use colorful print
+		},
+	)
+}
+
 func (config *Config) mustCreateDatabaseIfAbsent() bool {
 	db := config.mustNewDB("")
 	if mysqlDb, err := db.DB(); err != nil {
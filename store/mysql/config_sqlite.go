@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	// available database drivers
+	DriverMysql  = "mysql"
+	DriverSqlite = "sqlite"
+)
+
+// IsSqlite checks whether SQLite is used as the database driver.
+func (config *Config) IsSqlite() bool {
+	return strings.EqualFold(config.Driver, DriverSqlite)
+}
+
+// mustOpenOrCreateSqlite opens or creates SQLite database file for single node or dev deployments
+// with zero external database dependencies.
+//
+// Be noted that only configurations (eg., rate limit, ACL and node route group etc.) are supported
+// to persist, while chain data requires MySQL database since the range partitioned tables are MySQL
+// specific.
+func (config *Config) mustOpenOrCreateSqlite(option StoreOption) *MysqlStore {
+	if option.Disabler != nil && !isAllChainDataDisabled(option.Disabler) {
+		logrus.Fatal("Chain data persistence is not supported by SQLite, please disable all chain data types")
+	}
+
+	db, err := gorm.Open(sqlite.Open(config.SqlitePath), &gorm.Config{
+		Logger: config.newGormLogger(),
+	})
+	if err != nil {
+		logrus.WithField("path", config.SqlitePath).WithError(err).Fatal("Failed to open sqlite")
+	}
+
+	// SQLite only supports one writer at a time
+	if sqlDb, err := db.DB(); err != nil {
+		logrus.WithError(err).Fatal("Failed to init sqlite db")
+	} else {
+		sqlDb.SetMaxOpenConns(1)
+	}
+
+	config.mustUseInstrumenter(db)
+
+	migrator := NewSchemaMigrator(db)
+	if config.AutoMigrate {
+		if _, err := migrator.Up(0); err != nil {
+			logrus.WithError(err).Fatal("Failed to migrate schema for sqlite database")
+		}
+	}
+
+	logrus.WithField("path", config.SqlitePath).Info("SQLite database initialized")
+
+	return mustNewStore(db, config, option)
+}
+
+func isAllChainDataDisabled(disabler store.StoreDisabler) bool {
+	return disabler.IsChainBlockDisabled() &&
+		disabler.IsChainTxnDisabled() &&
+		disabler.IsChainReceiptDisabled() &&
+		disabler.IsChainLogDisabled()
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type allDisabler struct{}
+
+func (allDisabler) IsChainBlockDisabled() bool                     { return true }
+func (allDisabler) IsChainTxnDisabled() bool                       { return true }
+func (allDisabler) IsChainReceiptDisabled() bool                   { return true }
+func (allDisabler) IsChainLogDisabled() bool                       { return true }
+func (allDisabler) IsDisabledForType(edt store.EpochDataType) bool { return true }
+
+func newTestSqliteStore(t *testing.T) *MysqlStore {
+	config := &Config{
+		Enabled:     true,
+		Driver:      DriverSqlite,
+		SqlitePath:  filepath.Join(t.TempDir(), "confura.db"),
+		AutoMigrate: true,
+	}
+
+	ms := config.MustOpenOrCreate(StoreOption{Disabler: allDisabler{}})
+	t.Cleanup(func() { ms.Close() })
+
+	return ms
+}
+
+func TestSqliteConfigStore(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	// upsert config
+	assert.NoError(t, ms.StoreConfig("foo", "bar"))
+	assert.NoError(t, ms.StoreConfig("foo", "baz"))
+
+	confs, err := ms.LoadConfig("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", confs["foo"])
+
+	// all schema migrations applied
+	pending, err := ms.SchemaMigrator().Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrConfigNotFound is returned if the config item does not exist.
+	ErrConfigNotFound = errors.New("config not found")
+	// ErrDecodeFailed is returned if the config item value is malformed.
+	ErrDecodeFailed = errors.New("config decode failed")
+	// ErrConfigConflict is returned if the config item has been modified concurrently.
+	ErrConfigConflict = errors.New("config modified concurrently")
+	// ErrNodeRouteGroupNotFound is returned if the node route group is neither builtin nor stored.
+	ErrNodeRouteGroupNotFound = errors.New("node route group not found")
+)
+
+// DecodeError is returned when failed to decode a config item, which can be
+// checked with `errors.Is(err, ErrDecodeFailed)`.
+type DecodeError struct {
+	ConfName string // config name
+	Err      error  // underlying error
+}
+
+func newDecodeError(confName string, err error) *DecodeError {
+	return &DecodeError{ConfName: confName, Err: err}
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v for %v: %v", ErrDecodeFailed, e.ConfName, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecodeFailed
+}
+
+// errConfigNotFound wraps `ErrConfigNotFound` with the config name for context.
+func errConfigNotFound(confName string) error {
+	return errors.WithMessage(ErrConfigNotFound, confName)
+}
+
+// errConfigConflict wraps `ErrConfigConflict` with the config name for context.
+func errConfigConflict(confName string) error {
+	return errors.WithMessage(ErrConfigConflict, confName)
+}
@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	instrumentKeyStart   = "confura:instrument_start"
+	instrumentKeyContext = "confura:instrument_context"
+	instrumentKeyCancel  = "confura:instrument_cancel"
+)
+
+// statementInstrumenter is a gorm plugin to enforce statement timeout, record latency of SQL
+// statements and log the slow ones.
+type statementInstrumenter struct {
+	// timeout of SQL statement, 0 means no timeout
+	timeout time.Duration
+	// threshold to log slow SQL statement, 0 means never log
+	slowThreshold time.Duration
+}
+
+func (si *statementInstrumenter) Name() string {
+	return "confura:instrument"
+}
+
+func (si *statementInstrumenter) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	type registerFunc func(name string, fn func(*gorm.DB)) error
+
+	// operation => register functions of before and after callbacks
+	processors := map[string][2]registerFunc{
+		"create": {cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		"query":  {cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		"update": {cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		"delete": {cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		"row":    {cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		"raw":    {cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+
+	for op, registers := range processors {
+		// Note, rows returned by `Row` processor are iterated after callbacks, and thus could
+		// not be bound with the statement timeout context.
+		timeout := si.timeout
+		if op == "row" {
+			timeout = 0
+		}
+
+		if err := registers[0]("confura:instrument_before_"+op, si.before(timeout)); err != nil {
+			return errors.WithMessagef(err, "failed to register before callback for %v", op)
+		}
+
+		if err := registers[1]("confura:instrument_after_"+op, si.after(op)); err != nil {
+			return errors.WithMessagef(err, "failed to register after callback for %v", op)
+		}
+	}
+
+	return nil
+}
+
+func (si *statementInstrumenter) before(timeout time.Duration) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(instrumentKeyStart, time.Now())
+
+		if timeout <= 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(db.Statement.Context, timeout)
+		db.InstanceSet(instrumentKeyContext, db.Statement.Context)
+		db.InstanceSet(instrumentKeyCancel, cancel)
+
+		db.Statement.Context = ctx
+	}
+}
+
+func (si *statementInstrumenter) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		// restore the statement context in case of statement reused
+		if cancel, ok := db.InstanceGet(instrumentKeyCancel); ok {
+			cancel.(context.CancelFunc)()
+
+			if ctx, ok := db.InstanceGet(instrumentKeyContext); ok {
+				db.Statement.Context = ctx.(context.Context)
+			}
+		}
+
+		v, ok := db.InstanceGet(instrumentKeyStart)
+		if !ok {
+			return
+		}
+
+		start := v.(time.Time)
+		elapsed := time.Since(start)
+
+		metrics.Registry.Store.Statement(op).UpdateSince(start)
+
+		if si.slowThreshold <= 0 || elapsed < si.slowThreshold {
+			return
+		}
+
+		metrics.Registry.Store.SlowStatement(op).Mark(1)
+
+		logger := logrus.WithFields(logrus.Fields{
+			"op":           op,
+			"table":        db.Statement.Table,
+			"sql":          db.Statement.SQL.String(),
+			"rowsAffected": db.RowsAffected,
+			"elapsed":      elapsed,
+		})
+
+		if db.Error != nil {
+			logger = logger.WithError(db.Error)
+		}
+
+		logger.Warn("Slow SQL statement detected")
+	}
+}
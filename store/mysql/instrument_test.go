@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	db := newReplicaTestDB(t, "timeout.db", "foo")
+	assert.NoError(t, db.Use(&statementInstrumenter{timeout: time.Nanosecond}))
+
+	var item replicaTestItem
+	assert.Equal(t, context.DeadlineExceeded, db.First(&item, 1).Error)
+
+	// statement context restored once done
+	tx := db.Where("id = ?", 1)
+	assert.Error(t, tx.Find(&item).Error)
+	assert.NoError(t, tx.Statement.Context.Err())
+}
@@ -0,0 +1,390 @@
+package mysql
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// schemaMigration is the db record of an applied schema migration.
+type schemaMigration struct {
+	Version   uint32 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:128;not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migration is a versioned schema change which can be applied (up) or reverted (down).
+type Migration struct {
+	// version number, which must be unique and increase monotonically
+	Version uint32
+	// short description of the schema change
+	Name string
+
+	Up   func(db *gorm.DB) error
+	Down func(db *gorm.DB) error
+}
+
+// MigrationStatus is the status of a versioned migration.
+type MigrationStatus struct {
+	Version   uint32
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// migrations all the versioned schema migrations in ascending order of version.
+//
+// Be noted a new migration should always be appended with a greater version number, and
+// the applied migrations should never be modified.
+var migrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "create_initial_tables",
+		Up: func(db *gorm.DB) error {
+			return createTablesIfAbsent(db, allModels...)
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(allModels...)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_config_audits_table",
+		Up: func(db *gorm.DB) error {
+			return createTablesIfAbsent(db, &ConfigAudit{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&ConfigAudit{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_api_key_usages_table",
+		Up: func(db *gorm.DB) error {
+			return createTablesIfAbsent(db, &ApiKeyUsage{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&ApiKeyUsage{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_slow_queries_table",
+		Up: func(db *gorm.DB) error {
+			return createTablesIfAbsent(db, &SlowQuery{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&SlowQuery{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "scope_configs_by_env",
+		Up:      scopeConfigsByEnv,
+		Down:    unscopeConfigsByEnv,
+	},
+	{
+		Version: 6,
+		Name:    "create_api_key_failures_table",
+		Up: func(db *gorm.DB) error {
+			return createTablesIfAbsent(db, &ApiKeyFailure{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&ApiKeyFailure{})
+		},
+	},
+}
+
+// unscopedConf is the config schema before scoped by environment.
+type unscopedConf struct {
+	ID        uint32
+	Name      string `gorm:"unique;size:128;not null"`
+	Value     string `gorm:"size:16250;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (unscopedConf) TableName() string {
+	return "configs"
+}
+
+// scopeConfigsByEnv adds environment column to configs and config audits, and replaces the unique
+// index of config name with that of (env, name).
+//
+// The configs table is rebuilt, since unique constraint of column is not droppable in SQLite. DDL
+// is not transactional in MySQL, so the migration resumes from the legacy table if interrupted.
+func scopeConfigsByEnv(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&ConfigAudit{}, "env") {
+		if err := db.Migrator().AddColumn(&ConfigAudit{}, "Env"); err != nil {
+			return err
+		}
+	}
+
+	const legacyTable = "configs_unscoped"
+
+	if !db.Migrator().HasTable(legacyTable) {
+		// table created in the latest schema already
+		if db.Migrator().HasColumn(&conf{}, "env") {
+			return nil
+		}
+
+		if err := db.Migrator().RenameTable(&conf{}, legacyTable); err != nil {
+			return err
+		}
+	}
+
+	return rebuildConfigs(db, &conf{}, legacyTable, "1 = 1")
+}
+
+// unscopeConfigsByEnv reverts `scopeConfigsByEnv`, which fails if there are configs of any other
+// environment than the default one, since they might be of the same name.
+func unscopeConfigsByEnv(db *gorm.DB) error {
+	const legacyTable = "configs_scoped"
+
+	if !db.Migrator().HasTable(legacyTable) {
+		// table reverted already
+		if !db.Migrator().HasColumn(&conf{}, "env") {
+			return dropConfigAuditEnv(db)
+		}
+
+		var count int64
+		if err := db.Model(&conf{}).Where("env <> ''").Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errors.Errorf("%v configs of non-default environments should be removed at first", count)
+		}
+
+		if err := db.Migrator().RenameTable(&conf{}, legacyTable); err != nil {
+			return err
+		}
+	}
+
+	if err := rebuildConfigs(db, &unscopedConf{}, legacyTable, "env = ''"); err != nil {
+		return err
+	}
+
+	return dropConfigAuditEnv(db)
+}
+
+// rebuildConfigs creates configs table of the model if absent, and copies the configs matched by
+// condition from the legacy table which are not copied yet, and then drops the legacy table.
+func rebuildConfigs(db *gorm.DB, model interface{}, legacyTable, cond string) error {
+	if err := createTablesIfAbsent(db, model); err != nil {
+		return err
+	}
+
+	err := db.Exec(
+		"INSERT INTO configs (id, name, value, created_at, updated_at) " +
+			"SELECT id, name, value, created_at, updated_at FROM " + legacyTable +
+			" WHERE " + cond + " AND id NOT IN (SELECT id FROM configs)",
+	).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Migrator().DropTable(legacyTable)
+}
+
+func dropConfigAuditEnv(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&ConfigAudit{}, "env") {
+		return nil
+	}
+
+	return db.Migrator().DropColumn(&ConfigAudit{}, "env")
+}
+
+func createTablesIfAbsent(db *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if db.Migrator().HasTable(model) {
+			continue
+		}
+
+		if err := db.Migrator().CreateTable(model); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SchemaMigrator applies or reverts versioned schema migrations, with the applied migrations
+// recorded in the `schema_migrations` table.
+type SchemaMigrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+func NewSchemaMigrator(db *gorm.DB) *SchemaMigrator {
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return &SchemaMigrator{db: db, migrations: sorted}
+}
+
+// init creates the `schema_migrations` table if not existed.
+func (m *SchemaMigrator) init() error {
+	return createTablesIfAbsent(m.db, &schemaMigration{})
+}
+
+// applied returns all applied migrations keyed by version.
+func (m *SchemaMigrator) applied() (map[uint32]*schemaMigration, error) {
+	if err := m.init(); err != nil {
+		return nil, errors.WithMessage(err, "failed to create schema migrations table")
+	}
+
+	var records []*schemaMigration
+	if err := m.db.Order("version ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint32]*schemaMigration, len(records))
+	for _, r := range records {
+		result[r.Version] = r
+	}
+
+	return result, nil
+}
+
+// Status returns the status of all versioned migrations.
+func (m *SchemaMigrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []MigrationStatus
+	for _, mg := range m.migrations {
+		status := MigrationStatus{Version: mg.Version, Name: mg.Name}
+		if r, ok := applied[mg.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &r.AppliedAt
+		}
+
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+// Pending returns all migrations not applied yet.
+func (m *SchemaMigrator) Pending() ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			result = append(result, mg)
+		}
+	}
+
+	return result, nil
+}
+
+// Up applies pending migrations in ascending order of version up to the target version
+// (inclusive), with 0 meaning the latest version.
+func (m *SchemaMigrator) Up(target uint32) ([]*Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []*Migration
+	for _, mg := range pending {
+		if target > 0 && mg.Version > target {
+			break
+		}
+
+		// Be noted DDL statements in MySQL cause an implicit commit, so the migration
+		// is recorded only after the schema change succeeds.
+		if err := mg.Up(m.db); err != nil {
+			return migrated, errors.WithMessagef(err, "failed to apply migration %v", mg.Version)
+		}
+
+		record := schemaMigration{Version: mg.Version, Name: mg.Name, AppliedAt: time.Now()}
+		if err := m.db.Create(&record).Error; err != nil {
+			return migrated, errors.WithMessagef(err, "failed to record migration %v", mg.Version)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"version": mg.Version, "name": mg.Name,
+		}).Info("Schema migration applied")
+
+		migrated = append(migrated, mg)
+	}
+
+	return migrated, nil
+}
+
+// Down reverts the specified number of latest applied migrations in descending order of version.
+func (m *SchemaMigrator) Down(steps int) ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []*Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		if mg.Down == nil {
+			return reverted, errors.Errorf("migration %v is irreversible", mg.Version)
+		}
+
+		if err := mg.Down(m.db); err != nil {
+			return reverted, errors.WithMessagef(err, "failed to revert migration %v", mg.Version)
+		}
+
+		if err := m.db.Delete(&schemaMigration{Version: mg.Version}).Error; err != nil {
+			return reverted, errors.WithMessagef(err, "failed to delete migration record %v", mg.Version)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"version": mg.Version, "name": mg.Name,
+		}).Info("Schema migration reverted")
+
+		reverted = append(reverted, mg)
+	}
+
+	return reverted, nil
+}
+
+// baseline marks all migrations up to the target version as applied without executing them,
+// which is used for database whose schema was created before versioned migrations introduced.
+func (m *SchemaMigrator) baseline(target uint32) error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+
+	for _, mg := range pending {
+		if mg.Version > target {
+			break
+		}
+
+		record := schemaMigration{Version: mg.Version, Name: mg.Name, AppliedAt: time.Now()}
+		if err := m.db.Create(&record).Error; err != nil {
+			return errors.WithMessagef(err, "failed to record migration %v", mg.Version)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newUnscopedConfigsDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migration.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.AutoMigrate(&unscopedConf{}))
+	assert.NoError(t, db.Create(&unscopedConf{Name: "foo", Value: "bar"}).Error)
+	assert.NoError(t, db.Table("config_audits").AutoMigrate(&struct {
+		ID       uint64
+		ConfName string
+	}{}))
+
+	return db
+}
+
+func TestScopeConfigsByEnv(t *testing.T) {
+	db := newUnscopedConfigsDB(t)
+
+	assert.NoError(t, scopeConfigsByEnv(db))
+	assert.True(t, db.Migrator().HasColumn(&ConfigAudit{}, "env"))
+
+	var cfgs []conf
+	assert.NoError(t, db.Find(&cfgs).Error)
+	if assert.Len(t, cfgs, 1) {
+		assert.Equal(t, "", cfgs[0].Env)
+		assert.Equal(t, "bar", cfgs[0].Value)
+	}
+
+	// same config name allowed in different environments
+	assert.NoError(t, db.Create(&conf{Env: "staging", Name: "foo", Value: "baz"}).Error)
+	assert.Error(t, db.Create(&conf{Env: "staging", Name: "foo", Value: "baz"}).Error)
+
+	// idempotent
+	assert.NoError(t, scopeConfigsByEnv(db))
+
+	// irreversible unless configs of non-default environments removed
+	assert.Error(t, unscopeConfigsByEnv(db))
+	assert.NoError(t, db.Delete(&conf{}, "env = ?", "staging").Error)
+
+	assert.NoError(t, unscopeConfigsByEnv(db))
+	assert.False(t, db.Migrator().HasColumn(&conf{}, "env"))
+	assert.False(t, db.Migrator().HasColumn(&ConfigAudit{}, "env"))
+
+	var unscoped []unscopedConf
+	assert.NoError(t, db.Find(&unscoped).Error)
+	if assert.Len(t, unscoped, 1) {
+		assert.Equal(t, "foo", unscoped[0].Name)
+		assert.Equal(t, "bar", unscoped[0].Value)
+	}
+
+	assert.NoError(t, unscopeConfigsByEnv(db))
+}
+
+func TestScopeConfigsByEnvResume(t *testing.T) {
+	db := newUnscopedConfigsDB(t)
+
+	// interrupted once the legacy table renamed and the new table created
+	assert.NoError(t, db.Migrator().RenameTable(&unscopedConf{}, "configs_unscoped"))
+	assert.NoError(t, db.Migrator().CreateTable(&conf{}))
+
+	assert.NoError(t, scopeConfigsByEnv(db))
+	assert.False(t, db.Migrator().HasTable("configs_unscoped"))
+
+	var cfgs []conf
+	assert.NoError(t, db.Find(&cfgs).Error)
+	if assert.Len(t, cfgs, 1) {
+		assert.Equal(t, "bar", cfgs[0].Value)
+	}
+}
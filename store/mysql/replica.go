@@ -0,0 +1,232 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	gosql "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// timeout to ping read replica for health checking
+	replicaPingTimeout = 3 * time.Second
+)
+
+// ReplicaConfig represents the read replicas configurations, to which reads are split from
+// the primary (writer) database.
+type ReplicaConfig struct {
+	// DSNs of read replicas
+	Dsns []string
+	// interval to check health of read replicas, unhealthy replicas are not read from until
+	// recovered
+	HealthCheckInterval time.Duration `default:"10s"`
+	// reads are served by the primary within the period since the last write to read your own
+	// writes regardless of replication lag, eg., for data sync
+	StickyWindow time.Duration `default:"5s"`
+}
+
+// replica is a read replica database with health status.
+type replica struct {
+	name    string // replica name for logging and metrics, eg., host address
+	db      *sql.DB
+	healthy int32 // atomic, 1 means healthy
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// checkHealth pings the replica and updates the health status, which returns true if the
+// status changed.
+func (r *replica) checkHealth() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+
+	var healthy int32
+	if err == nil {
+		healthy = 1
+	}
+
+	metrics.Registry.Store.ReplicaHealthy(r.name).Update(int64(healthy))
+
+	return atomic.SwapInt32(&r.healthy, healthy) != healthy, err
+}
+
+// replicaResolver is a gorm plugin to split reads onto the healthy read replicas in round-robin,
+// which falls back to the primary database if no replica available.
+//
+// Note, reads are kept on the primary inside transactions, for locking reads (eg., `SELECT ...
+// FOR UPDATE`), or shortly after writes to read your own writes.
+type replicaResolver struct {
+	replicas     []*replica
+	stickyWindow time.Duration
+
+	next      uint32 // atomic, round-robin index
+	lastWrite int64  // atomic, unix nano of the last write
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newReplicaResolver(replicas []*replica, stickyWindow time.Duration) *replicaResolver {
+	return &replicaResolver{
+		replicas:     replicas,
+		stickyWindow: stickyWindow,
+		done:         make(chan struct{}),
+	}
+}
+
+// mustOpenReplicas opens the configured read replicas with the same connection pool settings
+// as the primary, or returns nil if no replica configured.
+func (config *Config) mustOpenReplicas() *replicaResolver {
+	if len(config.Replicas.Dsns) == 0 {
+		return nil
+	}
+
+	var replicas []*replica
+	for _, dsn := range config.Replicas.Dsns {
+		dsnCfg, err := gosql.ParseDSN(dsn)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to parse read replica DSN")
+		}
+
+		db, err := sql.Open(DriverMysql, dsn)
+		if err != nil {
+			logrus.WithError(err).WithField("replica", dsnCfg.Addr).Fatal("Failed to open read replica")
+		}
+
+		config.setConnPool(db)
+
+		replicas = append(replicas, &replica{name: dsnCfg.Addr, db: db})
+	}
+
+	return newReplicaResolver(replicas, config.Replicas.StickyWindow)
+}
+
+func (r *replicaResolver) Name() string {
+	return "confura:replicas"
+}
+
+func (r *replicaResolver) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Query().Before("gorm:query").Register("confura:replicas_read", r.resolve); err != nil {
+		return err
+	}
+
+	if err := cb.Row().Before("gorm:row").Register("confura:replicas_read", r.resolve); err != nil {
+		return err
+	}
+
+	for _, p := range []interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}{cb.Create(), cb.Update(), cb.Delete(), cb.Raw()} {
+		if err := p.Register("confura:replicas_write", r.markWrite); err != nil {
+			return errors.WithMessage(err, "failed to register write callback")
+		}
+	}
+
+	return nil
+}
+
+// resolve switches the connection pool of read statement to a healthy replica if available.
+func (r *replicaResolver) resolve(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	// keep reads in transaction on the primary
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+
+	// locking reads must go to the primary
+	if _, ok := db.Statement.Clauses[clause.Locking{}.Name()]; ok {
+		return
+	}
+
+	if lastWrite := atomic.LoadInt64(&r.lastWrite); time.Since(time.Unix(0, lastWrite)) < r.stickyWindow {
+		return
+	}
+
+	if rp, ok := r.pick(); ok {
+		db.Statement.ConnPool = rp.db
+	}
+}
+
+func (r *replicaResolver) markWrite(db *gorm.DB) {
+	atomic.StoreInt64(&r.lastWrite, time.Now().UnixNano())
+}
+
+// pick picks a healthy replica in round-robin.
+func (r *replicaResolver) pick() (*replica, bool) {
+	n := uint32(len(r.replicas))
+	start := atomic.AddUint32(&r.next, 1)
+
+	for i := uint32(0); i < n; i++ {
+		if rp := r.replicas[(start+i)%n]; rp.isHealthy() {
+			return rp, true
+		}
+	}
+
+	return nil, false
+}
+
+// checkHealth checks health of all replicas at once.
+func (r *replicaResolver) checkHealth() {
+	for _, rp := range r.replicas {
+		changed, err := rp.checkHealth()
+		if !changed {
+			continue
+		}
+
+		logger := logrus.WithField("replica", rp.name)
+		if err != nil {
+			logger.WithError(err).Warn("Read replica turned unhealthy, reads fall back to others or the primary")
+		} else {
+			logger.Info("Read replica turned healthy")
+		}
+	}
+}
+
+// schedule checks health of replicas periodically until closed.
+func (r *replicaResolver) schedule(interval time.Duration) {
+	r.checkHealth()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.checkHealth()
+		}
+	}
+}
+
+// Close stops health checking and closes all replicas.
+func (r *replicaResolver) Close() (err error) {
+	r.closeOnce.Do(func() {
+		close(r.done)
+
+		for _, rp := range r.replicas {
+			if e := rp.db.Close(); e != nil {
+				err = e
+			}
+		}
+	})
+
+	return err
+}
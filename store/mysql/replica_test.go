@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type replicaTestItem struct {
+	ID    uint32
+	Value string
+}
+
+func newReplicaTestDB(t *testing.T, name, value string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.AutoMigrate(&replicaTestItem{}))
+	assert.NoError(t, db.Create(&replicaTestItem{ID: 1, Value: value}).Error)
+
+	return db
+}
+
+func TestReplicaResolver(t *testing.T) {
+	primary := newReplicaTestDB(t, "primary.db", "primary")
+	replicaDB, err := newReplicaTestDB(t, "replica.db", "replica").DB()
+	assert.NoError(t, err)
+
+	resolver := newReplicaResolver([]*replica{{name: "replica", db: replicaDB}}, time.Hour)
+	defer resolver.Close()
+	assert.NoError(t, primary.Use(resolver))
+
+	readValue := func() string {
+		var item replicaTestItem
+		assert.NoError(t, primary.First(&item, 1).Error)
+		return item.Value
+	}
+
+	// replica not healthy yet
+	assert.Equal(t, "primary", readValue())
+
+	resolver.checkHealth()
+	assert.Equal(t, "replica", readValue())
+
+	// transaction always reads from primary
+	assert.NoError(t, primary.Transaction(func(tx *gorm.DB) error {
+		var item replicaTestItem
+		assert.NoError(t, tx.First(&item, 1).Error)
+		assert.Equal(t, "primary", item.Value)
+		return nil
+	}))
+
+	// read your writes
+	assert.NoError(t, primary.Model(&replicaTestItem{}).Where("id = ?", 1).Update("value", "updated").Error)
+	assert.Equal(t, "updated", readValue())
+
+	// unhealthy replica falls back to primary
+	resolver.stickyWindow = 0
+	assert.Equal(t, "replica", readValue())
+
+	replicaDB.Close()
+	resolver.checkHealth()
+	assert.Equal(t, "updated", readValue())
+}
@@ -9,6 +9,7 @@ import (
 	citypes "github.com/Conflux-Chain/confura/types"
 	"github.com/Conflux-Chain/confura/util/metrics"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -34,6 +35,8 @@ type MysqlStore struct {
 	*RateLimitStore
 	*VirtualFilterLogStore
 	*NodeRouteStore
+	*UsageStore
+	*SlowQueryStore
 	ls   *logStore
 	ails *AddressIndexedLogStore
 	bcls *bigContractLogStore
@@ -45,6 +48,8 @@ type MysqlStore struct {
 	disabler store.StoreDisabler
 	// store pruner
 	pruner *storePruner
+	// read replicas resolver, nil if no read replica configured
+	replicas *replicaResolver
 }
 
 func mustNewStore(db *gorm.DB, config *Config, option StoreOption) *MysqlStore {
@@ -58,11 +63,13 @@ func mustNewStore(db *gorm.DB, config *Config, option StoreOption) *MysqlStore {
 		epochBlockMapStore:    ebms,
 		txStore:               newTxStore(db),
 		blockStore:            newBlockStore(db),
-		confStore:             newConfStore(db),
+		confStore:             newConfStore(db, config.Env, config.Encryption.MustNewCipher(), config.ConfigCacheTTL),
 		UserStore:             newUserStore(db),
-		RateLimitStore:        NewRateLimitStore(db),
+		RateLimitStore:        NewRateLimitStore(db, config.Env),
 		VirtualFilterLogStore: NewVirtualFilterLogStore(db),
 		NodeRouteStore:        NewNodeRouteStore(db),
+		UsageStore:            NewUsageStore(db),
+		SlowQueryStore:        NewSlowQueryStore(db),
 		ls:                    newLogStore(db, cs, ebms, pruner.newBnPartitionObsChan),
 		bcls:                  newBigContractLogStore(db, cs, ebms, ails, pruner.newBnPartitionObsChan),
 		ails:                  ails,
@@ -329,3 +336,19 @@ func (ms *MysqlStore) GetLogs(ctx context.Context, storeFilter store.LogFilter)
 func (ms *MysqlStore) Prune() {
 	go ms.pruner.schedulePrune(ms.config)
 }
+
+// SchemaMigrator returns the migrator to apply or revert versioned schema migrations.
+func (ms *MysqlStore) SchemaMigrator() *SchemaMigrator {
+	return NewSchemaMigrator(ms.baseStore.db)
+}
+
+// Close closes the read replicas if any and the primary database.
+func (ms *MysqlStore) Close() error {
+	if ms.replicas != nil {
+		if err := ms.replicas.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close read replicas")
+		}
+	}
+
+	return ms.baseStore.Close()
+}
@@ -17,7 +17,9 @@ func newBaseStore(db *gorm.DB) *baseStore {
 }
 
 func (baseStore) IsRecordNotFound(err error) bool {
-	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, store.ErrNotFound)
+	return errors.Is(err, gorm.ErrRecordNotFound) ||
+		errors.Is(err, store.ErrNotFound) ||
+		errors.Is(err, ErrConfigNotFound)
 }
 
 func (bs *baseStore) Close() error {
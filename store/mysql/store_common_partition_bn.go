@@ -481,3 +481,58 @@ func (bnps *bnPartitionedStore) pruneArchivePartitions(
 
 	return prunedPartitions, nil
 }
+
+// pruneExpiredPartitions iteratively prunes archive partitions chronologically from the
+// oldest partition until the partition has any entity data within the keep window, which
+// is the specified number of blocks backwards from the latest block number of the entity.
+// Be noted the latest partition will never be pruned.
+//
+// Note the iterative prune operations are not atomic.
+func (bnps *bnPartitionedStore) pruneExpiredPartitions(
+	entity string, tabler schema.Tabler, keepWindow uint64,
+) ([]*bnPartition, error) {
+	var prunedPartitions []*bnPartition
+
+	_, bnEnd, existed, err := bnps.bnRange(entity)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get block number range")
+	}
+
+	if !existed || bnEnd < keepWindow { // no partitions found or not expired yet
+		return nil, nil
+	}
+
+	startPartIdx, endPartIdx, existed, err := bnps.indexRange(entity)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get partition index range")
+	}
+
+	if !existed { // no partitions found
+		return nil, nil
+	}
+
+	expiredBn := bnEnd - keepWindow
+	for i := startPartIdx; i < endPartIdx; i++ {
+		partition, err := bnps.getPartitionByIndex(entity, i)
+		if err != nil {
+			return prunedPartitions, errors.WithMessagef(err, "failed to get partition %d", i)
+		}
+
+		if !partition.BnMax.Valid || uint64(partition.BnMax.Int64) >= expiredBn {
+			// partition not expired yet
+			break
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"partition": partition, "expiredBn": expiredBn,
+		}).Debug("Pruning expired bn partition...")
+
+		if _, err := bnps.shrinkPartition(entity, tabler, int(i)); err != nil {
+			return prunedPartitions, errors.WithMessagef(err, "failed to shrink partition %d", i)
+		}
+
+		prunedPartitions = append(prunedPartitions, partition)
+	}
+
+	return prunedPartitions, nil
+}
@@ -2,11 +2,17 @@ package mysql
 
 import (
 	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Conflux-Chain/confura/store/encryption"
 	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -28,13 +34,21 @@ const (
 	// pre-defined node route group config key prefix
 	NodeRouteGroupConfKeyPrefix   = "noderoute.group."
 	nodeRouteGroupSqlMatchPattern = NodeRouteGroupConfKeyPrefix + "%"
+
+	// pre-defined node route rules config key
+	NodeRouteRulesConfKey = "noderoute.rules"
+
+	// pre-defined usage quota config key prefix
+	UsageQuotaConfKeyPrefix   = "metering.quota."
+	usageQuotaSqlMatchPattern = UsageQuotaConfKeyPrefix + "%"
 )
 
 // configuration tables
 type conf struct {
 	ID        uint32
-	Name      string `gorm:"unique;size:128;not null"` // config name
-	Value     string `gorm:"size:16250;not null"`      // config value
+	Env       string `gorm:"size:32;not null;default:'';uniqueIndex:idx_configs_env_name,priority:1"` // environment
+	Name      string `gorm:"size:128;not null;uniqueIndex:idx_configs_env_name,priority:2"`           // config name
+	Value     string `gorm:"size:16250;not null"`                                                     // config value
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -45,18 +59,66 @@ func (conf) TableName() string {
 
 type confStore struct {
 	*baseStore
+
+	// environment (eg., `prod` or `staging`) to scope configs, so that one database could serve
+	// multiple gateway environments
+	env string
+	// cipher to encrypt sensitive config values at rest, nil if encryption disabled
+	cipher *encryption.Cipher
+
+	// cache of decoded rate limit, access control and usage quota configs
+	cache *configCache
 }
 
-func newConfStore(db *gorm.DB) *confStore {
+func newConfStore(db *gorm.DB, env string, cipher *encryption.Cipher, cacheTTL time.Duration) *confStore {
 	return &confStore{
 		baseStore: newBaseStore(db),
+		env:       env,
+		cipher:    cipher,
+		cache:     newConfigCache(cacheTTL),
+	}
+}
+
+// configEnv returns the environment of config, which is empty for internal configs shared by all
+// environments, eg., reorg version of the chain data synced into database.
+func (cs *confStore) configEnv(confName string) string {
+	if confName == MysqlConfKeyReorgVersion {
+		return ""
+	}
+
+	return cs.env
+}
+
+// envScope scopes config queries to the environment along with the shared internal configs.
+func (cs *confStore) envScope(db *gorm.DB) *gorm.DB {
+	return db.Where("env = ? OR (env = '' AND name = ?)", cs.env, MysqlConfKeyReorgVersion)
+}
+
+// InvalidateConfigCache expires the cached configs so that they will be reloaded from db.
+func (cs *confStore) InvalidateConfigCache() {
+	cs.cache.invalidate()
+}
+
+// queryConfigs returns a function to query configs whose name matches the pattern.
+func (cs *confStore) queryConfigs(pattern string) func() ([]conf, error) {
+	return func() ([]conf, error) {
+		var cfgs []conf
+		if err := cs.db.Scopes(cs.envScope).Where("name LIKE ?", pattern).Find(&cfgs).Error; err != nil {
+			return nil, err
+		}
+
+		return cfgs, cs.decryptConfigs(cfgs)
 	}
 }
 
 func (cs *confStore) LoadConfig(confNames ...string) (map[string]interface{}, error) {
 	var confs []conf
 
-	if err := cs.db.Where("name IN ?", confNames).Find(&confs).Error; err != nil {
+	if err := cs.db.Scopes(cs.envScope).Where("name IN ?", confNames).Find(&confs).Error; err != nil {
+		return nil, err
+	}
+
+	if err := cs.decryptConfigs(confs); err != nil {
 		return nil, err
 	}
 
@@ -68,19 +130,318 @@ func (cs *confStore) LoadConfig(confNames ...string) (map[string]interface{}, er
 	return res, nil
 }
 
+// ListConfigs lists the raw values of all configs whose names have the specified prefix.
+func (cs *confStore) ListConfigs(namePrefix string) (map[string]string, error) {
+	var confs []conf
+
+	if err := cs.db.Scopes(cs.envScope).Where("name LIKE ?", namePrefix+"%").Find(&confs).Error; err != nil {
+		return nil, err
+	}
+
+	if err := cs.decryptConfigs(confs); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(confs))
+	for _, c := range confs {
+		// `_` in prefix matches any single character
+		if strings.HasPrefix(c.Name, namePrefix) {
+			res[c.Name] = c.Value
+		}
+	}
+
+	return res, nil
+}
+
 func (cs *confStore) StoreConfig(confName string, confVal interface{}) error {
-	return cs.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "name"}},
-		DoUpdates: clause.Assignments(map[string]interface{}{"value": confVal}),
+	return cs.StoreConfigBy(DefaultConfigOperator, confName, confVal)
+}
+
+// StoreConfigBy creates or updates config on behalf of the operator, with the change audited.
+func (cs *confStore) StoreConfigBy(operator, confName string, confVal interface{}) error {
+	newVal := confVal.(string)
+
+	if !isConfigAuditable(confName) {
+		return cs.upsertConfig(cs.db, confName, newVal)
+	}
+
+	// invalidate the cached configs once changes committed
+	defer cs.cache.invalidate()
+
+	return cs.db.Transaction(func(dbTx *gorm.DB) error {
+		oldVal, err := cs.loadConfigValue(dbTx, confName)
+		if err != nil {
+			return err
+		}
+
+		if err := cs.upsertConfig(dbTx, confName, newVal); err != nil {
+			return err
+		}
+
+		return cs.addConfigAudit(dbTx, &ConfigAudit{
+			ConfName: confName,
+			Action:   ConfigAuditActionStore,
+			Operator: operator,
+			OldValue: oldVal,
+			NewValue: &newVal,
+		})
+	})
+}
+
+// StoreConfigsBy creates or updates configs (config name => value) on behalf of the operator in
+// a single transaction, so that either all or none of the changes are applied.
+func (cs *confStore) StoreConfigsBy(operator string, confs map[string]string) error {
+	changes := make([]*ConfigChange, 0, len(confs))
+	for confName, newVal := range confs {
+		newVal := newVal
+		changes = append(changes, &ConfigChange{Name: confName, Value: &newVal})
+	}
+
+	return cs.ApplyConfigsBy(operator, changes)
+}
+
+// ApplyConfigsBy applies the config changes on behalf of the operator in a single transaction,
+// so that either all or none of the changes are applied, eg., conflicted with any version.
+func (cs *confStore) ApplyConfigsBy(operator string, changes []*ConfigChange) error {
+	defer cs.cache.invalidate()
+
+	return cs.db.Transaction(func(dbTx *gorm.DB) error {
+		for _, change := range changes {
+			if err := cs.applyConfigChange(dbTx, operator, change); err != nil {
+				return errors.WithMessagef(err, "failed to apply config %v", change.Name)
+			}
+		}
+
+		return nil
+	})
+}
+
+// ConfigVersion returns the version of config value for optimistic concurrency control, which
+// is the md5 checksum in hex.
+func ConfigVersion(confVal string) string {
+	checksum := md5.Sum([]byte(confVal))
+	return hex.EncodeToString(checksum[:])
+}
+
+// configVersion returns the version of config value, or empty if config not existed.
+func configVersion(confVal *string) string {
+	if confVal == nil {
+		return ""
+	}
+
+	return ConfigVersion(*confVal)
+}
+
+// StoreConfigIfMatch creates or updates config on behalf of the operator only if the config version
+// matches, with the change audited.
+func (cs *confStore) StoreConfigIfMatch(operator, confName string, confVal interface{}, version string) error {
+	newVal := confVal.(string)
+
+	defer cs.cache.invalidate()
+
+	return cs.db.Transaction(func(dbTx *gorm.DB) error {
+		return cs.applyConfigChange(dbTx, operator, &ConfigChange{
+			Name: confName, Value: &newVal, Version: &version,
+		})
+	})
+}
+
+// applyConfigChange applies the config change within the db transaction, with the change audited.
+// valueEqualCond returns the condition to compare config value byte by byte, since values that
+// differ only in letter case or trailing spaces are equal under the default collation of MySQL,
+// which would let compare and swap succeed on stale value. SQLite compares binary by default.
+func valueEqualCond(db *gorm.DB) string {
+	if db.Dialector.Name() == DriverSqlite {
+		return "value = ?"
+	}
+
+	return "CAST(value AS BINARY) = ?"
+}
+
+func (cs *confStore) applyConfigChange(dbTx *gorm.DB, operator string, change *ConfigChange) error {
+	confName, newVal := change.Name, change.Value
+
+	old, err := cs.loadConfig(dbTx, confName)
+	if err != nil {
+		return err
+	}
+
+	var oldVal *string
+	if old != nil {
+		// stored value kept to compare and swap, which might be encrypted
+		stored := *old
+		if err := cs.decryptConfig(old); err != nil {
+			return err
+		}
+
+		oldVal, old = &old.Value, &stored
+	}
+
+	if change.Version != nil && configVersion(oldVal) != *change.Version {
+		return errConfigConflict(confName)
+	}
+
+	action := ConfigAuditActionStore
+
+	switch {
+	case newVal == nil && oldVal == nil: // nothing to delete
+		return nil
+	case newVal == nil:
+		// compare and delete in case of updated concurrently
+		res := dbTx.Delete(&conf{}, "id = ? AND "+valueEqualCond(dbTx), old.ID, old.Value)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return errConfigConflict(confName)
+		}
+
+		action = ConfigAuditActionDelete
+	case change.Version == nil:
+		if err := cs.upsertConfig(dbTx, confName, *newVal); err != nil {
+			return err
+		}
+	case oldVal == nil:
+		if err := cs.createConfig(dbTx, confName, *newVal); err != nil {
+			// created concurrently
+			if v, _ := cs.loadConfig(dbTx, confName); v != nil {
+				return errConfigConflict(confName)
+			}
+
+			return err
+		}
+	case *oldVal != *newVal:
+		stored, err := cs.cipher.Encrypt(confName, *newVal)
+		if err != nil {
+			return err
+		}
+
+		// compare and swap in case of updated concurrently
+		res := dbTx.Model(&conf{}).
+			Where("id = ? AND "+valueEqualCond(dbTx), old.ID, old.Value).
+			Update("value", stored)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return errConfigConflict(confName)
+		}
+	default: // unchanged
+		return nil
+	}
+
+	if !isConfigAuditable(confName) {
+		return nil
+	}
+
+	return cs.addConfigAudit(dbTx, &ConfigAudit{
+		ConfName: confName,
+		Action:   action,
+		Operator: operator,
+		OldValue: oldVal,
+		NewValue: newVal,
+	})
+}
+
+func (cs *confStore) DeleteConfig(confName string) (bool, error) {
+	return cs.DeleteConfigBy(DefaultConfigOperator, confName)
+}
+
+// DeleteConfigBy deletes config on behalf of the operator, with the change audited.
+func (cs *confStore) DeleteConfigBy(operator, confName string) (removed bool, err error) {
+	if !isConfigAuditable(confName) {
+		res := cs.db.Delete(&conf{}, "env = ? AND name = ?", cs.configEnv(confName), confName)
+		return res.RowsAffected > 0, res.Error
+	}
+
+	defer cs.cache.invalidate()
+
+	err = cs.db.Transaction(func(dbTx *gorm.DB) error {
+		oldVal, err := cs.loadConfigValue(dbTx, confName)
+		if err != nil || oldVal == nil {
+			return err
+		}
+
+		res := dbTx.Delete(&conf{}, "env = ? AND name = ?", cs.env, confName)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if removed = res.RowsAffected > 0; !removed {
+			return nil
+		}
+
+		return cs.addConfigAudit(dbTx, &ConfigAudit{
+			ConfName: confName,
+			Action:   ConfigAuditActionDelete,
+			Operator: operator,
+			OldValue: oldVal,
+		})
+	})
+
+	return removed, err
+}
+
+func (cs *confStore) upsertConfig(db *gorm.DB, confName, confVal string) error {
+	stored, err := cs.cipher.Encrypt(confName, confVal)
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "env"}, {Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"value": stored}),
 	}).Create(&conf{
+		Env:   cs.configEnv(confName),
 		Name:  confName,
-		Value: confVal.(string),
+		Value: stored,
 	}).Error
 }
 
-func (cs *confStore) DeleteConfig(confName string) (bool, error) {
-	res := cs.db.Delete(&conf{}, "name = ?", confName)
-	return res.RowsAffected > 0, res.Error
+// createConfig creates config, which fails if already existed.
+func (cs *confStore) createConfig(db *gorm.DB, confName, confVal string) error {
+	stored, err := cs.cipher.Encrypt(confName, confVal)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&conf{Env: cs.configEnv(confName), Name: confName, Value: stored}).Error
+}
+
+// loadConfig loads the stored config within the db transaction, or nil if not existed.
+func (cs *confStore) loadConfig(dbTx *gorm.DB, confName string) (*conf, error) {
+	var cfgs []conf
+	err := dbTx.Where("env = ? AND name = ?", cs.configEnv(confName), confName).Limit(1).Find(&cfgs).Error
+	if err != nil || len(cfgs) == 0 {
+		return nil, err
+	}
+
+	return &cfgs[0], nil
+}
+
+// loadConfigValue loads the decrypted config value within the db transaction, or nil if not existed.
+func (cs *confStore) loadConfigValue(dbTx *gorm.DB, confName string) (*string, error) {
+	cfg, err := cs.loadConfig(dbTx, confName)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+
+	if err := cs.decryptConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg.Value, nil
+}
+
+// wrapNotFound converts record not found error to `ErrConfigNotFound` with config name.
+func (cs *confStore) wrapNotFound(err error, confName string) error {
+	if cs.IsRecordNotFound(err) {
+		return errConfigNotFound(confName)
+	}
+
+	return err
 }
 
 // reorg config
@@ -113,8 +474,14 @@ func (cs *confStore) createOrUpdateReorgVersion(dbTx *gorm.DB) error {
 
 // access control config
 func (cs *confStore) LoadAclAllowList(name string) (*acl.AllowList, error) {
+	confName := AclAllowListConfKeyPrefix + name
+
 	var cfg conf
-	if err := cs.db.Where("name = ?", AclAllowListConfKeyPrefix+name).First(&cfg).Error; err != nil {
+	if err := cs.db.Scopes(cs.envScope).Where("name = ?", confName).First(&cfg).Error; err != nil {
+		return nil, cs.wrapNotFound(err, confName)
+	}
+
+	if err := cs.decryptConfig(&cfg); err != nil {
 		return nil, err
 	}
 
@@ -123,7 +490,11 @@ func (cs *confStore) LoadAclAllowList(name string) (*acl.AllowList, error) {
 
 func (cs *confStore) LoadAclAllowListById(aclID uint32) (*acl.AllowList, error) {
 	cfg := conf{ID: aclID}
-	if err := cs.db.First(&cfg).Error; err != nil {
+	if err := cs.db.Scopes(cs.envScope).First(&cfg).Error; err != nil {
+		return nil, cs.wrapNotFound(err, fmt.Sprintf("allowlist #%v", aclID))
+	}
+
+	if err := cs.decryptConfig(&cfg); err != nil {
 		return nil, err
 	}
 
@@ -131,28 +502,24 @@ func (cs *confStore) LoadAclAllowListById(aclID uint32) (*acl.AllowList, error)
 }
 
 func (cs *confStore) LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[uint32][md5.Size]byte, error) {
-	var cfgs []conf
-	if err := cs.db.Where("name LIKE ?", aclAllowListSqlMatchPattern).Find(&cfgs).Error; err != nil {
+	values, checksums, err := cs.cache.load(
+		aclAllowListSqlMatchPattern,
+		cs.queryConfigs(aclAllowListSqlMatchPattern),
+		func(cfg conf) (interface{}, error) {
+			al, err := cs.decodeAclAllowLists(cfg)
+			if err != nil {
+				logrus.WithField("cfg", cfg).WithError(err).Warn("Invalid access control allowlist config")
+			}
+			return al, err
+		},
+	)
+	if err != nil || len(values) == 0 {
 		return nil, nil, err
 	}
 
-	if len(cfgs) == 0 {
-		return nil, nil, nil
-	}
-
-	allowLists := make(map[uint32]*acl.AllowList)
-	checksums := make(map[uint32][md5.Size]byte)
-
-	// decode allow lists from access control configs
-	for _, v := range cfgs {
-		al, err := cs.decodeAclAllowLists(v)
-		if err != nil {
-			logrus.WithField("cfg", v).WithError(err).Warn("Invalid access control allowlist config")
-			continue
-		}
-
-		allowLists[v.ID] = al
-		checksums[v.ID] = md5.Sum([]byte(v.Value))
+	allowLists := make(map[uint32]*acl.AllowList, len(values))
+	for id, v := range values {
+		allowLists[id] = v.(*acl.AllowList)
 	}
 
 	return allowLists, checksums, nil
@@ -160,16 +527,20 @@ func (cs *confStore) LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[u
 
 func (cs *confStore) decodeAclAllowLists(cfg conf) (*acl.AllowList, error) {
 	// eg., acl.allowlists.fluent
+	if !strings.HasPrefix(cfg.Name, AclAllowListConfKeyPrefix) {
+		return nil, newDecodeError(cfg.Name, errors.New("not an allowlist config"))
+	}
+
 	name := cfg.Name[len(AclAllowListConfKeyPrefix):]
 	if len(name) == 0 {
-		return nil, errors.New("allowlist name is too short")
+		return nil, newDecodeError(cfg.Name, errors.New("allowlist name is too short"))
 	}
 
 	data := []byte(cfg.Value)
 	al := acl.NewAllowList(cfg.ID, name)
 
 	if err := json.Unmarshal(data, al); err != nil {
-		return nil, err
+		return nil, newDecodeError(cfg.Name, err)
 	}
 
 	return al, nil
@@ -199,8 +570,14 @@ func (cs *confStore) LoadRateLimitConfigs() (*rate.Config, error) {
 }
 
 func (cs *confStore) LoadRateLimitStrategy(name string) (*rate.Strategy, error) {
+	confName := RateLimitStrategyConfKeyPrefix + name
+
 	var cfg conf
-	if err := cs.db.Where("name = ?", RateLimitStrategyConfKeyPrefix+name).First(&cfg).Error; err != nil {
+	if err := cs.db.Scopes(cs.envScope).Where("name = ?", confName).First(&cfg).Error; err != nil {
+		return nil, cs.wrapNotFound(err, confName)
+	}
+
+	if err := cs.decryptConfig(&cfg); err != nil {
 		return nil, err
 	}
 
@@ -208,28 +585,24 @@ func (cs *confStore) LoadRateLimitStrategy(name string) (*rate.Strategy, error)
 }
 
 func (cs *confStore) LoadRateLimitStrategyConfigs() (map[uint32]*rate.Strategy, map[uint32][md5.Size]byte, error) {
-	var cfgs []conf
-	if err := cs.db.Where("name LIKE ?", rateLimitStrategySqlMatchPattern).Find(&cfgs).Error; err != nil {
+	values, checksums, err := cs.cache.load(
+		rateLimitStrategySqlMatchPattern,
+		cs.queryConfigs(rateLimitStrategySqlMatchPattern),
+		func(cfg conf) (interface{}, error) {
+			strategy, err := cs.decodeRateLimitStrategy(cfg)
+			if err != nil {
+				logrus.WithField("cfg", cfg).WithError(err).Warn("Invalid rate limit strategy config")
+			}
+			return strategy, err
+		},
+	)
+	if err != nil || len(values) == 0 {
 		return nil, nil, err
 	}
 
-	if len(cfgs) == 0 {
-		return nil, nil, nil
-	}
-
-	strategies := make(map[uint32]*rate.Strategy)
-	checksums := make(map[uint32][md5.Size]byte)
-
-	// decode ratelimit strategy from config item
-	for _, v := range cfgs {
-		strategy, err := cs.decodeRateLimitStrategy(v)
-		if err != nil {
-			logrus.WithField("cfg", v).WithError(err).Warn("Invalid rate limit strategy config")
-			continue
-		}
-
-		strategies[v.ID] = strategy
-		checksums[v.ID] = md5.Sum([]byte(v.Value))
+	strategies := make(map[uint32]*rate.Strategy, len(values))
+	for id, v := range values {
+		strategies[id] = v.(*rate.Strategy)
 	}
 
 	return strategies, checksums, nil
@@ -239,40 +612,678 @@ func (cs *confStore) decodeRateLimitStrategy(cfg conf) (*rate.Strategy, error) {
 	// eg., ratelimit.strategy.whitelist
 	name := cfg.Name[len(RateLimitStrategyConfKeyPrefix):]
 	if len(name) == 0 {
-		return nil, errors.New("strategy name is too short")
+		return nil, newDecodeError(cfg.Name, errors.New("strategy name is too short"))
 	}
 
 	data := []byte(cfg.Value)
 	stg := rate.NewStrategy(cfg.ID, name)
 
 	if err := json.Unmarshal(data, stg); err != nil {
-		return nil, err
+		return nil, newDecodeError(cfg.Name, err)
 	}
 
 	return stg, nil
 }
 
+// usage quota config
+
+func (cs *confStore) LoadUsageQuotaConfigs() (map[uint32]*metering.Quota, error) {
+	values, _, err := cs.cache.load(
+		usageQuotaSqlMatchPattern,
+		cs.queryConfigs(usageQuotaSqlMatchPattern),
+		func(cfg conf) (interface{}, error) {
+			quota, err := cs.decodeUsageQuota(cfg)
+			if err != nil {
+				logrus.WithField("cfg", cfg).WithError(err).Warn("Invalid usage quota config")
+			}
+			return quota, err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := make(map[uint32]*metering.Quota, len(values))
+	for id, v := range values {
+		quotas[id] = v.(*metering.Quota)
+	}
+
+	return quotas, nil
+}
+
+func (cs *confStore) decodeUsageQuota(cfg conf) (*metering.Quota, error) {
+	// eg., metering.quota.default
+	name := cfg.Name[len(UsageQuotaConfKeyPrefix):]
+	if len(name) == 0 {
+		return nil, newDecodeError(cfg.Name, errors.New("quota name is too short"))
+	}
+
+	quota, err := metering.ParseQuota(cfg.ID, name, cfg.Value)
+	if err != nil {
+		return nil, newDecodeError(cfg.Name, err)
+	}
+
+	return quota, nil
+}
+
+// config decoders shared by other config backends
+
+// DecodeAclAllowList decodes access control allowlist from the raw config item.
+func DecodeAclAllowList(id uint32, confName, confVal string) (*acl.AllowList, error) {
+	return (&confStore{}).decodeAclAllowLists(conf{ID: id, Name: confName, Value: confVal})
+}
+
+// DecodeRateLimitStrategy decodes rate limit strategy from the raw config item.
+func DecodeRateLimitStrategy(id uint32, confName, confVal string) (*rate.Strategy, error) {
+	return (&confStore{}).decodeRateLimitStrategy(conf{ID: id, Name: confName, Value: confVal})
+}
+
+// DecodeNodeRouteGroup decodes node route group from the raw config item.
+func DecodeNodeRouteGroup(id uint32, confName, confVal string) (*NodeRouteGroup, error) {
+	return (&confStore{}).decodeNodeRouteGroup(conf{ID: id, Name: confName, Value: confVal})
+}
+
+// DecodeUsageQuota decodes usage quota from the raw config item.
+func DecodeUsageQuota(id uint32, confName, confVal string) (*metering.Quota, error) {
+	return (&confStore{}).decodeUsageQuota(conf{ID: id, Name: confName, Value: confVal})
+}
+
+// ValidateConfig validates the raw config item of network space ("cfx" or "eth") as per the
+// config name prefix, while configs of unknown prefix are always valid.
+func ValidateConfig(network, confName, confVal string) error {
+	switch {
+	case strings.HasPrefix(confName, AclAllowListConfKeyPrefix):
+		al, err := DecodeAclAllowList(0, confName, confVal)
+		if err != nil {
+			return err
+		}
+
+		if err := al.Validate(network); err != nil {
+			return newDecodeError(confName, err)
+		}
+	case strings.HasPrefix(confName, RateLimitStrategyConfKeyPrefix):
+		_, err := DecodeRateLimitStrategy(0, confName, confVal)
+		return err
+	case strings.HasPrefix(confName, NodeRouteGroupConfKeyPrefix):
+		grp, err := DecodeNodeRouteGroup(0, confName, confVal)
+		if err != nil {
+			return err
+		}
+
+		for url, weight := range grp.Weights {
+			if weight < 1 || weight > MaxNodeRouteWeight {
+				return newDecodeError(confName, errors.Errorf(
+					"weight of node %v must be between 1 and %v", url, MaxNodeRouteWeight,
+				))
+			}
+		}
+
+		if grp.Canary != nil {
+			if err := grp.validateCanary(grp.Canary); err != nil {
+				return newDecodeError(confName, err)
+			}
+		}
+
+		if err := grp.validateShadow(grp.Shadow); err != nil {
+			return newDecodeError(confName, err)
+		}
+
+		if err := grp.validateMaintenance(grp.Maintenance); err != nil {
+			return newDecodeError(confName, err)
+		}
+
+		for url, tags := range grp.Tags {
+			if err := validateNodeTags(tags); err != nil {
+				return newDecodeError(confName, errors.WithMessagef(err, "invalid tags of node %v", url))
+			}
+		}
+
+		if grp.MaxInflight < 0 {
+			return newDecodeError(confName, errors.New("max in-flight requests must not be negative"))
+		}
+	case strings.HasPrefix(confName, UsageQuotaConfKeyPrefix):
+		_, err := DecodeUsageQuota(0, confName, confVal)
+		return err
+	case confName == NodeRouteRulesConfKey:
+		_, err := DecodeNodeRouteRules(confVal)
+		return err
+	case confName == MysqlConfKeyReorgVersion:
+		if _, err := strconv.Atoi(confVal); err != nil {
+			return newDecodeError(confName, err)
+		}
+	}
+
+	return nil
+}
+
 // node route config
 
+const (
+	// DefaultNodeRouteWeight default weight of node in route group
+	DefaultNodeRouteWeight = 1
+	// MaxNodeRouteWeight max weight of node in route group, which is bounded since node is
+	// replicated in hash ring as per weight.
+	MaxNodeRouteWeight = 10
+)
+
+// Capability tags of node in route group.
+const (
+	// NodeTagArchive node keeps all the historical state
+	NodeTagArchive = "archive"
+	// NodeTagPruned node prunes the state of blocks beyond the recent ones
+	NodeTagPruned = "pruned"
+	// NodeTagTrace node enables trace and debug APIs
+	NodeTagTrace = "trace"
+)
+
 type NodeRouteGroup struct {
-	ID    uint32   `json:"-"`     // group ID
-	Name  string   `json:"-"`     // group name
-	Nodes []string `json:"nodes"` // node urls
+	ID      uint32           `json:"-"`                 // group ID
+	Name    string           `json:"-"`                 // group name
+	Nodes   []string         `json:"nodes"`             // node urls
+	Weights map[string]int   `json:"weights,omitempty"` // node url => weight if not default
+	Drained []string         `json:"drained,omitempty"` // node urls that accept no new traffic
+	Canary  *NodeRouteCanary `json:"canary,omitempty"`  // canary nodes to split traffic
+
+	// shadow node urls not in live rotation yet, which receive a sampled fraction of the read
+	// traffic mirrored with responses discarded, eg., to validate a new client build
+	Shadow []string `json:"shadow,omitempty"`
+
+	// drain windows of drained nodes, within which websocket subscriptions are migrated to
+	// other nodes gradually: node url => drain
+	Drains map[string]*NodeDrain `json:"drains,omitempty"`
+
+	// daily maintenance windows, within which nodes are drained automatically
+	Maintenance []*NodeMaintenanceWindow `json:"maintenance,omitempty"`
+
+	// capability tags of nodes, eg., to route historical state requests to archive nodes only:
+	// node url => tags
+	Tags map[string][]string `json:"tags,omitempty"`
+
+	// max in-flight requests per node, beyond which requests are rerouted to other nodes of
+	// the group or queued briefly, 0 means unlimited
+	MaxInflight int `json:"maxInflight,omitempty"`
+}
+
+// NodeDrain drain of node, which stops new traffic at once and then closes the websocket
+// subscriptions gradually within the window, so that clients reconnect to other nodes.
+type NodeDrain struct {
+	StartedAt  int64 `json:"startedAt"`  // unix timestamp in seconds
+	WindowSecs int64 `json:"windowSecs"` // seconds to migrate subscriptions
+}
+
+// Deadline returns the time by which all subscriptions should be migrated.
+func (d *NodeDrain) Deadline() time.Time {
+	return time.Unix(d.StartedAt+d.WindowSecs, 0)
+}
+
+// NodeDrainProgress drain progress of node in route group, which is reported by RPC gateway.
+type NodeDrainProgress struct {
+	Group    string    `json:"group"`
+	Url      string    `json:"url"`
+	Deadline time.Time `json:"deadline"`
+	// number of in-flight requests, which are completed as usual
+	InflightRequests int `json:"inflightRequests"`
+	// number of websocket subscriptions not migrated yet
+	Subscriptions int `json:"subscriptions"`
+	// number of websocket subscriptions migrated
+	Migrated int `json:"migrated"`
+	// whether all in-flight requests completed and subscriptions migrated
+	Completed bool `json:"completed"`
+}
+
+// NodeMaintenanceWindow daily maintenance window of nodes in route group (eg., nightly node
+// restarts), during which the nodes are drained automatically and re-enabled once the window
+// ends, without any change persisted.
+type NodeMaintenanceWindow struct {
+	Nodes        []string `json:"nodes"`        // node urls to maintain
+	Start        string   `json:"start"`        // start time of day in UTC, eg., "02:30"
+	DurationSecs int64    `json:"durationSecs"` // seconds of maintenance
+	// seconds to migrate websocket subscriptions since the window starts, which should not
+	// exceed the duration so that subscriptions are migrated before nodes restarted
+	DrainWindowSecs int64 `json:"drainWindowSecs,omitempty"`
+}
+
+// startOfDay returns the start time as offset of the day.
+func (w *NodeMaintenanceWindow) startOfDay() (time.Duration, error) {
+	t, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return 0, errors.Errorf("invalid start time %v of maintenance window, expected HH:MM", w.Start)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// activeSince returns the start time if window is active at the specified time, which might
+// be of the previous day if window crosses midnight.
+func (w *NodeMaintenanceWindow) activeSince(now time.Time) (time.Time, bool) {
+	offset, err := w.startOfDay()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	duration := time.Duration(w.DurationSecs) * time.Second
+
+	for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		if start := day.Add(offset); !now.Before(start) && now.Before(start.Add(duration)) {
+			return start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (w *NodeMaintenanceWindow) hasNode(url string) bool {
+	for _, v := range w.Nodes {
+		if v == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NodeRouteCanary canary nodes of route group (eg., a new client build), which take the
+// specified percentage of traffic from the group nodes.
+type NodeRouteCanary struct {
+	Nodes   []string `json:"nodes"`   // canary node urls
+	Percent int      `json:"percent"` // percentage of traffic in range [0, 100]
+}
+
+// SetCanary sets canary nodes to take the percentage of traffic, or removes the canary nodes
+// if empty.
+func (grp *NodeRouteGroup) SetCanary(nodes []string, percent int) error {
+	if len(nodes) == 0 {
+		grp.Canary = nil
+		return nil
+	}
+
+	canary := &NodeRouteCanary{Nodes: nodes, Percent: percent}
+	if err := grp.validateCanary(canary); err != nil {
+		return err
+	}
+
+	grp.Canary = canary
+	return nil
+}
+
+// IsCanary checks if the node of specified url is a canary node of the route group.
+func (grp *NodeRouteGroup) IsCanary(url string) bool {
+	if grp.Canary == nil {
+		return false
+	}
+
+	for _, v := range grp.Canary.Nodes {
+		if v == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (grp *NodeRouteGroup) validateCanary(canary *NodeRouteCanary) error {
+	if canary.Percent < 0 || canary.Percent > 100 {
+		return errors.New("canary percent must be between 0 and 100")
+	}
+
+	for _, url := range canary.Nodes {
+		if grp.HasNode(url) || grp.IsShadow(url) {
+			return errors.Errorf("canary node %v already exists in route group", url)
+		}
+	}
+
+	return nil
+}
+
+// SetShadow sets shadow nodes to mirror read traffic, or removes the shadow nodes if empty.
+func (grp *NodeRouteGroup) SetShadow(nodes []string) error {
+	if err := grp.validateShadow(nodes); err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		nodes = nil
+	}
+
+	grp.Shadow = nodes
+	return nil
+}
+
+// IsShadow checks if the node of specified url is a shadow node of the route group.
+func (grp *NodeRouteGroup) IsShadow(url string) bool {
+	for _, v := range grp.Shadow {
+		if v == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (grp *NodeRouteGroup) validateShadow(nodes []string) error {
+	for _, url := range nodes {
+		if grp.HasNode(url) || grp.IsCanary(url) {
+			return errors.Errorf("shadow node %v already exists in route group", url)
+		}
+	}
+
+	return nil
+}
+
+// HasNode checks if the node of specified url is in the route group.
+func (grp *NodeRouteGroup) HasNode(url string) bool {
+	for _, v := range grp.Nodes {
+		if v == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddNode adds node of specified url into the route group, and returns false if already added.
+func (grp *NodeRouteGroup) AddNode(url string) bool {
+	if grp.HasNode(url) {
+		return false
+	}
+
+	grp.Nodes = append(grp.Nodes, url)
+	return true
+}
+
+// RemoveNode removes node of specified url along with its weight, drain state and tags from the
+// route group, and returns false if not found.
+func (grp *NodeRouteGroup) RemoveNode(url string) bool {
+	if !grp.HasNode(url) {
+		return false
+	}
+
+	grp.Nodes = removeString(grp.Nodes, url)
+	grp.Drained = removeString(grp.Drained, url)
+	delete(grp.Weights, url)
+	delete(grp.Drains, url)
+	delete(grp.Tags, url)
+
+	var windows []*NodeMaintenanceWindow
+	for _, w := range grp.Maintenance {
+		if w.Nodes = removeString(w.Nodes, url); len(w.Nodes) > 0 {
+			windows = append(windows, w)
+		}
+	}
+
+	grp.Maintenance = windows
+
+	return true
+}
+
+// Weight returns weight of node of specified url.
+func (grp *NodeRouteGroup) Weight(url string) int {
+	if w, ok := grp.Weights[url]; ok && w > 0 {
+		return w
+	}
+
+	return DefaultNodeRouteWeight
+}
+
+// SetWeight sets weight of node of specified url.
+func (grp *NodeRouteGroup) SetWeight(url string, weight int) error {
+	if weight < 1 || weight > MaxNodeRouteWeight {
+		return errors.Errorf("weight must be between 1 and %v", MaxNodeRouteWeight)
+	}
+
+	if weight == DefaultNodeRouteWeight {
+		delete(grp.Weights, url)
+		return nil
+	}
+
+	if grp.Weights == nil {
+		grp.Weights = make(map[string]int)
+	}
+
+	grp.Weights[url] = weight
+	return nil
+}
+
+// IsDrained checks if node of specified url is drained.
+func (grp *NodeRouteGroup) IsDrained(url string) bool {
+	for _, v := range grp.Drained {
+		if v == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetDrained marks or unmarks node of specified url as drained, and the drain window is removed
+// once undrained.
+func (grp *NodeRouteGroup) SetDrained(url string, drained bool) {
+	grp.Drained = removeString(grp.Drained, url)
+
+	if drained {
+		grp.Drained = append(grp.Drained, url)
+	} else {
+		delete(grp.Drains, url)
+	}
+}
+
+// StartDrain marks node of specified url as drained, and migrates its websocket subscriptions
+// to other nodes within the window.
+func (grp *NodeRouteGroup) StartDrain(url string, window time.Duration) {
+	grp.SetDrained(url, true)
+
+	if grp.Drains == nil {
+		grp.Drains = make(map[string]*NodeDrain)
+	}
+
+	grp.Drains[url] = &NodeDrain{StartedAt: time.Now().Unix(), WindowSecs: int64(window.Seconds())}
+}
+
+// SetMaintenance sets daily maintenance windows of the route group, or removes them if empty.
+func (grp *NodeRouteGroup) SetMaintenance(windows []*NodeMaintenanceWindow) error {
+	if err := grp.validateMaintenance(windows); err != nil {
+		return err
+	}
+
+	if len(windows) == 0 {
+		windows = nil
+	}
+
+	grp.Maintenance = windows
+	return nil
+}
+
+func (grp *NodeRouteGroup) validateMaintenance(windows []*NodeMaintenanceWindow) error {
+	for _, w := range windows {
+		if _, err := w.startOfDay(); err != nil {
+			return err
+		}
+
+		if w.DurationSecs <= 0 || w.DurationSecs > 86400 {
+			return errors.New("duration of maintenance window must be between 1 second and 24 hours")
+		}
+
+		if w.DrainWindowSecs < 0 || w.DrainWindowSecs > w.DurationSecs {
+			return errors.New("drain window must not be negative or exceed the maintenance duration")
+		}
+
+		if len(w.Nodes) == 0 {
+			return errors.New("nodes of maintenance window must not be empty")
+		}
+
+		for _, url := range w.Nodes {
+			if !grp.HasNode(url) {
+				return errors.Errorf("maintenance node %v not found in route group", url)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MaintenanceDrain returns the drain of node of specified url if under maintenance at the
+// specified time, which starts along with the maintenance window.
+func (grp *NodeRouteGroup) MaintenanceDrain(url string, now time.Time) (*NodeDrain, bool) {
+	for _, w := range grp.Maintenance {
+		if !w.hasNode(url) {
+			continue
+		}
+
+		if start, ok := w.activeSince(now); ok {
+			return &NodeDrain{StartedAt: start.Unix(), WindowSecs: w.DrainWindowSecs}, true
+		}
+	}
+
+	return nil, false
+}
+
+// DrainedAt returns urls of nodes drained at the specified time, including the nodes drained
+// manually and the nodes under maintenance.
+func (grp *NodeRouteGroup) DrainedAt(now time.Time) []string {
+	drained := append([]string(nil), grp.Drained...)
+
+	for _, url := range grp.Nodes {
+		if _, ok := grp.MaintenanceDrain(url, now); ok && !grp.IsDrained(url) {
+			drained = append(drained, url)
+		}
+	}
+
+	return drained
+}
+
+// DrainsAt returns drain windows of nodes drained at the specified time, where manual drain
+// takes precedence over maintenance: node url => drain
+func (grp *NodeRouteGroup) DrainsAt(now time.Time) map[string]*NodeDrain {
+	drains := make(map[string]*NodeDrain)
+
+	for _, url := range grp.Nodes {
+		if grp.IsDrained(url) {
+			if drain, ok := grp.Drains[url]; ok {
+				drains[url] = drain
+			}
+		} else if drain, ok := grp.MaintenanceDrain(url, now); ok {
+			drains[url] = drain
+		}
+	}
+
+	return drains
+}
+
+// SetTags sets capability tags of node of specified url, or removes the tags if empty.
+func (grp *NodeRouteGroup) SetTags(url string, tags []string) error {
+	if err := validateNodeTags(tags); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		delete(grp.Tags, url)
+		return nil
+	}
+
+	if grp.Tags == nil {
+		grp.Tags = make(map[string][]string)
+	}
+
+	grp.Tags[url] = tags
+	return nil
+}
+
+// HasTag checks if node of specified url is tagged with the capability.
+func (grp *NodeRouteGroup) HasTag(url, tag string) bool {
+	for _, v := range grp.Tags[url] {
+		if v == tag {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (cs *confStore) StoreNodeRouteGroup(routeGrp *NodeRouteGroup) error {
+// TaggedNodes returns urls of nodes tagged with the capability.
+func (grp *NodeRouteGroup) TaggedNodes(tag string) (urls []string) {
+	for _, url := range grp.Nodes {
+		if grp.HasTag(url, tag) {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+func validateNodeTags(tags []string) error {
+	seen := make(map[string]bool)
+
+	for _, tag := range tags {
+		switch tag {
+		case NodeTagArchive, NodeTagPruned, NodeTagTrace:
+		default:
+			return errors.Errorf("unknown node tag %v", tag)
+		}
+
+		if seen[tag] {
+			return errors.Errorf("duplicate node tag %v", tag)
+		}
+
+		seen[tag] = true
+	}
+
+	if seen[NodeTagArchive] && seen[NodeTagPruned] {
+		return errors.New("node could not be tagged as both archive and pruned")
+	}
+
+	return nil
+}
+
+// ValidateNodeRouteGroup checks if the route group is either builtin or persisted in store, eg.,
+// to reject allowlist pinned to a route group without any full node, otherwise
+// `ErrNodeRouteGroupNotFound` returned.
+func ValidateNodeRouteGroup(store NodeRouteGroupStore, group string, builtinGroups []string) error {
+	for _, grp := range builtinGroups {
+		if grp == group {
+			return nil
+		}
+	}
+
+	routeGroups, err := store.LoadNodeRouteGroups(group)
+	if err != nil {
+		return errors.WithMessage(err, "failed to load node route group")
+	}
+
+	if _, ok := routeGroups[group]; !ok {
+		return errors.WithMessagef(ErrNodeRouteGroupNotFound, "route group %v", group)
+	}
+
+	return nil
+}
+
+func removeString(values []string, value string) (res []string) {
+	for _, v := range values {
+		if v != value {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// StoreNodeRouteGroupBy creates or updates node route group on behalf of the operator, with the
+// change audited.
+func (cs *confStore) StoreNodeRouteGroupBy(operator string, routeGrp *NodeRouteGroup) error {
 	cfgVal, err := json.Marshal(routeGrp)
 	if err != nil {
 		return errors.WithMessage(err, "failed to marshal node route group")
 	}
 
 	cfgKey := NodeRouteGroupConfKeyPrefix + routeGrp.Name
-	return cs.StoreConfig(cfgKey, string(cfgVal))
+	return cs.StoreConfigBy(operator, cfgKey, string(cfgVal))
 }
 
-func (cs *confStore) DelNodeRouteGroup(group string) error {
+// DelNodeRouteGroupBy deletes node route group on behalf of the operator, with the change audited.
+func (cs *confStore) DelNodeRouteGroupBy(operator, group string) error {
 	cfgKey := NodeRouteGroupConfKeyPrefix + group
-	_, err := cs.DeleteConfig(cfgKey)
+	_, err := cs.DeleteConfigBy(operator, cfgKey)
 	return err
 }
 
@@ -286,9 +1297,13 @@ func (cs *confStore) LoadNodeRouteGroups(inclusiveGroups ...string) (res map[str
 	var cfgs []conf
 
 	if len(nodeRouteGrpConfKeys) == 0 {
-		err = cs.db.Where("name LIKE ?", nodeRouteGroupSqlMatchPattern).Find(&cfgs).Error
+		err = cs.db.Scopes(cs.envScope).Where("name LIKE ?", nodeRouteGroupSqlMatchPattern).Find(&cfgs).Error
 	} else {
-		err = cs.db.Where("name IN (?)", nodeRouteGrpConfKeys).Find(&cfgs).Error
+		err = cs.db.Scopes(cs.envScope).Where("name IN (?)", nodeRouteGrpConfKeys).Find(&cfgs).Error
+	}
+
+	if err == nil {
+		err = cs.decryptConfigs(cfgs)
 	}
 
 	if err != nil {
@@ -319,15 +1334,69 @@ func (cs *confStore) decodeNodeRouteGroup(cfg conf) (*NodeRouteGroup, error) {
 	// eg., noderoute.group.cfxvip
 	name := cfg.Name[len(NodeRouteGroupConfKeyPrefix):]
 	if len(name) == 0 {
-		return nil, errors.New("route group name is too short")
+		return nil, newDecodeError(cfg.Name, errors.New("route group name is too short"))
 	}
 
 	grp := NodeRouteGroup{ID: cfg.ID, Name: name}
 	data := []byte(cfg.Value)
 
 	if err := json.Unmarshal(data, &grp); err != nil {
-		return nil, err
+		return nil, newDecodeError(cfg.Name, err)
 	}
 
 	return &grp, nil
 }
+
+// node route rules config
+
+// NodeRouteRule routes RPC methods that match the pattern to the route group, in which the
+// pattern supports wildcards as per `path.Match`, eg., `debug_*` or `eth_sendRawTransaction`.
+type NodeRouteRule struct {
+	Method string `json:"method"` // RPC method pattern
+	Group  string `json:"group"`  // route group
+}
+
+// Match checks if the RPC method matches the rule pattern.
+func (rule *NodeRouteRule) Match(method string) bool {
+	matched, _ := path.Match(rule.Method, method)
+	return matched
+}
+
+// DecodeNodeRouteRules decodes the ordered node route rules from the raw config value.
+func DecodeNodeRouteRules(confVal string) ([]*NodeRouteRule, error) {
+	var rules []*NodeRouteRule
+	if err := json.Unmarshal([]byte(confVal), &rules); err != nil {
+		return nil, newDecodeError(NodeRouteRulesConfKey, err)
+	}
+
+	for i, rule := range rules {
+		if len(rule.Method) == 0 || len(rule.Group) == 0 {
+			return nil, newDecodeError(NodeRouteRulesConfKey, errors.Errorf(
+				"method pattern and group of rule #%v must not be empty", i,
+			))
+		}
+
+		if _, err := path.Match(rule.Method, ""); err != nil {
+			return nil, newDecodeError(NodeRouteRulesConfKey, errors.WithMessagef(
+				err, "invalid method pattern %v", rule.Method,
+			))
+		}
+	}
+
+	return rules, nil
+}
+
+// LoadNodeRouteRules loads the ordered node route rules from config store, or nil if absent.
+func LoadNodeRouteRules(cs ConfigStore) ([]*NodeRouteRule, error) {
+	confs, err := cs.LoadConfig(NodeRouteRulesConfKey)
+	if err != nil {
+		return nil, err
+	}
+
+	confVal, ok := confs[NodeRouteRulesConfKey].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	return DecodeNodeRouteRules(confVal)
+}
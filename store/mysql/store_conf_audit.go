@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// config audit actions
+	ConfigAuditActionStore  = "store"
+	ConfigAuditActionDelete = "delete"
+
+	// default operator for config changes if not specified
+	DefaultConfigOperator = "system"
+)
+
+// ConfigAudit is the audit record of config change.
+type ConfigAudit struct {
+	ID       uint64
+	Env      string  `gorm:"size:32;not null;default:''"` // environment
+	ConfName string  `gorm:"index;size:128;not null"`     // config name
+	Action   string  `gorm:"size:16;not null"`            // config change action
+	Operator string  `gorm:"size:128;not null"`           // who made the change
+	OldValue *string `gorm:"size:16250"`                  // nil if not existed
+	NewValue *string `gorm:"size:16250"`                  // nil if deleted
+
+	CreatedAt time.Time
+}
+
+func (ConfigAudit) TableName() string {
+	return "config_audits"
+}
+
+// isConfigAuditable checks whether the config change should be audited, which excludes
+// the internal state frequently changed by the sync service.
+func isConfigAuditable(confName string) bool {
+	return confName != MysqlConfKeyReorgVersion
+}
+
+// addConfigAudit records config change within the db transaction.
+func (cs *confStore) addConfigAudit(dbTx *gorm.DB, audit *ConfigAudit) (err error) {
+	audit.Env = cs.env
+
+	// config values audited are as sensitive as configs
+	if audit.OldValue, err = cs.cipher.EncryptValue(audit.ConfName, audit.OldValue); err != nil {
+		return err
+	}
+
+	if audit.NewValue, err = cs.cipher.EncryptValue(audit.ConfName, audit.NewValue); err != nil {
+		return err
+	}
+
+	return dbTx.Create(audit).Error
+}
+
+// LoadConfigAudits loads change history of the config in descending order of time,
+// with `limit` <= 0 meaning no limit.
+func (cs *confStore) LoadConfigAudits(confName string, limit int) ([]*ConfigAudit, error) {
+	db := cs.db.Where("env = ? AND conf_name = ?", cs.env, confName).Order("id DESC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+
+	var audits []*ConfigAudit
+	if err := db.Find(&audits).Error; err != nil {
+		return nil, err
+	}
+
+	for _, audit := range audits {
+		var err error
+		if audit.OldValue, err = cs.decryptValue(confName, audit.OldValue); err != nil {
+			return nil, err
+		}
+
+		if audit.NewValue, err = cs.decryptValue(confName, audit.NewValue); err != nil {
+			return nil, err
+		}
+	}
+
+	return audits, nil
+}
@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"crypto/md5"
+	"sync"
+	"time"
+)
+
+// configDecoder decodes config row into typed config value, and the config is skipped if
+// failed to decode.
+type configDecoder func(cfg conf) (interface{}, error)
+
+// cachedConfigSet decoded config set matched by some name pattern.
+type cachedConfigSet struct {
+	loadedAt  time.Time
+	values    map[uint32]interface{}    // config ID => decoded value
+	checksums map[uint32][md5.Size]byte // config ID => md5 checksum of raw value
+}
+
+// configCache keeps decoded configs in memory, so that db is queried at most once within the
+// refresh interval, and config rows are re-decoded only if the md5 checksums changed.
+type configCache struct {
+	mu sync.Mutex
+	// refresh interval to reload configs from db, 0 means always reload
+	ttl time.Duration
+	// config name match pattern => cached config set
+	sets map[string]*cachedConfigSet
+}
+
+func newConfigCache(ttl time.Duration) *configCache {
+	return &configCache{
+		ttl:  ttl,
+		sets: make(map[string]*cachedConfigSet),
+	}
+}
+
+// load loads decoded configs matched by name pattern from cache, or refreshes the cache by
+// querying db if expired.
+func (cc *configCache) load(
+	pattern string, query func() ([]conf, error), decode configDecoder,
+) (map[uint32]interface{}, map[uint32][md5.Size]byte, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cached, ok := cc.sets[pattern]
+	if ok && time.Since(cached.loadedAt) < cc.ttl {
+		return cached.copy()
+	}
+
+	cfgs, err := query()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshed := &cachedConfigSet{
+		loadedAt:  time.Now(),
+		values:    make(map[uint32]interface{}, len(cfgs)),
+		checksums: make(map[uint32][md5.Size]byte, len(cfgs)),
+	}
+
+	for _, v := range cfgs {
+		checksum := md5.Sum([]byte(v.Value))
+
+		// skip re-decoding unchanged config
+		if ok && cached.checksums[v.ID] == checksum {
+			if val, exists := cached.values[v.ID]; exists {
+				refreshed.values[v.ID] = val
+				refreshed.checksums[v.ID] = checksum
+				continue
+			}
+		}
+
+		val, err := decode(v)
+		if err != nil {
+			continue
+		}
+
+		refreshed.values[v.ID] = val
+		refreshed.checksums[v.ID] = checksum
+	}
+
+	cc.sets[pattern] = refreshed
+
+	return refreshed.copy()
+}
+
+// invalidate expires all the cached configs, so that configs will be reloaded from db, while
+// only the changed ones are re-decoded.
+func (cc *configCache) invalidate() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for _, set := range cc.sets {
+		set.loadedAt = time.Time{}
+	}
+}
+
+// copy returns copies of the cached maps to prevent the cache from being altered, or nil
+// if no config available.
+func (s *cachedConfigSet) copy() (map[uint32]interface{}, map[uint32][md5.Size]byte, error) {
+	if len(s.values) == 0 {
+		return nil, nil, nil
+	}
+
+	values := make(map[uint32]interface{}, len(s.values))
+	checksums := make(map[uint32][md5.Size]byte, len(s.checksums))
+
+	for id, val := range s.values {
+		values[id] = val
+		checksums[id] = s.checksums[id]
+	}
+
+	return values, checksums, nil
+}
@@ -0,0 +1,38 @@
+package mysql
+
+// decrypt decrypts the config value if encrypted, whose error is wrapped as `DecodeError`.
+func (cs *confStore) decrypt(confName, confVal string) (string, error) {
+	val, err := cs.cipher.Decrypt(confName, confVal)
+	if err != nil {
+		return "", newDecodeError(confName, err)
+	}
+
+	return val, nil
+}
+
+// decryptValue decrypts the optional config value, eg., of config audit.
+func (cs *confStore) decryptValue(confName string, confVal *string) (*string, error) {
+	val, err := cs.cipher.DecryptValue(confName, confVal)
+	if err != nil {
+		return nil, newDecodeError(confName, err)
+	}
+
+	return val, nil
+}
+
+// decryptConfig decrypts the config value in place.
+func (cs *confStore) decryptConfig(cfg *conf) (err error) {
+	cfg.Value, err = cs.decrypt(cfg.Name, cfg.Value)
+	return err
+}
+
+// decryptConfigs decrypts values of the configs in place.
+func (cs *confStore) decryptConfigs(cfgs []conf) error {
+	for i := range cfgs {
+		if err := cs.decryptConfig(&cfgs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
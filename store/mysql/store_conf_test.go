@@ -0,0 +1,435 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestConfStoreTypedErrors(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	_, err := ms.LoadAclAllowList("absent")
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.True(t, ms.IsRecordNotFound(err))
+
+	_, err = ms.LoadRateLimitStrategy("absent")
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+
+	assert.NoError(t, ms.StoreConfig(AclAllowListConfKeyPrefix+"malformed", "{"))
+
+	_, err = ms.LoadAclAllowList("malformed")
+	assert.True(t, errors.Is(err, ErrDecodeFailed))
+
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, AclAllowListConfKeyPrefix+"malformed", decodeErr.ConfName)
+	}
+}
+
+func TestConfStoreAudits(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	confName := RateLimitStrategyConfKeyPrefix + "audited"
+
+	assert.NoError(t, ms.StoreConfigBy("alice", confName, "v1"))
+	assert.NoError(t, ms.StoreConfig(confName, "v2"))
+
+	removed, err := ms.DeleteConfigBy("bob", confName)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	// deleting absent config is not audited
+	removed, err = ms.DeleteConfigBy("bob", confName)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	// internal state change is not audited
+	assert.NoError(t, ms.StoreConfig(MysqlConfKeyReorgVersion, "1"))
+
+	audits, err := ms.LoadConfigAudits(confName, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, audits, 3) {
+		assert.Equal(t, ConfigAuditActionDelete, audits[0].Action)
+		assert.Equal(t, "bob", audits[0].Operator)
+		assert.Equal(t, "v2", *audits[0].OldValue)
+		assert.Nil(t, audits[0].NewValue)
+
+		assert.Equal(t, DefaultConfigOperator, audits[1].Operator)
+		assert.Equal(t, "v1", *audits[1].OldValue)
+		assert.Equal(t, "v2", *audits[1].NewValue)
+
+		assert.Equal(t, "alice", audits[2].Operator)
+		assert.Nil(t, audits[2].OldValue)
+	}
+
+	audits, err = ms.LoadConfigAudits(confName, 1)
+	assert.NoError(t, err)
+	assert.Len(t, audits, 1)
+
+	audits, err = ms.LoadConfigAudits(MysqlConfKeyReorgVersion, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, audits)
+}
+
+func TestConfigCache(t *testing.T) {
+	cfgs := []conf{{ID: 1, Value: "a"}, {ID: 2, Value: "b"}}
+
+	var queries, decodes int
+	query := func() ([]conf, error) { queries++; return cfgs, nil }
+	decode := func(cfg conf) (interface{}, error) { decodes++; return cfg.Value, nil }
+
+	cc := newConfigCache(time.Hour)
+
+	values, checksums, err := cc.load("p", query, decode)
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.Len(t, checksums, 2)
+
+	// served from cache within refresh interval
+	cfgs[0].Value = "c"
+	values, _, _ = cc.load("p", query, decode)
+	assert.Equal(t, 1, queries)
+	assert.Equal(t, 2, decodes)
+	assert.Equal(t, "a", values[1])
+
+	// only the changed config is re-decoded after invalidation
+	cc.invalidate()
+	values, _, _ = cc.load("p", query, decode)
+	assert.Equal(t, 2, queries)
+	assert.Equal(t, 3, decodes)
+	assert.Equal(t, "c", values[1])
+
+	cc.ttl = 0
+	cfgs[1].Value = "d"
+	values, _, _ = cc.load("p", query, decode)
+	assert.Equal(t, 3, queries)
+	assert.Equal(t, 4, decodes)
+	assert.Equal(t, "c", values[1])
+	assert.Equal(t, "d", values[2])
+}
+
+func TestConfStoreCacheInvalidatedOnWrites(t *testing.T) {
+	ms := newTestSqliteStore(t)
+	ms.cache.ttl = time.Hour
+
+	confName := RateLimitStrategyConfKeyPrefix + "cached"
+	assert.NoError(t, ms.StoreConfig(confName, `{"rpc_all_qps": {"algo": "token_bucket", "option": {"rate": 1, "burst": 1}}}`))
+
+	strategies, _, err := ms.LoadRateLimitStrategyConfigs()
+	assert.NoError(t, err)
+	assert.Len(t, strategies, 1)
+
+	removed, err := ms.DeleteConfig(confName)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	strategies, _, err = ms.LoadRateLimitStrategyConfigs()
+	assert.NoError(t, err)
+	assert.Empty(t, strategies)
+}
+
+func TestConfStoreListConfigs(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	assert.NoError(t, ms.StoreConfig(RateLimitStrategyConfKeyPrefix+"free", `{}`))
+	assert.NoError(t, ms.StoreConfig(AclAllowListConfKeyPrefix+"vip", `{}`))
+	assert.NoError(t, ms.StoreConfig("ratelimitXstrategy.fake", `{}`))
+
+	confs, err := ms.ListConfigs(RateLimitStrategyConfKeyPrefix)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{RateLimitStrategyConfKeyPrefix + "free": `{}`}, confs)
+
+	confs, err = ms.ListConfigs("")
+	assert.NoError(t, err)
+	assert.Len(t, confs, 3)
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig("eth", "unknown.config", "{"))
+	assert.NoError(t, ValidateConfig("eth", MysqlConfKeyReorgVersion, "3"))
+	assert.Error(t, ValidateConfig("eth", MysqlConfKeyReorgVersion, "v3"))
+
+	assert.NoError(t, ValidateConfig("eth", RateLimitStrategyConfKeyPrefix+"free", `{}`))
+	assert.Error(t, ValidateConfig("eth", RateLimitStrategyConfKeyPrefix+"free", `{`))
+
+	assert.NoError(t, ValidateConfig("eth", AclAllowListConfKeyPrefix+"vip", `{"ContractAddresses":["0x0000000000000000000000000000000000000001"]}`))
+	err := ValidateConfig("eth", AclAllowListConfKeyPrefix+"vip", `{"ContractAddresses":["cfx:invalid"]}`)
+	assert.True(t, errors.Is(err, ErrDecodeFailed))
+	err = ValidateConfig("eth", AclAllowListConfKeyPrefix+"vip", `{"BlockedContracts":["0xinvalid"]}`)
+	assert.True(t, errors.Is(err, ErrDecodeFailed))
+
+	assert.NoError(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"grp", `{"nodes":["http://n1"],"weights":{"http://n1":3}}`))
+	assert.Error(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"grp", `{"nodes":["http://n1"],"weights":{"http://n1":100}}`))
+	assert.NoError(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"grp", `{"nodes":["http://n1"],"maxInflight":64}`))
+	assert.Error(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"grp", `{"nodes":["http://n1"],"maxInflight":-1}`))
+}
+
+func TestConfStoreStoreConfigsBy(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	confName := AclAllowListConfKeyPrefix + "vip"
+	assert.NoError(t, ms.StoreConfig(confName, "v1"))
+
+	assert.NoError(t, ms.StoreConfigsBy("alice", map[string]string{
+		confName:                 "v2",
+		MysqlConfKeyReorgVersion: "1",
+	}))
+
+	confs, err := ms.ListConfigs("")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{confName: "v2", MysqlConfKeyReorgVersion: "1"}, confs)
+
+	audits, err := ms.LoadConfigAudits(confName, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, audits, 2) {
+		assert.Equal(t, "alice", audits[0].Operator)
+		assert.Equal(t, "v1", *audits[0].OldValue)
+	}
+
+	// internal state change is not audited
+	audits, err = ms.LoadConfigAudits(MysqlConfKeyReorgVersion, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, audits)
+}
+
+func TestConfStoreStoreConfigIfMatch(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	confName := RateLimitStrategyConfKeyPrefix + "free"
+
+	// create only if not existed
+	assert.NoError(t, ms.StoreConfigIfMatch("alice", confName, "v1", ""))
+	assert.True(t, errors.Is(ms.StoreConfigIfMatch("bob", confName, "v1", ""), ErrConfigConflict))
+
+	// stale version rejected
+	assert.NoError(t, ms.StoreConfigIfMatch("alice", confName, "v2", ConfigVersion("v1")))
+	err := ms.StoreConfigIfMatch("bob", confName, "v3", ConfigVersion("v1"))
+	assert.True(t, errors.Is(err, ErrConfigConflict))
+
+	confs, err := ms.LoadConfig(confName)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", confs[confName])
+
+	audits, err := ms.LoadConfigAudits(confName, 0)
+	assert.NoError(t, err)
+	assert.Len(t, audits, 2)
+}
+
+func TestConfStoreApplyConfigsBy(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	strategy := RateLimitStrategyConfKeyPrefix + "free"
+	allowList := AclAllowListConfKeyPrefix + "vip"
+	assert.NoError(t, ms.StoreConfigBy("alice", strategy, "v1"))
+
+	ptr := func(s string) *string { return &s }
+
+	// all changes rolled back on any conflict
+	err := ms.ApplyConfigsBy("bob", []*ConfigChange{
+		{Name: allowList, Value: ptr("v1")},
+		{Name: strategy, Value: ptr("v2"), Version: ptr(ConfigVersion("v0"))},
+	})
+	assert.True(t, errors.Is(err, ErrConfigConflict))
+
+	confs, err := ms.LoadConfig(strategy, allowList)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{strategy: "v1"}, confs)
+
+	assert.NoError(t, ms.ApplyConfigsBy("bob", []*ConfigChange{
+		{Name: allowList, Value: ptr("v1"), Version: ptr("")},
+		{Name: strategy, Version: ptr(ConfigVersion("v1"))},
+	}))
+
+	confs, err = ms.LoadConfig(strategy, allowList)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{allowList: "v1"}, confs)
+
+	audits, err := ms.LoadConfigAudits(strategy, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, audits, 2) {
+		assert.Equal(t, ConfigAuditActionDelete, audits[0].Action)
+		assert.Equal(t, "bob", audits[0].Operator)
+	}
+}
+
+func TestConfStoreEnvScoping(t *testing.T) {
+	ms := newTestSqliteStore(t)
+	staging := newConfStore(ms.confStore.db, "staging", nil, 0)
+
+	confName := RateLimitStrategyConfKeyPrefix + "free"
+	assert.NoError(t, ms.StoreConfigBy("alice", confName, "prod"))
+	assert.NoError(t, staging.StoreConfigBy("bob", confName, "staging"))
+
+	confs, err := staging.LoadConfig(confName)
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", confs[confName])
+
+	audits, err := staging.LoadConfigAudits(confName, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, audits, 1) {
+		assert.Equal(t, "bob", audits[0].Operator)
+	}
+
+	removed, err := staging.DeleteConfigBy("bob", confName)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	confs, err = ms.LoadConfig(confName)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", confs[confName])
+
+	// reorg version shared by all environments
+	assert.NoError(t, ms.StoreConfig(MysqlConfKeyReorgVersion, "1"))
+
+	confs, err = staging.LoadConfig(MysqlConfKeyReorgVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", confs[MysqlConfKeyReorgVersion])
+}
+
+func TestNodeRouteGroupCanary(t *testing.T) {
+	grp := &NodeRouteGroup{Name: "vip", Nodes: []string{"http://n1"}}
+
+	assert.Error(t, grp.SetCanary([]string{"http://n1"}, 5))
+	assert.Error(t, grp.SetCanary([]string{"http://c1"}, -1))
+
+	assert.NoError(t, grp.SetCanary([]string{"http://c1"}, 5))
+	assert.True(t, grp.IsCanary("http://c1"))
+	assert.False(t, grp.IsCanary("http://n1"))
+
+	assert.NoError(t, grp.SetCanary(nil, 0))
+	assert.Nil(t, grp.Canary)
+
+	assert.NoError(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"canary":{"nodes":["http://c1"],"percent":5}}`))
+	assert.Error(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"canary":{"nodes":["http://n1"],"percent":5}}`))
+}
+
+func TestNodeRouteGroupShadow(t *testing.T) {
+	grp := &NodeRouteGroup{Name: "vip", Nodes: []string{"http://n1"}}
+	assert.NoError(t, grp.SetCanary([]string{"http://c1"}, 5))
+
+	assert.Error(t, grp.SetShadow([]string{"http://n1"}))
+	assert.Error(t, grp.SetShadow([]string{"http://c1"}))
+
+	assert.NoError(t, grp.SetShadow([]string{"http://s1"}))
+	assert.True(t, grp.IsShadow("http://s1"))
+	assert.False(t, grp.IsShadow("http://n1"))
+
+	// shadow node could not be canary node at the same time
+	assert.Error(t, grp.SetCanary([]string{"http://s1"}, 5))
+
+	assert.NoError(t, grp.SetShadow(nil))
+	assert.Nil(t, grp.Shadow)
+
+	assert.NoError(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"shadow":["http://s1"]}`))
+	assert.Error(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"shadow":["http://n1"]}`))
+}
+
+func TestNodeRouteRules(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	rules, err := LoadNodeRouteRules(ms)
+	assert.NoError(t, err)
+	assert.Nil(t, rules)
+
+	assert.NoError(t, ms.StoreConfig(NodeRouteRulesConfKey, `[
+		{"method": "debug_*", "group": "etharchives"},
+		{"method": "eth_sendRawTransaction", "group": "ethsequencer"}
+	]`))
+
+	rules, err = LoadNodeRouteRules(ms)
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.True(t, rules[0].Match("debug_traceTransaction"))
+		assert.False(t, rules[0].Match("eth_call"))
+		assert.True(t, rules[1].Match("eth_sendRawTransaction"))
+		assert.False(t, rules[1].Match("eth_sendTransaction"))
+	}
+
+	assert.Error(t, ValidateConfig("eth", NodeRouteRulesConfKey, `[{"method": "*"}]`))
+	assert.NoError(t, ValidateConfig("eth", NodeRouteRulesConfKey, `[{"method": "*", "group": "ethhttp"}]`))
+}
+
+func TestNodeRouteGroupMaintenance(t *testing.T) {
+	grp := &NodeRouteGroup{Name: "vip", Nodes: []string{"http://node1:8545", "http://node2:8545"}}
+
+	assert.Error(t, grp.SetMaintenance([]*NodeMaintenanceWindow{
+		{Nodes: []string{"http://node1:8545"}, Start: "2:30am", DurationSecs: 1800},
+	}))
+	assert.Error(t, grp.SetMaintenance([]*NodeMaintenanceWindow{
+		{Nodes: []string{"http://node3:8545"}, Start: "02:30", DurationSecs: 1800},
+	}))
+	assert.Error(t, grp.SetMaintenance([]*NodeMaintenanceWindow{
+		{Nodes: []string{"http://node1:8545"}, Start: "02:30", DurationSecs: 1800, DrainWindowSecs: 3600},
+	}))
+
+	// window crosses midnight
+	assert.NoError(t, grp.SetMaintenance([]*NodeMaintenanceWindow{
+		{Nodes: []string{"http://node1:8545"}, Start: "23:30", DurationSecs: 3600, DrainWindowSecs: 300},
+	}))
+
+	start := time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC)
+
+	assert.Empty(t, grp.DrainedAt(start.Add(-time.Second)))
+	assert.Equal(t, []string{"http://node1:8545"}, grp.DrainedAt(start))
+	assert.Equal(t, []string{"http://node1:8545"}, grp.DrainedAt(start.Add(45*time.Minute)))
+	assert.Empty(t, grp.DrainedAt(start.Add(time.Hour)))
+
+	drain, ok := grp.MaintenanceDrain("http://node1:8545", start.Add(45*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, NodeDrain{StartedAt: start.Unix(), WindowSecs: 300}, *drain)
+
+	// manual drain takes precedence
+	grp.StartDrain("http://node1:8545", time.Minute)
+	assert.Equal(t, []string{"http://node1:8545"}, grp.DrainedAt(start))
+	assert.Equal(t, int64(60), grp.DrainsAt(start)["http://node1:8545"].WindowSecs)
+
+	// maintenance window removed along with node
+	grp.RemoveNode("http://node1:8545")
+	assert.Empty(t, grp.Maintenance)
+}
+
+func TestNodeRouteGroupTags(t *testing.T) {
+	grp := &NodeRouteGroup{Name: "vip", Nodes: []string{"http://node1:8545", "http://node2:8545"}}
+
+	assert.Error(t, grp.SetTags("http://node1:8545", []string{"fast"}))
+	assert.Error(t, grp.SetTags("http://node1:8545", []string{NodeTagArchive, NodeTagArchive}))
+	assert.Error(t, grp.SetTags("http://node1:8545", []string{NodeTagArchive, NodeTagPruned}))
+
+	assert.NoError(t, grp.SetTags("http://node1:8545", []string{NodeTagArchive, NodeTagTrace}))
+	assert.NoError(t, grp.SetTags("http://node2:8545", []string{NodeTagPruned}))
+
+	assert.True(t, grp.HasTag("http://node1:8545", NodeTagTrace))
+	assert.False(t, grp.HasTag("http://node2:8545", NodeTagArchive))
+	assert.Equal(t, []string{"http://node1:8545"}, grp.TaggedNodes(NodeTagArchive))
+
+	assert.NoError(t, grp.SetTags("http://node2:8545", nil))
+	assert.Empty(t, grp.TaggedNodes(NodeTagPruned))
+
+	// tags removed along with node
+	grp.RemoveNode("http://node1:8545")
+	assert.Empty(t, grp.Tags)
+
+	assert.NoError(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"tags":{"http://n1":["archive"]}}`))
+	assert.Error(t, ValidateConfig("eth", NodeRouteGroupConfKeyPrefix+"vip", `{"nodes":["http://n1"],"tags":{"http://n1":["full"]}}`))
+}
+
+func TestConfStoreValueEqualCond(t *testing.T) {
+	ms := newTestSqliteStore(t)
+	assert.Equal(t, "value = ?", valueEqualCond(ms.confStore.db))
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN: "user:pass@tcp(127.0.0.1:3306)/confura", SkipInitializeWithVersion: true,
+	}), &gorm.Config{DisableAutomaticPing: true})
+	assert.NoError(t, err)
+
+	// compared byte by byte, rather than by case insensitive and pad space collation
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&conf{}).Where("id = ? AND "+valueEqualCond(tx), 1, "V1 ").Update("value", "v2")
+	})
+	assert.Contains(t, sql, "CAST(value AS BINARY) = 'V1 '")
+}
@@ -0,0 +1,128 @@
+package mysql
+
+import (
+	"context"
+	"crypto/md5"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+)
+
+var (
+	_ ConfigStore            = (*MysqlStore)(nil)
+	_ ConfigLister           = (*MysqlStore)(nil)
+	_ AclAllowListStore      = (*MysqlStore)(nil)
+	_ RateLimitStrategyStore = (*MysqlStore)(nil)
+	_ NodeRouteGroupStore    = (*MysqlStore)(nil)
+	_ UsageQuotaStore        = (*MysqlStore)(nil)
+	_ NodeRouteReader        = (*MysqlStore)(nil)
+	_ ConfigAuditStore       = (*MysqlStore)(nil)
+	_ ConfigCasStore         = (*MysqlStore)(nil)
+	_ ConfigCacheInvalidator = (*MysqlStore)(nil)
+	_ ConfigManager          = (*MysqlStore)(nil)
+
+	_ metering.Store = (*MysqlStore)(nil)
+)
+
+// ConfigStore persists named config items.
+type ConfigStore interface {
+	LoadConfig(confNames ...string) (map[string]interface{}, error)
+	StoreConfig(confName string, confVal interface{}) error
+	DeleteConfig(confName string) (bool, error)
+}
+
+// ConfigLister lists the raw values of configs by name prefix.
+type ConfigLister interface {
+	ListConfigs(namePrefix string) (map[string]string, error)
+}
+
+// ConfigAuditStore persists config changes on behalf of operators with the change history audited.
+type ConfigAuditStore interface {
+	StoreConfigBy(operator, confName string, confVal interface{}) error
+	StoreConfigsBy(operator string, confs map[string]string) error
+	DeleteConfigBy(operator, confName string) (bool, error)
+	LoadConfigAudits(confName string, limit int) ([]*ConfigAudit, error)
+}
+
+// ConfigCasStore persists config changes on behalf of operators only if the config is not modified
+// since the expected version (see `ConfigVersion`) was read, otherwise `ErrConfigConflict` returned.
+// Empty version means the config must not exist yet.
+type ConfigCasStore interface {
+	StoreConfigIfMatch(operator, confName string, confVal interface{}, version string) error
+	// ApplyConfigsBy applies all the config changes atomically, so that configs depending on each
+	// other (eg., strategy, allowlist and route group) would never be partially applied.
+	ApplyConfigsBy(operator string, changes []*ConfigChange) error
+}
+
+// ConfigChange is a change of config item to apply in batch.
+type ConfigChange struct {
+	Name string
+	// new config value, or nil to delete the config
+	Value *string
+	// expected config version if not nil, empty means the config must not exist yet
+	Version *string
+}
+
+// ConfigCacheInvalidator forces the cached configs to be reloaded from store.
+type ConfigCacheInvalidator interface {
+	InvalidateConfigCache()
+}
+
+// AclAllowListStore loads access control allowlists, which returns `ErrConfigNotFound`
+// if allowlist not found or `ErrDecodeFailed` if allowlist config malformed.
+type AclAllowListStore interface {
+	LoadAclAllowList(name string) (*acl.AllowList, error)
+	LoadAclAllowListById(aclID uint32) (*acl.AllowList, error)
+	LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[uint32][md5.Size]byte, error)
+}
+
+// RateLimitStrategyStore loads rate limit strategies, which returns `ErrConfigNotFound`
+// if strategy not found or `ErrDecodeFailed` if strategy config malformed.
+type RateLimitStrategyStore interface {
+	LoadRateLimitConfigs() (*rate.Config, error)
+	LoadRateLimitStrategy(name string) (*rate.Strategy, error)
+	LoadRateLimitStrategyConfigs() (map[uint32]*rate.Strategy, map[uint32][md5.Size]byte, error)
+}
+
+// NodeRouteGroupStore persists node route groups on behalf of the operator for audit.
+type NodeRouteGroupStore interface {
+	StoreNodeRouteGroupBy(operator string, routeGrp *NodeRouteGroup) error
+	DelNodeRouteGroupBy(operator, group string) error
+	LoadNodeRouteGroups(inclusiveGroups ...string) (map[string]*NodeRouteGroup, error)
+}
+
+// NodeRouteReader finds node routes of route keys, eg., API keys pinned to node route groups.
+type NodeRouteReader interface {
+	FindNodeRoute(routeKey string) (*NodeRoute, error)
+	LoadNodeRoutes(filter NodeRouteFilter) ([]*NodeRoute, error)
+}
+
+// UsageQuotaStore loads compute units quotas of API keys, in which malformed quotas are
+// skipped.
+type UsageQuotaStore interface {
+	LoadUsageQuotaConfigs() (map[uint32]*metering.Quota, error)
+}
+
+// ConfigManager aggregates all config stores, which could be substituted by embedders
+// with their own store implementation.
+type ConfigManager interface {
+	ConfigStore
+	ConfigLister
+	ConfigAuditStore
+	ConfigCasStore
+	ConfigCacheInvalidator
+	AclAllowListStore
+	RateLimitStrategyStore
+	NodeRouteGroupStore
+	UsageQuotaStore
+}
+
+// ConfigWatcher watches config changes of store, eg., Consul config store, so that consumers
+// could apply the changes at once rather than waiting for the next periodical reloading.
+type ConfigWatcher interface {
+	// Watch watches config changes until context done.
+	Watch(ctx context.Context)
+	// OnChange registers listener to be notified once configs changed.
+	OnChange(listener func())
+}
@@ -41,7 +41,8 @@ func (sp *storePruner) observe() {
 }
 
 // schedulePrune periodically monitors and removes extra more than the max sepcified number of
-// archive bn partitions. Be noted this function will block caller thread.
+// archive bn partitions, as well as the archive bn partitions out of the retention window if
+// configured. Be noted this function will block caller thread.
 func (sp *storePruner) schedulePrune(config *Config) {
 	ticker := time.NewTicker(time.Minute * 15)
 	defer ticker.Stop()
@@ -51,9 +52,7 @@ func (sp *storePruner) schedulePrune(config *Config) {
 			entity := key.(string)
 			tabler := value.(schema.Tabler)
 
-			pruned, err := sp.partitionedStore.pruneArchivePartitions(
-				entity, tabler, config.MaxBnRangedArchiveLogPartitions,
-			)
+			pruned, err := sp.prune(entity, tabler, config)
 
 			logger := logrus.WithField("entity", entity)
 
@@ -66,7 +65,11 @@ func (sp *storePruner) schedulePrune(config *Config) {
 			}
 
 			if err == nil {
-				sp.bnPartitionObsEntitySet.Delete(entity)
+				// Partitions may expire as new blocks synchronized even if no new partition
+				// observed, so keep tracking the entity if retention window configured.
+				if config.LogRetentionBlocks == 0 {
+					sp.bnPartitionObsEntitySet.Delete(entity)
+				}
 
 				// To minimize the db performance loss, we only remove extra archive partitions
 				// for one entity at a time.
@@ -80,3 +83,16 @@ func (sp *storePruner) schedulePrune(config *Config) {
 		})
 	}
 }
+
+// prune removes archive bn partitions either exceeding the max number or out of the retention window.
+func (sp *storePruner) prune(entity string, tabler schema.Tabler, config *Config) ([]*bnPartition, error) {
+	pruned, err := sp.partitionedStore.pruneArchivePartitions(
+		entity, tabler, config.MaxBnRangedArchiveLogPartitions,
+	)
+	if err != nil || config.LogRetentionBlocks == 0 {
+		return pruned, err
+	}
+
+	expired, err := sp.partitionedStore.pruneExpiredPartitions(entity, tabler, config.LogRetentionBlocks)
+	return append(pruned, expired...), err
+}
@@ -15,6 +15,7 @@ type RateLimit struct {
 	LimitType int    `gorm:"default:0;not null"`       // limit type
 	LimitKey  string `gorm:"unique;size:128;not null"` // limit key
 	Memo      string `gorm:"size:128"`                 // memo
+	Secret    string `gorm:"size:128"`                 // HMAC secret to verify signed requests
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -26,11 +27,15 @@ func (RateLimit) TableName() string {
 
 type RateLimitStore struct {
 	*baseStore
+
+	// environment to scope keysets, of which the bound rate limit strategies are configured in
+	env string
 }
 
-func NewRateLimitStore(db *gorm.DB) *RateLimitStore {
+func NewRateLimitStore(db *gorm.DB, env string) *RateLimitStore {
 	return &RateLimitStore{
 		baseStore: newBaseStore(db),
+		env:       env,
 	}
 }
 
@@ -57,8 +62,21 @@ func (rls *RateLimitStore) DeleteRateLimit(limitKey string) (bool, error) {
 	return res.RowsAffected > 0, res.Error
 }
 
+// SetRateLimitSecret sets the HMAC secret of rate limit key to verify signed requests, or disables
+// request signing if secret is empty.
+func (rls *RateLimitStore) SetRateLimitSecret(limitKey, secret string) (bool, error) {
+	res := rls.db.Model(&RateLimit{}).Where("limit_key = ?", limitKey).Update("secret", secret)
+	return res.RowsAffected > 0, res.Error
+}
+
+// LoadRateLimitKeyset loads keyset by filter, which is scoped to the environment by the bound rate
+// limit strategies.
 func (rls *RateLimitStore) LoadRateLimitKeyset(filter *rate.KeysetFilter) (res []*RateLimit, err error) {
-	db := rls.db
+	strategies := rls.db.Model(&conf{}).
+		Select("id").
+		Where("env = ? AND name LIKE ?", rls.env, rateLimitStrategySqlMatchPattern)
+
+	db := rls.db.Where("s_id IN (?)", strategies)
 
 	if len(filter.KeySet) > 0 {
 		db = db.Where("limit_key IN (?)", filter.KeySet)
@@ -68,6 +86,10 @@ func (rls *RateLimitStore) LoadRateLimitKeyset(filter *rate.KeysetFilter) (res [
 		db = db.Where("s_id IN (?)", filter.SIDs)
 	}
 
+	if len(filter.AclIDs) > 0 {
+		db = db.Where("acl_id IN (?)", filter.AclIDs)
+	}
+
 	if filter.Limit > 0 {
 		db = db.Limit(filter.Limit)
 	}
@@ -82,6 +104,7 @@ func (rls *RateLimitStore) LoadRateLimitKeyset(filter *rate.KeysetFilter) (res [
 	return res, err
 }
 
+// LoadRateLimitKeyInfos loads key infos of keyset by filter, which is scoped to the environment.
 func (rls *RateLimitStore) LoadRateLimitKeyInfos(filter *rate.KeysetFilter) (res []*rate.KeyInfo, err error) {
 	ratelimits, err := rls.LoadRateLimitKeyset(filter)
 	if err != nil {
@@ -90,10 +113,11 @@ func (rls *RateLimitStore) LoadRateLimitKeyInfos(filter *rate.KeysetFilter) (res
 
 	for i := range ratelimits {
 		res = append(res, &rate.KeyInfo{
-			Type:  rate.LimitType(ratelimits[i].LimitType),
-			Key:   ratelimits[i].LimitKey,
-			SID:   ratelimits[i].SID,
-			AclID: ratelimits[i].AclID,
+			Type:   rate.LimitType(ratelimits[i].LimitType),
+			Key:    ratelimits[i].LimitKey,
+			SID:    ratelimits[i].SID,
+			AclID:  ratelimits[i].AclID,
+			Secret: ratelimits[i].Secret,
 		})
 	}
 
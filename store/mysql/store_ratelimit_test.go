@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitKeysetScopedByEnv(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	prod := &conf{Env: "prod", Name: RateLimitStrategyConfKeyPrefix + "basic", Value: "{}"}
+	staging := &conf{Env: "staging", Name: RateLimitStrategyConfKeyPrefix + "basic", Value: "{}"}
+	assert.NoError(t, ms.baseStore.db.Create(prod).Error)
+	assert.NoError(t, ms.baseStore.db.Create(staging).Error)
+
+	prodStore := NewRateLimitStore(ms.baseStore.db, "prod")
+	assert.NoError(t, prodStore.AddRateLimit(prod.ID, 0, rate.LimitTypeByKey, "prodKey", ""))
+	assert.NoError(t, prodStore.AddRateLimit(staging.ID, 0, rate.LimitTypeByKey, "stagingKey", ""))
+
+	keyset, err := prodStore.LoadRateLimitKeyset(&rate.KeysetFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, keyset, 1) {
+		assert.Equal(t, "prodKey", keyset[0].LimitKey)
+	}
+
+	// keys bound to strategies of other environments are invisible
+	infos, err := prodStore.LoadRateLimitKeyInfos(&rate.KeysetFilter{KeySet: []string{"stagingKey"}})
+	assert.NoError(t, err)
+	assert.Empty(t, infos)
+
+	infos, err = NewRateLimitStore(ms.baseStore.db, "staging").LoadRateLimitKeyInfos(&rate.KeysetFilter{KeySet: []string{"stagingKey"}})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/slowlog"
+	"gorm.io/gorm"
+)
+
+// SlowQuery persisted slow RPC call for later analysis.
+type SlowQuery struct {
+	ID         uint64
+	Method     string    `gorm:"size:128;not null;index:idx_method_time,priority:1"`
+	Params     string    `gorm:"type:text"`
+	DurationMs int64     `gorm:"not null"`
+	BlockRange uint64    `gorm:"not null;default:0"`
+	Upstream   string    `gorm:"size:128"`
+	TraceId    string    `gorm:"size:128;index"`
+	Caller     string    `gorm:"size:128"`
+	ErrorCode  int       `gorm:"not null;default:0"`
+	CreatedAt  time.Time `gorm:"not null;index;index:idx_method_time,priority:2"`
+}
+
+func (SlowQuery) TableName() string {
+	return "slow_queries"
+}
+
+type SlowQueryStore struct {
+	*baseStore
+}
+
+func NewSlowQueryStore(db *gorm.DB) *SlowQueryStore {
+	return &SlowQueryStore{
+		baseStore: newBaseStore(db),
+	}
+}
+
+// AddSlowQueries implements `slowlog.Store` to persist slow queries.
+func (sqs *SlowQueryStore) AddSlowQueries(queries []*slowlog.Query) error {
+	records := make([]*SlowQuery, 0, len(queries))
+	for _, q := range queries {
+		records = append(records, &SlowQuery{
+			Method:     q.Method,
+			Params:     q.Params,
+			DurationMs: q.DurationMs,
+			BlockRange: q.BlockRange,
+			Upstream:   q.Upstream,
+			TraceId:    q.TraceId,
+			Caller:     q.Caller,
+			ErrorCode:  q.ErrorCode,
+			CreatedAt:  q.Time,
+		})
+	}
+
+	return sqs.db.Create(records).Error
+}
+
+// LoadSlowQueries loads the latest slow queries of the RPC method since the specified time, or of
+// all methods if method is empty.
+func (sqs *SlowQueryStore) LoadSlowQueries(method string, since time.Time, limit int) ([]*SlowQuery, error) {
+	db := sqs.db.Where("created_at >= ?", since)
+	if len(method) > 0 {
+		db = db.Where("method = ?", method)
+	}
+
+	var records []*SlowQuery
+	err := db.Order("created_at DESC").Limit(limit).Find(&records).Error
+
+	return records, err
+}
@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/slowlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryStore(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	now := time.Now()
+	err := ms.AddSlowQueries([]*slowlog.Query{
+		{Time: now.Add(-time.Hour), Method: "eth_getLogs", DurationMs: 5000},
+		{Time: now, Method: "eth_getLogs", BlockRange: 5000, TraceId: "trace1"},
+		{Time: now, Method: "eth_call", DurationMs: 3000},
+	})
+	assert.NoError(t, err)
+
+	queries, err := ms.LoadSlowQueries("eth_getLogs", now.Add(-time.Minute), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, "trace1", queries[0].TraceId)
+		assert.Equal(t, uint64(5000), queries[0].BlockRange)
+	}
+
+	queries, err = ms.LoadSlowQueries("", now.Add(-2*time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Len(t, queries, 3)
+}
@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metering"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApiKeyUsage daily usage aggregate of API key per RPC method.
+type ApiKeyUsage struct {
+	ID           uint64
+	ApiKey       string `gorm:"size:128;not null;uniqueIndex:uidx_key_date_method,priority:1"`
+	Date         string `gorm:"size:10;not null;uniqueIndex:uidx_key_date_method,priority:2"` // UTC date
+	Method       string `gorm:"size:128;not null;uniqueIndex:uidx_key_date_method,priority:3"`
+	Requests     uint64 `gorm:"not null;default:0"`
+	ComputeUnits uint64 `gorm:"not null;default:0"`
+
+	UpdatedAt time.Time
+}
+
+func (ApiKeyUsage) TableName() string {
+	return "api_key_usages"
+}
+
+// ApiKeyFailure daily failure aggregate of API key per JSON-RPC error code.
+type ApiKeyFailure struct {
+	ID       uint64
+	ApiKey   string `gorm:"size:128;not null;uniqueIndex:uidx_key_date_code,priority:1"`
+	Date     string `gorm:"size:10;not null;uniqueIndex:uidx_key_date_code,priority:2"` // UTC date
+	Code     int    `gorm:"not null;uniqueIndex:uidx_key_date_code,priority:3"`
+	Requests uint64 `gorm:"not null;default:0"`
+
+	UpdatedAt time.Time
+}
+
+func (ApiKeyFailure) TableName() string {
+	return "api_key_failures"
+}
+
+type UsageStore struct {
+	*baseStore
+}
+
+func NewUsageStore(db *gorm.DB) *UsageStore {
+	return &UsageStore{
+		baseStore: newBaseStore(db),
+	}
+}
+
+// AddUsages implements `metering.Store` to accumulate usages onto the daily aggregates.
+func (us *UsageStore) AddUsages(usages []*metering.Usage) error {
+	return us.db.Transaction(func(dbTx *gorm.DB) error {
+		for _, u := range usages {
+			err := dbTx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "api_key"}, {Name: "date"}, {Name: "method"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"requests":      gorm.Expr("requests + ?", u.Requests),
+					"compute_units": gorm.Expr("compute_units + ?", u.ComputeUnits),
+					"updated_at":    time.Now(),
+				}),
+			}).Create(&ApiKeyUsage{
+				ApiKey:       u.ApiKey,
+				Date:         u.Date,
+				Method:       u.Method,
+				Requests:     u.Requests,
+				ComputeUnits: u.ComputeUnits,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadUsages implements `metering.Store` to load daily usage aggregates of the API key.
+func (us *UsageStore) LoadUsages(apiKey, fromDate, toDate string) ([]*metering.Usage, error) {
+	var records []*ApiKeyUsage
+
+	err := us.db.Where("api_key = ? AND date >= ? AND date <= ?", apiKey, fromDate, toDate).
+		Order("date ASC, method ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]*metering.Usage, 0, len(records))
+	for _, r := range records {
+		usages = append(usages, &metering.Usage{
+			ApiKey:       r.ApiKey,
+			Date:         r.Date,
+			Method:       r.Method,
+			Requests:     r.Requests,
+			ComputeUnits: r.ComputeUnits,
+		})
+	}
+
+	return usages, nil
+}
+
+// AddFailures implements `metering.Store` to accumulate failures onto the daily aggregates.
+func (us *UsageStore) AddFailures(failures []*metering.Failure) error {
+	return us.db.Transaction(func(dbTx *gorm.DB) error {
+		for _, f := range failures {
+			err := dbTx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "api_key"}, {Name: "date"}, {Name: "code"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"requests":   gorm.Expr("requests + ?", f.Requests),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&ApiKeyFailure{
+				ApiKey:   f.ApiKey,
+				Date:     f.Date,
+				Code:     f.Code,
+				Requests: f.Requests,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadFailures implements `metering.Store` to load daily failure aggregates of the API key.
+func (us *UsageStore) LoadFailures(apiKey, fromDate, toDate string) ([]*metering.Failure, error) {
+	var records []*ApiKeyFailure
+
+	err := us.db.Where("api_key = ? AND date >= ? AND date <= ?", apiKey, fromDate, toDate).
+		Order("date ASC, code ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	failures := make([]*metering.Failure, 0, len(records))
+	for _, r := range records {
+		failures = append(failures, &metering.Failure{
+			ApiKey:   r.ApiKey,
+			Date:     r.Date,
+			Code:     r.Code,
+			Requests: r.Requests,
+		})
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageStoreAccumulates(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	usage := func(date string, requests, cus uint64) *metering.Usage {
+		return &metering.Usage{
+			ApiKey: "key", Date: date, Method: "eth_call", Requests: requests, ComputeUnits: cus,
+		}
+	}
+
+	assert.NoError(t, ms.AddUsages([]*metering.Usage{usage("2024-01-01", 1, 10), usage("2024-01-02", 2, 20)}))
+	assert.NoError(t, ms.AddUsages([]*metering.Usage{usage("2024-01-02", 3, 30)}))
+
+	usages, err := ms.LoadUsages("key", "2024-01-02", "2024-01-31")
+	assert.NoError(t, err)
+	if assert.Len(t, usages, 1) {
+		assert.Equal(t, uint64(5), usages[0].Requests)
+		assert.Equal(t, uint64(50), usages[0].ComputeUnits)
+	}
+
+	usages, err = ms.LoadUsages("other", "2024-01-01", "2024-01-31")
+	assert.NoError(t, err)
+	assert.Empty(t, usages)
+}
+
+func TestUsageStoreAccumulatesFailures(t *testing.T) {
+	ms := newTestSqliteStore(t)
+
+	failure := func(date string, code int, requests uint64) *metering.Failure {
+		return &metering.Failure{ApiKey: "key", Date: date, Code: code, Requests: requests}
+	}
+
+	assert.NoError(t, ms.AddFailures([]*metering.Failure{failure("2024-01-01", -32005, 1), failure("2024-01-02", 3, 2)}))
+	assert.NoError(t, ms.AddFailures([]*metering.Failure{failure("2024-01-02", 3, 3), failure("2024-01-02", -32005, 4)}))
+
+	failures, err := ms.LoadFailures("key", "2024-01-02", "2024-01-02")
+	assert.NoError(t, err)
+	assert.Equal(t, []*metering.Failure{failure("2024-01-02", -32005, 4), failure("2024-01-02", 3, 5)}, failures)
+
+	failures, err = ms.LoadFailures("other", "2024-01-01", "2024-01-31")
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+}
@@ -0,0 +1,195 @@
+// Package accesslog writes one structured JSON record per RPC call to pluggable sinks, eg.,
+// stdout, rotating files or syslog, with configurable sampling to keep the volume manageable.
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// supported access log sinks
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+)
+
+// Config access log configurations.
+type Config struct {
+	// switch to turn on/off access log
+	Enabled bool
+	// sinks to write access log to, available sinks are `stdout`, `file` and `syslog`
+	Sinks []string `default:"[stdout]"`
+	// rotating file sink
+	File FileConfig
+	// syslog sink
+	Syslog SyslogConfig
+	// fraction of successful requests to log, in range (0, 1]
+	SampleRate float64 `default:"1"`
+	// whether to log all failed requests regardless of sampling
+	AlwaysLogErrors bool `default:"true"`
+}
+
+// Record access log record of an RPC call.
+type Record struct {
+	Time       string `json:"time"`
+	Chain      string `json:"chain,omitempty"` // additional chain served by the gateway
+	Method     string `json:"method"`
+	DurationMs int64  `json:"durationMs"`
+	Upstream   string `json:"upstream,omitempty"`
+	BytesIn    int    `json:"bytesIn"`
+	BytesOut   int    `json:"bytesOut"`
+	ErrorCode  int    `json:"errorCode,omitempty"`
+	Caller     string `json:"caller,omitempty"` // ID of API key
+	IP         string `json:"ip,omitempty"`
+}
+
+// Logger writes access log records to all sinks.
+type Logger struct {
+	conf *Config
+
+	mu    sync.Mutex
+	sinks []io.WriteCloser
+}
+
+// MustNewLoggerFromViper creates access logger from viper, or nil if disabled.
+func MustNewLoggerFromViper() *Logger {
+	var conf Config
+	viper.MustUnmarshalKey("accessLog", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.SampleRate <= 0 || conf.SampleRate > 1 {
+		logrus.WithField("config", conf).Fatal("Invalid access log sample rate")
+	}
+
+	var sinks []io.WriteCloser
+
+	for _, name := range conf.Sinks {
+		sink, err := newSink(name, &conf)
+		if err != nil {
+			logrus.WithError(err).WithField("sink", name).Fatal("Failed to create access log sink")
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	logrus.WithField("config", conf).Info("RPC access log enabled")
+
+	return NewLogger(&conf, sinks...)
+}
+
+func newSink(name string, conf *Config) (io.WriteCloser, error) {
+	switch name {
+	case SinkStdout:
+		return newStdoutSink(), nil
+	case SinkFile:
+		return NewRotatingFile(conf.File)
+	case SinkSyslog:
+		return newSyslogSink(conf.Syslog)
+	default:
+		return nil, errUnsupportedSink
+	}
+}
+
+func NewLogger(conf *Config, sinks ...io.WriteCloser) *Logger {
+	return &Logger{conf: conf, sinks: sinks}
+}
+
+// sampled checks if the record should be logged.
+func (l *Logger) sampled(record *Record) bool {
+	if record.ErrorCode != 0 && l.conf.AlwaysLogErrors {
+		return true
+	}
+
+	return l.conf.SampleRate >= 1 || rand.Float64() < l.conf.SampleRate
+}
+
+// Write writes record as a JSON line to all sinks.
+func (l *Logger) Write(record *Record) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal access log record")
+		return
+	}
+
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sink := range l.sinks {
+		if _, err := sink.Write(data); err != nil {
+			metrics.Registry.RPC.AccessLog("failed").Mark(1)
+			logrus.WithError(err).Debug("Failed to write access log record")
+		} else {
+			metrics.Registry.RPC.AccessLog("written").Mark(1)
+		}
+	}
+}
+
+// Close closes all sinks.
+func (l *Logger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sink := range l.sinks {
+		sink.Close()
+	}
+
+	l.sinks = nil
+}
+
+// Call writes access log record of RPC call once responded, which passes through if logger is nil.
+func (l *Logger) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if l == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		start := time.Now()
+
+		ctx = handlers.WithUpstreamRecorder(ctx)
+		resp := next(ctx, msg)
+
+		record := &Record{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			Method:     msg.Method,
+			DurationMs: int64(time.Since(start) / time.Millisecond),
+			BytesIn:    len(msg.Params),
+		}
+
+		if resp != nil {
+			record.BytesOut = len(resp.Result)
+
+			if resp.Error != nil {
+				record.ErrorCode = resp.Error.Code
+			}
+		}
+
+		if !l.sampled(record) {
+			return resp
+		}
+
+		record.Upstream, _ = handlers.GetUpstreamFromContext(ctx)
+		record.Chain, _ = handlers.GetChainFromContext(ctx)
+		record.Caller, _ = handlers.GetAuthIdFromContext(ctx)
+		record.IP, _ = handlers.GetIPAddressFromContext(ctx)
+
+		l.Write(record)
+
+		return resp
+	}
+}
@@ -0,0 +1,92 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (*bufferSink) Close() error { return nil }
+
+func TestLoggerCall(t *testing.T) {
+	sink := &bufferSink{}
+	logger := NewLogger(&Config{SampleRate: 1}, sink)
+
+	handler := logger.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		handlers.RecordUpstream(ctx, "node1")
+
+		if msg.Method == "eth_call" {
+			return msg.ErrorResponse(&rpc.JsonError{Code: 3, Message: "execution reverted"})
+		}
+
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x1"`)}
+	})
+
+	ctx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "key1")
+	ctx = context.WithValue(ctx, handlers.CtxKeyRealIP, "10.0.0.1")
+	handler(ctx, &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x1"]`)})
+	handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"})
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var record Record
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "eth_getBalance", record.Method)
+	assert.Equal(t, "node1", record.Upstream)
+	assert.Equal(t, 7, record.BytesIn)
+	assert.Equal(t, 5, record.BytesOut)
+	assert.Equal(t, "key1", record.Caller)
+	assert.Equal(t, "10.0.0.1", record.IP)
+	assert.Zero(t, record.ErrorCode)
+
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, 3, record.ErrorCode)
+}
+
+func TestLoggerSampled(t *testing.T) {
+	logger := NewLogger(&Config{SampleRate: 0.000001, AlwaysLogErrors: true})
+
+	assert.True(t, logger.sampled(&Record{ErrorCode: -32000}))
+
+	logger.conf.AlwaysLogErrors = false
+	assert.False(t, logger.sampled(&Record{ErrorCode: -32000}))
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	rf, err := NewRotatingFile(FileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 1})
+	assert.NoError(t, err)
+	defer rf.Close()
+
+	line := bytes.Repeat([]byte("x"), 700<<10)
+	for i := 0; i < 3; i++ {
+		_, err := rf.Write(line)
+		assert.NoError(t, err)
+	}
+
+	// current file and the latest backup retained
+	files, _ := filepath.Glob(path + "*")
+	assert.Len(t, files, 2)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(line)), info.Size())
+}
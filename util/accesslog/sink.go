@@ -0,0 +1,154 @@
+package accesslog
+
+import (
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errUnsupportedSink = errors.New("unsupported access log sink")
+
+// FileConfig configurations of rotating file sink.
+type FileConfig struct {
+	// path of the access log file
+	Path string `default:"logs/access.log"`
+	// max size in megabytes of the access log file before rotated
+	MaxSizeMB int64 `default:"100"`
+	// max number of rotated files to retain, or retain all if 0
+	MaxBackups int `default:"10"`
+}
+
+// SyslogConfig configurations of syslog sink.
+type SyslogConfig struct {
+	// network to connect syslog daemon, eg., `udp` or `tcp`, or the local daemon if empty
+	Network string
+	// address of syslog daemon, eg., `127.0.0.1:514`
+	Address string
+	// tag of syslog messages
+	Tag string `default:"confura"`
+}
+
+// stdoutSink writes to stdout, which is never closed.
+type stdoutSink struct {
+	io.Writer
+}
+
+func newStdoutSink() io.WriteCloser {
+	return stdoutSink{os.Stdout}
+}
+
+func (stdoutSink) Close() error { return nil }
+
+func newSyslogSink(conf SyslogConfig) (io.WriteCloser, error) {
+	return syslog.Dial(conf.Network, conf.Address, syslog.LOG_INFO|syslog.LOG_LOCAL0, conf.Tag)
+}
+
+// RotatingFile is a file writer that renames the file with timestamp suffix once it grows beyond
+// the max size, and then writes to a new file. Note, it is not thread safe.
+type RotatingFile struct {
+	conf FileConfig
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(conf FileConfig) (*RotatingFile, error) {
+	if conf.MaxSizeMB <= 0 {
+		return nil, errors.New("max size of access log file must be positive")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(conf.Path), 0755); err != nil {
+		return nil, errors.WithMessage(err, "failed to create access log directory")
+	}
+
+	rf := &RotatingFile{conf: conf}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.conf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.WithMessage(err, "failed to open access log file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.WithMessage(err, "failed to stat access log file")
+	}
+
+	rf.file, rf.size = file, info.Size()
+
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.conf.MaxSizeMB<<20 {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+
+	return n, err
+}
+
+// rotate renames the current file as backup, and removes the stale backups if any.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	rf.file = nil
+
+	backup := rf.conf.Path + "." + time.Now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(rf.conf.Path, backup); err != nil {
+		return errors.WithMessage(err, "failed to rename access log file")
+	}
+
+	if err := rf.open(); err != nil {
+		return err
+	}
+
+	if rf.conf.MaxBackups <= 0 {
+		return nil
+	}
+
+	// backups are sorted by timestamp suffix
+	backups, err := filepath.Glob(rf.conf.Path + ".*")
+	if err != nil || len(backups) <= rf.conf.MaxBackups {
+		return nil
+	}
+
+	sort.Strings(backups)
+	for _, stale := range backups[:len(backups)-rf.conf.MaxBackups] {
+		os.Remove(stale)
+	}
+
+	return nil
+}
+
+func (rf *RotatingFile) Close() error {
+	if rf.file == nil {
+		return nil
+	}
+
+	err := rf.file.Close()
+	rf.file = nil
+
+	return err
+}
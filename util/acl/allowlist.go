@@ -1,8 +1,36 @@
 package acl
 
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
 const (
 	// pre-defined default allowlist name
 	DefaultAllowList = "default"
+	// pre-defined allowlist name for anonymous requests without any API key, which falls back
+	// to the default allowlist if not configured
+	AnonymousAllowList = "anonymous"
+)
+
+// BypassTier determines how requests are exempt from rate limit and compute units quota.
+type BypassTier string
+
+const (
+	// rate limit and compute units quota are enforced as usual
+	BypassTierStandard BypassTier = ""
+	// exempt from rate limit and quota, but usage is still metered and the requests that would
+	// have been rejected by key scope rate limit are tracked by metrics
+	BypassTierMonitored BypassTier = "monitored"
+	// fully exempt from rate limit, quota and usage metering, eg., internal services
+	BypassTierUnlimited BypassTier = "unlimited"
 )
 
 // AllowList access control rule list.
@@ -13,6 +41,11 @@ type AllowList struct {
 	// Any requests which query addresses that are not in the allowlist are rejected.
 	ContractAddresses []string
 
+	// Any requests which interact with the blocked contract addresses, either by call, simulation
+	// (eg., `eth_simulateV1`, `eth_callMany` or `debug_traceCall`) or raw transaction, are rejected,
+	// eg., sanctioned or abusive contracts.
+	BlockedContracts []string
+
 	// The allowed methods list. If the list is empty, all methods will be accpeted.
 	AllowMethods []string
 
@@ -24,6 +57,30 @@ type AllowList struct {
 
 	// Restricted `Origin` request headers
 	Origins []string
+
+	// Request and response size limits overriding the global ones
+	Limits *Limits
+
+	// CORS policy overriding the global or virtual host one, eg., allowed origins of dApp
+	Cors *CorsPolicy
+
+	// Node route group to which requests are pinned, eg., dedicated archive nodes for VIP
+	// customers, or the default group if empty.
+	RouteGroup string
+
+	// Whether to submit raw transactions to the private relays rather than the public mempool
+	// to protect from MEV, e.g., front-running.
+	PrivateTx bool
+
+	// Bypass tier to exempt requests from rate limit, or standard if empty.
+	BypassTier BypassTier
+
+	// Rules to strip or mask response fields per method, eg., to hide transaction input data
+	// or peer info from some tier.
+	Redactions []*Redaction
+
+	// Destinations of daily usage reports for the bound API keys, or not reported if nil.
+	Report *ReportPolicy
 }
 
 func NewAllowList(id uint32, name string) *AllowList {
@@ -32,3 +89,146 @@ func NewAllowList(id uint32, name string) *AllowList {
 		Name: name,
 	}
 }
+
+// ExplicitlyAllows checks if the RPC method is explicitly allowed by the allowed methods list,
+// which is used to opt in the restricted methods, eg., `txpool_content`.
+func (al *AllowList) ExplicitlyAllows(method string) bool {
+	for _, r := range al.AllowMethods {
+		matched, err := regexp.MatchString(util.WildCardToRegexp(r), method)
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsMethod checks if the RPC method is allowed by the allowed and disallowed methods list.
+func (al *AllowList) AllowsMethod(method string) bool {
+	return newValidatorBase(al).validateAllowMethods(Context{RpcMethod: method}) == nil
+}
+
+// allowListRules the accepted schema of allowlist rules config json.
+type allowListRules struct {
+	ContractAddresses []string
+	BlockedContracts  []string
+	AllowMethods      []string
+	DisallowMethods   []string
+	UserAgents        []string
+	Origins           []string
+	Limits            *Limits
+	Cors              *CorsPolicy
+	RouteGroup        string
+	PrivateTx         bool
+	BypassTier        BypassTier
+	Redactions        []*Redaction
+	Report            *ReportPolicy
+}
+
+// ParseAllowList strictly parses allowlist rules config json, and validates the rules
+// for the specified network space ("cfx" or "eth").
+func ParseAllowList(name, rules, network string) (*AllowList, error) {
+	if len(name) == 0 {
+		return nil, errors.New("name must not be empty")
+	}
+
+	var alr allowListRules
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(rules)))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&alr); err != nil {
+		return nil, errors.WithMessage(err, "invalid allowlist rules config json")
+	}
+
+	al := &AllowList{
+		Name:              name,
+		ContractAddresses: alr.ContractAddresses,
+		BlockedContracts:  alr.BlockedContracts,
+		AllowMethods:      alr.AllowMethods,
+		DisallowMethods:   alr.DisallowMethods,
+		UserAgents:        alr.UserAgents,
+		Origins:           alr.Origins,
+		Limits:            alr.Limits,
+		Cors:              alr.Cors,
+		RouteGroup:        alr.RouteGroup,
+		PrivateTx:         alr.PrivateTx,
+		BypassTier:        alr.BypassTier,
+		Redactions:        alr.Redactions,
+		Report:            alr.Report,
+	}
+
+	if err := al.Validate(network); err != nil {
+		return nil, err
+	}
+
+	return al, nil
+}
+
+// Validate validates the allowlist rules for the specified network space ("cfx" or "eth").
+func (al *AllowList) Validate(network string) error {
+	if len(al.AllowMethods) > 0 && len(al.DisallowMethods) > 0 {
+		return errors.New("The allow and disallow method sets can not be set at the same time")
+	}
+
+	if al.Limits != nil {
+		if err := al.Limits.Validate(); err != nil {
+			return errors.WithMessage(err, "invalid allowlist limits")
+		}
+	}
+
+	switch al.BypassTier {
+	case BypassTierStandard, BypassTierMonitored, BypassTierUnlimited:
+	default:
+		return errors.Errorf("invalid bypass tier %v", al.BypassTier)
+	}
+
+	if al.Cors != nil {
+		if err := al.Cors.Validate(); err != nil {
+			return errors.WithMessage(err, "invalid allowlist CORS policy")
+		}
+	}
+
+	for _, r := range al.Redactions {
+		if err := r.Validate(); err != nil {
+			return errors.WithMessage(err, "invalid allowlist redaction")
+		}
+	}
+
+	if al.Report != nil {
+		if err := al.Report.Validate(); err != nil {
+			return errors.WithMessage(err, "invalid allowlist report policy")
+		}
+	}
+
+	if err := validateContractAddresses(network, al.ContractAddresses); err != nil {
+		return errors.WithMessage(err, "invalid allowlist contract addresses")
+	}
+
+	if err := validateContractAddresses(network, al.BlockedContracts); err != nil {
+		return errors.WithMessage(err, "invalid blocked contract addresses")
+	}
+
+	return nil
+}
+
+func validateContractAddresses(network string, addresses []string) error {
+	if strings.EqualFold(network, "eth") {
+		for _, caddr := range addresses {
+			if !common.IsHexAddress(caddr) {
+				return errors.Errorf("%v is not a hex address", caddr)
+			}
+		}
+		return nil
+	}
+
+	if strings.EqualFold(network, "cfx") {
+		for _, ctAddr := range addresses {
+			if _, err := cfxaddress.NewFromBase32(ctAddr); err != nil {
+				return errors.WithMessagef(err, "%v is not a valid base32 string", ctAddr)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package acl
+
+import "github.com/pkg/errors"
+
+// CorsPolicy CORS policy for browser dApps, of which empty fields inherit from the global (or
+// virtual host) policy if overridden by allowlist.
+type CorsPolicy struct {
+	// Allowed origins, eg., `https://*.example.com`, or `*` to allow any origin
+	AllowedOrigins []string `json:",omitempty"`
+
+	// Allowed methods of cross-origin requests
+	AllowedMethods []string `json:",omitempty"`
+
+	// Allowed request headers of cross-origin requests, or `*` to allow any header
+	AllowedHeaders []string `json:",omitempty"`
+
+	// Response headers exposed to browsers
+	ExposedHeaders []string `json:",omitempty"`
+
+	// Seconds to cache preflight results by browsers, or not cached if zero
+	MaxAge int `json:",omitempty"`
+
+	// Whether to allow requests with credentials, eg., cookies
+	AllowCredentials bool `json:",omitempty"`
+}
+
+// Validate validates the max age is not negative.
+func (p *CorsPolicy) Validate() error {
+	if p.MaxAge < 0 {
+		return errors.New("CORS max age must not be negative")
+	}
+
+	return nil
+}
+
+// Override returns a copy of policy with non-empty fields overridden by the specified policy.
+func (p CorsPolicy) Override(other *CorsPolicy) *CorsPolicy {
+	if other == nil {
+		return &p
+	}
+
+	if len(other.AllowedOrigins) > 0 {
+		p.AllowedOrigins = other.AllowedOrigins
+	}
+
+	if len(other.AllowedMethods) > 0 {
+		p.AllowedMethods = other.AllowedMethods
+	}
+
+	if len(other.AllowedHeaders) > 0 {
+		p.AllowedHeaders = other.AllowedHeaders
+	}
+
+	if len(other.ExposedHeaders) > 0 {
+		p.ExposedHeaders = other.ExposedHeaders
+	}
+
+	if other.MaxAge > 0 {
+		p.MaxAge = other.MaxAge
+	}
+
+	if other.AllowCredentials {
+		p.AllowCredentials = true
+	}
+
+	return &p
+}
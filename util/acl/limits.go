@@ -0,0 +1,104 @@
+package acl
+
+import "github.com/pkg/errors"
+
+// Limits request and response size limits, of which zero value means no limit if globally
+// configured, or inheriting from global limits if overridden by allowlist.
+type Limits struct {
+	// Max bytes of HTTP request body
+	MaxRequestBodySize int `json:",omitempty"`
+
+	// Max number of items in a batch request
+	MaxBatchLength int `json:",omitempty"`
+
+	// Max block (or epoch) range of `getLogs` filter
+	MaxLogsBlockRange uint64 `json:",omitempty"`
+
+	// Max bytes of RPC response result
+	MaxResponseSize int `json:",omitempty"`
+
+	// Max number of concurrent websocket connections per access token (or IP if anonymous)
+	MaxWsConnections int `json:",omitempty"`
+
+	// Max number of subscriptions per websocket connection
+	MaxWsSubscriptions int `json:",omitempty"`
+
+	// Max number of messages per second per websocket connection
+	MaxWsMessagesPerSecond int `json:",omitempty"`
+
+	// Max bytes of websocket message (single or batch call), which falls back to the max HTTP
+	// request body size if not specified, and the connection is closed once exceeded
+	MaxWsMessageSize int `json:",omitempty"`
+
+	// Seconds to close websocket connection without any message or subscription
+	WsIdleTimeoutSecs int `json:",omitempty"`
+}
+
+// Validate validates the limits are not negative.
+func (l *Limits) Validate() error {
+	if l.MaxRequestBodySize < 0 || l.MaxBatchLength < 0 || l.MaxResponseSize < 0 {
+		return errors.New("limits must not be negative")
+	}
+
+	if l.MaxWsConnections < 0 || l.MaxWsSubscriptions < 0 ||
+		l.MaxWsMessagesPerSecond < 0 || l.MaxWsMessageSize < 0 || l.WsIdleTimeoutSecs < 0 {
+		return errors.New("websocket limits must not be negative")
+	}
+
+	return nil
+}
+
+// WsMessageSizeLimit returns the max bytes of websocket message, which is the same as HTTP
+// request body if not specified.
+func (l *Limits) WsMessageSizeLimit() int {
+	if l.MaxWsMessageSize > 0 {
+		return l.MaxWsMessageSize
+	}
+
+	return l.MaxRequestBodySize
+}
+
+// Override returns a copy of limits with non-zero fields overridden by the specified limits.
+func (l Limits) Override(other *Limits) *Limits {
+	if other == nil {
+		return &l
+	}
+
+	if other.MaxRequestBodySize > 0 {
+		l.MaxRequestBodySize = other.MaxRequestBodySize
+	}
+
+	if other.MaxBatchLength > 0 {
+		l.MaxBatchLength = other.MaxBatchLength
+	}
+
+	if other.MaxLogsBlockRange > 0 {
+		l.MaxLogsBlockRange = other.MaxLogsBlockRange
+	}
+
+	if other.MaxResponseSize > 0 {
+		l.MaxResponseSize = other.MaxResponseSize
+	}
+
+	if other.MaxWsConnections > 0 {
+		l.MaxWsConnections = other.MaxWsConnections
+	}
+
+	if other.MaxWsSubscriptions > 0 {
+		l.MaxWsSubscriptions = other.MaxWsSubscriptions
+	}
+
+	if other.MaxWsMessagesPerSecond > 0 {
+		l.MaxWsMessagesPerSecond = other.MaxWsMessagesPerSecond
+	}
+
+	if other.MaxWsMessageSize > 0 {
+		l.MaxWsMessageSize = other.MaxWsMessageSize
+	}
+
+	if other.WsIdleTimeoutSecs > 0 {
+		l.WsIdleTimeoutSecs = other.WsIdleTimeoutSecs
+	}
+
+	return &l
+}
@@ -0,0 +1,133 @@
+package acl
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/pkg/errors"
+)
+
+// Redaction rule to strip or mask fields of RPC method response, eg., to hide transaction input
+// data from some tier: {"Method": "eth_getTransactionBy*", "Fields": ["input"], "Mask": "0x"}.
+type Redaction struct {
+	// RPC method, which supports wildcard
+	Method string
+
+	// Field paths of response result separated by dot, where `*` matches all items of array or
+	// all fields of object, eg., `transactions.*.input`
+	Fields []string
+
+	// JSON value to replace the fields with, or fields are stripped if empty
+	Mask json.RawMessage `json:",omitempty"`
+}
+
+// Validate validates the method, field paths and mask value.
+func (r *Redaction) Validate() error {
+	if len(r.Method) == 0 {
+		return errors.New("redaction method must not be empty")
+	}
+
+	if len(r.Fields) == 0 {
+		return errors.Errorf("redaction fields of method %v must not be empty", r.Method)
+	}
+
+	for _, field := range r.Fields {
+		for _, key := range strings.Split(field, ".") {
+			if len(key) == 0 {
+				return errors.Errorf("invalid redaction field path %q", field)
+			}
+		}
+	}
+
+	if len(r.Mask) > 0 && !json.Valid(r.Mask) {
+		return errors.Errorf("redaction mask of method %v must be valid json", r.Method)
+	}
+
+	return nil
+}
+
+func (r *Redaction) matches(method string) bool {
+	matched, err := regexp.MatchString(util.WildCardToRegexp(r.Method), method)
+	return err == nil && matched
+}
+
+// Redact applies the redaction rules of RPC method to the response result, which is returned as
+// it is if no rule matched.
+func (al *AllowList) Redact(method string, result json.RawMessage) (json.RawMessage, bool, error) {
+	var rules []*Redaction
+	for _, r := range al.Redactions {
+		if r.matches(method) {
+			rules = append(rules, r)
+		}
+	}
+
+	if len(rules) == 0 {
+		return result, false, nil
+	}
+
+	// keep numbers as they are, eg., big integers
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false, errors.WithMessage(err, "failed to decode response result")
+	}
+
+	for _, r := range rules {
+		for _, field := range r.Fields {
+			value = redactPath(value, strings.Split(field, "."), r.Mask)
+		}
+	}
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to encode redacted response result")
+	}
+
+	return redacted, true, nil
+}
+
+// redactPath strips or masks the fields of specified path in the JSON value.
+func redactPath(value interface{}, path []string, mask json.RawMessage) interface{} {
+	key, rest := path[0], path[1:]
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if key != "*" && k != key {
+				continue
+			}
+
+			switch {
+			case len(rest) > 0:
+				node[k] = redactPath(child, rest, mask)
+			case len(mask) > 0:
+				node[k] = mask
+			default:
+				delete(node, k)
+			}
+		}
+	case []interface{}:
+		if key != "*" {
+			return value
+		}
+
+		items := node[:0]
+		for _, item := range node {
+			switch {
+			case len(rest) > 0:
+				items = append(items, redactPath(item, rest, mask))
+			case len(mask) > 0:
+				items = append(items, mask)
+			}
+		}
+
+		return items
+	}
+
+	return value
+}
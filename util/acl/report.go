@@ -0,0 +1,44 @@
+package acl
+
+import (
+	"net/mail"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// ReportPolicy destinations of daily usage reports for API keys bound to the allowlist, so that
+// customers could self-diagnose quota and error issues.
+type ReportPolicy struct {
+	// Webhook URL to which reports are posted in JSON
+	Webhook string `json:",omitempty"`
+
+	// Email addresses to which reports are sent
+	Emails []string `json:",omitempty"`
+}
+
+// Validate validates at least one valid destination is specified.
+func (p *ReportPolicy) Validate() error {
+	if len(p.Webhook) == 0 && len(p.Emails) == 0 {
+		return errors.New("either webhook or emails must be specified")
+	}
+
+	if len(p.Webhook) > 0 {
+		u, err := url.Parse(p.Webhook)
+		if err != nil {
+			return errors.WithMessage(err, "invalid webhook URL")
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return errors.Errorf("invalid webhook URL %v", p.Webhook)
+		}
+	}
+
+	for _, email := range p.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return errors.WithMessagef(err, "invalid email address %v", email)
+		}
+	}
+
+	return nil
+}
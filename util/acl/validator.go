@@ -2,7 +2,9 @@ package acl
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -11,6 +13,8 @@ import (
 	cfxTypes "github.com/Conflux-Chain/go-conflux-sdk/types"
 	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	web3Types "github.com/openweb3/web3go/types"
 	"github.com/sirupsen/logrus"
 )
@@ -23,12 +27,32 @@ var (
 	errBadRpcParams        = errors.New("bad RPC parameters")
 )
 
+const (
+	// JSON-RPC error code when request rejected by policy, eg., blocked contract
+	errCodePolicyRejected = -32003
+)
+
+// ContractBlockedError is returned if request interacts with the blocked contract address.
+type ContractBlockedError struct {
+	Address string
+}
+
+func (e *ContractBlockedError) Error() string {
+	return fmt.Sprintf("interaction with contract %v is blocked by policy", e.Address)
+}
+
+func (e *ContractBlockedError) ErrorCode() int {
+	return errCodePolicyRejected
+}
+
 // validation context
 type Context struct {
 	context.Context
 
 	RpcMethod        string
 	ExtractRpcParams func() ([]interface{}, error)
+	// raw positional params, eg., of methods forwarded to full node in raw without typed handler
+	RpcParams json.RawMessage
 }
 
 type ValidatorFactory func(al *AllowList) Validator
@@ -40,6 +64,14 @@ type Validator interface {
 // parse contract addresses from RPC method params
 type cntAddrParser func(params []interface{}) ([]string, bool)
 
+// parse target contract address of call or raw transaction from RPC method params, which
+// returns false if no target, eg., contract creation.
+type txTargetParser func(params []interface{}) (string, bool)
+
+// parse target contract addresses of calls from raw RPC method params, eg., simulated calls,
+// which returns false if params malformed.
+type rawTxTargetParser func(params json.RawMessage) ([]string, bool)
+
 // allowlist validator. Each allowlist type is "AND"ed together,
 // while multiple entries of the same type are "OR"ed.
 type validatorBase struct {
@@ -53,9 +85,20 @@ type validatorBase struct {
 	// contract addresses mapset
 	cntAddrRules map[string]bool
 
+	// blocked contract addresses mapset
+	blockedCntAddrRules map[string]bool
+
 	// contract address parsers by RPC method params:
 	// RPC method => cntAddrParser
 	cntAddrParsers map[string]cntAddrParser
+
+	// target contract address parsers by RPC method params:
+	// RPC method => txTargetParser
+	txTargetParsers map[string]txTargetParser
+
+	// target contract addresses parsers by raw RPC method params:
+	// RPC method => rawTxTargetParser
+	rawTxTargetParsers map[string]rawTxTargetParser
 }
 
 func newValidatorBase(al *AllowList) *validatorBase {
@@ -82,12 +125,18 @@ func newValidatorBase(al *AllowList) *validatorBase {
 		contractAddrRules[strings.ToLower(r)] = true
 	}
 
+	blockedContractAddrRules := make(map[string]bool)
+	for _, r := range al.BlockedContracts {
+		blockedContractAddrRules[strings.ToLower(r)] = true
+	}
+
 	return &validatorBase{
 		AllowList:           al,
 		originRules:         originRules,
 		allowMethodRules:    allowMethodRules,
 		disallowMethodRules: disallowMethodRules,
 		cntAddrRules:        contractAddrRules,
+		blockedCntAddrRules: blockedContractAddrRules,
 	}
 }
 
@@ -108,6 +157,10 @@ func (v *validatorBase) Validate(ctx Context) error {
 		return err
 	}
 
+	if err := v.validateBlockedContracts(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -218,6 +271,50 @@ func (v *validatorBase) validateContractAddresses(ctx Context) error {
 	return nil
 }
 
+// Validate the RPC methods which interact with contract by call or raw transaction, and reject
+// those targeting any blocked contract address.
+func (v *validatorBase) validateBlockedContracts(ctx Context) error {
+	if len(v.blockedCntAddrRules) == 0 {
+		return nil
+	}
+
+	if rawParser, ok := v.rawTxTargetParsers[ctx.RpcMethod]; ok {
+		targets, ok := rawParser(ctx.RpcParams)
+		if !ok {
+			return errBadRpcParams
+		}
+
+		for _, target := range targets {
+			if v.blockedCntAddrRules[strings.ToLower(target)] {
+				return &ContractBlockedError{Address: target}
+			}
+		}
+
+		return nil
+	}
+
+	if ctx.ExtractRpcParams == nil {
+		return nil
+	}
+
+	parser, ok := v.txTargetParsers[ctx.RpcMethod]
+	if !ok {
+		return nil
+	}
+
+	inputParams, err := ctx.ExtractRpcParams()
+	if err != nil {
+		return errBadRpcParams
+	}
+
+	target, ok := parser(inputParams)
+	if ok && v.blockedCntAddrRules[strings.ToLower(target)] {
+		return &ContractBlockedError{Address: target}
+	}
+
+	return nil
+}
+
 type EthValidator struct {
 	*validatorBase
 }
@@ -237,6 +334,18 @@ func NewEthValidator(al *AllowList) Validator {
 		"eth_getStorageAt":        v.parseAddr,
 	}
 
+	v.txTargetParsers = map[string]txTargetParser{
+		"eth_call":               v.parseCallTarget,
+		"eth_estimateGas":        v.parseCallTarget,
+		"eth_sendRawTransaction": v.parseRawTxTarget,
+	}
+
+	v.rawTxTargetParsers = map[string]rawTxTargetParser{
+		"eth_simulateV1":  v.parseSimulateV1Targets,
+		"eth_callMany":    v.parseCallManyTargets,
+		"debug_traceCall": v.parseTraceCallTargets,
+	}
+
 	for _, cntAddr := range v.ContractAddresses {
 		if !common.IsHexAddress(cntAddr) {
 			logrus.WithField("contractAddr", cntAddr).Warn("Invalid contract address for allowlist")
@@ -244,9 +353,120 @@ func NewEthValidator(al *AllowList) Validator {
 		}
 	}
 
+	for _, cntAddr := range v.BlockedContracts {
+		if !common.IsHexAddress(cntAddr) {
+			logrus.WithField("contractAddr", cntAddr).Warn("Invalid blocked contract address for allowlist")
+			delete(v.blockedCntAddrRules, strings.ToLower(cntAddr))
+		}
+	}
+
 	return v
 }
 
+func (v *EthValidator) parseCallTarget(params []interface{}) (string, bool) {
+	if len(params) == 0 {
+		return "", false
+	}
+
+	cr, ok := params[0].(web3Types.CallRequest)
+	if !ok || cr.To == nil {
+		return "", false
+	}
+
+	return cr.To.String(), true
+}
+
+// parseRawTxTarget decodes the signed transaction payload to get the target address.
+func (v *EthValidator) parseRawTxTarget(params []interface{}) (string, bool) {
+	if len(params) == 0 {
+		return "", false
+	}
+
+	rawTx, ok := params[0].(hexutil.Bytes)
+	if !ok {
+		return "", false
+	}
+
+	var tx ethTypes.Transaction
+	if err := tx.UnmarshalBinary(rawTx); err != nil || tx.To() == nil {
+		return "", false
+	}
+
+	return tx.To().String(), true
+}
+
+// ethCallTarget target contract address of call object in raw params, which is nil for contract
+// creation.
+type ethCallTarget struct {
+	To *common.Address `json:"to"`
+}
+
+// parseFirstRawParam decodes the first positional param in raw.
+func parseFirstRawParam(params json.RawMessage, v interface{}) bool {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(args[0], v) == nil
+}
+
+func collectCallTargets(calls []ethCallTarget, res []string) []string {
+	for _, call := range calls {
+		if call.To != nil {
+			res = append(res, call.To.String())
+		}
+	}
+
+	return res
+}
+
+// parseSimulateV1Targets parses targets of all calls in `blockStateCalls` of `eth_simulateV1`.
+func (v *EthValidator) parseSimulateV1Targets(params json.RawMessage) (res []string, ok bool) {
+	var opts struct {
+		BlockStateCalls []struct {
+			Calls []ethCallTarget `json:"calls"`
+		} `json:"blockStateCalls"`
+	}
+
+	if !parseFirstRawParam(params, &opts) {
+		return nil, false
+	}
+
+	for _, block := range opts.BlockStateCalls {
+		res = collectCallTargets(block.Calls, res)
+	}
+
+	return res, true
+}
+
+// parseCallManyTargets parses targets of all transactions in bundles of `eth_callMany`.
+func (v *EthValidator) parseCallManyTargets(params json.RawMessage) (res []string, ok bool) {
+	var bundles []struct {
+		Transactions []ethCallTarget `json:"transactions"`
+	}
+
+	if !parseFirstRawParam(params, &bundles) {
+		return nil, false
+	}
+
+	for _, bundle := range bundles {
+		res = collectCallTargets(bundle.Transactions, res)
+	}
+
+	return res, true
+}
+
+// parseTraceCallTargets parses target of the call object of `debug_traceCall`.
+func (v *EthValidator) parseTraceCallTargets(params json.RawMessage) ([]string, bool) {
+	var call ethCallTarget
+	if !parseFirstRawParam(params, &call) {
+		return nil, false
+	}
+
+	return collectCallTargets([]ethCallTarget{call}, nil), true
+}
+
 func (v *EthValidator) parseCallRequest(params []interface{}) (res []string, ok bool) {
 	if len(params) == 0 {
 		return
@@ -290,6 +510,9 @@ func (v *EthValidator) parseAddr(params []interface{}) (res []string, ok bool) {
 
 type CfxValidator struct {
 	*validatorBase
+
+	// network ID to decode address of raw transaction
+	networkId uint32
 }
 
 func NewCfxValidator(al *AllowList) Validator {
@@ -307,6 +530,12 @@ func NewCfxValidator(al *AllowList) Validator {
 		"cfx_getStorageAt":             v.parseAddr,
 	}
 
+	v.txTargetParsers = map[string]txTargetParser{
+		"cfx_call":                     v.parseCallTarget,
+		"cfx_estimateGasAndCollateral": v.parseCallTarget,
+		"cfx_sendRawTransaction":       v.parseRawTxTarget,
+	}
+
 	v.uniformContractAddrRulesets()
 	return v
 }
@@ -323,6 +552,52 @@ func (v *CfxValidator) uniformContractAddrRulesets() {
 
 		v.cntAddrRules[addr.MustGetBase32Address()] = true
 	}
+
+	v.blockedCntAddrRules = make(map[string]bool)
+
+	for _, cntAddr := range v.BlockedContracts {
+		addr, err := cfxaddress.NewFromBase32(cntAddr)
+		if err != nil {
+			logrus.WithField("contractAddr", cntAddr).Warn("Invalid blocked contract address for allowlist")
+			continue
+		}
+
+		v.networkId = addr.GetNetworkID()
+		v.blockedCntAddrRules[addr.MustGetBase32Address()] = true
+	}
+}
+
+func (v *CfxValidator) parseCallTarget(params []interface{}) (string, bool) {
+	if len(params) == 0 {
+		return "", false
+	}
+
+	cr, ok := params[0].(cfxTypes.CallRequest)
+	if !ok || cr.To == nil {
+		return "", false
+	}
+
+	return cr.To.MustGetBase32Address(), true
+}
+
+// parseRawTxTarget decodes the signed transaction payload to get the target address, which is
+// encoded with the network ID of blocked contract addresses.
+func (v *CfxValidator) parseRawTxTarget(params []interface{}) (string, bool) {
+	if len(params) == 0 {
+		return "", false
+	}
+
+	rawTx, ok := params[0].(hexutil.Bytes)
+	if !ok {
+		return "", false
+	}
+
+	var tx cfxTypes.SignedTransaction
+	if err := tx.Decode(rawTx, v.networkId); err != nil || tx.UnsignedTransaction.To == nil {
+		return "", false
+	}
+
+	return tx.UnsignedTransaction.To.MustGetBase32Address(), true
 }
 
 func (v *CfxValidator) parseCallRequest(params []interface{}) (res []string, ok bool) {
@@ -0,0 +1,246 @@
+// Package analytics publishes sanitized request metadata to an event stream (eg., Kafka or NATS)
+// asynchronously, so that usage analytics could be built without scraping access logs.
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// supported event sinks
+	SinkKafka = "kafka"
+	SinkNats  = "nats"
+
+	// request status of event
+	StatusSuccess = "success"
+	StatusError   = "error"
+
+	// caller of request without access token
+	anonymousCaller = "anonymous"
+)
+
+// Config event pipeline configurations.
+type Config struct {
+	// switch to turn on/off event publishing
+	Enabled bool
+	// event sink, available sinks are `kafka` and `nats`
+	Sink string
+	// Kafka REST proxy to publish events
+	Kafka KafkaConfig
+	// NATS server to publish events
+	Nats NatsConfig
+	// max number of events buffered, beyond which events are dropped
+	BufferSize int `default:"10000"`
+	// max number of events published in a batch
+	BatchSize int `default:"100"`
+	// interval to publish buffered events
+	FlushInterval time.Duration `default:"1s"`
+	// salt to hash access token as caller identity
+	Salt string
+}
+
+// Event sanitized metadata of an RPC request, without any raw access token or parameter.
+type Event struct {
+	Time       int64  `json:"time"`            // unix timestamp in milliseconds
+	Chain      string `json:"chain,omitempty"` // additional chain served by the gateway
+	Method     string `json:"method"`
+	ParamsHash string `json:"paramsHash,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Status     string `json:"status"`
+	ErrorCode  int    `json:"errorCode,omitempty"`
+	Caller     string `json:"caller"` // salted hash of access token
+	Node       string `json:"node,omitempty"`
+}
+
+// Publisher publishes events to the event stream.
+type Publisher interface {
+	Publish(events []*Event) error
+	Close() error
+}
+
+// Pipeline buffers events and publishes them in batch asynchronously, so that request handling
+// is never blocked by the event stream.
+type Pipeline struct {
+	conf      *Config
+	publisher Publisher
+	events    chan *Event
+	closeOnce sync.Once
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// MustNewPipelineFromViper creates event pipeline from viper, or nil if disabled.
+func MustNewPipelineFromViper() *Pipeline {
+	var conf Config
+	viper.MustUnmarshalKey("analytics", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	var publisher Publisher
+
+	switch conf.Sink {
+	case SinkKafka:
+		publisher = NewKafkaPublisher(conf.Kafka)
+	case SinkNats:
+		natsPublisher, err := NewNatsPublisher(conf.Nats)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create analytics NATS publisher")
+		}
+
+		publisher = natsPublisher
+	default:
+		logrus.WithField("sink", conf.Sink).Fatal("Unsupported analytics event sink")
+	}
+
+	logrus.WithField("config", conf).Info("Analytics event pipeline enabled")
+
+	return NewPipeline(&conf, publisher)
+}
+
+func NewPipeline(conf *Config, publisher Publisher) *Pipeline {
+	p := &Pipeline{
+		conf:      conf,
+		publisher: publisher,
+		events:    make(chan *Event, conf.BufferSize),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go p.loop()
+
+	return p
+}
+
+// Submit enqueues event to publish, which is dropped if buffer is full.
+func (p *Pipeline) Submit(event *Event) {
+	select {
+	case p.events <- event:
+	default:
+		metrics.Registry.RPC.AnalyticsEvents("dropped").Mark(1)
+	}
+}
+
+// Close publishes the buffered events and closes the publisher.
+func (p *Pipeline) Close() {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		<-p.done
+		p.publisher.Close()
+	})
+}
+
+func (p *Pipeline) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.conf.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, p.conf.BatchSize)
+
+	for {
+		select {
+		case event := <-p.events:
+			batch = p.add(batch, event)
+		case <-ticker.C:
+			batch = p.publish(batch)
+		case <-p.quit:
+			for { // drain the buffered events
+				select {
+				case event := <-p.events:
+					batch = p.add(batch, event)
+				default:
+					p.publish(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// add adds event into batch, which is published once full.
+func (p *Pipeline) add(batch []*Event, event *Event) []*Event {
+	if batch = append(batch, event); len(batch) >= p.conf.BatchSize {
+		return p.publish(batch)
+	}
+
+	return batch
+}
+
+// publish publishes the batch of events, and returns the emptied batch for reuse.
+func (p *Pipeline) publish(batch []*Event) []*Event {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := p.publisher.Publish(batch); err != nil {
+		logrus.WithField("events", len(batch)).WithError(err).Info("Failed to publish analytics events")
+		metrics.Registry.RPC.AnalyticsEvents("failed").Mark(int64(len(batch)))
+	} else {
+		metrics.Registry.RPC.AnalyticsEvents("published").Mark(int64(len(batch)))
+	}
+
+	return batch[:0]
+}
+
+// hash returns the hex encoded hash of data with salt, which is truncated to 16 bytes.
+func (p *Pipeline) hash(data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(p.conf.Salt))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Call publishes event of RPC request once responded, which passes through if pipeline is nil.
+func (p *Pipeline) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if p == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		start := time.Now()
+
+		ctx = handlers.WithUpstreamRecorder(ctx)
+		resp := next(ctx, msg)
+
+		event := &Event{
+			Time:      start.UnixNano() / int64(time.Millisecond),
+			Method:    msg.Method,
+			LatencyMs: int64(time.Since(start) / time.Millisecond),
+			Caller:    anonymousCaller,
+		}
+
+		event.Node, _ = handlers.GetUpstreamFromContext(ctx)
+		event.Chain, _ = handlers.GetChainFromContext(ctx)
+
+		if token, ok := handlers.GetAccessTokenFromContext(ctx); ok && len(token) > 0 {
+			event.Caller = p.hash([]byte(token))
+		}
+
+		if len(msg.Params) > 0 {
+			event.ParamsHash = p.hash(msg.Params)
+		}
+
+		if resp != nil && resp.Error != nil {
+			event.Status, event.ErrorCode = StatusError, resp.Error.Code
+		} else {
+			event.Status = StatusSuccess
+		}
+
+		p.Submit(event)
+
+		return resp
+	}
+}
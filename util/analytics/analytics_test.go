@@ -0,0 +1,148 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type memPublisher struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (p *memPublisher) Publish(events []*Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range events {
+		copied := *e
+		p.events = append(p.events, &copied)
+	}
+
+	return nil
+}
+
+func (p *memPublisher) Close() error { return nil }
+
+func TestPipelineCall(t *testing.T) {
+	publisher := &memPublisher{}
+	pipeline := NewPipeline(&Config{
+		BufferSize: 10, BatchSize: 2, FlushInterval: time.Hour, Salt: "salt",
+	}, publisher)
+
+	handler := pipeline.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		handlers.RecordUpstream(ctx, "node1")
+
+		if msg.Method == "eth_call" {
+			return msg.ErrorResponse(&rpc.JsonError{Code: 3, Message: "execution reverted"})
+		}
+
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x1"`)}
+	})
+
+	ctx := context.WithValue(context.Background(), handlers.CtxKeyAccessToken, "secretToken")
+	handler(ctx, &rpc.JsonRpcMessage{Method: "eth_getBalance", Params: json.RawMessage(`["0x1"]`)})
+	handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"})
+	handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_chainId"})
+
+	// the last event is published on close
+	pipeline.Close()
+
+	assert.Len(t, publisher.events, 3)
+
+	e := publisher.events[0]
+	assert.Equal(t, "eth_getBalance", e.Method)
+	assert.Equal(t, StatusSuccess, e.Status)
+	assert.Equal(t, "node1", e.Node)
+	assert.Len(t, e.Caller, 32)
+	assert.NotContains(t, e.Caller, "secretToken")
+	assert.Len(t, e.ParamsHash, 32)
+
+	e = publisher.events[1]
+	assert.Equal(t, anonymousCaller, e.Caller)
+	assert.Equal(t, StatusError, e.Status)
+	assert.Equal(t, 3, e.ErrorCode)
+	assert.Empty(t, e.ParamsHash)
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var body map[string][]map[string]*Event
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/rpc.events", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	publisher := NewKafkaPublisher(KafkaConfig{Url: server.URL + "/", Topic: "rpc.events", Timeout: time.Second})
+	defer publisher.Close()
+
+	err := publisher.Publish([]*Event{{Method: "eth_call"}, {Method: "eth_chainId"}})
+	assert.NoError(t, err)
+	assert.Len(t, body["records"], 2)
+	assert.Equal(t, "eth_chainId", body["records"][1]["value"].Method)
+}
+
+func TestNatsPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 10)
+
+	// fake NATS server
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte(`INFO {"server_id":"test","max_payload":1048576}` + "\r\n"))
+
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB"):
+				payload, _ := reader.ReadString('\n')
+				received <- strings.TrimSpace(line) + " " + strings.TrimSpace(payload)
+			}
+		}
+	}()
+
+	publisher, err := NewNatsPublisher(NatsConfig{
+		Url: "nats://" + listener.Addr().String(), Subject: "rpc.events", Timeout: time.Second,
+	})
+	assert.NoError(t, err)
+	defer publisher.Close()
+
+	assert.NoError(t, publisher.Publish([]*Event{{Method: "eth_call", Status: StatusSuccess}}))
+
+	select {
+	case msg := <-received:
+		assert.True(t, strings.HasPrefix(msg, "PUB rpc.events "))
+		assert.Contains(t, msg, `"method":"eth_call"`)
+	case <-time.After(time.Second):
+		t.Fatal("event not published")
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -17,9 +18,12 @@ type expirableValue struct {
 // This cache uses a lazy eviction policy, by which the expired entry will be purged when
 // it's being looked up.
 type ExpirableLruCache struct {
-	lru *lru.Cache
-	mu  sync.Mutex
-	ttl time.Duration
+	bytes int64 // total size of cached values in bytes, accessed atomically
+
+	lru   *lru.Cache
+	mu    sync.Mutex
+	ttl   time.Duration
+	sizer func(key, value interface{}) int64 // nil if size in bytes not tracked
 }
 
 func NewExpirableLruCache(size int, ttl time.Duration) *ExpirableLruCache {
@@ -27,6 +31,24 @@ func NewExpirableLruCache(size int, ttl time.Duration) *ExpirableLruCache {
 	return &ExpirableLruCache{lru: cache, ttl: ttl}
 }
 
+// NewSizedExpirableLruCache creates an LRU cache which also tracks the total size in bytes of
+// cached entries measured by sizer, eg., to account the cache for memory budget.
+func NewSizedExpirableLruCache(
+	size int, ttl time.Duration, sizer func(key, value interface{}) int64,
+) *ExpirableLruCache {
+	c := &ExpirableLruCache{ttl: ttl, sizer: sizer}
+	c.lru, _ = lru.NewWithEvict(size, func(key, value interface{}) {
+		atomic.AddInt64(&c.bytes, -sizer(key, value.(*expirableValue).value))
+	})
+
+	return c
+}
+
+// Bytes returns the total size in bytes of cached entries, which is always 0 if not sized.
+func (c *ExpirableLruCache) Bytes() int64 {
+	return atomic.LoadInt64(&c.bytes)
+}
+
 // Add adds a value to the cache. Returns true if an eviction occurred.
 func (c *ExpirableLruCache) Add(key, value interface{}) bool {
 	c.mu.Lock()
@@ -37,6 +59,15 @@ func (c *ExpirableLruCache) Add(key, value interface{}) bool {
 		expiresAt: time.Now().Add(c.ttl),
 	}
 
+	if c.sizer != nil {
+		// value replaced without eviction callback
+		if old, ok := c.lru.Peek(key); ok {
+			atomic.AddInt64(&c.bytes, -c.sizer(key, old.(*expirableValue).value))
+		}
+
+		atomic.AddInt64(&c.bytes, c.sizer(key, value))
+	}
+
 	return c.lru.Add(key, ev)
 }
 
@@ -60,6 +91,43 @@ func (c *ExpirableLruCache) Get(key interface{}) (v interface{}, found bool) {
 	return ev.value, true
 }
 
+// ContainsOrAdd checks if the key is in the cache without updating the recent-ness or
+// deleting it for being stale, and if not, adds the value. Returns whether the key found,
+// which is treated as not found if expired.
+func (c *ExpirableLruCache) ContainsOrAdd(key, value interface{}) (found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cv, ok := c.lru.Peek(key); ok && !cv.(*expirableValue).expiresAt.Before(time.Now()) {
+		return true
+	}
+
+	ev := &expirableValue{
+		value:     value,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+
+	if c.sizer != nil {
+		if old, ok := c.lru.Peek(key); ok {
+			atomic.AddInt64(&c.bytes, -c.sizer(key, old.(*expirableValue).value))
+		}
+
+		atomic.AddInt64(&c.bytes, c.sizer(key, value))
+	}
+
+	c.lru.Add(key, ev)
+
+	return false
+}
+
+// Remove removes the key from the cache, and returns true if the key was contained.
+func (c *ExpirableLruCache) Remove(key interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Remove(key)
+}
+
 // GetNoExp looks up a key's value from the cache without expiration action.
 func (c *ExpirableLruCache) GetNoExp(key interface{}) (v interface{}, expired, found bool) {
 	c.mu.Lock()
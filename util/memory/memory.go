@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+// Pressure is the level of memory pressure against the memory ceiling.
+type Pressure int
+
+const (
+	// PressureNone memory usage below the high watermark
+	PressureNone Pressure = iota
+	// PressureHigh memory usage beyond the high watermark, but below the ceiling
+	PressureHigh
+	// PressureCritical memory usage beyond the ceiling
+	PressureCritical
+)
+
+func (p Pressure) String() string {
+	switch p {
+	case PressureHigh:
+		return "high"
+	case PressureCritical:
+		return "critical"
+	default:
+		return "none"
+	}
+}
+
+// Config configurations of the memory accountant.
+type Config struct {
+	// switch to turn on/off memory accounting
+	Enabled bool
+	// memory ceiling in bytes, eg., 80% of the container memory limit
+	Ceiling int64
+	// ratio of the ceiling beyond which memory is under high pressure
+	HighWatermark float64 `default:"0.8"`
+	// interval to sample the heap memory in use, 0 means never sampled
+	SampleInterval time.Duration `default:"1s"`
+}
+
+func (conf *Config) validate() bool {
+	return conf.Ceiling > 0 && conf.HighWatermark > 0 && conf.HighWatermark <= 1 && conf.SampleInterval >= 0
+}
+
+var (
+	cacheMu sync.Mutex
+	caches  = make(map[string]func() int64) // cache name => size in bytes
+)
+
+// RegisterCache registers an in-memory cache by name, whose size in bytes is accounted as
+// memory usage, which could be registered before or after the accountant initialized.
+func RegisterCache(name string, size func() int64) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	caches[name] = size
+}
+
+func cacheBytes() (total int64) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	for _, size := range caches {
+		total += size()
+	}
+
+	return total
+}
+
+// Accountant tracks the bytes of buffered requests and responses, the size of registered caches
+// and the sampled heap memory in use, against the configured memory ceiling. Bytes are reserved
+// in advance (eg., the estimated response size of `eth_getLogs`), so that memory pressure rises
+// before the memory actually allocated under request storms.
+type Accountant struct {
+	conf Config
+
+	buffered int64 // bytes of buffered requests and responses, accessed atomically
+	heap     int64 // sampled bytes of heap in use, accessed atomically
+
+	mu       sync.Mutex
+	released chan struct{} // closed and renewed once memory released if any waiter
+	waiting  bool
+}
+
+// defaultAccountant is the accountant fed by RPC servers, nil if disabled.
+var defaultAccountant *Accountant
+
+// MustInitFromViper creates the default memory accountant from viper and starts to sample the
+// heap memory if enabled.
+func MustInitFromViper() {
+	var conf Config
+	viper.MustUnmarshalKey("rpc.memoryBudget", &conf)
+
+	if !conf.Enabled {
+		return
+	}
+
+	if !conf.validate() {
+		logrus.WithField("config", conf).Fatal("Invalid memory budget config")
+	}
+
+	defaultAccountant = NewAccountant(conf)
+
+	if conf.SampleInterval > 0 {
+		go defaultAccountant.run()
+	}
+
+	logrus.WithField("config", conf).Info("Memory budget enabled")
+}
+
+func NewAccountant(conf Config) *Accountant {
+	return &Accountant{conf: conf, released: make(chan struct{})}
+}
+
+// Default returns the default memory accountant, or nil if disabled.
+func Default() *Accountant {
+	return defaultAccountant
+}
+
+// Reserve accounts the bytes to buffer, which should be released once freed. It is a no-op if
+// the accountant is nil.
+func (a *Accountant) Reserve(n int64) {
+	if a != nil && n > 0 {
+		atomic.AddInt64(&a.buffered, n)
+	}
+}
+
+// Release releases the reserved bytes, and wakes up waiters for memory. It is a no-op if the
+// accountant is nil.
+func (a *Accountant) Release(n int64) {
+	if a == nil || n <= 0 {
+		return
+	}
+
+	atomic.AddInt64(&a.buffered, -n)
+	a.notify()
+}
+
+func (a *Accountant) notify() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.waiting {
+		close(a.released)
+		a.released = make(chan struct{})
+		a.waiting = false
+	}
+}
+
+// Usage returns the memory usage in bytes, which is the larger one of the accounted bytes (both
+// buffered and cached) and the sampled heap memory in use.
+func (a *Accountant) Usage() int64 {
+	if a == nil {
+		return 0
+	}
+
+	usage := atomic.LoadInt64(&a.buffered) + cacheBytes()
+	if heap := atomic.LoadInt64(&a.heap); heap > usage {
+		return heap
+	}
+
+	return usage
+}
+
+// Pressure returns the current memory pressure, which is always none if the accountant is nil.
+func (a *Accountant) Pressure() Pressure {
+	if a == nil {
+		return PressureNone
+	}
+
+	return a.pressure(a.Usage())
+}
+
+func (a *Accountant) pressure(usage int64) Pressure {
+	switch {
+	case usage >= a.conf.Ceiling:
+		return PressureCritical
+	case float64(usage) >= float64(a.conf.Ceiling)*a.conf.HighWatermark:
+		return PressureHigh
+	default:
+		return PressureNone
+	}
+}
+
+// Wait waits until memory pressure drops below the high watermark, and returns false if timed
+// out or context done.
+func (a *Accountant) Wait(ctx context.Context, timeout time.Duration) bool {
+	if a == nil {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		a.mu.Lock()
+
+		if a.Pressure() == PressureNone {
+			a.mu.Unlock()
+			return true
+		}
+
+		released := a.released
+		a.waiting = true
+
+		a.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// run samples the heap memory in use periodically, since reading memory stats stops the world.
+func (a *Accountant) run() {
+	ticker := time.NewTicker(a.conf.SampleInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.sample()
+	}
+}
+
+func (a *Accountant) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	prev := atomic.SwapInt64(&a.heap, int64(stats.HeapInuse))
+	if int64(stats.HeapInuse) < prev { // memory freed by GC
+		a.notify()
+	}
+
+	buffered, cached := atomic.LoadInt64(&a.buffered), cacheBytes()
+
+	metrics.Registry.RPC.MemoryUsage("buffered").Update(buffered)
+	metrics.Registry.RPC.MemoryUsage("cache").Update(cached)
+	metrics.Registry.RPC.MemoryUsage("heap").Update(int64(stats.HeapInuse))
+}
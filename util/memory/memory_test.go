@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountantPressure(t *testing.T) {
+	a := NewAccountant(Config{Ceiling: 100, HighWatermark: 0.8})
+	assert.Equal(t, PressureNone, a.Pressure())
+
+	a.Reserve(80)
+	assert.Equal(t, PressureHigh, a.Pressure())
+
+	RegisterCache("test", func() int64 { return 20 })
+	defer RegisterCache("test", func() int64 { return 0 })
+
+	assert.Equal(t, int64(100), a.Usage())
+	assert.Equal(t, PressureCritical, a.Pressure())
+
+	a.Release(80)
+	assert.Equal(t, PressureNone, a.Pressure())
+
+	// sampled heap memory in use
+	a.heap = 90
+	assert.Equal(t, int64(90), a.Usage())
+	assert.Equal(t, PressureHigh, a.Pressure())
+
+	// pass through if disabled
+	var disabled *Accountant
+	disabled.Reserve(1000)
+	assert.Equal(t, PressureNone, disabled.Pressure())
+	assert.True(t, disabled.Wait(context.Background(), time.Millisecond))
+}
+
+func TestAccountantWait(t *testing.T) {
+	a := NewAccountant(Config{Ceiling: 100, HighWatermark: 0.5})
+	a.Reserve(60)
+
+	// timed out
+	assert.False(t, a.Wait(context.Background(), 10*time.Millisecond))
+
+	// woken up once released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		a.Release(20)
+	}()
+
+	assert.True(t, a.Wait(context.Background(), time.Second))
+
+	// context done
+	a.Reserve(20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, a.Wait(ctx, time.Second))
+}
@@ -0,0 +1,366 @@
+// Package metering accounts the compute units consumed by API keys, which are aggregated daily
+// per RPC method and persisted for billing or capping purpose. Besides, failed requests of API
+// keys are aggregated daily per error code for usage reports.
+package metering
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DateLayout layout of the UTC date which usages are aggregated by.
+	DateLayout = "2006-01-02"
+
+	// MaxApiKeyLength max length of API key to meter, which is bounded by the store column.
+	MaxApiKeyLength = 128
+	// MaxMethodLength max length of RPC method to meter, which is bounded by the store column.
+	MaxMethodLength = 128
+)
+
+// Usage aggregated usage of an API key for an RPC method in a day.
+type Usage struct {
+	ApiKey       string `json:"apiKey"`
+	Date         string `json:"date"` // UTC date in format of `DateLayout`
+	Method       string `json:"method"`
+	Requests     uint64 `json:"requests"`
+	ComputeUnits uint64 `json:"computeUnits"`
+}
+
+// Failure aggregated failed requests of an API key by JSON-RPC error code in a day, including
+// the ones rejected by rate limit or quota.
+type Failure struct {
+	ApiKey   string `json:"apiKey"`
+	Date     string `json:"date"` // UTC date in format of `DateLayout`
+	Code     int    `json:"code"`
+	Requests uint64 `json:"requests"`
+}
+
+// Store persists daily usage and failure aggregates.
+type Store interface {
+	// AddUsages accumulates the usages onto the persisted daily aggregates.
+	AddUsages(usages []*Usage) error
+	// LoadUsages loads daily usage aggregates of the API key between dates inclusively.
+	LoadUsages(apiKey, fromDate, toDate string) ([]*Usage, error)
+	// AddFailures accumulates the failures onto the persisted daily aggregates.
+	AddFailures(failures []*Failure) error
+	// LoadFailures loads daily failure aggregates of the API key between dates inclusively.
+	LoadFailures(apiKey, fromDate, toDate string) ([]*Failure, error)
+}
+
+// Config usage metering configurations.
+type Config struct {
+	// switch to turn on/off usage metering
+	Enabled bool
+	// compute units of RPC method not weighted
+	DefaultWeight uint64 `default:"1"`
+	// compute units by RPC method, of which the name is case insensitive
+	Weights map[string]uint64
+	// interval to flush aggregated usages to store
+	FlushInterval time.Duration `default:"1m"`
+	// max flush attempts of aggregates that failed to persist individually while the others
+	// succeeded, eg., rejected by store constraints, which are dropped then
+	MaxFlushRetries int `default:"3"`
+}
+
+type usageKey struct {
+	apiKey, date, method string
+}
+
+type failureKey struct {
+	apiKey, date string
+	code         int
+}
+
+// Meter aggregates usages in memory, and flushes them to store periodically.
+type Meter struct {
+	conf  *Config
+	store Store
+
+	mu       sync.Mutex
+	pending  map[usageKey]*Usage
+	failures map[failureKey]*Failure
+
+	// flush attempts of the aggregates rejected individually by store
+	usageRetries   map[usageKey]int
+	failureRetries map[failureKey]int
+
+	quotaMu  sync.Mutex
+	quotas   map[string]*Quota       // quota name => quota
+	consumed map[string]*consumption // api key => consumption
+	alerted  map[alertKey]string     // api key and period => quota period alerted in soft mode
+
+	// loads consumptions from store without quota lock held, deduplicated per API key
+	loads singleflight.Group
+}
+
+// MustNewMeterFromViper creates usage meter from viper, or returns false if disabled.
+func MustNewMeterFromViper(store Store) (*Meter, bool) {
+	var conf Config
+	viper.MustUnmarshalKey("metering", &conf)
+
+	if !conf.Enabled {
+		return nil, false
+	}
+
+	return NewMeter(&conf, store), true
+}
+
+func NewMeter(conf *Config, store Store) *Meter {
+	// viper lowercases map keys, so do the same for literal config
+	weights := make(map[string]uint64, len(conf.Weights))
+	for method, weight := range conf.Weights {
+		weights[strings.ToLower(method)] = weight
+	}
+
+	conf.Weights = weights
+
+	return &Meter{
+		conf:           conf,
+		store:          store,
+		pending:        make(map[usageKey]*Usage),
+		failures:       make(map[failureKey]*Failure),
+		usageRetries:   make(map[usageKey]int),
+		failureRetries: make(map[failureKey]int),
+		quotas:         make(map[string]*Quota),
+		consumed:       make(map[string]*consumption),
+		alerted:        make(map[alertKey]string),
+	}
+}
+
+// Weight returns the compute units of the RPC method.
+func (m *Meter) Weight(method string) uint64 {
+	if weight, ok := m.conf.Weights[strings.ToLower(method)]; ok {
+		return weight
+	}
+
+	return m.conf.DefaultWeight
+}
+
+// Record accounts a request of the RPC method for the API key, which is ignored if either the API
+// key or method is too long to persist.
+func (m *Meter) Record(apiKey, method string) {
+	if len(apiKey) > MaxApiKeyLength || len(method) > MaxMethodLength {
+		return
+	}
+
+	date := time.Now().UTC().Format(DateLayout)
+	weight := m.Weight(method)
+
+	m.add(&Usage{
+		ApiKey:       apiKey,
+		Date:         date,
+		Method:       method,
+		Requests:     1,
+		ComputeUnits: weight,
+	})
+
+	m.consume(apiKey, date, weight)
+}
+
+func (m *Meter) add(usages ...*Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range usages {
+		key := usageKey{u.ApiKey, u.Date, u.Method}
+
+		if agg, ok := m.pending[key]; ok {
+			agg.Requests += u.Requests
+			agg.ComputeUnits += u.ComputeUnits
+		} else {
+			m.pending[key] = u
+		}
+	}
+}
+
+// RecordFailure accounts a failed request of the JSON-RPC error code for the API key, which is
+// ignored if the API key is too long to persist.
+func (m *Meter) RecordFailure(apiKey string, code int) {
+	if len(apiKey) > MaxApiKeyLength {
+		return
+	}
+
+	m.addFailures(&Failure{
+		ApiKey:   apiKey,
+		Date:     time.Now().UTC().Format(DateLayout),
+		Code:     code,
+		Requests: 1,
+	})
+}
+
+func (m *Meter) addFailures(failures ...*Failure) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range failures {
+		key := failureKey{f.ApiKey, f.Date, f.Code}
+
+		if agg, ok := m.failures[key]; ok {
+			agg.Requests += f.Requests
+		} else {
+			m.failures[key] = f
+		}
+	}
+}
+
+// Flush persists the aggregated usages and failures to store, which will be retried in the next
+// round if failed. If a batch fails, the aggregates are persisted one by one to isolate the ones
+// rejected by store, which are dropped after the max flush retries.
+func (m *Meter) Flush() error {
+	err := m.flushUsages()
+
+	if ferr := m.flushFailures(); err == nil {
+		err = ferr
+	}
+
+	return err
+}
+
+func (m *Meter) flushUsages() error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[usageKey]*Usage)
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	usages := make([]*Usage, 0, len(pending))
+	for _, u := range pending {
+		usages = append(usages, u)
+	}
+
+	failed, err := persistIsolated(len(usages), func(from, to int) error {
+		return m.store.AddUsages(usages[from:to])
+	})
+
+	if len(failed) == len(usages) { // store unavailable
+		m.add(usages...)
+		return err
+	}
+
+	var retries []*Usage
+
+	m.mu.Lock()
+	for i, u := range usages {
+		key := usageKey{u.ApiKey, u.Date, u.Method}
+
+		switch m.usageRetries[key]++; {
+		case !failed[i]:
+			delete(m.usageRetries, key)
+		case m.usageRetries[key] < m.conf.MaxFlushRetries:
+			retries = append(retries, u)
+		default:
+			delete(m.usageRetries, key)
+			logrus.WithField("usage", u).Error("Usage dropped due to too many flush failures")
+		}
+	}
+	m.mu.Unlock()
+
+	m.add(retries...)
+	m.resetConsumptions()
+
+	return err
+}
+
+func (m *Meter) flushFailures() error {
+	m.mu.Lock()
+	pending := m.failures
+	m.failures = make(map[failureKey]*Failure)
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	failures := make([]*Failure, 0, len(pending))
+	for _, f := range pending {
+		failures = append(failures, f)
+	}
+
+	failed, err := persistIsolated(len(failures), func(from, to int) error {
+		return m.store.AddFailures(failures[from:to])
+	})
+
+	if len(failed) == len(failures) { // store unavailable
+		m.addFailures(failures...)
+		return err
+	}
+
+	var retries []*Failure
+
+	m.mu.Lock()
+	for i, f := range failures {
+		key := failureKey{f.ApiKey, f.Date, f.Code}
+
+		switch m.failureRetries[key]++; {
+		case !failed[i]:
+			delete(m.failureRetries, key)
+		case m.failureRetries[key] < m.conf.MaxFlushRetries:
+			retries = append(retries, f)
+		default:
+			delete(m.failureRetries, key)
+			logrus.WithField("failure", f).Error("Failure dropped due to too many flush failures")
+		}
+	}
+	m.mu.Unlock()
+
+	m.addFailures(retries...)
+
+	return err
+}
+
+// persistIsolated persists n aggregates in batch, or one by one if the batch failed so as to isolate
+// the ones rejected by store, eg., due to constraints violated. Returns the failed ones by index, of
+// which all are regarded as failed if store unavailable, eg., none persisted one by one either.
+func persistIsolated(n int, persist func(from, to int) error) (map[int]bool, error) {
+	err := persist(0, n)
+	if err == nil {
+		return nil, nil
+	}
+
+	failed := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		if persist(i, i+1) != nil {
+			failed[i] = true
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	return failed, errors.WithMessagef(err, "%v out of %v aggregates failed to persist", len(failed), n)
+}
+
+// Run flushes usages periodically until context done, and then flushes the remaining ones.
+func (m *Meter) Run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(m.conf.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.Flush(); err != nil {
+				logrus.WithError(err).Error("Failed to flush usages on shutdown")
+			}
+			return
+		case <-ticker.C:
+			if err := m.Flush(); err != nil {
+				logrus.WithError(err).Warn("Failed to flush usages")
+			}
+		}
+	}
+}
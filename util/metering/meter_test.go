@@ -0,0 +1,288 @@
+package metering
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	fail     bool
+	rejected string // API key of aggregates rejected by store
+	usages   map[usageKey]*Usage
+	failures map[failureKey]*Failure
+
+	loads   int32         // number of usages loaded
+	loading chan struct{} // blocks loading usages until closed if not nil
+}
+
+func (s *memStore) AddUsages(usages []*Usage) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+
+	for _, u := range usages {
+		if u.ApiKey == s.rejected {
+			return errors.New("constraint violated")
+		}
+	}
+
+	for _, u := range usages {
+		key := usageKey{u.ApiKey, u.Date, u.Method}
+		if agg, ok := s.usages[key]; ok {
+			agg.Requests += u.Requests
+			agg.ComputeUnits += u.ComputeUnits
+		} else {
+			s.usages[key] = u
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) LoadUsages(apiKey, fromDate, toDate string) (res []*Usage, err error) {
+	atomic.AddInt32(&s.loads, 1)
+
+	if s.loading != nil {
+		<-s.loading
+	}
+
+	for _, u := range s.usages {
+		if u.ApiKey == apiKey && u.Date >= fromDate && u.Date <= toDate {
+			res = append(res, u)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memStore) AddFailures(failures []*Failure) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+
+	for _, f := range failures {
+		if f.ApiKey == s.rejected {
+			return errors.New("constraint violated")
+		}
+	}
+
+	for _, f := range failures {
+		key := failureKey{f.ApiKey, f.Date, f.Code}
+		if agg, ok := s.failures[key]; ok {
+			agg.Requests += f.Requests
+		} else {
+			s.failures[key] = f
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) LoadFailures(apiKey, fromDate, toDate string) (res []*Failure, err error) {
+	for _, f := range s.failures {
+		if f.ApiKey == apiKey && f.Date >= fromDate && f.Date <= toDate {
+			res = append(res, f)
+		}
+	}
+
+	return res, nil
+}
+
+func TestMeter(t *testing.T) {
+	store := &memStore{usages: make(map[usageKey]*Usage), failures: make(map[failureKey]*Failure)}
+	meter := NewMeter(&Config{
+		DefaultWeight: 1,
+		Weights:       map[string]uint64{"eth_getLogs": 20},
+	}, store)
+
+	assert.Equal(t, uint64(20), meter.Weight("eth_getlogs"))
+	assert.Equal(t, uint64(1), meter.Weight("eth_chainId"))
+
+	meter.Record("key", "eth_getLogs")
+	meter.Record("key", "eth_getLogs")
+	meter.Record("key", "eth_chainId")
+	meter.RecordFailure("key", -32005)
+
+	// usages retained if failed to flush
+	store.fail = true
+	assert.Error(t, meter.Flush())
+
+	meter.Record("key", "eth_getLogs")
+	meter.RecordFailure("key", -32005)
+	meter.RecordFailure("key", 3)
+
+	store.fail = false
+	assert.NoError(t, meter.Flush())
+	assert.Len(t, store.usages, 2)
+
+	today := time.Now().UTC().Format(DateLayout)
+	assert.Equal(t, map[failureKey]*Failure{
+		{"key", today, -32005}: {ApiKey: "key", Date: today, Code: -32005, Requests: 2},
+		{"key", today, 3}:      {ApiKey: "key", Date: today, Code: 3, Requests: 1},
+	}, store.failures)
+
+	for key, u := range store.usages {
+		switch key.method {
+		case "eth_getLogs":
+			assert.Equal(t, uint64(3), u.Requests)
+			assert.Equal(t, uint64(60), u.ComputeUnits)
+		case "eth_chainId":
+			assert.Equal(t, uint64(1), u.ComputeUnits)
+		}
+	}
+}
+
+func TestMeterFlushIsolation(t *testing.T) {
+	store := &memStore{
+		rejected: "bad",
+		usages:   make(map[usageKey]*Usage),
+		failures: make(map[failureKey]*Failure),
+	}
+	meter := NewMeter(&Config{DefaultWeight: 1, MaxFlushRetries: 2}, store)
+
+	// too long to persist
+	meter.Record(strings.Repeat("k", MaxApiKeyLength+1), "eth_call")
+	meter.Record("key", strings.Repeat("m", MaxMethodLength+1))
+	meter.RecordFailure(strings.Repeat("k", MaxApiKeyLength+1), 3)
+	assert.NoError(t, meter.Flush())
+	assert.Empty(t, store.usages)
+	assert.Empty(t, store.failures)
+
+	// aggregates rejected by store are isolated and retried
+	meter.Record("key", "eth_call")
+	meter.Record("bad", "eth_call")
+	meter.RecordFailure("key", 3)
+	meter.RecordFailure("bad", 3)
+	assert.Error(t, meter.Flush())
+	assert.Len(t, store.usages, 1)
+	assert.Len(t, store.failures, 1)
+	assert.Len(t, meter.pending, 1)
+	assert.Len(t, meter.failures, 1)
+
+	// dropped once max retries reached, as long as the other aggregates persisted
+	meter.Record("key", "eth_call")
+	meter.RecordFailure("key", 3)
+	assert.Error(t, meter.Flush())
+	assert.Empty(t, meter.pending)
+	assert.Empty(t, meter.failures)
+	assert.Empty(t, meter.usageRetries)
+	assert.Empty(t, meter.failureRetries)
+
+	today := time.Now().UTC().Format(DateLayout)
+	assert.Equal(t, uint64(2), store.usages[usageKey{"key", today, "eth_call"}].Requests)
+	assert.Equal(t, uint64(2), store.failures[failureKey{"key", today, 3}].Requests)
+
+	// retained as usual if store unavailable
+	meter.Record("key", "eth_call")
+	meter.Record("bad", "eth_call")
+	store.fail = true
+
+	for i := 0; i < 3; i++ {
+		assert.Error(t, meter.Flush())
+	}
+
+	assert.Len(t, meter.pending, 2)
+}
+
+func TestMeterQuota(t *testing.T) {
+	store := &memStore{usages: make(map[usageKey]*Usage), failures: make(map[failureKey]*Failure)}
+	meter := NewMeter(&Config{DefaultWeight: 10}, store)
+
+	today := time.Now().UTC().Format(DateLayout)
+	store.AddUsages([]*Usage{{ApiKey: "key", Date: today, Method: "eth_call", Requests: 9, ComputeUnits: 90}})
+
+	_, err := ParseQuota(1, "pro", `{"daily": 100, "mode": "strict"}`)
+	assert.Error(t, err)
+
+	soft, err := ParseQuota(1, "pro", `{"daily": 100, "mode": "soft"}`)
+	assert.NoError(t, err)
+	hard, err := ParseQuota(2, DefaultQuota, `{"daily": 100, "monthly": 1000}`)
+	assert.NoError(t, err)
+	assert.Equal(t, QuotaModeHard, hard.Mode)
+
+	meter.SetQuotas(map[uint32]*Quota{1: soft, 2: hard})
+
+	// consumption loaded from store
+	assert.NoError(t, meter.CheckQuota("key", ""))
+	meter.Record("key", "eth_call")
+
+	// local consumption accumulated
+	err = meter.CheckQuota("key", "")
+	if assert.Error(t, err) {
+		var qerr *QuotaExceededError
+		assert.True(t, errors.As(err, &qerr))
+		assert.Equal(t, "daily", qerr.Period)
+		assert.Equal(t, ErrCodeQuotaExceeded, qerr.ErrorCode())
+	}
+
+	// only alert in soft mode
+	assert.NoError(t, meter.CheckQuota("key", "pro"))
+
+	// unknown key without usages
+	assert.NoError(t, meter.CheckQuota("other", ""))
+}
+
+func TestMeterQuotaLoading(t *testing.T) {
+	store := &memStore{
+		usages:   make(map[usageKey]*Usage),
+		failures: make(map[failureKey]*Failure),
+		loading:  make(chan struct{}),
+	}
+	meter := NewMeter(&Config{DefaultWeight: 10}, store)
+
+	quota, err := ParseQuota(1, DefaultQuota, `{"daily": 100}`)
+	assert.NoError(t, err)
+	meter.SetQuotas(map[uint32]*Quota{1: quota})
+
+	// concurrent checks of the same API key load usages only once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, meter.CheckQuota("key", ""))
+		}()
+	}
+
+	// quota lock not held while loading usages
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&store.loads) == 1 }, time.Second, time.Millisecond)
+	meter.Record("other", "eth_call")
+	meter.SetQuotas(map[uint32]*Quota{1: quota})
+
+	close(store.loading)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&store.loads))
+}
+
+func TestMeterQuotaSoftAlert(t *testing.T) {
+	store := &memStore{usages: make(map[usageKey]*Usage), failures: make(map[failureKey]*Failure)}
+	meter := NewMeter(&Config{DefaultWeight: 10}, store)
+
+	today := time.Now().UTC().Format(DateLayout)
+	store.AddUsages([]*Usage{{ApiKey: "key", Date: today, Method: "eth_call", Requests: 10, ComputeUnits: 100}})
+
+	soft, err := ParseQuota(1, DefaultQuota, `{"daily": 100, "mode": "soft"}`)
+	assert.NoError(t, err)
+	meter.SetQuotas(map[uint32]*Quota{1: soft})
+
+	assert.NoError(t, meter.CheckQuota("key", ""))
+	assert.Equal(t, today, meter.alerted[alertKey{"key", "daily"}])
+
+	// alerted once per quota period even though consumptions reloaded after flush
+	meter.Record("key", "eth_call")
+	assert.NoError(t, meter.Flush())
+	assert.Empty(t, meter.consumed)
+	assert.False(t, meter.alertOnce(alertKey{"key", "daily"}, today))
+
+	// alert state of past quota periods dropped
+	meter.alerted[alertKey{"key", "daily"}] = "2000-01-01"
+	meter.resetConsumptions()
+	assert.NotContains(t, meter.alerted, alertKey{"key", "daily"})
+}
@@ -0,0 +1,289 @@
+package metering
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pre-defined quota name applied to API keys without tier quota
+	DefaultQuota = "default"
+
+	// JSON-RPC error code when compute units quota exceeded
+	ErrCodeQuotaExceeded = -32007
+)
+
+// QuotaMode quota enforcement mode.
+type QuotaMode string
+
+const (
+	// requests are rejected once quota exceeded
+	QuotaModeHard QuotaMode = "hard"
+	// requests are only logged for alert once quota exceeded
+	QuotaModeSoft QuotaMode = "soft"
+)
+
+// Quota compute units budget of API keys, of which zero budget means unlimited.
+type Quota struct {
+	ID   uint32 `json:"-"`
+	Name string `json:"-"`
+
+	Daily   uint64    // daily compute units budget
+	Monthly uint64    // monthly compute units budget
+	Mode    QuotaMode // enforcement mode, defaults to `hard`
+}
+
+// ParseQuota strictly parses quota config json.
+func ParseQuota(id uint32, name, value string) (*Quota, error) {
+	quota := &Quota{ID: id, Name: name}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(quota); err != nil {
+		return nil, errors.WithMessage(err, "invalid quota config json")
+	}
+
+	switch quota.Mode {
+	case "":
+		quota.Mode = QuotaModeHard
+	case QuotaModeHard, QuotaModeSoft:
+	default:
+		return nil, errors.Errorf("invalid quota mode %v", quota.Mode)
+	}
+
+	return quota, nil
+}
+
+// QuotaExceededError JSON-RPC error when compute units quota exceeded.
+type QuotaExceededError struct {
+	Period string // `daily` or `monthly`
+	Budget uint64
+	Used   uint64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%v compute units quota exceeded (%v/%v)", e.Period, e.Used, e.Budget)
+}
+
+func (e *QuotaExceededError) ErrorCode() int { return ErrCodeQuotaExceeded }
+
+// QuotaLoader loads all quota configs, eg., from config store.
+type QuotaLoader func() (map[uint32]*Quota, error)
+
+// consumption compute units consumed by API key in the current day and month.
+type consumption struct {
+	date    string
+	daily   uint64
+	monthly uint64
+}
+
+// alertKey key of the quota period alerted in soft mode for API key, eg., `daily` or `monthly`.
+type alertKey struct {
+	apiKey, period string
+}
+
+// SetQuotas replaces the quotas to enforce.
+func (m *Meter) SetQuotas(quotas map[uint32]*Quota) {
+	byName := make(map[string]*Quota, len(quotas))
+	for _, q := range quotas {
+		byName[q.Name] = q
+	}
+
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	m.quotas = byName
+}
+
+// ReloadQuotas reloads quotas immediately, which is used to apply config changes without
+// waiting for the next periodical reloading.
+func (m *Meter) ReloadQuotas(loader QuotaLoader) error {
+	quotas, err := loader()
+	if err != nil {
+		return err
+	}
+
+	m.SetQuotas(quotas)
+	return nil
+}
+
+// AutoReloadQuotas reloads quotas periodically to hot-reload the config changes.
+func (m *Meter) AutoReloadQuotas(interval time.Duration, loader QuotaLoader) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// load immediately at first
+	m.ReloadQuotas(loader)
+
+	for range ticker.C {
+		if err := m.ReloadQuotas(loader); err != nil {
+			logrus.WithError(err).Error("Failed to load usage quotas")
+		}
+	}
+}
+
+// CheckQuota checks if the API key exceeds the quota of its tier, or the default quota if
+// tier quota not found. Note, the consumption is approximate since usages of other replicas
+// are only visible once flushed to store.
+func (m *Meter) CheckQuota(apiKey, tier string) error {
+	quota, ok := m.quota(tier)
+	if !ok {
+		return nil
+	}
+
+	c, err := m.consumption(apiKey)
+	if err != nil { // fail open
+		logrus.WithError(err).WithField("apiKey", apiKey).Warn("Failed to load usages for quota check")
+		return nil
+	}
+
+	var qerr *QuotaExceededError
+	var period string // quota period exceeded, eg., date or month
+
+	switch {
+	case quota.Monthly > 0 && c.monthly >= quota.Monthly:
+		qerr = &QuotaExceededError{Period: "monthly", Budget: quota.Monthly, Used: c.monthly}
+		period = c.date[:len("2006-01")]
+	case quota.Daily > 0 && c.daily >= quota.Daily:
+		qerr = &QuotaExceededError{Period: "daily", Budget: quota.Daily, Used: c.daily}
+		period = c.date
+	default:
+		return nil
+	}
+
+	if quota.Mode == QuotaModeHard {
+		return qerr
+	}
+
+	if m.alertOnce(alertKey{apiKey, qerr.Period}, period) {
+
+		logrus.WithFields(logrus.Fields{
+			"apiKey": apiKey,
+			"quota":  quota.Name,
+			"period": qerr.Period,
+			"budget": qerr.Budget,
+			"used":   qerr.Used,
+		}).Warn("Compute units quota exceeded in soft mode")
+	}
+
+	return nil
+}
+
+// quota returns the quota of tier, or the default quota if tier quota not found. Returns false
+// if neither found nor limited.
+func (m *Meter) quota(tier string) (*Quota, bool) {
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	quota, ok := m.quotas[tier]
+	if !ok {
+		quota, ok = m.quotas[DefaultQuota]
+	}
+
+	if !ok || (quota.Daily == 0 && quota.Monthly == 0) {
+		return nil, false
+	}
+
+	return quota, true
+}
+
+// alertOnce checks if the quota period is not alerted yet for the alert key, and marks it alerted,
+// so that soft mode alerts once per quota period regardless of consumptions reloaded.
+func (m *Meter) alertOnce(key alertKey, period string) bool {
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	if m.alerted[key] == period {
+		return false
+	}
+
+	m.alerted[key] = period
+	return true
+}
+
+// consumption returns a snapshot of the consumption of API key, which is loaded from store if
+// not cached or out of date. Note, the store is requested without quota lock held, and only once
+// for concurrent checks of the same API key.
+func (m *Meter) consumption(apiKey string) (consumption, error) {
+	now := time.Now().UTC()
+	date := now.Format(DateLayout)
+
+	if c, ok := m.cachedConsumption(apiKey, date); ok {
+		return c, nil
+	}
+
+	v, err, _ := m.loads.Do(apiKey, func() (interface{}, error) {
+		monthStart := now.AddDate(0, 0, 1-now.Day()).Format(DateLayout)
+
+		usages, err := m.store.LoadUsages(apiKey, monthStart, date)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &consumption{date: date}
+		for _, u := range usages {
+			c.monthly += u.ComputeUnits
+			if u.Date == date {
+				c.daily += u.ComputeUnits
+			}
+		}
+
+		m.quotaMu.Lock()
+		defer m.quotaMu.Unlock()
+
+		m.consumed[apiKey] = c
+		return *c, nil
+	})
+
+	if err != nil {
+		return consumption{}, err
+	}
+
+	return v.(consumption), nil
+}
+
+// cachedConsumption returns a snapshot of the cached consumption of API key on the date.
+func (m *Meter) cachedConsumption(apiKey, date string) (consumption, bool) {
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	if c, ok := m.consumed[apiKey]; ok && c.date == date {
+		return *c, true
+	}
+
+	return consumption{}, false
+}
+
+// consume accounts compute units to the cached consumption of API key if any.
+func (m *Meter) consume(apiKey, date string, computeUnits uint64) {
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	if c, ok := m.consumed[apiKey]; ok && c.date == date {
+		c.daily += computeUnits
+		c.monthly += computeUnits
+	}
+}
+
+// resetConsumptions drops the cached consumptions so that they will be reloaded from store
+// along with the usages of other replicas, and the soft mode alerts of past quota periods.
+func (m *Meter) resetConsumptions() {
+	date := time.Now().UTC().Format(DateLayout)
+
+	m.quotaMu.Lock()
+	defer m.quotaMu.Unlock()
+
+	m.consumed = make(map[string]*consumption)
+
+	for key, period := range m.alerted {
+		if period != date && period != date[:len("2006-01")] {
+			delete(m.alerted, key)
+		}
+	}
+}
@@ -122,6 +122,168 @@ func (*RpcMetrics) FullnodeNonRpcErrorRate(node ...string) Percentage {
 	return GetOrRegisterTimeWindowPercentageDefault("infura/rpc/fullnode/rate/nonRpcErr/%v", node[0])
 }
 
+// RPC metrics - full node connection pool
+
+func (*RpcMetrics) FullnodePoolConns(node string) metrics.Gauge {
+	return GetOrRegisterGauge("infura/rpc/fullnode/pool/conns/%v", node)
+}
+
+func (*RpcMetrics) FullnodePoolInflight(node string) metrics.Gauge {
+	return GetOrRegisterGauge("infura/rpc/fullnode/pool/inflight/%v", node)
+}
+
+func (*RpcMetrics) FullnodePoolDials(node string, err error) metrics.Meter {
+	if util.IsInterfaceValNil(err) {
+		return GetOrRegisterMeter("infura/rpc/fullnode/pool/dial/success/%v", node)
+	}
+
+	return GetOrRegisterMeter("infura/rpc/fullnode/pool/dial/failure/%v", node)
+}
+
+func (*RpcMetrics) FullnodePoolConnReused(node string) Percentage {
+	return GetOrRegisterTimeWindowPercentageDefault("infura/rpc/fullnode/pool/reused/%v", node)
+}
+
+// RPC metrics - rate limit
+
+func (*RpcMetrics) RateLimitRejected(scope, resource string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/ratelimit/rejected/%v/%v", scope, resource)
+}
+
+// RateLimitBypassed meters the requests exempt from rate limit by allowlist bypass tier.
+func (*RpcMetrics) RateLimitBypassed(tier, allowList string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/ratelimit/bypassed/%v/%v", tier, allowList)
+}
+
+// RateLimitSoftRejected meters the requests of monitored tier that would have been rejected.
+func (*RpcMetrics) RateLimitSoftRejected(allowList, resource string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/ratelimit/softRejected/%v/%v", allowList, resource)
+}
+
+// RPC metrics - compression
+
+func (*RpcMetrics) CompressionBytes(encoding string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/compression/%v/bytes", encoding)
+}
+
+func (*RpcMetrics) CompressionBytesSaved(encoding string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/compression/%v/saved", encoding)
+}
+
+// RPC metrics - timeout
+
+func (*RpcMetrics) Timeout(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/timeout/%v", method)
+}
+
+// RPC metrics - shadow traffic
+
+func (*RpcMetrics) Shadow(node, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/shadow/%v/%v", node, outcome)
+}
+
+// RPC metrics - analytics events
+
+func (*RpcMetrics) AnalyticsEvents(status string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/analytics/events/%v", status)
+}
+
+// RPC metrics - access log
+
+func (*RpcMetrics) AccessLog(status string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/accesslog/records/%v", status)
+}
+
+// RPC metrics - traffic recording
+
+func (*RpcMetrics) TrafficRecords(space, status string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/traffic/%v/records/%v", space, status)
+}
+
+// RPC metrics - slow queries
+
+func (*RpcMetrics) SlowQueries(space, status string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/slowlog/%v/queries/%v", space, status)
+}
+
+// RPC metrics - response verification
+
+func (*RpcMetrics) Verification(node, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/verification/%v/%v", node, outcome)
+}
+
+// RPC metrics - private transactions
+
+func (*RpcMetrics) PrivateTx(relay, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/privatetx/%v/%v", relay, outcome)
+}
+
+// RPC metrics - admission control
+
+func (*RpcMetrics) Admission(class, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/admission/%v/%v", class, outcome)
+}
+
+// RPC metrics - adaptive rate limiting
+
+func (*RpcMetrics) RateLimitThrottleFactor() metrics.GaugeFloat64 {
+	return GetOrRegisterGaugeFloat64("infura/rpc/ratelimit/throttle/factor")
+}
+
+func (*RpcMetrics) RateLimitShed() metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/ratelimit/shed")
+}
+
+// RPC metrics - cost estimation
+
+func (*RpcMetrics) CostRejected(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/cost/rejected/%v", method)
+}
+
+// RPC metrics - response redaction
+
+func (*RpcMetrics) Redacted(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/redacted/%v", method)
+}
+
+// RPC metrics - schema validation
+
+func (*RpcMetrics) SchemaViolations(node, method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/schema/violations/%v/%v", node, method)
+}
+
+// RPC metrics - historical state routing
+
+func (*RpcMetrics) HistoricalStateRerouted(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/historical/rerouted/%v", method)
+}
+
+// RPC metrics - scripting hooks
+
+func (*RpcMetrics) Script(name, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/script/%v/%v", name, outcome)
+}
+
+// RPC metrics - response streaming
+
+func (*RpcMetrics) StreamedBytes(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/stream/bytes/%v", method)
+}
+
+func (*RpcMetrics) StreamAborted(method string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/stream/aborted/%v", method)
+}
+
+// RPC metrics - memory budget
+
+func (*RpcMetrics) MemoryUsage(kind string) metrics.Gauge {
+	return GetOrRegisterGauge("infura/rpc/memory/usage/%v", kind)
+}
+
+func (*RpcMetrics) MemoryBackpressure(outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/rpc/memory/backpressure/%v", outcome)
+}
+
 // Sync service metrics
 type SyncMetrics struct{}
 
@@ -160,6 +322,21 @@ func (*StoreMetrics) GetLogs() TimerUpdater {
 	return NewTimerUpdaterByName("infura/store/mysql/getlogs")
 }
 
+// Statement times the SQL statements of operation, eg., `query` or `create`.
+func (*StoreMetrics) Statement(op string) metrics.Timer {
+	return GetOrRegisterTimer("infura/store/mysql/statements/%v", op)
+}
+
+// SlowStatement meters the slow SQL statements of operation.
+func (*StoreMetrics) SlowStatement(op string) metrics.Meter {
+	return GetOrRegisterMeter("infura/store/mysql/statements/%v/slow", op)
+}
+
+// ReplicaHealthy gauges the health status of mysql read replica, 1 for healthy and 0 otherwise.
+func (*StoreMetrics) ReplicaHealthy(replica string) metrics.Gauge {
+	return GetOrRegisterGauge("infura/store/mysql/replicas/%v/healthy", replica)
+}
+
 // Node manager metrics
 type NodeManagerMetrics struct{}
 
@@ -175,6 +352,26 @@ func (*NodeManagerMetrics) NodeAvailability(space, group, node string) string {
 	return fmt.Sprintf("infura/nodes/%v/availability/%v/%v", space, group, node)
 }
 
+func (*NodeManagerMetrics) SequencerFailovers(space string) metrics.Meter {
+	return GetOrRegisterMeter("infura/nodes/%v/sequencer/failovers", space)
+}
+
+func (*NodeManagerMetrics) Inflight(space, group, node, outcome string) metrics.Meter {
+	return GetOrRegisterMeter("infura/nodes/%v/inflight/%v/%v/%v", space, group, node, outcome)
+}
+
+func (*NodeManagerMetrics) Lag(space, group, node string) metrics.Gauge {
+	return GetOrRegisterGauge("infura/nodes/%v/lag/%v/%v", space, group, node)
+}
+
+func (*NodeManagerMetrics) LagRerouted(space, group, node string) metrics.Meter {
+	return GetOrRegisterMeter("infura/nodes/%v/lag/%v/%v/rerouted", space, group, node)
+}
+
+func (*NodeManagerMetrics) DrainMigrated(space, group, node string) metrics.Meter {
+	return GetOrRegisterMeter("infura/nodes/%v/drain/%v/%v/migrated", space, group, node)
+}
+
 // PubSub metrics
 type PubSubMetrics struct{}
 
@@ -186,6 +383,23 @@ func (*PubSubMetrics) InputLogFilter(space string) Percentage {
 	return GetOrRegisterTimeWindowPercentageDefault("infura/pubsub/%v/input/logFilter", space)
 }
 
+func (*PubSubMetrics) Replayed(space string) metrics.Meter {
+	return GetOrRegisterMeter("infura/pubsub/%v/replayed", space)
+}
+
+func (*PubSubMetrics) Disconnects(space, node string) metrics.Meter {
+	return GetOrRegisterMeter("infura/pubsub/%v/upstream/disconnects/%v", space, node)
+}
+
+// Reconnects reconnect attempts to upstream with status `success` or `failure`
+func (*PubSubMetrics) Reconnects(space, node, status string) metrics.Meter {
+	return GetOrRegisterMeter("infura/pubsub/%v/upstream/reconnects/%v/%v", space, node, status)
+}
+
+func (*PubSubMetrics) Backfilled(space, topic string) metrics.Meter {
+	return GetOrRegisterMeter("infura/pubsub/%v/backfilled/%v", space, topic)
+}
+
 // Virtual filter metrics
 type VirtualFilterMetrics struct{}
 
@@ -9,34 +9,41 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// reportConfig configurations to report metrics to InfluxDB.
+type reportConfig struct {
+	Enabled  bool `default:"true"`
+	Influxdb struct {
+		Host     string `default:"http://127.0.0.1:8086"`
+		DB       string `default:"infura_test"`
+		Username string
+		Password string
+	}
+	Report struct {
+		Enabled  bool
+		Interval time.Duration `default:"10s"`
+	}
+}
+
+// config is nil if metrics not reported periodically.
+var config *reportConfig
+
 // This package should be imported before any metric (e.g. timer, histogram) created.
 // Because, `metrics.Enabled` in go-ethereum is `false` by default, which leads to noop
 // metric created for static variables in any package.
 //
 // In addition, this package should be imported after the initialization of viper and logrus.
 func Init() {
-	var config struct {
-		Enabled  bool `default:"true"`
-		Influxdb struct {
-			Host     string `default:"http://127.0.0.1:8086"`
-			DB       string `default:"infura_test"`
-			Username string
-			Password string
-		}
-		Report struct {
-			Enabled  bool
-			Interval time.Duration `default:"10s"`
-		}
-	}
-
-	viper.MustUnmarshalKey("metrics", &config)
+	var conf reportConfig
+	viper.MustUnmarshalKey("metrics", &conf)
 
-	metrics.Enabled = config.Enabled
+	metrics.Enabled = conf.Enabled
 
-	if !metrics.Enabled || !config.Report.Enabled {
+	if !metrics.Enabled || !conf.Report.Enabled {
 		return
 	}
 
+	config = &conf
+
 	go influxdb.InfluxDB(
 		InfuraRegistry,
 		config.Report.Interval,
@@ -49,3 +56,24 @@ func Init() {
 
 	logrus.Info("Start to report metrics to influxdb periodically")
 }
+
+// Flush reports the collected metrics to InfluxDB at once, which is usually called before
+// process exits so that metrics since the last periodical report will not be lost.
+func Flush() {
+	if config == nil {
+		return
+	}
+
+	err := influxdb.InfluxDBWithTagsOnce(
+		InfuraRegistry,
+		config.Influxdb.Host,
+		config.Influxdb.DB,
+		config.Influxdb.Username,
+		config.Influxdb.Password,
+		"",  // namespace
+		nil, // tags
+	)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to flush metrics to influxdb")
+	}
+}
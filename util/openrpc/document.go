@@ -0,0 +1,38 @@
+package openrpc
+
+// Version of OpenRPC specification that documents conform to.
+const Version = "1.2.6"
+
+// Document OpenRPC document describing the RPC methods served, eg., for capability discovery by
+// client tooling.
+type Document struct {
+	OpenRPC string   `json:"openrpc"`
+	Info    Info     `json:"info"`
+	Methods []Method `json:"methods"`
+}
+
+// Info metadata of the served APIs.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Method RPC method with its params and result.
+type Method struct {
+	Name   string              `json:"name"`
+	Params []ContentDescriptor `json:"params"`
+	Result *ContentDescriptor  `json:"result,omitempty"` // nil if no result returned
+}
+
+// ContentDescriptor describes the content of param or result.
+type ContentDescriptor struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      interface{} `json:"schema"`
+}
+
+// AnySchema JSON schema that any value matches.
+func AnySchema() interface{} {
+	return map[string]interface{}{}
+}
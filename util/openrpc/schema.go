@@ -0,0 +1,284 @@
+package openrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// max number of violations reported for a response
+	maxViolations = 10
+	// max depth of nested schemas to guard against cyclic references
+	maxSchemaDepth = 64
+)
+
+// Violation schema violation of JSON value at the path, eg., `$.transactions[0].hash`.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: %v", v.Path, v.Message)
+}
+
+// patternCache caches the compiled regular expressions of `pattern` keyword.
+var patternCache sync.Map // pattern => *regexp.Regexp
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// validator validates JSON value against the subset of JSON schema used by OpenRPC documents,
+// including `$ref`, `type`, `pattern`, `enum`, `properties`, `required`, `additionalProperties`,
+// `items`, `oneOf`, `anyOf` and `allOf`, while the other keywords are ignored.
+//
+// Note, `oneOf` is validated as `anyOf` since the branches of Ethereum OpenRPC schemas might
+// overlap, eg., block number or tag. Besides, optional properties of null value are treated the
+// same as absent ones, since responses are re-encoded from typed results by gateway.
+type validator struct {
+	root       interface{} // root document to resolve `$ref`
+	violations []Violation
+	silent     bool // only to check whether matched, eg., for branches of `anyOf`
+}
+
+func (v *validator) report(path, format string, args ...interface{}) bool {
+	if !v.silent && len(v.violations) < maxViolations {
+		v.violations = append(v.violations, Violation{path, fmt.Sprintf(format, args...)})
+	}
+
+	return false
+}
+
+// matches checks whether the value matches the schema without reporting violations.
+func (v *validator) matches(schema, value interface{}, path string, depth int) bool {
+	sub := validator{root: v.root, silent: true}
+	return sub.validate(schema, value, path, depth)
+}
+
+func (v *validator) validate(schema, value interface{}, path string, depth int) bool {
+	if depth > maxSchemaDepth {
+		return v.report(path, "schema nested too deep")
+	}
+
+	s, ok := schema.(map[string]interface{})
+	if !ok { // eg., `true` schema
+		return true
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		resolved, ok := resolveRef(v.root, ref)
+		if !ok {
+			return v.report(path, "unresolved schema reference %v", ref)
+		}
+
+		return v.validate(resolved, value, path, depth+1)
+	}
+
+	valid := v.validateType(s, value, path)
+
+	if enum, ok := s["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		valid = v.report(path, "value not in enum")
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		if str, ok := value.(string); ok {
+			if re, err := compilePattern(pattern); err == nil && !re.MatchString(str) {
+				valid = v.report(path, "%q does not match pattern %v", str, pattern)
+			}
+		}
+	}
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		valid = v.validateObject(s, node, path, depth) && valid
+	case []interface{}:
+		if items, ok := s["items"]; ok {
+			for i, item := range node {
+				valid = v.validate(items, item, fmt.Sprintf("%v[%v]", path, i), depth+1) && valid
+			}
+		}
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			valid = v.validate(sub, value, path, depth+1) && valid
+		}
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		branches, ok := s[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+
+		if !v.matchesAny(branches, value, path, depth) {
+			valid = v.report(path, "value does not match any of %v schemas of %v", len(branches), keyword)
+		}
+	}
+
+	return valid
+}
+
+func (v *validator) matchesAny(branches []interface{}, value interface{}, path string, depth int) bool {
+	for _, branch := range branches {
+		if v.matches(branch, value, path, depth+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *validator) validateType(s map[string]interface{}, value interface{}, path string) bool {
+	var types []string
+
+	switch t := s["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if str, ok := v.(string); ok {
+				types = append(types, str)
+			}
+		}
+	default:
+		return true
+	}
+
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return v.report(path, "expected %v but got %v", strings.Join(types, " or "), actual)
+}
+
+func (v *validator) validateObject(s map[string]interface{}, obj map[string]interface{}, path string, depth int) bool {
+	valid := true
+
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, field := range required {
+			name, _ := field.(string)
+			if _, ok := obj[name]; !ok {
+				valid = v.report(path, "missing required field %v", name)
+			}
+		}
+	}
+
+	props, _ := s["properties"].(map[string]interface{})
+
+	for name, fieldValue := range obj {
+		fieldPath := path + "." + name
+
+		if prop, ok := props[name]; ok {
+			if fieldValue == nil && !isRequired(s, name) {
+				continue
+			}
+
+			valid = v.validate(prop, fieldValue, fieldPath, depth+1) && valid
+			continue
+		}
+
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				valid = v.report(fieldPath, "unexpected field")
+			}
+		case map[string]interface{}:
+			valid = v.validate(additional, fieldValue, fieldPath, depth+1) && valid
+		}
+	}
+
+	return valid
+}
+
+func isRequired(s map[string]interface{}, name string) bool {
+	required, _ := s["required"].([]interface{})
+
+	for _, field := range required {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonType returns the JSON schema type of value decoded with numbers as `json.Number`.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+
+		return "integer"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+
+	for _, v := range values {
+		if data, err := json.Marshal(v); err == nil && bytes.Equal(data, encoded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveRef resolves the local JSON pointer reference, eg., `#/components/schemas/uint`.
+func resolveRef(root interface{}, ref string) (interface{}, bool) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+
+	node := root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if node, ok = obj[token]; !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
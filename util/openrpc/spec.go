@@ -0,0 +1,227 @@
+package openrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// methodResult result schema of RPC method along with the document to resolve references.
+type methodResult struct {
+	schema interface{}
+	doc    interface{}
+}
+
+// Spec result schemas of RPC methods from OpenRPC documents, eg., the Ethereum execution APIs
+// (https://github.com/ethereum/execution-apis).
+type Spec struct {
+	results map[string]methodResult // method => result schema
+}
+
+// ParseSpec parses the OpenRPC document, of which only the result schemas of methods are used.
+func ParseSpec(data []byte) (*Spec, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode OpenRPC document")
+	}
+
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("OpenRPC document must be an object")
+	}
+
+	methods, ok := root["methods"].([]interface{})
+	if !ok {
+		return nil, errors.New("methods of OpenRPC document must be an array")
+	}
+
+	spec := &Spec{results: make(map[string]methodResult)}
+	for _, m := range methods {
+		method, _ := m.(map[string]interface{})
+
+		name, _ := method["name"].(string)
+		if len(name) == 0 {
+			return nil, errors.New("method name of OpenRPC document must not be empty")
+		}
+
+		result, _ := method["result"].(map[string]interface{})
+		if schema, ok := result["schema"]; ok {
+			spec.results[name] = methodResult{schema, doc}
+		}
+	}
+
+	return spec, nil
+}
+
+// LoadSpec loads the OpenRPC document from file.
+func LoadSpec(file string) (*Spec, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to read OpenRPC document %v", file)
+	}
+
+	return ParseSpec(data)
+}
+
+// DefaultSpec returns the built-in spec of the commonly used methods of Ethereum execution APIs.
+func DefaultSpec() *Spec {
+	spec, err := ParseSpec([]byte(defaultEthSpec))
+	if err != nil {
+		panic(errors.WithMessage(err, "invalid built-in OpenRPC document"))
+	}
+
+	return spec
+}
+
+// Merge merges the result schemas of the other spec, which take precedence over the existing ones.
+func (s *Spec) Merge(other *Spec) {
+	for method, result := range other.results {
+		s.results[method] = result
+	}
+}
+
+// Has checks whether the result schema of RPC method is specified.
+func (s *Spec) Has(method string) bool {
+	_, ok := s.results[method]
+	return ok
+}
+
+// Validate validates the JSON result of RPC method, and returns the violations if any. Note,
+// result of unspecified method is always valid.
+func (s *Spec) Validate(method string, result json.RawMessage) ([]Violation, error) {
+	mr, ok := s.results[method]
+	if !ok {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode result")
+	}
+
+	v := validator{root: mr.doc}
+	v.validate(mr.schema, value, "$", 0)
+
+	return v.violations, nil
+}
+
+// defaultEthSpec subset of Ethereum execution APIs, where only the required fields of objects
+// are specified so that client specific extensions, eg., `author` of blocks, are still allowed.
+const defaultEthSpec = `{
+  "openrpc": "1.2.4",
+  "info": {"title": "Ethereum JSON-RPC subset", "version": "1.0.0"},
+  "methods": [
+    {"name": "eth_blockNumber", "result": {"name": "Block number", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_chainId", "result": {"name": "Chain ID", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_gasPrice", "result": {"name": "Gas price", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_maxPriorityFeePerGas", "result": {"name": "Max priority fee", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_estimateGas", "result": {"name": "Gas used", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_getBalance", "result": {"name": "Balance", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_getTransactionCount", "result": {"name": "Nonce", "schema": {"$ref": "#/components/schemas/uint"}}},
+    {"name": "eth_getCode", "result": {"name": "Bytecode", "schema": {"$ref": "#/components/schemas/bytes"}}},
+    {"name": "eth_getStorageAt", "result": {"name": "Value", "schema": {"$ref": "#/components/schemas/bytes"}}},
+    {"name": "eth_call", "result": {"name": "Return data", "schema": {"$ref": "#/components/schemas/bytes"}}},
+    {"name": "eth_sendRawTransaction", "result": {"name": "Transaction hash", "schema": {"$ref": "#/components/schemas/hash32"}}},
+    {"name": "eth_getBlockByHash", "result": {"name": "Block", "schema": {"$ref": "#/components/schemas/BlockOrNull"}}},
+    {"name": "eth_getBlockByNumber", "result": {"name": "Block", "schema": {"$ref": "#/components/schemas/BlockOrNull"}}},
+    {"name": "eth_getTransactionByHash", "result": {"name": "Transaction", "schema": {"$ref": "#/components/schemas/TransactionOrNull"}}},
+    {"name": "eth_getTransactionByBlockHashAndIndex", "result": {"name": "Transaction", "schema": {"$ref": "#/components/schemas/TransactionOrNull"}}},
+    {"name": "eth_getTransactionByBlockNumberAndIndex", "result": {"name": "Transaction", "schema": {"$ref": "#/components/schemas/TransactionOrNull"}}},
+    {"name": "eth_getTransactionReceipt", "result": {"name": "Receipt", "schema": {"oneOf": [{"type": "null"}, {"$ref": "#/components/schemas/Receipt"}]}}},
+    {"name": "eth_getLogs", "result": {"name": "Logs", "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Log"}}}}
+  ],
+  "components": {
+    "schemas": {
+      "uint": {"type": "string", "pattern": "^0x(0|[1-9a-f][0-9a-f]*)$"},
+      "bytes": {"type": "string", "pattern": "^0x[0-9a-f]*$"},
+      "bytes256": {"type": "string", "pattern": "^0x[0-9a-f]{512}$"},
+      "hash32": {"type": "string", "pattern": "^0x[0-9a-f]{64}$"},
+      "address": {"type": "string", "pattern": "^0x[0-9a-fA-F]{40}$"},
+      "BlockOrNull": {"oneOf": [{"type": "null"}, {"$ref": "#/components/schemas/Block"}]},
+      "TransactionOrNull": {"oneOf": [{"type": "null"}, {"$ref": "#/components/schemas/Transaction"}]},
+      "Block": {
+        "type": "object",
+        "required": ["hash", "parentHash", "sha3Uncles", "miner", "stateRoot", "transactionsRoot", "receiptsRoot", "logsBloom", "number", "gasLimit", "gasUsed", "timestamp", "extraData", "transactions", "uncles"],
+        "properties": {
+          "hash": {"$ref": "#/components/schemas/hash32"},
+          "parentHash": {"$ref": "#/components/schemas/hash32"},
+          "sha3Uncles": {"$ref": "#/components/schemas/hash32"},
+          "miner": {"$ref": "#/components/schemas/address"},
+          "stateRoot": {"$ref": "#/components/schemas/hash32"},
+          "transactionsRoot": {"$ref": "#/components/schemas/hash32"},
+          "receiptsRoot": {"$ref": "#/components/schemas/hash32"},
+          "logsBloom": {"$ref": "#/components/schemas/bytes256"},
+          "number": {"$ref": "#/components/schemas/uint"},
+          "gasLimit": {"$ref": "#/components/schemas/uint"},
+          "gasUsed": {"$ref": "#/components/schemas/uint"},
+          "timestamp": {"$ref": "#/components/schemas/uint"},
+          "extraData": {"$ref": "#/components/schemas/bytes"},
+          "baseFeePerGas": {"$ref": "#/components/schemas/uint"},
+          "transactions": {
+            "type": "array",
+            "items": {"oneOf": [{"$ref": "#/components/schemas/hash32"}, {"$ref": "#/components/schemas/Transaction"}]}
+          },
+          "uncles": {"type": "array", "items": {"$ref": "#/components/schemas/hash32"}}
+        }
+      },
+      "Transaction": {
+        "type": "object",
+        "required": ["hash", "nonce", "from", "gas", "value", "input"],
+        "properties": {
+          "hash": {"$ref": "#/components/schemas/hash32"},
+          "blockHash": {"$ref": "#/components/schemas/hash32"},
+          "blockNumber": {"$ref": "#/components/schemas/uint"},
+          "transactionIndex": {"$ref": "#/components/schemas/uint"},
+          "nonce": {"$ref": "#/components/schemas/uint"},
+          "from": {"$ref": "#/components/schemas/address"},
+          "to": {"$ref": "#/components/schemas/address"},
+          "gas": {"$ref": "#/components/schemas/uint"},
+          "gasPrice": {"$ref": "#/components/schemas/uint"},
+          "value": {"$ref": "#/components/schemas/uint"},
+          "input": {"$ref": "#/components/schemas/bytes"}
+        }
+      },
+      "Receipt": {
+        "type": "object",
+        "required": ["transactionHash", "transactionIndex", "blockHash", "blockNumber", "from", "cumulativeGasUsed", "gasUsed", "logs", "logsBloom"],
+        "properties": {
+          "transactionHash": {"$ref": "#/components/schemas/hash32"},
+          "transactionIndex": {"$ref": "#/components/schemas/uint"},
+          "blockHash": {"$ref": "#/components/schemas/hash32"},
+          "blockNumber": {"$ref": "#/components/schemas/uint"},
+          "from": {"$ref": "#/components/schemas/address"},
+          "to": {"$ref": "#/components/schemas/address"},
+          "contractAddress": {"$ref": "#/components/schemas/address"},
+          "cumulativeGasUsed": {"$ref": "#/components/schemas/uint"},
+          "gasUsed": {"$ref": "#/components/schemas/uint"},
+          "logs": {"type": "array", "items": {"$ref": "#/components/schemas/Log"}},
+          "logsBloom": {"$ref": "#/components/schemas/bytes256"},
+          "status": {"$ref": "#/components/schemas/uint"}
+        }
+      },
+      "Log": {
+        "type": "object",
+        "required": ["address", "topics", "data"],
+        "properties": {
+          "removed": {"type": "boolean"},
+          "logIndex": {"$ref": "#/components/schemas/uint"},
+          "transactionIndex": {"$ref": "#/components/schemas/uint"},
+          "transactionHash": {"$ref": "#/components/schemas/hash32"},
+          "blockHash": {"$ref": "#/components/schemas/hash32"},
+          "blockNumber": {"$ref": "#/components/schemas/uint"},
+          "address": {"$ref": "#/components/schemas/address"},
+          "data": {"$ref": "#/components/schemas/bytes"},
+          "topics": {"type": "array", "items": {"$ref": "#/components/schemas/hash32"}}
+        }
+      }
+    }
+  }
+}`
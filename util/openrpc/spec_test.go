@@ -0,0 +1,105 @@
+package openrpc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecValidateDefault(t *testing.T) {
+	spec := DefaultSpec()
+
+	hash := "0x" + strings.Repeat("ab", 32)
+
+	testCases := []struct {
+		method     string
+		result     string
+		violations []string
+	}{
+		{"eth_blockNumber", `"0x1b4"`, nil},
+		{"eth_blockNumber", `"0x01b4"`, []string{`$: "0x01b4" does not match pattern`}},
+		{"eth_blockNumber", `436`, []string{"$: expected string but got integer"}},
+		{"eth_getCode", `"0x"`, nil},
+		{"eth_getBlockByNumber", `null`, nil},
+		{"eth_getTransactionByHash", `{"hash":"` + hash + `","nonce":"0x1","from":"0x` + strings.Repeat("A", 40) +
+			`","gas":"0x5208","value":"0x0","input":"0x","to":null,"author":"extension"}`, nil},
+		{"eth_getTransactionByHash", `{"hash":"0x12","nonce":"0x1","from":"0x` + strings.Repeat("a", 40) +
+			`","gas":"0x5208","value":"0x0"}`, []string{"$: value does not match any of 2 schemas of oneOf"}},
+		{"eth_getLogs", `[{"address":"0x` + strings.Repeat("a", 40) + `","topics":["` + hash + `"],"data":"0x"}]`, nil},
+		{"eth_getLogs", `[{"address":"0x` + strings.Repeat("a", 40) + `","topics":["0x1"]}]`, []string{
+			"$[0]: missing required field data",
+			`$[0].topics[0]: "0x1" does not match pattern`,
+		}},
+		// unspecified method
+		{"eth_syncing", `false`, nil},
+	}
+
+	for _, tc := range testCases {
+		violations, err := spec.Validate(tc.method, json.RawMessage(tc.result))
+		assert.NoError(t, err)
+		assert.Equal(t, len(tc.violations), len(violations), "%v: %v", tc.method, violations)
+
+		for _, v := range violations {
+			matched := false
+			for _, expected := range tc.violations {
+				matched = matched || strings.HasPrefix(v.String(), expected)
+			}
+
+			assert.True(t, matched, "unexpected violation %v", v)
+		}
+	}
+}
+
+func TestSpecMerge(t *testing.T) {
+	spec := DefaultSpec()
+	assert.False(t, spec.Has("eth_syncing"))
+
+	custom, err := ParseSpec([]byte(`{
+		"methods": [
+			{"name": "eth_syncing", "result": {"schema": {"oneOf": [{"type": "boolean"}, {"$ref": "#/components/schemas/Progress"}]}}},
+			{"name": "eth_blockNumber", "result": {"schema": {"type": "string", "enum": ["0x1"]}}}
+		],
+		"components": {
+			"schemas": {
+				"Progress": {
+					"type": "object",
+					"required": ["currentBlock"],
+					"additionalProperties": false,
+					"properties": {"currentBlock": {"type": "string"}}
+				}
+			}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	spec.Merge(custom)
+	assert.True(t, spec.Has("eth_syncing"))
+
+	violations, err := spec.Validate("eth_syncing", json.RawMessage(`{"currentBlock":"0x1"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = spec.Validate("eth_syncing", json.RawMessage(`{"currentBlock":"0x1","extra":1}`))
+	assert.NoError(t, err)
+	assert.Len(t, violations, 1)
+
+	// overridden by custom spec
+	violations, err = spec.Validate("eth_blockNumber", json.RawMessage(`"0x2"`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Violation{{"$", "value not in enum"}}, violations)
+
+	// references resolved within the default spec
+	violations, err = spec.Validate("eth_getBlockByHash", json.RawMessage(`null`))
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	_, err := ParseSpec([]byte(`[]`))
+	assert.Error(t, err)
+
+	_, err = ParseSpec([]byte(`{"methods": [{"result": {}}]}`))
+	assert.Error(t, err)
+}
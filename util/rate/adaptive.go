@@ -0,0 +1,207 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+// throttleFactorBits is the float64 bits of throttle factor applied to all rate limits, which is
+// in range (0, 1] and 0 bits means not throttled.
+var throttleFactorBits uint64
+
+// ThrottleFactor returns the factor to scale down the effective rate limits, eg., 0.5 means
+// the rate and burst (or quota) of all limit rules are halved, and 1 means not throttled.
+func ThrottleFactor() float64 {
+	if bits := atomic.LoadUint64(&throttleFactorBits); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+
+	return 1
+}
+
+func setThrottleFactor(factor float64) {
+	atomic.StoreUint64(&throttleFactorBits, math.Float64bits(factor))
+}
+
+// throttle scales down the limit value by the factor, but at least 1.
+func throttle(v int, factor float64) int {
+	if factor >= 1 {
+		return v
+	}
+
+	if scaled := int(float64(v) * factor); scaled > 1 {
+		return scaled
+	}
+
+	return 1
+}
+
+// AdaptiveConfig configurations of adaptive rate limiting driven by upstream health.
+type AdaptiveConfig struct {
+	// switch to turn on/off adaptive rate limiting
+	Enabled bool
+	// interval to evaluate upstream health and adjust the throttle factor
+	Interval time.Duration `default:"5s"`
+	// min number of upstream calls within an interval to evaluate, otherwise relaxed since
+	// upstream is barely loaded
+	MinSamples int `default:"50"`
+	// upstream is unhealthy if mean latency or ratio of failed calls (eg., io error or timeout)
+	// crosses the threshold
+	LatencyThreshold   time.Duration `default:"2s"`
+	ErrorRateThreshold float64       `default:"0.1"`
+	// throttle factor is multiplied by the decrease factor once unhealthy, and increased by the
+	// increase step once healthy, within range [min factor, 1]
+	DecreaseFactor float64 `default:"0.5"`
+	IncreaseStep   float64 `default:"0.1"`
+	MinFactor      float64 `default:"0.1"`
+	// anonymous requests are shed by the ratio of `1 - factor` once the throttle factor drops
+	// below the threshold, 0 means never shed
+	ShedThreshold float64 `default:"0.5"`
+}
+
+func (conf *AdaptiveConfig) validate() bool {
+	return conf.Interval > 0 && conf.MinSamples > 0 &&
+		conf.LatencyThreshold > 0 && conf.ErrorRateThreshold > 0 &&
+		conf.DecreaseFactor > 0 && conf.DecreaseFactor < 1 && conf.IncreaseStep > 0 &&
+		conf.MinFactor > 0 && conf.MinFactor <= 1 && conf.ShedThreshold >= 0 && conf.ShedThreshold <= 1
+}
+
+// upstreamSamples accumulates upstream calls within an evaluation interval.
+type upstreamSamples struct {
+	calls, failures int
+	latency         time.Duration // total latency of calls
+}
+
+// AdaptiveController is a feedback controller that tightens the effective rate limits, and sheds
+// anonymous requests if severe, when upstream latency or error rate crosses thresholds, and then
+// relaxes them gradually once upstream recovered (AIMD).
+type AdaptiveController struct {
+	conf AdaptiveConfig
+
+	mu      sync.Mutex
+	samples upstreamSamples
+}
+
+// defaultAdaptiveController is the controller fed by upstream calls, nil if disabled.
+var defaultAdaptiveController *AdaptiveController
+
+// MustInitAdaptiveControllerFromViper creates the default adaptive controller from viper and
+// starts the control loop if enabled.
+func MustInitAdaptiveControllerFromViper() {
+	var conf AdaptiveConfig
+	viper.MustUnmarshalKey("rpc.adaptive", &conf)
+
+	if !conf.Enabled {
+		return
+	}
+
+	if !conf.validate() {
+		logrus.WithField("config", conf).Fatal("Invalid adaptive rate limiting config")
+	}
+
+	defaultAdaptiveController = NewAdaptiveController(conf)
+	go defaultAdaptiveController.run()
+
+	logrus.WithField("config", conf).Info("Adaptive rate limiting enabled")
+}
+
+func NewAdaptiveController(conf AdaptiveConfig) *AdaptiveController {
+	return &AdaptiveController{conf: conf}
+}
+
+// ObserveUpstream feeds the upstream call to the default adaptive controller if enabled.
+func ObserveUpstream(latency time.Duration, failed bool) {
+	if c := defaultAdaptiveController; c != nil {
+		c.observe(latency, failed)
+	}
+}
+
+// ShouldShed checks whether the request should be shed by the default adaptive controller,
+// along with the retry hint.
+func ShouldShed(ctx context.Context) (time.Duration, bool) {
+	c := defaultAdaptiveController
+	if c == nil || !c.shed(ctx, ThrottleFactor()) {
+		return 0, false
+	}
+
+	return c.conf.Interval, true
+}
+
+func (c *AdaptiveController) observe(latency time.Duration, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.samples.calls++
+	c.samples.latency += latency
+
+	if failed {
+		c.samples.failures++
+	}
+}
+
+// shed randomly sheds anonymous requests by the ratio of `1 - factor` if severely throttled.
+func (c *AdaptiveController) shed(ctx context.Context, factor float64) bool {
+	if factor >= c.conf.ShedThreshold || !isAnonymous(ctx) {
+		return false
+	}
+
+	return rand.Float64() >= factor
+}
+
+func (c *AdaptiveController) run() {
+	ticker := time.NewTicker(c.conf.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		samples := c.samples
+		c.samples = upstreamSamples{}
+		c.mu.Unlock()
+
+		current := ThrottleFactor()
+		factor := c.adjust(current, samples)
+		if factor == current {
+			continue
+		}
+
+		setThrottleFactor(factor)
+		metrics.Registry.RPC.RateLimitThrottleFactor().Update(factor)
+
+		logger := logrus.WithFields(logrus.Fields{
+			"from":     current,
+			"to":       factor,
+			"calls":    samples.calls,
+			"failures": samples.failures,
+		})
+
+		if factor < current {
+			logger.Warn("Rate limits tightened due to unhealthy upstream")
+		} else {
+			logger.Info("Rate limits relaxed as upstream recovered")
+		}
+	}
+}
+
+// adjust returns the new throttle factor based on the upstream samples of the last interval.
+func (c *AdaptiveController) adjust(factor float64, samples upstreamSamples) float64 {
+	if samples.calls < c.conf.MinSamples {
+		return math.Min(factor+c.conf.IncreaseStep, 1)
+	}
+
+	meanLatency := samples.latency / time.Duration(samples.calls)
+	errorRate := float64(samples.failures) / float64(samples.calls)
+
+	if meanLatency > c.conf.LatencyThreshold || errorRate > c.conf.ErrorRateThreshold {
+		return math.Max(factor*c.conf.DecreaseFactor, c.conf.MinFactor)
+	}
+
+	return math.Min(factor+c.conf.IncreaseStep, 1)
+}
@@ -0,0 +1,75 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveControllerAdjust(t *testing.T) {
+	c := NewAdaptiveController(AdaptiveConfig{
+		MinSamples:         10,
+		LatencyThreshold:   time.Second,
+		ErrorRateThreshold: 0.1,
+		DecreaseFactor:     0.5,
+		IncreaseStep:       0.25,
+		MinFactor:          0.2,
+	})
+
+	healthy := upstreamSamples{calls: 100, failures: 5, latency: 100 * 100 * time.Millisecond}
+	slow := upstreamSamples{calls: 100, latency: 100 * 2 * time.Second}
+	failing := upstreamSamples{calls: 100, failures: 20, latency: 100 * 100 * time.Millisecond}
+
+	assert.Equal(t, 1.0, c.adjust(1, healthy))
+	assert.Equal(t, 0.5, c.adjust(1, slow))
+	assert.Equal(t, 0.25, c.adjust(0.5, failing))
+	assert.Equal(t, 0.2, c.adjust(0.25, failing))
+
+	// relaxed gradually once recovered or barely loaded
+	assert.Equal(t, 0.45, c.adjust(0.2, healthy))
+	assert.Equal(t, 0.7, c.adjust(0.45, upstreamSamples{calls: 1, failures: 1}))
+}
+
+func TestAdaptiveControllerShed(t *testing.T) {
+	c := NewAdaptiveController(AdaptiveConfig{ShedThreshold: 0.5})
+
+	anonymous := context.Background()
+	keyed := context.WithValue(anonymous, handlers.CtxKeyAccessToken, "key")
+
+	assert.False(t, c.shed(anonymous, 0.5))
+	assert.True(t, c.shed(anonymous, 0))
+	assert.False(t, c.shed(keyed, 0))
+}
+
+func TestThrottledLimiters(t *testing.T) {
+	setThrottleFactor(0.5)
+	defer setThrottleFactor(1)
+
+	now := time.Now()
+
+	window := newFixedWindow(time.Minute, 10)
+	assert.NoError(t, window.LimitAt(now, 5))
+
+	le, ok := LimitErrorOf(window.LimitAt(now, 1))
+	assert.True(t, ok)
+	assert.Equal(t, 5, le.Limit)
+
+	bucket := newTokenBucket(TokenBucketOption{Rate: 10, Burst: 10})
+	assert.NoError(t, bucket.LimitAt(now, 5))
+
+	le, ok = LimitErrorOf(bucket.LimitAt(now, 1))
+	assert.True(t, ok)
+	assert.Equal(t, 5, le.Limit)
+
+	// relaxed
+	setThrottleFactor(1)
+	assert.NoError(t, window.LimitAt(now, 5))
+	assert.NoError(t, bucket.LimitAt(now.Add(time.Second), 5))
+
+	le, ok = LimitErrorOf(bucket.LimitAt(now.Add(time.Second), 1))
+	assert.True(t, ok)
+	assert.Equal(t, 10, le.Limit)
+}
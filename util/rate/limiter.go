@@ -0,0 +1,190 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/rate"
+	"github.com/pkg/errors"
+	xrate "golang.org/x/time/rate"
+)
+
+// LimitError is returned when rate limited, along with the quota status so that clients could
+// back off accordingly.
+type LimitError struct {
+	// quota in fixed window, or burst of token bucket
+	Limit int
+	// duration until quota restored, eg., the next fixed window or the next token available
+	Reset time.Duration
+	// duration to wait before retry
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf(
+		"Too many requests (exceeds %v), try again after %v", e.Limit, e.RetryAfter.Round(time.Millisecond),
+	)
+}
+
+// LimitErrorOf returns the LimitError wrapped in error if any.
+func LimitErrorOf(err error) (*LimitError, bool) {
+	var le *LimitError
+	if errors.As(err, &le) {
+		return le, true
+	}
+
+	return nil, false
+}
+
+func errMaxExceeded(max int) error {
+	return errors.Errorf("Too many requests, exceeds %v at a time", max)
+}
+
+// fixedWindow limits rate in a fixed window, which reports quota status when rate limited.
+type fixedWindow struct {
+	interval time.Duration
+	quota    int
+
+	mu    sync.Mutex
+	start time.Time // start time of the current window
+	count int
+}
+
+func newFixedWindow(interval time.Duration, quota int) rate.Limiter {
+	return &fixedWindow{
+		interval: interval,
+		quota:    quota,
+		start:    time.Now().Truncate(interval),
+	}
+}
+
+func (w *fixedWindow) Limit() error { return w.LimitAt(time.Now(), 1) }
+
+func (w *fixedWindow) LimitN(n int) error { return w.LimitAt(time.Now(), n) }
+
+func (w *fixedWindow) LimitAt(now time.Time, n int) error {
+	quota := throttle(w.quota, ThrottleFactor())
+	if n > quota {
+		return errMaxExceeded(quota)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if start := now.Truncate(w.interval); w.start.Before(start) {
+		w.start, w.count = start, 0
+	}
+
+	if w.count+n <= quota {
+		w.count += n
+		return nil
+	}
+
+	reset := w.start.Add(w.interval).Sub(now)
+
+	return &LimitError{Limit: quota, Reset: reset, RetryAfter: reset}
+}
+
+func (w *fixedWindow) Expired() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return time.Since(w.start) > w.interval
+}
+
+// tokenBucket limits rate by token bucket, which reports quota status when rate limited. Besides,
+// the sustained rate and burst are ramped up linearly during the warm-up period if configured,
+// and scaled down by the throttle factor of adaptive rate limiting.
+type tokenBucket struct {
+	opt         TokenBucketOption
+	inner       *xrate.Limiter
+	created     time.Time
+	warming     int32  // 1 if in warm-up period
+	throttled   uint64 // float64 bits of the throttle factor applied
+	lastSeen    int64  // unix timestamp in seconds
+	timeoutSecs int64
+}
+
+func newTokenBucket(opt TokenBucketOption) rate.Limiter {
+	opt = opt.withDefaults()
+
+	b := &tokenBucket{
+		opt:         opt,
+		created:     time.Now(),
+		lastSeen:    time.Now().Unix(),
+		timeoutSecs: int64(float64(opt.Burst)/float64(opt.Rate)) + 1,
+	}
+
+	factor := ThrottleFactor()
+	b.throttled = math.Float64bits(factor)
+
+	r, burst, warming := b.rampAt(b.created)
+	if warming {
+		b.warming = 1
+	}
+
+	b.inner = xrate.NewLimiter(r*xrate.Limit(factor), throttle(burst, factor))
+
+	return b
+}
+
+// rampAt returns the effective rate and burst at the specified time, and whether still warming up.
+func (b *tokenBucket) rampAt(now time.Time) (xrate.Limit, int, bool) {
+	elapsed := now.Sub(b.created)
+	if b.opt.Warmup <= 0 || elapsed >= b.opt.Warmup {
+		return b.opt.Rate, b.opt.Burst, false
+	}
+
+	cold := 1 / b.opt.ColdFactor
+	factor := cold + (1-cold)*float64(elapsed)/float64(b.opt.Warmup)
+
+	burst := int(math.Round(float64(b.opt.Burst) * factor))
+	if burst < 1 {
+		burst = 1
+	}
+
+	return b.opt.Rate * xrate.Limit(factor), burst, true
+}
+
+func (b *tokenBucket) Limit() error { return b.LimitAt(time.Now(), 1) }
+
+func (b *tokenBucket) LimitN(n int) error { return b.LimitAt(time.Now(), n) }
+
+func (b *tokenBucket) LimitAt(now time.Time, n int) error {
+	factor := ThrottleFactor()
+	factorBits := math.Float64bits(factor)
+
+	if atomic.LoadInt32(&b.warming) == 1 || atomic.LoadUint64(&b.throttled) != factorBits {
+		r, burst, warming := b.rampAt(now)
+		b.inner.SetLimitAt(now, r*xrate.Limit(factor))
+		b.inner.SetBurstAt(now, throttle(burst, factor))
+
+		if !warming {
+			atomic.StoreInt32(&b.warming, 0)
+		}
+
+		atomic.StoreUint64(&b.throttled, factorBits)
+	}
+
+	rsv := b.inner.ReserveN(now, n)
+	if !rsv.OK() {
+		return errMaxExceeded(b.inner.Burst())
+	}
+
+	if delay := rsv.DelayFrom(now); delay > 0 {
+		rsv.CancelAt(now)
+		return &LimitError{Limit: b.inner.Burst(), Reset: delay, RetryAfter: delay}
+	}
+
+	atomic.StoreInt64(&b.lastSeen, now.Unix())
+
+	return nil
+}
+
+func (b *tokenBucket) Expired() bool {
+	lastSeen := atomic.LoadInt64(&b.lastSeen)
+	return time.Now().Unix() > lastSeen+b.timeoutSecs
+}
@@ -0,0 +1,66 @@
+package rate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWindowLimitError(t *testing.T) {
+	start := time.Now().Truncate(time.Minute)
+	limiter := newFixedWindow(time.Minute, 2)
+
+	assert.NoError(t, limiter.LimitAt(start.Add(time.Second), 2))
+
+	err := limiter.LimitAt(start.Add(20*time.Second), 1)
+	le, ok := LimitErrorOf(errors.WithMessage(err, "wrapped"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, le.Limit)
+	assert.Equal(t, 40*time.Second, le.Reset)
+	assert.Equal(t, 40*time.Second, le.RetryAfter)
+
+	// next window
+	assert.NoError(t, limiter.LimitAt(start.Add(time.Minute), 1))
+
+	// never satisfied
+	_, ok = LimitErrorOf(limiter.LimitAt(start.Add(time.Minute), 3))
+	assert.False(t, ok)
+}
+
+func TestTokenBucketLimitError(t *testing.T) {
+	now := time.Now()
+	limiter := newTokenBucket(TokenBucketOption{Rate: 1, Burst: 2})
+
+	assert.NoError(t, limiter.LimitAt(now, 2))
+
+	le, ok := LimitErrorOf(limiter.LimitAt(now, 1))
+	assert.True(t, ok)
+	assert.Equal(t, 2, le.Limit)
+	assert.Equal(t, time.Second, le.RetryAfter)
+
+	// token refilled
+	assert.NoError(t, limiter.LimitAt(now.Add(time.Second), 1))
+}
+
+func TestTokenBucketWarmup(t *testing.T) {
+	limiter := newTokenBucket(TokenBucketOption{Rate: 30, Burst: 30, Warmup: time.Minute}).(*tokenBucket)
+	created := limiter.created
+
+	// cold burst is a third of the full burst
+	assert.NoError(t, limiter.LimitAt(created, 10))
+	_, ok := LimitErrorOf(limiter.LimitAt(created, 1))
+	assert.True(t, ok)
+
+	// ramped up linearly
+	r, burst, warming := limiter.rampAt(created.Add(30 * time.Second))
+	assert.True(t, warming)
+	assert.Equal(t, 20, burst)
+	assert.InDelta(t, 20, float64(r), 1e-9)
+
+	// warmed up
+	assert.NoError(t, limiter.LimitAt(created.Add(2*time.Minute), 1))
+	assert.Equal(t, 30, limiter.inner.Burst())
+	assert.Equal(t, int32(0), limiter.warming)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/Conflux-Chain/confura/util"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,10 +19,14 @@ type KeyInfo struct {
 	AclID uint32    // bound allowlist ID
 	Key   string    // limit key
 	Type  LimitType // limit type
+
+	// HMAC secret to verify signed requests, empty if signing not enabled for the key
+	Secret string
 }
 
 type KeysetFilter struct {
 	SIDs   []uint32 // strategy IDs
+	AclIDs []uint32 // allowlist IDs
 	KeySet []string // limit key set
 	Limit  int      // result limit size (<= 0 means none)
 }
@@ -46,7 +51,9 @@ func NewKeyLoader(ksload ksLoadFunc) *KeyLoader {
 	}
 
 	// warm up limit key cache for better performance
-	kl.warmUpKeyCache()
+	if err := kl.WarmUp(); err != nil {
+		logrus.WithError(err).Warn("Failed to load limit keyset to warm up cache")
+	}
 
 	return kl
 }
@@ -121,16 +128,22 @@ func (kl *KeyLoader) rawLoad(key string) (*KeyInfo, error) {
 	return nil, err
 }
 
-func (kl *KeyLoader) warmUpKeyCache() {
+// WarmUp loads limit keyset from store to warm up limit key cache, eg., as preflight check of
+// warm standby mode.
+func (kl *KeyLoader) WarmUp() error {
 	kis, err := kl.ksload(&KeysetFilter{Limit: (LimitKeyCacheSize * 3 / 4)})
 	if err != nil {
-		logrus.WithError(err).Warn("Failed to load limit keyset to warm up cache")
-		return
+		return errors.WithMessage(err, "failed to load limit keyset")
 	}
 
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
 	for i := range kis {
 		kl.keyCache.Add(kis[i].Key, kis[i])
 	}
 
 	logrus.WithField("totalKeys", len(kis)).Info("Limit keyset loaded to cache")
+
+	return nil
 }
@@ -0,0 +1,33 @@
+package rate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLoaderWarmUp(t *testing.T) {
+	var unavailable bool
+	var loads int
+
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		loads++
+
+		if unavailable {
+			return nil, errors.New("store unavailable")
+		}
+
+		return []*KeyInfo{{Key: "key", SID: 1}}, nil
+	})
+	assert.Equal(t, 1, loads)
+
+	// served from the warmed cache
+	ki, ok := kloader.Load("key")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), ki.SID)
+	assert.Equal(t, 1, loads)
+
+	unavailable = true
+	assert.Error(t, kloader.WarmUp())
+}
@@ -36,6 +36,12 @@ func SVipStatusFromContext(ctx context.Context) (*SVipStatus, bool) {
 	return ki, ok && ki != nil
 }
 
+// isAnonymous checks if the request is sent without any API key.
+func isAnonymous(ctx context.Context) bool {
+	limitKey, ok := handlers.GetAccessTokenFromContext(ctx)
+	return !ok || len(limitKey) == 0
+}
+
 type Registry struct {
 	*http.Registry
 	*aclRegistry
@@ -43,6 +49,13 @@ type Registry struct {
 	mu      sync.Mutex
 	kloader *KeyLoader
 
+	// resolver to get node route group for route group scope ceiling
+	groupResolver RouteGroupResolver
+
+	reloadMu      sync.Mutex
+	reloader      ConfigReloader
+	lastCheckSums ConfigCheckSums // last config finger prints
+
 	// all available strategies
 	strategies    map[string]*Strategy // strategy name => *Strategy
 	id2Strategies map[uint32]*Strategy // strategy id => *Strategy
@@ -68,6 +81,11 @@ func (r *Registry) GetGroupAndKey(
 	ctx context.Context,
 	resource string,
 ) (group, key string, err error) {
+	if scope, ok := ctx.Value(ctxKeyLimitScope).(LimitScope); ok {
+		// use ceiling strategy for the limit scope
+		return r.genScopeGroupAndKey(ctx, resource, scope)
+	}
+
 	authId, ok := handlers.GetAuthIdFromContext(ctx)
 	if !ok {
 		// use default strategy if not authenticated
@@ -88,6 +106,36 @@ func (r *Registry) GetGroupAndKey(
 	return r.genDefaultGroupAndKey(ctx, resource)
 }
 
+// GetLogsLimits returns the `eth_getLogs` limits of strategy assigned to the request context.
+func (r *Registry) GetLogsLimits(ctx context.Context) (*GetLogsLimits, bool) {
+	var ki *KeyInfo
+
+	authId, authenticated := handlers.GetAuthIdFromContext(ctx)
+	vip, isVip := handlers.VipStatusFromContext(ctx)
+
+	if authenticated && !isVip {
+		ki, _ = r.kloader.Load(authId)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stg, ok := r.getDefaultStrategy(ctx)
+
+	switch {
+	case authenticated && isVip: // vip strategy with corresponding tier
+		stg, ok = r.getVipStrategy(vip.Tier)
+	case ki != nil: // strategy with corresponding key info
+		stg, ok = r.id2Strategies[ki.SID]
+	}
+
+	if !ok || stg.GetLogsLimits == nil {
+		return nil, false
+	}
+
+	return stg.GetLogsLimits, true
+}
+
 func (r *Registry) Create(ctx context.Context, resource, group string) (rate.Limiter, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -102,7 +150,7 @@ func (r *Registry) Create(ctx context.Context, resource, group string) (rate.Lim
 		return nil, errors.New("limit rule not found")
 	}
 
-	return r.createWithOption(opt)
+	return createWithOption(opt)
 }
 
 func (r *Registry) genDefaultGroupAndKey(
@@ -112,7 +160,7 @@ func (r *Registry) genDefaultGroupAndKey(
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	stg, ok := r.strategies[DefaultStrategy]
+	stg, ok := r.getDefaultStrategy(ctx)
 	if !ok { // no default strategy
 		logrus.WithField("resource", resource).Info("Default strategy not configured")
 		return
@@ -129,6 +177,19 @@ func (r *Registry) genDefaultGroupAndKey(
 	return stg.Name, key, nil
 }
 
+// getDefaultStrategy returns the anonymous strategy for requests without any API key if configured,
+// otherwise the default strategy.
+func (r *Registry) getDefaultStrategy(ctx context.Context) (*Strategy, bool) {
+	if isAnonymous(ctx) {
+		if stg, ok := r.strategies[AnonymousStrategy]; ok {
+			return stg, true
+		}
+	}
+
+	stg, ok := r.strategies[DefaultStrategy]
+	return stg, ok
+}
+
 func (r *Registry) genVipGroupAndKey(
 	ctx context.Context,
 	resource, limitKey string,
@@ -156,6 +217,29 @@ func (r *Registry) genVipGroupAndKey(
 	return stg.Name, key, nil
 }
 
+// KeyRegistered checks if the limit key is registered in keyset, eg., to validate API key.
+func (r *Registry) KeyRegistered(limitKey string) bool {
+	ki, ok := r.kloader.Load(limitKey)
+	return ok && ki != nil
+}
+
+// KeyStrategy returns the name of rate limit strategy bound to the limit key.
+func (r *Registry) KeyStrategy(limitKey string) (string, bool) {
+	ki, ok := r.kloader.Load(limitKey)
+	if !ok || ki == nil {
+		return "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stg, ok := r.id2Strategies[ki.SID]; ok {
+		return stg.Name, true
+	}
+
+	return "", false
+}
+
 func (r *Registry) genKeyInfoGroupAndKey(
 	ctx context.Context,
 	resource, limitKey string,
@@ -196,12 +280,12 @@ func (r *Registry) genKeyInfoGroupAndKey(
 	return group, key, err
 }
 
-func (r *Registry) createWithOption(option interface{}) (l rate.Limiter, err error) {
+func createWithOption(option interface{}) (l rate.Limiter, err error) {
 	switch opt := option.(type) {
 	case FixedWindowOption:
-		l = rate.NewFixedWindow(opt.Interval, opt.Quota)
+		l = newFixedWindow(opt.Interval, opt.Quota)
 	case TokenBucketOption:
-		l = rate.NewTokenBucket(int(opt.Rate), opt.Burst)
+		l = newTokenBucket(opt)
 	default:
 		err = errors.New("invalid limit option")
 	}
@@ -0,0 +1,237 @@
+package rate
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	web3Types "github.com/openweb3/web3go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowListLimits(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return []*KeyInfo{{Key: "proKey", AclID: 2}}, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{ID: 1, Name: acl.DefaultAllowList})
+	reg.addAllowList(&acl.AllowList{ID: 2, Name: "pro", Limits: &acl.Limits{MaxBatchLength: 500}})
+
+	global := acl.Limits{MaxBatchLength: 100, MaxResponseSize: 1024}
+
+	// anonymous request uses default allowlist without overrides
+	al, ok := reg.AllowList(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, global, *global.Override(al.Limits))
+
+	// allowlist bound to the key overrides global limits
+	proCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "proKey")
+	al, ok = reg.AllowList(proCtx)
+	assert.True(t, ok)
+
+	limits := global.Override(al.Limits)
+	assert.Equal(t, 500, limits.MaxBatchLength)
+	assert.Equal(t, 1024, limits.MaxResponseSize)
+}
+
+func TestAllowListCors(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return []*KeyInfo{{Key: "dappKey", AclID: 2}}, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{ID: 1, Name: acl.DefaultAllowList})
+	reg.addAllowList(&acl.AllowList{ID: 2, Name: "dapp", Cors: &acl.CorsPolicy{
+		AllowedOrigins: []string{"https://dapp.example.com"},
+	}})
+
+	global := acl.CorsPolicy{AllowedOrigins: []string{"*"}, MaxAge: 600}
+
+	al, ok := reg.AllowList(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, global, *global.Override(al.Cors))
+
+	// allowlist bound to the key restricts origins only
+	dappCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "dappKey")
+	al, ok = reg.AllowList(dappCtx)
+	assert.True(t, ok)
+
+	policy := global.Override(al.Cors)
+	assert.Equal(t, []string{"https://dapp.example.com"}, policy.AllowedOrigins)
+	assert.Equal(t, 600, policy.MaxAge)
+}
+
+func TestAllowListRouteGroup(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return []*KeyInfo{{Key: "vipKey", AclID: 2}, {Key: "routedKey", AclID: 2}}, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{ID: 1, Name: acl.DefaultAllowList})
+	reg.addAllowList(&acl.AllowList{ID: 2, Name: "vip", RouteGroup: "archive"})
+
+	// everyone else uses the default group
+	_, ok := reg.resolveRouteGroup(context.Background())
+	assert.False(t, ok)
+
+	// pinned by allowlist
+	vipCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "vipKey")
+	grp, ok := reg.resolveRouteGroup(vipCtx)
+	assert.True(t, ok)
+	assert.Equal(t, "archive", grp)
+
+	// route group of the key takes precedence
+	reg.SetRouteGroupResolver(func(ctx context.Context) (string, bool) {
+		authId, ok := handlers.GetAuthIdFromContext(ctx)
+		return "dedicated", ok && authId == "routedKey"
+	})
+
+	routedCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "routedKey")
+	grp, ok = reg.resolveRouteGroup(routedCtx)
+	assert.True(t, ok)
+	assert.Equal(t, "dedicated", grp)
+}
+
+func TestAllowListBlockedContracts(t *testing.T) {
+	blocked := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return nil, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{
+		ID: 1, Name: acl.DefaultAllowList, BlockedContracts: []string{blocked.Hex()},
+	})
+
+	allow := func(method string, params ...interface{}) error {
+		return reg.Allow(acl.Context{
+			Context:          context.Background(),
+			RpcMethod:        method,
+			ExtractRpcParams: func() ([]interface{}, error) { return params, nil },
+		})
+	}
+
+	// call to blocked contract
+	err := allow("eth_call", web3Types.CallRequest{To: &blocked})
+	assert.IsType(t, &acl.ContractBlockedError{}, err)
+	assert.NoError(t, allow("eth_call", web3Types.CallRequest{To: &other}))
+
+	// raw transaction to blocked contract
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+
+	rawTx := func(to common.Address) hexutil.Bytes {
+		tx := ethTypes.NewTransaction(0, to, big.NewInt(0), 21000, big.NewInt(1), nil)
+		signed, err := ethTypes.SignTx(tx, ethTypes.HomesteadSigner{}, key)
+		assert.NoError(t, err)
+
+		data, err := signed.MarshalBinary()
+		assert.NoError(t, err)
+
+		return data
+	}
+
+	err = allow("eth_sendRawTransaction", rawTx(blocked))
+	assert.IsType(t, &acl.ContractBlockedError{}, err)
+	assert.NoError(t, allow("eth_sendRawTransaction", rawTx(other)))
+
+	// not an interaction with contract
+	assert.NoError(t, allow("eth_getBalance", blocked))
+
+	// calls of simulation or trace in raw params
+	allowRaw := func(method, params string) error {
+		return reg.Allow(acl.Context{
+			Context:   context.Background(),
+			RpcMethod: method,
+			RpcParams: []byte(params),
+		})
+	}
+
+	simulateV1 := func(to common.Address) string {
+		return `[{"blockStateCalls": [{"calls": [{"to": "` + other.Hex() + `"}]}, {"calls": [{}, {"to": "` +
+			to.Hex() + `"}]}]}, "latest"]`
+	}
+
+	err = allowRaw("eth_simulateV1", simulateV1(blocked))
+	assert.IsType(t, &acl.ContractBlockedError{}, err)
+	assert.NoError(t, allowRaw("eth_simulateV1", simulateV1(other)))
+
+	callMany := func(to common.Address) string {
+		return `[[{"transactions": [{"to": "` + other.Hex() + `"}]}, {"transactions": [{"to": "` +
+			to.Hex() + `"}]}], {"blockNumber": "latest"}]`
+	}
+
+	err = allowRaw("eth_callMany", callMany(blocked))
+	assert.IsType(t, &acl.ContractBlockedError{}, err)
+	assert.NoError(t, allowRaw("eth_callMany", callMany(other)))
+
+	err = allowRaw("debug_traceCall", `[{"to": "`+blocked.Hex()+`"}, "latest", {}]`)
+	assert.IsType(t, &acl.ContractBlockedError{}, err)
+	assert.NoError(t, allowRaw("debug_traceCall", `[{"to": "`+other.Hex()+`"}, "latest", {}]`))
+
+	// contract creation
+	assert.NoError(t, allowRaw("debug_traceCall", `[{"data": "0x00"}, "latest"]`))
+
+	// malformed params
+	assert.Error(t, allowRaw("eth_simulateV1", `{"blockStateCalls": []}`))
+}
+
+func TestAllowListBypassTier(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return []*KeyInfo{{Key: "internalKey", AclID: 2}}, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{ID: 1, Name: acl.DefaultAllowList})
+	reg.addAllowList(&acl.AllowList{ID: 2, Name: "internal", BypassTier: acl.BypassTierUnlimited})
+
+	al, ok := reg.AllowList(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, acl.BypassTierStandard, al.BypassTier)
+
+	internalCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "internalKey")
+	al, ok = reg.AllowList(internalCtx)
+	assert.True(t, ok)
+	assert.Equal(t, acl.BypassTierUnlimited, al.BypassTier)
+
+	_, err := acl.ParseAllowList("internal", `{"BypassTier": "monitored"}`, "eth")
+	assert.NoError(t, err)
+
+	_, err = acl.ParseAllowList("internal", `{"BypassTier": "vip"}`, "eth")
+	assert.Error(t, err)
+}
+
+func TestAllowListAnonymous(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return nil, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	reg.addAllowList(&acl.AllowList{ID: 1, Name: acl.DefaultAllowList})
+
+	// fallback to default allowlist if anonymous allowlist not configured
+	al, ok := reg.AllowList(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, acl.DefaultAllowList, al.Name)
+
+	reg.addAllowList(&acl.AllowList{ID: 2, Name: acl.AnonymousAllowList, RouteGroup: "public"})
+
+	al, ok = reg.AllowList(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, "public", al.RouteGroup)
+
+	// unrecognized key still uses default allowlist
+	keyCtx := context.WithValue(context.Background(), handlers.CtxKeyAccessToken, "unknownKey")
+	al, ok = reg.AllowList(keyCtx)
+	assert.True(t, ok)
+	assert.Equal(t, acl.DefaultAllowList, al.Name)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Conflux-Chain/confura/util/acl"
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,30 +27,47 @@ type ConfigCheckSums struct {
 	AllowLists map[uint32][md5.Size]byte
 }
 
-func (m *Registry) AutoReload(interval time.Duration, reloader func() (*Config, error)) {
+// ConfigReloader loads rate limit and access control configs from wherever eg., store.
+type ConfigReloader func() (*Config, error)
+
+func (m *Registry) AutoReload(interval time.Duration, reloader ConfigReloader) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// last config finger prints
-	var cs ConfigCheckSums
+	m.reloadMu.Lock()
+	m.reloader = reloader
+	m.reloadMu.Unlock()
 
 	// load immediately at first
-	if rconf, err := reloader(); err == nil {
-		m.reloadOnce(rconf, &cs)
-		cs = rconf.CheckSums
-	}
+	m.Reload()
 
 	// load periodically
 	for range ticker.C {
-		rconf, err := reloader()
-		if err != nil {
+		if err := m.Reload(); err != nil {
 			logrus.WithError(err).Error("Failed to load rate limit configs")
-			continue
 		}
+	}
+}
 
-		m.reloadOnce(rconf, &cs)
-		cs = rconf.CheckSums
+// Reload reloads rate limit and access control configs immediately, which is used
+// to apply config changes without waiting for the next periodical reloading.
+func (m *Registry) Reload() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if m.reloader == nil {
+		return errors.New("config reloader not available")
+	}
+
+	rconf, err := m.reloader()
+	if err != nil {
+		return err
 	}
+
+	m.reloadOnce(rconf, &m.lastCheckSums)
+	m.lastCheckSums = rconf.CheckSums
+
+	return nil
 }
 
 func (m *Registry) reloadOnce(rc *Config, lastCs *ConfigCheckSums) {
@@ -0,0 +1,186 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/rate"
+	"github.com/Conflux-Chain/go-conflux-util/rate/http"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LimitScope is the scope at which rate limit is evaluated.
+type LimitScope string
+
+const (
+	// process wide ceiling shared by all chains
+	LimitScopeGlobal LimitScope = "global"
+	// ceiling for all requests of a chain
+	LimitScopeChain LimitScope = "chain"
+	// ceiling for all requests routed to the same node route group
+	LimitScopeGroup LimitScope = "group"
+	// strategy for the specific limit key (or IP)
+	LimitScopeKey LimitScope = "key"
+)
+
+const (
+	// pre-defined strategy name for chain scope ceiling
+	ChainStrategy = "chain"
+	// pre-defined strategy name prefix for route group scope ceiling
+	GroupStrategyPrefix = "group."
+
+	ctxKeyLimitScope = handlers.CtxKey("Infura-Rate-Limit-Scope")
+)
+
+var (
+	globalScopeRegistry     *http.Registry
+	globalScopeRegistryOnce sync.Once
+)
+
+// RouteGroupResolver resolves the node route group of the request.
+type RouteGroupResolver func(ctx context.Context) (string, bool)
+
+// SetRouteGroupResolver sets resolver to evaluate the route group scope ceiling.
+func (r *Registry) SetRouteGroupResolver(resolver RouteGroupResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.groupResolver = resolver
+}
+
+// LimitScopes evaluates rate limit at multiple scopes in order of global, chain, route group
+// and key in one pass, and returns the scope of the first rejection if any.
+//
+// Note quota may have been consumed at the preceding scopes if rejected by the subsequent one.
+func (r *Registry) LimitScopes(ctx context.Context, resource string) (LimitScope, error) {
+	if err := getGlobalScopeRegistry().Limit(ctx, resource); err != nil {
+		return LimitScopeGlobal, err
+	}
+
+	for _, scope := range []LimitScope{LimitScopeChain, LimitScopeGroup} {
+		sctx := context.WithValue(ctx, ctxKeyLimitScope, scope)
+		if err := r.Limit(sctx, resource); err != nil {
+			return scope, err
+		}
+	}
+
+	if err := r.Limit(ctx, resource); err != nil {
+		return LimitScopeKey, err
+	}
+
+	return "", nil
+}
+
+// genScopeGroupAndKey generates group and key for the chain or route group scope ceiling.
+func (r *Registry) genScopeGroupAndKey(
+	ctx context.Context, resource string, scope LimitScope,
+) (group, key string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var strategy string
+
+	switch scope {
+	case LimitScopeChain:
+		strategy, key = ChainStrategy, string(LimitScopeChain)
+
+		// additional chain served by the same gateway has its own ceiling
+		if chain, ok := handlers.GetChainFromContext(ctx); ok {
+			strategy, key = ChainStrategy+"."+chain, key+"."+chain
+		}
+	case LimitScopeGroup:
+		grp, ok := r.resolveRouteGroup(ctx)
+		if !ok {
+			return
+		}
+
+		strategy, key = GroupStrategyPrefix+grp, string(LimitScopeGroup)
+	default:
+		return "", "", errors.New("invalid limit scope")
+	}
+
+	stg, ok := r.strategies[strategy]
+	if !ok {
+		// ceiling not configured
+		return "", "", nil
+	}
+
+	if _, ok := stg.LimitOptions[resource]; !ok {
+		// limit rule not defined
+		return "", "", nil
+	}
+
+	return stg.Name, key, nil
+}
+
+// resolveRouteGroup resolves the node route group of the request, which is either routed by
+// the limit key or pinned by the assigned allowlist.
+func (r *Registry) resolveRouteGroup(ctx context.Context) (string, bool) {
+	if r.groupResolver != nil {
+		if grp, ok := r.groupResolver(ctx); ok && len(grp) > 0 {
+			return grp, true
+		}
+	}
+
+	if al, ok := r.AllowList(ctx); ok && len(al.RouteGroup) > 0 {
+		return al.RouteGroup, true
+	}
+
+	return "", false
+}
+
+// globalScopeFactory implements `http.LimiterFactory` for global scope ceiling, which is
+// configured statically since it is shared by all chains.
+type globalScopeFactory struct {
+	strategy *Strategy
+}
+
+func getGlobalScopeRegistry() *http.Registry {
+	globalScopeRegistryOnce.Do(func() {
+		globalScopeRegistry = http.NewRegistry(mustNewGlobalScopeFactoryFromViper())
+		go globalScopeRegistry.ScheduleGC(GCScheduleInterval)
+	})
+
+	return globalScopeRegistry
+}
+
+func mustNewGlobalScopeFactoryFromViper() *globalScopeFactory {
+	var conf struct {
+		// global strategy in JSON format
+		GlobalStrategy string
+	}
+	viper.MustUnmarshalKey("ratelimit", &conf)
+
+	strategy := NewStrategy(0, string(LimitScopeGlobal))
+	if len(conf.GlobalStrategy) > 0 {
+		if err := json.Unmarshal([]byte(conf.GlobalStrategy), strategy); err != nil {
+			logrus.WithError(err).Fatal("Invalid global rate limit strategy configured")
+		}
+	}
+
+	return &globalScopeFactory{strategy: strategy}
+}
+
+func (f *globalScopeFactory) GetGroupAndKey(
+	ctx context.Context, resource string,
+) (group, key string, err error) {
+	if _, ok := f.strategy.LimitOptions[resource]; !ok {
+		// limit rule not defined
+		return "", "", nil
+	}
+
+	return f.strategy.Name, string(LimitScopeGlobal), nil
+}
+
+func (f *globalScopeFactory) Create(ctx context.Context, resource, group string) (rate.Limiter, error) {
+	opt, ok := f.strategy.LimitOptions[resource]
+	if !ok {
+		return nil, errors.New("limit rule not found")
+	}
+
+	return createWithOption(opt)
+}
@@ -0,0 +1,78 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitScopes(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return nil, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	chainStg := NewStrategy(1, ChainStrategy)
+	chainStg.LimitOptions["rpc_all_qps"] = FixedWindowOption{Interval: time.Minute, Quota: 3}
+	groupStg := NewStrategy(2, GroupStrategyPrefix+"archive")
+	groupStg.LimitOptions["rpc_all_qps"] = FixedWindowOption{Interval: time.Minute, Quota: 1}
+	reg.addStrategy(chainStg)
+	reg.addStrategy(groupStg)
+
+	reg.SetRouteGroupResolver(func(ctx context.Context) (string, bool) {
+		authId, ok := handlers.GetAuthIdFromContext(ctx)
+		return "archive", ok && authId == "archiveKey"
+	})
+
+	archiveCtx := context.WithValue(context.Background(), handlers.CtxKeyAuthId, "archiveKey")
+
+	// route group ceiling is more restrictive
+	_, err := reg.LimitScopes(archiveCtx, "rpc_all_qps")
+	assert.NoError(t, err)
+
+	scope, err := reg.LimitScopes(archiveCtx, "rpc_all_qps")
+	assert.Error(t, err)
+	assert.Equal(t, LimitScopeGroup, scope)
+
+	// chain ceiling exhausted by other traffic
+	_, err = reg.LimitScopes(context.Background(), "rpc_all_qps")
+	assert.NoError(t, err)
+
+	scope, err = reg.LimitScopes(context.Background(), "rpc_all_qps")
+	assert.Error(t, err)
+	assert.Equal(t, LimitScopeChain, scope)
+
+	// no limit rule defined
+	_, err = reg.LimitScopes(context.Background(), "eth_call_qps")
+	assert.NoError(t, err)
+}
+
+func TestAnonymousStrategy(t *testing.T) {
+	kloader := NewKeyLoader(func(filter *KeysetFilter) ([]*KeyInfo, error) {
+		return nil, nil
+	})
+	reg := NewRegistry(kloader, acl.NewEthValidator)
+
+	defaultStg := NewStrategy(1, DefaultStrategy)
+	defaultStg.LimitOptions["rpc_all_qps"] = FixedWindowOption{Interval: time.Minute, Quota: 2}
+	anonymousStg := NewStrategy(2, AnonymousStrategy)
+	anonymousStg.LimitOptions["rpc_all_qps"] = FixedWindowOption{Interval: time.Minute, Quota: 1}
+	reg.addStrategy(defaultStg)
+	reg.addStrategy(anonymousStg)
+
+	ctx := context.WithValue(context.Background(), handlers.CtxKeyRealIP, "127.0.0.1")
+	keyCtx := context.WithValue(ctx, handlers.CtxKeyAccessToken, "unknownKey")
+
+	// anonymous traffic is limited separately
+	assert.NoError(t, reg.Limit(ctx, "rpc_all_qps"))
+	assert.Error(t, reg.Limit(ctx, "rpc_all_qps"))
+
+	// requests with unrecognized key from the same IP are not starved
+	assert.NoError(t, reg.Limit(keyCtx, "rpc_all_qps"))
+	assert.NoError(t, reg.Limit(keyCtx, "rpc_all_qps"))
+	assert.Error(t, reg.Limit(keyCtx, "rpc_all_qps"))
+}
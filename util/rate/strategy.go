@@ -2,6 +2,7 @@ package rate
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,6 +28,12 @@ const (
 const (
 	// pre-defined default strategy name
 	DefaultStrategy = "default"
+	// pre-defined strategy name for anonymous requests without any API key, which falls back
+	// to the default strategy if not configured
+	AnonymousStrategy = "anonymous"
+
+	// reserved key of `eth_getLogs` limits in strategy rules
+	GetLogsLimitsKey = "getLogsLimits"
 )
 
 // Strategy rate limit strategy
@@ -35,6 +42,16 @@ type Strategy struct {
 	Name string // strategy name
 
 	LimitOptions map[string]interface{} // resource => limit option
+
+	GetLogsLimits *GetLogsLimits // limits of `eth_getLogs` query, nil if unlimited
+}
+
+// GetLogsLimits limits of `eth_getLogs` query, where zero value means unlimited.
+type GetLogsLimits struct {
+	MaxBlockRange uint64 // max number of blocks in range
+	MaxAddresses  int    // max number of contract addresses
+	MaxTopics     int    // max number of topics of all dimensions
+	MaxLogs       int    // max number of returned logs
 }
 
 func NewStrategy(id uint32, name string) *Strategy {
@@ -47,12 +64,26 @@ func NewStrategy(id uint32, name string) *Strategy {
 
 // UnmarshalJSON implements `json.Unmarshaler`
 func (s *Strategy) UnmarshalJSON(data []byte) error {
-	tmpRules := make(map[string]*LimitRule)
+	tmpRules := make(map[string]json.RawMessage)
 	if err := json.Unmarshal(data, &tmpRules); err != nil {
 		return errors.WithMessage(err, "malformed json format")
 	}
 
-	for resource, rule := range tmpRules {
+	for resource, data := range tmpRules {
+		if resource == GetLogsLimitsKey {
+			s.GetLogsLimits = &GetLogsLimits{}
+			if err := json.Unmarshal(data, s.GetLogsLimits); err != nil {
+				return errors.WithMessage(err, "malformed getLogs limits")
+			}
+
+			continue
+		}
+
+		var rule LimitRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return errors.WithMessagef(err, "malformed limit rule of resource %v", resource)
+		}
+
 		s.LimitOptions[resource] = rule.Option
 	}
 
@@ -86,10 +117,71 @@ func (fwo *FixedWindowOption) UnmarshalJSON(data []byte) error {
 	return err
 }
 
-// FixedWindowOption limit option for token bucket
+// TokenBucketOption limit option for token bucket
 type TokenBucketOption struct {
-	Rate  rate.Limit
+	// sustained rate per second, which could be fractional, eg., 0.5
+	Rate rate.Limit
+	// burst capacity, which defaults to the sustained rate if zero
 	Burst int
+	// period for new (or long idle) keys to ramp up from the cold rate and burst, no warm-up if
+	// zero, so that abusive clients can't burst with freshly created keys
+	Warmup time.Duration
+	// rate and burst are divided by cold factor at the beginning of warm-up, which defaults to 3
+	ColdFactor float64
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`
+func (tbo *TokenBucketOption) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		Rate       rate.Limit
+		Burst      int
+		Warmup     string
+		ColdFactor float64
+	}
+
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	if tmp.Rate <= 0 || tmp.Burst < 0 {
+		return errors.New("rate must be positive and burst must not be negative")
+	}
+
+	if tmp.ColdFactor != 0 && tmp.ColdFactor < 1 {
+		return errors.New("cold factor must not be less than 1")
+	}
+
+	tbo.Rate, tbo.Burst, tbo.ColdFactor = tmp.Rate, tmp.Burst, tmp.ColdFactor
+
+	if len(tmp.Warmup) == 0 {
+		return nil
+	}
+
+	warmup, err := time.ParseDuration(tmp.Warmup)
+	if err != nil {
+		return err
+	}
+
+	if warmup < 0 {
+		return errors.New("warm-up period must not be negative")
+	}
+
+	tbo.Warmup = warmup
+
+	return nil
+}
+
+// withDefaults returns a copy of option with default burst and cold factor applied.
+func (tbo TokenBucketOption) withDefaults() TokenBucketOption {
+	if tbo.Burst == 0 {
+		tbo.Burst = int(math.Ceil(float64(tbo.Rate)))
+	}
+
+	if tbo.ColdFactor == 0 {
+		tbo.ColdFactor = 3
+	}
+
+	return tbo
 }
 
 func NewTokenBucketOption(r, b int) TokenBucketOption {
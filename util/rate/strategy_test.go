@@ -31,3 +31,49 @@ func TestUnmarshalStrategy(t *testing.T) {
 	fwopt := FixedWindowOption{Interval: 24 * time.Hour, Quota: 100000}
 	assert.Equal(t, fwopt, stg.LimitOptions["rpc_all_daily"])
 }
+
+func TestUnmarshalStrategyGetLogsLimits(t *testing.T) {
+	stgJsonStr := `{
+		"rpc_all_qps": {
+			"algo": "token_bucket",
+			"option": {"rate": 100, "burst":1000}
+		},
+		"getLogsLimits": {"maxBlockRange": 1000, "maxAddresses": 10, "maxLogs": 5000}
+	}`
+
+	stg := NewStrategy(1, "default")
+
+	err := json.Unmarshal(([]byte)(stgJsonStr), &stg)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(stg.LimitOptions))
+	assert.Equal(t, &GetLogsLimits{MaxBlockRange: 1000, MaxAddresses: 10, MaxLogs: 5000}, stg.GetLogsLimits)
+
+	stg = NewStrategy(2, "nolimits")
+	err = json.Unmarshal([]byte(`{"rpc_all_qps": {"algo": "unknown"}}`), &stg)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalStrategyTokenBucketWarmup(t *testing.T) {
+	stgJsonStr := `{
+		"rpc_all_qps": {
+			"algo": "token_bucket",
+			"option": {"rate": 0.5, "warmup": "1m", "coldFactor": 4}
+		}
+	}`
+
+	stg := NewStrategy(1, "default")
+
+	err := json.Unmarshal(([]byte)(stgJsonStr), &stg)
+	assert.NoError(t, err)
+
+	tbopt := TokenBucketOption{Rate: 0.5, Warmup: time.Minute, ColdFactor: 4}
+	assert.Equal(t, tbopt, stg.LimitOptions["rpc_all_qps"])
+
+	// burst defaults to the sustained rate
+	assert.Equal(t, 1, tbopt.withDefaults().Burst)
+
+	for _, option := range []string{`{"rate": 0}`, `{"rate": 1, "burst": -1}`, `{"rate": 1, "coldFactor": 0.5}`, `{"rate": 1, "warmup": "-1s"}`} {
+		err = json.Unmarshal([]byte(`{"rpc_all_qps": {"algo": "token_bucket", "option": `+option+`}}`), &stg)
+		assert.Error(t, err, option)
+	}
+}
@@ -1,6 +1,8 @@
 package rate
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 
 const (
 	LimitKeyLength = 32
+	SecretLength   = 32
 )
 
 func GenerateRandomLimitKey(limitType LimitType) (string, error) {
@@ -28,3 +31,13 @@ func GenerateRandomLimitKey(limitType LimitType) (string, error) {
 
 	return string(secretb), nil
 }
+
+// GenerateRandomSecret generates a cryptographically secure random HMAC secret.
+func GenerateRandomSecret() (string, error) {
+	data := make([]byte, SecretLength)
+	if _, err := crand.Read(data); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(data), nil
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultEmailSubject = "Daily usage report of {{.AllowList}} ({{.Network}}) on {{.Date}}"
+
+	defaultEmailTemplate = `Daily usage report of {{.AllowList}} on {{.Network}} space for {{.Date}} (UTC).
+{{range .Keys}}
+API key {{.ApiKey}}
+  Usage: {{.Requests}} requests, {{.ComputeUnits}} compute units
+  Failures: {{.Failures}} requests, of which {{.RateLimited}} rejected by rate limit or quota
+{{- if .Methods}}
+  Top methods:
+{{- range .Methods}}
+    {{.Method}}: {{.ComputeUnits}} compute units of {{.Requests}} requests
+{{- end}}{{end}}
+{{- if .Errors}}
+  Top error codes:
+{{- range .Errors}}
+    {{.Code}}: {{.Requests}} requests
+{{- end}}{{end}}
+{{end}}`
+)
+
+// WebhookConfig webhook delivery configurations.
+type WebhookConfig struct {
+	// timeout to post report
+	Timeout time.Duration `default:"10s"`
+}
+
+// EmailConfig email delivery configurations.
+type EmailConfig struct {
+	// SMTP server address (`host:port`), or email delivery is unavailable if empty
+	Server string
+	// username and password to authenticate with SMTP server if required
+	Username string
+	Password string
+	// sender email address
+	From string
+	// subject template (`text/template`) of report
+	Subject string
+	// body template (`text/template`) file of report, or the built-in plain text one if empty
+	Template string
+}
+
+type webhookClient struct {
+	client *http.Client
+}
+
+func newWebhookClient(conf *WebhookConfig) *webhookClient {
+	return &webhookClient{client: &http.Client{Timeout: conf.Timeout}}
+}
+
+// post posts the JSON encoded report to webhook.
+func (c *webhookClient) post(ctx context.Context, url string, report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected HTTP status %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sendMailFunc sends email via SMTP server, which is `smtp.SendMail` unless in test.
+type sendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+type emailSender struct {
+	conf     *EmailConfig
+	subject  *template.Template
+	body     *template.Template
+	sendMail sendMailFunc
+}
+
+func newEmailSender(conf *EmailConfig) (*emailSender, error) {
+	subjectText := conf.Subject
+	if len(subjectText) == 0 {
+		subjectText = defaultEmailSubject
+	}
+
+	subject, err := template.New("subject").Parse(subjectText)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid subject template")
+	}
+
+	bodyText := defaultEmailTemplate
+	if len(conf.Template) > 0 {
+		data, err := ioutil.ReadFile(conf.Template)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to read template file")
+		}
+
+		bodyText = string(data)
+	}
+
+	body, err := template.New("body").Parse(bodyText)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid body template")
+	}
+
+	if len(conf.Server) > 0 && len(conf.From) == 0 {
+		return nil, errors.New("sender email address not specified")
+	}
+
+	return &emailSender{
+		conf:     conf,
+		subject:  subject,
+		body:     body,
+		sendMail: smtp.SendMail,
+	}, nil
+}
+
+// send renders the report with templates, and sends it to the recipients.
+func (s *emailSender) send(to []string, report *Report) error {
+	if len(s.conf.Server) == 0 {
+		return errors.New("SMTP server not configured")
+	}
+
+	var subject, body bytes.Buffer
+
+	if err := s.subject.Execute(&subject, report); err != nil {
+		return errors.WithMessage(err, "failed to render subject")
+	}
+
+	if err := s.body.Execute(&body, report); err != nil {
+		return errors.WithMessage(err, "failed to render body")
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString("From: " + s.conf.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + strings.ReplaceAll(subject.String(), "\n", " ") + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if len(s.conf.Username) > 0 {
+		host, _, err := net.SplitHostPort(s.conf.Server)
+		if err != nil {
+			return errors.WithMessage(err, "invalid SMTP server address")
+		}
+
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, host)
+	}
+
+	return s.sendMail(s.conf.Server, auth, s.conf.From, to, msg.Bytes())
+}
@@ -0,0 +1,348 @@
+// Package report delivers daily usage reports of API keys to the destinations configured by the
+// bound allowlists, including requests and compute units per method, rate limit hits and top
+// error codes, so that customers could self-diagnose quota and error issues before filing tickets.
+//
+// Reports are aggregated from the usages and failures persisted by usage metering, hence metering
+// should be enabled for RPC servers.
+package report
+
+import (
+	"context"
+	"crypto/md5"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRateLimitCodes JSON-RPC error codes of requests rejected by rate limit or compute units
+// quota if not configured.
+var defaultRateLimitCodes = []int{-32005, metering.ErrCodeQuotaExceeded}
+
+// Config daily usage report configurations.
+type Config struct {
+	// switch to turn on/off daily usage reports
+	Enabled bool
+	// UTC time of day (`15:04`) to report usages of the previous day
+	At string `default:"01:00"`
+	// max number of methods reported per API key, ordered by compute units
+	TopMethods int `default:"10"`
+	// max number of error codes reported per API key, ordered by failed requests
+	TopErrors int `default:"5"`
+	// JSON-RPC error codes of requests rejected by rate limit or quota
+	RateLimitCodes []int
+	Webhook        WebhookConfig
+	Email          EmailConfig
+}
+
+// AllowListLoader loads allowlists, eg., from config store.
+type AllowListLoader interface {
+	LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[uint32][md5.Size]byte, error)
+}
+
+// KeyLoader loads API keys, eg., from rate limit keyset store.
+type KeyLoader interface {
+	LoadRateLimitKeyInfos(filter *rate.KeysetFilter) ([]*rate.KeyInfo, error)
+}
+
+// Space stores of network space to report usages.
+type Space struct {
+	Name       string // network space, eg., `cfx` or `eth`
+	AllowLists AllowListLoader
+	Keys       KeyLoader
+	Usages     metering.Store
+}
+
+// MethodUsage usage of RPC method in a day.
+type MethodUsage struct {
+	Method       string `json:"method"`
+	Requests     uint64 `json:"requests"`
+	ComputeUnits uint64 `json:"computeUnits"`
+}
+
+// ErrorCount failed requests of JSON-RPC error code in a day.
+type ErrorCount struct {
+	Code     int    `json:"code"`
+	Requests uint64 `json:"requests"`
+}
+
+// KeyReport daily usage report of API key. Note, requests rejected by rate limit or quota are
+// only counted as failures rather than metered requests.
+type KeyReport struct {
+	ApiKey       string         `json:"apiKey"` // masked API key
+	Requests     uint64         `json:"requests"`
+	ComputeUnits uint64         `json:"computeUnits"`
+	Failures     uint64         `json:"failures"`
+	RateLimited  uint64         `json:"rateLimited"`
+	Methods      []*MethodUsage `json:"methods"` // top methods by compute units
+	Errors       []*ErrorCount  `json:"errors"`  // top error codes by failed requests
+}
+
+// Report daily usage report of API keys bound to an allowlist.
+type Report struct {
+	Network   string       `json:"network"`
+	AllowList string       `json:"allowList"`
+	Date      string       `json:"date"` // UTC date in format of `metering.DateLayout`
+	Keys      []*KeyReport `json:"keys"`
+}
+
+// Reporter aggregates daily usage reports per allowlist, and delivers them to the destinations
+// configured by allowlist.
+type Reporter struct {
+	conf   *Config
+	at     time.Duration // offset of report time in UTC day
+	spaces []*Space
+	email  *emailSender
+	client *webhookClient
+}
+
+// MustNewReporterFromViper creates daily usage reporter of spaces from viper, or nil if disabled.
+func MustNewReporterFromViper(spaces ...*Space) *Reporter {
+	var conf Config
+	viper.MustUnmarshalKey("report", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	reporter, err := NewReporter(&conf, spaces...)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid daily usage report config")
+	}
+
+	// config not logged as is to hide the SMTP password
+	logrus.WithField("at", conf.At).Info("Daily usage report enabled")
+
+	return reporter
+}
+
+func NewReporter(conf *Config, spaces ...*Space) (*Reporter, error) {
+	at, err := time.Parse("15:04", conf.At)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid report time of day")
+	}
+
+	if conf.TopMethods < 0 || conf.TopErrors < 0 {
+		return nil, errors.New("number of top methods or errors must not be negative")
+	}
+
+	if len(conf.RateLimitCodes) == 0 {
+		conf.RateLimitCodes = defaultRateLimitCodes
+	}
+
+	email, err := newEmailSender(&conf.Email)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid email config")
+	}
+
+	return &Reporter{
+		conf:   conf,
+		at:     time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute,
+		spaces: spaces,
+		email:  email,
+		client: newWebhookClient(&conf.Webhook),
+	}, nil
+}
+
+// Run reports usages of the previous day at the configured time daily until context done.
+func (r *Reporter) Run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	for {
+		next := nextRunTime(time.Now(), r.at)
+		logrus.WithField("next", next).Info("Daily usage report scheduled")
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		date := next.AddDate(0, 0, -1).Format(metering.DateLayout)
+		if err := r.ReportDay(ctx, date); err != nil {
+			logrus.WithError(err).WithField("date", date).Error("Failed to deliver daily usage reports")
+		}
+	}
+}
+
+// nextRunTime returns the next time to report after now, which is at the offset of UTC day.
+func nextRunTime(now time.Time, at time.Duration) time.Time {
+	now = now.UTC()
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(at)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// ReportDay delivers usage reports of the UTC date (in format of `metering.DateLayout`) for all
+// allowlists with report policy. Allowlists failed to report are skipped, and an error is returned
+// at last if any.
+func (r *Reporter) ReportDay(ctx context.Context, date string) error {
+	var total, failed int
+
+	for _, space := range r.spaces {
+		allowLists, _, err := space.AllowLists.LoadAclAllowListConfigs()
+		if err != nil {
+			return errors.WithMessagef(err, "failed to load allowlists of %v space", space.Name)
+		}
+
+		for _, al := range allowLists {
+			if al.Report == nil || ctx.Err() != nil {
+				continue
+			}
+
+			total++
+
+			logger := logrus.WithFields(logrus.Fields{
+				"network": space.Name, "allowList": al.Name, "date": date,
+			})
+
+			report, err := r.build(space, al, date)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to aggregate daily usage report")
+				failed++
+				continue
+			}
+
+			if len(report.Keys) == 0 {
+				continue
+			}
+
+			if err := r.deliver(ctx, al.Report, report); err != nil {
+				logger.WithError(err).Warn("Failed to deliver daily usage report")
+				failed++
+				continue
+			}
+
+			logger.WithField("keys", len(report.Keys)).Debug("Daily usage report delivered")
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%v out of %v allowlists failed to report", failed, total)
+	}
+
+	return ctx.Err()
+}
+
+// build aggregates usage report of API keys bound to the allowlist in the UTC date.
+func (r *Reporter) build(space *Space, al *acl.AllowList, date string) (*Report, error) {
+	keys, err := space.Keys.LoadRateLimitKeyInfos(&rate.KeysetFilter{AclIDs: []uint32{al.ID}})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load API keys")
+	}
+
+	report := &Report{Network: space.Name, AllowList: al.Name, Date: date, Keys: []*KeyReport{}}
+
+	for _, key := range keys {
+		if key.AclID != al.ID {
+			continue
+		}
+
+		kr, err := r.buildKey(space.Usages, key.Key, date)
+		if err != nil {
+			return nil, err
+		}
+
+		if kr.Requests > 0 || kr.Failures > 0 {
+			report.Keys = append(report.Keys, kr)
+		}
+	}
+
+	sort.Slice(report.Keys, func(i, j int) bool {
+		return report.Keys[i].ComputeUnits > report.Keys[j].ComputeUnits
+	})
+
+	return report, nil
+}
+
+func (r *Reporter) buildKey(store metering.Store, apiKey, date string) (*KeyReport, error) {
+	usages, err := store.LoadUsages(apiKey, date, date)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load usages")
+	}
+
+	failures, err := store.LoadFailures(apiKey, date, date)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load failures")
+	}
+
+	kr := &KeyReport{ApiKey: maskKey(apiKey), Methods: []*MethodUsage{}, Errors: []*ErrorCount{}}
+
+	for _, u := range usages {
+		kr.Requests += u.Requests
+		kr.ComputeUnits += u.ComputeUnits
+		kr.Methods = append(kr.Methods, &MethodUsage{
+			Method: u.Method, Requests: u.Requests, ComputeUnits: u.ComputeUnits,
+		})
+	}
+
+	for _, f := range failures {
+		kr.Failures += f.Requests
+		kr.Errors = append(kr.Errors, &ErrorCount{Code: f.Code, Requests: f.Requests})
+
+		for _, code := range r.conf.RateLimitCodes {
+			if f.Code == code {
+				kr.RateLimited += f.Requests
+			}
+		}
+	}
+
+	sort.SliceStable(kr.Methods, func(i, j int) bool {
+		return kr.Methods[i].ComputeUnits > kr.Methods[j].ComputeUnits
+	})
+
+	sort.SliceStable(kr.Errors, func(i, j int) bool {
+		return kr.Errors[i].Requests > kr.Errors[j].Requests
+	})
+
+	if len(kr.Methods) > r.conf.TopMethods {
+		kr.Methods = kr.Methods[:r.conf.TopMethods]
+	}
+
+	if len(kr.Errors) > r.conf.TopErrors {
+		kr.Errors = kr.Errors[:r.conf.TopErrors]
+	}
+
+	return kr, nil
+}
+
+// deliver delivers the report to all destinations of the report policy.
+func (r *Reporter) deliver(ctx context.Context, policy *acl.ReportPolicy, report *Report) error {
+	if len(policy.Webhook) > 0 {
+		if err := r.client.post(ctx, policy.Webhook, report); err != nil {
+			return errors.WithMessage(err, "failed to post webhook")
+		}
+	}
+
+	if len(policy.Emails) > 0 {
+		if err := r.email.send(policy.Emails, report); err != nil {
+			return errors.WithMessage(err, "failed to send email")
+		}
+	}
+
+	return nil
+}
+
+// maskKey masks the API key except the leading and trailing few characters, so that the key is
+// recognizable by customers but not leaked via reports.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+
+	return key[:4] + "****" + key[len(key)-4:]
+}
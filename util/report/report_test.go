@@ -0,0 +1,182 @@
+package report
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	allowLists map[uint32]*acl.AllowList
+	keys       []*rate.KeyInfo
+	usages     []*metering.Usage
+	failures   []*metering.Failure
+}
+
+func (s *memStore) LoadAclAllowListConfigs() (map[uint32]*acl.AllowList, map[uint32][md5.Size]byte, error) {
+	return s.allowLists, nil, nil
+}
+
+func (s *memStore) LoadRateLimitKeyInfos(filter *rate.KeysetFilter) (res []*rate.KeyInfo, err error) {
+	for _, ki := range s.keys {
+		for _, aclID := range filter.AclIDs {
+			if ki.AclID == aclID {
+				res = append(res, ki)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memStore) AddUsages(usages []*metering.Usage) error { return nil }
+
+func (s *memStore) LoadUsages(apiKey, fromDate, toDate string) (res []*metering.Usage, err error) {
+	for _, u := range s.usages {
+		if u.ApiKey == apiKey && u.Date >= fromDate && u.Date <= toDate {
+			res = append(res, u)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memStore) AddFailures(failures []*metering.Failure) error { return nil }
+
+func (s *memStore) LoadFailures(apiKey, fromDate, toDate string) (res []*metering.Failure, err error) {
+	for _, f := range s.failures {
+		if f.ApiKey == apiKey && f.Date >= fromDate && f.Date <= toDate {
+			res = append(res, f)
+		}
+	}
+
+	return res, nil
+}
+
+func newTestStore(policy *acl.ReportPolicy) *memStore {
+	return &memStore{
+		allowLists: map[uint32]*acl.AllowList{
+			1: {ID: 1, Name: "dapp", Report: policy},
+			2: {ID: 2, Name: "unreported"},
+		},
+		keys: []*rate.KeyInfo{
+			{Key: "dappKey0123456789", AclID: 1},
+			{Key: "idleKey0123456789", AclID: 1},
+			{Key: "otherKey0123456789", AclID: 2},
+		},
+		usages: []*metering.Usage{
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Method: "eth_call", Requests: 10, ComputeUnits: 200},
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Method: "eth_getLogs", Requests: 4, ComputeUnits: 300},
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Method: "eth_chainId", Requests: 5, ComputeUnits: 5},
+			{ApiKey: "dappKey0123456789", Date: "2024-01-03", Method: "eth_call", Requests: 1, ComputeUnits: 20},
+			{ApiKey: "otherKey0123456789", Date: "2024-01-02", Method: "eth_call", Requests: 1, ComputeUnits: 20},
+		},
+		failures: []*metering.Failure{
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Code: -32005, Requests: 7},
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Code: -32007, Requests: 2},
+			{ApiKey: "dappKey0123456789", Date: "2024-01-02", Code: 3, Requests: 8},
+		},
+	}
+}
+
+func newTestReporter(t *testing.T, store *memStore) *Reporter {
+	reporter, err := NewReporter(&Config{
+		At:         "01:00",
+		TopMethods: 2,
+		TopErrors:  2,
+		Webhook:    WebhookConfig{Timeout: time.Second},
+		Email:      EmailConfig{Server: "127.0.0.1:25", From: "noreply@example.com"},
+	}, &Space{Name: "eth", AllowLists: store, Keys: store, Usages: store})
+	assert.NoError(t, err)
+
+	return reporter
+}
+
+func TestReportBuild(t *testing.T) {
+	store := newTestStore(&acl.ReportPolicy{Emails: []string{"ops@example.com"}})
+	reporter := newTestReporter(t, store)
+
+	report, err := reporter.build(reporter.spaces[0], store.allowLists[1], "2024-01-02")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Report{
+		Network:   "eth",
+		AllowList: "dapp",
+		Date:      "2024-01-02",
+		Keys: []*KeyReport{{
+			ApiKey:       "dapp****6789",
+			Requests:     19,
+			ComputeUnits: 505,
+			Failures:     17,
+			RateLimited:  9,
+			Methods: []*MethodUsage{
+				{Method: "eth_getLogs", Requests: 4, ComputeUnits: 300},
+				{Method: "eth_call", Requests: 10, ComputeUnits: 200},
+			},
+			Errors: []*ErrorCount{{Code: 3, Requests: 8}, {Code: -32005, Requests: 7}},
+		}},
+	}, report)
+}
+
+func TestReportDay(t *testing.T) {
+	var posted []*Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		posted = append(posted, &report)
+	}))
+	defer server.Close()
+
+	store := newTestStore(&acl.ReportPolicy{Webhook: server.URL, Emails: []string{"ops@example.com"}})
+	reporter := newTestReporter(t, store)
+
+	var mails [][]byte
+	reporter.email.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "127.0.0.1:25", addr)
+		assert.Equal(t, []string{"ops@example.com"}, to)
+		mails = append(mails, msg)
+		return nil
+	}
+
+	assert.NoError(t, reporter.ReportDay(context.Background(), "2024-01-02"))
+
+	assert.Len(t, posted, 1)
+	assert.Equal(t, "dapp", posted[0].AllowList)
+	assert.Len(t, posted[0].Keys, 1)
+
+	assert.Len(t, mails, 1)
+	assert.Contains(t, string(mails[0]), "Subject: Daily usage report of dapp (eth) on 2024-01-02\r\n")
+	assert.Contains(t, string(mails[0]), "API key dapp****6789\n  Usage: 19 requests, 505 compute units\n")
+	assert.Contains(t, string(mails[0]), "    -32005: 7 requests\n")
+
+	// nothing to report without any usage
+	assert.NoError(t, reporter.ReportDay(context.Background(), "2024-01-01"))
+	assert.Len(t, posted, 1)
+
+	// failed to deliver
+	server.Close()
+	assert.Error(t, reporter.ReportDay(context.Background(), "2024-01-02"))
+}
+
+func TestNextRunTime(t *testing.T) {
+	at := time.Hour
+
+	now := time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC), nextRunTime(now, at))
+
+	now = time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC), nextRunTime(now, at))
+
+	now = time.Date(2024, 1, 2, 8, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	assert.Equal(t, time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC), nextRunTime(now, at))
+}
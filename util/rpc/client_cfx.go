@@ -4,6 +4,7 @@ import (
 	"time"
 
 	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
 	"github.com/sirupsen/logrus"
 )
 
@@ -60,9 +61,24 @@ func NewCfxClient(url string, options ...ClientOption) (*sdk.Client, error) {
 	}
 
 	cfx, err := sdk.NewClient(url, *opt.ClientOption)
-	if err == nil && opt.hookMetrics {
+	if err != nil {
+		return nil, err
+	}
+
+	if cfxClientCfg.Transport.Enabled && isHttpUrl(url) {
+		p := newPooledProvider(url, providers.Option{
+			RetryCount:           opt.RetryCount,
+			RetryInterval:        opt.RetryInterval,
+			RequestTimeout:       opt.RequestTimeout,
+			MaxConnectionPerHost: opt.MaxConnectionPerHost,
+		}, &cfxClientCfg.Transport)
+		cfx.Provider().Close()
+		cfx.MiddlewarableProvider = p
+	}
+
+	if opt.hookMetrics {
 		HookMiddlewares(cfx.Provider(), url, "cfx")
 	}
 
-	return cfx, err
+	return cfx, nil
 }
@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/openweb3/go-rpc-provider/interfaces"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+)
+
+// contextProvider binds RPC requests to a context, so that the deadline or cancellation of
+// the context is propagated to full node, since typed RPC clients always request with the
+// background context.
+type contextProvider struct {
+	interfaces.Provider
+
+	ctx context.Context
+}
+
+// NewContextProvider creates a provider of which RPC requests are bound to the specified context,
+// which replaces the bound context if the provider is already bound, including the one wrapped by
+// RPC client without any hooks, eg., `web3go.Client` bound to another context.
+func NewContextProvider(ctx context.Context, p interfaces.Provider) interfaces.Provider {
+	if mp, ok := p.(*providers.MiddlewarableProvider); ok {
+		if bound, ok := mp.Inner.(*contextProvider); ok {
+			p = bound
+		}
+	}
+
+	if bound, ok := p.(*contextProvider); ok {
+		p = bound.Provider
+	}
+
+	return &contextProvider{Provider: p, ctx: ctx}
+}
+
+// bind derives from the bound context with the earlier deadline of the specified context if any.
+func (p *contextProvider) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(p.ctx, deadline)
+	}
+
+	return context.WithCancel(p.ctx)
+}
+
+func (p *contextProvider) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := p.bind(ctx)
+	defer cancel()
+
+	return p.Provider.CallContext(ctx, result, method, args...)
+}
+
+func (p *contextProvider) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	ctx, cancel := p.bind(ctx)
+	defer cancel()
+
+	return p.Provider.BatchCallContext(ctx, b)
+}
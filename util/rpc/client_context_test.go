@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/openweb3/go-rpc-provider/interfaces"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/stretchr/testify/assert"
+)
+
+// ctxRecordProvider records the context of the last RPC request.
+type ctxRecordProvider struct {
+	interfaces.Provider
+
+	ctx context.Context
+}
+
+func (p *ctxRecordProvider) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	p.ctx = ctx
+	return ctx.Err()
+}
+
+func (p *ctxRecordProvider) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	p.ctx = ctx
+	return ctx.Err()
+}
+
+func TestContextProvider(t *testing.T) {
+	inner := &ctxRecordProvider{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bound := NewContextProvider(ctx, inner)
+
+	// deadline of request propagated
+	reqCtx, reqCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer reqCancel()
+
+	assert.NoError(t, bound.CallContext(reqCtx, nil, "eth_blockNumber"))
+	_, ok := inner.ctx.Deadline()
+	assert.True(t, ok)
+
+	// cancelled along with the bound context
+	cancel()
+	assert.Equal(t, context.Canceled, bound.CallContext(context.Background(), nil, "eth_blockNumber"))
+
+	// rebound rather than nested, including the one wrapped by RPC client
+	for _, p := range []interfaces.Provider{bound, providers.NewMiddlewarableProvider(bound)} {
+		rebound := NewContextProvider(context.Background(), p)
+		assert.NoError(t, rebound.CallContext(context.Background(), nil, "eth_blockNumber"))
+		assert.NoError(t, rebound.BatchCallContext(context.Background(), nil))
+	}
+}
@@ -59,9 +59,19 @@ func NewEthClient(url string, options ...ClientOption) (*web3go.Client, error) {
 	}
 
 	eth, err := web3go.NewClientWithOption(url, opt.ClientOption)
-	if err == nil && opt.hookMetrics {
+	if err != nil {
+		return nil, err
+	}
+
+	if ethClientCfg.Transport.Enabled && isHttpUrl(url) {
+		p := newPooledProvider(url, opt.Option, &ethClientCfg.Transport)
+		eth.Provider().Close()
+		eth.SetProvider(p)
+	}
+
+	if opt.hookMetrics {
 		HookMiddlewares(eth.Provider(), url, "eth")
 	}
 
-	return eth, err
+	return eth, nil
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rate"
 	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
 	"github.com/openweb3/go-rpc-provider/utils"
 	"github.com/sirupsen/logrus"
@@ -24,6 +25,13 @@ func HookMiddlewares(provider *providers.MiddlewarableProvider, url, space strin
 	nodeName := Url2NodeName(url)
 	provider.HookCallContext(middlewareLog(nodeName, space))
 	provider.HookCallContext(middlewareMetrics(nodeName, space))
+
+	if space == "eth" {
+		if spec := mustLoadEthSchemaSpec(); spec != nil {
+			sampleRate := ethClientCfg.SchemaValidation.SampleRate
+			provider.HookCallContext(middlewareSchemaValidation(nodeName, spec, sampleRate))
+		}
+	}
 }
 
 func middlewareMetrics(fullnode, space string) providers.CallContextMiddleware {
@@ -42,6 +50,9 @@ func middlewareMetrics(fullnode, space string) providers.CallContextMiddleware {
 			metrics.Registry.RPC.FullnodeNonRpcErrorRate().Mark(nonRpcErr)
 			metrics.Registry.RPC.FullnodeNonRpcErrorRate(fullnode).Mark(nonRpcErr)
 
+			// feedback of upstream health for adaptive rate limiting
+			rate.ObserveUpstream(time.Since(start), nonRpcErr)
+
 			return err
 		}
 	}
@@ -18,6 +18,9 @@ type clientConfig struct {
 	RetryInterval   time.Duration `default:"1s"`
 	RequestTimeout  time.Duration `default:"3s"`
 	MaxConnsPerHost int           `default:"1024"`
+	Transport       transportConfig
+	// only applicable to evm space
+	SchemaValidation schemaValidationConfig
 }
 
 type ClientOptioner interface {
@@ -11,9 +11,15 @@ type CtxKey string
 const (
 	CtxKeyRateRegistry = CtxKey("Infura-Rate-Limit-Registry")
 	CtxKeyAuthId       = CtxKey("Infura-Auth-ID")
+	CtxKeyUsageMeter   = CtxKey("Infura-Usage-Meter")
 
 	CtxKeyRealIP      = CtxKey("Infura-Real-IP")
 	CtxKeyAccessToken = CtxKey("Infura-Access-Token")
 	CtxKeyReqOrigin   = CtxKey("Infura-Req-Origin")
 	CtxKeyUserAgent   = CtxKey("Infura-User-Agent")
+
+	CtxKeyChain = CtxKey("Infura-Chain")
+
+	// access token mapped from the verified client certificate of mutual TLS
+	CtxKeyClientCertToken = CtxKey("Infura-Client-Cert-Token")
 )
@@ -0,0 +1,25 @@
+package handlers
+
+import "context"
+
+const ctxKeyChainHead = CtxKey("Infura-Chain-Head")
+
+// ChainHeadFunc returns the latest block (or epoch) number of chain, or false if unknown.
+type ChainHeadFunc func() (uint64, bool)
+
+// WithChainHead returns a context to resolve the chain head on demand, eg., to resolve the
+// block tags of `getLogs` filter.
+func WithChainHead(ctx context.Context, head ChainHeadFunc) context.Context {
+	return context.WithValue(ctx, ctxKeyChainHead, head)
+}
+
+// GetChainHeadFromContext returns the latest block (or epoch) number of chain, or false if
+// unknown or not resolvable from context.
+func GetChainHeadFromContext(ctx context.Context) (uint64, bool) {
+	head, ok := ctx.Value(ctxKeyChainHead).(ChainHeadFunc)
+	if !ok || head == nil {
+		return 0, false
+	}
+
+	return head()
+}
@@ -104,6 +104,11 @@ func GetAccessToken(r *http.Request) string {
 		return ""
 	}
 
+	// client certificate takes precedence over access token in URL path
+	if token, ok := r.Context().Value(CtxKeyClientCertToken).(string); ok {
+		return token
+	}
+
 	// access token path pattern:
 	// http://example.com/${accessToken}...
 	key := strings.TrimLeft(r.URL.EscapedPath(), "/")
@@ -133,6 +138,13 @@ func GetUserAgentFromContext(ctx context.Context) (string, bool) {
 	return val, ok
 }
 
+// GetChainFromContext returns the name of additional chain served by the same gateway, or false
+// for the default chain.
+func GetChainFromContext(ctx context.Context) (string, bool) {
+	chain, ok := ctx.Value(CtxKeyChain).(string)
+	return chain, ok
+}
+
 func GetAuthIdFromContext(ctx context.Context) (string, bool) {
 	authId, ok := ctx.Value(CtxKeyAuthId).(string)
 	return authId, ok
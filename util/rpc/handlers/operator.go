@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OperatorHeader request header to identify the operator of management request (eg., admin REST
+// APIs or node management RPC) for config change audit.
+const OperatorHeader = "X-Admin-Operator"
+
+// GetOperator returns the operator identity of management request prefixed with the source, eg.,
+// `admin`, which falls back to the remote IP address if not specified.
+func GetOperator(source string, r *http.Request) string {
+	if op := strings.TrimSpace(r.Header.Get(OperatorHeader)); len(op) > 0 {
+		return source + ":" + op
+	}
+
+	return source + "@" + GetIPAddress(r)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const CtxKeyTraceId = CtxKey("Infura-Trace-ID")
+
+// GetTraceId returns the trace ID of HTTP request from W3C `traceparent` header or `X-Request-Id`
+// header, or generates a random one if absent.
+func GetTraceId(r *http.Request) string {
+	// traceparent: {version}-{trace-id}-{parent-id}-{trace-flags}
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+
+	if id := r.Header.Get("X-Request-Id"); len(id) > 0 && len(id) <= 128 {
+		return id
+	}
+
+	var id [16]byte
+	rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
+
+func GetTraceIdFromContext(ctx context.Context) (string, bool) {
+	traceId, ok := ctx.Value(CtxKeyTraceId).(string)
+	return traceId, ok
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+const ctxKeyUpstream = CtxKey("Infura-Upstream-Node")
+
+// upstreamRecorder records the full node that served the request, which might be set in another
+// goroutine, eg., request timed out.
+type upstreamRecorder struct {
+	mu   sync.Mutex
+	node string
+}
+
+// WithUpstreamRecorder returns a context to record the full node that served the request, or the
+// context itself if already recordable.
+func WithUpstreamRecorder(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ctxKeyUpstream).(*upstreamRecorder); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxKeyUpstream, &upstreamRecorder{})
+}
+
+// RecordUpstream records the full node that served the request if recordable.
+func RecordUpstream(ctx context.Context, nodeName string) {
+	if recorder, ok := ctx.Value(ctxKeyUpstream).(*upstreamRecorder); ok {
+		recorder.mu.Lock()
+		recorder.node = nodeName
+		recorder.mu.Unlock()
+	}
+}
+
+// GetUpstreamFromContext returns the recorded full node that served the request.
+func GetUpstreamFromContext(ctx context.Context) (string, bool) {
+	recorder, ok := ctx.Value(ctxKeyUpstream).(*upstreamRecorder)
+	if !ok {
+		return "", false
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	return recorder.node, len(recorder.node) > 0
+}
@@ -0,0 +1,231 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// remote address of IPC connections, which are always local
+	ipcRemoteAddr = "127.0.0.1:0"
+
+	// JSON-RPC error code when IPC connection rejected by middlewares
+	errCodeIpcRejected = -32000
+)
+
+// IpcConfig configurations of JSON-RPC over Unix domain socket (IPC) for co-located services,
+// eg., indexers or bots on the same host, to bypass the TCP stack.
+type IpcConfig struct {
+	// socket path, IPC disabled if empty
+	Path string
+	// file mode (octal) of socket, so that only the authorized users or groups could connect
+	Mode string `default:"0660"`
+	// access token on behalf of all IPC connections, eg., to apply allowlist and rate limits,
+	// which are anonymous if empty
+	AccessToken string
+}
+
+func mustLoadIpcConfig(key string) IpcConfig {
+	var conf IpcConfig
+	viper.MustUnmarshalKey(key, &conf)
+
+	if _, err := strconv.ParseUint(conf.Mode, 8, 32); err != nil {
+		logrus.WithField("config", conf).Fatal("Invalid file mode of IPC socket")
+	}
+
+	return conf
+}
+
+// ipcServer serves newline delimited JSON-RPC messages over Unix domain socket, which is
+// compatible with the IPC clients of geth. Each connection is bridged to an in-process websocket
+// connection along with a synthetic upgrade request, which goes through the same middlewares as
+// websocket to resolve the request context, eg., access token, rate registry and client provider.
+type ipcServer struct {
+	conf        IpcConfig
+	ws          http.Handler // websocket handler without middlewares
+	middlewares []handlers.Middleware
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+func newIpcServer(conf IpcConfig, ws http.Handler, middlewares []handlers.Middleware) *ipcServer {
+	return &ipcServer{conf: conf, ws: ws, middlewares: middlewares}
+}
+
+// listen creates the Unix domain socket of the specified path, whose file mode is restricted as
+// configured.
+func (s *ipcServer) listen(path string) (net.Listener, error) {
+	mode, _ := strconv.ParseUint(s.conf.Mode, 8, 32)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, errors.WithMessage(err, "failed to create socket directory")
+	}
+
+	// remove the leftover of previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithMessage(err, "failed to remove stale socket")
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		listener.Close()
+		return nil, errors.WithMessage(err, "failed to change socket file mode")
+	}
+
+	return listener, nil
+}
+
+// Serve accepts IPC connections until listener closed.
+func (s *ipcServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting new connections, while the accepted ones are closed along with the
+// in-process websocket connections once RPC handler stopped.
+func (s *ipcServer) Shutdown() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	// socket file is removed once closed
+	return s.listener.Close()
+}
+
+func (s *ipcServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/"+url.PathEscape(s.conf.AccessToken), nil)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create request for IPC connection")
+		return
+	}
+
+	r.RemoteAddr = ipcRemoteAddr
+	r.Header.Set("Upgrade", "websocket")
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.bridge(r.Context(), conn, w)
+	})
+
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		handler = s.middlewares[i](handler)
+	}
+
+	handler.ServeHTTP(&ipcResponseWriter{conn: conn}, r)
+}
+
+// bridge relays JSON-RPC messages between the IPC connection and an in-process websocket
+// connection until either closed.
+func (s *ipcServer) bridge(ctx context.Context, conn net.Conn, w http.ResponseWriter) {
+	ws, status, err := dialInProcess(ctx, s.ws, "ws://ipc/")
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer ws.Close()
+
+	// websocket connection is closed once IPC connection closed or malformed message received,
+	// which in turn terminates the relay of responses below
+	go func() {
+		defer ws.Close()
+
+		decoder := json.NewDecoder(conn)
+		for {
+			var msg json.RawMessage
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+
+			if err := ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		// exactly one message per line, which is encoded with trailing newline already
+		if _, err := conn.Write(append(bytes.TrimSpace(data), '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// ipcResponseWriter writes the HTTP error of middlewares (eg., too many connections) to the IPC
+// connection as JSON-RPC error.
+type ipcResponseWriter struct {
+	conn   net.Conn
+	header http.Header
+}
+
+func (w *ipcResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+
+	return w.header
+}
+
+func (w *ipcResponseWriter) WriteHeader(statusCode int) {}
+
+func (w *ipcResponseWriter) Write(data []byte) (int, error) {
+	n := len(data)
+
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) { // plain text error
+		data, _ = json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      nil,
+			"error": map[string]interface{}{
+				"code":    errCodeIpcRejected,
+				"message": string(data),
+			},
+		})
+	}
+
+	if _, err := w.conn.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
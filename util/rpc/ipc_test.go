@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type ipcTestService struct{}
+
+func (s *ipcTestService) AccessToken(ctx context.Context) string {
+	token, _ := handlers.GetAccessTokenFromContext(ctx)
+	return token
+}
+
+func TestIPC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipc")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	handler := rpc.NewServer()
+	assert.NoError(t, handler.RegisterName("test", &ipcTestService{}))
+	defer handler.Stop()
+
+	// inject access token as the HTTP middleware of RPC server
+	tokenMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), handlers.CtxKeyAccessToken, handlers.GetAccessToken(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	conf := IpcConfig{Mode: "0600", AccessToken: "ipckey"}
+	server := newIpcServer(conf, handler.WebsocketHandler([]string{"*"}), []handlers.Middleware{tokenMiddleware})
+
+	path := filepath.Join(dir, "test.ipc")
+	listener, err := server.listen(path)
+	assert.NoError(t, err)
+
+	go server.Serve(listener)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// single call
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_accessToken","params":[]}`))
+	assert.NoError(t, err)
+
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"ipckey"}`, line)
+
+	// batch call
+	_, err = conn.Write([]byte(`[{"jsonrpc":"2.0","id":2,"method":"test_accessToken","params":[]}]`))
+	assert.NoError(t, err)
+
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":2,"result":"ipckey"}]`, line)
+
+	// socket removed once shutdown
+	assert.NoError(t, server.Shutdown())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIPCRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipc")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "too many websocket connections", http.StatusTooManyRequests)
+		})
+	}
+
+	server := newIpcServer(IpcConfig{Mode: "0600"}, http.NotFoundHandler(), []handlers.Middleware{reject})
+
+	listener, err := server.listen(filepath.Join(dir, "test.ipc"))
+	assert.NoError(t, err)
+	defer server.Shutdown()
+
+	go server.Serve(listener)
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "test.ipc"))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"too many websocket connections"}}`, line)
+}
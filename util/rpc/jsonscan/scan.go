@@ -0,0 +1,308 @@
+// Package jsonscan scans JSON-RPC messages lazily without allocation, so that hot paths (eg.,
+// middlewares inspecting method and params of every request) avoid a full `encoding/json`
+// unmarshal. Values are returned as sub-slices of the scanned data, which must not be modified.
+//
+// Note, JSON syntax is checked only as far as needed to find value boundaries, and messages are
+// still validated when decoded by RPC server, so callers should fall back to `encoding/json` or
+// skip the fast path if scanning fails.
+package jsonscan
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Request is the method, ID and params of a JSON-RPC request.
+type Request struct {
+	// method name without quotes, nil if absent or not a string
+	Method []byte
+	// raw JSON of request ID, nil if absent, eg., notification
+	ID []byte
+	// raw JSON of params, nil if absent
+	Params []byte
+}
+
+// IsBatch checks if the message is a batch request, ie., a JSON array.
+func IsBatch(data []byte) bool {
+	i := skipSpace(data, 0)
+	return i < len(data) && data[i] == '['
+}
+
+// ScanRequest scans the method, ID and params of a single JSON-RPC request, and returns false
+// if the message is not a JSON object.
+func ScanRequest(data []byte) (req Request, ok bool) {
+	ok = ForEachField(data, func(key, value []byte) bool {
+		switch string(key) { // no allocation for switch on converted string
+		case "method":
+			req.Method, _ = String(value)
+		case "id":
+			req.ID = value
+		case "params":
+			req.Params = value
+		}
+
+		return true
+	})
+
+	return req, ok
+}
+
+// ForEachField iterates the fields of JSON object in order until fn returns false, with the key
+// without quotes and the raw JSON value. Returns false if data is not a JSON object.
+func ForEachField(data []byte, fn func(key, value []byte) bool) bool {
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return false
+	}
+
+	i = skipSpace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return trailing(data, i+1)
+	}
+
+	for i < len(data) {
+		if data[i] != '"' {
+			return false
+		}
+
+		end, ok := skipString(data, i)
+		if !ok {
+			return false
+		}
+
+		key := data[i+1 : end-1]
+
+		i = skipSpace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return false
+		}
+
+		start := skipSpace(data, i+1)
+		if end, ok = skipValue(data, start); !ok {
+			return false
+		}
+
+		if !fn(key, data[start:end]) {
+			return true
+		}
+
+		i = skipSpace(data, end)
+		if i >= len(data) {
+			return false
+		}
+
+		switch data[i] {
+		case ',':
+			i = skipSpace(data, i+1)
+		case '}':
+			return trailing(data, i+1)
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// ForEachElement iterates the elements of JSON array in order until fn returns false, with the
+// index and the raw JSON element. Returns false if data is not a JSON array.
+func ForEachElement(data []byte, fn func(index int, elem []byte) bool) bool {
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '[' {
+		return false
+	}
+
+	i = skipSpace(data, i+1)
+	if i < len(data) && data[i] == ']' {
+		return trailing(data, i+1)
+	}
+
+	for index := 0; i < len(data); index++ {
+		end, ok := skipValue(data, i)
+		if !ok {
+			return false
+		}
+
+		if !fn(index, data[i:end]) {
+			return true
+		}
+
+		i = skipSpace(data, end)
+		if i >= len(data) {
+			return false
+		}
+
+		switch data[i] {
+		case ',':
+			i = skipSpace(data, i+1)
+		case ']':
+			return trailing(data, i+1)
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// Field returns the raw JSON value of the object field, whose key is matched case-insensitively
+// and the last one wins if duplicated, which is the same as `encoding/json`.
+func Field(data []byte, key string) (value []byte, ok bool) {
+	ForEachField(data, func(k, v []byte) bool {
+		if bytes.IndexByte(k, '\\') >= 0 { // rarely escaped
+			k = unescape(k)
+		}
+
+		if len(k) == len(key) && bytes.EqualFold(k, []byte(key)) {
+			value, ok = v, true
+		}
+
+		return true
+	})
+
+	return value, ok
+}
+
+// Element returns the raw JSON element of the array at index.
+func Element(data []byte, index int) (elem []byte, ok bool) {
+	ForEachElement(data, func(i int, e []byte) bool {
+		if i == index {
+			elem, ok = e, true
+			return false
+		}
+
+		return true
+	})
+
+	return elem, ok
+}
+
+// Len returns the number of elements of JSON array, or false if data is not a JSON array.
+func Len(data []byte) (n int, ok bool) {
+	ok = ForEachElement(data, func(int, []byte) bool {
+		n++
+		return true
+	})
+
+	return n, ok
+}
+
+// String returns the decoded JSON string, or false if value is not a JSON string. It allocates
+// only if the string contains escapes.
+func String(value []byte) ([]byte, bool) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return nil, false
+	}
+
+	contents := value[1 : len(value)-1]
+	if bytes.IndexByte(contents, '\\') < 0 {
+		return contents, true
+	}
+
+	var decoded string
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return nil, false
+	}
+
+	return []byte(decoded), true
+}
+
+// unescape decodes the escaped contents of JSON string, or returns as it is if malformed.
+func unescape(contents []byte) []byte {
+	quoted := make([]byte, 0, len(contents)+2)
+	quoted = append(append(append(quoted, '"'), contents...), '"')
+
+	if decoded, ok := String(quoted); ok {
+		return decoded
+	}
+
+	return contents
+}
+
+// IsNull checks if the raw JSON value is null.
+func IsNull(value []byte) bool {
+	return string(value) == "null"
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+
+	return i
+}
+
+// trailing checks that nothing but whitespace follows the top level value.
+func trailing(data []byte, i int) bool {
+	return skipSpace(data, i) == len(data)
+}
+
+// skipValue returns the end offset of JSON value starting at offset i.
+func skipValue(data []byte, i int) (int, bool) {
+	if i >= len(data) {
+		return i, false
+	}
+
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		return skipContainer(data, i)
+	case '}', ']', ',', ':':
+		return i, false
+	default: // number, true, false or null
+		start := i
+		for i < len(data) && !isSpace(data[i]) && data[i] != ',' && data[i] != '}' && data[i] != ']' {
+			i++
+		}
+
+		return i, i > start
+	}
+}
+
+// skipString returns the end offset of JSON string starting at offset i, ie., the opening quote.
+func skipString(data []byte, i int) (int, bool) {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '"':
+			return i + 1, true
+		}
+	}
+
+	return i, false
+}
+
+// skipContainer returns the end offset of JSON object or array starting at offset i, which only
+// checks that brackets are balanced.
+func skipContainer(data []byte, i int) (int, bool) {
+	depth := 0
+
+	for i < len(data) {
+		switch data[i] {
+		case '"':
+			end, ok := skipString(data, i)
+			if !ok {
+				return end, false
+			}
+
+			i = end
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth--; depth == 0 {
+				return i + 1, true
+			}
+		}
+
+		i++
+	}
+
+	return i, false
+}
@@ -0,0 +1,141 @@
+package jsonscan
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRequest = []byte(`{"jsonrpc":"2.0", "id": {"a":"}"}, "method":"eth_getLogs",
+	"params":[{"fromBlock":"0x1","toBlock":"0x10","topics":[["0x\"a"], null]}, true]}`)
+
+func TestScanRequest(t *testing.T) {
+	req, ok := ScanRequest(testRequest)
+	assert.True(t, ok)
+	assert.Equal(t, "eth_getLogs", string(req.Method))
+	assert.Equal(t, `{"a":"}"}`, string(req.ID))
+	assert.Equal(t, `[{"fromBlock":"0x1","toBlock":"0x10","topics":[["0x\"a"], null]}, true]`, string(req.Params))
+
+	// notification
+	req, ok = ScanRequest([]byte(` {"method":"eth_subscription","params":[]} `))
+	assert.True(t, ok)
+	assert.Nil(t, req.ID)
+
+	// empty object
+	req, ok = ScanRequest([]byte(`{}`))
+	assert.True(t, ok)
+	assert.Nil(t, req.Method)
+
+	// escaped method
+	req, ok = ScanRequest([]byte(`{"method":"eth\u005fcall"}`))
+	assert.True(t, ok)
+	assert.Equal(t, "eth_call", string(req.Method))
+
+	for _, malformed := range []string{
+		``, `[]`, `null`, `{`, `{"id":1,}`, `{"id" 1}`, `{"id":}`, `{"id":1} x`, `{"id":"1}`, `{"id":[1}`,
+	} {
+		_, ok := ScanRequest([]byte(malformed))
+		assert.False(t, ok, malformed)
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	assert.True(t, IsBatch([]byte("\n [{}]")))
+	assert.False(t, IsBatch(testRequest))
+	assert.False(t, IsBatch(nil))
+}
+
+func TestElementAndField(t *testing.T) {
+	req, _ := ScanRequest(testRequest)
+
+	n, ok := Len(req.Params)
+	assert.True(t, ok)
+	assert.Equal(t, 2, n)
+
+	filter, ok := Element(req.Params, 0)
+	assert.True(t, ok)
+
+	last, ok := Element(req.Params, 1)
+	assert.True(t, ok)
+	assert.Equal(t, "true", string(last))
+
+	_, ok = Element(req.Params, 2)
+	assert.False(t, ok)
+
+	// case insensitive
+	from, ok := Field(filter, "FromBlock")
+	assert.True(t, ok)
+	assert.Equal(t, `"0x1"`, string(from))
+
+	topics, ok := Field(filter, "topics")
+	assert.True(t, ok)
+	assert.Equal(t, `[["0x\"a"], null]`, string(topics))
+
+	_, ok = Field(filter, "address")
+	assert.False(t, ok)
+
+	// last one wins
+	dup, _ := Field([]byte(`{"a":1,"a":2}`), "a")
+	assert.Equal(t, "2", string(dup))
+
+	// escaped key
+	escaped, ok := Field([]byte(`{"from\u0042lock":"0x1"}`), "fromBlock")
+	assert.True(t, ok)
+	assert.Equal(t, `"0x1"`, string(escaped))
+}
+
+func TestString(t *testing.T) {
+	s, ok := String([]byte(`"0x1"`))
+	assert.True(t, ok)
+	assert.Equal(t, "0x1", string(s))
+
+	s, ok = String([]byte(`"\u0030x\"1"`))
+	assert.True(t, ok)
+	assert.Equal(t, `0x"1`, string(s))
+
+	_, ok = String([]byte(`"\x"`))
+	assert.False(t, ok)
+
+	_, ok = String([]byte(`null`))
+	assert.False(t, ok)
+	assert.True(t, IsNull([]byte(`null`)))
+}
+
+func TestScanZeroAllocation(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		req, _ := ScanRequest(testRequest)
+		filter, _ := Element(req.Params, 0)
+		Field(filter, "toBlock")
+	})
+
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkScanRequest(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		req, _ := ScanRequest(testRequest)
+		filter, _ := Element(req.Params, 0)
+		Field(filter, "toBlock")
+	}
+}
+
+func BenchmarkUnmarshalRequest(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(testRequest, &req)
+
+		var params []struct {
+			ToBlock *string `json:"toBlock"`
+		}
+		json.Unmarshal(req.Params, &params)
+	}
+}
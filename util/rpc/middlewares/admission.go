@@ -0,0 +1,283 @@
+package middlewares
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// PriorityClass is a class of requests with the same priority, which is classified by the
+// allowlist assigned to the request (eg., `vip1`) and the method cost in compute units. Method
+// could end with `*` to match by prefix, eg., `debug_*`.
+type PriorityClass struct {
+	Name string
+	// names of the allowlists to match, empty means any
+	AllowLists []string
+	// methods to match, empty means any
+	Methods []string
+	// range of method compute units to match, 0 means unbounded
+	MinCost uint64
+	MaxCost uint64
+	// max number of requests queued when overloaded, 0 means shed immediately
+	QueueSize int
+	// max duration to wait in queue before shed, which is also the retry hint
+	MaxWait time.Duration
+}
+
+// AdmissionConfig configurations of admission control under overload.
+type AdmissionConfig struct {
+	// switch to turn on/off admission control
+	Enabled bool
+	// max number of requests handled concurrently, beyond which requests are queued by priority
+	MaxConcurrency int `default:"1000"`
+	// priority classes from the highest to the lowest, matched in order, and the lowest one
+	// is used for requests not classified
+	Classes []PriorityClass
+}
+
+// admissionError JSON-RPC error when request shed due to overload, with a retry hint in seconds.
+type admissionError struct {
+	class      string
+	retryAfter time.Duration
+}
+
+func (e *admissionError) Error() string {
+	return fmt.Sprintf("server overloaded, %v request rejected, retry after %vs", e.class, e.retryAfterSecs())
+}
+
+func (e *admissionError) ErrorCode() int { return errCodeLimitExceeded }
+
+func (e *admissionError) retryAfterSecs() int64 {
+	return int64(math.Ceil(e.retryAfter.Seconds()))
+}
+
+// jsonError returns JSON-RPC error with the retry hint attached as data.
+func (e *admissionError) jsonError() *rpc.JsonError {
+	return &rpc.JsonError{
+		Code:    e.ErrorCode(),
+		Message: e.Error(),
+		Data:    map[string]int64{"retryAfter": e.retryAfterSecs()},
+	}
+}
+
+// admissionWaiter is a request queued for the concurrency slot.
+type admissionWaiter struct {
+	granted chan struct{}
+}
+
+// AdmissionControl limits the number of requests handled concurrently. Once the limit reached,
+// requests are queued per priority class, and the slot released is granted to the queued request
+// of the highest priority. Requests are shed if queue is full or waited too long.
+type AdmissionControl struct {
+	conf AdmissionConfig
+
+	mu       sync.Mutex
+	inflight int
+	queues   []*list.List // class index => queued waiters
+}
+
+// MustNewAdmissionControlFromViper creates an instance of AdmissionControl from viper, or nil
+// if disabled.
+func MustNewAdmissionControlFromViper() *AdmissionControl {
+	var conf AdmissionConfig
+	viper.MustUnmarshalKey("rpc.admission", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.MaxConcurrency <= 0 || len(conf.Classes) == 0 {
+		logrus.WithField("config", conf).Fatal("Invalid RPC admission control config")
+	}
+
+	for _, class := range conf.Classes {
+		if len(class.Name) == 0 || class.QueueSize < 0 || class.MaxWait <= 0 ||
+			(class.MaxCost > 0 && class.MinCost > class.MaxCost) {
+			logrus.WithField("class", class).Fatal("Invalid RPC admission priority class")
+		}
+	}
+
+	logrus.WithField("config", conf).Info("Admission control RPC middleware enabled")
+
+	return NewAdmissionControl(conf)
+}
+
+func NewAdmissionControl(conf AdmissionConfig) *AdmissionControl {
+	queues := make([]*list.List, len(conf.Classes))
+	for i := range queues {
+		queues[i] = list.New()
+	}
+
+	return &AdmissionControl{conf: conf, queues: queues}
+}
+
+// classify returns the index of priority class for the request.
+func (ac *AdmissionControl) classify(ctx context.Context, method string) int {
+	var allowlist string
+	if registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry); ok {
+		if al, ok := registry.AllowList(ctx); ok {
+			allowlist = al.Name
+		}
+	}
+
+	var cost uint64
+	if meter, ok := ctx.Value(handlers.CtxKeyUsageMeter).(*metering.Meter); ok {
+		cost = meter.Weight(method)
+	}
+
+	for i, class := range ac.conf.Classes {
+		if class.match(allowlist, method, cost) {
+			return i
+		}
+	}
+
+	return len(ac.conf.Classes) - 1
+}
+
+func (c *PriorityClass) match(allowlist, method string, cost uint64) bool {
+	if cost < c.MinCost || (c.MaxCost > 0 && cost > c.MaxCost) {
+		return false
+	}
+
+	if len(c.AllowLists) > 0 && !containsFold(c.AllowLists, allowlist) {
+		return false
+	}
+
+	return len(c.Methods) == 0 || matchMethod(c.Methods, method)
+}
+
+// matchMethod checks if method matches any of the patterns, which could end with `*` to match
+// by prefix.
+func matchMethod(patterns []string, method string) bool {
+	for _, m := range patterns {
+		if m == method || (strings.HasSuffix(m, "*") && strings.HasPrefix(method, m[:len(m)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// acquire acquires a concurrency slot for the request of the specified class, and returns
+// error if shed.
+func (ac *AdmissionControl) acquire(ctx context.Context, classIdx int) *admissionError {
+	class := &ac.conf.Classes[classIdx]
+
+	ac.mu.Lock()
+
+	if ac.inflight < ac.conf.MaxConcurrency && ac.numQueued() == 0 {
+		ac.inflight++
+		ac.mu.Unlock()
+
+		metrics.Registry.RPC.Admission(class.Name, "admitted").Mark(1)
+		return nil
+	}
+
+	queue := ac.queues[classIdx]
+	if queue.Len() >= class.QueueSize {
+		ac.mu.Unlock()
+
+		metrics.Registry.RPC.Admission(class.Name, "shed").Mark(1)
+		return &admissionError{class: class.Name, retryAfter: class.MaxWait}
+	}
+
+	waiter := &admissionWaiter{granted: make(chan struct{})}
+	elem := queue.PushBack(waiter)
+
+	ac.mu.Unlock()
+
+	metrics.Registry.RPC.Admission(class.Name, "queued").Mark(1)
+
+	timer := time.NewTimer(class.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	select {
+	case <-waiter.granted: // granted just before dequeued
+		return nil
+	default:
+		queue.Remove(elem)
+	}
+
+	metrics.Registry.RPC.Admission(class.Name, "shed").Mark(1)
+
+	return &admissionError{class: class.Name, retryAfter: class.MaxWait}
+}
+
+// release grants the concurrency slot to the queued request of the highest priority if any,
+// otherwise returns the slot.
+func (ac *AdmissionControl) release() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for _, queue := range ac.queues {
+		if elem := queue.Front(); elem != nil {
+			queue.Remove(elem)
+			close(elem.Value.(*admissionWaiter).granted)
+			return
+		}
+	}
+
+	ac.inflight--
+}
+
+func (ac *AdmissionControl) numQueued() (n int) {
+	for _, queue := range ac.queues {
+		n += queue.Len()
+	}
+
+	return n
+}
+
+// Call admits requests by priority under overload, which passes through if AdmissionControl
+// is nil. Note, subscriptions are always admitted since they are long-lived.
+func (ac *AdmissionControl) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if ac == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if strings.HasSuffix(msg.Method, "_subscribe") || strings.HasSuffix(msg.Method, "_unsubscribe") {
+			return next(ctx, msg)
+		}
+
+		if err := ac.acquire(ctx, ac.classify(ctx, msg.Method)); err != nil {
+			return msg.ErrorResponse(err.jsonError())
+		}
+
+		defer ac.release()
+
+		return next(ctx, msg)
+	}
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityClassMatch(t *testing.T) {
+	class := PriorityClass{AllowLists: []string{"vip1"}, Methods: []string{"trace_*", "eth_call"}, MinCost: 10}
+
+	assert.True(t, class.match("VIP1", "trace_block", 20))
+	assert.True(t, class.match("vip1", "eth_call", 10))
+	assert.False(t, class.match("vip1", "eth_call", 5))
+	assert.False(t, class.match("default", "eth_call", 20))
+	assert.False(t, class.match("vip1", "eth_getBalance", 20))
+
+	// matches any
+	class = PriorityClass{MaxCost: 10}
+	assert.True(t, class.match("", "eth_getBalance", 1))
+	assert.False(t, class.match("", "eth_getBalance", 11))
+}
+
+func TestAdmissionControl(t *testing.T) {
+	ac := NewAdmissionControl(AdmissionConfig{
+		MaxConcurrency: 1,
+		Classes: []PriorityClass{
+			{Name: "high", Methods: []string{"eth_high"}, QueueSize: 1, MaxWait: time.Second},
+			{Name: "low", QueueSize: 1, MaxWait: time.Second},
+		},
+	})
+
+	release := make(chan struct{})
+	order := make(chan string, 3)
+
+	handler := ac.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if msg.Method == "eth_block" {
+			<-release
+		}
+
+		order <- msg.Method
+		return &rpc.JsonRpcMessage{}
+	})
+
+	call := func(method string) chan *rpc.JsonRpcMessage {
+		resp := make(chan *rpc.JsonRpcMessage, 1)
+		go func() { resp <- handler(context.Background(), &rpc.JsonRpcMessage{Method: method}) }()
+		time.Sleep(10 * time.Millisecond)
+		return resp
+	}
+
+	// occupy the only slot
+	blocked := call("eth_block")
+
+	// queued
+	low := call("eth_low")
+	high := call("eth_high")
+
+	// shed since queue is full
+	resp := <-call("eth_high")
+	assert.NotNil(t, resp.Error)
+
+	// released slot granted to the high priority one first
+	close(release)
+	assert.Nil(t, (<-blocked).Error)
+	assert.Nil(t, (<-high).Error)
+	assert.Nil(t, (<-low).Error)
+	assert.Equal(t, "eth_block", <-order)
+	assert.Equal(t, "eth_high", <-order)
+	assert.Equal(t, "eth_low", <-order)
+}
+
+func TestAdmissionControlMaxWait(t *testing.T) {
+	ac := NewAdmissionControl(AdmissionConfig{
+		MaxConcurrency: 1,
+		Classes:        []PriorityClass{{Name: "any", QueueSize: 1, MaxWait: 20 * time.Millisecond}},
+	})
+
+	assert.Nil(t, ac.acquire(context.Background(), 0))
+
+	// waited too long
+	err := ac.acquire(context.Background(), 0)
+	assert.NotNil(t, err)
+	assert.Zero(t, ac.numQueued())
+
+	// slot returned once released
+	ac.release()
+	assert.Nil(t, ac.acquire(context.Background(), 0))
+}
+
+func TestAdmissionErrorRetryHint(t *testing.T) {
+	err := &admissionError{class: "low", retryAfter: 1500 * time.Millisecond}
+	assert.Equal(t, int64(2), err.retryAfterSecs())
+
+	jsonErr := err.jsonError()
+	assert.Equal(t, errCodeLimitExceeded, jsonErr.Code)
+	assert.Equal(t, map[string]int64{"retryAfter": 2}, jsonErr.Data)
+}
@@ -20,6 +20,7 @@ func Allowlists(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 		aclCtx := acl.Context{
 			Context:   ctx,
 			RpcMethod: msg.Method,
+			RpcParams: msg.Params,
 		}
 
 		if connH, ok := ctx.Value("handler").(*rpc.ConnHandler); ok {
@@ -29,6 +30,11 @@ func Allowlists(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 		}
 
 		if err := registry.Allow(aclCtx); err != nil {
+			// policy error with specific error code
+			if _, ok := err.(*acl.ContractBlockedError); ok {
+				return msg.ErrorResponse(err)
+			}
+
 			return msg.ErrorResponse(errAllowlistsForbidden(err))
 		}
 
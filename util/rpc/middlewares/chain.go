@@ -0,0 +1,235 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+const (
+	ctxKeyAdmissions = handlers.CtxKey("Infura-RPC-Admissions")
+)
+
+// Stage of RPC request pipeline, in which middlewares are executed in the order of stage, and
+// then the order of registration within the same stage.
+type Stage int
+
+const (
+	// StageIngress guards the pipeline, eg., panic recovery and anti-injection.
+	StageIngress Stage = iota
+	// StageAuth authenticates callers, eg., API key and signed requests.
+	StageAuth
+	// StageACL applies allowlists of callers.
+	StageACL
+	// StageRateLimit limits request size, rate and usage quotas.
+	StageRateLimit
+	// StageObserve observes requests, eg., metrics and logs.
+	StageObserve
+	// StageCache answers requests from caches before routed to full nodes, which is reserved for
+	// extensions since built-in caches are applied by RPC APIs per method.
+	StageCache
+	// StageRoute resolves the full node to serve requests, along with the call options, eg.,
+	// timeouts and block tags.
+	StageRoute
+	// StageProxy delegates requests to the routed full node, eg., coalescing and hedging.
+	StageProxy
+)
+
+var stageNames = []string{"ingress", "auth", "acl", "rateLimit", "observe", "cache", "route", "proxy"}
+
+func (s Stage) String() string {
+	if s >= 0 && int(s) < len(stageNames) {
+		return stageNames[s]
+	}
+
+	return fmt.Sprintf("stage(%d)", int(s))
+}
+
+// Middleware of RPC request pipeline, which handles single call and/or batch requests.
+type Middleware struct {
+	// name for diagnostics
+	Name string
+	// stage to execute middleware
+	Stage Stage
+	// middleware of single call, which is optional
+	Call rpc.HandleCallMsgMiddleware
+	// middleware of batch requests, which is optional
+	Batch rpc.HandleBatchMiddleware
+}
+
+// Chain is an ordered chain of RPC middlewares, which could be extended at any time, eg., by
+// plugins or registration hooks, since the chain is composed per request.
+type Chain struct {
+	mu          sync.RWMutex
+	middlewares []Middleware // ordered by stage
+}
+
+// NewChain creates an empty middleware chain.
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// DefaultChain is the middleware chain of RPC servers.
+var DefaultChain = NewChain()
+
+// Use registers middlewares to the default chain of RPC servers, eg., in the `init` function of
+// package that implements company-specific logic.
+func Use(middlewares ...Middleware) {
+	DefaultChain.Use(middlewares...)
+}
+
+// Use appends middlewares to the end of their stages.
+func (c *Chain) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// copy on write, so that the snapshot in use is never changed
+	ordered := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	ordered = append(ordered, c.middlewares...)
+	ordered = append(ordered, middlewares...)
+
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Stage < ordered[j].Stage })
+
+	c.middlewares = ordered
+}
+
+// Middlewares returns the ordered middlewares of chain.
+func (c *Chain) Middlewares() []Middleware {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.middlewares
+}
+
+// Names returns the ordered names of middlewares, prefixed with stage, eg., `auth/signature`.
+func (c *Chain) Names() []string {
+	var names []string
+	for _, m := range c.Middlewares() {
+		names = append(names, fmt.Sprintf("%v/%v", m.Stage, m.Name))
+	}
+
+	return names
+}
+
+// HandleCallMsg is the single call middleware to hook into RPC server, which executes the
+// middlewares of chain in order. Batch items admitted ahead by `Admit` bypass the admission
+// stages, so that they are neither authorized nor rate limited twice.
+func (c *Chain) HandleCallMsg(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	middlewares := c.Middlewares()
+
+	next = composeCalls(middlewares, next, func(s Stage) bool { return s > StageRateLimit })
+	next = bypassAdmitted(composeCalls(middlewares, next, isAdmissionStage), next)
+
+	return composeCalls(middlewares, next, func(s Stage) bool { return s < StageAuth })
+}
+
+// composeCalls composes the single call middlewares of the filtered stages in order.
+func composeCalls(
+	middlewares []Middleware, next rpc.HandleCallMsgFunc, filter func(s Stage) bool,
+) rpc.HandleCallMsgFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].Call != nil && filter(middlewares[i].Stage) {
+			next = middlewares[i].Call(next)
+		}
+	}
+
+	return next
+}
+
+// isAdmissionStage checks whether middlewares of the stage decide to admit requests or not.
+func isAdmissionStage(s Stage) bool {
+	return s >= StageAuth && s <= StageRateLimit
+}
+
+// admission of batch item evaluated ahead of handling.
+type admission struct {
+	ctx  context.Context     // derived by admission middlewares if admitted
+	resp *rpc.JsonRpcMessage // rejection response if not admitted
+}
+
+// Admit evaluates admission of batch items ahead of handling, by the middlewares of auth, ACL and
+// rate limit stages, eg., to prefetch the admitted items only. It returns the context with
+// admissions, which should be passed down to handle the batch, along with the admitted items.
+//
+// Note, admitted items are then handled with the context derived by admission middlewares, while
+// rejected items are responded with the rejection responses. Besides, resources held by admission
+// middlewares (eg., concurrency slots) are released once items admitted.
+func (c *Chain) Admit(ctx context.Context, msgs []*rpc.JsonRpcMessage) (context.Context, []*rpc.JsonRpcMessage) {
+	var admittedCtx context.Context
+
+	admit := composeCalls(c.Middlewares(), func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		admittedCtx = ctx
+		return &rpc.JsonRpcMessage{Version: msg.Version, ID: msg.ID, Result: json.RawMessage("null")}
+	}, isAdmissionStage)
+
+	admissions := make(map[*rpc.JsonRpcMessage]*admission, len(msgs))
+
+	var admitted []*rpc.JsonRpcMessage
+
+	for _, msg := range msgs {
+		admittedCtx = nil
+
+		resp := admit(ctx, msg)
+		if admittedCtx == nil {
+			admissions[msg] = &admission{resp: resp}
+			continue
+		}
+
+		admissions[msg] = &admission{ctx: admittedCtx}
+		admitted = append(admitted, msg)
+	}
+
+	return context.WithValue(ctx, ctxKeyAdmissions, admissions), admitted
+}
+
+// bypassAdmitted bypasses the admission middlewares for batch items admitted ahead.
+func bypassAdmitted(admit, next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		admissions, _ := ctx.Value(ctxKeyAdmissions).(map[*rpc.JsonRpcMessage]*admission)
+
+		adm, ok := admissions[msg]
+		if !ok {
+			return admit(ctx, msg)
+		}
+
+		if adm.ctx == nil {
+			return adm.resp
+		}
+
+		return next(&admittedContext{Context: ctx, values: adm.ctx}, msg)
+	}
+}
+
+// admittedContext is the context of batch item admitted ahead, whose values derived by admission
+// middlewares take precedence, while cancellation follows the context to handle the item.
+type admittedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (ctx *admittedContext) Value(key interface{}) interface{} {
+	if v := ctx.values.Value(key); v != nil {
+		return v
+	}
+
+	return ctx.Context.Value(key)
+}
+
+// HandleBatch is the batch middleware to hook into RPC server, which executes the middlewares of
+// chain in order.
+func (c *Chain) HandleBatch(next rpc.HandleBatchFunc) rpc.HandleBatchFunc {
+	middlewares := c.Middlewares()
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].Batch != nil {
+			next = middlewares[i].Batch(next)
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTraceMiddleware(name string, stage Stage, trace *[]string) Middleware {
+	return Middleware{
+		Name:  name,
+		Stage: stage,
+		Call: func(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+			return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+				*trace = append(*trace, name)
+				return next(ctx, msg)
+			}
+		},
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+
+	chain := NewChain()
+	chain.Use(
+		newTraceMiddleware("client", StageRoute, &trace),
+		newTraceMiddleware("auth", StageAuth, &trace),
+		newTraceMiddleware("qps", StageRateLimit, &trace),
+	)
+
+	// registered later but executed in the order of stage
+	chain.Use(newTraceMiddleware("cache", StageCache, &trace))
+	chain.Use(newTraceMiddleware("daily", StageRateLimit, &trace))
+	chain.Use(Middleware{Name: "batchOnly", Stage: StageIngress})
+
+	assert.Equal(t, []string{
+		"ingress/batchOnly", "auth/auth", "rateLimit/qps", "rateLimit/daily", "cache/cache", "route/client",
+	}, chain.Names())
+
+	handler := chain.HandleCallMsg(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		trace = append(trace, "handler")
+		return msg
+	})
+
+	msg := &rpc.JsonRpcMessage{Method: "eth_blockNumber"}
+	assert.Equal(t, msg, handler(context.Background(), msg))
+	assert.Equal(t, []string{"auth", "qps", "daily", "cache", "client", "handler"}, trace)
+}
+
+const ctxKeyTestAcl = handlers.CtxKey("Test-Acl")
+
+func TestChainAdmit(t *testing.T) {
+	var trace []string
+
+	chain := NewChain()
+	chain.Use(
+		newTraceMiddleware("recover", StageIngress, &trace),
+		newTraceMiddleware("auth", StageAuth, &trace),
+		Middleware{
+			Name: "acl", Stage: StageACL,
+			Call: func(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+				return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+					trace = append(trace, "acl")
+					if msg.Method == "eth_sendTransaction" {
+						return msg.ErrorResponse(errors.New("forbidden"))
+					}
+
+					return next(context.WithValue(ctx, ctxKeyTestAcl, "admitted by acl"), msg)
+				}
+			},
+		},
+		newTraceMiddleware("client", StageRoute, &trace),
+	)
+
+	admitted := &rpc.JsonRpcMessage{Method: "eth_getBalance"}
+	rejected := &rpc.JsonRpcMessage{Method: "eth_sendTransaction"}
+
+	ctx, msgs := chain.Admit(context.Background(), []*rpc.JsonRpcMessage{admitted, rejected})
+	assert.Equal(t, []*rpc.JsonRpcMessage{admitted}, msgs)
+	assert.Equal(t, []string{"auth", "acl", "auth", "acl"}, trace)
+
+	var values []interface{}
+	handler := chain.HandleCallMsg(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		values = append(values, ctx.Value(ctxKeyTestAcl))
+		return msg
+	})
+
+	// admission stages bypassed for items admitted ahead
+	trace = nil
+	assert.Equal(t, admitted, handler(ctx, admitted))
+	assert.Equal(t, []string{"recover", "client"}, trace)
+	assert.Equal(t, []interface{}{"admitted by acl"}, values)
+
+	trace = nil
+	resp := handler(ctx, rejected)
+	assert.NotNil(t, resp.Error)
+	assert.Equal(t, []string{"recover"}, trace)
+
+	// not admitted ahead
+	trace = nil
+	other := &rpc.JsonRpcMessage{Method: "eth_blockNumber"}
+	assert.Equal(t, other, handler(ctx, other))
+	assert.Equal(t, []string{"recover", "auth", "acl", "client"}, trace)
+}
+
+func TestLoadPlugin(t *testing.T) {
+	assert.Error(t, LoadPlugin("/path/not/exists.so", NewChain()))
+}
@@ -0,0 +1,317 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/andybalholm/brotli"
+	"github.com/sirupsen/logrus"
+)
+
+// Supported content encodings in order of preference.
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+var supportedEncodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+
+// CompressionConfig configurations to compress HTTP responses.
+type CompressionConfig struct {
+	// switch to turn on/off response compression
+	Enabled bool
+	// responses smaller than the threshold in bytes are not compressed
+	MinSize int `default:"1024"`
+	// compression level, which is encoding specific, or the default level if 0
+	Level int
+	// enabled encodings, or all supported encodings if empty
+	Encodings []string
+}
+
+// MustNewCompressionFromViper creates HTTP middleware to compress responses, which passes
+// through all requests if compression is disabled.
+func MustNewCompressionFromViper() handlers.Middleware {
+	var conf CompressionConfig
+	viper.MustUnmarshalKey("rpc.compression", &conf)
+
+	if !conf.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	for _, encoding := range conf.Encodings {
+		if !isSupportedEncoding(encoding) {
+			logrus.WithField("encoding", encoding).Fatal("Unsupported response compression encoding")
+		}
+	}
+
+	logrus.WithField("config", conf).Info("Response compression HTTP middleware enabled")
+
+	return Compression(conf)
+}
+
+// Compression compresses responses with the encoding negotiated by `Accept-Encoding` request
+// header. Response is buffered until the minimum size reached, after which it is compressed in
+// a streaming way. Websocket upgrade requests are passed through.
+func Compression(conf CompressionConfig) handlers.Middleware {
+	encodings := conf.Encodings
+	if len(encodings) == 0 {
+		encodings = supportedEncodings
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get("Upgrade")) > 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if len(encoding) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				level:          conf.Level,
+				minSize:        conf.MinSize,
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding selects the preferred encoding accepted by client, or empty if none.
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if len(acceptEncoding) == 0 {
+		return ""
+	}
+
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		accepted[name] = q > 0
+	}
+
+	for _, encoding := range encodings {
+		if ok, found := accepted[encoding]; found {
+			if ok {
+				return encoding
+			}
+
+			continue
+		}
+
+		if accepted["*"] {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+func isSupportedEncoding(encoding string) bool {
+	for _, v := range supportedEncodings {
+		if v == encoding {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressWriter buffers response until the minimum size reached, and then compresses the
+// buffered and subsequent data.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	level    int
+	minSize  int
+
+	statusCode  int
+	buf         bytes.Buffer
+	encoder     io.WriteCloser
+	counter     *countWriter
+	written     int // uncompressed bytes written
+	passThrough bool
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+
+	if w.passThrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.written += len(data)
+
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+
+	// already encoded by handler
+	if len(w.Header().Get("Content-Encoding")) > 0 {
+		w.flushRaw()
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() < w.minSize {
+		return len(data), nil
+	}
+
+	if err := w.startEncoding(); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// startEncoding writes the response headers and buffered data to encoder.
+func (w *compressWriter) startEncoding() error {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	w.counter = &countWriter{Writer: w.ResponseWriter}
+	w.encoder = newEncoder(w.encoding, w.level, w.counter)
+
+	_, err := w.encoder.Write(w.buf.Bytes())
+	w.buf.Reset()
+
+	return err
+}
+
+// Flush writes the buffered data to client, which is compressed if the response is compressible,
+// eg., for streaming responses.
+func (w *compressWriter) Flush() {
+	if w.encoder == nil && !w.passThrough && w.statusCode != 0 && w.buf.Len() > 0 {
+		if err := w.startEncoding(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// flushRaw writes the response headers and buffered data without compression.
+func (w *compressWriter) flushRaw() {
+	w.passThrough = true
+
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// Close flushes the compressed data or buffered data if not compressed.
+func (w *compressWriter) Close() error {
+	if w.encoder == nil {
+		if !w.passThrough && w.statusCode != 0 {
+			w.flushRaw()
+		}
+
+		return nil
+	}
+
+	err := w.encoder.Close()
+
+	metrics.Registry.RPC.CompressionBytes(w.encoding).Mark(int64(w.written))
+	metrics.Registry.RPC.CompressionBytesSaved(w.encoding).Mark(int64(w.written - w.counter.n))
+
+	return err
+}
+
+func newEncoder(encoding string, level int, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case EncodingBrotli:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+
+		return brotli.NewWriterLevel(w, level)
+	case EncodingGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+
+		if gw, err := gzip.NewWriterLevel(w, level); err == nil {
+			return gw
+		}
+
+		return gzip.NewWriter(w)
+	default: // `deflate` content encoding is zlib format (RFC 7230)
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+
+		if zw, err := zlib.NewWriterLevel(w, level); err == nil {
+			return zw
+		}
+
+		return zlib.NewWriter(w)
+	}
+}
+
+// countWriter counts the bytes written to the underlying writer.
+type countWriter struct {
+	io.Writer
+	n int
+}
+
+func (w *countWriter) Write(data []byte) (int, error) {
+	n, err := w.Writer.Write(data)
+	w.n += n
+	return n, err
+}
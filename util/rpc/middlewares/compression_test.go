@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"gzip, br;q=0", EncodingGzip},
+		{"deflate;q=0.5, GZIP", EncodingGzip},
+		{"*", EncodingBrotli},
+		{"br;q=0, *", EncodingGzip},
+	}
+
+	for _, tc := range testCases {
+		actual := negotiateEncoding(tc.acceptEncoding, supportedEncodings)
+		assert.Equal(t, tc.expected, actual, tc.acceptEncoding)
+	}
+
+	assert.Equal(t, EncodingDeflate, negotiateEncoding("br, deflate", []string{EncodingDeflate}))
+}
+
+func TestCompression(t *testing.T) {
+	payload := strings.Repeat(`{"address":"0x0000000000000000000000000000000000000000"}`, 100)
+
+	handler := Compression(CompressionConfig{MinSize: 1024})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			// write in chunks to compress in streaming way
+			data := payload
+			if r.URL.Path == "/small" {
+				data = payload[:100]
+			}
+
+			for i := 0; i < len(data); i += 300 {
+				end := i + 300
+				if end > len(data) {
+					end = len(data)
+				}
+
+				w.Write([]byte(data[i:end]))
+			}
+		}),
+	)
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+
+		return recorder
+	}
+
+	// gzip
+	resp := serve("/", "gzip")
+	assert.Equal(t, EncodingGzip, resp.Header().Get("Content-Encoding"))
+	assert.Less(t, resp.Body.Len(), len(payload))
+
+	gr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(data))
+
+	// brotli
+	resp = serve("/", "br")
+	assert.Equal(t, EncodingBrotli, resp.Header().Get("Content-Encoding"))
+
+	data, err = ioutil.ReadAll(brotli.NewReader(resp.Body))
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(data))
+
+	// below min size
+	resp = serve("/small", "gzip")
+	assert.Empty(t, resp.Header().Get("Content-Encoding"))
+	assert.Equal(t, payload[:100], resp.Body.String())
+
+	// not accepted
+	resp = serve("/", "")
+	assert.Empty(t, resp.Header().Get("Content-Encoding"))
+	assert.Equal(t, payload, resp.Body.String())
+}
+
+func TestCompressionFlush(t *testing.T) {
+	chunk := `{"address":"0x0000000000000000000000000000000000000000"}`
+
+	recorder := httptest.NewRecorder()
+
+	var flushed int
+	handler := Compression(CompressionConfig{MinSize: 1024})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+
+			// compressed data written to client once flushed
+			flushed = recorder.Body.Len()
+
+			w.Write([]byte(chunk))
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	handler.ServeHTTP(recorder, r)
+
+	assert.Equal(t, EncodingGzip, recorder.Header().Get("Content-Encoding"))
+	assert.True(t, recorder.Flushed)
+	assert.Greater(t, flushed, 0)
+
+	gr, err := gzip.NewReader(recorder.Body)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, chunk+chunk, string(data))
+}
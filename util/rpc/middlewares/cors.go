@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
+)
+
+// CorsVirtualHostConfig CORS policy for requests to the specified hostnames.
+type CorsVirtualHostConfig struct {
+	// hostnames, eg., `app.example.com` or `*.example.com`
+	Hosts []string
+	// policy overriding the default one
+	Policy acl.CorsPolicy
+}
+
+// CorsConfig configurations of CORS policies, which could be overridden per serving hostname,
+// and then per allowlist.
+type CorsConfig struct {
+	// switch to turn on/off configurable CORS, otherwise any origin is allowed
+	Enabled bool
+	// default policy
+	Default acl.CorsPolicy
+	// policies per serving hostname, of which the first matched one applies
+	VirtualHosts []CorsVirtualHostConfig
+}
+
+type corsVirtualHost struct {
+	patterns []*regexp.Regexp
+	policy   *acl.CorsPolicy // merged with the default policy
+}
+
+// Cors handles CORS preflight and actual requests with the policy resolved by the serving
+// hostname and the allowlist of access token.
+type Cors struct {
+	registry *rate.Registry // nil if allowlist not available
+	global   acl.CorsPolicy
+	vhosts   []corsVirtualHost
+
+	handlers sync.Map // JSON of policy => *cors.Cors
+}
+
+// MustNewCorsFromViper creates HTTP middleware to handle CORS, which passes through all requests
+// if disabled.
+func MustNewCorsFromViper(registry *rate.Registry) handlers.Middleware {
+	var conf CorsConfig
+	viper.MustUnmarshalKey("rpc.cors", &conf)
+
+	if !conf.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	c, err := NewCors(conf, registry)
+	if err != nil {
+		logrus.WithField("config", conf).WithError(err).Fatal("Invalid CORS config")
+	}
+
+	logrus.WithField("config", conf).Info("CORS HTTP middleware enabled")
+
+	return c.Http
+}
+
+func NewCors(conf CorsConfig, registry *rate.Registry) (*Cors, error) {
+	if err := conf.Default.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Cors{registry: registry, global: conf.Default}
+
+	for _, vhc := range conf.VirtualHosts {
+		if err := vhc.Policy.Validate(); err != nil {
+			return nil, errors.WithMessagef(err, "invalid policy of hosts %v", vhc.Hosts)
+		}
+
+		vhost := corsVirtualHost{policy: conf.Default.Override(&vhc.Policy)}
+		for _, host := range vhc.Hosts {
+			pattern, err := regexp.Compile(util.WildCardToRegexp(strings.ToLower(host)))
+			if err != nil {
+				return nil, errors.WithMessagef(err, "invalid host %v", host)
+			}
+
+			vhost.patterns = append(vhost.patterns, pattern)
+		}
+
+		c.vhosts = append(c.vhosts, vhost)
+	}
+
+	return c, nil
+}
+
+// policy resolves the effective policy for request, which is overridden by the allowlist of
+// access token if any.
+func (c *Cors) policy(r *http.Request) *acl.CorsPolicy {
+	policy := c.vhostPolicy(r.Host)
+
+	if c.registry == nil {
+		return policy
+	}
+
+	// it's an outer middleware, where the RPC context values are not injected yet
+	ctx := context.WithValue(r.Context(), handlers.CtxKeyRateRegistry, c.registry)
+	if token := handlers.GetAccessToken(r); len(token) > 0 {
+		ctx = context.WithValue(ctx, handlers.CtxKeyAccessToken, token)
+	}
+
+	if al, ok := allowListFromContext(ctx); ok {
+		return policy.Override(al.Cors)
+	}
+
+	return policy
+}
+
+func (c *Cors) vhostPolicy(hostport string) *acl.CorsPolicy {
+	host := strings.ToLower(hostport)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, vhost := range c.vhosts {
+		for _, pattern := range vhost.patterns {
+			if pattern.MatchString(host) {
+				return vhost.policy
+			}
+		}
+	}
+
+	return &c.global
+}
+
+// handler returns the CORS handler of policy, which is cached since only a few policies are
+// configured in practice.
+func (c *Cors) handler(policy *acl.CorsPolicy) *cors.Cors {
+	key, _ := json.Marshal(policy)
+	if h, ok := c.handlers.Load(string(key)); ok {
+		return h.(*cors.Cors)
+	}
+
+	h, _ := c.handlers.LoadOrStore(string(key), cors.New(cors.Options{
+		AllowedOrigins:   policy.AllowedOrigins,
+		AllowedMethods:   policy.AllowedMethods,
+		AllowedHeaders:   policy.AllowedHeaders,
+		ExposedHeaders:   policy.ExposedHeaders,
+		MaxAge:           policy.MaxAge,
+		AllowCredentials: policy.AllowCredentials,
+	}))
+
+	return h.(*cors.Cors)
+}
+
+// Http responds to preflight requests without passing through, and adds CORS headers to the
+// responses of actual requests. Websocket upgrade requests are not subject to CORS.
+func (c *Cors) Http(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocketUpgrade(r) || len(r.Header.Get("Origin")) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c.handler(c.policy(r)).ServeHTTP(w, r, next.ServeHTTP)
+	})
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCors(t *testing.T) {
+	c, err := NewCors(CorsConfig{
+		Default: acl.CorsPolicy{AllowedOrigins: []string{"*"}, MaxAge: 600},
+		VirtualHosts: []CorsVirtualHostConfig{{
+			Hosts:  []string{"*.dapp.example.com"},
+			Policy: acl.CorsPolicy{AllowedOrigins: []string{"https://dapp.example.com"}, AllowCredentials: true},
+		}},
+	}, nil)
+	require.NoError(t, err)
+
+	var served int
+	handler := c.Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	serve := func(method, host, origin string) http.Header {
+		req := httptest.NewRequest(method, "http://"+host+"/", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder.Header()
+	}
+
+	// default policy
+	header := serve(http.MethodOptions, "rpc.example.com", "https://any.com")
+	assert.Equal(t, "*", header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "600", header.Get("Access-Control-Max-Age"))
+	assert.Equal(t, 0, served)
+
+	// virtual host policy inherits max age from the default policy
+	header = serve(http.MethodOptions, "eth.dapp.example.com:443", "https://dapp.example.com")
+	assert.Equal(t, "https://dapp.example.com", header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", header.Get("Access-Control-Max-Age"))
+
+	header = serve(http.MethodPost, "eth.dapp.example.com", "https://dapp.example.com")
+	assert.Equal(t, "https://dapp.example.com", header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, 1, served)
+
+	// origin not allowed
+	header = serve(http.MethodPost, "eth.dapp.example.com", "https://evil.com")
+	assert.Empty(t, header.Get("Access-Control-Allow-Origin"))
+
+	_, err = NewCors(CorsConfig{Default: acl.CorsPolicy{MaxAge: -1}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/jsonscan"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// CostConfig configurations to estimate the cost of requests before forwarded to full nodes.
+//
+// The cost of a call is `weight * blocks * depth`, where `blocks` is the block (or epoch) range
+// of `getLogs` or `trace_filter` filter, and `depth` is the trace depth of trace methods, eg.,
+// the number of trace types of `trace_replayTransaction` or the opcode level trace of
+// `debug_traceTransaction` without any tracer specified.
+type CostConfig struct {
+	// switch to turn on/off request cost estimation
+	Enabled bool
+	// max cost of a call, beyond which the call is rejected, 0 means unlimited
+	MaxCost uint64
+	// max total cost of all calls in a batch, 0 means unlimited
+	MaxBatchCost uint64
+	// weight of RPC method, eg., `trace_block: 50`, and method not configured uses the default
+	Weights       map[string]uint64
+	DefaultWeight uint64 `default:"1"`
+	// trace depth of opcode level trace, ie., `debug_trace*` without any tracer specified
+	OpcodeTraceDepth uint64 `default:"10"`
+}
+
+// requestCostError JSON-RPC error when request cost exceeds the ceiling.
+type requestCostError struct {
+	cost, maxCost uint64
+	reason        string
+}
+
+func (e *requestCostError) Error() string {
+	return fmt.Sprintf(
+		"request too expensive, estimated cost %v exceeds the max %v (%v), please narrow down the request",
+		e.cost, e.maxCost, e.reason,
+	)
+}
+
+func (e *requestCostError) ErrorCode() int { return errCodeLimitExceeded }
+
+// jsonError returns JSON-RPC error with the estimated cost attached as data.
+func (e *requestCostError) jsonError() *rpc.JsonError {
+	return &rpc.JsonError{
+		Code:    e.ErrorCode(),
+		Message: e.Error(),
+		Data:    map[string]uint64{"cost": e.cost, "maxCost": e.maxCost},
+	}
+}
+
+// callCost estimated cost of a call along with the factors.
+type callCost struct {
+	weight, blocks, depth uint64
+}
+
+func (c *callCost) total() uint64 {
+	return saturatingMul(saturatingMul(c.weight, c.blocks), c.depth)
+}
+
+func (c *callCost) String() string {
+	factors := []string{fmt.Sprintf("weight %v", c.weight)}
+
+	if c.blocks > 1 {
+		factors = append(factors, fmt.Sprintf("%v blocks", c.blocks))
+	}
+
+	if c.depth > 1 {
+		factors = append(factors, fmt.Sprintf("trace depth %v", c.depth))
+	}
+
+	return strings.Join(factors, " * ")
+}
+
+// saturatingMul multiplies without overflow, eg., for block range from 0 to max uint64.
+func saturatingMul(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+
+	return a * b
+}
+
+// CostEstimator estimates the cost of requests by block range, trace depth and batch length,
+// and rejects the pathological ones rather than wasting full node resources until timeout.
+type CostEstimator struct {
+	conf CostConfig
+}
+
+// MustNewCostEstimatorFromViper creates an instance of CostEstimator from viper, or nil if
+// disabled.
+func MustNewCostEstimatorFromViper() *CostEstimator {
+	var conf CostConfig
+	viper.MustUnmarshalKey("rpc.cost", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.DefaultWeight == 0 || conf.OpcodeTraceDepth == 0 {
+		logrus.WithField("config", conf).Fatal("Invalid RPC cost estimation config")
+	}
+
+	logrus.WithField("config", conf).Info("Cost estimation RPC middleware enabled")
+
+	return NewCostEstimator(conf)
+}
+
+func NewCostEstimator(conf CostConfig) *CostEstimator {
+	return &CostEstimator{conf: conf}
+}
+
+// estimate estimates the cost of the call.
+func (ce *CostEstimator) estimate(ctx context.Context, msg *rpc.JsonRpcMessage) *callCost {
+	cost := callCost{weight: ce.conf.DefaultWeight, blocks: 1, depth: 1}
+
+	// method names are lowercased by viper
+	if weight, ok := ce.conf.Weights[strings.ToLower(msg.Method)]; ok {
+		cost.weight = weight
+	}
+
+	switch msg.Method {
+	case "eth_getLogs", "cfx_getLogs", "trace_filter":
+		if span, ok, _ := parseFilterRange(ctx, msg); ok && span > 1 {
+			cost.blocks = span
+		}
+	case "trace_replayTransaction", "trace_replayBlockTransactions", "trace_callMany":
+		if n := parseTraceTypes(msg); n > 1 {
+			cost.depth = uint64(n)
+		}
+	case "debug_traceTransaction", "debug_traceCall",
+		"debug_traceBlockByNumber", "debug_traceBlockByHash":
+		if isOpcodeTrace(msg) {
+			cost.depth = ce.conf.OpcodeTraceDepth
+		}
+	}
+
+	return &cost
+}
+
+// parseTraceTypes returns the number of trace types, eg., `["trace", "vmTrace"]`, which is the
+// last param of `trace_replay*` methods.
+func parseTraceTypes(msg *rpc.JsonRpcMessage) int {
+	var last []byte
+	if !jsonscan.ForEachElement(msg.Params, func(_ int, param []byte) bool {
+		last = param
+		return true
+	}) || last == nil {
+		return 0
+	}
+
+	var n int
+	valid := jsonscan.ForEachElement(last, func(_ int, traceType []byte) bool {
+		if _, ok := jsonscan.String(traceType); !ok {
+			n = 0
+			return false
+		}
+
+		n++
+		return true
+	})
+
+	if !valid {
+		return 0
+	}
+
+	return n
+}
+
+// isOpcodeTrace checks whether the `debug_trace*` call traces at opcode level with the default
+// struct logger, which is the case if no tracer specified in the trace config.
+func isOpcodeTrace(msg *rpc.JsonRpcMessage) bool {
+	opcode := true
+
+	if !jsonscan.ForEachElement(msg.Params, func(_ int, param []byte) bool {
+		// trace config is the only object param, while `debug_traceCall` has the call object
+		// ahead, which has no tracer field either.
+		value, ok := jsonscan.Field(param, "tracer")
+		if !ok || jsonscan.IsNull(value) {
+			return true
+		}
+
+		if tracer, ok := jsonscan.String(value); ok {
+			opcode = len(tracer) == 0
+			return false
+		}
+
+		return true
+	}) {
+		return false
+	}
+
+	return opcode
+}
+
+// reject returns the error if cost exceeds the max, and nil otherwise.
+func reject(method string, cost, maxCost uint64, reason string) *requestCostError {
+	if maxCost == 0 || cost <= maxCost {
+		return nil
+	}
+
+	metrics.Registry.RPC.CostRejected(method).Mark(1)
+
+	return &requestCostError{cost: cost, maxCost: maxCost, reason: reason}
+}
+
+// Batch rejects the batch request if the total cost of all calls exceeds the max batch cost,
+// which passes through if CostEstimator is nil.
+func (ce *CostEstimator) Batch(next rpc.HandleBatchFunc) rpc.HandleBatchFunc {
+	if ce == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msgs []*rpc.JsonRpcMessage) []*rpc.JsonRpcMessage {
+		var total uint64
+		for _, msg := range msgs {
+			cost := ce.estimate(ctx, msg).total()
+			if total += cost; total < cost { // overflow
+				total = math.MaxUint64
+			}
+		}
+
+		reason := fmt.Sprintf("batch of %v calls", len(msgs))
+		err := reject("batch", total, ce.conf.MaxBatchCost, reason)
+		if err == nil {
+			return next(ctx, msgs)
+		}
+
+		resps := make([]*rpc.JsonRpcMessage, 0, len(msgs))
+		for _, msg := range msgs {
+			resps = append(resps, msg.ErrorResponse(err.jsonError()))
+		}
+
+		return resps
+	}
+}
+
+// Call rejects the call if the estimated cost exceeds the max cost, which passes through if
+// CostEstimator is nil.
+func (ce *CostEstimator) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if ce == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		cost := ce.estimate(ctx, msg)
+
+		if err := reject(msg.Method, cost.total(), ce.conf.MaxCost, cost.String()); err != nil {
+			return msg.ErrorResponse(err.jsonError())
+		}
+
+		return next(ctx, msg)
+	}
+}
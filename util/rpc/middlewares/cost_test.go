@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCostTestMsg(method, params string) *rpc.JsonRpcMessage {
+	return &rpc.JsonRpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage("1"),
+		Method:  method,
+		Params:  json.RawMessage(params),
+	}
+}
+
+func TestCostEstimatorEstimate(t *testing.T) {
+	ce := NewCostEstimator(CostConfig{
+		DefaultWeight:    1,
+		Weights:          map[string]uint64{"trace_filter": 20, "debug_tracetransaction": 100},
+		OpcodeTraceDepth: 10,
+	})
+
+	testCases := []struct {
+		method, params string
+		expected       uint64
+	}{
+		{"eth_blockNumber", `[]`, 1},
+		{"eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x64"}]`, 100},
+		{"eth_getLogs", `[{"fromBlock":"0x1","toBlock":"latest"}]`, 1},
+		{"cfx_getLogs", `[{"fromEpoch":"earliest","toEpoch":"0x9"}]`, 10},
+		{"trace_filter", `[{"fromBlock":"0x1","toBlock":"0xa"}]`, 200},
+		{"trace_replayTransaction", `["0xabc",["trace","vmTrace","stateDiff"]]`, 3},
+		{"debug_traceTransaction", `["0xabc"]`, 1000},
+		{"debug_traceTransaction", `["0xabc",{"tracer":"callTracer"}]`, 100},
+		{"debug_traceCall", `[{"to":"0x01"},"latest",{"tracer":"callTracer"}]`, 1},
+		{"debug_traceCall", `[{"to":"0x01"},"latest"]`, 10},
+	}
+
+	for _, tc := range testCases {
+		cost := ce.estimate(context.Background(), newCostTestMsg(tc.method, tc.params))
+		assert.Equal(t, tc.expected, cost.total(), "%v %v", tc.method, tc.params)
+	}
+
+	// tagged block range resolved against chain head if available
+	ctx := withTestChainHead(100)
+	cost := ce.estimate(ctx, newCostTestMsg("eth_getLogs", `[{"fromBlock":"0x1","toBlock":"latest"}]`))
+	assert.Equal(t, uint64(100), cost.total())
+
+	// no overflow for pathological block range
+	cost = ce.estimate(context.Background(), newCostTestMsg("trace_filter", `[{"fromBlock":"earliest","toBlock":"0xfffffffffffffffe"}]`))
+	assert.Equal(t, uint64(1<<64-1), cost.total())
+}
+
+func TestCostEstimatorReject(t *testing.T) {
+	ce := NewCostEstimator(CostConfig{DefaultWeight: 1, OpcodeTraceDepth: 10, MaxCost: 100, MaxBatchCost: 150})
+
+	var forwarded int
+	call := ce.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		forwarded++
+		return &rpc.JsonRpcMessage{}
+	})
+
+	resp := call(context.Background(), newCostTestMsg("eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x64"}]`))
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, 1, forwarded)
+
+	resp = call(context.Background(), newCostTestMsg("eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x65"}]`))
+	assert.NotNil(t, resp.Error)
+	assert.Contains(t, resp.Error.Message, "estimated cost 101 exceeds the max 100 (weight 1 * 101 blocks)")
+	assert.Equal(t, 1, forwarded)
+
+	batch := ce.Batch(func(ctx context.Context, msgs []*rpc.JsonRpcMessage) []*rpc.JsonRpcMessage {
+		return make([]*rpc.JsonRpcMessage, len(msgs))
+	})
+
+	msg := newCostTestMsg("eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x32"}]`)
+
+	resps := batch(context.Background(), []*rpc.JsonRpcMessage{msg, msg, msg})
+	assert.Len(t, resps, 3)
+	assert.Nil(t, resps[0])
+
+	resps = batch(context.Background(), []*rpc.JsonRpcMessage{msg, msg, msg, msg})
+	assert.Len(t, resps, 4)
+	for _, resp := range resps {
+		assert.NotNil(t, resp.Error)
+		assert.Contains(t, resp.Error.Message, "batch of 4 calls")
+	}
+}
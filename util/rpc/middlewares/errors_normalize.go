@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorRule normalizes upstream errors of which message contains any of the patterns (case
+// insensitive) into the consistent code and message.
+type ErrorRule struct {
+	Code     int
+	Message  string
+	Patterns []string
+}
+
+const (
+	// JSON-RPC error code of execution reverted, with the revert reason in error data
+	errCodeExecutionReverted = 3
+)
+
+// DefaultErrorRules are the built-in rules for the common failures, which are reported by
+// different full node implementations (eg., geth, erigon and parity) in different ways.
+var DefaultErrorRules = []ErrorRule{
+	{Code: -32000, Message: "nonce too low", Patterns: []string{
+		"nonce too low", "nonce is too low", "too stale nonce",
+	}},
+	{Code: -32000, Message: "nonce too high", Patterns: []string{
+		"nonce too high", "nonce is too high", "too distant future",
+	}},
+	{Code: -32000, Message: "already known", Patterns: []string{
+		"already known", "known transaction", "transaction already imported", "tx already exist",
+	}},
+	{Code: -32000, Message: "replacement transaction underpriced", Patterns: []string{
+		"replacement transaction underpriced", "too low to replace", "replacement underpriced",
+	}},
+	{Code: -32000, Message: "insufficient funds for gas * price + value", Patterns: []string{
+		"insufficient funds", "insufficient balance",
+	}},
+	{Code: -32000, Message: "intrinsic gas too low", Patterns: []string{
+		"intrinsic gas too low", "gas limit is too low", "not enough base gas",
+	}},
+	{Code: -32000, Message: "exceeds block gas limit", Patterns: []string{
+		"exceeds block gas limit", "gas limit exceeds", "exceeds gas limit",
+	}},
+	{Code: -32001, Message: "resource not found", Patterns: []string{
+		"header not found", "unknown block", "block not found",
+	}},
+	{Code: -32005, Message: "limit exceeded", Patterns: []string{
+		"query returned more than", "block range is too large", "block range too large",
+		"query timeout exceeded",
+	}},
+}
+
+// ErrorNormalizationConfig configurations to normalize upstream errors.
+type ErrorNormalizationConfig struct {
+	// switch to turn on/off error normalization
+	Enabled bool
+	// custom rules evaluated before the default rules
+	Rules []ErrorRule
+}
+
+// rawError is the upstream error preserved in `data` of the normalized error.
+type rawError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ErrorNormalizer maps upstream errors to a consistent set of codes and messages, with the
+// raw error preserved in `data` as {"raw": {"code": ..., "message": ..., "data": ...}}.
+type ErrorNormalizer struct {
+	rules []ErrorRule // with lower-cased patterns
+}
+
+// MustNewErrorNormalizerFromViper creates an instance of ErrorNormalizer from viper, or nil
+// if disabled.
+func MustNewErrorNormalizerFromViper() *ErrorNormalizer {
+	var conf ErrorNormalizationConfig
+	viper.MustUnmarshalKey("rpc.errorNormalization", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	for _, rule := range conf.Rules {
+		if len(rule.Message) == 0 || len(rule.Patterns) == 0 {
+			logrus.WithField("rule", rule).Fatal("Invalid error normalization rule")
+		}
+	}
+
+	logrus.WithField("customRules", len(conf.Rules)).Info("Error normalization RPC middleware enabled")
+
+	return NewErrorNormalizer(append(conf.Rules, DefaultErrorRules...))
+}
+
+func NewErrorNormalizer(rules []ErrorRule) *ErrorNormalizer {
+	normalizer := &ErrorNormalizer{rules: make([]ErrorRule, 0, len(rules))}
+
+	for _, rule := range rules {
+		patterns := make([]string, 0, len(rule.Patterns))
+		for _, p := range rule.Patterns {
+			patterns = append(patterns, strings.ToLower(p))
+		}
+
+		normalizer.rules = append(normalizer.rules, ErrorRule{
+			Code: rule.Code, Message: rule.Message, Patterns: patterns,
+		})
+	}
+
+	return normalizer
+}
+
+// Normalize normalizes the JSON-RPC error in place if any rule matched.
+//
+// Note, `execution reverted` errors are never normalized since clients decode the revert reason
+// from error data. Neither are limit exceeded errors, which are mostly originated by gateway along
+// with the allowed limits in message, eg., block range limit of `getLogs`.
+func (n *ErrorNormalizer) Normalize(err *rpc.JsonError) bool {
+	if err.Code == errCodeExecutionReverted || err.Code == errCodeLimitExceeded {
+		return false
+	}
+
+	msg := strings.ToLower(err.Message)
+	if strings.Contains(msg, "execution reverted") {
+		return false
+	}
+
+	for _, rule := range n.rules {
+		if !matchAny(msg, rule.Patterns) {
+			continue
+		}
+
+		if err.Code == rule.Code && err.Message == rule.Message {
+			return false // already normalized
+		}
+
+		err.Data = map[string]interface{}{
+			"raw": rawError{Code: err.Code, Message: err.Message, Data: err.Data},
+		}
+		err.Code, err.Message = rule.Code, rule.Message
+
+		return true
+	}
+
+	return false
+}
+
+func matchAny(msg string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(msg, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Call normalizes the error of RPC response, which passes through if normalizer is nil.
+func (n *ErrorNormalizer) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if n == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		resp := next(ctx, msg)
+
+		if resp != nil && resp.Error != nil {
+			n.Normalize(resp.Error)
+		}
+
+		return resp
+	}
+}
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorNormalizer(t *testing.T) {
+	custom := ErrorRule{Code: -32000, Message: "transaction underpriced", Patterns: []string{"Gas Price Too Low"}}
+	normalizer := NewErrorNormalizer(append([]ErrorRule{custom}, DefaultErrorRules...))
+
+	// parity style
+	err := &rpc.JsonError{Code: -32010, Message: "Transaction nonce is too low. Try incrementing the nonce."}
+	assert.True(t, normalizer.Normalize(err))
+	assert.Equal(t, -32000, err.Code)
+	assert.Equal(t, "nonce too low", err.Message)
+	assert.Equal(t, map[string]interface{}{
+		"raw": rawError{Code: -32010, Message: "Transaction nonce is too low. Try incrementing the nonce."},
+	}, err.Data)
+
+	// already normalized
+	err = &rpc.JsonError{Code: -32000, Message: "nonce too low"}
+	assert.False(t, normalizer.Normalize(err))
+	assert.Nil(t, err.Data)
+
+	// custom rule
+	err = &rpc.JsonError{Code: -32000, Message: "gas price too low"}
+	assert.True(t, normalizer.Normalize(err))
+	assert.Equal(t, "transaction underpriced", err.Message)
+
+	// revert reason kept
+	err = &rpc.JsonError{Code: 3, Message: "execution reverted", Data: "0x08c379a0"}
+	assert.False(t, normalizer.Normalize(err))
+	assert.Equal(t, "0x08c379a0", err.Data)
+}
+
+func TestErrorNormalizerSkipsReverted(t *testing.T) {
+	normalizer := NewErrorNormalizer([]ErrorRule{
+		{Code: -32000, Message: "insufficient funds", Patterns: []string{"insufficient balance"}},
+	})
+
+	// revert reason matching patterns
+	err := &rpc.JsonError{Code: 3, Message: "insufficient balance", Data: "0x08c379a0"}
+	assert.False(t, normalizer.Normalize(err))
+	assert.Equal(t, 3, err.Code)
+	assert.Equal(t, "0x08c379a0", err.Data)
+
+	// reverted with other code
+	err = &rpc.JsonError{Code: -32015, Message: "VM execution error: execution reverted: insufficient balance"}
+	assert.False(t, normalizer.Normalize(err))
+	assert.Equal(t, -32015, err.Code)
+	assert.Nil(t, err.Data)
+}
+
+func TestErrorNormalizerSkipsGatewayErrors(t *testing.T) {
+	normalizer := NewErrorNormalizer(DefaultErrorRules)
+
+	// block range limit of gateway with the allowed limits
+	limitErr := newLimitExceededError("block range too large, max %v blocks", 1000)
+	resp := NewErrorNormalizer(DefaultErrorRules).Call(
+		func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+			return msg.ErrorResponse(limitErr)
+		},
+	)(context.Background(), &rpc.JsonRpcMessage{})
+
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+	assert.Equal(t, limitErr.Error(), resp.Error.Message)
+	assert.Nil(t, resp.Error.Data)
+
+	// upstream error of other code still normalized
+	err := &rpc.JsonError{Code: -32000, Message: "block range too large"}
+	assert.True(t, normalizer.Normalize(err))
+	assert.Equal(t, errCodeLimitExceeded, err.Code)
+	assert.Equal(t, "limit exceeded", err.Message)
+}
+
+func TestErrorNormalizerCall(t *testing.T) {
+	handler := func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return msg.ErrorResponse(&rpc.JsonError{Code: -32000, Message: "header not found"})
+	}
+
+	// pass through if disabled
+	var disabled *ErrorNormalizer
+	resp := disabled.Call(handler)(context.Background(), &rpc.JsonRpcMessage{})
+	assert.Equal(t, "header not found", resp.Error.Message)
+
+	resp = NewErrorNormalizer(DefaultErrorRules).Call(handler)(context.Background(), &rpc.JsonRpcMessage{})
+	assert.Equal(t, -32001, resp.Error.Code)
+	assert.Equal(t, "resource not found", resp.Error.Message)
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+const (
+	// polling interval to check whether in-flight RPC calls drained
+	drainPollInterval = 20 * time.Millisecond
+
+	ctxKeyInFlightCounter = handlers.CtxKey("Infura-InFlight-Counter")
+)
+
+// InFlightCounter counts the RPC calls being handled by an RPC server, which are waited for on
+// graceful shutdown. Note, RPC call middlewares are shared by all RPC servers in process, so the
+// counter is injected into the request context by the HTTP middleware of each server.
+type InFlightCounter struct {
+	calls int64 // accessed atomically
+}
+
+// Http injects the counter into request context, so that RPC calls of the request (including
+// websocket and IPC connection) are tracked by `InFlight` middleware.
+func (c *InFlightCounter) Http(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKeyInFlightCounter, c)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Calls returns the number of RPC calls being handled.
+func (c *InFlightCounter) Calls() int64 {
+	return atomic.LoadInt64(&c.calls)
+}
+
+// Drain blocks until all in-flight RPC calls completed, and returns false if context is done
+// before that.
+func (c *InFlightCounter) Drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for c.Calls() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	return true
+}
+
+// InFlight tracks the RPC calls being handled by the RPC server of counter in context, so that
+// they could be drained on shutdown.
+func InFlight(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		c, ok := ctx.Value(ctxKeyInFlightCounter).(*InFlightCounter)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		atomic.AddInt64(&c.calls, 1)
+		defer atomic.AddInt64(&c.calls, -1)
+
+		return next(ctx, msg)
+	}
+}
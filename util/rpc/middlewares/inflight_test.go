@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlight(t *testing.T) {
+	counter, other := &InFlightCounter{}, &InFlightCounter{}
+
+	var ctx context.Context
+	counter.Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	release := make(chan struct{})
+	handler := InFlight(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		<-release
+		return &rpc.JsonRpcMessage{}
+	})
+
+	go handler(ctx, &rpc.JsonRpcMessage{Method: "eth_call"})
+
+	assert.Eventually(t, func() bool { return counter.Calls() == 1 }, time.Second, time.Millisecond)
+	assert.Zero(t, other.Calls())
+
+	// timeout to drain
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, counter.Drain(timeoutCtx))
+
+	close(release)
+	assert.True(t, counter.Drain(context.Background()))
+	assert.True(t, other.Drain(context.Background()))
+
+	// not tracked without counter
+	InFlight(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		assert.Zero(t, counter.Calls())
+		return nil
+	})(context.Background(), &rpc.JsonRpcMessage{})
+}
@@ -0,0 +1,308 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/rpc/jsonscan"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JSON-RPC error code when request or response exceeds limits (EIP-1474)
+	errCodeLimitExceeded = -32005
+)
+
+// limitExceededError JSON-RPC error when request or response exceeds limits.
+type limitExceededError struct {
+	msg string
+}
+
+func newLimitExceededError(format string, args ...interface{}) *limitExceededError {
+	return &limitExceededError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *limitExceededError) Error() string  { return e.msg }
+func (e *limitExceededError) ErrorCode() int { return errCodeLimitExceeded }
+
+// RequestLimiter enforces request and response size limits, which are configured globally
+// and could be overridden by the allowlist assigned to the request.
+type RequestLimiter struct {
+	global acl.Limits
+
+	mu      sync.Mutex
+	wsConns map[string]int // key => number of concurrent websocket connections
+}
+
+func MustNewRequestLimiterFromViper() *RequestLimiter {
+	limiter := RequestLimiter{wsConns: make(map[string]int)}
+	viper.MustUnmarshalKey("rpc.limits", &limiter.global)
+
+	if err := limiter.global.Validate(); err != nil {
+		panic(err)
+	}
+
+	return &limiter
+}
+
+// limits returns the effective limits for the request context.
+func (l *RequestLimiter) limits(ctx context.Context) *acl.Limits {
+	if al, ok := allowListFromContext(ctx); ok {
+		return l.global.Override(al.Limits)
+	}
+
+	return &l.global
+}
+
+// allowListFromContext returns the allowlist assigned to the request context, which could be
+// used even before authentication resolved, eg., by HTTP middlewares.
+func allowListFromContext(ctx context.Context) (*acl.AllowList, bool) {
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok {
+		return nil, false
+	}
+
+	// authentication is not resolved yet for HTTP request or batch
+	if _, ok := handlers.GetAuthIdFromContext(ctx); !ok {
+		if svs, ok := rate.SVipStatusFromContext(ctx); ok {
+			ctx = context.WithValue(ctx, handlers.CtxKeyAuthId, svs.Key)
+		}
+	}
+
+	return registry.AllowList(ctx)
+}
+
+// Http limits the size of HTTP request body, or the connection lifecycle for websocket upgrade
+// or Server-Sent Events request. Note, it should be used after the RPC context values (eg., rate registry and access
+// token) injected.
+func (l *RequestLimiter) Http(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocketUpgrade(r) || IsEventStream(r) {
+			l.serveWs(w, r, l.limits(r.Context()), next)
+			return
+		}
+
+		maxSize := l.limits(r.Context()).MaxRequestBodySize
+		if maxSize <= 0 || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(data) > maxSize {
+			err := newLimitExceededError("request body too large, max %v bytes", maxSize)
+			writeErrorResponse(w, err)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeErrorResponse writes JSON-RPC error response rather than HTTP error status.
+func writeErrorResponse(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	msg := &rpc.JsonRpcMessage{ID: json.RawMessage("null")}
+	json.NewEncoder(w).Encode(msg.ErrorResponse(err))
+}
+
+// Batch limits the number of items in a batch request.
+func (l *RequestLimiter) Batch(next rpc.HandleBatchFunc) rpc.HandleBatchFunc {
+	return func(ctx context.Context, msgs []*rpc.JsonRpcMessage) []*rpc.JsonRpcMessage {
+		maxLen := l.limits(ctx).MaxBatchLength
+		if maxLen <= 0 || len(msgs) <= maxLen {
+			return next(ctx, msgs)
+		}
+
+		err := newLimitExceededError("batch too large, max %v items", maxLen)
+
+		resps := make([]*rpc.JsonRpcMessage, 0, len(msgs))
+		for _, msg := range msgs {
+			resps = append(resps, msg.ErrorResponse(err))
+		}
+
+		return resps
+	}
+}
+
+// Call limits the block range of `getLogs` filter, the size of response result, and the rate of
+// messages and subscriptions over websocket connection.
+func (l *RequestLimiter) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		limits := l.limits(ctx)
+
+		if limits.MaxLogsBlockRange > 0 {
+			span, ok, err := parseLogsFilterRange(ctx, msg)
+			if err != nil { // fail closed rather than bypass the limit
+				return msg.ErrorResponse(newLimitExceededError(
+					"block range unresolvable due to %v, please specify block numbers, max %v blocks",
+					err, limits.MaxLogsBlockRange,
+				))
+			}
+
+			if ok && span > limits.MaxLogsBlockRange {
+				return msg.ErrorResponse(newLimitExceededError(
+					"block range too large, max %v blocks", limits.MaxLogsBlockRange,
+				))
+			}
+		}
+
+		resp := limitWsCall(ctx, msg, limits, next)
+
+		if limits.MaxResponseSize > 0 && resp != nil && len(resp.Result) > limits.MaxResponseSize {
+			return msg.ErrorResponse(newLimitExceededError(
+				"response too large, max %v bytes", limits.MaxResponseSize,
+			))
+		}
+
+		return resp
+	}
+}
+
+// CheckResponseSize checks the size of response result against limits, which is used to limit
+// streaming responses since never buffered for the call middleware.
+func (l *RequestLimiter) CheckResponseSize(ctx context.Context, size int) error {
+	if maxSize := l.limits(ctx).MaxResponseSize; maxSize > 0 && size > maxSize {
+		return newLimitExceededError("response too large, max %v bytes", maxSize)
+	}
+
+	return nil
+}
+
+// headTags block (or epoch) tags resolved against the chain head, of which the ones behind the
+// chain head (eg., `finalized`) are approximated by the chain head as well.
+var headTags = map[string]bool{
+	"latest": true, "safe": true, "finalized": true, "pending": true,
+	"latest_state": true, "latest_mined": true, "latest_checkpoint": true,
+	"latest_confirmed": true, "latest_finalized": true,
+}
+
+// errChainHeadUnknown error when the range bounds are resolvable only against the chain head,
+// which is unknown though.
+var errChainHeadUnknown = errors.New("chain head unknown")
+
+// parseLogsFilterRange parses the number of blocks (or epochs) requested by `getLogs` filter, of
+// which the tagged or omitted range bounds are resolved against the chain head from context.
+// Returns false if not a range, eg., filter by block hash, or error if chain head unknown.
+func parseLogsFilterRange(ctx context.Context, msg *rpc.JsonRpcMessage) (uint64, bool, error) {
+	if msg.Method != "eth_getLogs" && msg.Method != "cfx_getLogs" {
+		return 0, false, nil
+	}
+
+	return parseFilterRange(ctx, msg)
+}
+
+// parseFilterRange parses the number of blocks (or epochs) requested by the filter of first
+// param, eg., `getLogs` or `trace_filter` filter, which is scanned lazily since checked for
+// every `getLogs` request.
+func parseFilterRange(ctx context.Context, msg *rpc.JsonRpcMessage) (uint64, bool, error) {
+	filter, ok := jsonscan.Element(msg.Params, 0)
+	if !ok {
+		return 0, false, nil
+	}
+
+	// logs of the specified blocks only
+	if hasField(filter, "blockHash") || hasField(filter, "blockHashes") {
+		return 0, false, nil
+	}
+
+	// core space filter by epochs unless by block numbers
+	fromField, toField := "fromBlock", "toBlock"
+	if !hasField(filter, fromField) && !hasField(filter, toField) &&
+		(strings.HasPrefix(msg.Method, "cfx_") || hasField(filter, "fromEpoch") || hasField(filter, "toEpoch")) {
+		fromField, toField = "fromEpoch", "toEpoch"
+	}
+
+	return rangeSpan(ctx, filter, fromField, toField)
+}
+
+// hasField checks if the field of JSON object is specified and not null.
+func hasField(filter []byte, field string) bool {
+	value, ok := jsonscan.Field(filter, field)
+	return ok && !jsonscan.IsNull(value)
+}
+
+func rangeSpan(ctx context.Context, filter []byte, fromField, toField string) (uint64, bool, error) {
+	from, ok := parseRangeBound(filter, fromField)
+	if !ok {
+		return 0, false, nil
+	}
+
+	to, ok := parseRangeBound(filter, toField)
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch {
+	case from.head && to.head:
+		return 1, true, nil
+	case from.head || to.head:
+		head, ok := handlers.GetChainHeadFromContext(ctx)
+		if !ok {
+			return 0, false, errChainHeadUnknown
+		}
+
+		if from.head {
+			from.num = head
+		} else {
+			to.num = head
+		}
+	}
+
+	if to.num < from.num {
+		return 0, false, nil
+	}
+
+	return to.num - from.num + 1, true, nil
+}
+
+// rangeBound bound of block (or epoch) range, which is either a number or the chain head.
+type rangeBound struct {
+	num  uint64
+	head bool
+}
+
+// parseRangeBound parses the range bound by number or tag, which defaults to the chain head if
+// omitted, eg., `latest` for evm space, and `latest_checkpoint` or `latest_state` for core space
+// which is approximated by the chain head.
+func parseRangeBound(filter []byte, field string) (rangeBound, bool) {
+	value, ok := jsonscan.Field(filter, field)
+	if !ok || jsonscan.IsNull(value) {
+		return rangeBound{head: true}, true
+	}
+
+	bound, ok := jsonscan.String(value)
+	if !ok {
+		return rangeBound{}, false
+	}
+
+	if string(bound) == "earliest" {
+		return rangeBound{}, true
+	}
+
+	if headTags[string(bound)] {
+		return rangeBound{head: true}, true
+	}
+
+	num, err := hexutil.DecodeUint64(string(bound))
+	return rangeBound{num: num}, err == nil
+}
@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func withTestChainHead(head uint64) context.Context {
+	return handlers.WithChainHead(context.Background(), func() (uint64, bool) {
+		return head, true
+	})
+}
+
+func TestParseFilterRange(t *testing.T) {
+	ctx := withTestChainHead(1000)
+
+	testCases := []struct {
+		method, params string
+		span           uint64
+		ok             bool
+	}{
+		{"eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x64"}]`, 100, true},
+		{"eth_getLogs", `[{"fromBlock":"earliest","toBlock":"0x9"}]`, 10, true},
+		{"eth_getLogs", `[{"fromBlock":"0x3e0","toBlock":"latest"}]`, 9, true},
+		{"eth_getLogs", `[{"fromBlock":"0x3e0"}]`, 9, true},
+		{"eth_getLogs", `[{"fromBlock":"0x3e0","toBlock":null}]`, 9, true},
+		{"eth_getLogs", `[{"fromBlock":"0x0","toBlock":"finalized"}]`, 1001, true},
+		{"eth_getLogs", `[{"fromBlock":"earliest","toBlock":"pending"}]`, 1001, true},
+		{"eth_getLogs", `[{"fromBlock":"latest","toBlock":"latest"}]`, 1, true},
+		{"eth_getLogs", `[{}]`, 1, true},
+		{"eth_getLogs", `[{"fromBlock":"safe","toBlock":"0x3e9"}]`, 2, true},
+		{"eth_getLogs", `[{"fromBlock":"0x64","toBlock":"0x1"}]`, 0, false},
+		{"eth_getLogs", `[{"fromBlock":"bad","toBlock":"0x1"}]`, 0, false},
+		{"eth_getLogs", `[{"blockHash":"0xabc"}]`, 0, false},
+		{"cfx_getLogs", `[{"fromEpoch":"earliest","toEpoch":"0x9"}]`, 10, true},
+		{"cfx_getLogs", `[{"fromEpoch":"0x0","toEpoch":"latest_state"}]`, 1001, true},
+		{"cfx_getLogs", `[{"fromEpoch":"0x0"}]`, 1001, true},
+		{"cfx_getLogs", `[{"fromBlock":"0x1","toBlock":"0xa"}]`, 10, true},
+		{"cfx_getLogs", `[{"blockHashes":["0xabc"]}]`, 0, false},
+		{"trace_filter", `[{"fromEpoch":"0x1","toEpoch":"0xa"}]`, 10, true},
+	}
+
+	for _, tc := range testCases {
+		span, ok, err := parseFilterRange(ctx, newCostTestMsg(tc.method, tc.params))
+		assert.NoError(t, err, "%v %v", tc.method, tc.params)
+		assert.Equal(t, tc.ok, ok, "%v %v", tc.method, tc.params)
+		assert.Equal(t, tc.span, span, "%v %v", tc.method, tc.params)
+	}
+
+	// chain head required to resolve range bound
+	_, _, err := parseFilterRange(context.Background(), newCostTestMsg("eth_getLogs", `[{"fromBlock":"0x1"}]`))
+	assert.Equal(t, errChainHeadUnknown, err)
+
+	// chain head not required if both bounds tagged
+	span, ok, err := parseFilterRange(context.Background(), newCostTestMsg("eth_getLogs", `[{"toBlock":"latest"}]`))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), span)
+}
+
+func TestRequestLimiterLogsBlockRange(t *testing.T) {
+	limiter := &RequestLimiter{global: acl.Limits{MaxLogsBlockRange: 100}, wsConns: make(map[string]int)}
+
+	var forwarded int
+	call := limiter.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		forwarded++
+		return &rpc.JsonRpcMessage{Result: json.RawMessage("[]")}
+	})
+
+	testCases := []struct {
+		ctx      context.Context
+		params   string
+		rejected bool
+	}{
+		{withTestChainHead(1000), `[{"fromBlock":"0x384","toBlock":"0x3e7"}]`, false},
+		{withTestChainHead(1000), `[{"fromBlock":"0x1","toBlock":"0x3e7"}]`, true},
+		{withTestChainHead(1000), `[{"fromBlock":"0x385"}]`, false},
+		{withTestChainHead(1000), `[{"fromBlock":"0x1"}]`, true},
+		{withTestChainHead(1000), `[{"fromBlock":"0x1","toBlock":"latest"}]`, true},
+		{withTestChainHead(1000), `[{"fromBlock":"earliest","toBlock":"finalized"}]`, true},
+		{withTestChainHead(1000), `[{"blockHash":"0xabc"}]`, false},
+		{context.Background(), `[{"fromBlock":"0x1","toBlock":"0x64"}]`, false},
+		{context.Background(), `[{"fromBlock":"0x1"}]`, true},
+		{context.Background(), `[{"fromBlock":"latest"}]`, false},
+	}
+
+	for _, tc := range testCases {
+		forwarded = 0
+
+		resp := call(tc.ctx, newCostTestMsg("eth_getLogs", tc.params))
+		if tc.rejected {
+			if assert.NotNil(t, resp.Error, tc.params) {
+				assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+			}
+			assert.Zero(t, forwarded, tc.params)
+		} else {
+			assert.Nil(t, resp.Error, tc.params)
+			assert.Equal(t, 1, forwarded, tc.params)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package middlewares
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ctxKeyWsConn = handlers.CtxKey("Infura-WS-Conn")
+)
+
+var (
+	errWsMessageTooLarge = errors.New("websocket message too large")
+
+	// close frame with status code 1009 (message too big) sent to client
+	wsCloseMessageTooBig = []byte{0x88, 0x02, 0x03, 0xf1}
+)
+
+// wsConn lifecycle states of websocket (or Server-Sent Events) connection to enforce limits.
+type wsConn struct {
+	subscriptions int64 // number of active subscriptions
+	lastActive    int64 // unix nano of last received message
+
+	mu      sync.Mutex
+	limiter *rate.Limiter // messages rate limiter, lazily created
+	netConn net.Conn      // hijacked connection
+}
+
+func newWsConn() *wsConn {
+	return &wsConn{lastActive: time.Now().UnixNano()}
+}
+
+// allow checks if message is allowed within the rate limit, which is adjusted on the fly if
+// limit changed due to allowlist reloaded.
+func (c *wsConn) allow(limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limiter == nil {
+		c.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+	} else if c.limiter.Limit() != rate.Limit(limit) {
+		c.limiter.SetLimit(rate.Limit(limit))
+		c.limiter.SetBurst(limit)
+	}
+
+	return c.limiter.Allow()
+}
+
+func (c *wsConn) touch() {
+	atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+}
+
+// idle checks if no message received and no subscription alive for the specified duration.
+func (c *wsConn) idle(timeout time.Duration) bool {
+	lastActive := time.Unix(0, atomic.LoadInt64(&c.lastActive))
+	return atomic.LoadInt64(&c.subscriptions) <= 0 && time.Since(lastActive) >= timeout
+}
+
+func (c *wsConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.netConn != nil {
+		c.netConn.Close()
+	}
+}
+
+// wsResponseWriter captures the hijacked connection during websocket upgrade, so that idle
+// connection could be closed by gateway, and the size of message read is limited.
+type wsResponseWriter struct {
+	http.ResponseWriter
+	conn           *wsConn
+	maxMessageSize int
+}
+
+func (w *wsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+
+	netConn, rw, err := hijacker.Hijack()
+	if err == nil && w.maxMessageSize > 0 {
+		netConn = &wsReadLimitConn{Conn: netConn, maxSize: int64(w.maxMessageSize)}
+	}
+
+	if err == nil {
+		w.conn.mu.Lock()
+		w.conn.netConn = netConn
+		w.conn.mu.Unlock()
+	}
+
+	return netConn, rw, err
+}
+
+// Flush implements `http.Flusher` for Server-Sent Events stream.
+func (w *wsResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// IsEventStream checks if it is a Server-Sent Events request, which is limited the same as
+// websocket connection.
+func IsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// wsConnKey returns the key to count concurrent websocket connections, which is the access
+// token if provided, or IP address otherwise.
+func wsConnKey(ctx context.Context) string {
+	if token, ok := handlers.GetAccessTokenFromContext(ctx); ok && len(token) > 0 {
+		return "key:" + token
+	}
+
+	ip, _ := handlers.GetIPAddressFromContext(ctx)
+	return "ip:" + ip
+}
+
+// acquireWsConn increases the number of concurrent websocket connections of the key, which
+// fails if exceeds the max limit.
+func (l *RequestLimiter) acquireWsConn(key string, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if max > 0 && l.wsConns[key] >= max {
+		return false
+	}
+
+	l.wsConns[key]++
+	return true
+}
+
+func (l *RequestLimiter) releaseWsConn(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.wsConns[key]--; l.wsConns[key] <= 0 {
+		delete(l.wsConns, key)
+	}
+}
+
+// serveWs limits the number of concurrent websocket connections, and closes the connection
+// once idle for a while. Note, ping/pong keepalive is handled by the RPC server.
+func (l *RequestLimiter) serveWs(w http.ResponseWriter, r *http.Request, limits *acl.Limits, next http.Handler) {
+	key := wsConnKey(r.Context())
+	if !l.acquireWsConn(key, limits.MaxWsConnections) {
+		err := newLimitExceededError("too many websocket connections, max %v", limits.MaxWsConnections)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer l.releaseWsConn(key)
+
+	conn := newWsConn()
+	ctx := context.WithValue(r.Context(), ctxKeyWsConn, conn)
+
+	if limits.WsIdleTimeoutSecs > 0 {
+		done := make(chan struct{})
+		defer close(done)
+
+		go watchIdleWsConn(conn, time.Duration(limits.WsIdleTimeoutSecs)*time.Second, done)
+	}
+
+	next.ServeHTTP(&wsResponseWriter{w, conn, limits.WsMessageSizeLimit()}, r.WithContext(ctx))
+}
+
+// watchIdleWsConn closes the websocket connection once idle for the specified timeout.
+func watchIdleWsConn(conn *wsConn, timeout time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if conn.idle(timeout) {
+				conn.close()
+				return
+			}
+		}
+	}
+}
+
+// wsReadLimitConn limits the size of websocket message (single or batch call) read from the
+// hijacked connection, by parsing frame headers only. Once a message exceeds the limit, the
+// connection is closed with status code 1009 before the payload is buffered by RPC server.
+type wsReadLimitConn struct {
+	net.Conn
+	maxSize int64
+
+	header    []byte // frame header being read
+	remaining int64  // remaining payload bytes of the current frame
+	msgSize   int64  // accumulated payload bytes of the current (fragmented) data message
+	err       error  // sticky error once message too large
+}
+
+func (c *wsReadLimitConn) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.Conn.Read(p)
+
+	// deliver the frames ahead of the oversized message, and fails the subsequent read
+	if offset, ok := c.scan(p[:n]); !ok {
+		c.err = errWsMessageTooLarge
+		c.Conn.Write(wsCloseMessageTooBig)
+
+		if offset > 0 {
+			return offset, nil
+		}
+
+		return 0, c.err
+	}
+
+	return n, err
+}
+
+// scan parses frame headers of the data read, and returns the offset of the frame header by
+// which the message exceeds the size limit if any, or 0 if the header began in previous read.
+func (c *wsReadLimitConn) scan(data []byte) (int, bool) {
+	var start int
+
+	for i := 0; i < len(data); {
+		if c.remaining > 0 {
+			skip := c.remaining
+			if left := int64(len(data) - i); skip > left {
+				skip = left
+			}
+
+			c.remaining -= skip
+			i += int(skip)
+
+			continue
+		}
+
+		if len(c.header) == 0 {
+			start = i
+		}
+
+		c.header = append(c.header, data[i])
+		i++
+
+		if len(c.header) < wsFrameHeaderSize(c.header) {
+			continue
+		}
+
+		length, opcode, fin := parseWsFrameHeader(c.header)
+		c.header = c.header[:0]
+
+		if length > uint64(c.maxSize) {
+			return start, false
+		}
+
+		c.remaining = int64(length)
+
+		if opcode >= 0x8 { // control frame
+			continue
+		}
+
+		if opcode != 0x0 { // not continuation frame
+			c.msgSize = 0
+		}
+
+		if c.msgSize += c.remaining; c.msgSize > c.maxSize {
+			return start, false
+		}
+
+		if fin {
+			c.msgSize = 0
+		}
+	}
+
+	return 0, true
+}
+
+// wsFrameHeaderSize returns the size of websocket frame header, which is only determined once
+// the first 2 bytes read.
+func wsFrameHeaderSize(header []byte) int {
+	if len(header) < 2 {
+		return 2
+	}
+
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+
+	if header[1]&0x80 != 0 { // masked
+		size += 4
+	}
+
+	return size
+}
+
+// parseWsFrameHeader returns the payload length, opcode and FIN bit of websocket frame header.
+func parseWsFrameHeader(header []byte) (length uint64, opcode byte, fin bool) {
+	switch length = uint64(header[1] & 0x7f); length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:10])
+	}
+
+	return length, header[0] & 0x0f, header[0]&0x80 != 0
+}
+
+// limitWsCall limits the messages rate and number of subscriptions of websocket connection.
+func limitWsCall(
+	ctx context.Context, msg *rpc.JsonRpcMessage, limits *acl.Limits, next rpc.HandleCallMsgFunc,
+) *rpc.JsonRpcMessage {
+	conn, ok := ctx.Value(ctxKeyWsConn).(*wsConn)
+	if !ok {
+		return next(ctx, msg)
+	}
+
+	conn.touch()
+
+	if limits.MaxWsMessagesPerSecond > 0 && !conn.allow(limits.MaxWsMessagesPerSecond) {
+		return msg.ErrorResponse(newLimitExceededError(
+			"too many messages, max %v per second", limits.MaxWsMessagesPerSecond,
+		))
+	}
+
+	subscribe := strings.HasSuffix(msg.Method, "_subscribe")
+	if subscribe && limits.MaxWsSubscriptions > 0 &&
+		atomic.LoadInt64(&conn.subscriptions) >= int64(limits.MaxWsSubscriptions) {
+		return msg.ErrorResponse(newLimitExceededError(
+			"too many subscriptions, max %v per connection", limits.MaxWsSubscriptions,
+		))
+	}
+
+	resp := next(ctx, msg)
+	if resp == nil || resp.Error != nil {
+		return resp
+	}
+
+	switch {
+	case subscribe:
+		atomic.AddInt64(&conn.subscriptions, 1)
+	case strings.HasSuffix(msg.Method, "_unsubscribe") && string(resp.Result) == "true":
+		atomic.AddInt64(&conn.subscriptions, -1)
+	}
+
+	return resp
+}
@@ -0,0 +1,194 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/gorilla/websocket"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitWsCall(t *testing.T) {
+	limits := &acl.Limits{MaxWsSubscriptions: 1, MaxWsMessagesPerSecond: 3}
+	ctx := context.WithValue(context.Background(), ctxKeyWsConn, newWsConn())
+
+	next := func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Result: json.RawMessage("true")}
+	}
+
+	resp := limitWsCall(ctx, &rpc.JsonRpcMessage{Method: "eth_subscribe"}, limits, next)
+	assert.Nil(t, resp.Error)
+
+	resp = limitWsCall(ctx, &rpc.JsonRpcMessage{Method: "eth_subscribe"}, limits, next)
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+
+	resp = limitWsCall(ctx, &rpc.JsonRpcMessage{Method: "eth_unsubscribe"}, limits, next)
+	assert.Nil(t, resp.Error)
+
+	// messages rate limited
+	resp = limitWsCall(ctx, &rpc.JsonRpcMessage{Method: "eth_subscribe"}, limits, next)
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+
+	// not websocket connection
+	resp = limitWsCall(context.Background(), &rpc.JsonRpcMessage{Method: "eth_subscribe"}, limits, next)
+	assert.Nil(t, resp.Error)
+}
+
+// wsFrame encodes masked websocket frame of client.
+func wsFrame(opcode byte, fin bool, payload []byte) []byte {
+	header := []byte{opcode, 0x80}
+	if fin {
+		header[0] |= 0x80
+	}
+
+	switch n := len(payload); {
+	case n < 126:
+		header[1] |= byte(n)
+	case n <= math.MaxUint16:
+		header[1] |= 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] |= 127
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	// zero masking key, so that payload is not masked
+	return append(append(header, 0, 0, 0, 0), payload...)
+}
+
+func TestWsReadLimitConnScan(t *testing.T) {
+	conn := &wsReadLimitConn{maxSize: 200}
+
+	// fragmented message with control frame in between
+	var data []byte
+	data = append(data, wsFrame(0x1, false, make([]byte, 100))...)
+	data = append(data, wsFrame(0x9, true, []byte("ping"))...)
+	data = append(data, wsFrame(0x0, true, make([]byte, 100))...)
+
+	// frames split across reads
+	for _, b := range data {
+		_, ok := conn.scan([]byte{b})
+		assert.True(t, ok)
+	}
+
+	// message size accumulated across fragments
+	data = wsFrame(0x2, false, make([]byte, 150))
+	offset := len(data)
+	data = append(data, wsFrame(0x0, true, make([]byte, 100))...)
+
+	pos, ok := conn.scan(data)
+	assert.False(t, ok)
+	assert.Equal(t, offset, pos)
+
+	// oversized frame with 64 bits length
+	conn = &wsReadLimitConn{maxSize: 200}
+	data = append(wsFrame(0x1, true, []byte("{}")), wsFrame(0x1, true, make([]byte, 70000))...)
+
+	pos, ok = conn.scan(data)
+	assert.False(t, ok)
+	assert.Equal(t, len(wsFrame(0x1, true, []byte("{}"))), pos)
+}
+
+type wsTestAPI struct{}
+
+func (api *wsTestAPI) Echo(data string) string { return data }
+
+func TestWsMessageSizeLimit(t *testing.T) {
+	limiter := &RequestLimiter{
+		global:  acl.Limits{MaxRequestBodySize: 100},
+		wsConns: make(map[string]int),
+	}
+
+	rpcServer := rpc.NewServer()
+	assert.NoError(t, rpcServer.RegisterName("test", &wsTestAPI{}))
+
+	server := httptest.NewServer(limiter.Http(rpcServer.WebsocketHandler([]string{"*"})))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	call := func(data string) (resp map[string]interface{}, err error) {
+		req := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["%v"]}`, data)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		err = conn.ReadJSON(&resp)
+		return resp, err
+	}
+
+	resp, err := call("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", resp["result"])
+
+	// closed with status code 1009 once message too large
+	_, err = call(strings.Repeat("0", 100))
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+}
+
+func TestServeWs(t *testing.T) {
+	limiter := &RequestLimiter{
+		global:  acl.Limits{MaxWsConnections: 1, WsIdleTimeoutSecs: 1},
+		wsConns: make(map[string]int),
+	}
+
+	upgraded := make(chan struct{})
+	handler := limiter.Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+
+		close(upgraded)
+
+		// blocks until connection closed due to idle
+		conn.Read(make([]byte, 1))
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	newUpgradeRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Upgrade", "websocket")
+		return req
+	}
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, newUpgradeRequest().Write(conn))
+	<-upgraded
+
+	// too many connections
+	resp, err := http.DefaultClient.Do(newUpgradeRequest())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	resp.Body.Close()
+
+	// closed once idle
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	assert.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		return len(limiter.wsConns) == 0
+	}, time.Second, 10*time.Millisecond)
+}
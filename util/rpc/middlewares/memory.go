@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/memory"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const ctxKeyMemoryReservation = handlers.CtxKey("Infura-Memory-Reservation")
+
+// defaultLargeMethods methods of potentially large response if not configured.
+var defaultLargeMethods = []string{
+	"eth_getLogs", "cfx_getLogs", "eth_getBlockReceipts", "parity_getBlockReceipts", "trace_*", "debug_*",
+}
+
+// MemoryBudgetConfig configurations of backpressure for large requests under memory pressure,
+// which shares the `rpc.memoryBudget` config with the memory accountant.
+type MemoryBudgetConfig struct {
+	// switch to turn on/off memory budget
+	Enabled bool
+	// methods of potentially large response, eg., `eth_getLogs`, and method ending with `*`
+	// matches by prefix
+	LargeMethods []string
+	// bytes reserved in advance for the response of large methods until responded
+	ResponseReserve int64 `default:"1048576"`
+	// HTTP request body larger than this is a large request, 0 means unlimited
+	LargeRequestSize int64 `default:"1048576"`
+	// max number of large requests queued under high memory pressure, 0 means rejected at once
+	QueueSize int32 `default:"100"`
+	// max duration to wait in queue before rejected, which is also the retry hint
+	MaxWait time.Duration `default:"1s"`
+}
+
+// memoryReservation bytes reserved for an HTTP request, including the request body and the
+// responses buffered until written.
+type memoryReservation struct {
+	bytes int64 // accessed atomically
+}
+
+// MemoryBudget applies backpressure to large requests when the process approaches the memory
+// ceiling. Large requests are queued once memory usage crosses the high watermark, and rejected
+// if the queue is full, waited too long or memory usage crosses the ceiling. Other requests are
+// always admitted, since they barely allocate memory.
+type MemoryBudget struct {
+	conf       MemoryBudgetConfig
+	accountant *memory.Accountant
+
+	queued int32 // number of large requests queued, accessed atomically
+}
+
+// MustNewMemoryBudgetFromViper creates an instance of MemoryBudget from viper, or nil if
+// disabled. Note, the default memory accountant should be initialized at first.
+func MustNewMemoryBudgetFromViper() *MemoryBudget {
+	var conf MemoryBudgetConfig
+	viper.MustUnmarshalKey("rpc.memoryBudget", &conf)
+
+	if !conf.Enabled || memory.Default() == nil {
+		return nil
+	}
+
+	if conf.ResponseReserve < 0 || conf.LargeRequestSize < 0 || conf.QueueSize < 0 || conf.MaxWait <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid RPC memory budget config")
+	}
+
+	if len(conf.LargeMethods) == 0 {
+		conf.LargeMethods = defaultLargeMethods
+	}
+
+	logrus.WithField("config", conf).Info("Memory budget RPC middleware enabled")
+
+	return NewMemoryBudget(conf, memory.Default())
+}
+
+func NewMemoryBudget(conf MemoryBudgetConfig, accountant *memory.Accountant) *MemoryBudget {
+	return &MemoryBudget{conf: conf, accountant: accountant}
+}
+
+// admit admits the large request, which is queued under high memory pressure, and returns
+// error if rejected.
+func (mb *MemoryBudget) admit(ctx context.Context) *admissionError {
+	rejected := &admissionError{class: "memory intensive", retryAfter: mb.conf.MaxWait}
+
+	switch mb.accountant.Pressure() {
+	case memory.PressureNone:
+		metrics.Registry.RPC.MemoryBackpressure("admitted").Mark(1)
+		return nil
+	case memory.PressureCritical:
+		metrics.Registry.RPC.MemoryBackpressure("rejected").Mark(1)
+		return rejected
+	}
+
+	if atomic.AddInt32(&mb.queued, 1) > mb.conf.QueueSize {
+		atomic.AddInt32(&mb.queued, -1)
+		metrics.Registry.RPC.MemoryBackpressure("rejected").Mark(1)
+		return rejected
+	}
+
+	metrics.Registry.RPC.MemoryBackpressure("queued").Mark(1)
+
+	admitted := mb.accountant.Wait(ctx, mb.conf.MaxWait)
+	atomic.AddInt32(&mb.queued, -1)
+
+	if !admitted {
+		metrics.Registry.RPC.MemoryBackpressure("rejected").Mark(1)
+		return rejected
+	}
+
+	return nil
+}
+
+// Http accounts the HTTP request body and the buffered responses until written, and applies
+// backpressure to large request body, which passes through if MemoryBudget is nil. Note,
+// websocket connections are accounted per call rather than the connection lifecycle.
+func (mb *MemoryBudget) Http(next http.Handler) http.Handler {
+	if mb == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || isWebsocketUpgrade(r) || IsEventStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		size := r.ContentLength
+		if size < 0 { // unknown
+			size = 0
+		}
+
+		if mb.conf.LargeRequestSize > 0 && size > mb.conf.LargeRequestSize {
+			if err := mb.admit(r.Context()); err != nil {
+				writeErrorResponse(w, err)
+				return
+			}
+		}
+
+		reservation := &memoryReservation{bytes: size}
+		mb.accountant.Reserve(size)
+
+		defer func() {
+			mb.accountant.Release(atomic.LoadInt64(&reservation.bytes))
+		}()
+
+		ctx := context.WithValue(r.Context(), ctxKeyMemoryReservation, reservation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Call applies backpressure to requests of large methods, and accounts the response buffered,
+// which passes through if MemoryBudget is nil.
+func (mb *MemoryBudget) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if mb == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if !matchMethod(mb.conf.LargeMethods, msg.Method) {
+			return mb.hold(ctx, next(ctx, msg))
+		}
+
+		if err := mb.admit(ctx); err != nil {
+			return msg.ErrorResponse(err.jsonError())
+		}
+
+		// reserve in advance until responded, so that memory pressure rises under request storms,
+		// which is released even if panicked and recovered by upstream middlewares
+		mb.accountant.Reserve(mb.conf.ResponseReserve)
+		defer mb.accountant.Release(mb.conf.ResponseReserve)
+
+		return mb.hold(ctx, next(ctx, msg))
+	}
+}
+
+// hold accounts the response buffered until the HTTP response written, which is not accounted
+// for websocket since never released until the connection closed.
+func (mb *MemoryBudget) hold(ctx context.Context, resp *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+	reservation, ok := ctx.Value(ctxKeyMemoryReservation).(*memoryReservation)
+	if !ok || resp == nil {
+		return resp
+	}
+
+	size := int64(len(resp.Result))
+	if resp.Error != nil {
+		size += int64(len(resp.Error.Message))
+	}
+
+	atomic.AddInt64(&reservation.bytes, size)
+	mb.accountant.Reserve(size)
+
+	return resp
+}
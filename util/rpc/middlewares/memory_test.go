@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/memory"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMemoryBudget(queueSize int32) (*MemoryBudget, *memory.Accountant) {
+	accountant := memory.NewAccountant(memory.Config{Ceiling: 1000, HighWatermark: 0.5})
+
+	return NewMemoryBudget(MemoryBudgetConfig{
+		LargeMethods:     []string{"eth_getLogs", "trace_*"},
+		ResponseReserve:  100,
+		LargeRequestSize: 100,
+		QueueSize:        queueSize,
+		MaxWait:          50 * time.Millisecond,
+	}, accountant), accountant
+}
+
+func TestMemoryBudgetCall(t *testing.T) {
+	mb, accountant := newTestMemoryBudget(1)
+
+	var reserved int64
+	handler := mb.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		reserved = accountant.Usage()
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x1"`)}
+	})
+
+	// response reserved in advance for large methods only
+	assert.Nil(t, handler(context.Background(), &rpc.JsonRpcMessage{Method: "trace_block"}).Error)
+	assert.Equal(t, int64(100), reserved)
+	assert.Nil(t, handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"}).Error)
+	assert.Equal(t, int64(0), reserved)
+	assert.Equal(t, int64(0), accountant.Usage())
+
+	// queued under high pressure and rejected once timed out
+	accountant.Reserve(600)
+
+	resp := handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getLogs"})
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+	assert.Nil(t, handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"}).Error)
+
+	// admitted once memory released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		accountant.Release(200)
+	}()
+
+	assert.Nil(t, handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getLogs"}).Error)
+
+	// rejected at once beyond the ceiling
+	accountant.Reserve(600)
+
+	start := time.Now()
+	resp = handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getLogs"})
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+}
+
+func TestMemoryBudgetCallPanic(t *testing.T) {
+	mb, accountant := newTestMemoryBudget(1)
+
+	handler := mb.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		panic("boom")
+	})
+
+	// recovered by upstream middleware
+	assert.Panics(t, func() {
+		handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getLogs"})
+	})
+
+	// reservation released
+	assert.Equal(t, int64(0), accountant.Usage())
+}
+
+func TestMemoryBudgetQueueFull(t *testing.T) {
+	mb, accountant := newTestMemoryBudget(0)
+	accountant.Reserve(600)
+
+	handler := mb.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{}
+	})
+
+	start := time.Now()
+	resp := handler(context.Background(), &rpc.JsonRpcMessage{Method: "eth_getLogs"})
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+}
+
+func TestMemoryBudgetHttp(t *testing.T) {
+	mb, accountant := newTestMemoryBudget(0)
+
+	var held int64
+	call := mb.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x01"`)}
+	})
+
+	handler := mb.Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call(r.Context(), &rpc.JsonRpcMessage{Method: "eth_call"})
+
+		// request body and response held until written
+		held = accountant.Usage()
+	}))
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	assert.Equal(t, int64(len(body)+len(`"0x01"`)), held)
+	assert.Equal(t, int64(0), accountant.Usage())
+
+	// large request body rejected under high pressure
+	accountant.Reserve(600)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat(" ", 101))))
+	assert.Contains(t, recorder.Body.String(), "memory intensive request rejected")
+}
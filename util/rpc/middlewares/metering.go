@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metering"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+const (
+	errCodeMethodNotFound = -32601
+)
+
+// Metering enforces the compute units quota and accounts the compute units of RPC requests
+// for the API key if provided. The quota is resolved by the tier of API key, which is the
+// name of its bound rate limit strategy. Besides, the quota is exempt for the bypass tiers of
+// allowlist, and the unlimited one is not metered at all.
+//
+// Note, only API keys registered in keyset are metered, and requests of methods not found are
+// not metered either, so as to bound the cardinality of usage aggregates.
+func Metering(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		meter, registry, apiKey, ok := meteredApiKeyFromContext(ctx)
+		if !ok {
+			return next(ctx, msg)
+		}
+
+		tier, _ := registry.KeyStrategy(apiKey)
+
+		var bypassTier acl.BypassTier
+		if al, ok := registry.AllowList(ctx); ok {
+			bypassTier = al.BypassTier
+		}
+
+		switch bypassTier {
+		case acl.BypassTierUnlimited: // neither quota nor usage metering
+			return next(ctx, msg)
+		case acl.BypassTierStandard:
+			if err := meter.CheckQuota(apiKey, tier); err != nil {
+				return msg.ErrorResponse(err)
+			}
+		}
+
+		resp := next(ctx, msg)
+		if resp != nil && resp.Error != nil && isMethodNotFound(msg.Method, resp.Error) {
+			return resp
+		}
+
+		meter.Record(apiKey, msg.Method)
+
+		return resp
+	}
+}
+
+// FailureMetering accounts the failed requests per JSON-RPC error code for the API key if
+// provided, including the ones rejected by rate limit or quota, so that they could be reported
+// to customers for self-diagnosis. Requests of the unlimited bypass tier are not metered.
+func FailureMetering(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		resp := next(ctx, msg)
+		if resp == nil || resp.Error == nil {
+			return resp
+		}
+
+		meter, registry, apiKey, ok := meteredApiKeyFromContext(ctx)
+		if !ok {
+			return resp
+		}
+
+		if al, ok := registry.AllowList(ctx); ok && al.BypassTier == acl.BypassTierUnlimited {
+			return resp
+		}
+
+		meter.RecordFailure(apiKey, resp.Error.Code)
+
+		return resp
+	}
+}
+
+// meteredApiKeyFromContext returns the API key to meter from context if it is registered in keyset,
+// along with the usage meter and rate limit registry.
+func meteredApiKeyFromContext(ctx context.Context) (*metering.Meter, *rate.Registry, string, bool) {
+	meter, ok := ctx.Value(handlers.CtxKeyUsageMeter).(*metering.Meter)
+	if !ok {
+		return nil, nil, "", false
+	}
+
+	apiKey, ok := handlers.GetAccessTokenFromContext(ctx)
+	if !ok || len(apiKey) == 0 || len(apiKey) > metering.MaxApiKeyLength {
+		return nil, nil, "", false
+	}
+
+	registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
+	if !ok || !registry.KeyRegistered(apiKey) {
+		return nil, nil, "", false
+	}
+
+	return meter, registry, apiKey, true
+}
+
+// isMethodNotFound checks if the RPC method is not found by the error response.
+func isMethodNotFound(method string, err *rpc.JsonError) bool {
+	return err.Code == errCodeMethodNotFound || isMethodNotFoundByError(method, err)
+}
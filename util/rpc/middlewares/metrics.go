@@ -33,6 +33,11 @@ func Metrics(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 			metricMethod = "method_not_found"
 		}
 
+		// separate metrics for additional chain served by the same gateway
+		if chain, ok := handlers.GetChainFromContext(ctx); ok {
+			metricMethod = fmt.Sprintf("%v/%v", chain, metricMethod)
+		}
+
 		// collect rpc QPS/latency etc.
 		metrics.Registry.RPC.UpdateDuration(metricMethod, resp.Error, start)
 		// collect traffic hits
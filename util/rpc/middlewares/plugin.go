@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"plugin"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PluginRegisterSymbol is the function exported by Go plugin to register middlewares, which is of
+// type `func(chain *middlewares.Chain) error`. Note, Go plugins are only supported if the gateway
+// is built with cgo enabled, and plugin must be built with the same version of Go toolchain and
+// dependencies as the gateway, eg., in the builder image of Dockerfile. Otherwise, middlewares
+// should be registered at compile time by `Use(..)`, which is always supported.
+const PluginRegisterSymbol = "RegisterMiddlewares"
+
+// pluginConfig configurations to load extra middlewares from Go plugins.
+type pluginConfig struct {
+	// paths of Go plugin (.so) files to load in order
+	Paths []string
+}
+
+// MustLoadPluginsFromViper loads the configured Go plugins to register middlewares into chain.
+func MustLoadPluginsFromViper(chain *Chain) {
+	var conf pluginConfig
+	viper.MustUnmarshalKey("rpc.plugins", &conf)
+
+	for _, path := range conf.Paths {
+		if err := LoadPlugin(path, chain); err != nil {
+			logrus.WithField("path", path).WithError(err).Fatal("Failed to load RPC middleware plugin")
+		}
+
+		logrus.WithField("path", path).Info("RPC middleware plugin loaded")
+	}
+}
+
+// LoadPlugin loads Go plugin of the specified path to register middlewares into chain.
+func LoadPlugin(path string, chain *Chain) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return errors.WithMessage(
+			err, "failed to open plugin (gateway must be built with cgo enabled and the same toolchain as plugin)",
+		)
+	}
+
+	sym, err := p.Lookup(PluginRegisterSymbol)
+	if err != nil {
+		return errors.WithMessage(err, "failed to lookup register function")
+	}
+
+	var register func(chain *Chain) error
+
+	switch fn := sym.(type) {
+	case func(chain *Chain) error:
+		register = fn
+	case *func(chain *Chain) error: // exported variable
+		register = *fn
+	default:
+		return errors.Errorf("invalid type of %v: %T", PluginRegisterSymbol, sym)
+	}
+
+	return register(chain)
+}
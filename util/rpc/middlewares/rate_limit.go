@@ -3,13 +3,39 @@ package middlewares
 import (
 	"context"
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metrics"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
 	"github.com/openweb3/go-rpc-provider"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
+const ctxKeyRateLimitStatus = handlers.CtxKey("Infura-Rate-Limit-Status")
+
+// AdaptiveShedding sheds low tier (anonymous) requests once rate limits are severely tightened
+// by adaptive rate limiting due to unhealthy upstream.
+func AdaptiveShedding(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if retryAfter, ok := rate.ShouldShed(ctx); ok {
+			metrics.Registry.RPC.RateLimitShed().Mark(1)
+
+			err := &admissionError{class: "anonymous", retryAfter: retryAfter}
+			return msg.ErrorResponse(err.jsonError())
+		}
+
+		return next(ctx, msg)
+	}
+}
+
 func QpsRateLimit(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
 		registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry)
@@ -17,21 +43,50 @@ func QpsRateLimit(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 			return next(ctx, msg)
 		}
 
+		al, _ := registry.AllowList(ctx)
+
 		// overall rate limit
-		if err := registry.Limit(ctx, "rpc_all_qps"); err != nil {
-			return msg.ErrorResponse(errQpsRateLimited(err))
+		if err := limitScopes(ctx, registry, al, "rpc_all_qps"); err != nil {
+			return rateLimitedResponse(ctx, msg, errQpsRateLimited(err))
 		}
 
 		// single method rate limit
 		resource := fmt.Sprintf("%v_qps", msg.Method)
-		if err := registry.Limit(ctx, resource); err != nil {
-			return msg.ErrorResponse(errQpsRateLimited(err))
+		if err := limitScopes(ctx, registry, al, resource); err != nil {
+			return rateLimitedResponse(ctx, msg, errQpsRateLimited(err))
+		}
+
+		if status, ok := ctx.Value(ctxKeyRateLimitStatus).(*rateLimitStatus); ok {
+			status.pass()
 		}
 
 		return next(ctx, msg)
 	}
 }
 
+// limitScopes evaluates rate limit at all scopes, and collects metrics for the rejected scope.
+// Requests are exempt from rate limit if the bypass tier of the assigned allowlist is not
+// standard, of which the monitored one evaluates the key scope only without rejection, so that
+// the shared quotas of other scopes are not consumed.
+func limitScopes(ctx context.Context, registry *rate.Registry, al *acl.AllowList, resource string) error {
+	if al != nil && al.BypassTier != acl.BypassTierStandard {
+		metrics.Registry.RPC.RateLimitBypassed(string(al.BypassTier), al.Name).Mark(1)
+
+		if al.BypassTier == acl.BypassTierMonitored && registry.Limit(ctx, resource) != nil {
+			metrics.Registry.RPC.RateLimitSoftRejected(al.Name, resource).Mark(1)
+		}
+
+		return nil
+	}
+
+	scope, err := registry.LimitScopes(ctx, resource)
+	if err != nil {
+		metrics.Registry.RPC.RateLimitRejected(string(scope), resource).Mark(1)
+	}
+
+	return err
+}
+
 func errQpsRateLimited(err error) error {
 	return errors.WithMessage(err, "allowed qps exceeded")
 }
@@ -43,9 +98,11 @@ func DailyMaxReqRateLimit(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 			return next(ctx, msg)
 		}
 
+		al, _ := registry.AllowList(ctx)
+
 		// constrain daily total requests
-		if err := registry.Limit(ctx, "rpc_all_daily"); err != nil {
-			return msg.ErrorResponse(errDailyMaxReqRateLimited(err))
+		if err := limitScopes(ctx, registry, al, "rpc_all_daily"); err != nil {
+			return rateLimitedResponse(ctx, msg, errDailyMaxReqRateLimited(err))
 		}
 
 		return next(ctx, msg)
@@ -55,3 +112,146 @@ func DailyMaxReqRateLimit(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 func errDailyMaxReqRateLimited(err error) error {
 	return errors.WithMessage(err, "daily request limit exceeded")
 }
+
+// rateLimitedResponse returns the limit exceeded error response with the quota status attached
+// as data, which is also reported by HTTP headers if enabled.
+func rateLimitedResponse(ctx context.Context, msg *rpc.JsonRpcMessage, err error) *rpc.JsonRpcMessage {
+	le, ok := rate.LimitErrorOf(err)
+	if !ok {
+		return msg.ErrorResponse(err)
+	}
+
+	if status, ok := ctx.Value(ctxKeyRateLimitStatus).(*rateLimitStatus); ok {
+		status.reject(le)
+	}
+
+	return msg.ErrorResponse(&rpc.JsonError{
+		Code:    errCodeLimitExceeded,
+		Message: err.Error(),
+		Data: map[string]int64{
+			"limit":      int64(le.Limit),
+			"remaining":  0,
+			"reset":      ceilSecs(le.Reset),
+			"retryAfter": ceilSecs(le.RetryAfter),
+		},
+	})
+}
+
+func ceilSecs(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// RateLimitHeadersConfig configurations to report rate limit status by HTTP response headers.
+type RateLimitHeadersConfig struct {
+	// switch to turn on/off rate limit response headers
+	Enabled bool
+	// whether to respond with HTTP status 429 if all calls of request are rate limited
+	TooManyRequestsStatus bool `default:"true"`
+}
+
+// MustNewRateLimitHeadersFromViper creates HTTP middleware to report rate limit status by
+// response headers, which passes through all requests if disabled.
+func MustNewRateLimitHeadersFromViper() handlers.Middleware {
+	var conf RateLimitHeadersConfig
+	viper.MustUnmarshalKey("rpc.rateLimitHeaders", &conf)
+
+	if !conf.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	logrus.WithField("config", conf).Info("Rate limit response headers HTTP middleware enabled")
+
+	return RateLimitHeaders(conf)
+}
+
+// RateLimitHeaders adds `X-RateLimit-Limit`, `X-RateLimit-Remaining`, `X-RateLimit-Reset` and
+// `Retry-After` response headers if any call of request is rate limited. Websocket upgrade and
+// Server-Sent Events requests are passed through, whose calls are reported by error data only.
+func RateLimitHeaders(conf RateLimitHeadersConfig) handlers.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isWebsocketUpgrade(r) || IsEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := &rateLimitStatus{}
+			ctx := context.WithValue(r.Context(), ctxKeyRateLimitStatus, status)
+
+			rw := &rateLimitWriter{ResponseWriter: w, conf: conf, status: status}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// rateLimitStatus collects the rate limit status of calls in the same HTTP request.
+type rateLimitStatus struct {
+	mu       sync.Mutex
+	passed   int
+	rejected *rate.LimitError // the one with the longest retry-after
+}
+
+func (s *rateLimitStatus) pass() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.passed++
+}
+
+func (s *rateLimitStatus) reject(le *rate.LimitError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rejected == nil || le.RetryAfter > s.rejected.RetryAfter {
+		s.rejected = le
+	}
+}
+
+// rateLimitWriter writes the rate limit headers along with the response headers.
+type rateLimitWriter struct {
+	http.ResponseWriter
+
+	conf        RateLimitHeadersConfig
+	status      *rateLimitStatus
+	wroteHeader bool
+}
+
+func (w *rateLimitWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	w.status.mu.Lock()
+	le, passed := w.status.rejected, w.status.passed
+	w.status.mu.Unlock()
+
+	if le != nil {
+		header := w.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(le.Limit))
+		header.Set("X-RateLimit-Remaining", "0")
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSecs(le.Reset), 10))
+		header.Set("Retry-After", strconv.FormatInt(ceilSecs(le.RetryAfter), 10))
+
+		if w.conf.TooManyRequestsStatus && passed == 0 && statusCode == http.StatusOK {
+			statusCode = http.StatusTooManyRequests
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *rateLimitWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *rateLimitWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
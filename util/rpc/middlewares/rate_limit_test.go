@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	le := &rate.LimitError{Limit: 10, Reset: 1500 * time.Millisecond, RetryAfter: 1500 * time.Millisecond}
+
+	serve := func(calls ...bool) *httptest.ResponseRecorder {
+		handler := RateLimitHeaders(RateLimitHeadersConfig{TooManyRequestsStatus: true})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, limited := range calls {
+					status := r.Context().Value(ctxKeyRateLimitStatus).(*rateLimitStatus)
+					if limited {
+						status.reject(le)
+					} else {
+						status.pass()
+					}
+				}
+
+				w.Write([]byte("{}"))
+			}),
+		)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		return recorder
+	}
+
+	// not limited
+	recorder := serve(false)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Retry-After"))
+
+	// all limited
+	recorder = serve(true)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "10", recorder.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", recorder.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", recorder.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+
+	// partially limited batch
+	recorder = serve(false, true)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+}
+
+func TestRateLimitedResponse(t *testing.T) {
+	le := &rate.LimitError{Limit: 10, Reset: time.Second, RetryAfter: time.Second}
+
+	resp := rateLimitedResponse(context.Background(), &rpc.JsonRpcMessage{}, errQpsRateLimited(le))
+	assert.Equal(t, errCodeLimitExceeded, resp.Error.Code)
+
+	data, _ := json.Marshal(resp.Error.Data)
+	assert.JSONEq(t, `{"limit":10,"remaining":0,"reset":1,"retryAfter":1}`, string(data))
+}
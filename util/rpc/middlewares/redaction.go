@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var errRedactionFailed = errors.New("failed to redact response")
+
+// Redaction strips or masks response fields as per the redaction rules of allowlist assigned to
+// the request, eg., to hide transaction input data or peer info from some tier.
+func Redaction(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		resp := next(ctx, msg)
+
+		if al, ok := allowListFromContext(ctx); ok && len(al.Redactions) > 0 {
+			return redactResponse(al, msg, resp)
+		}
+
+		return resp
+	}
+}
+
+// redactResponse returns a copy of response with result redacted, since the response might be
+// shared, eg., cached or coalesced with other requests.
+func redactResponse(al *acl.AllowList, msg, resp *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+	if resp == nil || resp.Error != nil || len(resp.Result) == 0 {
+		return resp
+	}
+
+	result, redacted, err := al.Redact(msg.Method, resp.Result)
+	if err != nil {
+		// never respond with the unredacted result
+		logrus.WithFields(logrus.Fields{
+			"allowlist": al.Name,
+			"method":    msg.Method,
+		}).WithError(err).Warn("Failed to redact RPC response")
+
+		return msg.ErrorResponse(errRedactionFailed)
+	}
+
+	if !redacted {
+		return resp
+	}
+
+	metrics.Registry.RPC.Redacted(msg.Method).Mark(1)
+
+	copied := *resp
+	copied.Result = result
+
+	return &copied
+}
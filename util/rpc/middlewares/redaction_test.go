@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactResponse(t *testing.T) {
+	al, err := acl.ParseAllowList("tier", `{"Redactions": [
+		{"Method": "eth_getBlockBy*", "Fields": ["transactions.*.input"], "Mask": "0x"},
+		{"Method": "admin_peers", "Fields": ["*.network", "*.caps.*"]}
+	]}`, "eth")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		method, result, expected string
+	}{
+		{
+			"eth_getBlockByNumber",
+			`{"number":"0x1","difficulty":123456789012345678901234567890,"transactions":[{"hash":"0x01","input":"0xabcd"},"0x02"]}`,
+			`{"number":"0x1","difficulty":123456789012345678901234567890,"transactions":[{"hash":"0x01","input":"0x"},"0x02"]}`,
+		},
+		{
+			"admin_peers",
+			`[{"id":"1","network":{"remoteAddress":"1.2.3.4:30303"},"caps":["eth/66"]}]`,
+			`[{"id":"1","caps":[]}]`,
+		},
+		{"eth_blockNumber", `"0x1"`, `"0x1"`},
+		{"eth_getBlockByHash", `null`, `null`},
+	}
+
+	for _, tc := range testCases {
+		msg := &rpc.JsonRpcMessage{ID: json.RawMessage("1"), Method: tc.method}
+		resp := &rpc.JsonRpcMessage{ID: msg.ID, Result: json.RawMessage(tc.result)}
+
+		redacted := redactResponse(al, msg, resp)
+		assert.JSONEq(t, tc.expected, string(redacted.Result), tc.method)
+
+		// shared response untouched
+		assert.Equal(t, tc.result, string(resp.Result))
+	}
+
+	// invalid rules rejected
+	_, err = acl.ParseAllowList("tier", `{"Redactions": [{"Method": "eth_call", "Fields": ["a..b"]}]}`, "eth")
+	assert.Error(t, err)
+
+	_, err = acl.ParseAllowList("tier", `{"Redactions": [{"Method": "eth_call"}]}`, "eth")
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// HTTP request headers of signed request, where signature is the hex encoded HMAC-SHA256 of
+	// `${timestamp}.${body}` with the secret of access token, and timestamp is in unix seconds.
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+
+	// JSON-RPC error code when request signature is missing or invalid
+	errCodeUnauthorized = -32001
+
+	ctxKeySignedRequest = handlers.CtxKey("Infura-Signed-Request")
+)
+
+// signatureError JSON-RPC error when request signature is missing or invalid.
+type signatureError struct {
+	msg string
+}
+
+func newSignatureError(format string, args ...interface{}) *signatureError {
+	return &signatureError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *signatureError) Error() string  { return e.msg }
+func (e *signatureError) ErrorCode() int { return errCodeUnauthorized }
+
+// SignatureConfig configurations to verify HMAC signed requests.
+type SignatureConfig struct {
+	// switch to turn on/off request signature verification
+	Enabled bool
+	// methods that must be signed if the access token has a secret, eg., `eth_sendRawTransaction`
+	Methods []string
+	// max clock skew of request timestamp, within which the same signature is rejected as replay
+	Window time.Duration `default:"5m"`
+	// max number of signatures remembered to detect replay
+	ReplayCacheSize int `default:"100000"`
+}
+
+// SignatureVerifier verifies HMAC signed requests with the secret of access token, so that the
+// high-value methods could require signed requests rather than bare access tokens.
+type SignatureVerifier struct {
+	conf    SignatureConfig
+	methods map[string]bool
+	seen    *util.ExpirableLruCache // hex encoded MAC => timestamp
+}
+
+// MustNewSignatureVerifierFromViper creates an instance of SignatureVerifier from viper, or nil
+// if disabled.
+func MustNewSignatureVerifierFromViper() *SignatureVerifier {
+	var conf SignatureConfig
+	viper.MustUnmarshalKey("rpc.signature", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.Window <= 0 || conf.ReplayCacheSize <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid request signature config")
+	}
+
+	logrus.WithField("config", conf).Info("Request signature verification enabled")
+
+	return NewSignatureVerifier(conf)
+}
+
+func NewSignatureVerifier(conf SignatureConfig) *SignatureVerifier {
+	v := &SignatureVerifier{
+		conf:    conf,
+		methods: make(map[string]bool),
+		// signatures beyond twice the window are rejected by timestamp anyway
+		seen: util.NewExpirableLruCache(conf.ReplayCacheSize, 2*conf.Window),
+	}
+
+	for _, method := range conf.Methods {
+		v.methods[method] = true
+	}
+
+	return v
+}
+
+// Http verifies the signature of HTTP request or websocket upgrade request if signed, which
+// passes through if verifier is nil. Note, it should be used after the RPC context values (eg.,
+// rate registry and access token) injected and the request body limited.
+func (v *SignatureVerifier) Http(next http.Handler) http.Handler {
+	if v == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get(SignatureHeader)
+		if len(signature) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := v.verify(r.Context(), r.Header.Get(SignatureTimestampHeader), signature, body); err != nil {
+			writeErrorResponse(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ctxKeySignedRequest, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (v *SignatureVerifier) verify(ctx context.Context, timestamp, signature string, body []byte) error {
+	ki, ok := rate.SVipStatusFromContext(ctx)
+	if !ok || len(ki.Secret) == 0 {
+		return newSignatureError("no signing secret configured for access token")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return newSignatureError("invalid signature timestamp")
+	}
+
+	if skew := time.Since(time.Unix(ts, 0)); skew > v.conf.Window || skew < -v.conf.Window {
+		return newSignatureError("signature timestamp out of window")
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, Sign(ki.Secret, timestamp, body)) {
+		return newSignatureError("invalid signature")
+	}
+
+	// keyed by the decoded MAC, since hex decoding is case insensitive
+	if v.seen.ContainsOrAdd(hex.EncodeToString(sig), ts) {
+		return newSignatureError("signature replayed")
+	}
+
+	return nil
+}
+
+// Call rejects the configured methods if not signed while the access token has a secret, which
+// passes through if verifier is nil.
+func (v *SignatureVerifier) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if v == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if !v.methods[msg.Method] {
+			return next(ctx, msg)
+		}
+
+		if ki, ok := rate.SVipStatusFromContext(ctx); !ok || len(ki.Secret) == 0 {
+			return next(ctx, msg)
+		}
+
+		if signed, _ := ctx.Value(ctxKeySignedRequest).(bool); !signed {
+			return msg.ErrorResponse(newSignatureError("signed request required for method %v", msg.Method))
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// Sign returns the HMAC-SHA256 signature of request body at timestamp with secret.
+func Sign(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/acl"
+	"github.com/Conflux-Chain/confura/util/rate"
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	kloader := rate.NewKeyLoader(func(filter *rate.KeysetFilter) ([]*rate.KeyInfo, error) {
+		return []*rate.KeyInfo{{Key: "signedKey", Secret: "secret"}, {Key: "bareKey"}}, nil
+	})
+	registry := rate.NewRegistry(kloader, acl.NewEthValidator)
+
+	verifier := NewSignatureVerifier(SignatureConfig{
+		Methods: []string{"eth_sendRawTransaction"}, Window: time.Minute, ReplayCacheSize: 10,
+	})
+
+	call := verifier.Call(func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		return &rpc.JsonRpcMessage{Result: json.RawMessage("true")}
+	})
+
+	handler := verifier.Http(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := call(r.Context(), &rpc.JsonRpcMessage{Method: "eth_sendRawTransaction"})
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	encodeSignature := hex.EncodeToString
+
+	serve := func(token, body string, ts time.Time, sign bool) *rpc.JsonError {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+		ctx := context.WithValue(req.Context(), handlers.CtxKeyRateRegistry, registry)
+		ctx = context.WithValue(ctx, handlers.CtxKeyAccessToken, token)
+		req = req.WithContext(ctx)
+
+		if sign {
+			timestamp := strconv.FormatInt(ts.Unix(), 10)
+			req.Header.Set(SignatureTimestampHeader, timestamp)
+			req.Header.Set(SignatureHeader, encodeSignature(Sign("secret", timestamp, []byte(body))))
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		var resp rpc.JsonRpcMessage
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+
+		return resp.Error
+	}
+
+	// signed request
+	assert.Nil(t, serve("signedKey", "tx1", time.Now(), true))
+
+	// replayed
+	err := serve("signedKey", "tx2", time.Now(), true)
+	assert.Nil(t, err)
+	err = serve("signedKey", "tx2", time.Now(), true)
+	assert.Equal(t, errCodeUnauthorized, err.Code)
+
+	// replayed with hex case changed
+	encodeSignature = func(sig []byte) string { return strings.ToUpper(hex.EncodeToString(sig)) }
+	err = serve("signedKey", "tx2", time.Now(), true)
+	assert.Equal(t, errCodeUnauthorized, err.Code)
+	encodeSignature = hex.EncodeToString
+
+	// out of window
+	err = serve("signedKey", "tx3", time.Now().Add(-2*time.Minute), true)
+	assert.Equal(t, errCodeUnauthorized, err.Code)
+
+	// not signed while secret configured
+	err = serve("signedKey", "tx4", time.Now(), false)
+	assert.Equal(t, errCodeUnauthorized, err.Code)
+
+	// bare access token without secret
+	assert.Nil(t, serve("bareKey", "tx5", time.Now(), false))
+
+	err = serve("bareKey", "tx6", time.Now(), true)
+	assert.Equal(t, errCodeUnauthorized, err.Code)
+
+	// nil verifier
+	var nilVerifier *SignatureVerifier
+	assert.Nil(t, nilVerifier.Call(call)(context.Background(), &rpc.JsonRpcMessage{}).Error)
+}
@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// JSON-RPC error code when request timed out
+	ErrCodeTimeout = -32008
+)
+
+// timeoutError JSON-RPC error when request timed out.
+type timeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("request timed out, %v exceeds %v", e.method, e.timeout)
+}
+
+func (e *timeoutError) ErrorCode() int { return ErrCodeTimeout }
+
+// TimeoutClass is a class of RPC methods with the same timeout, eg., fast reads, traces or
+// `eth_call`. Method could end with `*` to match by prefix, eg., `trace_*`.
+type TimeoutClass struct {
+	Name    string
+	Timeout time.Duration
+	Methods []string
+}
+
+// TimeoutConfig configurations of per method timeouts.
+type TimeoutConfig struct {
+	// switch to turn on/off method timeouts
+	Enabled bool
+	// timeout of methods not classified, 0 means no timeout
+	Default time.Duration
+	// method classes matched in order
+	Classes []TimeoutClass
+}
+
+// MethodTimeout enforces timeouts per RPC method class via context deadline, which is
+// propagated to the full node request if supported by the RPC client.
+type MethodTimeout struct {
+	conf TimeoutConfig
+}
+
+// MustNewMethodTimeoutFromViper creates an instance of MethodTimeout from viper, or nil if
+// disabled.
+func MustNewMethodTimeoutFromViper() *MethodTimeout {
+	var conf TimeoutConfig
+	viper.MustUnmarshalKey("rpc.timeouts", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	for _, class := range conf.Classes {
+		if class.Timeout <= 0 || len(class.Methods) == 0 {
+			logrus.WithField("class", class).Fatal("Invalid RPC method timeout class")
+		}
+	}
+
+	logrus.WithField("config", conf).Info("Method timeout RPC middleware enabled")
+
+	return NewMethodTimeout(conf)
+}
+
+func NewMethodTimeout(conf TimeoutConfig) *MethodTimeout {
+	return &MethodTimeout{conf: conf}
+}
+
+// Timeout returns the timeout of the specified RPC method, or 0 if no timeout. Note,
+// subscriptions are never timed out.
+func (t *MethodTimeout) Timeout(method string) time.Duration {
+	if strings.HasSuffix(method, "_subscribe") || strings.HasSuffix(method, "_unsubscribe") {
+		return 0
+	}
+
+	for _, class := range t.conf.Classes {
+		for _, m := range class.Methods {
+			if m == method || (strings.HasSuffix(m, "*") && strings.HasPrefix(method, m[:len(m)-1])) {
+				return class.Timeout
+			}
+		}
+	}
+
+	return t.conf.Default
+}
+
+// Call returns timeout error once the deadline exceeded rather than waiting for the handler
+// to complete, which passes through if MethodTimeout is nil.
+func (t *MethodTimeout) Call(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
+	if t == nil {
+		return next
+	}
+
+	return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		timeout := t.Timeout(msg.Method)
+		if timeout <= 0 {
+			return next(ctx, msg)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// recover panics since the handler is executed in a separate goroutine
+		respCh := make(chan *rpc.JsonRpcMessage, 1)
+		go func() { respCh <- Recover(next)(ctx, msg) }()
+
+		select {
+		case resp := <-respCh:
+			if ctx.Err() == context.DeadlineExceeded && resp.Error != nil {
+				return t.timeoutResponse(msg, timeout)
+			}
+
+			return resp
+		case <-ctx.Done():
+			return t.timeoutResponse(msg, timeout)
+		}
+	}
+}
+
+func (t *MethodTimeout) timeoutResponse(msg *rpc.JsonRpcMessage, timeout time.Duration) *rpc.JsonRpcMessage {
+	metrics.Registry.RPC.Timeout(msg.Method).Mark(1)
+	return msg.ErrorResponse(&timeoutError{method: msg.Method, timeout: timeout})
+}
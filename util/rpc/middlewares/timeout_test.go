@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodTimeoutClasses(t *testing.T) {
+	timeout := NewMethodTimeout(TimeoutConfig{
+		Default: time.Second,
+		Classes: []TimeoutClass{
+			{Name: "calls", Timeout: 5 * time.Second, Methods: []string{"eth_call"}},
+			{Name: "traces", Timeout: 10 * time.Second, Methods: []string{"trace_*"}},
+		},
+	})
+
+	assert.Equal(t, 5*time.Second, timeout.Timeout("eth_call"))
+	assert.Equal(t, 10*time.Second, timeout.Timeout("trace_block"))
+	assert.Equal(t, time.Second, timeout.Timeout("eth_blockNumber"))
+	assert.Equal(t, time.Duration(0), timeout.Timeout("eth_subscribe"))
+}
+
+func TestMethodTimeoutCall(t *testing.T) {
+	timeout := NewMethodTimeout(TimeoutConfig{
+		Classes: []TimeoutClass{
+			{Name: "calls", Timeout: 50 * time.Millisecond, Methods: []string{"eth_call"}},
+		},
+	})
+
+	// deadline propagated to handler
+	handler := func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		if _, ok := ctx.Deadline(); !ok {
+			return &rpc.JsonRpcMessage{Result: json.RawMessage(`"no deadline"`)}
+		}
+
+		<-ctx.Done()
+		return msg.ErrorResponse(ctx.Err())
+	}
+
+	resp := timeout.Call(handler)(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"})
+	assert.Equal(t, ErrCodeTimeout, resp.Error.Code)
+
+	// handler not aware of context
+	blocking := func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
+		time.Sleep(time.Second)
+		return &rpc.JsonRpcMessage{Result: json.RawMessage(`"0x1"`)}
+	}
+
+	start := time.Now()
+	resp = timeout.Call(blocking)(context.Background(), &rpc.JsonRpcMessage{Method: "eth_call"})
+	assert.Equal(t, ErrCodeTimeout, resp.Error.Code)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// no timeout
+	resp = timeout.Call(handler)(context.Background(), &rpc.JsonRpcMessage{Method: "eth_blockNumber"})
+	assert.Nil(t, resp.Error)
+}
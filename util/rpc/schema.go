@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/confura/util/openrpc"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/sirupsen/logrus"
+)
+
+// schemaValidationConfig configurations to validate responses of full nodes against the Ethereum
+// OpenRPC schemas, so as to catch misbehaving full nodes before clients do.
+type schemaValidationConfig struct {
+	// switch to turn on/off schema validation
+	Enabled bool
+	// OpenRPC document (eg., of https://github.com/ethereum/execution-apis), which overrides the
+	// built-in schemas of commonly used methods
+	SpecFile string
+	// ratio of responses to validate, since validation is CPU intensive for large responses
+	SampleRate float64 `default:"0.1"`
+}
+
+var (
+	ethSchemaSpec     *openrpc.Spec
+	ethSchemaSpecOnce sync.Once
+)
+
+// mustLoadEthSchemaSpec loads the OpenRPC spec to validate evm space responses, or nil if disabled.
+func mustLoadEthSchemaSpec() *openrpc.Spec {
+	ethSchemaSpecOnce.Do(func() {
+		conf := ethClientCfg.SchemaValidation
+		if !conf.Enabled {
+			return
+		}
+
+		spec := openrpc.DefaultSpec()
+
+		if len(conf.SpecFile) > 0 {
+			custom, err := openrpc.LoadSpec(conf.SpecFile)
+			if err != nil {
+				logrus.WithError(err).WithField("file", conf.SpecFile).Fatal("Failed to load OpenRPC spec")
+			}
+
+			spec.Merge(custom)
+		}
+
+		ethSchemaSpec = spec
+	})
+
+	return ethSchemaSpec
+}
+
+// middlewareSchemaValidation validates the sampled responses of full node against the result
+// schemas of RPC methods, and logs the mismatches if any.
+func middlewareSchemaValidation(fullnode string, spec *openrpc.Spec, sampleRate float64) providers.CallContextMiddleware {
+	return func(handler providers.CallContextFunc) providers.CallContextFunc {
+		return func(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+			err := handler(ctx, result, method, args...)
+			if err != nil || !spec.Has(method) || rand.Float64() >= sampleRate {
+				return err
+			}
+
+			// result is decoded into typed value already, so validate the re-encoded one as served
+			data, merr := json.Marshal(result)
+			if merr != nil {
+				return err
+			}
+
+			violations, verr := spec.Validate(method, data)
+			if verr != nil || len(violations) == 0 {
+				return err
+			}
+
+			metrics.Registry.RPC.SchemaViolations(fullnode, method).Mark(1)
+
+			details := make([]string, 0, len(violations))
+			for _, v := range violations {
+				details = append(details, v.String())
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"fullnode":   fullnode,
+				"method":     method,
+				"args":       args,
+				"violations": details,
+			}).Warn("Full node response mismatches OpenRPC schema")
+
+			return err
+		}
+	}
+}
@@ -2,12 +2,14 @@ package rpc
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/openweb3/go-rpc-provider"
 	"github.com/sirupsen/logrus"
@@ -19,10 +21,13 @@ type Protocol string
 const (
 	ProtocolHttp = "HTTP"
 	ProtocolWS   = "WS"
+	ProtocolSSE  = "SSE"
+	ProtocolIPC  = "IPC"
 )
 
 var (
-	// DefaultShutdownTimeout is default timeout to shutdown RPC server.
+	// DefaultShutdownTimeout is default timeout to shutdown RPC server, including the time
+	// to drain in-flight requests.
 	DefaultShutdownTimeout = 3 * time.Second
 
 	// defaultWsPingInterval the default websocket ping/pong heartbeating interval.
@@ -31,8 +36,22 @@ var (
 
 // Server serves JSON RPC services.
 type Server struct {
-	name    string
-	servers map[Protocol]*http.Server
+	name        string
+	handler     *rpc.Server
+	wsHandler   http.Handler // websocket handler without middlewares
+	middlewares []handlers.Middleware
+	servers     map[Protocol]*http.Server
+	tlsConfig   *tls.Config // nil if TLS termination disabled
+	ipc         *ipcServer  // nil if IPC disabled
+	inflight    *middlewares.InFlightCounter
+	stopOnce    sync.Once
+
+	mu         sync.Mutex
+	serving    int                         // number of protocols being served
+	onShutdown []func(ctx context.Context) // called once RPC handler stopped
+
+	// middlewares without TLS client authentication, which are applied to IPC connections
+	ipcMiddlewares []handlers.Middleware
 }
 
 // MustNewServer creates an instance of Server with specified RPC services.
@@ -52,16 +71,27 @@ func MustNewServer(name string, rpcs map[string]interface{}, middlewares ...hand
 		"name": name,
 	}).Info("RPC server APIs registered")
 
+	// any origin is allowed unless CORS policies configured, which are handled by middleware
+	cors := []string{"*"}
+	if viper.GetBool("rpc.cors.enabled") {
+		cors = nil
+	}
+
 	httpServer := http.Server{
-		Handler: node.NewHTTPHandlerStack(handler, []string{"*"}, []string{"*"}),
+		Handler: node.NewHTTPHandlerStack(handler, cors, []string{"*"}),
 	}
 
 	viper.SetDefault("rpc.wsPingInterval", defaultWsPingInterval)
-	wsServer := http.Server{
-		Handler: handler.WebsocketHandler([]string{"*"}, rpc.WebsocketOption{
-			WsPingInterval: viper.GetDuration("rpc.wsPingInterval"),
-		}),
-	}
+	// set in advance, since protocols are shutdown concurrently
+	viper.SetDefault("rpc.shutdownTimeout", DefaultShutdownTimeout)
+	wsHandler := handler.WebsocketHandler([]string{"*"}, rpc.WebsocketOption{
+		WsPingInterval: viper.GetDuration("rpc.wsPingInterval"),
+	})
+	wsServer := http.Server{Handler: wsHandler}
+
+	// in-flight RPC calls tracked per server, since RPC call middlewares are shared in process
+	inflight := newInFlightCounter()
+	middlewares = append([]handlers.Middleware{inflight.Http}, middlewares...)
 
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		httpServer.Handler = middlewares[i](httpServer.Handler)
@@ -69,7 +99,12 @@ func MustNewServer(name string, rpcs map[string]interface{}, middlewares ...hand
 	}
 
 	return &Server{
-		name: name,
+		name:           name,
+		handler:        handler,
+		wsHandler:      wsHandler,
+		middlewares:    middlewares,
+		ipcMiddlewares: middlewares,
+		inflight:       inflight,
 		servers: map[Protocol]*http.Server{
 			ProtocolHttp: &httpServer,
 			ProtocolWS:   &wsServer,
@@ -77,6 +112,55 @@ func MustNewServer(name string, rpcs map[string]interface{}, middlewares ...hand
 	}
 }
 
+// EnableSSE enables Server-Sent Events transport for subscriptions of the specified namespace,
+// eg., eth or cfx, which applies the same middlewares as websocket.
+func (s *Server) EnableSSE(namespace string) {
+	sse := newSSEHandler(namespace, s.wsHandler)
+
+	sseServer := http.Server{Handler: sse}
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		sseServer.Handler = s.middlewares[i](sseServer.Handler)
+	}
+
+	// SSE streams are not hijacked, which should be ended to shutdown gracefully
+	sseServer.RegisterOnShutdown(sse.shutdown)
+
+	s.servers[ProtocolSSE] = &sseServer
+}
+
+// EnableIPC enables JSON-RPC over Unix domain socket for co-located services if configured, which
+// applies the same middlewares as websocket, except that connections are authorized by the file
+// mode of socket rather than TLS client certificates. Returns the socket path to serve.
+func (s *Server) EnableIPC(key string) (string, bool) {
+	conf := mustLoadIpcConfig(key)
+	if len(conf.Path) == 0 {
+		return "", false
+	}
+
+	s.ipc = newIpcServer(conf, s.wsHandler, s.ipcMiddlewares)
+
+	return conf.Path, true
+}
+
+// EnableTLS enables TLS termination for all protocols if configured, so that RPC server could
+// be exposed without a separate terminating proxy. Clients could also be authenticated by
+// certificates if mutual TLS configured.
+func (s *Server) EnableTLS() {
+	var auth *clientCertAuth
+	s.tlsConfig, auth = mustLoadServerTLSConfig()
+
+	if auth == nil {
+		return
+	}
+
+	// client certificate is resolved before any other middleware, including those of protocols
+	// enabled later, eg., SSE
+	s.middlewares = append([]handlers.Middleware{auth.middleware}, s.middlewares...)
+	for _, server := range s.servers {
+		server.Handler = auth.middleware(server.Handler)
+	}
+}
+
 // MustServe serves RPC server in blocking way or panics if failed.
 func (s *Server) MustServe(endpoint string, protocol Protocol) {
 	logger := logrus.WithFields(logrus.Fields{
@@ -85,6 +169,11 @@ func (s *Server) MustServe(endpoint string, protocol Protocol) {
 		"protocol": protocol,
 	})
 
+	if protocol == ProtocolIPC {
+		s.mustServeIPC(endpoint, logger)
+		return
+	}
+
 	server, ok := s.servers[protocol]
 	if !ok {
 		logger.Fatal("RPC protocol unsupported")
@@ -95,11 +184,37 @@ func (s *Server) MustServe(endpoint string, protocol Protocol) {
 		logger.WithError(err).Fatal("Failed to listen to endpoint")
 	}
 
-	logger.Info("JSON RPC server started")
+	listener = tlsListener(listener, s.tlsConfig)
+
+	logger.WithField("tls", s.tlsConfig != nil).Info("JSON RPC server started")
 
 	server.Serve(listener)
 }
 
+func (s *Server) mustServeIPC(path string, logger *logrus.Entry) {
+	if s.ipc == nil {
+		logger.Fatal("RPC protocol unsupported")
+	}
+
+	listener, err := s.ipc.listen(path)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to listen to endpoint")
+	}
+
+	logger.WithField("mode", s.ipc.conf.Mode).Info("JSON RPC server started")
+
+	s.ipc.Serve(listener)
+}
+
+// RegisterOnShutdown registers a function to call once RPC handler stopped on graceful shutdown,
+// eg., to close upstream subscriptions, which should return once the context is done.
+func (s *Server) RegisterOnShutdown(f func(ctx context.Context)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onShutdown = append(s.onShutdown, f)
+}
+
 // MustServeGraceful serves RPC server in a goroutine until graceful shutdown.
 func (s *Server) MustServeGraceful(
 	ctx context.Context, wg *sync.WaitGroup, endpoint string, protocol Protocol,
@@ -107,6 +222,10 @@ func (s *Server) MustServeGraceful(
 	wg.Add(1)
 	defer wg.Done()
 
+	s.mu.Lock()
+	s.serving++
+	s.mu.Unlock()
+
 	go s.MustServe(endpoint, protocol)
 
 	<-ctx.Done()
@@ -114,8 +233,12 @@ func (s *Server) MustServeGraceful(
 	s.shutdown(protocol)
 }
 
+// shutdown stops accepting new connections, and then waits for in-flight requests to complete
+// up to the configured timeout. Note, hijacked websocket connections are not tracked by the HTTP
+// server, so RPC handler is stopped at last to close them along with subscriptions, once all the
+// protocols served shut down, since RPC handler is shared by protocols.
 func (s *Server) shutdown(protocol Protocol) {
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("rpc.shutdownTimeout"))
 	defer cancel()
 
 	logger := logrus.WithFields(logrus.Fields{
@@ -123,11 +246,59 @@ func (s *Server) shutdown(protocol Protocol) {
 		"protocol": protocol,
 	})
 
-	if err := s.servers[protocol].Shutdown(ctx); err != nil {
+	var err error
+	if protocol == ProtocolIPC {
+		err = s.ipc.Shutdown()
+	} else {
+		err = s.servers[protocol].Shutdown(ctx)
+	}
+
+	if err != nil {
 		logger.WithError(err).Error("Failed to shutdown RPC server")
 	} else {
 		logger.Info("Succeed to shutdown RPC server")
 	}
+
+	if !s.inflight.Drain(ctx) {
+		logger.WithField("inflight", s.inflight.Calls()).
+			Warn("Timeout to drain in-flight RPC requests")
+	}
+
+	s.mu.Lock()
+	s.serving--
+	serving, onShutdown := s.serving, s.onShutdown
+	s.mu.Unlock()
+
+	if serving > 0 { // other protocols still draining
+		return
+	}
+
+	s.stopOnce.Do(func() {
+		s.handler.Stop()
+
+		for _, f := range onShutdown {
+			f(ctx)
+		}
+
+		logger.Info("RPC handler stopped")
+	})
+}
+
+// Handler returns the HTTP handler with middlewares applied for the specified protocol, which
+// could be used to serve RPC requests on top of other transports.
+func (s *Server) Handler(protocol Protocol) (http.Handler, bool) {
+	server, ok := s.servers[protocol]
+	if !ok {
+		return nil, false
+	}
+
+	return server.Handler, true
 }
 
 func (s *Server) String() string { return s.name }
+
+// newInFlightCounter creates in-flight counter of server, since package `middlewares` is shadowed
+// by the argument of `MustNewServer`.
+func newInFlightCounter() *middlewares.InFlightCounter {
+	return &middlewares.InFlightCounter{}
+}
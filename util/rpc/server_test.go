@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+var hookInFlightOnce sync.Once
+
+type shutdownTestService struct {
+	release chan struct{}
+}
+
+func (s *shutdownTestService) Wait() string {
+	<-s.release
+	return "done"
+}
+
+func freeEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	// RPC call middlewares are hooked by the application in process
+	hookInFlightOnce.Do(func() { rpc.HookHandleCallMsg(middlewares.InFlight) })
+
+	svc := &shutdownTestService{release: make(chan struct{})}
+	server := MustNewServer("shutdown", map[string]interface{}{"test": svc})
+	other := MustNewServer("other", map[string]interface{}{"test": &shutdownTestService{}})
+
+	var mu sync.Mutex
+	var stopped int
+	server.RegisterOnShutdown(func(ctx context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped++
+	})
+
+	httpEndpoint, wsEndpoint := freeEndpoint(t), freeEndpoint(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	go server.MustServeGraceful(ctx, &wg, httpEndpoint, ProtocolHttp)
+	go server.MustServeGraceful(ctx, &wg, wsEndpoint, ProtocolWS)
+
+	var client *rpc.Client
+	assert.Eventually(t, func() (ok bool) {
+		var err error
+		client, err = rpc.DialWebsocket(context.Background(), "ws://"+wsEndpoint, "")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer client.Close()
+
+	result := make(chan error)
+	go func() {
+		var res string
+		result <- client.Call(&res, "test_wait")
+	}()
+
+	// tracked per server
+	assert.Eventually(t, func() bool { return server.inflight.Calls() == 1 }, time.Second, time.Millisecond)
+	assert.Zero(t, other.inflight.Calls())
+
+	// websocket connection never closed by HTTP protocol shutdown before drained
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	close(svc.release)
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("in-flight websocket call not responded")
+	}
+
+	wg.Wait()
+
+	// RPC handler stopped once all protocols shutdown
+	assert.Equal(t, 1, stopped)
+}
@@ -0,0 +1,275 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/middlewares"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	// interval to send comment line to keep SSE stream alive through proxies
+	sseKeepAliveInterval = 15 * time.Second
+)
+
+var (
+	errListenerClosed = errors.New("listener closed")
+
+	// topics of subscriptions exposed via SSE
+	sseTopics = map[string]bool{"newHeads": true, "logs": true}
+)
+
+// sseHandler streams subscription notifications as Server-Sent Events, eg.,
+// `GET /${accessToken}?topic=logs&filter={"address":"0x..."}`, for clients that can't use
+// websocket through their infrastructure.
+//
+// Subscription is made over an in-process websocket connection to the RPC handler, along with
+// the SSE request context, so that the same ACL and rate limits as websocket apply.
+type sseHandler struct {
+	namespace string       // namespace of `subscribe` method, eg., eth or cfx
+	ws        http.Handler // websocket handler without middlewares
+
+	quitOnce sync.Once
+	quit     chan struct{} // closed on server shutdown
+}
+
+func newSSEHandler(namespace string, ws http.Handler) *sseHandler {
+	return &sseHandler{namespace: namespace, ws: ws, quit: make(chan struct{})}
+}
+
+func (h *sseHandler) shutdown() {
+	h.quitOnce.Do(func() { close(h.quit) })
+}
+
+// sseSubscribeParams parses `subscribe` method params from the query of SSE request.
+func sseSubscribeParams(r *http.Request) ([]interface{}, error) {
+	query := r.URL.Query()
+
+	topic := query.Get("topic")
+	if !sseTopics[topic] {
+		return nil, errors.Errorf("unsupported topic %q, available topics are newHeads and logs", topic)
+	}
+
+	params := []interface{}{topic}
+
+	if filter := query.Get("filter"); len(filter) > 0 {
+		if topic != "logs" {
+			return nil, errors.New("filter only available for logs topic")
+		}
+
+		if !json.Valid([]byte(filter)) {
+			return nil, errors.New("invalid filter json")
+		}
+
+		params = append(params, json.RawMessage(filter))
+	}
+
+	return params, nil
+}
+
+func (h *sseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !middlewares.IsEventStream(r) {
+		http.Error(w, "text/event-stream must be accepted", http.StatusNotAcceptable)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	params, err := sseSubscribeParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, status, err := dialInProcess(r.Context(), h.ws, "ws://sse/")
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer conn.Close()
+
+	if err := h.subscribe(conn, params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable buffering of nginx
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	notifications := make(chan json.RawMessage, 100)
+	go readNotifications(conn, notifications)
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case result, ok := <-notifications:
+			if !ok { // connection closed
+				return
+			}
+
+			fmt.Fprintf(w, "data: %s\n\n", result)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-h.quit:
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+// dialInProcess connects to the websocket handler in process, which serves the upgrade request
+// with the specified context. It returns the HTTP status code if failed.
+func dialInProcess(ctx context.Context, ws http.Handler, url string) (*websocket.Conn, int, error) {
+	client, server := net.Pipe()
+
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ws.ServeHTTP(w, r.WithContext(ctx))
+		}),
+	}
+
+	listener := newConnListener(server)
+	defer listener.Close() // the only connection has been accepted
+
+	go httpServer.Serve(listener)
+
+	dialer := websocket.Dialer{
+		NetDialContext: func(context.Context, string, string) (net.Conn, error) {
+			return client, nil
+		},
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, url, nil)
+	if err == nil {
+		return conn, http.StatusOK, nil
+	}
+
+	client.Close()
+
+	if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp.StatusCode, errors.Errorf("subscription rejected: %v", resp.Status)
+	}
+
+	return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to subscribe")
+}
+
+// subscribe sends `subscribe` request and waits for the response.
+func (h *sseHandler) subscribe(conn *websocket.Conn, params []interface{}) error {
+	req := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  h.namespace + "_subscribe",
+		"params":  params,
+	}
+
+	if err := conn.WriteJSON(req); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := conn.ReadJSON(&resp); err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return errors.Errorf("subscription error %v: %v", resp.Error.Code, resp.Error.Message)
+	}
+
+	return nil
+}
+
+// readNotifications reads subscription results from websocket connection until closed.
+func readNotifications(conn *websocket.Conn, notifications chan<- json.RawMessage) {
+	defer close(notifications)
+
+	for {
+		var msg struct {
+			Params struct {
+				Result json.RawMessage `json:"result"`
+			} `json:"params"`
+		}
+
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		if len(msg.Params.Result) > 0 {
+			notifications <- msg.Params.Result
+		}
+	}
+}
+
+// connListener serves a single connection.
+type connListener struct {
+	conns chan net.Conn
+	addr  net.Addr
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	l := &connListener{
+		conns:  make(chan net.Conn, 1),
+		addr:   conn.LocalAddr(),
+		closed: make(chan struct{}),
+	}
+
+	l.conns <- conn
+
+	return l
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	default:
+	}
+
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr { return l.addr }
@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type sseTestService struct{}
+
+func (s *sseTestService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for i := 1; i <= 3; i++ {
+			notifier.Notify(sub.ID, map[string]int{"number": i})
+		}
+	}()
+
+	return sub, nil
+}
+
+func TestSSE(t *testing.T) {
+	server := MustNewServer("sse", map[string]interface{}{"eth": &sseTestService{}})
+	server.EnableSSE("eth")
+
+	handler, _ := server.Handler(ProtocolSSE)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	newRequest := func(query string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/?"+query, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		return req
+	}
+
+	// unsupported topic
+	resp, err := http.DefaultClient.Do(newRequest("topic=syncing"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	// subscription failed
+	resp, err = http.DefaultClient.Do(newRequest("topic=logs"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.DefaultClient.Do(newRequest("topic=newHeads"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []string
+
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < 3 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			events = append(events, strings.TrimPrefix(line, "data: "))
+		}
+	}
+
+	assert.Equal(t, []string{`{"number":1}`, `{"number":2}`, `{"number":3}`}, events)
+}
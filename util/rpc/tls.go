@@ -0,0 +1,398 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	// tlsConfigOnce loads TLS configurations for RPC servers only once, so that ACME certificate
+	// manager and its HTTP challenge server are shared among all RPC servers in process.
+	tlsConfigOnce   sync.Once
+	serverTLSConfig *tls.Config
+	serverCertAuth  *clientCertAuth
+
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// acmeConfig configurations to issue and renew certificates automatically via ACME, eg., Let's
+// Encrypt, which uses the TLS-ALPN-01 challenge by default.
+type acmeConfig struct {
+	// switch to turn on/off ACME certificate management
+	Enabled bool
+	// domains to issue certificates for, any other host names are rejected
+	Domains []string
+	// contact email for certificate expiration notices
+	Email string
+	// directory to cache issued certificates and account key across restarts
+	CacheDir string `default:"certs"`
+	// ACME directory URL, eg., staging environment for tests, or Let's Encrypt if empty
+	DirectoryUrl string
+	// HTTP endpoint to serve HTTP-01 challenges, eg., ":80", disabled if empty
+	HttpChallengeEndpoint string
+}
+
+// clientIdentityConfig maps identity of client certificate to access token.
+type clientIdentityConfig struct {
+	// common name or SAN (DNS name, URI or email address) of client certificate
+	Name string
+	// access token, whose allowlist and rate limit strategy apply to the client
+	Key string
+}
+
+// clientAuthConfig configurations of mutual TLS authentication for internal consumers.
+type clientAuthConfig struct {
+	// switch to turn on/off client certificate verification
+	Enabled bool
+	// CA certificates file in PEM format to verify client certificates
+	CaFile string
+	// whether to reject clients without a verified and mapped certificate, otherwise such
+	// clients are authenticated by access token as usual
+	Required bool
+	// client certificate identities mapped to access tokens
+	Identities []clientIdentityConfig
+}
+
+// tlsConfig configurations of native TLS termination for HTTP, websocket and SSE servers.
+type tlsConfig struct {
+	// switch to turn on/off TLS termination
+	Enabled bool
+	// certificate and private key files, which are hot reloaded once changed
+	CertFile string
+	KeyFile  string
+	// interval to check certificate files changed
+	ReloadInterval time.Duration `default:"1m"`
+	// automatic certificate management, which takes precedence over certificate files
+	Acme acmeConfig
+	// min TLS version, `1.0`, `1.1`, `1.2` or `1.3`
+	MinVersion string `default:"1.2"`
+	// cipher suites for TLS 1.2 and below by name, eg., `TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256`,
+	// or Go defaults if empty
+	CipherSuites []string
+	// mutual TLS authentication
+	ClientAuth clientAuthConfig
+}
+
+// mustLoadServerTLSConfig loads TLS configurations for RPC servers, or returns nil if disabled.
+// Besides, client certificate authentication is returned if mutual TLS enabled.
+func mustLoadServerTLSConfig() (*tls.Config, *clientCertAuth) {
+	tlsConfigOnce.Do(func() {
+		var conf tlsConfig
+		viper.MustUnmarshalKey("rpc.tls", &conf)
+
+		if !conf.Enabled {
+			return
+		}
+
+		tc, err := newServerTLSConfig(conf)
+		if err != nil {
+			logrus.WithField("config", conf).WithError(err).Fatal("Failed to load TLS config")
+		}
+
+		auth, err := newClientCertAuth(conf.ClientAuth, tc)
+		if err != nil {
+			logrus.WithField("config", conf).WithError(err).Fatal("Failed to load TLS client auth config")
+		}
+
+		serverTLSConfig, serverCertAuth = tc, auth
+
+		logrus.WithField("config", conf).Info("TLS termination enabled for RPC servers")
+	})
+
+	return serverTLSConfig, serverCertAuth
+}
+
+func newServerTLSConfig(conf tlsConfig) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[conf.MinVersion]
+	if !ok {
+		return nil, errors.Errorf("invalid min TLS version %v", conf.MinVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(conf.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	var tc *tls.Config
+
+	if conf.Acme.Enabled {
+		if tc, err = newAcmeTLSConfig(conf.Acme); err != nil {
+			return nil, errors.WithMessage(err, "failed to create ACME certificate manager")
+		}
+	} else {
+		reloader, err := newCertReloader(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to load certificate")
+		}
+
+		go reloader.watch(conf.ReloadInterval)
+
+		tc = &tls.Config{GetCertificate: reloader.getCertificate}
+	}
+
+	tc.MinVersion = minVersion
+	tc.CipherSuites = cipherSuites
+
+	return tc, nil
+}
+
+// parseCipherSuites parses cipher suites by name, of which insecure ones are not allowed.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		supported[cs.Name] = cs.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, errors.Errorf("unsupported cipher suite %v", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func newAcmeTLSConfig(conf acmeConfig) (*tls.Config, error) {
+	if len(conf.Domains) == 0 {
+		return nil, errors.New("no domain configured")
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(conf.CacheDir),
+		HostPolicy: autocert.HostWhitelist(conf.Domains...),
+		Email:      conf.Email,
+	}
+
+	if len(conf.DirectoryUrl) > 0 {
+		manager.Client = &acme.Client{DirectoryURL: conf.DirectoryUrl}
+	}
+
+	if len(conf.HttpChallengeEndpoint) > 0 {
+		go func() {
+			err := http.ListenAndServe(conf.HttpChallengeEndpoint, manager.HTTPHandler(nil))
+			logrus.WithError(err).Fatal("ACME HTTP challenge server stopped")
+		}()
+	}
+
+	// renewed automatically by manager before expiration
+	return manager.TLSConfig(), nil
+}
+
+// certReloader reloads certificate once the certificate or private key file changed, so that
+// certificate renewed by external tools takes effect without restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // the latest modification time of certificate and key files
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// reload loads certificate if files changed, and returns whether reloaded.
+func (r *certReloader) reload() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	changed := modTime.After(r.modTime)
+	r.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// watch checks certificate files periodically, which lives as long as the process.
+func (r *certReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reloaded, err := r.reload()
+		if err != nil {
+			// keep serving with the old certificate
+			logrus.WithError(err).Warn("Failed to reload TLS certificate")
+		} else if reloaded {
+			logrus.WithField("certFile", r.certFile).Info("TLS certificate reloaded")
+		}
+	}
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// clientCertAuth authenticates clients by verified certificates, which are mapped to access
+// tokens so that the allowlist and rate limit strategy of access token apply.
+type clientCertAuth struct {
+	required bool
+	tokens   map[string]string // identity => access token
+}
+
+// newClientCertAuth configures client certificate verification for TLS config, or returns nil
+// if mutual TLS disabled.
+func newClientCertAuth(conf clientAuthConfig, tc *tls.Config) (*clientCertAuth, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	pem, err := ioutil.ReadFile(conf.CaFile)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read CA file")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no CA certificate found")
+	}
+
+	tc.ClientCAs = pool
+	if conf.Required {
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	auth := &clientCertAuth{
+		required: conf.Required,
+		tokens:   make(map[string]string),
+	}
+
+	for _, id := range conf.Identities {
+		if len(id.Name) == 0 || len(id.Key) == 0 {
+			return nil, errors.Errorf("invalid client identity %v", id.Name)
+		}
+
+		auth.tokens[id.Name] = id.Key
+	}
+
+	return auth, nil
+}
+
+// clientCertIdentities returns the common name and SANs of certificate.
+func clientCertIdentities(cert *x509.Certificate) []string {
+	var ids []string
+
+	if len(cert.Subject.CommonName) > 0 {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+
+	ids = append(ids, cert.DNSNames...)
+	ids = append(ids, cert.EmailAddresses...)
+
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+
+	return ids
+}
+
+// resolve returns the access token mapped from the verified client certificate if any.
+func (a *clientCertAuth) resolve(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	for _, id := range clientCertIdentities(state.VerifiedChains[0][0]) {
+		if token, ok := a.tokens[id]; ok {
+			return token, true
+		}
+	}
+
+	return "", false
+}
+
+// middleware injects the access token mapped from client certificate into request context, and
+// rejects unmapped clients if required.
+func (a *clientCertAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := a.resolve(r.TLS); ok {
+			ctx := context.WithValue(r.Context(), handlers.CtxKeyClientCertToken, token)
+			r = r.WithContext(ctx)
+		} else if a.required {
+			http.Error(w, "client certificate not authorized", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tlsListener wraps listener with TLS termination if configured.
+func tlsListener(listener net.Listener, tc *tls.Config) net.Listener {
+	if tc == nil {
+		return listener
+	}
+
+	return tls.NewListener(listener, tc)
+}
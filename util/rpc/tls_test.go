@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/rpc/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate of the common name into files.
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	writeTestCert(t, certFile, keyFile, "old")
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	commonName := func() string {
+		cert, err := reloader.getCertificate(nil)
+		require.NoError(t, err)
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+
+		return leaf.Subject.CommonName
+	}
+
+	assert.Equal(t, "old", commonName())
+
+	// not changed
+	reloaded, err := reloader.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// renewed
+	writeTestCert(t, certFile, keyFile, "new")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	reloaded, err = reloader.reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new", commonName())
+
+	// keep the old one if failed to load
+	require.NoError(t, os.Remove(keyFile))
+
+	_, err = reloader.reload()
+	assert.Error(t, err)
+	assert.Equal(t, "new", commonName())
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	_, err := newServerTLSConfig(tlsConfig{MinVersion: "1.4"})
+	assert.Error(t, err)
+
+	_, err = newServerTLSConfig(tlsConfig{MinVersion: "1.2", CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	assert.Error(t, err)
+
+	_, err = newServerTLSConfig(tlsConfig{MinVersion: "1.2", Acme: acmeConfig{Enabled: true}})
+	assert.Error(t, err)
+
+	tc, err := newServerTLSConfig(tlsConfig{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		Acme:         acmeConfig{Enabled: true, Domains: []string{"rpc.example.com"}, CacheDir: os.TempDir()},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tc.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, tc.CipherSuites)
+	assert.NotNil(t, tc.GetCertificate)
+}
+
+func TestClientCertAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caFile, caKeyFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	writeTestCert(t, caFile, caKeyFile, "ca")
+
+	_, err = newClientCertAuth(clientAuthConfig{Enabled: true, CaFile: caKeyFile}, &tls.Config{})
+	assert.Error(t, err)
+
+	tc := &tls.Config{}
+	auth, err := newClientCertAuth(clientAuthConfig{
+		Enabled:    true,
+		CaFile:     caFile,
+		Required:   true,
+		Identities: []clientIdentityConfig{{Name: "indexer.internal", Key: "token"}},
+	}, tc)
+	require.NoError(t, err)
+	assert.NotNil(t, tc.ClientCAs)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tc.ClientAuth)
+
+	handler := auth.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(handlers.GetAccessToken(r)))
+	}))
+
+	serve := func(cert *x509.Certificate) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/other", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	// mapped by DNS SAN, which takes precedence over access token in URL path
+	recorder := serve(&x509.Certificate{Subject: pkix.Name{CommonName: "indexer"}, DNSNames: []string{"indexer.internal"}})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "token", recorder.Body.String())
+
+	// not mapped or no certificate
+	assert.Equal(t, http.StatusForbidden, serve(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}}).Code)
+	assert.Equal(t, http.StatusForbidden, serve(nil).Code)
+
+	// authenticated by access token as usual if not required
+	auth.required = false
+	recorder = serve(nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "other", recorder.Body.String())
+}
@@ -0,0 +1,354 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/openweb3/go-rpc-provider"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/pkg/errors"
+)
+
+// transportConfig configurations of pooled HTTP transport to full nodes, which is used instead
+// of the default HTTP/1.1 client if enabled.
+type transportConfig struct {
+	// switch to turn on/off pooled transport
+	Enabled bool
+	// whether to attempt HTTP/2 for https full nodes
+	Http2 bool `default:"true"`
+	// max idle connections per full node
+	MaxIdleConnsPerHost int `default:"256"`
+	// how long an idle connection remains in pool before closed
+	IdleConnTimeout time.Duration `default:"90s"`
+	// interval to close idle connections so that long-lived connections are re-established,
+	// eg., for load balancer behind, 0 to disable
+	ConnRecycleInterval time.Duration
+	// timeout to establish connection
+	DialTimeout time.Duration `default:"3s"`
+	// TCP keep-alive period
+	KeepAlive time.Duration `default:"30s"`
+}
+
+// pooledTransportKey identifies the pooled transport, which is shared only by clients of the same
+// full node with the same connection limit.
+type pooledTransportKey struct {
+	nodeName        string
+	maxConnsPerHost int
+}
+
+var (
+	// pooled transports shared by all clients of the same full node
+	pooledTransports   = make(map[pooledTransportKey]*pooledTransport)
+	pooledTransportsMu sync.Mutex
+)
+
+// pooledTransport is a pooled HTTP transport to a full node, with pool utilization metrics.
+type pooledTransport struct {
+	*http.Transport
+
+	key      pooledTransportKey
+	nodeName string
+	conns    int64 // opened connections
+	inflight int64 // in-flight requests
+
+	refs int           // number of clients sharing the transport, guarded by pooledTransportsMu
+	done chan struct{} // closed once released by all clients
+}
+
+// getOrNewPooledTransport returns the pooled transport to the full node of specified URL, which
+// should be released once the client closed.
+func getOrNewPooledTransport(nodeUrl string, maxConnsPerHost int, conf *transportConfig) *pooledTransport {
+	key := pooledTransportKey{
+		nodeName:        Url2NodeName(nodeUrl),
+		maxConnsPerHost: maxConnsPerHost,
+	}
+
+	pooledTransportsMu.Lock()
+	defer pooledTransportsMu.Unlock()
+
+	t, ok := pooledTransports[key]
+	if !ok {
+		t = newPooledTransport(key, conf)
+		pooledTransports[key] = t
+	}
+
+	t.refs++
+
+	return t
+}
+
+func newPooledTransport(key pooledTransportKey, conf *transportConfig) *pooledTransport {
+	t := &pooledTransport{
+		key:      key,
+		nodeName: key.nodeName,
+		done:     make(chan struct{}),
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   conf.DialTimeout,
+		KeepAlive: conf.KeepAlive,
+	}
+
+	t.Transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         t.dialContext(dialer),
+		ForceAttemptHTTP2:   conf.Http2,
+		MaxIdleConns:        conf.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost: conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     key.maxConnsPerHost,
+		IdleConnTimeout:     conf.IdleConnTimeout,
+		TLSHandshakeTimeout: conf.DialTimeout,
+	}
+
+	if conf.ConnRecycleInterval > 0 {
+		go t.recycle(conf.ConnRecycleInterval)
+	}
+
+	return t
+}
+
+// dialContext dials new connection, which is tracked for pool utilization metrics.
+func (t *pooledTransport) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		metrics.Registry.RPC.FullnodePoolDials(t.nodeName, err).Mark(1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		metrics.Registry.RPC.FullnodePoolConns(t.nodeName).Update(atomic.AddInt64(&t.conns, 1))
+
+		return &trackedConn{Conn: conn, transport: t}, nil
+	}
+}
+
+// recycle closes idle connections periodically until the transport released by all clients.
+func (t *pooledTransport) recycle(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.CloseIdleConnections()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// release removes the transport from pool and closes idle connections once released by all
+// the clients sharing it.
+func (t *pooledTransport) release() {
+	pooledTransportsMu.Lock()
+	defer pooledTransportsMu.Unlock()
+
+	if t.refs--; t.refs > 0 {
+		return
+	}
+
+	delete(pooledTransports, t.key)
+	close(t.done)
+
+	t.CloseIdleConnections()
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	metrics.Registry.RPC.FullnodePoolInflight(t.nodeName).Update(atomic.AddInt64(&t.inflight, 1))
+	defer func() {
+		metrics.Registry.RPC.FullnodePoolInflight(t.nodeName).Update(atomic.AddInt64(&t.inflight, -1))
+	}()
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.Registry.RPC.FullnodePoolConnReused(t.nodeName).Mark(info.Reused)
+		},
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	return t.Transport.RoundTrip(req)
+}
+
+// trackedConn decreases the number of opened connections once closed.
+type trackedConn struct {
+	net.Conn
+
+	transport *pooledTransport
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		conns := atomic.AddInt64(&c.transport.conns, -1)
+		metrics.Registry.RPC.FullnodePoolConns(c.transport.nodeName).Update(conns)
+	})
+
+	return c.Conn.Close()
+}
+
+// httpProvider is a JSON-RPC provider over pooled HTTP transport, which doesn't support
+// subscriptions.
+type httpProvider struct {
+	url       string
+	client    *http.Client
+	transport *pooledTransport
+	closeOnce sync.Once
+}
+
+// isHttpUrl checks if the full node URL is of http(s) scheme, which is required by pooled
+// transport.
+func isHttpUrl(nodeUrl string) bool {
+	u, err := url.Parse(nodeUrl)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// newPooledProvider creates JSON-RPC provider over pooled HTTP transport with request timeout
+// and retry as the default provider does.
+func newPooledProvider(nodeUrl string, opt providers.Option, conf *transportConfig) *providers.MiddlewarableProvider {
+	transport := getOrNewPooledTransport(nodeUrl, opt.MaxConnectionPerHost, conf)
+
+	var p *providers.MiddlewarableProvider
+	p = providers.NewMiddlewarableProvider(&httpProvider{
+		url:       nodeUrl,
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+	})
+	p = providers.NewTimeoutableProvider(p, opt.RequestTimeout)
+	p = providers.NewRetriableProvider(p, opt.RetryCount, opt.RetryInterval)
+
+	return p
+}
+
+type jsonrpcRequest struct {
+	Version string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type jsonrpcResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpc.JsonError  `json:"error"`
+}
+
+func newJsonrpcRequest(id int, method string, args []interface{}) *jsonrpcRequest {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	return &jsonrpcRequest{Version: "2.0", ID: id, Method: method, Params: args}
+}
+
+func (p *httpProvider) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	var resp jsonrpcResponse
+	if err := p.post(ctx, newJsonrpcRequest(1, method, args), &resp); err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (p *httpProvider) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	reqs := make([]*jsonrpcRequest, len(b))
+	for i, elem := range b {
+		reqs[i] = newJsonrpcRequest(i, elem.Method, elem.Args)
+	}
+
+	var resps []jsonrpcResponse
+	if err := p.post(ctx, reqs, &resps); err != nil {
+		return err
+	}
+
+	responded := make([]bool, len(b))
+	for _, resp := range resps {
+		if resp.ID < 0 || resp.ID >= len(b) {
+			continue
+		}
+
+		elem := &b[resp.ID]
+		responded[resp.ID] = true
+
+		switch {
+		case resp.Error != nil:
+			elem.Error = resp.Error
+		case elem.Result != nil && len(resp.Result) > 0:
+			elem.Error = json.Unmarshal(resp.Result, elem.Result)
+		}
+	}
+
+	for i, ok := range responded {
+		if !ok {
+			b[i].Error = errors.New("missing batch response")
+		}
+	}
+
+	return nil
+}
+
+// post sends JSON-RPC request and decodes the response.
+func (p *httpProvider) post(ctx context.Context, msg, resp interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	httpResp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return fmt.Errorf("%v", httpResp.Status)
+	}
+
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (p *httpProvider) Subscribe(
+	ctx context.Context, namespace string, channel interface{}, args ...interface{},
+) (*rpc.ClientSubscription, error) {
+	return nil, rpc.ErrNotificationsUnsupported
+}
+
+func (p *httpProvider) SubscribeWithReconn(
+	ctx context.Context, namespace string, channel interface{}, args ...interface{},
+) *rpc.ReconnClientSubscription {
+	return nil
+}
+
+// Close releases the shared transport, which is still available for other clients of the same
+// full node until released by all of them.
+func (p *httpProvider) Close() {
+	p.closeOnce.Do(p.transport.release)
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openweb3/go-rpc-provider"
+	providers "github.com/openweb3/go-rpc-provider/provider_wrapper"
+	"github.com/openweb3/go-rpc-provider/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handleTestRequest(req *jsonrpcRequest) *jsonrpcResponse {
+	resp := &jsonrpcResponse{ID: req.ID}
+
+	switch req.Method {
+	case "eth_chainId":
+		resp.Result = json.RawMessage(`"0x47"`)
+	default:
+		resp.Error = &rpc.JsonError{Code: -32601, Message: "method not found"}
+	}
+
+	return resp
+}
+
+func TestPooledProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&raw))
+
+		if raw[0] != '[' {
+			var req jsonrpcRequest
+			require.NoError(t, json.Unmarshal(raw, &req))
+			json.NewEncoder(w).Encode(handleTestRequest(&req))
+			return
+		}
+
+		var reqs []*jsonrpcRequest
+		require.NoError(t, json.Unmarshal(raw, &reqs))
+
+		// respond in reverse order
+		var resps []*jsonrpcResponse
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, handleTestRequest(reqs[i]))
+		}
+
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer server.Close()
+
+	conf := transportConfig{
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     time.Minute,
+		DialTimeout:         time.Second,
+	}
+	p := newPooledProvider(server.URL, providers.Option{RequestTimeout: time.Second}, &conf)
+	defer p.Close()
+
+	ctx := context.Background()
+
+	var chainId string
+	require.NoError(t, p.CallContext(ctx, &chainId, "eth_chainId"))
+	assert.Equal(t, "0x47", chainId)
+
+	err := p.CallContext(ctx, &chainId, "eth_unknown")
+	assert.Error(t, err)
+	assert.True(t, utils.IsRPCJSONError(err))
+
+	batch := []rpc.BatchElem{
+		{Method: "eth_chainId", Result: new(string)},
+		{Method: "eth_unknown", Result: new(string)},
+	}
+	require.NoError(t, p.BatchCallContext(ctx, batch))
+	assert.Equal(t, "0x47", *batch[0].Result.(*string))
+	assert.NoError(t, batch[0].Error)
+	assert.Error(t, batch[1].Error)
+
+	// connection reused by clients of the same full node
+	transport := getOrNewPooledTransport(server.URL, 0, &conf)
+	defer transport.release()
+	assert.Equal(t, int64(1), transport.conns)
+}
+
+func TestPooledTransportRelease(t *testing.T) {
+	conf := transportConfig{ConnRecycleInterval: time.Minute}
+
+	t1 := getOrNewPooledTransport("http://127.0.0.1:8545", 8, &conf)
+	t2 := getOrNewPooledTransport("http://127.0.0.1:8545", 8, &conf)
+	assert.Same(t, t1, t2)
+
+	// not shared by clients of different connection limit
+	t3 := getOrNewPooledTransport("http://127.0.0.1:8545", 16, &conf)
+	assert.NotSame(t, t1, t3)
+	assert.Equal(t, 16, t3.MaxConnsPerHost)
+	t3.release()
+
+	// still shared until released by all clients
+	t1.release()
+	assert.Same(t, t1, getOrNewPooledTransport("http://127.0.0.1:8545", 8, &conf))
+	t1.release()
+
+	t2.release()
+
+	t4 := getOrNewPooledTransport("http://127.0.0.1:8545", 8, &conf)
+	defer t4.release()
+	assert.NotSame(t, t1, t4)
+
+	select {
+	case <-t1.done: // recycling stopped
+	default:
+		assert.Fail(t, "transport not closed once released by all clients")
+	}
+}
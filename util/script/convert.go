@@ -0,0 +1,276 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+
+	"github.com/pkg/errors"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// metatable to mark tables converted from JSON objects, so that empty objects are kept as is,
+// which is shared by all Lua states and thus protected from scripts by `__metatable`.
+var objectMetatable = func() *lua.LTable {
+	tbl := &lua.LTable{Metatable: lua.LNil}
+	tbl.RawSetString("__metatable", lua.LString("object"))
+	return tbl
+}()
+
+// max integer that Lua number (float64) could represent exactly, beyond which JSON numbers are
+// passed to script as strings to avoid precision loss.
+const maxSafeInteger = 1<<53 - 1
+
+// requestToLua converts request to Lua table `{method = .., params = {..}}`.
+func requestToLua(L *lua.LState, req *Request) (lua.LValue, error) {
+	params, err := jsonToLua(L, req.Params)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid params")
+	}
+
+	tbl := L.NewTable()
+	tbl.RawSetString("method", lua.LString(req.Method))
+	tbl.RawSetString("params", params)
+
+	return tbl, nil
+}
+
+// luaToRequest converts Lua table `{method = .., params = {..}}` to request.
+func luaToRequest(value lua.LValue) (*Request, error) {
+	tbl, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, errors.Errorf("request table expected, got %v", value.Type())
+	}
+
+	method, ok := tbl.RawGetString("method").(lua.LString)
+	if !ok {
+		return nil, errors.New("method of request not string")
+	}
+
+	params, err := luaToJson(tbl.RawGetString("params"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid params")
+	}
+
+	return &Request{Method: string(method), Params: params}, nil
+}
+
+// responseToLua converts response to Lua table `{result = .., error = {code = .., message = ..}}`.
+func responseToLua(L *lua.LState, resp *Response) (lua.LValue, error) {
+	result, err := jsonToLua(L, resp.Result)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid result")
+	}
+
+	tbl := L.NewTable()
+	tbl.RawSetString("result", result)
+
+	if resp.Error != nil {
+		errTbl := L.NewTable()
+		errTbl.RawSetString("code", lua.LNumber(resp.Error.Code))
+		errTbl.RawSetString("message", lua.LString(resp.Error.Message))
+		tbl.RawSetString("error", errTbl)
+	}
+
+	return tbl, nil
+}
+
+// luaToResponse converts Lua table `{result = .., error = {code = .., message = ..}}` to response.
+func luaToResponse(value lua.LValue) (*Response, error) {
+	tbl, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, errors.Errorf("response table expected, got %v", value.Type())
+	}
+
+	if errValue := tbl.RawGetString("error"); errValue != lua.LNil {
+		return &Response{Error: luaToError(errValue)}, nil
+	}
+
+	result, err := luaToJson(tbl.RawGetString("result"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid result")
+	}
+
+	return &Response{Result: result}, nil
+}
+
+// luaToError converts Lua table `{code = .., message = ..}` or string message to RPC error.
+func luaToError(value lua.LValue) *Error {
+	err := &Error{Code: -32000, Message: "request rejected by script"}
+
+	switch v := value.(type) {
+	case lua.LString:
+		err.Message = string(v)
+	case *lua.LTable:
+		if code, ok := v.RawGetString("code").(lua.LNumber); ok {
+			err.Code = int(code)
+		}
+
+		if msg, ok := v.RawGetString("message").(lua.LString); ok {
+			err.Message = string(msg)
+		}
+	}
+
+	return err
+}
+
+// jsonToLua converts JSON to Lua value, where null or empty JSON is converted to nil.
+func jsonToLua(L *lua.LState, data json.RawMessage) (lua.LValue, error) {
+	if len(data) == 0 {
+		return lua.LNil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return goToLua(L, v), nil
+}
+
+func goToLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case json.Number:
+		if n, err := v.Float64(); err == nil && math.Abs(n) <= maxSafeInteger {
+			return lua.LNumber(n)
+		}
+
+		return lua.LString(v)
+	case []interface{}:
+		tbl := L.CreateTable(len(v), 0)
+		for i, elem := range v {
+			// nil is not allowed in Lua array, so null elements are exposed as global `null`
+			if elem == nil {
+				tbl.RawSetInt(i+1, L.GetGlobal(nullGlobal))
+			} else {
+				tbl.RawSetInt(i+1, goToLua(L, elem))
+			}
+		}
+
+		return tbl
+	case map[string]interface{}:
+		tbl := L.CreateTable(0, len(v))
+		tbl.Metatable = objectMetatable
+
+		for key, elem := range v {
+			tbl.RawSetString(key, goToLua(L, elem))
+		}
+
+		return tbl
+	default:
+		return lua.LNil
+	}
+}
+
+// luaToJson converts Lua value to JSON, where nil is converted to empty JSON.
+func luaToJson(value lua.LValue) (json.RawMessage, error) {
+	if value == lua.LNil {
+		return nil, nil
+	}
+
+	v, err := luaToGo(value, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// max nesting depth of Lua tables to convert, which also prevents tables referencing themselves
+const maxTableDepth = 32
+
+func luaToGo(value lua.LValue, depth int) (interface{}, error) {
+	switch v := value.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case *lua.LUserData: // the global `null`, which is the only userdata available to scripts
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LString:
+		return string(v), nil
+	case lua.LNumber:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, errors.New("number not finite")
+		}
+
+		if f := float64(v); f == math.Trunc(f) && math.Abs(f) <= maxSafeInteger {
+			return int64(f), nil
+		}
+
+		return float64(v), nil
+	case *lua.LTable:
+		if depth >= maxTableDepth {
+			return nil, errors.New("table nested too deep")
+		}
+
+		return luaTableToGo(v, depth+1)
+	default:
+		return nil, errors.Errorf("unsupported type %v", value.Type())
+	}
+}
+
+// luaTableToGo converts Lua table to JSON array if keys are sequential integers from 1, otherwise
+// to JSON object with string keys. Note, empty table is converted to empty array unless converted
+// from JSON object.
+func luaTableToGo(tbl *lua.LTable, depth int) (interface{}, error) {
+	if n := tbl.Len(); tbl.Metatable != objectMetatable && (n > 0 || isEmptyTable(tbl)) {
+		arr := make([]interface{}, 0, n)
+
+		for i := 1; i <= n; i++ {
+			elem, err := luaToGo(tbl.RawGetInt(i), depth)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, elem)
+		}
+
+		if countTableKeys(tbl) == n {
+			return arr, nil
+		}
+	}
+
+	obj := make(map[string]interface{})
+
+	var err error
+	tbl.ForEach(func(key, value lua.LValue) {
+		if err != nil {
+			return
+		}
+
+		var k string
+		switch key := key.(type) {
+		case lua.LString:
+			k = string(key)
+		case lua.LNumber:
+			k = key.String()
+		default:
+			err = errors.Errorf("unsupported key type %v", key.Type())
+			return
+		}
+
+		obj[k], err = luaToGo(value, depth)
+	})
+
+	return obj, err
+}
+
+func isEmptyTable(tbl *lua.LTable) bool {
+	key, _ := tbl.Next(lua.LNil)
+	return key == lua.LNil
+}
+
+func countTableKeys(tbl *lua.LTable) (n int) {
+	tbl.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
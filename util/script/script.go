@@ -0,0 +1,270 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	// hook function to rewrite request, eg., `function on_request(req) ... return req end`
+	hookOnRequest = "on_request"
+	// hook function to rewrite response, eg., `function on_response(req, resp) ... return resp end`
+	hookOnResponse = "on_response"
+
+	// global value of JSON null, eg., `params[2] == null`
+	nullGlobal = "null"
+)
+
+var (
+	ErrBudgetExceeded = errors.New("script exceeded the time budget")
+
+	// functions of base library unavailable to scripts, which access files or load code
+	unsafeBaseFuncs = []string{"dofile", "loadfile", "load", "loadstring", "print", "collectgarbage"}
+)
+
+// Budget of resources to run a hook function of script.
+type Budget struct {
+	// max duration to run a hook function, beyond which script is interrupted
+	Timeout time.Duration `default:"10ms"`
+	// max depth of Lua call stack
+	CallStackSize int `default:"120"`
+	// max size of Lua data stack
+	RegistrySize int `default:"5120"`
+}
+
+// Error is the JSON-RPC error returned by script to reject request, eg.,
+// `return nil, {code = -32602, message = "gas required"}`.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) ErrorCode() int { return e.Code }
+
+// Request is the JSON-RPC request exposed to script as table `{method = .., params = {..}}`.
+type Request struct {
+	Method string
+	Params json.RawMessage
+}
+
+// Response is the JSON-RPC response exposed to script as table `{result = .., error = {..}}`.
+type Response struct {
+	Result json.RawMessage
+	Error  *Error
+}
+
+// Script is a compiled Lua script of request/response rewriting hooks, which is executed in pooled
+// sandboxed Lua states that only provide the base (without file access), table, string and math
+// libraries. Note, global variables are kept across calls in the same Lua state.
+type Script struct {
+	name   string
+	budget Budget
+	proto  *lua.FunctionProto
+
+	onRequest, onResponse bool // whether hook functions defined
+
+	states sync.Pool // pooled *lua.LState
+}
+
+// Load loads Lua script from file.
+func Load(path string, budget Budget) (*Script, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read script file")
+	}
+
+	return Compile(path, string(source), budget)
+}
+
+// Compile compiles Lua script, which must define either or both of the `on_request` and
+// `on_response` hook functions.
+func Compile(name, source string, budget Budget) (*Script, error) {
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to parse script")
+	}
+
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to compile script")
+	}
+
+	s := &Script{name: name, budget: budget, proto: proto}
+
+	L, err := s.newState()
+	if err != nil {
+		return nil, err
+	}
+	defer L.Close()
+
+	s.onRequest = L.GetGlobal(hookOnRequest).Type() == lua.LTFunction
+	s.onResponse = L.GetGlobal(hookOnResponse).Type() == lua.LTFunction
+
+	if !s.onRequest && !s.onResponse {
+		return nil, errors.Errorf("neither %v nor %v function defined", hookOnRequest, hookOnResponse)
+	}
+
+	return s, nil
+}
+
+// Name returns the name of script, eg., file path.
+func (s *Script) Name() string {
+	return s.name
+}
+
+// newState creates a sandboxed Lua state with the script loaded.
+func (s *Script) newState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:  true,
+		CallStackSize: s.budget.CallStackSize,
+		RegistrySize:  s.budget.RegistrySize,
+	})
+
+	libs := []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+
+	for _, lib := range libs {
+		if err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.open), Protect: true}, lua.LString(lib.name)); err != nil {
+			L.Close()
+			return nil, errors.WithMessagef(err, "failed to open %v library", lib.name)
+		}
+	}
+
+	for _, fn := range unsafeBaseFuncs {
+		L.SetGlobal(fn, lua.LNil)
+	}
+
+	L.SetGlobal(nullGlobal, L.NewUserData())
+
+	// run the main chunk to define hook functions
+	if err := s.call(L, L.NewFunctionFromProto(s.proto), 0); err != nil {
+		L.Close()
+		return nil, errors.WithMessage(err, "failed to run script")
+	}
+
+	return L, nil
+}
+
+// call calls Lua function within the time budget.
+func (s *Script) call(L *lua.LState, fn lua.LValue, nret int, args ...lua.LValue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.budget.Timeout)
+	defer cancel()
+
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	err := L.CallByParam(lua.P{Fn: fn, NRet: nret, Protect: true}, args...)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrBudgetExceeded
+	}
+
+	return err
+}
+
+// hook calls the hook function with a pooled Lua state, and returns the 2 results.
+func (s *Script) hook(
+	name string, args func(L *lua.LState) ([]lua.LValue, error),
+) (lua.LValue, lua.LValue, error) {
+	L, ok := s.states.Get().(*lua.LState)
+	if !ok {
+		var err error
+		if L, err = s.newState(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	values, err := args(L)
+	if err != nil {
+		s.states.Put(L)
+		return nil, nil, err
+	}
+
+	if err := s.call(L, L.GetGlobal(name), 2, values...); err != nil {
+		// state might be inconsistent once interrupted
+		L.Close()
+		return nil, nil, err
+	}
+
+	ret, retErr := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+
+	s.states.Put(L)
+
+	return ret, retErr, nil
+}
+
+// OnRequest runs the `on_request(req)` hook if defined, which returns the rewritten request, or
+// nil if unchanged. Script could also return an error as the 2nd result to reject the request.
+// Note, method of request is read-only, since requests have been authorized by method.
+func (s *Script) OnRequest(req *Request) (*Request, *Error, error) {
+	if !s.onRequest {
+		return nil, nil, nil
+	}
+
+	ret, retErr, err := s.hook(hookOnRequest, func(L *lua.LState) ([]lua.LValue, error) {
+		arg, err := requestToLua(L, req)
+		return []lua.LValue{arg}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if retErr != lua.LNil {
+		return nil, luaToError(retErr), nil
+	}
+
+	if ret == lua.LNil {
+		return nil, nil, nil
+	}
+
+	rewritten, err := luaToRequest(ret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rewritten.Method != req.Method {
+		return nil, nil, errors.Errorf("method of request is read-only, got %v", rewritten.Method)
+	}
+
+	return rewritten, nil, nil
+}
+
+// OnResponse runs the `on_response(req, resp)` hook if defined, which returns the rewritten
+// response, or nil if unchanged.
+func (s *Script) OnResponse(req *Request, resp *Response) (*Response, error) {
+	if !s.onResponse {
+		return nil, nil
+	}
+
+	ret, _, err := s.hook(hookOnResponse, func(L *lua.LState) ([]lua.LValue, error) {
+		reqArg, err := requestToLua(L, req)
+		if err != nil {
+			return nil, err
+		}
+
+		respArg, err := responseToLua(L, resp)
+		return []lua.LValue{reqArg, respArg}, err
+	})
+	if err != nil || ret == lua.LNil {
+		return nil, err
+	}
+
+	return luaToResponse(ret)
+}
@@ -0,0 +1,241 @@
+package script
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBudget = Budget{Timeout: 100 * time.Millisecond, CallStackSize: 120, RegistrySize: 5120}
+
+func TestCompileScript(t *testing.T) {
+	_, err := Compile("syntax", "function on_request(req", testBudget)
+	assert.Error(t, err)
+
+	_, err = Compile("nohook", "local x = 1", testBudget)
+	assert.Error(t, err)
+
+	s, err := Compile("ok", "function on_response(req, resp) end", testBudget)
+	require.NoError(t, err)
+
+	// on_request not defined
+	req, rpcErr, err := s.OnRequest(&Request{Method: "eth_call", Params: json.RawMessage(`[]`)})
+	assert.Nil(t, req)
+	assert.Nil(t, rpcErr)
+	assert.NoError(t, err)
+}
+
+func TestLoadScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hook.lua")
+	require.NoError(t, ioutil.WriteFile(path, []byte("function on_request(req) return req end"), 0644))
+
+	s, err := Load(path, testBudget)
+	require.NoError(t, err)
+	assert.Equal(t, path, s.Name())
+
+	_, err = Load(filepath.Join(dir, "missing.lua"), testBudget)
+	assert.Error(t, err)
+}
+
+func TestScriptOnRequest(t *testing.T) {
+	s, err := Compile("rewrite", `
+function on_request(req)
+	if req.method == "eth_getBalance" and req.params[2] == "pending" then
+		req.params[2] = "latest"
+		return req
+	end
+
+	if req.method == "eth_estimateGas" and req.params[1].gas == nil then
+		req.params[1].gas = "0x5208"
+		return req
+	end
+
+	if req.method == "eth_getLogs" then
+		return nil, {code = -32602, message = "eth_getLogs disabled"}
+	end
+
+	if req.method == "eth_sign" then
+		return nil, "eth_sign disabled"
+	end
+end
+`, testBudget)
+	require.NoError(t, err)
+
+	// rewrite block tag
+	req, rpcErr, err := s.OnRequest(&Request{
+		Method: "eth_getBalance",
+		Params: json.RawMessage(`["0x0000000000000000000000000000000000000001","pending"]`),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, "eth_getBalance", req.Method)
+	assert.JSONEq(t, `["0x0000000000000000000000000000000000000001","latest"]`, string(req.Params))
+
+	// inject default gas
+	req, _, err = s.OnRequest(&Request{
+		Method: "eth_estimateGas",
+		Params: json.RawMessage(`[{"to":"0x0000000000000000000000000000000000000001","value":1}]`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"to":"0x0000000000000000000000000000000000000001","value":1,"gas":"0x5208"}]`, string(req.Params))
+
+	// unchanged
+	req, rpcErr, err = s.OnRequest(&Request{Method: "eth_blockNumber"})
+	assert.NoError(t, err)
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, req)
+
+	// rejected
+	_, rpcErr, err = s.OnRequest(&Request{Method: "eth_getLogs", Params: json.RawMessage(`[{}]`)})
+	assert.NoError(t, err)
+	assert.Equal(t, &Error{Code: -32602, Message: "eth_getLogs disabled"}, rpcErr)
+
+	_, rpcErr, err = s.OnRequest(&Request{Method: "eth_sign"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Error{Code: -32000, Message: "eth_sign disabled"}, rpcErr)
+}
+
+func TestScriptMethodReadOnly(t *testing.T) {
+	s, err := Compile("method", `
+function on_request(req)
+	req.method = "debug_traceTransaction"
+	return req
+end
+`, testBudget)
+	require.NoError(t, err)
+
+	_, _, err = s.OnRequest(&Request{Method: "eth_getTransactionByHash"})
+	assert.Error(t, err)
+}
+
+func TestScriptDropParams(t *testing.T) {
+	s, err := Compile("drop", `
+function on_request(req)
+	table.remove(req.params, 3)
+	return req
+end
+`, testBudget)
+	require.NoError(t, err)
+
+	req, _, err := s.OnRequest(&Request{
+		Method: "eth_call",
+		Params: json.RawMessage(`[{"data":"0x"},"latest",{"0x01":{}}]`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"data":"0x"},"latest"]`, string(req.Params))
+}
+
+func TestScriptOnResponse(t *testing.T) {
+	s, err := Compile("response", `
+function on_response(req, resp)
+	if req.method == "eth_chainId" then
+		resp.result = "0x1"
+		return resp
+	end
+
+	if resp.error ~= nil and resp.error.code == -32016 then
+		resp.error.message = "execution reverted"
+		return resp
+	end
+end
+`, testBudget)
+	require.NoError(t, err)
+
+	resp, err := s.OnResponse(&Request{Method: "eth_chainId"}, &Response{Result: json.RawMessage(`"0x2"`)})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, `"0x1"`, string(resp.Result))
+
+	resp, err = s.OnResponse(&Request{Method: "eth_call"}, &Response{Error: &Error{Code: -32016, Message: "reverted: 0x"}})
+	require.NoError(t, err)
+	assert.Equal(t, &Error{Code: -32016, Message: "execution reverted"}, resp.Error)
+
+	// unchanged
+	resp, err = s.OnResponse(&Request{Method: "eth_call"}, &Response{Result: json.RawMessage(`"0x"`)})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestScriptJsonConversion(t *testing.T) {
+	s, err := Compile("echo", "function on_request(req) return req end", testBudget)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		params   string
+		expected string
+	}{
+		{`[]`, `[]`},
+		{`[{}]`, `[{}]`},
+		{`[null,1,"a",true]`, `[null,1,"a",true]`},
+		{`[{"a":[1,2.5,{"b":null}]}]`, `[{"a":[1,2.5,{}]}]`}, // null fields dropped from objects
+		{`[9007199254740991]`, `[9007199254740991]`},
+		{`[18446744073709551615]`, `["18446744073709551615"]`}, // big integers passed as strings
+	}
+
+	for _, tc := range testCases {
+		req, _, err := s.OnRequest(&Request{Method: "echo", Params: json.RawMessage(tc.params)})
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.expected, string(req.Params), tc.params)
+	}
+}
+
+func TestScriptBudget(t *testing.T) {
+	s, err := Compile("loop", `
+function on_request(req)
+	if req.method == "loop" then
+		while true do end
+	end
+
+	return req
+end
+`, Budget{Timeout: 20 * time.Millisecond, CallStackSize: 120, RegistrySize: 5120})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, _, err = s.OnRequest(&Request{Method: "loop"})
+	assert.Equal(t, ErrBudgetExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// works as usual after interrupted
+	req, _, err := s.OnRequest(&Request{Method: "eth_blockNumber"})
+	assert.NoError(t, err)
+	assert.Equal(t, "eth_blockNumber", req.Method)
+}
+
+func TestScriptSandbox(t *testing.T) {
+	for _, source := range []string{
+		`dofile("/etc/passwd")`,
+		`loadstring("return 1")`,
+		`os.exit(1)`,
+		`io.open("/etc/passwd")`,
+		`require("os")`,
+	} {
+		s, err := Compile("sandbox", "function on_request(req) "+source+" end", testBudget)
+		require.NoError(t, err)
+
+		_, _, err = s.OnRequest(&Request{Method: "eth_blockNumber"})
+		assert.Error(t, err, source)
+	}
+}
+
+func TestScriptObjectMetatable(t *testing.T) {
+	s, err := Compile("meta", `
+function on_request(req)
+	setmetatable(req.params[1], nil)
+end
+`, testBudget)
+	require.NoError(t, err)
+
+	_, _, err = s.OnRequest(&Request{Method: "eth_call", Params: json.RawMessage(`[{}]`)})
+	assert.Error(t, err)
+}
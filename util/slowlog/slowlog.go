@@ -0,0 +1,196 @@
+// Package slowlog captures expensive RPC calls, i.e., calls exceeding the latency or block range
+// threshold, with the size-capped params, upstream full node and trace ID, which are persisted
+// to a rotating file or store for later analysis.
+package slowlog
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/accesslog"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// supported slow query sinks
+	SinkFile  = "file"
+	SinkStore = "store"
+
+	// spaces of slow queries
+	SpaceCfx = "cfx"
+	SpaceEth = "eth"
+)
+
+// Config slow query log configurations.
+type Config struct {
+	// switch to turn on/off slow query log
+	Enabled bool
+	// sink to persist slow queries, available sinks are `file` and `store`
+	Sink string `default:"file"`
+	// rotating file to write slow queries
+	File accesslog.FileConfig
+	// latency threshold, beyond which calls are logged
+	Latency time.Duration `default:"3s"`
+	// block (or epoch) range threshold of filter params, beyond which calls are logged, or
+	// no block range check if 0
+	BlockRange uint64 `default:"1000"`
+	// max size in bytes of params to log, beyond which params are truncated
+	MaxParamsSize int `default:"4096"`
+	// max number of slow queries buffered, beyond which slow queries are dropped
+	BufferSize int `default:"1000"`
+}
+
+// Query slow RPC call.
+type Query struct {
+	Time       time.Time `json:"time"`
+	Space      string    `json:"space"`
+	Method     string    `json:"method"`
+	Params     string    `json:"params,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	BlockRange uint64    `json:"blockRange,omitempty"`
+	Upstream   string    `json:"upstream,omitempty"`
+	TraceId    string    `json:"traceId,omitempty"`
+	Caller     string    `json:"caller,omitempty"` // ID of API key
+	ErrorCode  int       `json:"errorCode,omitempty"`
+}
+
+// Store persists slow queries.
+type Store interface {
+	AddSlowQueries(queries []*Query) error
+}
+
+// Logger buffers slow queries and persists them asynchronously, so that the slow calls are not
+// slowed down any further.
+type Logger struct {
+	conf    *Config
+	file    *accesslog.RotatingFile
+	queries chan *Query
+
+	mu     sync.Mutex
+	stores map[string]Store // space => store
+}
+
+// MustNewLoggerFromViper creates slow query logger from viper, or nil if disabled.
+func MustNewLoggerFromViper() *Logger {
+	var conf Config
+	viper.MustUnmarshalKey("slowLog", &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	var file *accesslog.RotatingFile
+
+	switch conf.Sink {
+	case SinkFile:
+		var err error
+		if file, err = accesslog.NewRotatingFile(conf.File); err != nil {
+			logrus.WithError(err).Fatal("Failed to create slow query log file")
+		}
+	case SinkStore:
+	default:
+		logrus.WithField("sink", conf.Sink).Fatal("Unsupported slow query log sink")
+	}
+
+	logrus.WithField("config", conf).Info("RPC slow query log enabled")
+
+	return NewLogger(&conf, file)
+}
+
+// NewLogger creates slow query logger, which writes slow queries to the file if not nil, or to
+// the store of each space.
+func NewLogger(conf *Config, file *accesslog.RotatingFile) *Logger {
+	l := &Logger{
+		conf:    conf,
+		file:    file,
+		queries: make(chan *Query, conf.BufferSize),
+		stores:  make(map[string]Store),
+	}
+
+	go l.loop()
+
+	return l
+}
+
+// SetStore sets the store to persist slow queries of the space, which is nil safe.
+func (l *Logger) SetStore(space string, store Store) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stores[space] = store
+}
+
+func (l *Logger) store(space string) (Store, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	store, ok := l.stores[space]
+	return store, ok
+}
+
+// IsSlow checks if the call is slow by latency or block range.
+func (l *Logger) IsSlow(duration time.Duration, blockRange uint64) bool {
+	if duration >= l.conf.Latency {
+		return true
+	}
+
+	return l.conf.BlockRange > 0 && blockRange > l.conf.BlockRange
+}
+
+// TruncateParams returns params capped to the max size.
+func (l *Logger) TruncateParams(params json.RawMessage) string {
+	if len(params) <= l.conf.MaxParamsSize {
+		return string(params)
+	}
+
+	return string(params[:l.conf.MaxParamsSize]) + "...(truncated)"
+}
+
+// Log enqueues slow query to persist, which is dropped if buffer is full.
+func (l *Logger) Log(query *Query) {
+	select {
+	case l.queries <- query:
+	default:
+		metrics.Registry.RPC.SlowQueries(query.Space, "dropped").Mark(1)
+	}
+}
+
+func (l *Logger) loop() {
+	for query := range l.queries {
+		if ok, err := l.persist(query); !ok {
+			metrics.Registry.RPC.SlowQueries(query.Space, "dropped").Mark(1)
+		} else if err != nil {
+			logrus.WithError(err).WithField("query", query).Info("Failed to persist slow query")
+			metrics.Registry.RPC.SlowQueries(query.Space, "failed").Mark(1)
+		} else {
+			metrics.Registry.RPC.SlowQueries(query.Space, "persisted").Mark(1)
+		}
+	}
+}
+
+// persist persists the slow query, or returns false if no sink available, eg., database disabled.
+func (l *Logger) persist(query *Query) (bool, error) {
+	if l.file == nil {
+		store, ok := l.store(query.Space)
+		if !ok {
+			return false, nil
+		}
+
+		return true, store.AddSlowQueries([]*Query{query})
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = l.file.Write(append(data, '\n'))
+	return true, err
+}
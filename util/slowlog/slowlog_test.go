@@ -0,0 +1,81 @@
+package slowlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/accesslog"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	queries []*Query
+}
+
+func (s *memStore) AddSlowQueries(queries []*Query) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, queries...)
+	return nil
+}
+
+func (s *memStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queries)
+}
+
+func TestLoggerIsSlow(t *testing.T) {
+	logger := NewLogger(&Config{Latency: time.Second, BlockRange: 100, MaxParamsSize: 8}, nil)
+
+	assert.True(t, logger.IsSlow(2*time.Second, 0))
+	assert.True(t, logger.IsSlow(time.Millisecond, 101))
+	assert.False(t, logger.IsSlow(time.Millisecond, 100))
+
+	logger.conf.BlockRange = 0
+	assert.False(t, logger.IsSlow(time.Millisecond, 10000))
+
+	assert.Equal(t, `["0x1"]`, logger.TruncateParams(json.RawMessage(`["0x1"]`)))
+	assert.Equal(t, `["0x1234...(truncated)`, logger.TruncateParams(json.RawMessage(`["0x123456789"]`)))
+}
+
+func TestLoggerStore(t *testing.T) {
+	logger := NewLogger(&Config{BufferSize: 10}, nil)
+
+	store := &memStore{}
+	logger.SetStore(SpaceEth, store)
+
+	// no store for core space
+	logger.Log(&Query{Space: SpaceCfx, Method: "cfx_getLogs"})
+	logger.Log(&Query{Space: SpaceEth, Method: "eth_getLogs"})
+
+	assert.Eventually(t, func() bool { return store.len() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "eth_getLogs", store.queries[0].Method)
+}
+
+func TestLoggerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slowlog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "slow.log")
+	file, err := accesslog.NewRotatingFile(accesslog.FileConfig{Path: path, MaxSizeMB: 1})
+	assert.NoError(t, err)
+
+	logger := NewLogger(&Config{BufferSize: 10}, file)
+	logger.Log(&Query{Space: SpaceEth, Method: "eth_getLogs", TraceId: "trace1"})
+
+	assert.Eventually(t, func() bool {
+		data, _ := ioutil.ReadFile(path)
+		return strings.Contains(string(data), `"traceId":"trace1"`)
+	}, time.Second, 10*time.Millisecond)
+}
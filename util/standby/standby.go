@@ -0,0 +1,244 @@
+// Package standby supports to start service instance in warm standby mode, which gets fully
+// prepared (configs loaded, backends connected, and caches of node routes and rate limit keys
+// warmed from store) without serving traffic until promoted, so as to achieve near-instant
+// failover.
+package standby
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// State is the lifecycle state of standby instance.
+type State string
+
+const (
+	// StateWarming preflight checks are still in progress
+	StateWarming State = "warming"
+	// StateReady all preflight checks passed and ready to be promoted
+	StateReady State = "ready-to-promote"
+	// StatePromoted promoted to serve traffic
+	StatePromoted State = "promoted"
+)
+
+var (
+	ErrNotReady         = errors.New("preflight checks not passed yet")
+	ErrAlreadyPromoted  = errors.New("already promoted")
+	ErrStandbyDisabled  = errors.New("warm standby mode not enabled")
+	errPreflightPending = errors.New("pending")
+)
+
+// Config warm standby configurations.
+type Config struct {
+	// switch to turn on/off warm standby mode
+	Enabled bool
+	// interval to retry the failed preflight checks
+	RetryInterval time.Duration `default:"5s"`
+}
+
+// PreflightFunc checks or prepares resources before serving traffic.
+type PreflightFunc func() error
+
+type preflight struct {
+	name  string
+	check PreflightFunc
+	err   error
+}
+
+// Status is the status of standby instance.
+type Status struct {
+	State State
+	// preflight name => error message if failed, or empty if passed
+	Preflights map[string]string
+	ReadyAt    *time.Time
+	PromotedAt *time.Time
+}
+
+// Controller runs preflight checks and defers serving traffic until promoted.
+type Controller struct {
+	conf *Config
+
+	mu         sync.Mutex
+	state      State
+	preflights []*preflight
+	deferred   []func() // deferred functions to serve traffic
+	readyAt    *time.Time
+	promotedAt *time.Time
+}
+
+// MustNewControllerFromViper creates standby controller from viper, which serves traffic
+// immediately if warm standby mode not enabled.
+func MustNewControllerFromViper() *Controller {
+	var conf Config
+	viper.MustUnmarshalKey("standby", &conf)
+
+	return NewController(&conf)
+}
+
+func NewController(conf *Config) *Controller {
+	c := &Controller{conf: conf, state: StateWarming}
+
+	if !conf.Enabled {
+		now := time.Now()
+		c.state, c.promotedAt = StatePromoted, &now
+	}
+
+	return c
+}
+
+// Enabled returns whether the warm standby mode is enabled.
+func (c *Controller) Enabled() bool {
+	return c.conf.Enabled
+}
+
+// AddPreflight adds a preflight check to be passed before ready to promote, which is ignored
+// if warm standby mode not enabled.
+func (c *Controller) AddPreflight(name string, check PreflightFunc) {
+	if !c.conf.Enabled {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.preflights = append(c.preflights, &preflight{
+		name: name, check: check, err: errPreflightPending,
+	})
+}
+
+// Serve executes the function to serve traffic once promoted, or immediately if already promoted.
+func (c *Controller) Serve(fn func()) {
+	c.mu.Lock()
+
+	if c.state != StatePromoted {
+		c.deferred = append(c.deferred, fn)
+		c.mu.Unlock()
+		return
+	}
+
+	c.mu.Unlock()
+	fn()
+}
+
+// Run runs all preflight checks repeatedly until passed or context done.
+func (c *Controller) Run(ctx context.Context) {
+	if !c.conf.Enabled {
+		return
+	}
+
+	logrus.Info("Instance started in warm standby mode")
+
+	ticker := time.NewTicker(c.conf.RetryInterval)
+	defer ticker.Stop()
+
+	for !c.runPreflights() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPreflights runs all the failed preflight checks, and returns true if all passed.
+func (c *Controller) runPreflights() bool {
+	c.mu.Lock()
+	preflights := append([]*preflight(nil), c.preflights...)
+	c.mu.Unlock()
+
+	passed := true
+	for _, pf := range preflights {
+		if pf.err == nil {
+			continue
+		}
+
+		err := pf.check()
+
+		c.mu.Lock()
+		pf.err = err
+		c.mu.Unlock()
+
+		if err != nil {
+			passed = false
+			logrus.WithField("preflight", pf.name).WithError(err).Warn("Standby preflight check failed")
+		}
+	}
+
+	if !passed {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == StateWarming {
+		now := time.Now()
+		c.state, c.readyAt = StateReady, &now
+
+		logrus.WithField("preflights", len(preflights)).Info("Standby instance is ready to promote")
+	}
+
+	return true
+}
+
+// Promote promotes the ready standby instance to serve traffic.
+func (c *Controller) Promote() error {
+	if !c.conf.Enabled {
+		return ErrStandbyDisabled
+	}
+
+	c.mu.Lock()
+
+	switch c.state {
+	case StateWarming:
+		c.mu.Unlock()
+		return ErrNotReady
+	case StatePromoted:
+		c.mu.Unlock()
+		return ErrAlreadyPromoted
+	}
+
+	now := time.Now()
+	c.state, c.promotedAt = StatePromoted, &now
+
+	deferred := c.deferred
+	c.deferred = nil
+
+	c.mu.Unlock()
+
+	for _, fn := range deferred {
+		fn()
+	}
+
+	logrus.WithField("readyAt", c.readyAt).Info("Standby instance promoted to serve traffic")
+
+	return nil
+}
+
+// Status returns the current status of standby instance.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := Status{
+		State:      c.state,
+		Preflights: make(map[string]string, len(c.preflights)),
+		ReadyAt:    c.readyAt,
+		PromotedAt: c.promotedAt,
+	}
+
+	for _, pf := range c.preflights {
+		if pf.err != nil {
+			status.Preflights[pf.name] = pf.err.Error()
+		} else {
+			status.Preflights[pf.name] = ""
+		}
+	}
+
+	return status
+}
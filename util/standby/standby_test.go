@@ -0,0 +1,50 @@
+package standby
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerDisabled(t *testing.T) {
+	c := NewController(&Config{})
+
+	served := false
+	c.Serve(func() { served = true })
+
+	assert.True(t, served)
+	assert.Equal(t, StatePromoted, c.Status().State)
+	assert.Equal(t, ErrStandbyDisabled, c.Promote())
+}
+
+func TestControllerPromote(t *testing.T) {
+	c := NewController(&Config{Enabled: true})
+
+	healthy := false
+	c.AddPreflight("backend", func() error {
+		if !healthy {
+			return errors.New("backend unavailable")
+		}
+		return nil
+	})
+
+	served := false
+	c.Serve(func() { served = true })
+
+	assert.False(t, c.runPreflights())
+	assert.Equal(t, StateWarming, c.Status().State)
+	assert.Equal(t, "backend unavailable", c.Status().Preflights["backend"])
+	assert.Equal(t, ErrNotReady, c.Promote())
+	assert.False(t, served)
+
+	healthy = true
+	assert.True(t, c.runPreflights())
+	assert.Equal(t, StateReady, c.Status().State)
+	assert.Empty(t, c.Status().Preflights["backend"])
+
+	assert.NoError(t, c.Promote())
+	assert.True(t, served)
+	assert.Equal(t, StatePromoted, c.Status().State)
+	assert.Equal(t, ErrAlreadyPromoted, c.Promote())
+}
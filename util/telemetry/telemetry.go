@@ -0,0 +1,184 @@
+// Package telemetry provides opt-in reporting of anonymized deployment statistics, which helps
+// maintainers to prioritize features. It is disabled by default, and no information that could
+// identify the deployment (e.g., IP address, hostname or access keys) is ever collected.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Conflux-Chain/confura/config"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// qpsBuckets upper bounds of the aggregate QPS buckets
+var qpsBuckets = []struct {
+	bound float64
+	name  string
+}{
+	{1, "<1"}, {10, "1-10"}, {100, "10-100"}, {1000, "100-1k"}, {10000, "1k-10k"},
+}
+
+type reporterConfig struct {
+	// switch to turn on/off telemetry, which is off by default
+	Enabled bool
+	// endpoint to which the stats are posted
+	Endpoint string
+	// interval to report stats
+	Interval time.Duration `default:"24h"`
+	// only logs the stats without reporting if true
+	DryRun bool
+}
+
+// Stats anonymized deployment statistics to report.
+type Stats struct {
+	// random identifier generated on each startup
+	InstanceId string `json:"instanceId"`
+	Version    string `json:"version"`
+	GitCommit  string `json:"gitCommit"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	GoVersion  string `json:"goVersion"`
+	// enabled features or services
+	Features []string `json:"features"`
+	// aggregate RPC QPS bucket
+	QpsBucket string `json:"qpsBucket"`
+	// seconds since startup
+	Uptime int64 `json:"uptime"`
+}
+
+// Reporter periodically reports anonymized deployment statistics.
+type Reporter struct {
+	conf       *reporterConfig
+	instanceId string
+	features   []string
+	startedAt  time.Time
+	httpClient *http.Client
+}
+
+// MustNewReporterFromViper creates a telemetry reporter with the enabled features, or returns
+// false if telemetry is disabled.
+func MustNewReporterFromViper(features ...string) (*Reporter, bool) {
+	var conf reporterConfig
+	viper.MustUnmarshalKey("telemetry", &conf)
+
+	if !conf.Enabled {
+		return nil, false
+	}
+
+	if len(conf.Endpoint) == 0 && !conf.DryRun {
+		logrus.Fatal("Telemetry endpoint must be configured unless in dry run mode")
+	}
+
+	if conf.Interval <= 0 {
+		logrus.WithField("interval", conf.Interval).Fatal("Invalid telemetry report interval")
+	}
+
+	return &Reporter{
+		conf:       &conf,
+		instanceId: newInstanceId(),
+		features:   features,
+		startedAt:  time.Now(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, true
+}
+
+func newInstanceId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Collect collects the current deployment statistics.
+func (r *Reporter) Collect() *Stats {
+	return &Stats{
+		InstanceId: r.instanceId,
+		Version:    config.Version,
+		GitCommit:  config.GitCommit,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		GoVersion:  runtime.Version(),
+		Features:   r.features,
+		QpsBucket:  qpsBucket(metrics.GetOrRegisterTimer("infura/rpc/duration/all").Rate1()),
+		Uptime:     int64(time.Since(r.startedAt).Seconds()),
+	}
+}
+
+// Run reports stats periodically until the context is done.
+func (r *Reporter) Run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+
+	logrus.WithField("config", r.conf).Info("Telemetry reporter started")
+
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.report(ctx); err != nil {
+				logrus.WithError(err).Debug("Failed to report telemetry stats")
+			}
+		}
+	}
+}
+
+func (r *Reporter) report(ctx context.Context) error {
+	stats := r.Collect()
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return errors.WithMessage(err, "failed to marshal stats")
+	}
+
+	// always log the report for inspection
+	logrus.WithField("stats", string(data)).Info("Telemetry stats collected")
+
+	if r.conf.DryRun {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.conf.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return errors.WithMessage(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return errors.WithMessage(err, "failed to post stats")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("unexpected response status %v", resp.Status)
+	}
+
+	return nil
+}
+
+func qpsBucket(qps float64) string {
+	for _, b := range qpsBuckets {
+		if qps < b.bound {
+			return b.name
+		}
+	}
+
+	return ">=10k"
+}
@@ -0,0 +1,199 @@
+// Package traffic records sanitized RPC request streams to rotating files, which could be replayed
+// later against a staging gateway by the `bench` subcommand, eg., to reproduce incidents or to
+// validate new routing and caching configurations.
+//
+// Requests are recorded as JSON lines of arrival time (unix milliseconds), method and params only,
+// so that neither caller identity (eg., API key or IP) nor responses are recorded. Methods which
+// carry signatures or secrets, eg., transaction submission, are excluded too.
+package traffic
+
+import (
+	"encoding/json"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/accesslog"
+	"github.com/Conflux-Chain/confura/util/metrics"
+	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultExcludeMethods methods never recorded even if excluded methods configured, since they
+// carry signed transactions or secrets, or take effect on chain once replayed.
+var defaultExcludeMethods = []string{
+	"*_sendRawTransaction", "*_sendTransaction", "*_sign*", "personal_*", "admin_*",
+}
+
+// Config traffic recording configurations.
+type Config struct {
+	// switch to turn on/off traffic recording
+	Enabled bool
+	// rotating file to record requests, whose name should end with `.jsonl` to be replayed
+	File accesslog.FileConfig
+	// fraction of requests to record, in range (0, 1]
+	SampleRate float64 `default:"1"`
+	// methods to record, or all methods if empty
+	Methods []string
+	// extra methods never recorded besides the default ones, which is matched by substring if
+	// wrapped with `*`, by suffix if starts with `*`, or by prefix if ends with `*`, eg., `*_sign*`,
+	// `*_sendRawTransaction` or `personal_*`
+	ExcludeMethods []string
+	// max size in bytes of params, beyond which requests are not recorded rather than truncated
+	MaxParamsSize int `default:"65536"`
+	// max number of requests buffered, beyond which requests are dropped
+	BufferSize int `default:"10000"`
+}
+
+// Record recorded RPC request, which is compatible with requests of `bench` traffic profile.
+type Record struct {
+	Time   int64           `json:"time"` // arrival time in unix milliseconds
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Recorder buffers sampled requests and writes them to file asynchronously, so that requests are
+// not slowed down.
+type Recorder struct {
+	conf    *Config
+	space   string // space of requests to record, eg., `cfx` or `eth`
+	file    *accesslog.RotatingFile
+	records chan *Record
+	methods map[string]bool
+	// methods never recorded, including the default ones
+	excludes []string
+}
+
+// MustNewRecorderFromViper creates traffic recorder of the space from viper, or nil if disabled.
+func MustNewRecorderFromViper(key, space string) *Recorder {
+	var conf Config
+	viper.MustUnmarshalKey(key, &conf)
+
+	if !conf.Enabled {
+		return nil
+	}
+
+	if conf.SampleRate <= 0 || conf.SampleRate > 1 || conf.BufferSize <= 0 {
+		logrus.WithField("config", conf).Fatal("Invalid traffic recording config")
+	}
+
+	// also prevents writing to the access log file by default
+	if filepath.Ext(conf.File.Path) != ".jsonl" {
+		logrus.WithField("path", conf.File.Path).Fatal("Traffic recording file name should end with `.jsonl`")
+	}
+
+	file, err := accesslog.NewRotatingFile(conf.File)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create traffic recording file")
+	}
+
+	logrus.WithField("config", conf).WithField("space", space).Info("RPC traffic recording enabled")
+
+	return NewRecorder(&conf, space, file)
+}
+
+func NewRecorder(conf *Config, space string, file *accesslog.RotatingFile) *Recorder {
+	r := &Recorder{
+		conf:    conf,
+		space:   space,
+		file:    file,
+		records: make(chan *Record, conf.BufferSize),
+		methods: make(map[string]bool),
+		// merged so that methods carrying secrets are never recorded by misconfiguration
+		excludes: append(append([]string(nil), defaultExcludeMethods...), conf.ExcludeMethods...),
+	}
+
+	for _, method := range conf.Methods {
+		r.methods[method] = true
+	}
+
+	go r.loop()
+
+	return r
+}
+
+// sampled checks if the request should be recorded.
+func (r *Recorder) sampled(msg *rpc.JsonRpcMessage) bool {
+	if len(r.methods) > 0 && !r.methods[msg.Method] {
+		return false
+	}
+
+	if excluded(r.excludes, msg.Method) {
+		return false
+	}
+
+	return r.conf.SampleRate >= 1 || rand.Float64() < r.conf.SampleRate
+}
+
+// Record enqueues the request to record, which is dropped if buffer is full.
+func (r *Recorder) Record(arrival time.Time, msg *rpc.JsonRpcMessage) {
+	if !r.sampled(msg) {
+		return
+	}
+
+	if len(msg.Params) > r.conf.MaxParamsSize {
+		metrics.Registry.RPC.TrafficRecords(r.space, "skipped").Mark(1)
+		return
+	}
+
+	record := &Record{
+		Time:   arrival.UnixNano() / int64(time.Millisecond),
+		Method: msg.Method,
+		// copied since params may be rewritten by later middlewares
+		Params: append(json.RawMessage(nil), msg.Params...),
+	}
+
+	select {
+	case r.records <- record:
+	default:
+		metrics.Registry.RPC.TrafficRecords(r.space, "dropped").Mark(1)
+	}
+}
+
+func (r *Recorder) loop() {
+	for record := range r.records {
+		if err := r.write(record); err != nil {
+			logrus.WithError(err).Debug("Failed to write traffic record")
+			metrics.Registry.RPC.TrafficRecords(r.space, "failed").Mark(1)
+		} else {
+			metrics.Registry.RPC.TrafficRecords(r.space, "recorded").Mark(1)
+		}
+	}
+}
+
+func (r *Recorder) write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+// excluded checks if the method matches any of the patterns, which is matched by substring if the
+// pattern is wrapped with `*`, by suffix if starts with `*`, or by prefix if ends with `*`.
+func excluded(patterns []string, method string) bool {
+	for _, p := range patterns {
+		switch {
+		case strings.HasPrefix(p, "*") && strings.HasSuffix(p, "*") && len(p) > 1:
+			if strings.Contains(method, p[1:len(p)-1]) {
+				return true
+			}
+		case strings.HasPrefix(p, "*"):
+			if strings.HasSuffix(method, p[1:]) {
+				return true
+			}
+		case strings.HasSuffix(p, "*"):
+			if strings.HasPrefix(method, p[:len(p)-1]) {
+				return true
+			}
+		case p == method:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package traffic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Conflux-Chain/confura/util/accesslog"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcluded(t *testing.T) {
+	for _, method := range []string{
+		"eth_sendRawTransaction", "cfx_sendTransaction", "eth_sign", "eth_signTypedData_v4", "personal_unlockAccount",
+	} {
+		assert.True(t, excluded(defaultExcludeMethods, method), method)
+	}
+
+	for _, method := range []string{"eth_call", "cfx_getLogs", "eth_getTransactionByHash", "net_version"} {
+		assert.False(t, excluded(defaultExcludeMethods, method), method)
+	}
+
+	assert.True(t, excluded([]string{"eth_call"}, "eth_call"))
+	assert.False(t, excluded([]string{"eth_call"}, "eth_callMany"))
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traffic")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "traffic.jsonl")
+	file, err := accesslog.NewRotatingFile(accesslog.FileConfig{Path: path, MaxSizeMB: 1})
+	assert.NoError(t, err)
+
+	recorder := NewRecorder(&Config{
+		SampleRate:     1,
+		ExcludeMethods: []string{"eth_getLogs"}, // merged with default ones
+		MaxParamsSize:  32,
+		BufferSize:     10,
+	}, "eth", file)
+
+	arrival := time.Unix(1700000000, 123456789)
+
+	params := json.RawMessage(`["0x1", false]`)
+	recorder.Record(arrival, &rpc.JsonRpcMessage{Method: "eth_getBlockByNumber", Params: params})
+	params[2] = 'x' // params rewritten after recorded
+
+	recorder.Record(arrival, &rpc.JsonRpcMessage{Method: "eth_sendRawTransaction", Params: json.RawMessage(`["0x00"]`)})
+	recorder.Record(arrival, &rpc.JsonRpcMessage{Method: "personal_sign", Params: json.RawMessage(`["0x00"]`)})
+	recorder.Record(arrival, &rpc.JsonRpcMessage{Method: "eth_getLogs", Params: json.RawMessage(`[{}]`)})
+	recorder.Record(arrival, &rpc.JsonRpcMessage{Method: "eth_getBlockReceipts", Params: json.RawMessage(`["0x0000000000000000000000000000"]`)})
+	recorder.Record(arrival.Add(time.Second), &rpc.JsonRpcMessage{Method: "eth_chainId"})
+
+	var records []Record
+	assert.Eventually(t, func() bool {
+		f, err := os.Open(path)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+
+		records = nil
+		for scanner := bufio.NewScanner(f); scanner.Scan(); {
+			var record Record
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records = append(records, record)
+		}
+
+		return len(records) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, Record{
+		Time: 1700000000123, Method: "eth_getBlockByNumber", Params: json.RawMessage(`["0x1",false]`),
+	}, records[0])
+	assert.Equal(t, Record{Time: 1700000001123, Method: "eth_chainId"}, records[1])
+}